@@ -0,0 +1,149 @@
+// Package audit keeps an append-only log of the blocks test
+// and run mode run: who ran what, where, when, and what became
+// of it, for teams driving production runbooks with mdrip.
+//
+// The log holds a schema.AuditRecord per line.  Each record
+// holds the hash of the one before, so changing, dropping or
+// reordering records breaks the chain, as Verify reports.  With a
+// key, the hashes are HMACs, so that only those holding the key
+// can write a chain that verifies.
+package audit
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/monopole/mdrip/schema"
+)
+
+// Log is an audit log open for appending.
+type Log struct {
+	f   *os.File
+	key []byte
+	// seq and last are the Seq and Hash of the log's last record.
+	seq  int
+	last string
+}
+
+// Open opens the log at path for appending, creating it if need
+// be.  It's an error if the records already there don't verify
+// with the key, since appending to a broken chain would hide
+// the break.  An empty key means the log isn't signed.
+func Open(path string, key []byte) (*Log, error) {
+	l := &Log{key: key}
+	if f, err := os.Open(path); err == nil {
+		l.seq, l.last, err = Verify(f, key)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("won't append to audit log %s: %v", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	l.f = f
+	return l, nil
+}
+
+// Append numbers the records, times them now, chains them to
+// those before, and writes them to the log.
+func (l *Log) Append(records []schema.AuditRecord) error {
+	for _, r := range records {
+		l.seq++
+		r.Seq = l.seq
+		r.Time = time.Now().UTC().Format(time.RFC3339)
+		r.Prev = l.last
+		r.Hash = ""
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		l.last = sum(l.key, data)
+		line := string(data[:len(data)-len(hashSuffix)]) + l.last + hashSuffix + "\n"
+		if _, err := io.WriteString(l.f, line); err != nil {
+			return err
+		}
+	}
+	return l.f.Sync()
+}
+
+// Close closes the log.
+func (l *Log) Close() error {
+	return l.f.Close()
+}
+
+// hashSuffix ends each line, after the hash, since
+// the hash is the last field of a record.
+const hashSuffix = `"}`
+
+// Verify reads a log, checking that its records are numbered
+// in order, and that each is unchanged and chained to the one
+// before.  It returns the number of records, and the hash of the
+// last, which, kept elsewhere, shows later whether records were
+// dropped from the end, which the chain alone can't.
+func Verify(r io.Reader, key []byte) (int, string, error) {
+	in := bufio.NewScanner(r)
+	in.Buffer(make([]byte, 64*1024), 1024*1024)
+	n, last := 0, ""
+	for in.Scan() {
+		line := in.Text()
+		var x schema.AuditRecord
+		if err := json.Unmarshal([]byte(line), &x); err != nil {
+			return n, last, fmt.Errorf("line %d: %v", n+1, err)
+		}
+		if x.Kind != schema.KindAuditRecord {
+			return n, last, fmt.Errorf("line %d holds %q, not an %s", n+1, x.Kind, schema.KindAuditRecord)
+		}
+		if x.Seq != n+1 {
+			return n, last, fmt.Errorf("line %d holds record %d; records were dropped or reordered", n+1, x.Seq)
+		}
+		if x.Prev != last {
+			return n, last, fmt.Errorf("record %d doesn't follow record %d", x.Seq, n)
+		}
+		unhashed := strings.TrimSuffix(line, x.Hash+hashSuffix)
+		if unhashed == line || sum(key, []byte(unhashed+hashSuffix)) != x.Hash {
+			return n, last, fmt.Errorf(
+				"record %d was changed, or the log is signed with another key", x.Seq)
+		}
+		n, last = x.Seq, x.Hash
+	}
+	return n, last, in.Err()
+}
+
+// sum is the hex SHA-256 of data, or, given a key, its HMAC-SHA256.
+func sum(key, data []byte) string {
+	var h hash.Hash
+	if len(key) > 0 {
+		h = hmac.New(sha256.New, key)
+	} else {
+		h = sha256.New()
+	}
+	h.Write(data)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// Who returns the name of the user running mdrip, and
+// of the host it runs on, for the records of their runs.
+func Who() (string, string) {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if s := os.Getenv("SUDO_USER"); len(s) > 0 && s != name {
+		name += " (sudo by " + s + ")"
+	}
+	host, _ := os.Hostname()
+	return name, host
+}
@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/schema"
+)
+
+func records(names ...string) []schema.AuditRecord {
+	var result []schema.AuditRecord
+	for i, n := range names {
+		result = append(result, schema.AuditRecord{
+			Header: schema.Header{Version: schema.Version, Kind: schema.KindAuditRecord},
+			User:   "ann", Host: "box", Source: "runbook", File: "runbook/deploy.md",
+			Index: i, Name: n, CodeSHA256: strings.Repeat("0", 64), State: "passed"})
+	}
+	return result
+}
+
+func TestLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdrip-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "audit.log")
+	key := []byte("zebra")
+	for _, names := range [][]string{{"drain", "upgrade"}, {"uncordon"}} {
+		l, err := Open(p, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Append(records(names...)); err != nil {
+			t.Fatal(err)
+		}
+		l.Close()
+	}
+	data, _ := ioutil.ReadFile(p)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	n, last, err := Verify(strings.NewReader(string(data)), key)
+	if err != nil || n != 3 || !strings.Contains(lines[2], `"hash":"`+last+`"`) {
+		t.Fatalf("got %d %s %v", n, last, err)
+	}
+	if _, _, err := Verify(strings.NewReader(string(data)), []byte("other")); err == nil ||
+		!strings.Contains(err.Error(), "record 1 was changed, or the log is signed with another key") {
+		t.Errorf("got %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		lines []string
+		want  string
+	}{
+		"changed": {
+			[]string{lines[0], strings.Replace(lines[1], `"passed"`, `"failed"`, 1), lines[2]},
+			"record 2 was changed"},
+		"dropped": {
+			[]string{lines[0], lines[2]},
+			"line 2 holds record 3; records were dropped or reordered"},
+		"reordered": {
+			[]string{lines[1], lines[0]},
+			"line 1 holds record 2"},
+	} {
+		_, _, err := Verify(strings.NewReader(strings.Join(tc.lines, "\n")), key)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got %v, want %q", name, err, tc.want)
+		}
+	}
+
+	ioutil.WriteFile(p, []byte(lines[1]+"\n"), 0600)
+	if _, err := Open(p, key); err == nil ||
+		!strings.Contains(err.Error(), "won't append to audit log") {
+		t.Errorf("got %v", err)
+	}
+}
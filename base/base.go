@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -47,6 +48,119 @@ type Label string
 // String form of the label.
 func (l Label) String() string { return string(l) }
 
+// A label of the form key=value, e.g. @target=demo:0.1, is a block
+// attribute rather than a selection label.
+const attributeMarker = "="
+
+// IsAttribute is true if the label has the form key=value.
+func (l Label) IsAttribute() bool {
+	return strings.Contains(string(l), attributeMarker)
+}
+
+// Key of an attribute label, or the whole label if not an attribute.
+func (l Label) Key() string {
+	return strings.SplitN(string(l), attributeMarker, 2)[0]
+}
+
+// Value of an attribute label, or empty if not an attribute.
+func (l Label) Value() string {
+	parts := strings.SplitN(string(l), attributeMarker, 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// FindAttribute returns the value of the first attribute
+// with the given key in the list of labels.
+func FindAttribute(labels []Label, key string) (string, bool) {
+	for _, l := range labels {
+		if l.IsAttribute() && l.Key() == key {
+			return l.Value(), true
+		}
+	}
+	return "", false
+}
+
+// archAliases maps other common names of architectures
+// to the names Go uses, i.e. the values of runtime.GOARCH.
+var archAliases = map[string]string{
+	"x86_64":  "amd64",
+	"x64":     "amd64",
+	"aarch64": "arm64",
+	"i386":    "386",
+	"i686":    "386",
+	"x86":     "386",
+}
+
+// NormalizeArch returns Go's name for the architecture, e.g. arm64 for aarch64.
+func NormalizeArch(arch string) string {
+	a := strings.ToLower(strings.TrimSpace(arch))
+	if n, ok := archAliases[a]; ok {
+		return n
+	}
+	return a
+}
+
+// SuitsArch is true if the labels hold no ArchAttribute, or if the
+// attribute's comma separated list includes the given architecture.
+// Every block suits the empty architecture.
+func SuitsArch(labels []Label, arch string) bool {
+	list, ok := FindAttribute(labels, ArchAttribute)
+	if !ok || len(arch) == 0 {
+		return true
+	}
+	for _, a := range strings.Split(list, ",") {
+		if NormalizeArch(a) == NormalizeArch(arch) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindExpectation returns how the code of a block holding expected
+// output is compared to the output of the block before it - empty
+// for an exact match, else e.g. "contains" or "regex" - and true, if
+// the labels mark it as such a block, via the label @expected or an
+// ExpectedAttribute.
+func FindExpectation(labels []Label) (string, bool) {
+	for _, l := range labels {
+		if l == Label(ExpectedAttribute) {
+			return "", true
+		}
+	}
+	return FindAttribute(labels, ExpectedAttribute)
+}
+
+// ParseTags splits a comma separated list of tags, e.g. the value
+// of a TagsAttribute, dropping empty ones.  Tags are lower case.
+func ParseTags(list string) []string {
+	result := []string{}
+	for _, t := range strings.Split(list, ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); len(t) > 0 {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// MergeTags returns the distinct tags in the given lists, sorted.
+func MergeTags(lists ...[]string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, l := range lists {
+		for _, t := range l {
+			t = strings.ToLower(t)
+			if !seen[t] {
+				seen[t] = true
+				result = append(result, t)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
 const (
 	// WildCardLabel matches an label.
 	WildCardLabel = Label(`__wildcard__`)
@@ -55,6 +169,62 @@ const (
 	// SleepLabel indicates the author wants a sleep after the block in a test context
 	// where there is no natural human caused pause.
 	SleepLabel = Label(`sleep`)
+	// TargetAttribute names the tmux target a block should be sent to.
+	TargetAttribute = `target`
+	// ArchAttribute lists the architectures a block is for,
+	// e.g. @arch=arm64 or @arch=amd64,386.
+	ArchAttribute = `arch`
+	// TimeoutAttribute overrides, for one block, the time test mode
+	// waits for it, e.g. @timeout=90s.
+	TimeoutAttribute = `timeout`
+	// RetriesAttribute has test mode run a failing block again, up
+	// to the given number of times, e.g. @retries=2, before failing.
+	RetriesAttribute = `retries`
+	// IsolationAttribute, as @isolation=subshell, has test mode run
+	// a block in a subshell, so that the variables it sets and the
+	// directory it moves to don't last beyond it.  The default is
+	// @isolation=none.
+	IsolationAttribute = `isolation`
+	// TagsAttribute lists tags categorizing a block, e.g.
+	// @tags=advanced,gcp.  Unlike labels, tags don't choose which
+	// blocks run; they only help readers and reports find blocks.
+	TagsAttribute = `tags`
+	// InterpreterAttribute names the program test mode pipes a block
+	// to instead of running it in the shell, e.g. @interpreter=python3.
+	// Without it, the block's fence language may choose one.
+	InterpreterAttribute = `interpreter`
+	// ExpectedAttribute marks a block as holding the output expected
+	// of the block before it, rather than code, e.g. @expected for an
+	// exact match, @expected=contains or @expected=regex.
+	ExpectedAttribute = `expected`
+	// NameAttribute names a block, e.g. @name=create-cluster, so
+	// that later blocks may need it.  It also names the block in
+	// reports, in place of its first label.
+	NameAttribute = `name`
+	// NeedsAttribute lists, by NameAttribute, blocks a block needs
+	// to have run before it, e.g. @needs=create-cluster,login.
+	NeedsAttribute = `needs`
+	// UseAttribute, as @use=install-kind, has a block's code be
+	// that of the block so named, by NameAttribute, in the library
+	// of shared blocks, e.g. that given by --lib.
+	UseAttribute = `use`
+	// SudoAttribute, as @sudo=true, has test mode run a block as
+	// root, via sudo, in a shell of its own.  Test mode refuses to
+	// run such blocks unless allowed to.
+	SudoAttribute = `sudo`
+	// SayLabel indicates that, when the block is sent to tmux, it should
+	// be preceded by a shell comment announcing it, so that a recorded
+	// terminal session explains itself.
+	SayLabel = Label(`say`)
+	// SetupLabel marks a block that prepares its lesson, e.g. by
+	// creating a cluster.  A lesson's setup blocks are kept whenever
+	// any of its blocks are, whatever label chose them.
+	SetupLabel = Label(`setup`)
+	// TeardownLabel marks a block that cleans up after its lesson,
+	// e.g. by deleting a cluster.  Like setup blocks, a lesson's
+	// teardown blocks are kept whenever any of its blocks are, and
+	// test mode runs them even if a block before them fails.
+	TeardownLabel = Label(`teardown`)
 )
 
 // OpaqueCode is an opaque, uninterpreted, unknown block of text that
@@ -1,6 +1,7 @@
 package base
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -27,3 +28,55 @@ func TestBase(t *testing.T) {
 		}
 	}
 }
+
+func TestFindAttribute(t *testing.T) {
+	labels := []Label{"hello", "target=demo:0.1", "needs=a=b"}
+	tests := []struct {
+		key   string
+		want  string
+		found bool
+	}{
+		{"target", "demo:0.1", true},
+		{"needs", "a=b", true},
+		{"hello", "", false},
+		{"missing", "", false},
+	}
+	for _, test := range tests {
+		got, found := FindAttribute(labels, test.key)
+		if got != test.want || found != test.found {
+			t.Errorf("%s: got (%q, %v), want (%q, %v)",
+				test.key, got, found, test.want, test.found)
+		}
+	}
+}
+
+func TestSuitsArch(t *testing.T) {
+	tests := []struct {
+		labels []Label
+		arch   string
+		want   bool
+	}{
+		{[]Label{"hello"}, "arm64", true},
+		{[]Label{"arch=arm64"}, "arm64", true},
+		{[]Label{"arch=arm64"}, "amd64", false},
+		{[]Label{"arch=amd64, 386"}, "386", true},
+		{[]Label{"arch=aarch64"}, "arm64", true},
+		{[]Label{"arch=x86_64"}, "amd64", true},
+		{[]Label{"arch=arm64"}, "", true},
+	}
+	for _, test := range tests {
+		if got := SuitsArch(test.labels, test.arch); got != test.want {
+			t.Errorf("%v for %q: got %v, want %v", test.labels, test.arch, got, test.want)
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	if got := strings.Join(ParseTags(" GCP, ,advanced,"), "|"); got != "gcp|advanced" {
+		t.Errorf("ParseTags got %s", got)
+	}
+	got := strings.Join(MergeTags([]string{"gcp", "beginner"}, []string{"Beginner", "aws"}), "|")
+	if got != "aws|beginner|gcp" {
+		t.Errorf("MergeTags got %s", got)
+	}
+}
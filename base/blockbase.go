@@ -12,5 +12,8 @@ func (x *BlockBase) Prose() MdProse { return x.prose }
 // Code from the block.
 func (x *BlockBase) Code() OpaqueCode { return x.code }
 
+// SetCode replaces the block's code.
+func (x *BlockBase) SetCode(c OpaqueCode) { x.code = c }
+
 // NewBlockBase is a ctor.
 func NewBlockBase(p MdProse, c OpaqueCode) BlockBase { return BlockBase{p, c} }
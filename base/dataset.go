@@ -2,11 +2,19 @@ package base
 
 import (
 	"errors"
+	"time"
 )
 
 // DataSet indicates the origin of multiple markdown sources.
 type DataSet struct {
 	args []*DataSource
+	// lib, if not nil, holds the shared blocks the
+	// lessons may use; see UseAttribute.
+	lib *DataSet
+	// lang is the language of the lessons to load, where
+	// they're translated, e.g. fr for intro.fr.md rather
+	// than intro.md; "" for the lessons as first written.
+	lang string
 }
 
 // FirstArg is, uh, the first member of the dataset - sometimes special.
@@ -19,6 +27,85 @@ func (d *DataSet) Size() int {
 	return len(d.args)
 }
 
+// SetRef sets the branch or tag to clone for
+// data sources that are git repositories.
+func (d *DataSet) SetRef(ref string) {
+	for _, x := range d.args {
+		if x.IsGitRepo() {
+			x.SetRef(ref)
+		}
+	}
+}
+
+// SetFetchTimeOut sets the max time to spend fetching
+// data sources that are web files.
+func (d *DataSet) SetFetchTimeOut(t time.Duration) {
+	for _, x := range d.args {
+		if x.IsWebFile() {
+			x.SetFetchTimeOut(t)
+		}
+	}
+}
+
+// SetCache sets where copies are kept of data
+// sources that are git repositories or web files.
+func (d *DataSet) SetCache(c Cache) {
+	for _, x := range d.args {
+		if x.IsGitRepo() || x.IsWebFile() {
+			x.SetCache(c)
+		}
+	}
+}
+
+// SetExclude sets the globs of the paths, below data
+// sources that are directories, not to load.
+func (d *DataSet) SetExclude(globs []string) {
+	for _, x := range d.args {
+		x.SetExclude(globs)
+	}
+}
+
+// SetInclude sets the globs of the only files, below
+// data sources that are directories, to load as lessons.
+func (d *DataSet) SetInclude(globs []string) {
+	for _, x := range d.args {
+		x.SetInclude(globs)
+	}
+}
+
+// SetLibrary sets the dataset holding the blocks,
+// named with NameAttribute, that lessons may use.
+func (d *DataSet) SetLibrary(lib *DataSet) {
+	d.lib = lib
+}
+
+// SetLang sets the language of the lessons to load.
+func (d *DataSet) SetLang(lang string) {
+	d.lang = lang
+}
+
+// Lang is the language of the lessons to load, where
+// they're translated into it.
+func (d *DataSet) Lang() string {
+	return d.lang
+}
+
+// Library is the dataset holding the blocks that
+// lessons may use, or nil if there's none.
+func (d *DataSet) Library() *DataSet {
+	return d.lib
+}
+
+// Split returns a dataset per member of this one, in order,
+// each with this one's library and language.
+func (d *DataSet) Split() []*DataSet {
+	result := make([]*DataSet, len(d.args))
+	for i, x := range d.args {
+		result[i] = &DataSet{[]*DataSource{x}, d.lib, d.lang}
+	}
+	return result
+}
+
 // AsPaths is an array of file paths representing the dataset.
 func (d *DataSet) AsPaths() []FilePath {
 	result := make([]FilePath, len(d.args))
@@ -50,5 +137,5 @@ func NewDataSet(fArgs []string) (*DataSet, error) {
 	if len(result) < 1 {
 		return nil, errors.New("must specify a data source - files, directory, or github clone url")
 	}
-	return &DataSet{result}, nil
+	return &DataSet{result, nil, ""}, nil
 }
@@ -3,6 +3,7 @@ package base
 import (
 	"errors"
 	"path/filepath"
+	"time"
 
 	"strings"
 )
@@ -13,6 +14,36 @@ type DataSource struct {
 	repoName string
 	relPath  string
 	absPath  string
+	// gitURL is set for git repositories off github.
+	gitURL string
+	// ref is the branch or tag to clone; empty means the default.
+	ref string
+	// fileURL is set for a single file fetched over http(s).
+	fileURL string
+	// fetchTimeOut limits the time spent fetching fileURL.
+	fetchTimeOut time.Duration
+	// cache keeps copies of git repositories and web files.
+	cache Cache
+	// exclude holds globs of paths, below the datasource
+	// if it's a directory, not to load.
+	exclude []string
+	// include, if not empty, holds globs of the only
+	// files below the datasource to load as lessons.
+	include []string
+}
+
+// Cache says where, and for how long, copies of remote data
+// sources - git repositories and web files - are kept, so
+// they needn't be fetched again, e.g. by repeated CI runs,
+// or fetched at all on a flaky network.
+type Cache struct {
+	// Dir holds the copies; empty means none are kept.
+	Dir string
+	// TTL is how long a copy is used before it's fetched
+	// again.  A stale copy is still used if that fails.
+	TTL time.Duration
+	// Offline means never to fetch, only to use copies.
+	Offline bool
 }
 
 // IsGithub is true if the datasource was github.
@@ -20,8 +51,82 @@ func (d *DataSource) IsGithub() bool {
 	return len(d.repoName) > 0
 }
 
+// IsGitRepo is true if the datasource is a git repository,
+// on github or elsewhere, that must be cloned.
+func (d *DataSource) IsGitRepo() bool {
+	return d.IsGithub() || len(d.gitURL) > 0
+}
+
+// IsWebFile is true if the datasource is a single
+// markdown file to fetch over http(s).
+func (d *DataSource) IsWebFile() bool {
+	return len(d.fileURL) > 0
+}
+
+// FileURL is the http(s) URL of a web file.
+func (d *DataSource) FileURL() string {
+	return d.fileURL
+}
+
+// FetchTimeOut is the max time to spend fetching a web file.
+func (d *DataSource) FetchTimeOut() time.Duration {
+	return d.fetchTimeOut
+}
+
+// SetFetchTimeOut sets the max time to spend fetching a web file.
+func (d *DataSource) SetFetchTimeOut(t time.Duration) {
+	d.fetchTimeOut = t
+}
+
+// Cache is where copies of the datasource are kept, if remote.
+func (d *DataSource) Cache() Cache {
+	return d.cache
+}
+
+// SetCache sets where copies of the datasource are kept.
+func (d *DataSource) SetCache(c Cache) {
+	d.cache = c
+}
+
+// Exclude holds globs of the paths, relative to the datasource,
+// or of the names, of the files and directories below it not
+// to load, e.g. drafts, *.wip.md or vendor/**.
+func (d *DataSource) Exclude() []string {
+	return d.exclude
+}
+
+// SetExclude sets the globs of the paths not to load.
+func (d *DataSource) SetExclude(globs []string) {
+	d.exclude = globs
+}
+
+// Include holds globs, like those of Exclude, of the files
+// below the datasource to load as lessons, e.g. docs/**/*.md;
+// if it's empty, every markdown file is.
+func (d *DataSource) Include() []string {
+	return d.include
+}
+
+// SetInclude sets the globs of the only files to load as lessons.
+func (d *DataSource) SetInclude(globs []string) {
+	d.include = globs
+}
+
+// Ref is the branch or tag to clone.
+func (d *DataSource) Ref() string {
+	return d.ref
+}
+
+// SetRef sets the branch or tag to clone.
+func (d *DataSource) SetRef(ref string) {
+	d.ref = ref
+}
+
 // Display is a string intended for display.
 func (d *DataSource) Display() string {
+	if len(d.gitURL) > 0 || len(d.fileURL) > 0 {
+		return d.raw
+	}
 	if d.IsGithub() {
 		result := "gh:" + d.repoName
 		if len(d.relPath) > 0 {
@@ -37,17 +142,30 @@ func (d *DataSource) Href() string {
 	if d.IsGithub() {
 		result := "https://github.com/" + d.repoName
 		if len(d.relPath) > 0 {
-			return result + "/blob/master/" + d.relPath
+			ref := "master"
+			if len(d.ref) > 0 {
+				ref = d.ref
+			}
+			return result + "/blob/" + ref + "/" + d.relPath
 		}
 		return result
 	}
+	if len(d.gitURL) > 0 {
+		return d.gitURL
+	}
+	if len(d.fileURL) > 0 {
+		return d.fileURL
+	}
 	return "file://" + d.absPath
 }
 
-// GithubCloneArg returns the data source in a form suitable for git clone.
-// Using https instead of ssh so no need for keys
+// CloneArg returns the data source in a form suitable for git clone.
+// For github, using https instead of ssh so no need for keys
 // (works only with public repos obviously).
-func (d *DataSource) GithubCloneArg() string {
+func (d *DataSource) CloneArg() string {
+	if len(d.gitURL) > 0 {
+		return d.gitURL
+	}
 	return "https://github.com/" + d.repoName + ".git"
 }
 
@@ -83,14 +201,58 @@ func NewDataSource(arg string) (*DataSource, error) {
 		if err != nil {
 			return nil, err
 		}
-		return &DataSource{arg, repoName, path, ""}, nil
+		return &DataSource{arg, repoName, path, "", "", "", "", 0, Cache{}, nil, nil}, nil
+	}
+	if smellsLikeGitURL(n) {
+		url, path := splitGitURL(n)
+		return &DataSource{arg, "", path, "", url, "", "", 0, Cache{}, nil, nil}, nil
+	}
+	if smellsLikeFileURL(n) {
+		return &DataSource{arg, "", "", "", "", "", n, 0, Cache{}, nil, nil}, nil
 	}
 	path, err := filepath.Abs(arg)
 	if err != nil {
 		return nil, errors.New(
 			"unable to resolve absolute path of " + arg)
 	}
-	return &DataSource{arg, "", arg, path}, nil
+	return &DataSource{arg, "", arg, path, "", "", "", 0, Cache{}, nil, nil}, nil
+}
+
+// smellsLikeGitURL is true for URLs of git repositories off
+// github, e.g. https://gitlab.com/org/repo.git, or
+// git@gitlab.com:org/repo.git.
+func smellsLikeGitURL(arg string) bool {
+	a := strings.ToLower(arg)
+	for _, p := range []string{"git@", "ssh://", "git://", "git+ssh://"} {
+		if strings.HasPrefix(a, p) {
+			return true
+		}
+	}
+	if !strings.HasPrefix(a, "https://") && !strings.HasPrefix(a, "http://") {
+		return false
+	}
+	u, _ := splitGitURL(a)
+	return strings.HasSuffix(u, ".git")
+}
+
+// smellsLikeFileURL is true for http(s) URLs that aren't
+// git repositories, e.g. https://example.com/docs/README.md.
+func smellsLikeFileURL(arg string) bool {
+	a := strings.ToLower(arg)
+	return strings.HasPrefix(a, "https://") || strings.HasPrefix(a, "http://")
+}
+
+// splitGitURL splits a git URL from a path within the repository,
+// separated by a double slash, e.g. https://host/org/repo.git//docs.
+func splitGitURL(arg string) (string, string) {
+	start := 0
+	if i := strings.Index(arg, "://"); i > -1 {
+		start = i + len("://")
+	}
+	if j := strings.Index(arg[start:], "//"); j > -1 {
+		return arg[:start+j], arg[start+j+len("//"):]
+	}
+	return arg, ""
 }
 
 func smellsLikeGithubCloneArg(arg string) bool {
@@ -52,3 +52,67 @@ func TestExtractGithubRepoName(t *testing.T) {
 		}
 	}
 }
+
+func TestGitURL(t *testing.T) {
+	tests := []struct {
+		input   string
+		isGit   bool
+		wantURL string
+		path    string
+	}{
+		{"https://gitlab.com/org/repo.git", true, "https://gitlab.com/org/repo.git", ""},
+		{"https://gitlab.com/org/repo.git//docs/intro", true, "https://gitlab.com/org/repo.git", "docs/intro"},
+		{"git@gitlab.com:org/repo.git", true, "git@gitlab.com:org/repo.git", ""},
+		{"ssh://git@host/org/repo//docs", true, "ssh://git@host/org/repo", "docs"},
+		{"https://example.com/README.md", false, "", ""},
+		{"https://raw.githubusercontent.com/org/repo/main/README.md", false, "", ""},
+		{"docs/README.md", false, "", ""},
+	}
+	for _, test := range tests {
+		if got := smellsLikeGitURL(test.input); got != test.isGit {
+			t.Errorf("smellsLikeGitURL(%s) = %v, want %v", test.input, got, test.isGit)
+			continue
+		}
+		if !test.isGit {
+			continue
+		}
+		d, err := NewDataSource(test.input)
+		if err != nil {
+			t.Fatalf("%s: %v", test.input, err)
+		}
+		if !d.IsGitRepo() || d.IsGithub() {
+			t.Errorf("%s should be a git repo off github", test.input)
+		}
+		if d.CloneArg() != test.wantURL || string(d.RelPath()) != test.path {
+			t.Errorf("%s: got (%s, %s), want (%s, %s)",
+				test.input, d.CloneArg(), d.RelPath(), test.wantURL, test.path)
+		}
+	}
+}
+
+func TestFileURL(t *testing.T) {
+	tests := []struct {
+		input     string
+		isWebFile bool
+	}{
+		{"https://raw.githubusercontent.com/org/repo/main/README.md", true},
+		{"HTTP://example.com/docs/intro.md", true},
+		{"https://gitlab.com/org/repo.git", false},
+		{"https://github.com/org/repo/README.md", false},
+		{"docs/README.md", false},
+	}
+	for _, test := range tests {
+		d, err := NewDataSource(test.input)
+		if err != nil {
+			t.Fatalf("%s: %v", test.input, err)
+		}
+		if d.IsWebFile() != test.isWebFile {
+			t.Errorf("%s: IsWebFile() = %v, want %v",
+				test.input, d.IsWebFile(), test.isWebFile)
+			continue
+		}
+		if test.isWebFile && (d.FileURL() != test.input || d.Href() != test.input) {
+			t.Errorf("%s: got url %s, href %s", test.input, d.FileURL(), d.Href())
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package base
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// A Label used to select blocks, e.g. the value of --label, may be
+// an expression over the labels of a block, e.g.
+//
+//	setup && !slow
+//	(install || upgrade) && !arch=arm64
+//
+// with ! binding tighter than &&, and && tighter than ||.
+// A leading @ on a label is optional.
+
+// labelNode is a node in the tree of a parsed label expression.
+type labelNode interface {
+	matches(labels []Label) bool
+}
+
+type labelLeaf Label
+
+func (x labelLeaf) matches(labels []Label) bool {
+	for _, l := range labels {
+		if l == Label(x) {
+			return true
+		}
+	}
+	return false
+}
+
+type labelNot struct{ x labelNode }
+
+func (n labelNot) matches(labels []Label) bool { return !n.x.matches(labels) }
+
+type labelAnd struct{ x, y labelNode }
+
+func (n labelAnd) matches(labels []Label) bool {
+	return n.x.matches(labels) && n.y.matches(labels)
+}
+
+type labelOr struct{ x, y labelNode }
+
+func (n labelOr) matches(labels []Label) bool {
+	return n.x.matches(labels) || n.y.matches(labels)
+}
+
+// labelParser is a recursive descent parser of label expressions.
+type labelParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *labelParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *labelParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *labelParser) parseOr() (labelNode, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.next()
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		x = labelOr{x, y}
+	}
+	return x, nil
+}
+
+func (p *labelParser) parseAnd() (labelNode, error) {
+	x, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.next()
+		y, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		x = labelAnd{x, y}
+	}
+	return x, nil
+}
+
+func (p *labelParser) parseNot() (labelNode, error) {
+	switch t := p.next(); t {
+	case "!":
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return labelNot{x}, nil
+	case "(":
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return x, nil
+	case "", ")", "&&", "||":
+		if t == "" {
+			return nil, fmt.Errorf("expected a label at the end")
+		}
+		return nil, fmt.Errorf("expected a label, got %q", t)
+	default:
+		return labelLeaf(strings.TrimPrefix(t, "@")), nil
+	}
+}
+
+// tokenizeLabels splits a label expression into labels and operators.
+func tokenizeLabels(s string) ([]string, error) {
+	var result []string
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '!' || c == '(' || c == ')':
+			result = append(result, string(c))
+			i++
+		case strings.HasPrefix(s[i:], "&&") || strings.HasPrefix(s[i:], "||"):
+			result = append(result, s[i:i+2])
+			i += 2
+		case c == '&' || c == '|':
+			return nil, fmt.Errorf("use && or ||, not %c", c)
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\n!()&|", rune(s[j])) {
+				j++
+			}
+			result = append(result, s[i:j])
+			i = j
+		}
+	}
+	return result, nil
+}
+
+func parseLabelExpr(s string) (labelNode, error) {
+	tokens, err := tokenizeLabels(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &labelParser{tokens: tokens}
+	x, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.peek())
+	}
+	return x, nil
+}
+
+// CheckSelector returns an error if the label, used to select
+// blocks, isn't a well formed expression.
+func (l Label) CheckSelector() error {
+	if l == WildCardLabel {
+		return nil
+	}
+	if _, err := parseLabelExpr(string(l)); err != nil {
+		return fmt.Errorf("bad label expression %q: %v", l, err)
+	}
+	return nil
+}
+
+// IsExpression is true if the label, used to select blocks,
+// is more than one label, e.g. "setup && !slow".
+func (l Label) IsExpression() bool {
+	return l != WildCardLabel && strings.ContainsAny(string(l), "!()&| ")
+}
+
+// Selects is true if the label, used to select blocks, selects a
+// block with the given labels.  The WildCardLabel selects any block.
+// A malformed expression (see CheckSelector) selects nothing.
+func (l Label) Selects(labels []Label) bool {
+	if l == WildCardLabel {
+		return true
+	}
+	x, err := parseLabelExpr(string(l))
+	if err != nil {
+		return false
+	}
+	return x.matches(labels)
+}
+
+// AllOf joins labels used to select blocks into one
+// selecting only the blocks each of them selects.
+func AllOf(labels []Label) Label {
+	var parts []string
+	for _, l := range labels {
+		if l == WildCardLabel || len(strings.TrimSpace(string(l))) == 0 {
+			continue
+		}
+		if l.IsExpression() && len(labels) > 1 {
+			parts = append(parts, "("+string(l)+")")
+		} else {
+			parts = append(parts, string(l))
+		}
+	}
+	if len(parts) == 0 {
+		return WildCardLabel
+	}
+	return Label(strings.Join(parts, " && "))
+}
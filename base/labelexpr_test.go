@@ -0,0 +1,59 @@
+package base
+
+import "testing"
+
+func TestLabelSelects(t *testing.T) {
+	block := []Label{"setup", "test", "arch=arm64"}
+	tests := []struct {
+		label Label
+		want  bool
+	}{
+		{WildCardLabel, true},
+		{"setup", true},
+		{"@setup", true},
+		{"slow", false},
+		{"setup && !slow", true},
+		{"setup && slow", false},
+		{"slow || test", true},
+		{"!(setup || slow)", false},
+		{"(install || setup) && !arch=amd64", true},
+		{"!!test", true},
+		{"slow || setup && test", true},
+		{"setup && (slow", false},
+	}
+	for _, tc := range tests {
+		if got := tc.label.Selects(block); got != tc.want {
+			t.Errorf("%q selects: got %v, want %v", tc.label, got, tc.want)
+		}
+	}
+}
+
+func TestLabelCheckSelector(t *testing.T) {
+	for _, l := range []Label{"setup", "a && !b", "(a||b)&&c", WildCardLabel} {
+		if err := l.CheckSelector(); err != nil {
+			t.Errorf("%q: unexpected error %v", l, err)
+		}
+	}
+	for _, l := range []Label{"a &&", "a & b", "(a", "a)", "!", "a b", "&& a"} {
+		if err := l.CheckSelector(); err == nil {
+			t.Errorf("%q: expected an error", l)
+		}
+	}
+}
+
+func TestAllOf(t *testing.T) {
+	tests := []struct {
+		labels []Label
+		want   Label
+	}{
+		{nil, WildCardLabel},
+		{[]Label{"setup"}, "setup"},
+		{[]Label{"setup && !slow"}, "setup && !slow"},
+		{[]Label{"setup", "a || b"}, "setup && (a || b)"},
+	}
+	for _, tc := range tests {
+		if got := AllOf(tc.labels); got != tc.want {
+			t.Errorf("AllOf(%v): got %q, want %q", tc.labels, got, tc.want)
+		}
+	}
+}
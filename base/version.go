@@ -0,0 +1,21 @@
+package base
+
+import "runtime/debug"
+
+// version may be set at build time, e.g. with
+//
+//	go build -ldflags "-X github.com/monopole/mdrip/base.version=v1.2.3"
+var version = ""
+
+// Version of mdrip, from the build if it says, else from the
+// module's build info, else "devel".
+func Version() string {
+	if len(version) > 0 {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok &&
+		len(info.Main.Version) > 0 && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "devel"
+}
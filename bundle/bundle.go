@@ -0,0 +1,278 @@
+// Package bundle appends a tutorial's content to a copy of the mdrip
+// executable, so that one file can be handed out and served offline.
+//
+// The copy's layout is
+//
+//	executable | zip archive | archive length (8 bytes) | magic (8 bytes)
+//
+// and the archive's comment names the path, within the
+// archive, to load, e.g. "content" or "content/README.md".
+// The executable itself holds the libraries its pages load,
+// KaTeX and mermaid (see webapp.Libraries), so a bundle's
+// pages need no network either.
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/pkg/errors"
+)
+
+const (
+	magic       = "MDRIPBND"
+	trailerSize = int64(8 + len(magic))
+	contentDir  = "content"
+)
+
+// Write copies the executable at exe to out, appending the
+// tutorial at src, a local file or directory.
+func Write(exe string, src base.FilePath, out string) error {
+	fi, err := os.Stat(string(src))
+	if err != nil {
+		return errors.Wrap(err, "unable to bundle "+string(src))
+	}
+	var archive bytes.Buffer
+	z := zip.NewWriter(&archive)
+	root := contentDir
+	if fi.IsDir() {
+		err = addDir(z, string(src))
+	} else {
+		root = contentDir + "/" + filepath.Base(string(src))
+		err = addFile(z, string(src), root)
+	}
+	if err != nil {
+		return err
+	}
+	if err := z.SetComment(root); err != nil {
+		return err
+	}
+	if err := z.Close(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return errors.Wrap(err, "unable to create "+out)
+	}
+	defer f.Close()
+	if err := copyExecutable(f, exe); err != nil {
+		return err
+	}
+	if _, err := f.Write(archive.Bytes()); err != nil {
+		return err
+	}
+	var trailer [trailerSize]byte
+	binary.BigEndian.PutUint64(trailer[:8], uint64(archive.Len()))
+	copy(trailer[8:], magic)
+	_, err = f.Write(trailer[:])
+	return err
+}
+
+// copyExecutable writes the executable, minus any bundle it already holds.
+func copyExecutable(w io.Writer, exe string) error {
+	f, err := os.Open(exe)
+	if err != nil {
+		return errors.Wrap(err, "unable to read "+exe)
+	}
+	defer f.Close()
+	size, archiveSize, err := sizes(f)
+	if err != nil {
+		return err
+	}
+	if archiveSize > 0 {
+		size -= archiveSize + trailerSize
+	}
+	_, err = io.CopyN(w, f, size)
+	return err
+}
+
+func addDir(z *zip.Writer, dir string) error {
+	return filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() && strings.HasPrefix(fi.Name(), ".") && p != dir {
+			// Skip .git, etc.
+			return filepath.SkipDir
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		return addFile(z, p, contentDir+"/"+filepath.ToSlash(rel))
+	})
+}
+
+func addFile(z *zip.Writer, p, name string) error {
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return errors.Wrap(err, "unable to read "+p)
+	}
+	w, err := z.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// sizes returns the size of the file, and of the bundled
+// archive it holds, zero if none.
+func sizes(f *os.File) (int64, int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	size := fi.Size()
+	if size < trailerSize {
+		return size, 0, nil
+	}
+	var trailer [trailerSize]byte
+	if _, err := f.ReadAt(trailer[:], size-trailerSize); err != nil {
+		return 0, 0, err
+	}
+	if string(trailer[8:]) != magic {
+		return size, 0, nil
+	}
+	n := int64(binary.BigEndian.Uint64(trailer[:8]))
+	if n <= 0 || n > size-trailerSize {
+		return 0, 0, errors.New("corrupt bundle in " + f.Name())
+	}
+	return size, n, nil
+}
+
+// Unpack extracts the tutorial bundled in the executable at exe,
+// returning the path to load it from.  The second return is false
+// if the executable holds no bundle.  Content is extracted once,
+// to a directory named for the executable's contents in the user's
+// cache directory, and reused only if the user owns it and no one
+// else may write to it.  Without a cache directory, content is
+// extracted to a new temporary directory each time.
+func Unpack(exe string) (string, bool, error) {
+	f, err := os.Open(exe)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+	size, archiveSize, err := sizes(f)
+	if err != nil || archiveSize == 0 {
+		return "", false, err
+	}
+	start := size - trailerSize - archiveSize
+	z, err := zip.NewReader(io.NewSectionReader(f, start, archiveSize), archiveSize)
+	if err != nil {
+		return "", false, errors.Wrap(err, "corrupt bundle in "+exe)
+	}
+	h := sha1.New()
+	if _, err := io.Copy(h, io.NewSectionReader(f, start, archiveSize)); err != nil {
+		return "", false, err
+	}
+	name := fmt.Sprintf("bundle-%x", h.Sum(nil)[:6])
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		tmp, err := ioutil.TempDir("", "mdrip-"+name+"-")
+		if err != nil {
+			return "", false, err
+		}
+		if err := extractAll(z, tmp); err != nil {
+			os.RemoveAll(tmp)
+			return "", false, err
+		}
+		return filepath.Join(tmp, filepath.FromSlash(z.Comment)), true, nil
+	}
+	parent := filepath.Join(cache, "mdrip")
+	if err := os.MkdirAll(parent, 0700); err != nil {
+		return "", false, errors.Wrap(err, "unable to unpack bundle")
+	}
+	if err := checkPrivate(parent); err != nil {
+		return "", false, err
+	}
+	dir := filepath.Join(parent, name)
+	root := filepath.Join(dir, filepath.FromSlash(z.Comment))
+	if _, err := os.Lstat(dir); err == nil {
+		if err := checkPrivate(dir); err != nil {
+			return "", false, err
+		}
+		return root, true, nil
+	}
+	// Made beside dir, so it can be renamed to it.
+	tmp, err := ioutil.TempDir(parent, "unpack-")
+	if err != nil {
+		return "", false, err
+	}
+	defer os.RemoveAll(tmp)
+	if err := extractAll(z, tmp); err != nil {
+		return "", false, err
+	}
+	// Rename, so that a partial extraction is never used.
+	if err := os.Rename(tmp, dir); err != nil {
+		if checkPrivate(dir) == nil {
+			// Another instance got there first.
+			return root, true, nil
+		}
+		return "", false, errors.Wrap(err, "unable to unpack bundle")
+	}
+	return root, true, nil
+}
+
+// checkPrivate returns an error unless dir is a directory the
+// user owns, and no one else may write to, so that no one else
+// can have put the content there, or swap it.
+func checkPrivate(dir string) error {
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !fi.IsDir():
+		return errors.New(dir + " isn't a directory; remove it")
+	case !ownedByUser(fi):
+		return errors.New(dir + " belongs to another user; remove it")
+	case fi.Mode().Perm()&0022 != 0:
+		return errors.New(dir + " may be written by others; remove it")
+	}
+	return nil
+}
+
+func extractAll(z *zip.Reader, dir string) error {
+	for _, zf := range z.File {
+		if err := extract(zf, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extract(zf *zip.File, dir string) error {
+	p := filepath.Join(dir, filepath.FromSlash(zf.Name))
+	if !strings.HasPrefix(p, filepath.Clean(dir)+string(filepath.Separator)) {
+		return errors.New("bad path in bundle: " + zf.Name)
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	r, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	_, err = io.Copy(w, r)
+	return err
+}
@@ -0,0 +1,82 @@
+package bundle
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+)
+
+func TestWriteUnpack(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "bundle-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	defer os.Setenv("XDG_CACHE_HOME", os.Getenv("XDG_CACHE_HOME"))
+	os.Setenv("XDG_CACHE_HOME", filepath.Join(tmp, "cache"))
+
+	exe := filepath.Join(tmp, "exe")
+	tut := filepath.Join(tmp, "tut")
+	out := filepath.Join(tmp, "out")
+	write(t, exe, "not really a program")
+	write(t, filepath.Join(tut, "README.md"), "# Hi\n")
+	write(t, filepath.Join(tut, "sub", "lesson.md"), "# Lesson\n")
+	write(t, filepath.Join(tut, ".git", "config"), "junk")
+
+	if _, ok, err := Unpack(exe); ok || err != nil {
+		t.Fatalf("plain executable should have no bundle, got %v %v", ok, err)
+	}
+	if err := Write(exe, base.FilePath(tut), out); err != nil {
+		t.Fatal(err)
+	}
+	root, ok, err := Unpack(out)
+	if !ok || err != nil {
+		t.Fatalf("expected a bundle, got %v %v", ok, err)
+	}
+	for _, n := range []string{"README.md", filepath.Join("sub", "lesson.md")} {
+		if _, err := os.Stat(filepath.Join(root, n)); err != nil {
+			t.Errorf("missing %s: %v", n, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, ".git")); err == nil {
+		t.Errorf("dot directories should not be bundled")
+	}
+	if again, _, err := Unpack(out); again != root || err != nil {
+		t.Errorf("expected to reuse %s, got %q %v", root, again, err)
+	}
+	// Content others could have swapped isn't used.
+	if err := os.Chmod(filepath.Dir(root), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Unpack(out); err == nil {
+		t.Errorf("expected to refuse a directory others may write to")
+	}
+	os.Chmod(filepath.Dir(root), 0700)
+
+	// Bundling from a bundle replaces, rather than stacks, the content.
+	again := filepath.Join(tmp, "again")
+	if err := Write(out, base.FilePath(filepath.Join(tut, "README.md")), again); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := os.Stat(again)
+	o, _ := os.Stat(out)
+	if a.Size() >= o.Size() {
+		t.Errorf("old bundle not stripped: %d >= %d", a.Size(), o.Size())
+	}
+	root, ok, err = Unpack(again)
+	if !ok || err != nil || filepath.Base(root) != "README.md" {
+		t.Errorf("expected a single file bundle, got %q %v %v", root, ok, err)
+	}
+}
+
+func write(t *testing.T, p, content string) {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package bundle
+
+import (
+	"os"
+	"syscall"
+)
+
+// ownedByUser is true if the file belongs to the user running mdrip.
+func ownedByUser(fi os.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && int(st.Uid) == os.Getuid()
+}
@@ -0,0 +1,9 @@
+package bundle
+
+import "os"
+
+// ownedByUser is true, since Windows keeps a user's cache
+// directory, under their profile, to themselves.
+func ownedByUser(fi os.FileInfo) bool {
+	return true
+}
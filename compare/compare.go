@@ -0,0 +1,235 @@
+// Package compare compares the results of two test mode runs, as
+// written by --format json, e.g. before and after a change to a
+// tutorial, and writes what got worse or better as markdown, for
+// posting on the change's pull request.
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/monopole/mdrip/schema"
+)
+
+// MinSlowdown is the least a block must slow by to count
+// as a timing regression, so quick blocks' jitter isn't one.
+const MinSlowdown = time.Second
+
+// maxOutputLines is how much of a newly failing block's
+// stderr, from its end, the markdown holds.
+const maxOutputLines = 20
+
+// Load reads the results of a run from the file.
+func Load(path string) (*schema.Results, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r schema.Results
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("bad results in %s: %v", path, err)
+	}
+	if r.Kind != schema.KindResults {
+		return nil, fmt.Errorf(
+			"%s holds %q, not the %s of --mode test --format json",
+			path, r.Kind, schema.KindResults)
+	}
+	return &r, nil
+}
+
+// Change is what became of a block from one run to the next.
+type Change struct {
+	// File is the block's lesson, relative to the
+	// directory holding all of the run's lessons.
+	File string
+	// Before and After are the block's results in each
+	// run; Before is nil if the block is new.
+	Before, After *schema.BlockResult
+}
+
+// Diff is how a run differs from an earlier one.
+type Diff struct {
+	Before, After *schema.Results
+	// Failures are blocks failing now that didn't before.
+	Failures []Change
+	// Fixed are blocks passing now that failed before.
+	Fixed []Change
+	// Slower are blocks passing in both runs, but slower by
+	// more than the threshold.
+	Slower []Change
+}
+
+// key identifies a block across runs: by its lesson, its name,
+// and which of the lesson's blocks so named it is, so that adding
+// or removing other blocks doesn't confuse it with another.
+type key struct {
+	file, name string
+	n          int
+}
+
+// keys returns the key of each of the run's blocks.  Lessons are
+// identified relative to the directory holding all of them, since
+// runs being compared are often of checkouts in different places.
+func keys(r *schema.Results) []key {
+	dir := ""
+	for i, b := range r.Blocks {
+		d := b.File[:strings.LastIndex(b.File, "/")+1]
+		if i == 0 {
+			dir = d
+		}
+		for !strings.HasPrefix(d, dir) {
+			dir = dir[:strings.LastIndex(dir[:len(dir)-1], "/")+1]
+		}
+	}
+	seen := map[key]int{}
+	result := make([]key, len(r.Blocks))
+	for i, b := range r.Blocks {
+		k := key{b.File[len(dir):], b.Name, 0}
+		k.n = seen[k]
+		seen[k]++
+		result[i] = k
+	}
+	return result
+}
+
+// Compare returns how the after run differs from the before run.
+// A block passing in both is slower if its time grew by more than
+// threshold percent, and by at least MinSlowdown.
+func Compare(before, after *schema.Results, threshold float64) *Diff {
+	d := &Diff{Before: before, After: after}
+	old := map[key]*schema.BlockResult{}
+	for i, k := range keys(before) {
+		old[k] = &before.Blocks[i]
+	}
+	for i, k := range keys(after) {
+		b, a := old[k], &after.Blocks[i]
+		switch {
+		case a.State == "failed" && (b == nil || b.State != "failed"):
+			d.Failures = append(d.Failures, Change{k.file, b, a})
+		case a.State == "passed" && b != nil && b.State == "failed":
+			d.Fixed = append(d.Fixed, Change{k.file, b, a})
+		case a.State == "passed" && b != nil && b.State == "passed" &&
+			a.Seconds > b.Seconds*(1+threshold/100) &&
+			a.Seconds-b.Seconds >= MinSlowdown.Seconds():
+			d.Slower = append(d.Slower, Change{k.file, b, a})
+		}
+	}
+	return d
+}
+
+// Regressed is true if a block newly fails, or got slower.
+func (d *Diff) Regressed() bool {
+	return len(d.Failures) > 0 || len(d.Slower) > 0
+}
+
+func count(r *schema.Results, state string) int {
+	n := 0
+	for _, b := range r.Blocks {
+		if b.State == state {
+			n++
+		}
+	}
+	return n
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
+
+// cell escapes text for a markdown table cell.
+func cell(s string) string {
+	return strings.Replace(strings.Replace(s, "|", `\|`, -1), "\n", " ", -1)
+}
+
+// where returns the table cells saying which block it is.
+func where(c Change) string {
+	return fmt.Sprintf("`%s` | %d | %s", cell(c.File), c.After.Index+1, cell(c.After.Name))
+}
+
+// fence returns a code fence longer than any run of backticks in s.
+func fence(s string) string {
+	longest, run := 0, 0
+	for _, c := range s {
+		if c == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+// tail returns the last n lines of s.
+func tail(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = append([]string{"..."}, lines[len(lines)-n:]...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WriteMarkdown writes the diff as markdown: a headline, a table of
+// the runs' counts, then tables of the blocks that newly fail, with
+// the end of their stderr, that were fixed, and that got slower.
+func (d *Diff) WriteMarkdown(w io.Writer) {
+	fmt.Fprintf(w, "### mdrip: %s, %s, %s\n\n",
+		plural(len(d.Failures), "new failure", "new failures"),
+		plural(len(d.Fixed), "fixed block", "fixed blocks"),
+		plural(len(d.Slower), "slower block", "slower blocks"))
+	fmt.Fprint(w, "| | Before | After |\n|---|---:|---:|\n")
+	for _, state := range []string{"passed", "failed", "skipped"} {
+		fmt.Fprintf(w, "| %s | %d | %d |\n",
+			strings.Title(state), count(d.Before, state), count(d.After, state))
+	}
+	if len(d.Failures) > 0 {
+		fmt.Fprint(w, "\n#### New failures\n\n| File | Block | Name | Before |\n|---|---:|---|---|\n")
+		for _, c := range d.Failures {
+			before := "new"
+			if c.Before != nil {
+				before = c.Before.State
+			}
+			fmt.Fprintf(w, "| %s | %s |\n", where(c), before)
+		}
+		for _, c := range d.Failures {
+			out := tail(c.After.StdErr, maxOutputLines)
+			if len(strings.TrimSpace(out)) == 0 {
+				continue
+			}
+			f := fence(out)
+			fmt.Fprintf(w, "\n<details><summary>stderr of %s #%d</summary>\n\n%s\n%s\n%s\n\n</details>\n",
+				c.File, c.After.Index+1, f, out, f)
+		}
+	}
+	if len(d.Fixed) > 0 {
+		fmt.Fprint(w, "\n#### Fixed\n\n| File | Block | Name |\n|---|---:|---|\n")
+		for _, c := range d.Fixed {
+			fmt.Fprintf(w, "| %s |\n", where(c))
+		}
+	}
+	if len(d.Slower) > 0 {
+		fmt.Fprint(w, "\n#### Slower\n\n| File | Block | Name | Before | After | Change |\n"+
+			"|---|---:|---|---:|---:|---:|\n")
+		for _, c := range d.Slower {
+			change := "-"
+			if c.Before.Seconds > 0 {
+				change = fmt.Sprintf("+%.0f%%",
+					100*(c.After.Seconds-c.Before.Seconds)/c.Before.Seconds)
+			}
+			fmt.Fprintf(w, "| %s | %.1fs | %.1fs | %s |\n", where(c),
+				c.Before.Seconds, c.After.Seconds, change)
+		}
+	}
+}
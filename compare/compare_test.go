@@ -0,0 +1,132 @@
+package compare
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/schema"
+)
+
+func results(blocks ...schema.BlockResult) *schema.Results {
+	return &schema.Results{
+		Header: schema.Header{Version: schema.Version, Kind: schema.KindResults},
+		Blocks: blocks}
+}
+
+func block(file string, index int, name, state string, seconds float64) schema.BlockResult {
+	return schema.BlockResult{File: file, Index: index, Name: name, State: state, Seconds: seconds}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compare")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for n, test := range map[string]struct {
+		content string
+		err     string
+	}{
+		"fine":    {`{"version": "mdrip/v1", "kind": "results", "passed": true, "blocks": []}`, ""},
+		"tree":    {`{"version": "mdrip/v1", "kind": "tree"}`, `holds "tree"`},
+		"garbage": {`passed`, "bad results"},
+	} {
+		p := filepath.Join(dir, n+".json")
+		if err := ioutil.WriteFile(p, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Load(p)
+		if len(test.err) == 0 {
+			if err != nil {
+				t.Errorf("%s: %v", n, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, want %q", n, err, test.err)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	before := results(
+		block("install.md", 0, "download", "passed", 2),
+		block("install.md", 1, "unpack", "failed", 1),
+		block("install.md", 2, "check", "skipped", 0),
+		block("run.md", 0, "serve", "passed", 10),
+		block("run.md", 1, "serve", "passed", 0.1),
+		block("run.md", 2, "query", "passed", 5),
+	)
+	after := results(
+		block("install.md", 0, "download", "passed", 2.5),
+		block("install.md", 1, "verify", "failed", 1),
+		block("install.md", 2, "unpack", "passed", 1),
+		block("install.md", 3, "check", "failed", 0.2),
+		block("run.md", 0, "serve", "passed", 20),
+		block("run.md", 1, "serve", "passed", 0.9),
+		block("run.md", 2, "query", "passed", 5.5),
+	)
+	after.Blocks[3].StdErr = "check: not found\n"
+	// The runs were of checkouts in different places.
+	for i := range before.Blocks {
+		before.Blocks[i].File = "/ci/main/docs/" + before.Blocks[i].File
+	}
+	for i := range after.Blocks {
+		after.Blocks[i].File = "/ci/pr-7/docs/" + after.Blocks[i].File
+	}
+	d := Compare(before, after, 50)
+	names := func(changes []Change) string {
+		var s []string
+		for _, c := range changes {
+			s = append(s, c.File+"#"+c.After.Name)
+		}
+		return strings.Join(s, " ")
+	}
+	for _, test := range []struct {
+		what, got, want string
+	}{
+		{"failures", names(d.Failures), "install.md#verify install.md#check"},
+		{"fixed", names(d.Fixed), "install.md#unpack"},
+		{"slower", names(d.Slower), "run.md#serve"},
+	} {
+		if test.got != test.want {
+			t.Errorf("%s: got %q, want %q", test.what, test.got, test.want)
+		}
+	}
+	if !d.Regressed() {
+		t.Errorf("expected a regression")
+	}
+	var b strings.Builder
+	d.WriteMarkdown(&b)
+	for _, want := range []string{
+		"### mdrip: 2 new failures, 1 fixed block, 1 slower block\n",
+		"| Failed | 1 | 2 |\n",
+		"| `install.md` | 2 | verify | new |\n",
+		"| `install.md` | 4 | check | skipped |\n",
+		"<details><summary>stderr of install.md #4</summary>\n\n```\ncheck: not found\n```\n",
+		"| `install.md` | 3 | unpack |\n",
+		"| `run.md` | 1 | serve | 10.0s | 20.0s | +100% |\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("got\n%s\nwant it to hold\n%s", b.String(), want)
+		}
+	}
+	if Compare(before, before, 50).Regressed() {
+		t.Errorf("a run shouldn't regress from itself")
+	}
+}
+
+func TestFence(t *testing.T) {
+	for s, want := range map[string]string{
+		"plain":          "```",
+		"a ``` fence":    "````",
+		"``````` longer": "````````",
+	} {
+		if got := fence(s); got != want {
+			t.Errorf("%q: got %q, want %q", s, got, want)
+		}
+	}
+}
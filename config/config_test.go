@@ -1,6 +1,7 @@
 package config
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -14,3 +15,21 @@ func TestGetConfig(t *testing.T) {
 		t.Errorf("expected data source complaint, not: " + err.Error())
 	}
 }
+
+func TestAddEnv(t *testing.T) {
+	c := DefaultConfig()
+	c.runOpts.Env = []string{"A=1"}
+	c.runOpts.ClearEnv = true
+	if err := c.AddEnv([]string{"ZONE=eu"}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Runner() == nil {
+		t.Fatalf("expected a runner")
+	}
+	if got := strings.Join(c.runOpts.Env, " "); got != "A=1 ZONE=eu" {
+		t.Errorf("got env %q", got)
+	}
+	if !c.runOpts.ClearEnv {
+		t.Errorf("lost the other options")
+	}
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultConfigFile is the config file mdrip reads, if it's in
+// the current directory, e.g. at the root of a repository.
+const DefaultConfigFile = "mdrip.yaml"
+
+// settings hold flag values from a config file, by flag name;
+// a repeatable flag, e.g. --label, may have several.
+type settings map[string][]string
+
+// configFile holds the settings of a config file: those for
+// every mode, and those for just one, e.g. under "test:", and
+// the file arguments to use if none are given.
+type configFile struct {
+	all    settings
+	byMode map[ModeType]settings
+	args   []string
+}
+
+// modeNamed is the mode with the given name, e.g. test, or
+// one of its command words, e.g. serve; false if none is.
+func modeNamed(n string) (ModeType, bool) {
+	switch n {
+	case "print":
+		return ModePrint, true
+	case "test":
+		return ModeTest, true
+	case "demo":
+		return ModeDemo, true
+	case "tmux":
+		return ModeTmux, true
+	}
+	m, ok := commandModes[n]
+	return m, ok
+}
+
+// parseConfigFile parses a config file's YAML, a map of flag
+// names, in either spelling, to values - a list of them for a
+// repeatable flag, and, for --env, maybe a map - and of mode
+// names to such maps, applying only in those modes, and of
+// args to file arguments, e.g.
+//
+//	args: [docs]
+//	label: test
+//	fetchTimeOut: 30s
+//	exclude: [drafts]
+//	test:
+//	  env:
+//	    REGION: us-east1
+//	  keepGoing: true
+func parseConfigFile(b []byte) (*configFile, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	result := &configFile{settings{}, map[ModeType]settings{}, nil}
+	for k, v := range doc {
+		if k == "args" {
+			a := settings{}
+			a.addValues(k, v)
+			result.args = a[k]
+			continue
+		}
+		if m, ok := modeNamed(k); ok {
+			section, ok := v.(map[interface{}]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s should hold flags for that mode", k)
+			}
+			s := settings{}
+			for name, x := range section {
+				if err := s.add(fmt.Sprint(name), x); err != nil {
+					return nil, err
+				}
+			}
+			result.byMode[m] = s
+			continue
+		}
+		if err := result.all.add(k, v); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// add the value, or values, of the named flag.
+func (s settings) add(name string, v interface{}) error {
+	f := lookupFlag(name)
+	if f == nil {
+		return fmt.Errorf("there's no flag named %s", name)
+	}
+	s.addValues(f.Name, v)
+	return nil
+}
+
+// addValues adds the value, or values, under the key.
+func (s settings) addValues(k string, v interface{}) {
+	switch x := v.(type) {
+	case []interface{}:
+		for _, y := range x {
+			s[k] = append(s[k], fmt.Sprint(y))
+		}
+	case map[interface{}]interface{}:
+		// e.g. env: {REGION: us-east1}.
+		var pairs []string
+		for name, y := range x {
+			pairs = append(pairs, fmt.Sprintf("%v=%v", name, y))
+		}
+		sort.Strings(pairs)
+		s[k] = append(s[k], pairs...)
+	case nil:
+		s[k] = append(s[k], "")
+	default:
+		s[k] = append(s[k], fmt.Sprint(x))
+	}
+}
+
+// flagKey is a flag's name, less case and dashes, so
+// that both of a flag's spellings, e.g. cacheDir and
+// cache-dir, have the same key.
+func flagKey(name string) string {
+	return strings.ToLower(strings.Replace(name, "-", "", -1))
+}
+
+// lookupFlag is the flag with the given name, in
+// either spelling; nil if there's none.
+func lookupFlag(name string) *flag.Flag {
+	if f := flag.Lookup(name); f != nil {
+		return f
+	}
+	var result *flag.Flag
+	flag.VisitAll(func(f *flag.Flag) {
+		if result == nil && flagKey(f.Name) == flagKey(name) {
+			result = f
+		}
+	})
+	return result
+}
+
+// forMode merges the settings for every mode with those for the
+// given one, which win; a flag's values come from one or the other.
+func (c *configFile) forMode(m ModeType) settings {
+	result := settings{}
+	for k, v := range c.all {
+		result[k] = v
+	}
+	for k, v := range c.byMode[m] {
+		result[k] = v
+	}
+	return result
+}
+
+// fromConfigFile holds the names of the flags set from the config
+// file, so that checks of which flags were given can skip them.
+var fromConfigFile = map[string]bool{}
+
+// privileged holds the keys of the flags that make mdrip run
+// blocks, or run them with more privilege or reach, which only
+// a config file named with --config may set.  The one found in
+// the current directory may be a stranger's, e.g. in a clone,
+// and reading its tutorial mustn't run it, let alone as root.
+var privileged = flagKeys(
+	"mode", "allowSudo", "runAs", "sudoAskpass", "preflight",
+	"browser", "edit", "editRemote", "allowOrigin", "useHostname")
+
+func flagKeys(names ...string) map[string]bool {
+	result := map[string]bool{}
+	for _, n := range names {
+		result[flagKey(n)] = true
+	}
+	return result
+}
+
+// apply sets the flags, but for those whose keys are in given.
+// Unless trusted, i.e. named with --config, the settings may
+// not set privileged flags.
+func (s settings) apply(given map[string]bool, trusted bool) error {
+	var names []string
+	for n := range s {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		if given[flagKey(n)] || len(s[n]) == 0 {
+			continue
+		}
+		if !trusted && privileged[flagKey(n)] {
+			return fmt.Errorf(
+				"only a config file named with --config may set %s", n)
+		}
+		for _, v := range s[n] {
+			if err := flag.Set(n, v); err != nil {
+				return fmt.Errorf("%s: %v", n, err)
+			}
+			fromConfigFile[n] = true
+		}
+	}
+	return nil
+}
+
+// readConfigFile sets the flags not given on the command line,
+// nor, for --mode, as a leading command word, to the values in
+// the config file, if there is one, so that flags win.  It
+// returns the file's args, and whether there was a file.
+// Only a file named with --config may set privileged flags.
+func readConfigFile(modeWord bool) ([]string, bool, error) {
+	name := *configFileName
+	if len(name) == 0 {
+		return nil, false, nil
+	}
+	trusted := isFlagSet("config")
+	b, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) && !trusted {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	c, err := parseConfigFile(b)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %v", name, err)
+	}
+	given := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { given[flagKey(f.Name)] = true })
+	if modeWord {
+		given[flagKey("mode")] = true
+	}
+	// The mode picks the settings; so, set it first.
+	if err := (settings{"mode": c.all["mode"]}).apply(given, trusted); err != nil {
+		return nil, false, fmt.Errorf("%s: %v", name, err)
+	}
+	given[flagKey("mode")] = true
+	if err := c.forMode(determineMode()).apply(given, trusted); err != nil {
+		return nil, false, fmt.Errorf("%s: %v", name, err)
+	}
+	glog.Infof("Read flags from %s", name)
+	return c.args, true, nil
+}
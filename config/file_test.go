@@ -0,0 +1,147 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfigFile(t *testing.T) {
+	c, err := parseConfigFile([]byte(`
+mode: test
+label: [setup, test]
+fetch-time-out: 30s
+exclude: drafts
+test:
+  env:
+    ZONE: b
+    REGION: us-east1
+  keepGoing: true
+  label: slow
+serve:
+  port: 8080
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := func(s settings) string {
+		var x []string
+		for _, n := range []string{"mode", "label", "fetchTimeOut", "exclude", "env", "keepGoing", "port"} {
+			if v, ok := s[n]; ok {
+				x = append(x, n+"="+strings.Join(v, ","))
+			}
+		}
+		return strings.Join(x, " ")
+	}
+	if s := got(c.forMode(ModePrint)); s != "mode=test label=setup,test fetchTimeOut=30s exclude=drafts" {
+		t.Errorf("print: got %s", s)
+	}
+	// The mode's settings win, e.g. for label.
+	if s := got(c.forMode(ModeTest)); s !=
+		"mode=test label=slow fetchTimeOut=30s exclude=drafts env=REGION=us-east1,ZONE=b keepGoing=true" {
+		t.Errorf("test: got %s", s)
+	}
+	if s := got(c.forMode(ModeDemo)); !strings.HasSuffix(s, "port=8080") {
+		t.Errorf("demo: got %s", s)
+	}
+	for doc, want := range map[string]string{
+		"labl: test":    "there's no flag named labl",
+		"test: verbose": "test should hold flags for that mode",
+		"test: {x: 1}":  "there's no flag named x",
+	} {
+		if _, err := parseConfigFile([]byte(doc)); err == nil || err.Error() != want {
+			t.Errorf("%s: got error %v, want %s", doc, err, want)
+		}
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdrip-config-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, DefaultConfigFile)
+	if err := ioutil.WriteFile(name, []byte(`
+args: [docs]
+label: all
+fetchTimeOut: 30s
+parallel: 2
+test:
+  label: slow
+  parallel: 3
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		*mode, *configFileName, *parallel = "print", DefaultConfigFile, 1
+		*labels, *fetchTimeOut = nil, 10*time.Second
+		fromConfigFile = map[string]bool{}
+	}()
+	if err := flag.CommandLine.Parse(
+		[]string{"--mode", "test", "--parallel", "4", "--config", name}); err != nil {
+		t.Fatal(err)
+	}
+	args, ok, err := readConfigFile(false)
+	if err != nil || !ok {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	if strings.Join(args, " ") != "docs" {
+		t.Errorf("got args %v", args)
+	}
+	// The command line wins, then the mode's settings,
+	// then those for every mode.
+	if *parallel != 4 {
+		t.Errorf("got --parallel %d, want 4, from the command line", *parallel)
+	}
+	if strings.Join(*labels, ",") != "slow" {
+		t.Errorf("got --label %v, want slow, from test:", *labels)
+	}
+	if *fetchTimeOut != 30*time.Second {
+		t.Errorf("got --fetchTimeOut %v, want 30s, from the top level", *fetchTimeOut)
+	}
+	// Flags from the file weren't given.
+	if !given("parallel") || given("label") || given("fetch-time-out") {
+		t.Errorf("got given parallel %v, label %v, fetchTimeOut %v",
+			given("parallel"), given("label"), given("fetch-time-out"))
+	}
+}
+
+func TestApplyPrivileged(t *testing.T) {
+	defer func() {
+		*allowSudo, *runAs = false, ""
+		fromConfigFile = map[string]bool{}
+	}()
+	for _, test := range []struct {
+		s       settings
+		given   map[string]bool
+		trusted bool
+		err     string
+	}{
+		{settings{"allowSudo": {"true"}}, nil, false,
+			"only a config file named with --config may set allowSudo"},
+		{settings{"run-as": {"root"}}, nil, false,
+			"only a config file named with --config may set run-as"},
+		{settings{"mode": nil}, nil, false, ""},
+		// A flag given on the command line isn't set from the file.
+		{settings{"allowSudo": {"true"}}, map[string]bool{"allowsudo": true}, false, ""},
+		{settings{"allowSudo": {"true"}, "parallel": {"2"}}, nil, true, ""},
+	} {
+		err := test.s.apply(test.given, test.trusted)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.err {
+			t.Errorf("%v, trusted %v: got %q, want %q", test.s, test.trusted, got, test.err)
+		}
+	}
+	if !*allowSudo || *parallel != 2 {
+		t.Errorf("a trusted file's settings weren't applied")
+	}
+	*parallel = 1
+}
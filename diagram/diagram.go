@@ -0,0 +1,112 @@
+// Package diagram deals with fenced code blocks holding diagram
+// source, e.g. mermaid or plantuml, which should be drawn rather
+// than extracted and run.
+package diagram
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/base64"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Languages, from a code fence's info string, that hold diagrams.
+const (
+	// Mermaid diagrams are drawn in the browser,
+	// or by a Kroki server.
+	Mermaid = "mermaid"
+	// PlantUML diagrams are drawn by a PlantUML server.
+	PlantUML = "plantuml"
+)
+
+// IsDiagram is true if the language names a diagram language.
+func IsDiagram(language string) bool {
+	l := strings.ToLower(language)
+	return l == Mermaid || l == PlantUML
+}
+
+// Servers are the URLs of the servers drawing diagrams; an
+// empty URL leaves diagrams of that language to the browser,
+// for mermaid, or shown as text, for plantuml.
+type Servers struct {
+	// PlantUML is a PlantUML server, e.g. https://www.plantuml.com/plantuml.
+	PlantUML string
+	// Mermaid is a Kroki server, e.g. https://kroki.io.
+	Mermaid string
+}
+
+var plantUMLBlock = codeBlock(PlantUML)
+
+var mermaidBlock = codeBlock(Mermaid)
+
+// codeBlock matches a rendered code block in the given language.
+func codeBlock(language string) *regexp.Regexp {
+	return regexp.MustCompile(
+		`(?s)<pre><code class="language-` + language + `">(.*?)</code></pre>`)
+}
+
+// Render replaces rendered code blocks in the HTML with images
+// of the diagrams they hold, drawn by the servers, for those
+// languages that have one.
+func Render(h string, s Servers) string {
+	if len(s.PlantUML) > 0 {
+		url := strings.TrimSuffix(s.PlantUML, "/") + "/svg/"
+		h = replaceBlocks(h, plantUMLBlock, func(src string) string {
+			return url + Encode(src)
+		})
+	}
+	if len(s.Mermaid) > 0 {
+		url := strings.TrimSuffix(s.Mermaid, "/") + "/" + Mermaid + "/svg/"
+		h = replaceBlocks(h, mermaidBlock, func(src string) string {
+			return url + EncodeKroki(src)
+		})
+	}
+	return h
+}
+
+// replaceBlocks replaces the code blocks the pattern matches
+// with images found at the URL made of their source.
+func replaceBlocks(h string, pattern *regexp.Regexp, url func(string) string) string {
+	return pattern.ReplaceAllStringFunc(h, func(m string) string {
+		src := html.UnescapeString(pattern.FindStringSubmatch(m)[1])
+		return `<img class="diagram" alt="diagram" src="` +
+			html.EscapeString(url(src)) + `">`
+	})
+}
+
+// EncodeKroki encodes diagram source the way Kroki servers
+// expect it in a URL: zlib compressed, then in URL safe base64.
+func EncodeKroki(src string) string {
+	var buf bytes.Buffer
+	w, _ := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	w.Write([]byte(src))
+	w.Close()
+	return base64.URLEncoding.EncodeToString(buf.Bytes())
+}
+
+const plantUMLAlphabet = "0123456789" +
+	"ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+	"abcdefghijklmnopqrstuvwxyz-_"
+
+// Encode encodes diagram source the way PlantUML servers expect
+// it in a URL: deflated, then in PlantUML's variant of base64.
+func Encode(src string) string {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestCompression)
+	w.Write([]byte(src))
+	w.Close()
+	data := buf.Bytes()
+	var b strings.Builder
+	for i := 0; i < len(data); i += 3 {
+		var c [3]byte
+		copy(c[:], data[i:])
+		b.WriteByte(plantUMLAlphabet[c[0]>>2])
+		b.WriteByte(plantUMLAlphabet[(c[0]&0x3)<<4|c[1]>>4])
+		b.WriteByte(plantUMLAlphabet[(c[1]&0xF)<<2|c[2]>>6])
+		b.WriteByte(plantUMLAlphabet[c[2]&0x3F])
+	}
+	return b.String()
+}
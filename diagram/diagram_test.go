@@ -0,0 +1,77 @@
+package diagram
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/base64"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestIsDiagram(t *testing.T) {
+	for lang, want := range map[string]bool{
+		"mermaid": true, "PlantUML": true, "bash": false, "": false,
+	} {
+		if got := IsDiagram(lang); got != want {
+			t.Errorf("IsDiagram(%q) = %v, want %v", lang, got, want)
+		}
+	}
+}
+
+func decode(s string) (string, error) {
+	var data []byte
+	for i := 0; i+3 < len(s); i += 4 {
+		var c [4]byte
+		for j := range c {
+			c[j] = byte(strings.IndexByte(plantUMLAlphabet, s[i+j]))
+		}
+		data = append(data, c[0]<<2|c[1]>>4, c[1]<<4|c[2]>>2, c[2]<<6|c[3])
+	}
+	b, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(data)))
+	return string(b), err
+}
+
+func TestEncode(t *testing.T) {
+	const src = "Bob -> Alice : hello"
+	got, err := decode(Encode(src))
+	if err != nil || got != src {
+		t.Errorf("got (%q, %v) from decoding, want %q", got, err, src)
+	}
+}
+
+func TestRender(t *testing.T) {
+	h := "<p>x</p>\n<pre><code class=\"language-plantuml\">A -&gt; B\n</code></pre>\n" +
+		"<pre><code class=\"language-mermaid\">graph TD\n  A--&gt;B\n</code></pre>\n"
+	if got := Render(h, Servers{}); got != h {
+		t.Errorf("without a renderer, html should be unchanged")
+	}
+	got := Render(h, Servers{PlantUML: "http://example.com/plantuml/"})
+	want := `<img class="diagram" alt="diagram" src="http://example.com/plantuml/svg/` +
+		Encode("A -> B\n") + `">`
+	if !strings.Contains(got, want) || !strings.Contains(got, "language-mermaid") {
+		t.Errorf("got\n%s\nwant it to hold\n%s", got, want)
+	}
+	got = Render(h, Servers{Mermaid: "https://kroki.example.com"})
+	want = `<img class="diagram" alt="diagram" src="https://kroki.example.com/mermaid/svg/` +
+		EncodeKroki("graph TD\n  A-->B\n") + `">`
+	if !strings.Contains(got, want) || !strings.Contains(got, "language-plantuml") {
+		t.Errorf("got\n%s\nwant it to hold\n%s", got, want)
+	}
+}
+
+func TestEncodeKroki(t *testing.T) {
+	src := "graph TD\n  A-->B\n"
+	data, err := base64.URLEncoding.DecodeString(EncodeKroki(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ioutil.ReadAll(r); string(got) != src {
+		t.Errorf("got %q, want %q", got, src)
+	}
+}
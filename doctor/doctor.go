@@ -0,0 +1,308 @@
+// Package doctor diagnoses the environment mdrip runs in, reporting
+// problems along with suggested fixes.
+package doctor
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/loader"
+	"github.com/monopole/mdrip/model"
+	"github.com/monopole/mdrip/program"
+	"github.com/monopole/mdrip/tmux"
+)
+
+// Finding is the result of one diagnostic check.
+type Finding struct {
+	name   string
+	ok     bool
+	detail string
+	fix    string
+}
+
+// Name of the check.
+func (f *Finding) Name() string { return f.name }
+
+// Ok is true if the check passed.
+func (f *Finding) Ok() bool { return f.ok }
+
+// Detail describes what was found.
+func (f *Finding) Detail() string { return f.detail }
+
+// Fix suggests what to do if the check failed.
+func (f *Finding) Fix() string { return f.fix }
+
+func pass(name, detail string) *Finding {
+	return &Finding{name, true, detail, ""}
+}
+
+func fail(name, detail, fix string) *Finding {
+	return &Finding{name, false, detail, fix}
+}
+
+// Doctor runs diagnostic checks.
+type Doctor struct {
+	hostAndPort string
+	ds          *base.DataSet
+	// staleMonths is how long a lesson may go unchanged;
+	// 0 if that's not to be checked.
+	staleMonths int
+	// links checks the content's links; nil if they're
+	// not to be checked.
+	links *LinkChecker
+}
+
+// NewDoctor returns a Doctor that will check the given server
+// address and, if non-nil, the given content, flagging lessons
+// unchanged for more than staleMonths, if that's not 0, and,
+// if links isn't nil, links leading nowhere.
+func NewDoctor(hostAndPort string, ds *base.DataSet, staleMonths int, links *LinkChecker) *Doctor {
+	return &Doctor{hostAndPort, ds, staleMonths, links}
+}
+
+// Examine runs all checks, returning their findings in order.
+func (d *Doctor) Examine() []*Finding {
+	result := []*Finding{
+		checkShell(),
+		checkTmux(),
+		checkTerminal(),
+		checkGit(),
+		checkPort(d.hostAndPort),
+	}
+	if d.ds != nil {
+		f, t := checkContent(d.ds)
+		result = append(result, f)
+		if t != nil {
+			result = append(result, checkNeeds(t), checkAnchors(t))
+		}
+		if t != nil && d.staleMonths > 0 {
+			result = append(result, checkFreshness(t, d.staleMonths, time.Now()))
+		}
+		if t != nil && d.links != nil {
+			result = append(result, checkLinks(t, d.links))
+		}
+	}
+	return result
+}
+
+func checkShell() *Finding {
+	const name = "shell"
+	p, err := exec.LookPath("bash")
+	if err != nil {
+		return fail(name, "bash not found on PATH",
+			"install bash; --mode test runs code blocks with it")
+	}
+	return pass(name, p)
+}
+
+func checkTmux() *Finding {
+	const name = "tmux"
+	p, err := exec.LookPath("tmux")
+	if err != nil {
+		return fail(name, "tmux not found on PATH",
+			"install tmux to paste blocks from --mode demo into a terminal")
+	}
+	v, err := exec.Command(p, "-V").Output()
+	version := strings.TrimSpace(string(v))
+	if err != nil {
+		version = "unknown version"
+	}
+	if p != tmux.Path {
+		return fail(name, version+" found at "+p+", but mdrip expects "+tmux.Path,
+			"symlink "+p+" to "+tmux.Path)
+	}
+	if !tmux.NewTmux(tmux.Path).IsUp() {
+		return fail(name, version+" installed, but not running",
+			"start tmux before clicking code blocks in --mode demo")
+	}
+	return pass(name, version+" running")
+}
+
+func checkTerminal() *Finding {
+	const name = "terminal"
+	term := os.Getenv("TERM")
+	if len(term) == 0 || term == "dumb" {
+		return fail(name, fmt.Sprintf("TERM=%q", term),
+			"set TERM (e.g. xterm-256color) so pasted blocks behave as if typed")
+	}
+	detail := "TERM=" + term
+	if fi, err := os.Stdout.Stat(); err == nil && fi.Mode()&os.ModeCharDevice == 0 {
+		detail += ", stdout is not a terminal"
+	}
+	return pass(name, detail)
+}
+
+func checkGit() *Finding {
+	const name = "git"
+	p, err := exec.LookPath("git")
+	if err != nil {
+		return fail(name, "git not found on PATH",
+			"install git to load tutorials from git repository arguments")
+	}
+	return pass(name, p)
+}
+
+func checkPort(hostAndPort string) *Finding {
+	const name = "port"
+	l, err := net.Listen("tcp", hostAndPort)
+	if err != nil {
+		return fail(name, hostAndPort+" unavailable: "+err.Error(),
+			"stop whatever is listening there, or pass a different --port")
+	}
+	l.Close()
+	return pass(name, hostAndPort+" available")
+}
+
+// checkContent checks the content, returning it too if it loaded.
+func checkContent(ds *base.DataSet) (*Finding, model.Tutorial) {
+	const name = "content"
+	t, err := loader.NewLoader(ds).Load()
+	if err != nil {
+		return fail(name, "unable to load "+ds.String()+": "+err.Error(),
+			"point mdrip at a .md file, or a directory holding some"), nil
+	}
+	c := model.NewTutorialLessonCounter()
+	t.Accept(c)
+	p := program.NewProgramFromTutorial(base.WildCardLabel, t)
+	blocks := 0
+	for _, l := range p.Lessons() {
+		blocks += len(l.Blocks())
+	}
+	detail := fmt.Sprintf("%d lessons, %d code blocks in %s",
+		c.Count(), blocks, ds.String())
+	if blocks == 0 {
+		return fail(name, detail,
+			"add fenced code blocks; only they can be extracted and run"), t
+	}
+	if x := model.SlugCollisions(t); len(x) > 0 {
+		return fail(name, strings.Join(x, "; "),
+			"rename one of each pair, or give it a slug in its front matter"), t
+	}
+	return pass(name, detail), t
+}
+
+// checkNeeds checks that blocks need, by @needs, only
+// blocks named, by @name, before them.
+func checkNeeds(t model.Tutorial) *Finding {
+	const name = "needs"
+	x := program.NewProgramFromTutorial(base.WildCardLabel, t).CheckNeeds()
+	if len(x) > 0 {
+		return fail(name, strings.Join(x, "; "),
+			"name each needed block with @name, and move it before the blocks needing it")
+	}
+	return pass(name, "every block needed by @needs is named, and comes before its needers")
+}
+
+// checkAnchors checks that heading ids are unique, and that
+// anchors in front matter lead to headings.
+func checkAnchors(t model.Tutorial) *Finding {
+	const name = "anchors"
+	x := program.NewProgramFromTutorial(base.WildCardLabel, t).CheckAnchors()
+	if len(x) > 0 {
+		return fail(name, strings.Join(x, "; "),
+			"give each heading its own {#id}, and point each anchor at a heading's id")
+	}
+	return pass(name, "heading ids are unique, and every anchor leads to one")
+}
+
+// maxStaleShown is how many stale lessons, or broken
+// links, a finding names.
+const maxStaleShown = 5
+
+// lessonCollector gathers every lesson of a tutorial.
+type lessonCollector struct {
+	lessons []*model.LessonTut
+}
+
+func (v *lessonCollector) VisitBlockTut(b *model.BlockTut) {}
+
+func (v *lessonCollector) VisitLessonTut(l *model.LessonTut) {
+	v.lessons = append(v.lessons, l)
+}
+
+func (v *lessonCollector) VisitCourse(c *model.Course) {
+	for _, x := range c.Children() {
+		x.Accept(v)
+	}
+}
+
+func (v *lessonCollector) VisitTopCourse(t *model.TopCourse) {
+	v.VisitCourse(&t.Course)
+}
+
+// checkFreshness flags lessons whose files, per git, haven't
+// changed for more than the given number of months.
+func checkFreshness(t model.Tutorial, months int, now time.Time) *Finding {
+	const name = "freshness"
+	v := &lessonCollector{}
+	t.Accept(v)
+	var known int
+	var stale []string
+	for _, l := range v.lessons {
+		r := l.Revision()
+		if r == nil {
+			continue
+		}
+		known++
+		if r.IsOlderThan(months, now) {
+			stale = append(stale, fmt.Sprintf("%s (%s, %s)", l.Path(), r.Date(), r.Author))
+		}
+	}
+	if known == 0 {
+		return pass(name, "no lessons in git, so none dated")
+	}
+	if len(stale) == 0 {
+		return pass(name, fmt.Sprintf(
+			"all %d lessons in git changed in the last %d months", known, months))
+	}
+	shown := stale
+	if len(shown) > maxStaleShown {
+		shown = append(shown[:maxStaleShown:maxStaleShown], "...")
+	}
+	return fail(name, fmt.Sprintf("%d of %d lessons unchanged for over %d months: %s",
+		len(stale), known, months, strings.Join(shown, ", ")),
+		"check they still work, and commit any fix, or a new verified date in their front matter")
+}
+
+// checkLinks flags links in lessons leading to errors,
+// e.g. 404s, or moved permanently elsewhere.
+func checkLinks(t model.Tutorial, lc *LinkChecker) *Finding {
+	const name = "links"
+	v := &lessonCollector{}
+	t.Accept(v)
+	n, bad := lc.Check(v.lessons)
+	if len(bad) == 0 {
+		return pass(name, fmt.Sprintf("all %d links lead somewhere", n))
+	}
+	shown := bad
+	if len(shown) > maxStaleShown {
+		shown = append(shown[:maxStaleShown:maxStaleShown], "...")
+	}
+	return fail(name, fmt.Sprintf("%d of %d links are broken or moved: %s",
+		len(bad), n, strings.Join(shown, "; ")),
+		"fix or update each link, or skip it with --linkAllow {prefix}")
+}
+
+// Report writes findings to the given writer, returning
+// the number of failed checks.
+func Report(w io.Writer, findings []*Finding) int {
+	failures := 0
+	for _, f := range findings {
+		status := "ok  "
+		if !f.Ok() {
+			status = "FAIL"
+			failures++
+		}
+		fmt.Fprintf(w, "[%s] %-9s %s\n", status, f.Name(), f.Detail())
+		if !f.Ok() {
+			fmt.Fprintf(w, "       %-9s fix: %s\n", "", f.Fix())
+		}
+	}
+	return failures
+}
@@ -0,0 +1,91 @@
+package doctor
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+func TestCheckPort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if f := checkPort(l.Addr().String()); f.Ok() {
+		t.Errorf("expected port %s to be unavailable", l.Addr())
+	}
+}
+
+func TestCheckContent(t *testing.T) {
+	ds, _ := base.NewDataSet([]string{"/zebra/does/not/exist"})
+	if f, _ := checkContent(ds); f.Ok() {
+		t.Errorf("expected content failure, got %s", f.Detail())
+	}
+}
+
+func TestCheckContentSlugCollision(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdrip-doctor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "setup.md"), []byte("```\ndate\n```\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "install.md"),
+		[]byte("---\nslug: Setup\n---\n```\ndate\n```\n"), 0644)
+	ds, _ := base.NewDataSet([]string{dir})
+	f, _ := checkContent(ds)
+	if f.Ok() || !strings.Contains(f.Detail(), `both have the slug "`) {
+		t.Errorf("expected a slug collision, got %s", f.Detail())
+	}
+}
+
+func TestCheckFreshness(t *testing.T) {
+	now := time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+	lesson := func(name string, r *model.Revision) model.Tutorial {
+		l := model.NewLessonTutForTests(base.FilePath(name), []*model.BlockTut{})
+		l.SetRevision(r)
+		return l
+	}
+	recent := model.NewRevision(now.AddDate(0, -1, 0), "Tess")
+	old := model.NewRevision(now.AddDate(-2, 0, 0), "Tess")
+	var tests = map[string]struct {
+		lessons []model.Tutorial
+		ok      bool
+		detail  string
+	}{
+		"notInGit": {[]model.Tutorial{lesson("a.md", nil)}, true, "no lessons in git"},
+		"fresh":    {[]model.Tutorial{lesson("a.md", recent), lesson("b.md", nil)}, true, "all 1 lessons"},
+		"stale": {[]model.Tutorial{lesson("a.md", recent), lesson("b.md", old)}, false,
+			"1 of 2 lessons unchanged for over 12 months: b.md (2022-06-15, Tess)"},
+	}
+	for n, test := range tests {
+		f := checkFreshness(model.NewCourse(base.FilePath("c"), test.lessons), 12, now)
+		if f.Ok() != test.ok || !strings.Contains(f.Detail(), test.detail) {
+			t.Errorf("%s: got %v %s", n, f.Ok(), f.Detail())
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	var b bytes.Buffer
+	n := Report(&b, []*Finding{
+		pass("a", "fine"),
+		fail("b", "broken", "fix it"),
+	})
+	if n != 1 {
+		t.Errorf("expected 1 failure, got %d", n)
+	}
+	got := b.String()
+	if !strings.Contains(got, "[FAIL] b") || !strings.Contains(got, "fix: fix it") {
+		t.Errorf("unexpected report:\n%s", got)
+	}
+}
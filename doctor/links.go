@@ -0,0 +1,204 @@
+package doctor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+// LinkChecker checks that the http(s) URLs lessons hold, in
+// prose links and in code, e.g. the downloads of curl and wget,
+// still lead somewhere.
+type LinkChecker struct {
+	// allow holds prefixes of URLs not to check.
+	allow []string
+	// workers is how many URLs are checked at once.
+	workers int
+	timeOut time.Duration
+	// cache, if it has a directory, remembers URLs found good,
+	// so they aren't checked again until its TTL passes.
+	cache base.Cache
+}
+
+// NewLinkChecker returns a LinkChecker skipping URLs starting
+// with any of the allowed prefixes, checking up to workers
+// URLs at once, waiting up to timeOut for each.
+func NewLinkChecker(
+	allow []string, workers int, timeOut time.Duration, cache base.Cache) *LinkChecker {
+	if workers < 1 {
+		workers = 1
+	}
+	return &LinkChecker{allow, workers, timeOut, cache}
+}
+
+// linkURL matches http(s) URLs in markdown, prose or code.
+var linkURL = regexp.MustCompile(`https?://[^\s<>"'` + "`" + `()\[\]]+`)
+
+// neverChecked are hosts that are placeholders, or that
+// name the machine running the tutorial, not the web.
+var neverChecked = map[string]bool{
+	"localhost": true, "127.0.0.1": true, "0.0.0.0": true,
+	"example.com": true, "example.org": true, "example.net": true,
+}
+
+// findLinks returns the URLs worth checking in the markdown.
+func findLinks(md string) []string {
+	var result []string
+	for _, u := range linkURL.FindAllString(md, -1) {
+		u = strings.TrimRight(u, ".,;:!?*")
+		if strings.ContainsAny(u, "${") {
+			// Made of shell or template variables;
+			// unknowable until run.
+			continue
+		}
+		p, err := url.Parse(u)
+		if err != nil || len(p.Hostname()) == 0 || neverChecked[p.Hostname()] {
+			continue
+		}
+		result = append(result, u)
+	}
+	return result
+}
+
+func (lc *LinkChecker) isAllowed(u string) bool {
+	for _, a := range lc.allow {
+		if strings.HasPrefix(u, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// linkCacheFile holds, in the cache's directory, when each
+// URL was last found good.
+const linkCacheFile = "links.json"
+
+func (lc *LinkChecker) loadGood() map[string]time.Time {
+	good := map[string]time.Time{}
+	if len(lc.cache.Dir) == 0 {
+		return good
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(lc.cache.Dir, linkCacheFile)); err == nil {
+		json.Unmarshal(b, &good)
+	}
+	return good
+}
+
+func (lc *LinkChecker) saveGood(good map[string]time.Time) {
+	if len(lc.cache.Dir) == 0 {
+		return
+	}
+	b, err := json.MarshalIndent(good, "", "  ")
+	if err != nil || os.MkdirAll(lc.cache.Dir, 0755) != nil {
+		return
+	}
+	ioutil.WriteFile(filepath.Join(lc.cache.Dir, linkCacheFile), b, 0644)
+}
+
+// isMoved is true of the redirects saying a link
+// should change, rather than that it's fine as it is,
+// e.g. the 302s of release downloads.
+func isMoved(code int) bool {
+	return code == http.StatusMovedPermanently || code == http.StatusPermanentRedirect
+}
+
+// check returns what's wrong with the URL, or "" if nothing is.
+func (lc *LinkChecker) check(client *http.Client, u string) string {
+	resp, err := client.Head(u)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed ||
+		resp.StatusCode == http.StatusNotImplemented) {
+		// Some servers answer only GET.
+		resp.Body.Close()
+		resp, err = client.Get(u)
+	}
+	if err != nil {
+		return err.Error()
+	}
+	resp.Body.Close()
+	if isMoved(resp.StatusCode) {
+		return fmt.Sprintf("%s, to %s", resp.Status, resp.Header.Get("Location"))
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return resp.Status
+	}
+	return ""
+}
+
+// Check checks the links of the lessons, returning how many
+// there are, and a problem per bad one, naming the lessons
+// holding it.
+func (lc *LinkChecker) Check(lessons []*model.LessonTut) (int, []string) {
+	where := map[string][]string{}
+	var links []string
+	for _, l := range lessons {
+		for _, u := range findLinks(l.Raw()) {
+			if lc.isAllowed(u) {
+				continue
+			}
+			if _, ok := where[u]; !ok {
+				links = append(links, u)
+			}
+			p := string(l.Path())
+			if n := where[u]; len(n) == 0 || n[len(n)-1] != p {
+				where[u] = append(n, p)
+			}
+		}
+	}
+	good := lc.loadGood()
+	client := &http.Client{
+		Timeout: lc.timeOut,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if isMoved(req.Response.StatusCode) {
+				return http.ErrUseLastResponse
+			}
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after %d redirects", len(via))
+			}
+			return nil
+		},
+	}
+	var problems []string
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan bool, lc.workers)
+	now := time.Now()
+	var stale []string
+	for _, u := range links {
+		if t, ok := good[u]; !ok || now.Sub(t) >= lc.cache.TTL {
+			stale = append(stale, u)
+		}
+	}
+	for _, u := range stale {
+		wg.Add(1)
+		sem <- true
+		go func(u string) {
+			defer func() { <-sem; wg.Done() }()
+			bad := lc.check(client, u)
+			mu.Lock()
+			defer mu.Unlock()
+			if len(bad) == 0 {
+				good[u] = now
+				return
+			}
+			delete(good, u)
+			problems = append(problems, fmt.Sprintf(
+				"%s: %s (%s)", strings.Join(where[u], ", "), u, bad))
+		}(u)
+	}
+	wg.Wait()
+	lc.saveGood(good)
+	sort.Strings(problems)
+	return len(links), problems
+}
@@ -0,0 +1,91 @@
+package doctor
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+func TestFindLinks(t *testing.T) {
+	got := findLinks("See [the docs](https://x.io/docs).\n" +
+		"```\ncurl -LO https://dl.x.io/v1/x.tgz\n" +
+		"curl http://localhost:8080/healthz\n" +
+		"curl https://dl.x.io/$VERSION/x.tgz\n" +
+		"curl https://dl.x.io/{{.VERSION}}/x.tgz\n```\n")
+	want := "https://x.io/docs https://dl.x.io/v1/x.tgz"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+}
+
+func TestCheckLinks(t *testing.T) {
+	// The test server is on 127.0.0.1, usually never checked.
+	delete(neverChecked, "127.0.0.1")
+	defer func() { neverChecked["127.0.0.1"] = true }()
+	var mu sync.Mutex
+	hits := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hits[r.URL.Path]++
+			mu.Unlock()
+			switch r.URL.Path {
+			case "/ok":
+			case "/getOnly":
+				if r.Method == http.MethodHead {
+					w.WriteHeader(http.StatusMethodNotAllowed)
+				}
+			case "/release":
+				http.Redirect(w, r, "/ok", http.StatusFound)
+			case "/moved":
+				http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "mdrip-links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	lesson := func(name, md string) *model.LessonTut {
+		c := model.NewMdContent()
+		c.SetRaw(md)
+		return model.NewLessonTutFromMdContent(base.FilePath(name), c)
+	}
+	u := srv.URL
+	lessons := []*model.LessonTut{
+		lesson("a.md", fmt.Sprintf("[ok](%s/ok) and %s/getOnly, %s/release\n", u, u, u)),
+		lesson("b.md", fmt.Sprintf("curl %s/moved %s/gone %s/skip/this\n", u, u, u)),
+		lesson("c.md", fmt.Sprintf("curl %s/gone\n", u)),
+	}
+	lc := NewLinkChecker([]string{u + "/skip/"}, 2, time.Second,
+		base.Cache{Dir: dir, TTL: time.Hour})
+	n, bad := lc.Check(lessons)
+	want := []string{
+		"b.md, c.md: " + u + "/gone (404 Not Found)",
+		"b.md: " + u + "/moved (301 Moved Permanently, to /ok)",
+	}
+	if n != 5 || strings.Join(bad, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %d links, bad\n%s\nwant 5, bad\n%s",
+			n, strings.Join(bad, "\n"), strings.Join(want, "\n"))
+	}
+	if hits["/skip/this"] != 0 {
+		t.Errorf("checked an allowed link")
+	}
+	// Good links are cached; only the bad ones are checked again.
+	before := hits["/ok"]
+	if _, bad := lc.Check(lessons); len(bad) != 2 || hits["/ok"] != before || hits["/gone"] != 2 {
+		t.Errorf("got bad %v, hits %v", bad, hits)
+	}
+}
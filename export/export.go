@@ -0,0 +1,208 @@
+// Package export writes a tutorial's web app as static files, for
+// hosting on GitHub Pages, S3 or any other plain file server.
+//
+// The site's layout is
+//
+//	index.html               the first lesson
+//	{lessonPath}/index.html  each lesson, at the path demo mode serves it at
+//	_/glossary/index.html    the glossary
+//	_/print/index.html       every lesson, as a handout to print
+//	_/asset/{i}/{path}       images, by path under the i'th loaded path
+//	_/assets/{library}/...   the libraries pages load, e.g. KaTeX
+//	favicon.ico
+//	.nojekyll                so GitHub Pages serves the _ directory
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/diagram"
+	"github.com/monopole/mdrip/model"
+	"github.com/monopole/mdrip/program"
+	"github.com/monopole/mdrip/util"
+	"github.com/monopole/mdrip/webapp"
+)
+
+// assetDir is where, under the site's prefix, images are copied to.
+const assetDir = "/_/asset/"
+
+// printPage is where, under the site, the handout goes.
+const printPage = "_/print"
+
+// assetRef matches the URL of an image served by demo mode,
+// capturing its escaped file path.
+var assetRef = regexp.MustCompile(
+	regexp.QuoteMeta(program.AssetPath+"?"+program.AssetParam+"=") + `([^"'&\s<>]+)`)
+
+// Site says where and how to write an export.
+type Site struct {
+	// Dir is the directory to write to.
+	Dir string
+	// URL is where the site will be served from, e.g.
+	// https://monopole.github.io/mdrip; its path is the prefix
+	// of the site's links.  Empty means the root of some host.
+	URL string
+	// Endpoint is the URL of an mdrip in demo mode, holding the
+	// same tutorial, that runs blocks; empty means clicking
+	// a block only copies it.
+	Endpoint string
+	// Brand makes the pages an organization's own.
+	Brand webapp.Branding
+}
+
+// Write writes the tutorial, loaded from the data set, to the site.
+func Write(s Site, t model.Tutorial, ds *base.DataSet,
+	diagrams diagram.Servers, msgs *webapp.Messages) error {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return fmt.Errorf("bad site URL %q: %v", s.URL, err)
+	}
+	prefix := strings.TrimRight(u.Path, "/")
+	a := &assets{s.Dir, prefix, ds.AsPaths(), map[string]string{}}
+	v := webapp.NewLessonFinder()
+	t.Accept(v)
+	paths := []string{""}
+	for _, p := range webapp.LessonPages(t) {
+		paths = append(paths, p.Path)
+	}
+	page := func(p, file string, print bool) error {
+		wa := webapp.NewWebApp(
+			webapp.NewStaticSessionData(), u.Scheme, u.Host, prefix,
+			t, ds.FirstArg(), v.LessonPath(p), v.CoursePaths(), nil,
+			diagrams, msgs, false, false, nil, true, s.Endpoint)
+		wa.Brand(s.Brand)
+		if print {
+			wa.Print()
+		}
+		var b bytes.Buffer
+		if err := wa.Render(&b); err != nil {
+			return err
+		}
+		h, err := a.copy(b.String())
+		if err != nil {
+			return err
+		}
+		return writeFile(filepath.Join(s.Dir, filepath.FromSlash(file), "index.html"), []byte(h))
+	}
+	for _, p := range paths {
+		if err := page(p, p, false); err != nil {
+			return err
+		}
+	}
+	if err := page("", printPage, true); err != nil {
+		return err
+	}
+	g := program.NewLessonPgmExtractor(base.WildCardLabel)
+	t.Accept(g)
+	var b bytes.Buffer
+	if err := webapp.RenderGlossary(&b, g.FirstTitle(), g.Glossary(), msgs); err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(s.Dir, "_", "glossary", "index.html"), b.Bytes()); err != nil {
+		return err
+	}
+	if err := copyLibraries(s.Dir); err != nil {
+		return err
+	}
+	b.Reset()
+	util.Lissajous(&b, 7, 3, 1)
+	if err := writeFile(filepath.Join(s.Dir, "favicon.ico"), b.Bytes()); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(s.Dir, ".nojekyll"), nil)
+}
+
+// copyLibraries copies the libraries pages load, e.g. KaTeX,
+// into the site, where demo mode serves them.
+func copyLibraries(dir string) error {
+	lib := webapp.Libraries()
+	return fs.WalkDir(lib, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(lib, p)
+		if err != nil {
+			return err
+		}
+		return writeFile(filepath.Join(dir, filepath.FromSlash(
+			strings.TrimPrefix(webapp.LibraryPath, "/")), filepath.FromSlash(p)), data)
+	})
+}
+
+// assets copies the images pages refer to into the site.
+type assets struct {
+	dir    string
+	prefix string
+	roots  []base.FilePath
+	// copied maps the file path of each image copied to its URL.
+	copied map[string]string
+}
+
+// copy copies the images the page refers to, returning
+// the page with its images' URLs pointing at the copies.
+// Images outside the loaded paths, which demo mode
+// wouldn't serve either, are left alone.
+func (a *assets) copy(page string) (string, error) {
+	var err error
+	page = assetRef.ReplaceAllStringFunc(page, func(ref string) string {
+		p, e := url.QueryUnescape(assetRef.FindStringSubmatch(ref)[1])
+		if e != nil || err != nil {
+			return ref
+		}
+		if u, ok := a.copied[p]; ok {
+			return u
+		}
+		rel, ok := a.place(p)
+		if !ok {
+			return ref
+		}
+		b, e := ioutil.ReadFile(p)
+		if e == nil {
+			e = writeFile(filepath.Join(a.dir, filepath.FromSlash(assetDir), filepath.FromSlash(rel)), b)
+		}
+		if e != nil {
+			err = e
+			return ref
+		}
+		u := a.prefix + (&url.URL{Path: assetDir + rel}).EscapedPath()
+		a.copied[p] = u
+		return u
+	})
+	return page, err
+}
+
+// place returns where, under assetDir, the image goes:
+// its path relative to the first loaded path holding it,
+// under that path's index.
+func (a *assets) place(p string) (string, bool) {
+	for i, root := range a.roots {
+		dir := string(root)
+		if fi, err := os.Stat(dir); err == nil && !fi.IsDir() {
+			dir = filepath.Dir(dir)
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err == nil && rel != ".." &&
+			!strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return path.Join(strconv.Itoa(i), filepath.ToSlash(rel)), true
+		}
+	}
+	return "", false
+}
+
+func writeFile(name string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, b, 0644)
+}
@@ -0,0 +1,135 @@
+package export
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/diagram"
+	"github.com/monopole/mdrip/loader"
+	"github.com/monopole/mdrip/webapp"
+)
+
+func write(t *testing.T, name, content string) {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func read(t *testing.T, name string) string {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestWrite(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "export-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	tut := filepath.Join(tmp, "tut")
+	out := filepath.Join(tmp, "site")
+	write(t, filepath.Join(tut, "setup.md"),
+		"# Setup\n\n![diagram](img/d.png)\n\n```\necho one\n```\n")
+	write(t, filepath.Join(tut, "use", "run.md"), "# Run\n\n```\necho two\n```\n")
+	write(t, filepath.Join(tut, "img", "d.png"), "not really an image")
+	ds, err := base.NewDataSet([]string{tut})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tutorial, err := loader.NewLoader(ds).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		endpoint string
+		canRun   bool
+	}{{"", false}, {"http://localhost:8000", true}} {
+		err := Write(Site{out, "https://monopole.github.io/mdrip", test.endpoint,
+			webapp.Branding{Title: "Acme Academy"}},
+			tutorial, ds, diagram.Servers{}, webapp.DefaultMessages())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range []string{
+			"index.html", "setup/index.html", "use/run/index.html",
+			"_/glossary/index.html", "_/print/index.html", "_/assets/README.md",
+			"favicon.ico", ".nojekyll"} {
+			if _, err := os.Stat(filepath.Join(out, filepath.FromSlash(n))); err != nil {
+				t.Errorf("missing %s: %v", n, err)
+			}
+		}
+		if got := read(t, filepath.Join(out, "_", "asset", "0", "img", "d.png")); got != "not really an image" {
+			t.Errorf("asset has %q", got)
+		}
+		page := read(t, filepath.Join(out, "use", "run", "index.html"))
+		for _, want := range []string{
+			`src="/mdrip/_/asset/0/img/d.png"`,
+			`<link rel="canonical" href="https://monopole.github.io/mdrip/use/run">`,
+			`<title id='title'> Acme Academy </title>`,
+		} {
+			if !strings.Contains(page, want) {
+				t.Errorf("page lacks %s", want)
+			}
+		}
+		if h := read(t, filepath.Join(out, "_", "print", "index.html")); !strings.Contains(h, "<h1 class='printTitle'>") {
+			t.Errorf("handout lacks its title")
+		}
+		if strings.Contains(page, "/_/asset?") {
+			t.Errorf("page refers to demo mode's assets")
+		}
+		// Without an endpoint, the page hides its run buttons.
+		if got := !strings.Contains(page, ".sequenceButton, .codeBlockSay {"); got != test.canRun {
+			t.Errorf("endpoint %q: page can run blocks is %v", test.endpoint, got)
+		}
+		if test.canRun && !strings.Contains(page, `'http:\/\/localhost:8000/_/status`) {
+			t.Errorf("page doesn't ask the endpoint for status")
+		}
+	}
+}
+
+func TestWritePDF(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "export-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	tut := filepath.Join(tmp, "tut")
+	write(t, filepath.Join(tut, "setup.md"), "# Setup\n\n```\necho one\n```\n")
+	ds, err := base.NewDataSet([]string{tut})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tutorial, err := loader.NewLoader(ds).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The browser copies the page it's given to the PDF.
+	browser := filepath.Join(tmp, "browser")
+	write(t, browser, "#!/bin/sh\nfor a; do\n  case $a in\n"+
+		"    --print-to-pdf=*) out=${a#--print-to-pdf=} ;;\n"+
+		"    file://*) page=${a#file://} ;;\n  esac\ndone\ncp \"$page\" \"$out\"\n")
+	os.Chmod(browser, 0755)
+	out := filepath.Join(tmp, "course.pdf")
+	if err := WritePDF(out, browser, webapp.Branding{}, tutorial, ds,
+		diagram.Servers{}, webapp.DefaultMessages()); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, out); !strings.Contains(got, "<h1 class='printTitle'>") {
+		t.Errorf("PDF isn't of the handout:\n%s", got)
+	}
+	if err := WritePDF(out, filepath.Join(tmp, "none"), webapp.Branding{}, tutorial, ds,
+		diagram.Servers{}, webapp.DefaultMessages()); err == nil {
+		t.Errorf("expected an error for a missing browser")
+	}
+}
@@ -0,0 +1,84 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/diagram"
+	"github.com/monopole/mdrip/model"
+	"github.com/monopole/mdrip/webapp"
+)
+
+// browsers are those able to print a page to a PDF without
+// a display, in the order they're looked for on the PATH.
+var browsers = []string{
+	"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome", "msedge"}
+
+// findBrowser returns the path of the given browser, or,
+// if that's empty, of the first of browsers on the PATH.
+func findBrowser(browser string) (string, error) {
+	if len(browser) > 0 {
+		return exec.LookPath(browser)
+	}
+	for _, b := range browsers {
+		if p, err := exec.LookPath(b); err == nil {
+			return p, nil
+		}
+	}
+	return "", errors.New("no headless browser found; install one of " +
+		strings.Join(browsers, ", ") + ", or give its path with --browser")
+}
+
+// budgetMs is how long, in the browser's virtual time, the
+// handout has to draw its diagrams and typeset its math.
+const budgetMs = 10000
+
+// WritePDF writes the tutorial, loaded from the data set, to
+// the file out as a PDF of the site's handout, every lesson
+// starting a new page, printed by the given headless browser,
+// or, if that's empty, by the first of browsers found.
+func WritePDF(out, browser string, brand webapp.Branding, t model.Tutorial,
+	ds *base.DataSet, diagrams diagram.Servers, msgs *webapp.Messages) error {
+	b, err := findBrowser(browser)
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(out)
+	if err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir("", "mdrip-pdf-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	// The site's links start with its path, so those
+	// in the handout lead to files in the directory.
+	site := "file://" + filepath.ToSlash(dir)
+	if err := Write(Site{Dir: dir, URL: site, Brand: brand}, t, ds, diagrams, msgs); err != nil {
+		return err
+	}
+	args := []string{
+		"--headless", "--disable-gpu", "--no-pdf-header-footer",
+		fmt.Sprintf("--virtual-time-budget=%d", budgetMs),
+		"--print-to-pdf=" + abs,
+	}
+	if os.Geteuid() == 0 {
+		// Chrome won't run as root, e.g. in a container, sandboxed.
+		args = append(args, "--no-sandbox")
+	}
+	cmd := exec.Command(b, append(args, site+"/"+printPage+"/index.html")...)
+	if o, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %v\n%s", b, err, o)
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return fmt.Errorf("%s wrote no PDF: %v", b, err)
+	}
+	return nil
+}
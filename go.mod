@@ -1,17 +1,24 @@
 module github.com/monopole/mdrip
 
-go 1.13
+go 1.16
 
 require (
+	github.com/coreos/go-oidc v2.2.1+incompatible
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/golang/glog v0.0.0-20160126235308-23def4e6c14b
 	github.com/gorilla/context v0.0.0-20160226214623-1ea25387ff6f // indirect
 	github.com/gorilla/mux v1.6.0
-	github.com/gorilla/securecookie v0.0.0-20160422134519-667fe4e3466a // indirect
+	github.com/gorilla/securecookie v0.0.0-20160422134519-667fe4e3466a
 	github.com/gorilla/sessions v0.0.0-20160922145804-ca9ada445741
 	github.com/gorilla/websocket v1.2.0
 	github.com/pkg/errors v0.8.0
+	github.com/pquerna/cachecontrol v0.2.0 // indirect
 	github.com/shurcooL/sanitized_anchor_name v1.0.0 // indirect
+	golang.org/x/crypto v0.0.0-20201221181555-eec23a3978ad
+	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
 	gopkg.in/russross/blackfriday.v2 v2.0.0
+	gopkg.in/square/go-jose.v2 v2.5.1
+	gopkg.in/yaml.v2 v2.2.8
 )
 
 // v2.0.0 is incompatible with kubectl libraries
@@ -8,6 +8,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/diagram"
 	"github.com/monopole/mdrip/model"
 )
 
@@ -17,16 +18,17 @@ type itemType int
 
 // Things that the lexer emits.
 const (
-	itemError      itemType = iota
-	itemBlockLabel          // Label for a command block
-	itemCodeBlock           // All lines between codeFence marks
-	itemHeader1             // Header1
-	itemHeader2             // Header2
-	itemHeader3             // Header3
-	itemHeader4             // Header4
-	itemHeader5             // Header5
-	itemHeader6             // Header6
-	itemProse               // Anything other than the above.
+	itemError        itemType = iota
+	itemBlockLabel            // Label for a command block
+	itemCodeBlock             // All lines between codeFence marks
+	itemCodeLanguage          // Language specifier following the opening codeFence
+	itemHeader1               // Header1
+	itemHeader2               // Header2
+	itemHeader3               // Header3
+	itemHeader4               // Header4
+	itemHeader5               // Header5
+	itemHeader6               // Header6
+	itemProse                 // Anything other than the above.
 	itemEOF
 )
 
@@ -38,6 +40,8 @@ func textType(t itemType) string {
 		return "LABEL"
 	case itemCodeBlock:
 		return "BLOCK"
+	case itemCodeLanguage:
+		return "LANG"
 	case itemHeader1:
 		return "H1"
 	case itemHeader2:
@@ -218,6 +222,8 @@ const (
 	acceptableProse      = miscChar + string(headerMarker) + lettersAndNumbers
 	acceptableBlockQuote = miscChar + msSpecialChar + lettersAndNumbers
 	acceptableLabel      = underScore + lettersAndNumbers
+	attributeMarker      = '='
+	acceptableValue      = acceptableLabel + "-.:/,%"
 )
 
 func isBlockQuoteStart(remainder string) bool {
@@ -349,8 +355,9 @@ func lexCommentRemainder(l *lexer) stateFn {
 	return lexText
 }
 
-// lexBlockLabels scans a string like "@1 @hey" emitting the labels
-// "1" and "hey".  LabelMarker known to be present.
+// lexBlockLabels scans a string like "@1 @hey @target=a" emitting the
+// labels "1", "hey" and "target=a".  A label with a value is a block
+// attribute.  LabelMarker known to be present.
 func lexBlockLabels(l *lexer) stateFn {
 	for {
 		switch r := l.next(); {
@@ -364,6 +371,9 @@ func lexBlockLabels(l *lexer) stateFn {
 			if l.width == 0 {
 				return l.errorf("empty block label")
 			}
+			if l.accept(string(attributeMarker)) {
+				l.acceptRun(acceptableValue)
+			}
 			l.emit(itemBlockLabel)
 		default:
 			l.backup()
@@ -391,9 +401,13 @@ func lexBlockLabels(l *lexer) stateFn {
 func lexCodeBlock(l *lexer) stateFn {
 	l.current += position(len(codeFence))
 	l.ignore()
-	// Ignore any language specifier.
+	// Emit any language specifier.
 	if idx := strings.Index(l.input[l.current:], "\n"); idx > -1 {
-		l.current += position(idx) + 1
+		l.current += position(idx)
+		if lang := strings.TrimSpace(l.input[l.start:l.current]); len(lang) > 0 {
+			l.items <- lexedItem{itemCodeLanguage, lang}
+		}
+		l.current++
 		l.ignore()
 	}
 	for {
@@ -437,10 +451,85 @@ func headerWeight(x itemType) int {
 	}
 }
 
+// languageOf returns the language named by a code fence's info
+// string, e.g. bash from "bash", "Bash title=x" or "{.bash}".
+func languageOf(info string) string {
+	fields := strings.Fields(strings.Trim(info, "{}"))
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimPrefix(fields[0], "."))
+}
+
+// fenceLabels returns labels given in a code fence's info string
+// after the language, e.g. the info string "text @expected=regex"
+// holds the label expected=regex.
+func fenceLabels(info string) []base.Label {
+	var result []base.Label
+	fields := strings.Fields(strings.Trim(info, "{}"))
+	for i := 1; i < len(fields); i++ {
+		if l := strings.TrimPrefix(fields[i], "@"); len(l) > 0 && len(l) < len(fields[i]) {
+			result = append(result, base.Label(l))
+		}
+	}
+	return result
+}
+
+const frontMatterDelim = "---"
+
+// splitFrontMatter splits YAML front matter, held between lines of
+// three dashes at the very top of the markdown, from the rest.
+// If there's no front matter, the first return is empty.
+func splitFrontMatter(s string) (string, string) {
+	lines := strings.SplitAfter(s, "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != frontMatterDelim {
+		return "", s
+	}
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == frontMatterDelim {
+			return strings.Join(lines[1:i], ""), strings.Join(lines[i+1:], "")
+		}
+	}
+	return "", s
+}
+
+// lineCounter finds the line numbers of lexed items in the input,
+// which holds them, in order, verbatim.
+type lineCounter struct {
+	input  string
+	cursor int
+	// offset is the number of lines before the input, e.g. front matter.
+	offset int
+}
+
+// find returns the line on which val next appears, and moves past it.
+// It returns 0 if val can't be found.
+func (c *lineCounter) find(val string) int {
+	i := strings.Index(c.input[c.cursor:], val)
+	if i < 0 {
+		return 0
+	}
+	i += c.cursor
+	c.cursor = i + len(val)
+	return c.offset + strings.Count(c.input[:i], "\n") + 1
+}
+
 // Parse lexes the incoming string into a list of model.BlockParsed.
+// Front matter that fails to parse is treated as prose, so the
+// author sees it.
 func Parse(s string) *model.MdContent {
 	result := model.NewMdContent()
+	result.SetRaw(s)
+	lines := &lineCounter{s, 0, 0}
+	if front, rest := splitFrontMatter(s); len(front) > 0 {
+		if fm, err := model.ParseFrontMatter(front); err == nil {
+			result.SetFrontMatter(fm)
+			lines = &lineCounter{rest, 0, strings.Count(s[:len(s)-len(rest)], "\n")}
+			s = rest
+		}
+	}
 	prose := ""
+	language := ""
 	labels := []base.Label{}
 	l := newLex(s)
 	for {
@@ -458,16 +547,34 @@ func Parse(s string) *model.MdContent {
 			return result
 		case item.typ == itemBlockLabel:
 			labels = append(labels, base.Label(item.val))
+		case item.typ == itemCodeLanguage:
+			language = item.val
 		case item.typ == itemProse:
+			lines.find(item.val)
 			prose += item.val
 			result.AddProse(item.val)
 		case isHeader(item.typ):
 			prose += "#######"[:headerWeight(item.typ)] + " " + item.val + "\n"
 			result.AddHeader(item.val, headerWeight(item.typ))
+		case item.typ == itemCodeBlock && diagram.IsDiagram(language):
+			// Diagrams are drawn with the prose, not extracted.
+			fence := codeFence + language + "\n" + item.val + codeFence + "\n"
+			prose += fence
+			result.AddProse(fence)
+			labels = []base.Label{}
+			language = ""
 		case item.typ == itemCodeBlock:
-			result.AddBlockParsed(
-				model.NewBlockParsed(labels, base.MdProse(prose), base.OpaqueCode(item.val)))
+			labels = append(labels, fenceLabels(language)...)
+			labels = append(labels, result.FrontMatter().BlockLabels()...)
+			b := model.NewBlockParsed(labels, base.MdProse(prose), base.OpaqueCode(item.val))
+			b.SetLanguage(languageOf(language))
+			if n := lines.find(item.val); n > 1 {
+				// The fence is on the line before the code.
+				b.SetLine(n - 1)
+			}
+			result.AddBlockParsed(b)
 			labels = []base.Label{}
+			language = ""
 			prose = ""
 		}
 	}
@@ -2,7 +2,10 @@ package lexer
 
 import (
 	"fmt"
+	"strings"
 	"testing"
+
+	"github.com/monopole/mdrip/model"
 )
 
 type lexTest struct {
@@ -67,12 +70,24 @@ var lexTests = []lexTest{
 			{itemCodeBlock, block2},
 			{itemProse, "\n ee ff\n"},
 			tEOF}},
+	{"blockWithAttribute",
+		"aa <!-- @1 @target=demo:0.1 @needs=a-b,c -->\n" +
+			"```\n" + block2 + "```\n",
+		[]lexedItem{
+			{itemProse, "aa "},
+			{itemBlockLabel, "1"},
+			{itemBlockLabel, "target=demo:0.1"},
+			{itemBlockLabel, "needs=a-b,c"},
+			{itemCodeBlock, block2},
+			{itemProse, "\n"},
+			tEOF}},
 	{"blockWithLangName",
 		"Hello <!-- @1 -->\n" +
 			"```java\nvoid main whatever\n```",
 		[]lexedItem{
 			{itemProse, "Hello "},
 			{itemBlockLabel, "1"},
+			{itemCodeLanguage, "java"},
 			{itemCodeBlock, "void main whatever\n"},
 			tEOF}},
 	{"blockNoLabel",
@@ -175,3 +190,83 @@ func TestLex(t *testing.T) {
 		}
 	}
 }
+
+func TestParseFrontMatter(t *testing.T) {
+	md := Parse("---\nrequires: [install, setup/configure]\n---\n# Hello\n")
+	got := md.FrontMatter().Requires
+	if len(got) != 2 || got[0] != "install" || got[1] != "setup/configure" {
+		t.Errorf("got requires %v", got)
+	}
+	if !md.HasTitle() || md.GetTitle() != "Hello" {
+		t.Errorf("front matter should not hide the title")
+	}
+	md = Parse("---\nrequires: [unclosed\n---\n# Hello\n")
+	if len(md.FrontMatter().Requires) != 0 ||
+		!strings.Contains(string(md.Blocks[0].Prose()), "requires") {
+		t.Errorf("bad front matter should be left as prose")
+	}
+}
+
+func TestParseFrontMatterMetadata(t *testing.T) {
+	md := Parse("---\ntitle: Install it\nweight: 3\nauthor: Pat\n" +
+		"draft: true\nlabels: [\"@setup\", test]\n---\n" +
+		"<!-- @one -->\n```\necho 1\n```\n")
+	fm := md.FrontMatter()
+	if fm.Title != "Install it" || fm.Weight != 3 || fm.Author != "Pat" || !fm.Draft {
+		t.Errorf("got front matter %+v", fm)
+	}
+	got := md.Blocks[0].Labels()
+	if len(got) != 3 || got[0] != "one" || got[1] != "setup" || got[2] != "test" {
+		t.Errorf("got labels %v", got)
+	}
+}
+
+func TestParseLanguageLabel(t *testing.T) {
+	md := Parse("```Bash title=x\necho 1\n```\n" +
+		"<!-- @install -->\n```{.python}\nprint(1)\n```\n" +
+		"```\ndate\n```\n" +
+		"```text @expected=regex\nhi\n```\n")
+	if len(md.Blocks) != 4 {
+		t.Fatalf("got %d blocks", len(md.Blocks))
+	}
+	for i, want := range []string{"bash", "install python", "", "expected=regex text"} {
+		var got []string
+		for _, l := range md.Blocks[i].Labels() {
+			got = append(got, string(l))
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("block %d: got labels %v, want %s", i, got, want)
+		}
+	}
+	if n := model.NewBlockTut(md.Blocks[0]).Name(); n != model.AnonBlockName {
+		t.Errorf("a language shouldn't name a block, got %s", n)
+	}
+	if md.Blocks[1].Language() != "python" {
+		t.Errorf("got language %q", md.Blocks[1].Language())
+	}
+}
+
+func TestParseDiagram(t *testing.T) {
+	md := Parse("Flow:\n```mermaid\ngraph TD; A-->B;\n```\n" +
+		"Run:\n```\necho hi\n```\n")
+	if len(md.Blocks) != 1 || md.Blocks[0].Code() != "echo hi\n" {
+		t.Fatalf("diagram should not be a code block, got %d blocks", len(md.Blocks))
+	}
+	if !strings.Contains(string(md.Blocks[0].Prose()), "```mermaid\ngraph TD") {
+		t.Errorf("diagram should be in the prose, got %q", md.Blocks[0].Prose())
+	}
+}
+
+func TestParseLines(t *testing.T) {
+	md := Parse("---\nrequires: []\n---\n# Hi\n\n" +
+		"<!-- @a -->\n```\necho one\n```\nprose\n" +
+		"```bash\necho one\n```\n")
+	if len(md.Blocks) != 2 {
+		t.Fatalf("got %d blocks", len(md.Blocks))
+	}
+	for i, want := range []int{7, 11} {
+		if got := md.Blocks[i].Line(); got != want {
+			t.Errorf("block %d: got line %d, want %d", i, got, want)
+		}
+	}
+}
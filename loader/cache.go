@@ -0,0 +1,127 @@
+package loader
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/monopole/mdrip/base"
+	"github.com/pkg/errors"
+)
+
+// The cache holds, below its directory,
+//
+//	git/{key}       a clone of each repository, at a ref
+//	http/{key}.md   a copy of each web file
+//
+// where the key is a digest of the source's URL (and ref).
+// A copy's age is that of its modification time.
+
+// cacheKey names the copy of the remote source with the given URL.
+func cacheKey(u string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(u)))[:16]
+}
+
+// cacheAge returns the age of the copy at the path,
+// and true, or false if there's none.
+func cacheAge(p string) (time.Duration, bool) {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return 0, false
+	}
+	return time.Since(fi.ModTime()), true
+}
+
+// useCopy is true if the copy of the given age should be
+// used without fetching again.
+func useCopy(c base.Cache, age time.Duration, ok bool) bool {
+	return ok && (c.Offline || age < c.TTL)
+}
+
+func notCached(source *base.DataSource, c base.Cache) error {
+	return errors.Errorf(
+		"%s isn't in the cache at %s, and it's offline only", source.Display(), c.Dir)
+}
+
+// cachedClone returns the directory of the cache's clone of the
+// source's repository, cloning it first if there's none, or if
+// it's older than the cache's TTL.  If that fails, it returns
+// the old clone, if any, so a flaky network doesn't stop a
+// tutorial from loading.
+func cachedClone(gitPath string, source *base.DataSource) (string, error) {
+	c := source.Cache()
+	dir := filepath.Join(c.Dir, "git", cacheKey(source.CloneArg()+"#"+source.Ref()))
+	age, ok := cacheAge(dir)
+	if useCopy(c, age, ok) {
+		glog.Infof("Using %s, cloned %s ago, for %s", dir, age.Round(time.Second), source.Display())
+		return dir, nil
+	}
+	if c.Offline {
+		return "", notCached(source, c)
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", errors.Wrap(err, "unable to make cache")
+	}
+	// Clone beside the old clone, then swap them, so that
+	// a failed clone leaves the old one be.
+	tmp, err := ioutil.TempDir(filepath.Dir(dir), "clone-")
+	if err != nil {
+		return "", errors.Wrap(err, "unable to make cache")
+	}
+	if err := clone(gitPath, source, tmp); err != nil {
+		cleanUp(tmp)
+		if ok {
+			glog.Warningf("%v; using the clone made %s ago", err, age.Round(time.Second))
+			return dir, nil
+		}
+		return "", err
+	}
+	os.RemoveAll(dir)
+	if err := os.Rename(tmp, dir); err != nil {
+		cleanUp(tmp)
+		return "", errors.Wrap(err, "unable to cache clone")
+	}
+	now := time.Now()
+	os.Chtimes(dir, now, now)
+	return dir, nil
+}
+
+// cachedFetch returns the cache's copy of the source's web file,
+// fetching it first if there's none, or if it's older than the
+// cache's TTL.  If that fails, it returns the old copy, if any.
+func cachedFetch(source *base.DataSource) ([]byte, error) {
+	c := source.Cache()
+	name := filepath.Join(c.Dir, "http", cacheKey(source.FileURL())+".md")
+	age, ok := cacheAge(name)
+	if useCopy(c, age, ok) {
+		glog.Infof("Using %s, fetched %s ago, for %s", name, age.Round(time.Second), source.FileURL())
+		return ioutil.ReadFile(name)
+	}
+	if c.Offline {
+		return nil, notCached(source, c)
+	}
+	body, err := fetch(source)
+	if err != nil {
+		if ok {
+			glog.Warningf("%v; using the copy fetched %s ago", err, age.Round(time.Second))
+			return ioutil.ReadFile(name)
+		}
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return nil, errors.Wrap(err, "unable to make cache")
+	}
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, body, 0644); err != nil {
+		return nil, errors.Wrap(err, "unable to cache "+source.FileURL())
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		return nil, errors.Wrap(err, "unable to cache "+source.FileURL())
+	}
+	return body, nil
+}
@@ -0,0 +1,153 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+// library maps names to the shared blocks so named.
+type library map[string]*model.BlockTut
+
+// loadLibrary loads the blocks of the dataset
+// named with base.NameAttribute.
+func loadLibrary(ds *base.DataSet) (library, error) {
+	t, err := NewLoader(ds).Load()
+	if err != nil {
+		return nil, fmt.Errorf("loading library %s: %v", ds, err)
+	}
+	v := &libraryCollector{lib: library{}}
+	t.Accept(v)
+	if len(v.problems) > 0 {
+		return nil, fmt.Errorf("bad library %s:\n%s", ds, strings.Join(v.problems, "\n"))
+	}
+	return v.lib, nil
+}
+
+// libraryCollector visits a tutorial, collecting its named blocks.
+type libraryCollector struct {
+	lib      library
+	lesson   base.FilePath
+	problems []string
+}
+
+func (v *libraryCollector) VisitBlockTut(b *model.BlockTut) {
+	n, ok := b.Attribute(base.NameAttribute)
+	if !ok || len(n) == 0 || len(b.Code()) == 0 {
+		return
+	}
+	if _, dup := v.lib[n]; dup {
+		v.problems = append(v.problems,
+			fmt.Sprintf("%s: a block named %q came before", v.lesson, n))
+		return
+	}
+	v.lib[n] = b
+}
+
+func (v *libraryCollector) VisitLessonTut(l *model.LessonTut) {
+	v.lesson = l.Path()
+	for _, x := range l.Children() {
+		x.Accept(v)
+	}
+}
+
+func (v *libraryCollector) VisitCourse(c *model.Course) {
+	for _, x := range c.Children() {
+		x.Accept(v)
+	}
+}
+
+func (v *libraryCollector) VisitTopCourse(t *model.TopCourse) {
+	for _, x := range t.Children() {
+		x.Accept(v)
+	}
+}
+
+// useLibrary gives each of the tutorial's blocks with a
+// base.UseAttribute the code of the library's block so named,
+// and its language, if it has none of its own.  It's an
+// error to use a block not in the library, or, if lib is
+// nil, to use any.
+func useLibrary(t model.Tutorial, lib *base.DataSet) error {
+	v := &libraryUser{}
+	t.Accept(v)
+	if len(v.uses) == 0 {
+		return nil
+	}
+	if lib == nil {
+		return errors.New(
+			"blocks use shared blocks, but there's no library, e.g. --lib, to find them in")
+	}
+	blocks, err := loadLibrary(lib)
+	if err != nil {
+		return err
+	}
+	var problems []string
+	for _, u := range v.uses {
+		n, _ := u.block.Attribute(base.UseAttribute)
+		x, ok := blocks[n]
+		if !ok {
+			problems = append(problems,
+				fmt.Sprintf("%s: no block named %q in library %s", u.lesson, n, lib))
+			continue
+		}
+		u.block.SetCode(x.Code())
+		if len(u.block.Language()) == 0 {
+			u.block.SetLanguage(x.Language())
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// use is a block using a shared block.
+type use struct {
+	lesson base.FilePath
+	block  *model.BlockTut
+}
+
+// libraryUser visits a tutorial, collecting the
+// blocks using shared blocks.
+type libraryUser struct {
+	lesson base.FilePath
+	uses   []use
+}
+
+func (v *libraryUser) VisitBlockTut(b *model.BlockTut) {
+	if _, ok := b.Attribute(base.UseAttribute); ok {
+		v.uses = append(v.uses, use{v.lesson, b})
+	}
+}
+
+func (v *libraryUser) VisitLessonTut(l *model.LessonTut) {
+	v.useIn(l)
+	for _, x := range l.Variants() {
+		if x != l {
+			v.useIn(x)
+		}
+	}
+}
+
+func (v *libraryUser) useIn(l *model.LessonTut) {
+	v.lesson = l.Path()
+	for _, x := range l.Children() {
+		x.Accept(v)
+	}
+}
+
+func (v *libraryUser) VisitCourse(c *model.Course) {
+	for _, x := range c.Children() {
+		x.Accept(v)
+	}
+}
+
+func (v *libraryUser) VisitTopCourse(t *model.TopCourse) {
+	for _, x := range t.Children() {
+		x.Accept(v)
+	}
+}
@@ -0,0 +1,77 @@
+package loader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+const libraryLesson = "# Common\n\n" +
+	"<!-- @name=install-kind -->\n```bash\necho installing kind\n```\n\n" +
+	"<!-- @name=login -->\n```\necho login\n```\n"
+
+func TestUseLibrary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loader-library-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	lib := filepath.Join(dir, "lib")
+	os.Mkdir(lib, 0755)
+	ioutil.WriteFile(filepath.Join(lib, "common.md"), []byte(libraryLesson), 0644)
+	write := func(name, uses string) *base.DataSet {
+		p := filepath.Join(dir, name)
+		ioutil.WriteFile(p, []byte("# "+name+"\n\n<!-- @setup @use="+uses+
+			" -->\n```\n# shared\n```\n\n<!-- @check -->\n```\necho check\n```\n"), 0644)
+		ds, err := base.NewDataSet([]string{p})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ds
+	}
+	libDS, err := base.NewDataSet([]string{lib})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds := write("good.md", "install-kind")
+	ds.SetLibrary(libDS)
+	tut, err := NewLoader(ds).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks := tut.(*model.LessonTut).Blocks()
+	if got := blocks[0].Code(); got != "echo installing kind\n" {
+		t.Errorf("got code %q", got)
+	}
+	if got := blocks[0].Language(); got != "bash" {
+		t.Errorf("got language %q", got)
+	}
+	if got := blocks[1].Code(); got != "echo check\n" {
+		t.Errorf("a block using nothing changed: %q", got)
+	}
+
+	ds = write("typo.md", "install-k8s")
+	ds.SetLibrary(libDS)
+	if _, err := NewLoader(ds).Load(); err == nil ||
+		!strings.Contains(err.Error(), `no block named "install-k8s"`) {
+		t.Errorf("got %v", err)
+	}
+	if _, err := NewLoader(write("nolib.md", "login")).Load(); err == nil ||
+		!strings.Contains(err.Error(), "no library") {
+		t.Errorf("got %v", err)
+	}
+
+	ioutil.WriteFile(filepath.Join(lib, "more.md"), []byte(libraryLesson), 0644)
+	ds = write("dup.md", "login")
+	ds.SetLibrary(libDS)
+	if _, err := NewLoader(ds).Load(); err == nil ||
+		!strings.Contains(err.Error(), `a block named "install-kind" came before`) {
+		t.Errorf("got %v", err)
+	}
+}
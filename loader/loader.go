@@ -3,10 +3,14 @@ package loader
 import (
 	"bytes"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/monopole/mdrip/base"
@@ -17,9 +21,23 @@ import (
 
 const (
 	badLeadingChar = "~.#"
+	// defaultFetchTimeOut applies to web files lacking a time out.
+	defaultFetchTimeOut = 10 * time.Second
 )
 
 func isOrderFile(n base.FilePath) bool {
+	return isRegularFileNamed(n, "README_ORDER.txt")
+}
+
+func isGlossaryFile(n base.FilePath) bool {
+	return isRegularFileNamed(n, "GLOSSARY.txt")
+}
+
+func isRedirectsFile(n base.FilePath) bool {
+	return isRegularFileNamed(n, "REDIRECTS.txt")
+}
+
+func isRegularFileNamed(n base.FilePath, name string) bool {
 	s, err := os.Stat(string(n))
 	if err != nil {
 		return false
@@ -30,7 +48,7 @@ func isOrderFile(n base.FilePath) bool {
 	if !s.Mode().IsRegular() {
 		return false
 	}
-	return filepath.Base(s.Name()) == "README_ORDER.txt"
+	return filepath.Base(s.Name()) == name
 }
 
 func isDesirableFile(n base.FilePath) bool {
@@ -73,26 +91,112 @@ func isDesirableDir(n base.FilePath) bool {
 	return true
 }
 
-func scanDir(d base.FilePath) (model.Tutorial, error) {
+// exclusion holds globs of the paths, relative to its root,
+// or of the names, of files not to load, and, maybe, of the
+// only files to load as lessons.
+type exclusion struct {
+	root    base.FilePath
+	globs   []string
+	include []string
+}
+
+func newExclusion(root base.FilePath, globs, include []string) *exclusion {
+	if len(globs) == 0 && len(include) == 0 {
+		return nil
+	}
+	return &exclusion{root, globs, include}
+}
+
+// matches is true if the path, or its name, matches a glob.
+func (x *exclusion) matches(p base.FilePath, globs []string) bool {
+	rel, err := filepath.Rel(string(x.root), string(p))
+	if err != nil {
+		rel = string(p)
+	}
+	rel = filepath.ToSlash(rel)
+	for _, g := range globs {
+		g = strings.TrimSuffix(g, "/")
+		if matchGlob(g, rel) || matchGlob(g, path.Base(rel)) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludes is true if the path isn't to be loaded.
+func (x *exclusion) excludes(p base.FilePath) bool {
+	return x != nil && x.matches(p, x.globs)
+}
+
+// includes is true if the file may be loaded as a lesson.
+func (x *exclusion) includes(p base.FilePath) bool {
+	return x == nil || len(x.include) == 0 || x.matches(p, x.include)
+}
+
+// matchGlob is like path.Match, but a ** segment of the
+// pattern matches any number of the name's segments, e.g.
+// **/archive/*.md matches archive/old.md and a/archive/old.md,
+// and vendor/** matches vendor and all below it.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], name[0])
+	return ok && matchSegments(pattern[1:], name[1:])
+}
+
+func scanDir(d base.FilePath, skip *exclusion) (model.Tutorial, error) {
 	files, err := d.ReadDir()
 	if err != nil {
 		return BadLoad(d), err
 	}
 	var items = []model.Tutorial{}
 	var ordering = []string{}
+	var glossary = model.Glossary{}
+	var redirects = model.Redirects{}
+	var subRedirects = []*model.Course{}
+	var translations = map[base.FilePath][]base.FilePath{}
 	for _, f := range files {
 		p := d.Join(f)
+		if skip.excludes(p) {
+			continue
+		}
 		if isDesirableFile(p) {
+			if !skip.includes(p) {
+				continue
+			}
+			if orig, lang := model.SplitLang(p); len(lang) > 0 && isDesirableFile(orig) {
+				translations[orig] = append(translations[orig], p)
+				continue
+			}
 			l, err := scanFile(p)
-			if err == nil {
+			if err == nil && !isDraft(l) {
 				items = append(items, l)
 			}
 			continue
 		}
 		if isDesirableDir(p) {
-			c, err := scanDir(p)
+			c, err := scanDir(p, skip)
 			if err == nil {
 				items = append(items, c)
+				if course, ok := c.(*model.Course); ok {
+					subRedirects = append(subRedirects, course)
+				}
 			}
 			continue
 		}
@@ -101,12 +205,53 @@ func scanDir(d base.FilePath) (model.Tutorial, error) {
 			if err == nil {
 				ordering = strings.Split(contents, "\n")
 			}
+			continue
 		}
+		if isGlossaryFile(p) {
+			contents, err := p.Read()
+			if err == nil {
+				glossary = model.ParseGlossary(contents)
+			}
+			continue
+		}
+		if isRedirectsFile(p) {
+			contents, err := p.Read()
+			if err == nil {
+				redirects = model.ParseRedirects(contents)
+			}
+		}
+	}
+	for _, x := range items {
+		if l, ok := x.(*model.LessonTut); ok {
+			addTranslations(l, translations)
+		}
+	}
+	for _, c := range subRedirects {
+		redirects = redirects.Merge(c.Slug(), c.Redirects())
 	}
 	if len(items) == 0 {
 		return nil, errors.New("no content in directory " + string(d))
 	}
-	return model.NewCourse(d, reorder(items, ordering)), nil
+	c := model.NewCourse(d, reorder(items, ordering))
+	c.SetGlossary(glossary)
+	c.SetRedirects(redirects)
+	return c, nil
+}
+
+// addTranslations makes the files translating the lesson, e.g.
+// intro.fr.md translating intro.md, its variants, taking them
+// from translations, so none is added twice.
+func addTranslations(t model.Tutorial, translations map[base.FilePath][]base.FilePath) {
+	l := t.(*model.LessonTut)
+	for _, p := range translations[l.Path()] {
+		x, err := scanFile(p)
+		if err != nil || isDraft(x) {
+			continue
+		}
+		_, lang := model.SplitLang(p)
+		l.AddTranslation(lang, x.(*model.LessonTut))
+	}
+	delete(translations, l.Path())
 }
 
 func scanFile(n base.FilePath) (model.Tutorial, error) {
@@ -143,8 +288,36 @@ func shiftToTop(x []model.Tutorial, top string) []model.Tutorial {
 	return append(result, other...)
 }
 
-// reorder tutorial array in some fashion
+// isDraft is true if the tutorial is a lesson marked as a draft.
+func isDraft(t model.Tutorial) bool {
+	l, ok := t.(*model.LessonTut)
+	return ok && l.IsDraft()
+}
+
+// weight of a lesson, from its front matter; 0 for other tutorials.
+func weight(t model.Tutorial) int {
+	if l, ok := t.(*model.LessonTut); ok {
+		return l.Weight()
+	}
+	return 0
+}
+
+// sortByWeight puts weighted lessons first, lightest first,
+// otherwise leaving the order alone.
+func sortByWeight(x []model.Tutorial) {
+	sort.SliceStable(x, func(i, j int) bool {
+		wi, wj := weight(x[i]), weight(x[j])
+		if wi == 0 || wj == 0 {
+			return wi != 0 && wj == 0
+		}
+		return wi < wj
+	})
+}
+
+// reorder tutorial array in some fashion; README_ORDER
+// trumps weights in front matter.
 func reorder(x []model.Tutorial, ordering []string) []model.Tutorial {
+	sortByWeight(x)
 	for i := len(ordering) - 1; i >= 0; i-- {
 		x = shiftToTop(x, ordering[i])
 	}
@@ -166,19 +339,41 @@ func NewLoader(ds *base.DataSet) *Loader {
 	return &Loader{ds}
 }
 
-// SmellsLikeGithub is true if the DataSet smells like github.
-func (l *Loader) SmellsLikeGithub() bool {
+// IsRemote is true if the DataSet must be fetched from elsewhere,
+// e.g. cloned from a git repository or fetched over http,
+// rather than read locally.
+func (l *Loader) IsRemote() bool {
 	if l.ds.Size() != 1 {
 		return false
 	}
-	return l.ds.FirstArg().IsGithub()
+	return l.ds.FirstArg().IsGitRepo() || l.ds.FirstArg().IsWebFile()
 }
 
-// Load loads the DataSet into a Tutorial.
+// Load loads the DataSet into a Tutorial, giving blocks
+// using shared blocks the code of those in its library,
+// with lessons in the DataSet's language, where they're
+// translated into it.
 func (l *Loader) Load() (model.Tutorial, error) {
+	t, err := l.load()
+	if err != nil {
+		return t, err
+	}
+	if err := useLibrary(t, l.ds.Library()); err != nil {
+		return BadLoad(l.ds.FirstArg().AbsPath()), err
+	}
+	if len(l.ds.Lang()) > 0 {
+		t = model.InLang(t, l.ds.Lang())
+	}
+	return t, nil
+}
+
+func (l *Loader) load() (model.Tutorial, error) {
 	if l.ds.Size() == 1 {
-		if l.ds.FirstArg().IsGithub() {
-			return loadTutorialFromGitHub(l.ds.FirstArg())
+		if l.ds.FirstArg().IsGitRepo() {
+			return loadTutorialFromGit(l.ds.FirstArg())
+		}
+		if l.ds.FirstArg().IsWebFile() {
+			return loadTutorialFromURL(l.ds.FirstArg())
 		}
 		return loadTutorialFromPath(l.ds.FirstArg())
 	}
@@ -188,34 +383,53 @@ func (l *Loader) Load() (model.Tutorial, error) {
 
 func loadTutorialFromPath(source *base.DataSource) (model.Tutorial, error) {
 	if isDesirableFile(source.AbsPath()) {
-		return scanFile(source.AbsPath())
+		t, err := scanFile(source.AbsPath())
+		if err == nil {
+			setRevisions(t, source.AbsPath())
+		}
+		return t, err
 	}
 	if !isDesirableDir(source.AbsPath()) {
 		return nil, errors.New("nothing found at " + string(source.AbsPath()))
 	}
 	glog.Infof("Loading %s from path %s\n", source.Display(), source.AbsPath())
 
-	c, err := scanDir(source.AbsPath())
+	c, err := scanDir(source.AbsPath(), newExclusion(source.AbsPath(), source.Exclude(), source.Include()))
 	if err != nil {
 		return BadLoad(source.AbsPath()), err
 	}
-	return model.NewTopCourse(source.Display(), source.AbsPath(), c.Children()), nil
+	setRevisions(c, source.AbsPath())
+	t := model.NewTopCourse(source.Display(), source.AbsPath(), c.Children())
+	if course, ok := c.(*model.Course); ok {
+		t.SetGlossary(course.Glossary())
+		t.SetRedirects(course.Redirects())
+	}
+	for _, x := range model.SlugCollisions(t) {
+		glog.Warningf("URL collision: %s", x)
+	}
+	return t, nil
 }
 
 func loadTutorialFromPaths(source *base.DataSource, paths []base.FilePath) (model.Tutorial, error) {
 	var items = []model.Tutorial{}
+	var redirects = model.Redirects{}
 	for _, f := range paths {
 		if isDesirableFile(f) {
 			l, err := scanFile(f)
 			if err == nil {
+				setRevisions(l, f)
 				items = append(items, l)
 			}
 			continue
 		}
 		if isDesirableDir(f) {
-			c, err := scanDir(f)
+			c, err := scanDir(f, newExclusion(f, source.Exclude(), source.Include()))
 			if err == nil {
+				setRevisions(c, f)
 				items = append(items, c)
+				if course, ok := c.(*model.Course); ok {
+					redirects = redirects.Merge(course.Slug(), course.Redirects())
+				}
 			}
 			continue
 		}
@@ -223,7 +437,9 @@ func loadTutorialFromPaths(source *base.DataSource, paths []base.FilePath) (mode
 	if len(items) == 0 {
 		return BadLoad(paths[0]), errors.New("nothing useful found in paths")
 	}
-	return model.NewTopCourse(source.Display(), source.AbsPath(), items), nil
+	t := model.NewTopCourse(source.Display(), source.AbsPath(), items)
+	t.SetRedirects(redirects)
+	return t, nil
 }
 
 func cleanUp(tmpDir string) {
@@ -231,32 +447,106 @@ func cleanUp(tmpDir string) {
 	glog.Infof("Deleted " + tmpDir)
 }
 
-func loadTutorialFromGitHub(source *base.DataSource) (model.Tutorial, error) {
+// loadTutorialFromGit makes a partial clone of the source's
+// repository in a temporary directory, loads the tutorial
+// from it, then deletes the clone.  Given a cache, it loads
+// the tutorial from the cache's clone, instead.
+func loadTutorialFromGit(source *base.DataSource) (model.Tutorial, error) {
 	gitPath, err := exec.LookPath("git")
-	if err != nil {
+	if err != nil && !source.Cache().Offline {
 		return BadLoad(base.FilePath(source.Raw())),
 			errors.Wrap(err, "maybe no git on path")
 	}
+	if len(source.Cache().Dir) > 0 {
+		dir, err := cachedClone(gitPath, source)
+		if err != nil {
+			return BadLoad(base.FilePath(source.Raw())), err
+		}
+		return loadTutorialFromClone(source, dir)
+	}
 	tmpDir, err := ioutil.TempDir("", "mdrip-git-")
 	if err != nil {
 		return BadLoad(base.FilePath(source.Raw())),
 			errors.Wrap(err, "unable to create tmp dir")
 	}
-	glog.Infof("Cloning to %s ...\n", tmpDir)
 	defer cleanUp(tmpDir)
-	cmd := exec.Command(gitPath, "clone", source.GithubCloneArg(), tmpDir)
-	var out bytes.Buffer
+	if err := clone(gitPath, source, tmpDir); err != nil {
+		return BadLoad(base.FilePath(source.Raw())), err
+	}
+	return loadTutorialFromClone(source, tmpDir)
+}
+
+// clone makes a partial clone of the source's repository in dir.
+func clone(gitPath string, source *base.DataSource, dir string) error {
+	glog.Infof("Cloning to %s ...\n", dir)
+	// Skipping file contents, rather than history, keeps the clone
+	// small while leaving git log able to date each lesson.
+	args := []string{"clone", "--filter=blob:none"}
+	if len(source.Ref()) > 0 {
+		args = append(args, "--branch", source.Ref())
+	}
+	cmd := exec.Command(gitPath, append(args, source.CloneArg(), dir)...)
+	var out, stdErr bytes.Buffer
 	cmd.Stdout = &out
-	err = cmd.Run()
-	if err != nil {
-		return BadLoad(base.FilePath(source.Raw())),
-			errors.Wrap(err, "git clone failure")
+	cmd.Stderr = &stdErr
+	if err := cmd.Run(); err != nil {
+		return errors.Wrap(err, "git clone failure: "+strings.TrimSpace(stdErr.String()))
 	}
 	glog.Info("Clone complete.")
-	fullPath := tmpDir
+	return nil
+}
+
+func loadTutorialFromClone(source *base.DataSource, dir string) (model.Tutorial, error) {
+	fullPath := dir
 	if len(source.RelPath()) > 0 {
 		fullPath = filepath.Join(fullPath, string(source.RelPath()))
 	}
 	source.SetAbsPath(fullPath)
 	return loadTutorialFromPath(source)
 }
+
+// loadTutorialFromURL fetches a single markdown file
+// over http(s), or from the cache, if it has one, and
+// makes a lesson of it.
+func loadTutorialFromURL(source *base.DataSource) (model.Tutorial, error) {
+	n := base.FilePath(source.FileURL())
+	var body []byte
+	var err error
+	if len(source.Cache().Dir) > 0 {
+		body, err = cachedFetch(source)
+	} else {
+		body, err = fetch(source)
+	}
+	if err != nil {
+		return BadLoad(n), err
+	}
+	md := lexer.Parse(string(body))
+	if len(md.Blocks) < 1 {
+		return BadLoad(n), errors.New("no content in " + source.FileURL())
+	}
+	return model.NewLessonTutFromMdContent(n, md), nil
+}
+
+// fetch gets the source's file over http(s).
+func fetch(source *base.DataSource) ([]byte, error) {
+	timeOut := source.FetchTimeOut()
+	if timeOut <= 0 {
+		timeOut = defaultFetchTimeOut
+	}
+	glog.Infof("Fetching %s ...\n", source.FileURL())
+	client := &http.Client{Timeout: timeOut}
+	resp, err := client.Get(source.FileURL())
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch "+source.FileURL())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf(
+			"fetching %s: got %s", source.FileURL(), resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read "+source.FileURL())
+	}
+	return body, nil
+}
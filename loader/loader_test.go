@@ -7,6 +7,10 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
 
 	"github.com/monopole/mdrip/base"
 	"github.com/monopole/mdrip/model"
@@ -60,3 +64,211 @@ func xTestLoadTutorialFromGitHub(t *testing.T) {
 	printer := model.NewTutorialTxtPrinter(os.Stdout)
 	tut.Accept(printer)
 }
+
+func TestLoadTutorialFromURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/docs/README.md":
+				fmt.Fprint(w, "# Hi\n```\necho hi\n```\n")
+			case "/slow.md":
+				time.Sleep(200 * time.Millisecond)
+				fmt.Fprint(w, "```\necho late\n```\n")
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+	defer srv.Close()
+
+	tests := []struct {
+		path    string
+		wantErr string
+	}{
+		{"/docs/README.md", ""},
+		{"/missing.md", "404"},
+		{"/slow.md", "unable to fetch"},
+	}
+	for _, test := range tests {
+		ds, err := base.NewDataSet([]string{srv.URL + test.path})
+		if err != nil {
+			t.Fatalf("%s: %v", test.path, err)
+		}
+		ds.SetFetchTimeOut(50 * time.Millisecond)
+		l := NewLoader(ds)
+		if !l.IsRemote() {
+			t.Errorf("%s: a URL should be remote", test.path)
+		}
+		tut, err := l.Load()
+		if len(test.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: got error %v, want %q", test.path, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.path, err)
+		}
+		lesson, ok := tut.(*model.LessonTut)
+		if !ok || lesson.Name() != "README" || len(lesson.Children()) != 1 {
+			t.Errorf("%s: unexpected tutorial %v", test.path, tut)
+		}
+	}
+}
+
+func TestScanDirFrontMatter(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "loader-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	files := map[string]string{
+		"a.md": "---\ntitle: Last\n---\n```\necho a\n```\n",
+		"b.md": "---\nweight: 20\n---\n```\necho b\n```\n",
+		"c.md": "---\nweight: 10\n---\n```\necho c\n```\n",
+		"d.md": "---\ndraft: true\n---\n```\necho d\n```\n",
+	}
+	for n, c := range files {
+		if err := ioutil.WriteFile(tmpDir+"/"+n, []byte(c), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tut, err := scanDir(base.FilePath(tmpDir), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, x := range tut.Children() {
+		got = append(got, x.(*model.LessonTut).NavName())
+	}
+	if strings.Join(got, ",") != "c,b,Last" {
+		t.Errorf("got lessons %v", got)
+	}
+}
+
+func TestCachedFetch(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			hits++
+			fmt.Fprint(w, "```\necho cached\n```\n")
+		}))
+	dir, err := ioutil.TempDir("", "loader-cache-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	load := func(c base.Cache) error {
+		ds, err := base.NewDataSet([]string{srv.URL + "/a.md"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ds.SetCache(c)
+		_, err = NewLoader(ds).Load()
+		return err
+	}
+
+	if err := load(base.Cache{Dir: dir, TTL: time.Hour}); err != nil {
+		t.Fatalf("first load: %v", err)
+	}
+	if err := load(base.Cache{Dir: dir, TTL: time.Hour}); err != nil || hits != 1 {
+		t.Errorf("fresh copy: got error %v after %d fetches, want 1", err, hits)
+	}
+	srv.Close()
+	// The copy is stale, but the server's gone, so it's used anyway.
+	if err := load(base.Cache{Dir: dir}); err != nil {
+		t.Errorf("stale copy: %v", err)
+	}
+	if err := load(base.Cache{Dir: dir, Offline: true}); err != nil {
+		t.Errorf("offline: %v", err)
+	}
+	err = load(base.Cache{Dir: t.Name() + "-missing", Offline: true})
+	if err == nil || !strings.Contains(err.Error(), "offline only") {
+		t.Errorf("offline miss: got error %v", err)
+	}
+}
+
+func TestLoadTranslations(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "loader-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	files := map[string]string{
+		"01_intro.md":    "```\necho hello\n```\n",
+		"01_intro.fr.md": "```\necho bonjour\n```\n",
+		"01_intro.ja.md": "```\necho konnichiwa\n```\n",
+		"02_next.md":     "```\necho next\n```\n",
+		"03_alone.de.md": "```\necho allein\n```\n",
+	}
+	for n, c := range files {
+		if err := ioutil.WriteFile(tmpDir+"/"+n, []byte(c), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	code := func(lang string) string {
+		ds, err := base.NewDataSet([]string{tmpDir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ds.SetLang(lang)
+		tut, err := NewLoader(ds).Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, x := range tut.Children() {
+			l := x.(*model.LessonTut)
+			got = append(got, l.Name()+":"+strings.TrimSpace(l.Blocks()[0].Code().String()))
+		}
+		return strings.Join(got, " ")
+	}
+	// A file named for a language, but translating nothing, is a lesson.
+	if got := code(""); got != "01_intro:echo hello 02_next:echo next 03_alone.de:echo allein" {
+		t.Errorf("as written, got %s", got)
+	}
+	if got := code("fr"); got != "01_intro:echo bonjour 02_next:echo next 03_alone.de:echo allein" {
+		t.Errorf("in fr, got %s", got)
+	}
+}
+
+func TestExclusion(t *testing.T) {
+	x := newExclusion("/docs", []string{
+		"drafts/", "*.wip.md", "setup/old.md", "vendor/**", "**/archive/*.md"}, nil)
+	for p, want := range map[base.FilePath]bool{
+		"/docs/drafts":            true,
+		"/docs/a/drafts":          true,
+		"/docs/intro.wip.md":      true,
+		"/docs/setup/old.md":      true,
+		"/docs/vendor":            true,
+		"/docs/vendor/x/y.md":     true,
+		"/docs/archive/v1.md":     true,
+		"/docs/a/b/archive/v1.md": true,
+		"/docs/old.md":            false,
+		"/docs/setup/intro.md":    false,
+		"/docs/drafts-2/new.md":   false,
+		"/docs/a/vendor/x.md":     false,
+		"/docs/archive/v1/x.md":   false,
+	} {
+		if got := x.excludes(p); got != want {
+			t.Errorf("%s: got %v, want %v", p, got, want)
+		}
+	}
+	if newExclusion("/docs", nil, nil).excludes("/docs/drafts") {
+		t.Errorf("excluded without globs")
+	}
+	x = newExclusion("/docs", nil, []string{"guide/**/*.md", "README.md"})
+	for p, want := range map[base.FilePath]bool{
+		"/docs/guide/intro.md":   true,
+		"/docs/guide/a/b/run.md": true,
+		"/docs/a/README.md":      true,
+		"/docs/intro.md":         false,
+		"/docs/other/run.md":     false,
+	} {
+		if got := x.includes(p); got != want {
+			t.Errorf("include %s: got %v, want %v", p, got, want)
+		}
+	}
+	if x.excludes("/docs/other") {
+		t.Errorf("excluded a directory by --include")
+	}
+}
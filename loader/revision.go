@@ -0,0 +1,81 @@
+package loader
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+// revisionSetter sets, on each lesson it visits, the last
+// change to the lesson's file, per git log.
+type revisionSetter struct {
+	gitPath string
+}
+
+// setRevisions sets the revisions of the tutorial's lessons,
+// if git is on the path and the tutorial under dir is in a
+// git work tree; otherwise, the lessons are left without.
+func setRevisions(t model.Tutorial, dir base.FilePath) {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		return
+	}
+	d := string(dir)
+	if fi, err := os.Stat(d); err != nil || !fi.IsDir() {
+		d = filepath.Dir(d)
+	}
+	cmd := exec.Command(gitPath, "rev-parse", "--is-inside-work-tree")
+	cmd.Dir = d
+	if out, err := cmd.Output(); err != nil || strings.TrimSpace(string(out)) != "true" {
+		return
+	}
+	t.Accept(&revisionSetter{gitPath})
+}
+
+func (v *revisionSetter) VisitBlockTut(b *model.BlockTut) {}
+
+func (v *revisionSetter) VisitLessonTut(l *model.LessonTut) {
+	v.setRevision(l)
+	for _, x := range l.Variants() {
+		if x != l {
+			v.setRevision(x)
+		}
+	}
+}
+
+func (v *revisionSetter) setRevision(l *model.LessonTut) {
+	p := string(l.Path())
+	cmd := exec.Command(v.gitPath, "log", "-1", "--format=%aI%x00%an", "--", filepath.Base(p))
+	cmd.Dir = filepath.Dir(p)
+	out, err := cmd.Output()
+	if err != nil {
+		glog.Warningf("no git history for %s: %v", p, err)
+		return
+	}
+	parts := strings.SplitN(strings.TrimSpace(string(out)), "\x00", 2)
+	if len(parts) != 2 {
+		// The file isn't committed.
+		return
+	}
+	when, err := time.Parse(time.RFC3339, parts[0])
+	if err != nil {
+		return
+	}
+	l.SetRevision(model.NewRevision(when, parts[1]))
+}
+
+func (v *revisionSetter) VisitCourse(c *model.Course) {
+	for _, x := range c.Children() {
+		x.Accept(v)
+	}
+}
+
+func (v *revisionSetter) VisitTopCourse(t *model.TopCourse) {
+	v.VisitCourse(&t.Course)
+}
@@ -0,0 +1,67 @@
+package loader
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+func TestSetRevisions(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git")
+	}
+	dir, err := ioutil.TempDir("", "loader-revision-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Tess", "GIT_AUTHOR_EMAIL=tess@example.com",
+			"GIT_AUTHOR_DATE=2024-03-01T09:30:00Z",
+			"GIT_COMMITTER_NAME=Tess", "GIT_COMMITTER_EMAIL=tess@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	lesson := "# Setup\n\n```\ndate\n```\n"
+	ioutil.WriteFile(filepath.Join(dir, "setup.md"), []byte(lesson), 0644)
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-qm", "Start")
+	ioutil.WriteFile(filepath.Join(dir, "draft.md"), []byte(lesson), 0644)
+
+	ds, err := base.NewDataSet([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tut, err := NewLoader(ds).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	revisions := map[string]*model.Revision{}
+	for _, x := range tut.Children() {
+		if l, ok := x.(*model.LessonTut); ok {
+			revisions[l.Name()] = l.Revision()
+		}
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("got lessons %v", revisions)
+	}
+	if r := revisions["draft"]; r != nil {
+		t.Errorf("uncommitted lesson got revision %v", r)
+	}
+	r := revisions["setup"]
+	if r == nil || r.Author != "Tess" ||
+		!r.When.Equal(time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("got revision %+v", r)
+	}
+}
@@ -1,65 +1,731 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/golang/glog"
+	"github.com/monopole/mdrip/audit"
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/bundle"
+	"github.com/monopole/mdrip/compare"
 	"github.com/monopole/mdrip/config"
+	"github.com/monopole/mdrip/doctor"
+	"github.com/monopole/mdrip/export"
 	"github.com/monopole/mdrip/loader"
+	"github.com/monopole/mdrip/manifest"
+	"github.com/monopole/mdrip/model"
+	"github.com/monopole/mdrip/preflight"
 	"github.com/monopole/mdrip/program"
+	"github.com/monopole/mdrip/scaffold"
+	"github.com/monopole/mdrip/schema"
 	"github.com/monopole/mdrip/subshell"
 	"github.com/monopole/mdrip/tmux"
+	"github.com/monopole/mdrip/webapp"
 	"github.com/monopole/mdrip/webserver"
 )
 
 func trueMain(c *config.Config) error {
 	switch c.Mode() {
 	case config.ModeTmux:
-		t := tmux.NewTmux(tmux.Path)
-		if !t.IsUp() {
-			glog.Fatal(tmux.Path, " not running")
+		m := c.Multiplexer()
+		if err := makeTmuxLayout(c); err != nil {
+			return err
+		}
+		if !m.IsUp() {
+			glog.Fatal(m.Name(), " not running")
+		}
+		if pane := c.Targets().Default(); len(pane) > 0 {
+			m = m.WithTarget(pane)
 		}
 		// Treat the first arg as a host address argument.
-		t.Adapt(c.DataSet().FirstArg().Raw())
+		tmux.Adapt(m, c.DataSet().FirstArg().Raw(), c.Pipeline())
+	case config.ModeInit:
+		name := ""
+		if len(c.Args()) > 0 {
+			name = c.Args()[0]
+		}
+		t, err := scaffold.Lookup(name)
+		if err != nil {
+			return err
+		}
+		dir := c.Out()
+		if len(dir) == 0 {
+			dir = "."
+		}
+		if err := t.Write(base.FilePath(dir)); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %q tutorial to %s\n", t.Name(), dir)
+	case config.ModeDoctor:
+		var links *doctor.LinkChecker
+		if c.CheckLinks() {
+			links = doctor.NewLinkChecker(
+				c.LinkAllow(), c.LinkWorkers(), c.FetchTimeOut(), c.Cache())
+		}
+		d := doctor.NewDoctor(c.HostAndPort(), c.DataSet(), c.StaleMonths(), links)
+		if n := doctor.Report(os.Stdout, d.Examine()); n > 0 {
+			fmt.Printf("\n%d problem(s) found.\n", n)
+			os.Exit(1)
+		}
+	case config.ModeBundle:
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		if err := bundle.Write(exe, c.DataSet().FirstArg().AbsPath(), c.Out()); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s; run it with \"%s serve\"\n", c.Out(), c.Out())
+		if !webapp.HasLibraries() {
+			fmt.Fprintln(os.Stderr, "This mdrip was built without KaTeX and mermaid "+
+				"(see go generate ./webapp), so the bundle's pages fetch them from a CDN.")
+		}
+	case config.ModeExport:
+		t, err := loader.NewLoader(c.DataSet()).Load()
+		if err != nil {
+			return err
+		}
+		err = export.Write(export.Site{
+			Dir: c.Out(), URL: c.SiteURL(), Endpoint: c.Endpoint(), Brand: c.Branding()},
+			t, c.DataSet(), c.Diagrams(), c.Messages())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote site to %s\n", c.Out())
+	case config.ModePDF:
+		t, err := loader.NewLoader(c.DataSet()).Load()
+		if err != nil {
+			return err
+		}
+		err = export.WritePDF(c.Out(), c.Browser(), c.Branding(),
+			t, c.DataSet(), c.Diagrams(), c.Messages())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s\n", c.Out())
+	case config.ModeSchema:
+		names := c.Args()
+		if len(names) == 0 {
+			names = schema.Names()
+		}
+		for _, n := range names {
+			d, ok := schema.Document(n)
+			if !ok {
+				return fmt.Errorf("no schema %q; choose from %s", n, strings.Join(schema.Names(), ", "))
+			}
+			fmt.Print(d)
+		}
+	case config.ModeCompare:
+		before, err := compare.Load(c.Args()[0])
+		if err != nil {
+			return err
+		}
+		after, err := compare.Load(c.Args()[1])
+		if err != nil {
+			return err
+		}
+		compare.Compare(before, after, c.Threshold()).WriteMarkdown(os.Stdout)
+	case config.ModeVerifyAudit:
+		f, err := os.Open(c.AuditLog())
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		n, last, err := audit.Verify(f, c.AuditKey())
+		if err != nil {
+			return fmt.Errorf("audit log %s is broken: %v", c.AuditLog(), err)
+		}
+		fmt.Printf("%d records, chain intact, last hash %s\n", n, last)
+	case config.ModeToken:
+		fmt.Println(webserver.MakeToken(c.TokenSecret(), time.Now().Add(c.TTL())))
+	case config.ModeLocate:
+		f, err := os.Open(c.Args()[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		loc, err := program.Locate(f, c.ScriptLine())
+		if err != nil {
+			return err
+		}
+		fmt.Println(loc)
+	case config.ModeCatalog:
+		return writeCatalog(c)
+	case config.ModeJSON:
+		t, err := loader.NewLoader(c.DataSet()).Load()
+		if err != nil {
+			return err
+		}
+		return schema.Write(os.Stdout, schema.NewTree(t))
+	case config.ModeScript:
+		t, err := loader.NewLoader(c.DataSet()).Load()
+		if err != nil {
+			return err
+		}
+		p := extractProgram(c, t)
+		if len(c.Out()) > 0 {
+			return writeScript(c, p)
+		}
+		p.PrintScript(os.Stdout, scriptOptions(c))
+	case config.ModeExplain:
+		t, err := loader.NewLoader(c.DataSet()).Load()
+		if err != nil {
+			return err
+		}
+		x, err := program.Explain(t, c.Block(), c.Label(), c.Arch())
+		if err != nil {
+			return err
+		}
+		program.PrintExplanations(os.Stdout, x)
 	case config.ModeDemo:
+		if err := makeTmuxLayout(c); err != nil {
+			return err
+		}
+		t := webserver.TLS{CertFile: c.Cert(), KeyFile: c.Key(), ACMEHost: c.ACMEHost()}
+		a, err := makeAuth(c)
+		if err != nil {
+			return err
+		}
+		var proposer webserver.Proposer
+		if c.Edit() == config.EditGit {
+			proposer = webserver.NewGitProposer(c.EditRemote())
+		}
+		m := c.Multiplexer()
+		if c.Paste() == config.PasteClipboard {
+			m = nil
+		}
+		if c.DataSet().Size() > 1 {
+			h, err := webserver.NewHub(c.DataSet().Split())
+			if err != nil {
+				return err
+			}
+			h.Transform(c.Pipeline())
+			h.PasteInto(m, c.Targets())
+			h.DrawDiagrams(c.Diagrams())
+			h.Translate(c.Messages())
+			h.RequireTokens(c.TokenSecret())
+			if c.Watch() {
+				h.Watch()
+			}
+			if proposer != nil {
+				h.TakeEdits(proposer)
+			}
+			h.AllowOrigins(c.AllowOrigins())
+			h.Brand(c.Branding())
+			if len(c.ProgressFile()) > 0 {
+				if err := h.KeepProgress(c.ProgressFile()); err != nil {
+					return err
+				}
+			}
+			if len(c.ClassCode()) > 0 {
+				h.OpenClassroom(c.ClassCode(), instructorKey(c, "/{tutorial}"))
+			}
+			return h.Serve(c.HostAndPort(), t, a)
+		}
 		l := loader.NewLoader(c.DataSet())
 		s, err := webserver.NewServer(l)
 		if err != nil {
 			return err
 		}
-		err = s.Serve(c.HostAndPort())
+		s.Transform(c.Pipeline())
+		s.PasteInto(m, c.Targets())
+		s.DrawDiagrams(c.Diagrams())
+		s.Translate(c.Messages())
+		s.RequireTokens(c.TokenSecret())
+		if c.Watch() {
+			s.Watch()
+		}
+		if proposer != nil {
+			s.TakeEdits(proposer)
+		}
+		s.AllowOrigins(c.AllowOrigins())
+		s.Brand(c.Branding())
+		if len(c.ProgressFile()) > 0 {
+			if err := s.KeepProgress(c.ProgressFile()); err != nil {
+				return err
+			}
+		}
+		if len(c.ClassCode()) > 0 {
+			s.OpenClassroom(c.ClassCode(), instructorKey(c, ""))
+		}
+		err = s.Serve(c.HostAndPort(), t, a)
 		if err != nil {
 			return err
 		}
 	case config.ModeTest:
+		if len(c.Manifest()) > 0 {
+			return runManifest(c)
+		}
 		t, err := loader.NewLoader(c.DataSet()).Load()
 		if err != nil {
 			return err
 		}
-		p := program.NewProgramFromTutorial(c.Label(), t)
-		s := subshell.NewSubshell(c.BlockTimeOut(), p)
-		if r := s.Run(); r.Error() != nil {
-			r.Print(c.Label())
-			if !c.IgnoreTestFailure() {
-				glog.Fatal(r.Error())
-			}
+		return runProgram(c, extractProgram(c, t))
+	case config.ModeMinimize:
+		t, err := loader.NewLoader(c.DataSet()).Load()
+		if err != nil {
+			return err
+		}
+		return runProgram(c,
+			program.NewProgramFromTutorialForArch(c.Label(), c.Arch(), t))
+	case config.ModeRun:
+		t, err := loader.NewLoader(c.DataSet()).Load()
+		if err != nil {
+			return err
+		}
+		p, err := program.NewProgramFromTutorialAtPath(
+			c.Label(), c.Arch(), c.Block(), t)
+		if err != nil {
+			return err
 		}
+		return runProgram(c, p)
 	default:
 		t, err := loader.NewLoader(c.DataSet()).Load()
 		if err != nil {
 			return err
 		}
-		p := program.NewProgramFromTutorial(c.Label(), t)
-		if c.Preambled() > 0 {
-			p.PrintPreambled(os.Stdout, c.Preambled())
-		} else {
-			p.PrintNormal(os.Stdout)
+		p := extractProgram(c, t)
+		if c.Format() == config.FormatJSON {
+			return schema.Write(os.Stdout, schema.NewProgram(p))
+		}
+		if len(c.Out()) > 0 {
+			return writeScript(c, p)
+		}
+		if len(c.Shebang()) > 0 || c.Strict() {
+			p.PrintHeader(os.Stdout, scriptOptions(c))
+		}
+		printProgram(c, p, os.Stdout)
+	}
+	return nil
+}
+
+// instructorKey returns the key to the classroom's dashboard,
+// making one up if none is given, and says, on stderr, where
+// students join, and where the dashboard is, under the prefix,
+// showing the key only if it was made up.
+func instructorKey(c *config.Config, prefix string) string {
+	key, shown := c.InstructorKey(), "$"+config.InstructorKeyEnv
+	if len(key) == 0 {
+		key = webserver.NewInstructorKey()
+		shown = key
+	}
+	fmt.Fprintf(os.Stderr, "Students join at %s/join with code %s\n", prefix, c.ClassCode())
+	fmt.Fprintf(os.Stderr, "The instructor's dashboard is %s/classroom?%s=%s\n",
+		prefix, webapp.KeyInstructorKey, shown)
+	return key
+}
+
+// makeAuth returns the Auth demo mode's flags ask for, if any.
+func makeAuth(c *config.Config) (webserver.Auth, error) {
+	switch {
+	case len(c.BasicAuth()) > 0:
+		return webserver.NewBasicAuth(c.BasicAuth())
+	case len(c.OIDCIssuer()) > 0:
+		return webserver.NewOIDCAuth(
+			c.OIDCIssuer(), c.OIDCClientID(), c.OIDCClientSecret(), c.OIDCAllow())
+	}
+	return nil, nil
+}
+
+// extractProgram extracts, from the tutorial, the program
+// of the blocks having the label, or, if there are phases,
+// of those having each phase's label, in turn.
+func extractProgram(c *config.Config, t model.Tutorial) *program.Program {
+	if p := c.Phases(); len(p) > 0 {
+		return program.NewProgramFromPhases(p, c.Arch(), t)
+	}
+	return program.NewProgramFromTutorialForArch(c.Label(), c.Arch(), t)
+}
+
+func scriptOptions(c *config.Config) program.ScriptOptions {
+	return program.ScriptOptions{
+		Shebang: c.Shebang(), Strict: c.Strict(), Source: c.DataSet().String()}
+}
+
+func printProgram(c *config.Config, p *program.Program, w io.Writer) {
+	if c.Preambled() > 0 {
+		p.PrintPreambled(w, c.Preambled())
+	} else {
+		p.PrintNormal(w)
+	}
+}
+
+// writeScript writes the program to the --out file, with a header
+// saying where it came from.
+func writeScript(c *config.Config, p *program.Program) error {
+	f, err := os.Create(c.Out())
+	if err != nil {
+		return err
+	}
+	if c.Mode() == config.ModeScript || c.Mode() == config.ModeMinimize {
+		p.PrintScript(f, scriptOptions(c))
+	} else {
+		p.PrintHeader(f, scriptOptions(c))
+		printProgram(c, p, f)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if c.Executable() {
+		return os.Chmod(c.Out(), 0755)
+	}
+	return nil
+}
+
+// prepareProgram returns the program to run: rewritten by the
+// --transform pipeline, its blocks given their labels'
+// --labelDefaults, checked for bad @needs, and cut down by
+// --only and --startAt.
+func prepareProgram(c *config.Config, p *program.Program) (*program.Program, error) {
+	if c.MissingVars() != config.MissingVarsKeep {
+		if err := fillVars(c, p); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.Pipeline()) > 0 {
+		p = p.Rewrite(c.Pipeline().Apply)
+	}
+	if len(c.LabelDefaults()) > 0 {
+		d, err := program.LoadLabelDefaults(c.LabelDefaults())
+		if err != nil {
+			return nil, err
+		}
+		p = p.WithDefaults(d)
+	}
+	if x := p.CheckNeeds(); len(x) > 0 {
+		return nil, fmt.Errorf("bad @name or @needs attributes:\n%s", strings.Join(x, "\n"))
+	}
+	if x := p.CheckAttributes(); len(x) > 0 {
+		return nil, fmt.Errorf("bad block attributes:\n%s", strings.Join(x, "\n"))
+	}
+	if len(c.Only()) > 0 {
+		var err error
+		if p, err = p.Only(c.Only()); err != nil {
+			return nil, err
+		}
+	}
+	if file, block := c.StartAt(); len(file) > 0 {
+		var err error
+		if p, err = p.StartAt(file, block); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// fillVars deals with the variables the program's blocks use
+// that have no value: failing, listing them, or asking for their
+// values, setting them in mdrip's environment, where the vars
+// transform finds them, and giving them to the runner to export,
+// since with --envClear the blocks' shell doesn't get mdrip's.
+func fillVars(c *config.Config, p *program.Program) error {
+	missing := p.MissingVars(c.VarLookup())
+	if len(missing) == 0 {
+		return nil
+	}
+	if c.MissingVars() == config.MissingVarsFail {
+		var lines []string
+		for _, v := range missing {
+			lines = append(lines, "  "+v.String())
+		}
+		return fmt.Errorf("blocks use variables with no value; set them with --env or --envFile:\n%s",
+			strings.Join(lines, "\n"))
+	}
+	values, err := program.PromptVars(os.Stdin, os.Stderr, missing)
+	if err != nil {
+		return err
+	}
+	env := make([]string, 0, len(values))
+	for k, v := range values {
+		os.Setenv(k, v)
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return c.AddEnv(env)
+}
+
+// runProgram runs the program's blocks, as test mode does, after
+// prepareProgram readies them, exiting with an error if one fails.
+func runProgram(c *config.Config, p *program.Program) error {
+	p, err := prepareProgram(c, p)
+	if err != nil {
+		return err
+	}
+	if c.DryRun() {
+		p.PrintDryRun(os.Stdout)
+		return nil
+	}
+	if x := p.SudoBlocks(); len(x) > 0 && !c.AllowSudo() {
+		return fmt.Errorf("refusing to run blocks as root without --allowSudo:\n%s",
+			strings.Join(x, "\n"))
+	}
+	if len(c.Preflight()) > 0 {
+		if err := runPreflight(c); err != nil {
+			return err
+		}
+	}
+	if c.Mode() == config.ModeMinimize {
+		return minimize(c, p)
+	}
+	r := c.Runner().Run(p)
+	if err := writeAudit(c, c.DataSet().String(), r); err != nil {
+		return err
+	}
+	if len(c.JUnit()) > 0 {
+		if err := writeJUnit(c.JUnit(), r); err != nil {
+			return err
+		}
+	}
+	if c.Format() == config.FormatJSON {
+		if err := schema.Write(os.Stdout, schema.NewResults(r)); err != nil {
+			return err
+		}
+	}
+	if r.Error() != nil {
+		r.Print(c.Label())
+	}
+	if c.CaptureState() && c.Format() != config.FormatJSON {
+		r.PrintStateChanges(os.Stderr)
+	}
+	if c.KeepGoing() {
+		r.PrintSummary(os.Stderr)
+	}
+	if r.Error() != nil {
+		if !c.IgnoreTestFailure() {
+			glog.Fatal(r.Error())
+		}
+	}
+	return nil
+}
+
+// makeTmuxLayout, given --tmuxLayout, starts a tmux session with
+// an editor pane and a pane that blocks naming no target go to.
+func makeTmuxLayout(c *config.Config) error {
+	if !c.TmuxLayout() {
+		return nil
+	}
+	pane, err := tmux.NewTmux(tmux.Path).MakeLayout()
+	if err != nil {
+		return err
+	}
+	c.Targets().SetDefault(pane)
+	fmt.Fprintf(os.Stderr, "Blocks go to tmux pane %s; attach with: tmux attach -t %s\n",
+		pane, tmux.SessionName)
+	return nil
+}
+
+// runManifest runs, as test mode does, the tutorials listed in the
+// --manifest file that are due on the --schedule, one after the
+// other, then reports on each, exiting with an error if one failed.
+func runManifest(c *config.Config) error {
+	entries, err := manifest.Load(c.Manifest())
+	if err != nil {
+		return err
+	}
+	entries = manifest.Due(entries, c.Schedule())
+	if len(entries) == 0 {
+		return fmt.Errorf("no tutorials in %s have schedule %q", c.Manifest(), c.Schedule())
+	}
+	if len(c.Preflight()) > 0 && !c.DryRun() {
+		if err := runPreflight(c); err != nil {
+			return err
+		}
+	}
+	var results []manifest.Result
+	for _, e := range entries {
+		fmt.Fprintf(os.Stderr, "Verifying %s (%s)\n", e.Name, e.Source)
+		r, err := verify(c, e)
+		if err != nil {
+			r.Problem = err.Error()
+		}
+		results = append(results, r)
+	}
+	if c.DryRun() {
+		return nil
+	}
+	manifest.WriteReport(os.Stdout, results)
+	var failed []string
+	for _, r := range results {
+		if !r.OK() {
+			failed = append(failed, r.Entry.Name)
+		}
+	}
+	if len(failed) > 0 && !c.IgnoreTestFailure() {
+		glog.Fatalf("tutorials failed: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// verify runs the blocks of the manifest entry's tutorial,
+// counting what became of them.
+func verify(c *config.Config, e manifest.Entry) (manifest.Result, error) {
+	result := manifest.Result{Entry: e}
+	ds, err := base.NewDataSet([]string{e.Source})
+	if err != nil {
+		return result, err
+	}
+	ds.SetRef(e.Ref)
+	ds.SetFetchTimeOut(c.FetchTimeOut())
+	ds.SetCache(c.Cache())
+	ds.SetLang(c.Lang())
+	ds.SetExclude(c.Exclude())
+	ds.SetInclude(c.Include())
+	ds.SetLibrary(c.Library())
+	t, err := loader.NewLoader(ds).Load()
+	if err != nil {
+		return result, err
+	}
+	label := c.Label()
+	if len(e.Labels) > 0 {
+		label = e.Label()
+	}
+	p, err := prepareProgram(c,
+		program.NewProgramFromTutorialForArch(label, c.Arch(), t))
+	if err != nil {
+		return result, err
+	}
+	if c.DryRun() {
+		p.PrintDryRun(os.Stdout)
+		return result, nil
+	}
+	if x := p.SudoBlocks(); len(x) > 0 && !c.AllowSudo() {
+		return result, fmt.Errorf("refusing to run %d blocks as root without --allowSudo", len(x))
+	}
+	r := c.Runner().Run(p)
+	if err := writeAudit(c, e.Source, r); err != nil {
+		return result, err
+	}
+	for _, b := range r.Reports() {
+		switch {
+		case b.Failed():
+			result.Failed++
+		case b.Skipped():
+			result.Skipped++
+		default:
+			result.Passed++
+		}
+	}
+	if r.Error() != nil {
+		r.Print(label)
+		if b := r.Block(); b != nil {
+			return result, fmt.Errorf("block %d of %s", b.Index(), r.FileName())
 		}
+		return result, r.Error()
+	}
+	return result, nil
+}
+
+// minimize runs the program, and if a block fails, writes a script
+// of the fewest blocks before it that still make it fail, and it.
+func minimize(c *config.Config, p *program.Program) error {
+	r := c.Runner().Run(p)
+	if r.Error() == nil {
+		return errors.New("no block failed, so there's nothing to minimize")
+	}
+	failed := r.Block()
+	fmt.Fprintf(os.Stderr, "Block %d of %s failed; minimizing the blocks before it.\n",
+		failed.Index(), r.FileName())
+	m, tries, err := p.Minimize(failed, func(q *program.Program) bool {
+		x := c.Runner().Run(q)
+		return x.Error() != nil && x.Block() == failed
+	})
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, l := range m.Lessons() {
+		n += len(l.Blocks())
 	}
+	fmt.Fprintf(os.Stderr, "After %d runs, %d blocks reproduce the failure.\n", tries+1, n)
+	if len(c.Out()) > 0 {
+		return writeScript(c, m)
+	}
+	m.PrintScript(os.Stdout, scriptOptions(c))
 	return nil
 }
 
+// runPreflight runs the probes declared in the --preflight file
+// where the blocks would run, exiting with preflight.ExitCode
+// if one fails.
+func runPreflight(c *config.Config) error {
+	probes, err := preflight.Load(c.Preflight())
+	if err != nil {
+		return err
+	}
+	r := c.Runner().Run(preflight.NewProgram(c.Preflight(), probes))
+	if r.Error() == nil {
+		return nil
+	}
+	r.Print(base.WildCardLabel)
+	fmt.Fprintf(os.Stderr,
+		"Preflight probe failed: %v\nThe environment, not the tutorial, is broken; ran no block.\n",
+		r.Error())
+	os.Exit(preflight.ExitCode)
+	return nil
+}
+
+// writeCatalog writes an HTML catalog of the tutorials in the
+// data set, each linking to where it came from.
+func writeCatalog(c *config.Config) error {
+	var entries []webapp.CatalogEntry
+	for _, ds := range c.DataSet().Split() {
+		t, err := loader.NewLoader(ds).Load()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, webapp.NewCatalogEntry(
+			ds.FirstArg().Href(), ds.FirstArg().Display(), t, time.Now()))
+	}
+	if len(c.Out()) == 0 {
+		return webapp.RenderCatalog(os.Stdout, entries, c.Messages())
+	}
+	f, err := os.Create(c.Out())
+	if err != nil {
+		return err
+	}
+	if err := webapp.RenderCatalog(f, entries, c.Messages()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeAudit, given --auditLog, appends a record
+// of each block of the run to the audit log.
+func writeAudit(c *config.Config, source string, r *subshell.RunResult) error {
+	if len(c.AuditLog()) == 0 {
+		return nil
+	}
+	l, err := audit.Open(c.AuditLog(), c.AuditKey())
+	if err != nil {
+		return err
+	}
+	user, host := audit.Who()
+	if err := l.Append(schema.NewAuditRecords(user, host, source, r)); err != nil {
+		l.Close()
+		return err
+	}
+	return l.Close()
+}
+
+func writeJUnit(n string, r *subshell.RunResult) error {
+	f, err := os.Create(n)
+	if err != nil {
+		return err
+	}
+	if err := subshell.WriteJUnit(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 func main() {
 	c, err := config.GetConfig()
 	if err != nil {
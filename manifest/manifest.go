@@ -0,0 +1,126 @@
+// Package manifest lists tutorials, from any number of repositories
+// and paths, for one --mode test run to verify, so that one CI job
+// can check a whole organization's tutorials, and reports on each.
+//
+// A manifest is a YAML file holding a list of Entries.
+package manifest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"text/tabwriter"
+
+	"github.com/monopole/mdrip/base"
+	"gopkg.in/yaml.v2"
+)
+
+// Entry is a tutorial to verify.
+type Entry struct {
+	// Name identifies the entry in reports, e.g. k8s.
+	Name string `yaml:"name"`
+	// Source is where the tutorial is, as given to mdrip on the
+	// command line: a path, a file's URL, or a git repository,
+	// e.g. gh:org/docs/tutorials.
+	Source string `yaml:"source"`
+	// Ref, if not empty, is the branch, tag or commit
+	// of a git repository to verify, as --ref says.
+	Ref string `yaml:"ref"`
+	// Labels select the blocks to run, as --label does;
+	// if empty, the run's --label selects them.
+	Labels []string `yaml:"labels"`
+	// Schedule, if not empty, is when to verify the entry, e.g.
+	// nightly or weekly: given --schedule, only entries
+	// with that schedule are verified.
+	Schedule string `yaml:"schedule"`
+}
+
+// Label selects the entry's blocks; the WildCardLabel
+// if the entry has no labels.
+func (e Entry) Label() base.Label {
+	labels := make([]base.Label, len(e.Labels))
+	for i, l := range e.Labels {
+		labels[i] = base.Label(l)
+	}
+	return base.AllOf(labels)
+}
+
+// Load reads the entries listed in the YAML file.
+func Load(path string) ([]Entry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	if err := yaml.UnmarshalStrict(data, &entries); err != nil {
+		return nil, fmt.Errorf("bad manifest %s: %v", path, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no entries in manifest %s", path)
+	}
+	names := map[string]bool{}
+	for i, e := range entries {
+		if len(e.Name) == 0 || len(e.Source) == 0 {
+			return nil, fmt.Errorf("entry %d in %s needs a name and a source", i+1, path)
+		}
+		if names[e.Name] {
+			return nil, fmt.Errorf("entry %s in %s is listed twice", e.Name, path)
+		}
+		names[e.Name] = true
+		if err := e.Label().CheckSelector(); err != nil {
+			return nil, fmt.Errorf("entry %s in %s: %v", e.Name, path, err)
+		}
+	}
+	return entries, nil
+}
+
+// Due returns the entries with the given schedule,
+// or all of them if the schedule is empty.
+func Due(entries []Entry, schedule string) []Entry {
+	if len(schedule) == 0 {
+		return entries
+	}
+	var result []Entry
+	for _, e := range entries {
+		if e.Schedule == schedule {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Result is what became of an entry's blocks.
+type Result struct {
+	Entry                   Entry
+	Passed, Failed, Skipped int
+	// Problem, if not empty, says why the entry failed: where its
+	// failing block is, or why its blocks couldn't be run at all.
+	Problem string
+}
+
+// OK is true if the entry's blocks all ran, and passed.
+func (r Result) OK() bool {
+	return len(r.Problem) == 0 && r.Failed == 0
+}
+
+// WriteReport writes a table of the results, one row per entry,
+// and a count of the entries that failed.
+func WriteReport(w io.Writer, results []Result) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSOURCE\tPASSED\tFAILED\tSKIPPED\tSTATUS")
+	failed := 0
+	for _, r := range results {
+		status := "ok"
+		if !r.OK() {
+			failed++
+			status = "FAILED"
+			if len(r.Problem) > 0 {
+				status += ": " + r.Problem
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\n", r.Entry.Name, r.Entry.Source,
+			r.Passed, r.Failed, r.Skipped, status)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d of %d tutorials failed\n", failed, len(results))
+}
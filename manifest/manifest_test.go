@@ -0,0 +1,87 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for n, test := range map[string]struct {
+		content string
+		err     string
+	}{
+		"fine": {"- name: k8s\n  source: gh:org/k8s-docs\n  ref: v1.2\n  labels: [test, '!slow']\n  schedule: nightly\n" +
+			"- name: local\n  source: ./docs\n", ""},
+		"empty":      {"", "no entries"},
+		"nameless":   {"- source: ./docs\n", "needs a name"},
+		"sourceless": {"- name: docs\n", "needs a name and a source"},
+		"twice":      {"- name: docs\n  source: a\n- name: docs\n  source: b\n", "listed twice"},
+		"badLabel":   {"- name: docs\n  source: a\n  labels: ['a &&']\n", "entry docs"},
+		"typo":       {"- name: docs\n  sorce: a\n", "bad manifest"},
+	} {
+		p := filepath.Join(dir, n+".yaml")
+		if err := ioutil.WriteFile(p, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := Load(p)
+		if len(test.err) == 0 {
+			if err != nil {
+				t.Errorf("%s: %v", n, err)
+			} else if got := string(entries[0].Label()); got != "test && (!slow)" {
+				t.Errorf("%s: got label %q", n, got)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, want %q", n, err, test.err)
+		}
+	}
+}
+
+func TestDue(t *testing.T) {
+	entries := []Entry{
+		{Name: "a", Schedule: "nightly"},
+		{Name: "b", Schedule: "weekly"},
+		{Name: "c"},
+	}
+	for schedule, want := range map[string]string{
+		"":        "a b c",
+		"nightly": "a",
+		"monthly": "",
+	} {
+		var got []string
+		for _, e := range Due(entries, schedule) {
+			got = append(got, e.Name)
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("%q: got %v, want %s", schedule, got, want)
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	var b strings.Builder
+	WriteReport(&b, []Result{
+		{Entry{Name: "k8s", Source: "gh:org/k8s"}, 3, 0, 0, ""},
+		{Entry{Name: "istio", Source: "./istio"}, 1, 1, 2, "block 2 of istio/install.md"},
+		{Entry{Name: "gone", Source: "./gone"}, 0, 0, 0, "no such file"},
+	})
+	for _, want := range []string{
+		"k8s    gh:org/k8s  3       0       0        ok\n",
+		"FAILED: block 2 of istio/install.md\n",
+		"FAILED: no such file\n",
+		"2 of 3 tutorials failed\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("got\n%s\nwant it to hold %q", b.String(), want)
+		}
+	}
+}
@@ -6,6 +6,10 @@ import "github.com/monopole/mdrip/base"
 type BlockParsed struct {
 	base.BlockBase
 	labels []base.Label
+	// line is where the block's code starts in its file; 0 if unknown.
+	line int
+	// language of the code, from its fence's info string, e.g. bash.
+	language string
 }
 
 // NewProseOnlyBlock makes a BlockParsed with no code.
@@ -15,7 +19,25 @@ func NewProseOnlyBlock(p base.MdProse) *BlockParsed {
 
 // NewBlockParsed returns a BlockParsed with the given content.
 func NewBlockParsed(labels []base.Label, p base.MdProse, c base.OpaqueCode) *BlockParsed {
-	return &BlockParsed{base.NewBlockBase(p, c), labels}
+	return &BlockParsed{base.NewBlockBase(p, c), labels, 0, ""}
+}
+
+// Line is the line number of the block's opening code fence, or 0 if unknown.
+func (x *BlockParsed) Line() int { return x.line }
+
+// SetLine sets the line number of the block's opening code fence.
+func (x *BlockParsed) SetLine(n int) { x.line = n }
+
+// Language of the code, e.g. bash, or empty if the fence didn't say.
+func (x *BlockParsed) Language() string { return x.language }
+
+// SetLanguage sets the language of the code, adding it to the
+// block's labels, so that e.g. --label bash selects bash blocks.
+func (x *BlockParsed) SetLanguage(lang string) {
+	x.language = lang
+	if len(lang) > 0 && !x.HasLabel(base.Label(lang)) {
+		x.labels = append(x.labels, base.Label(lang))
+	}
 }
 
 // Labels are the labels found on the block.
@@ -30,3 +52,15 @@ func (x *BlockParsed) HasLabel(label base.Label) bool {
 	}
 	return false
 }
+
+// Attribute returns the value of the block's attribute with the
+// given key, e.g. the attribute @target=demo:0.1 has key "target".
+func (x *BlockParsed) Attribute(key string) (string, bool) {
+	return base.FindAttribute(x.labels, key)
+}
+
+// Tags are the block's own tags, from its @tags attribute.
+func (x *BlockParsed) Tags() []string {
+	list, _ := x.Attribute(base.TagsAttribute)
+	return base.ParseTags(list)
+}
@@ -19,19 +19,19 @@ var bb = base.NewBlockBase(
 
 var bpTests = []bpTest{
 	{"empty",
-		BlockParsed{bb, []base.Label{}},
+		BlockParsed{bb, []base.Label{}, 0, ""},
 		base.WildCardLabel,
 		false},
 	{"test1",
-		BlockParsed{bb, []base.Label{base.WildCardLabel, base.SleepLabel}},
+		BlockParsed{bb, []base.Label{base.WildCardLabel, base.SleepLabel}, 0, ""},
 		base.WildCardLabel,
 		true},
 	{"test2",
-		BlockParsed{bb, []base.Label{base.SleepLabel, base.WildCardLabel}},
+		BlockParsed{bb, []base.Label{base.SleepLabel, base.WildCardLabel}, 0, ""},
 		base.WildCardLabel,
 		true},
 	{"test2",
-		BlockParsed{bb, []base.Label{base.SleepLabel, base.SleepLabel}},
+		BlockParsed{bb, []base.Label{base.SleepLabel, base.SleepLabel}, 0, ""},
 		base.WildCardLabel,
 		false},
 }
@@ -19,7 +19,9 @@ func NewBlockTut(b *BlockParsed) *BlockTut {
 
 func (x *BlockTut) firstNiceLabel() base.Label {
 	for _, l := range x.labels {
-		if l != base.WildCardLabel && l != base.AnonLabel {
+		// The language label, e.g. bash, doesn't name a block.
+		if l != base.WildCardLabel && l != base.AnonLabel && !l.IsAttribute() &&
+			string(l) != x.language {
 			return l
 		}
 	}
@@ -34,8 +36,12 @@ func (x *BlockTut) Title() string {
 	return x.Name()
 }
 
-// Name attempts to return a decent name for the block.
+// Name attempts to return a decent name for the block: its
+// @name attribute if it has one, else its first label.
 func (x *BlockTut) Name() string {
+	if n, ok := base.FindAttribute(x.labels, base.NameAttribute); ok && len(n) > 0 {
+		return n
+	}
 	l := x.firstNiceLabel()
 	if l == base.AnonLabel {
 		return AnonBlockName
@@ -14,19 +14,19 @@ type btTest struct {
 
 var btTests = []btTest{
 	{"empty",
-		BlockParsed{bb, []base.Label{}},
+		BlockParsed{bb, []base.Label{}, 0, ""},
 		AnonBlockName},
 	{"anylabel",
-		BlockParsed{bb, []base.Label{base.WildCardLabel}},
+		BlockParsed{bb, []base.Label{base.WildCardLabel}, 0, ""},
 		AnonBlockName},
 	{"sleeplabel",
-		BlockParsed{bb, []base.Label{base.SleepLabel, base.WildCardLabel}},
+		BlockParsed{bb, []base.Label{base.SleepLabel, base.WildCardLabel}, 0, ""},
 		"sleep"},
 	{"wildFirst",
-		BlockParsed{bb, []base.Label{base.WildCardLabel, base.Label("hoser"), base.SleepLabel}},
+		BlockParsed{bb, []base.Label{base.WildCardLabel, base.Label("hoser"), base.SleepLabel}, 0, ""},
 		"hoser"},
 	{"xFirst",
-		BlockParsed{bb, []base.Label{base.Label("shazam"), base.WildCardLabel, base.SleepLabel}},
+		BlockParsed{bb, []base.Label{base.Label("shazam"), base.WildCardLabel, base.SleepLabel}, 0, ""},
 		"shazam"},
 }
 
@@ -7,10 +7,15 @@ type Course struct {
 	name     string
 	path     base.FilePath
 	children []Tutorial
+	glossary Glossary
+	// redirects hold the old paths of pages moved within the course.
+	redirects Redirects
 }
 
 // NewCourse makes a Course.
-func NewCourse(p base.FilePath, c []Tutorial) *Course { return &Course{p.Base(), p, c} }
+func NewCourse(p base.FilePath, c []Tutorial) *Course {
+	return &Course{p.Base(), p, c, Glossary{}, Redirects{}}
+}
 
 // Accept accepts a visitor.
 func (c *Course) Accept(v TutVisitor) { v.VisitCourse(c) }
@@ -26,3 +31,16 @@ func (c *Course) Path() base.FilePath { return c.path }
 
 // Children are the parts of the course.
 func (c *Course) Children() []Tutorial { return c.children }
+
+// Glossary holds terms defined for the course and everything in it.
+func (c *Course) Glossary() Glossary { return c.glossary }
+
+// SetGlossary sets the course's glossary.
+func (c *Course) SetGlossary(g Glossary) { c.glossary = g }
+
+// Redirects map the old paths of pages in the course, relative
+// to it, to their new ones.
+func (c *Course) Redirects() Redirects { return c.redirects }
+
+// SetRedirects sets the course's redirects.
+func (c *Course) SetRedirects(r Redirects) { c.redirects = r }
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// FrontMatter is lesson metadata, held in YAML between
+// lines of three dashes at the top of a markdown file, e.g.
+//
+//	---
+//	title: Installing the CLI
+//	slug: install-cli
+//	weight: 10
+//	author: Jane Doe
+//	duration: 20m
+//	verified: 2024-03-01
+//	labels: [install]
+//	requires: [install, configure]
+//	tags: [beginner, gcp]
+//	vars:
+//	  REGION: the GCP region to use, e.g. us-east1
+//	---
+type FrontMatter struct {
+	// Title names the lesson in the navigation, instead of its file.
+	Title string `yaml:"title"`
+	// Slug, if given, replaces the file's name in the lesson's URL.
+	Slug string `yaml:"slug"`
+	// Weight orders the lessons in a directory; lighter lessons
+	// come first, and lessons without a weight come last.
+	Weight int `yaml:"weight"`
+	// Labels are added to every code block in the lesson,
+	// e.g. "install" or "@install".
+	Labels []string `yaml:"labels"`
+	// Author of the lesson.
+	Author string `yaml:"author"`
+	// Draft lessons are skipped when loading a directory.
+	Draft bool `yaml:"draft"`
+	// Duration is roughly how long the lesson takes, e.g. 20m.
+	Duration string `yaml:"duration"`
+	// Verified is the date, e.g. 2024-03-01, on which
+	// the lesson was last known to work.
+	Verified string `yaml:"verified"`
+	// Requires names lessons to complete before this one,
+	// e.g. "install", or with a path, "setup/install".
+	Requires []string `yaml:"requires"`
+	// Tags categorize the lesson, and all its blocks.
+	Tags []string `yaml:"tags"`
+	// Vars describe the variables, e.g. {{.REGION}}, the
+	// lesson's blocks use, mapping their names to descriptions.
+	Vars map[string]string `yaml:"vars"`
+	// Anchors map the ids the lesson's headings had before being
+	// renamed, e.g. setup--get-tools, to the ids they have now.
+	Anchors map[string]string `yaml:"anchors"`
+}
+
+// NewFrontMatter returns empty front matter.
+func NewFrontMatter() *FrontMatter {
+	return &FrontMatter{}
+}
+
+// BlockLabels are the labels to add to each of the lesson's blocks.
+func (fm *FrontMatter) BlockLabels() []base.Label {
+	result := []base.Label{}
+	for _, l := range fm.Labels {
+		if l = strings.TrimPrefix(strings.TrimSpace(l), "@"); len(l) > 0 {
+			result = append(result, base.Label(l))
+		}
+	}
+	return result
+}
+
+// VerifiedLayout is the layout of the date in Verified.
+const VerifiedLayout = "2006-01-02"
+
+// ParseFrontMatter parses the YAML text of front matter.
+func ParseFrontMatter(s string) (*FrontMatter, error) {
+	result := NewFrontMatter()
+	if err := yaml.Unmarshal([]byte(s), result); err != nil {
+		return nil, errors.Wrap(err, "bad front matter")
+	}
+	return result, nil
+}
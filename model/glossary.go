@@ -0,0 +1,57 @@
+package model
+
+import (
+	"sort"
+	"strings"
+)
+
+// Glossary maps terms to their definitions.
+type Glossary map[string]string
+
+// ParseGlossary parses glossary file contents, one
+// "term: definition" per line.  Blank lines, lines
+// starting with # and lines without a colon are ignored.
+func ParseGlossary(s string) Glossary {
+	result := Glossary{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		term := strings.TrimSpace(parts[0])
+		def := strings.TrimSpace(parts[1])
+		if len(term) > 0 && len(def) > 0 {
+			result[term] = def
+		}
+	}
+	return result
+}
+
+// Terms in the glossary, sorted case-insensitively.
+func (g Glossary) Terms() []string {
+	result := make([]string, 0, len(g))
+	for t := range g {
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return strings.ToLower(result[i]) < strings.ToLower(result[j])
+	})
+	return result
+}
+
+// Merge returns a new glossary holding the terms of both,
+// with definitions from the other glossary winning.
+func (g Glossary) Merge(other Glossary) Glossary {
+	result := Glossary{}
+	for t, d := range g {
+		result[t] = d
+	}
+	for t, d := range other {
+		result[t] = d
+	}
+	return result
+}
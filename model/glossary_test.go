@@ -0,0 +1,26 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGlossary(t *testing.T) {
+	g := ParseGlossary(`
+# A comment.
+pod: a group of containers
+node:   a machine: real or virtual
+not a definition
+empty:
+`)
+	if got := strings.Join(g.Terms(), ","); got != "node,pod" {
+		t.Errorf("got terms %s", got)
+	}
+	if g["node"] != "a machine: real or virtual" {
+		t.Errorf("got definition %q", g["node"])
+	}
+	m := g.Merge(Glossary{"pod": "overridden"})
+	if m["pod"] != "overridden" || g["pod"] != "a group of containers" {
+		t.Errorf("merge should favor the other glossary, leaving this one alone")
+	}
+}
@@ -0,0 +1,133 @@
+package model
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/monopole/mdrip/base"
+)
+
+// Variants maps languages to a lesson written in each; the
+// lesson as first written, e.g. intro.md, is under "", and
+// its translations, e.g. intro.fr.md, under their language.
+type Variants map[string]*LessonTut
+
+// langCode matches the language codes ending lessons' names,
+// e.g. the fr of intro.fr.md, or the pt-BR of intro.pt-BR.md.
+var langCode = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})?$`)
+
+// IsLang is true if s is a language code that may end a
+// lesson's name, e.g. fr or pt-BR, or "", for none.
+func IsLang(s string) bool {
+	return len(s) == 0 || langCode.MatchString(s)
+}
+
+// SplitLang splits the path of a markdown file named for a
+// language, e.g. intro.fr.md, into the path of the file it
+// translates, intro.md, and the language, fr.  The language
+// is "" for any other path.
+func SplitLang(p base.FilePath) (base.FilePath, string) {
+	s := string(p)
+	ext := ""
+	if i := strings.LastIndex(s, "."); i > 0 {
+		s, ext = s[:i], s[i:]
+	}
+	i := strings.LastIndex(s, ".")
+	if i < 0 || strings.ContainsAny(s[i:], `/\`) || !langCode.MatchString(s[i+1:]) {
+		return p, ""
+	}
+	return base.FilePath(s[:i] + ext), s[i+1:]
+}
+
+// Lang is the language of the lesson's file, per its
+// name; "" for a lesson that isn't a translation.
+func (l *LessonTut) Lang() string { return l.lang }
+
+// Variants holds the lesson in each of its languages;
+// nil if it has no translations.
+func (l *LessonTut) Variants() Variants { return l.variants }
+
+// AddTranslation makes t, the lesson's translation into the
+// given language, one of the lesson's variants.
+func (l *LessonTut) AddTranslation(lang string, t *LessonTut) {
+	if l.variants == nil {
+		l.variants = Variants{l.lang: l}
+	}
+	t.lang = lang
+	t.variants = l.variants
+	l.variants[lang] = t
+}
+
+// In is the lesson in the given language, or, lacking
+// that translation, the lesson as first written.
+func (v Variants) In(lang string) *LessonTut {
+	if l, ok := v[lang]; ok {
+		return l
+	}
+	return v[""]
+}
+
+// Langs are the languages, sorted, lessons in the tutorial
+// are translated into.
+func Langs(t Tutorial) []string {
+	v := &langCollector{map[string]bool{}}
+	t.Accept(v)
+	var result []string
+	for lang := range v.langs {
+		result = append(result, lang)
+	}
+	sort.Strings(result)
+	return result
+}
+
+type langCollector struct {
+	langs map[string]bool
+}
+
+func (v *langCollector) VisitBlockTut(b *BlockTut) {}
+
+func (v *langCollector) VisitLessonTut(l *LessonTut) {
+	for lang := range l.variants {
+		if len(lang) > 0 {
+			v.langs[lang] = true
+		}
+	}
+}
+
+func (v *langCollector) VisitCourse(c *Course) {
+	for _, x := range c.children {
+		x.Accept(v)
+	}
+}
+
+func (v *langCollector) VisitTopCourse(t *TopCourse) {
+	v.VisitCourse(&t.Course)
+}
+
+// InLang returns the tutorial with each lesson translated
+// into the given language, where there's a translation, and
+// as first written elsewhere; "" asks for the latter, only.
+// The tutorial is left as it was.
+func InLang(t Tutorial, lang string) Tutorial {
+	switch x := t.(type) {
+	case *LessonTut:
+		if x.variants == nil {
+			return x
+		}
+		return x.variants.In(lang)
+	case *Course:
+		return &Course{x.name, x.path, childrenInLang(x.children, lang), x.glossary, x.redirects}
+	case *TopCourse:
+		return &TopCourse{Course{x.name, x.path, childrenInLang(x.children, lang), x.glossary, x.redirects}}
+	}
+	return t
+}
+
+func childrenInLang(c []Tutorial, lang string) []Tutorial {
+	result := make([]Tutorial, len(c))
+	for i, x := range c {
+		result[i] = InLang(x, lang)
+	}
+	return result
+}
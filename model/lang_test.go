@@ -0,0 +1,53 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+)
+
+func TestSplitLang(t *testing.T) {
+	var tests = map[base.FilePath]struct {
+		orig base.FilePath
+		lang string
+	}{
+		"docs/01_intro.fr.md":    {"docs/01_intro.md", "fr"},
+		"docs/01_intro.pt-BR.md": {"docs/01_intro.md", "pt-BR"},
+		"docs/01_intro.md":       {"docs/01_intro.md", ""},
+		"docs/v1.2.md":           {"docs/v1.2.md", ""},
+		"docs/intro.Setup.md":    {"docs/intro.Setup.md", ""},
+		"docs.fr/intro.md":       {"docs.fr/intro.md", ""},
+	}
+	for p, want := range tests {
+		orig, lang := SplitLang(p)
+		if orig != want.orig || lang != want.lang {
+			t.Errorf("%s: got %s, %q", p, orig, lang)
+		}
+	}
+}
+
+func TestInLang(t *testing.T) {
+	intro := NewLessonTutForTests("intro.md", []*BlockTut{})
+	intro.AddTranslation("fr", NewLessonTutForTests("intro.fr.md", []*BlockTut{}))
+	intro.AddTranslation("ja", NewLessonTutForTests("intro.ja.md", []*BlockTut{}))
+	next := NewLessonTutForTests("next.md", []*BlockTut{})
+	tut := NewTopCourse("top", "top", []Tutorial{intro, NewCourse("more", []Tutorial{next})})
+	if got := Langs(tut); len(got) != 2 || got[0] != "fr" || got[1] != "ja" {
+		t.Errorf("got langs %v", got)
+	}
+	fr := InLang(tut, "fr")
+	l := fr.Children()[0].(*LessonTut)
+	if l.Path() != "intro.fr.md" || l.Name() != "intro" || l.Lang() != "fr" {
+		t.Errorf("got %s, named %s, in %q", l.Path(), l.Name(), l.Lang())
+	}
+	if fr.Children()[1].Children()[0] != next {
+		t.Errorf("expected the untranslated lesson as written")
+	}
+	if tut.Children()[0] != intro {
+		t.Errorf("expected the tutorial left as it was")
+	}
+	// Languages may be switched back and forth.
+	if InLang(InLang(fr, "ja"), "").Children()[0] != intro {
+		t.Errorf("expected the lesson as written")
+	}
+}
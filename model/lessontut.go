@@ -1,6 +1,9 @@
 package model
 
 import (
+	"strings"
+	"time"
+
 	"github.com/monopole/mdrip/base"
 )
 
@@ -11,11 +14,20 @@ type LessonTut struct {
 	path      base.FilePath
 	mdContent *MdContent
 	blocks    []*BlockTut
+	// revision is the last change to the lesson's file;
+	// nil if it isn't known, e.g. the file isn't in git.
+	revision *Revision
+	// lang is the language the lesson's file is in, per
+	// its name, e.g. fr for intro.fr.md; "" for intro.md.
+	lang string
+	// variants, if not nil, holds the lesson in each of the
+	// languages it's in, this one included, by language.
+	variants Variants
 }
 
 // NewLessonTutForTests makes one for tests.
 func NewLessonTutForTests(p base.FilePath, blocks []*BlockTut) *LessonTut {
-	return &LessonTut{p, NewMdContent(), blocks}
+	return &LessonTut{p, NewMdContent(), blocks, nil, "", nil}
 }
 
 // NewLessonTutFromMdContent converts MdContent to a LessonTut.
@@ -24,7 +36,7 @@ func NewLessonTutFromMdContent(p base.FilePath, md *MdContent) *LessonTut {
 	for i, b := range md.Blocks {
 		result[i] = NewBlockTut(b)
 	}
-	return &LessonTut{p, md, result}
+	return &LessonTut{p, md, result, nil, "", nil}
 }
 
 // Accept accepts a visitor.
@@ -32,17 +44,109 @@ func (l *LessonTut) Accept(v TutVisitor) { v.VisitLessonTut(l) }
 
 // Title is the purported title of the LessonTut.
 func (l *LessonTut) Title() string {
+	if t := l.mdContent.FrontMatter().Title; len(t) > 0 {
+		return t
+	}
 	if l.mdContent.HasTitle() {
 		return l.mdContent.GetTitle()
 	}
 	return l.Name()
 }
 
-// Name is the purported name of the lesson.
+// Name is the purported name of the lesson; that of
+// a translation is that of the lesson it translates.
 func (l *LessonTut) Name() string {
+	if len(l.lang) > 0 {
+		return strings.TrimSuffix(l.path.Base(), "."+l.lang)
+	}
 	return l.path.Base()
 }
 
+// NavName is what to call the lesson in navigation; the
+// title from its front matter, else its name.
+func (l *LessonTut) NavName() string {
+	if t := l.mdContent.FrontMatter().Title; len(t) > 0 {
+		return t
+	}
+	return l.Name()
+}
+
+// Weight orders the lesson among others; 0 if unweighted.
+func (l *LessonTut) Weight() int {
+	return l.mdContent.FrontMatter().Weight
+}
+
+// Author of the lesson, from its front matter.
+func (l *LessonTut) Author() string {
+	return l.mdContent.FrontMatter().Author
+}
+
+// Duration is roughly how long the lesson takes, from its
+// front matter; 0 if not given, or not a duration.
+func (l *LessonTut) Duration() time.Duration {
+	d, err := time.ParseDuration(l.mdContent.FrontMatter().Duration)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// Verified is when the lesson was last known to work, from
+// its front matter; the zero time if not given.
+func (l *LessonTut) Verified() time.Time {
+	t, err := time.Parse(VerifiedLayout, l.mdContent.FrontMatter().Verified)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// Revision is the last change to the lesson's file, per git;
+// nil if it isn't known.
+func (l *LessonTut) Revision() *Revision { return l.revision }
+
+// SetRevision sets the last change to the lesson's file.
+func (l *LessonTut) SetRevision(r *Revision) { l.revision = r }
+
+// IsDraft is true if the lesson's front matter marks it a draft.
+func (l *LessonTut) IsDraft() bool {
+	return l.mdContent.FrontMatter().Draft
+}
+
+// Requires names the lessons to complete before this one.
+func (l *LessonTut) Requires() []string {
+	return l.mdContent.FrontMatter().Requires
+}
+
+// Vars maps the names of variables the lesson's blocks
+// use to descriptions of them, from its front matter.
+func (l *LessonTut) Vars() map[string]string {
+	return l.mdContent.FrontMatter().Vars
+}
+
+// Anchors maps the old ids of the lesson's renamed
+// headings to their ids now, from its front matter.
+func (l *LessonTut) Anchors() map[string]string {
+	return l.mdContent.FrontMatter().Anchors
+}
+
+// Tags categorizing the lesson, from its front matter.
+func (l *LessonTut) Tags() []string {
+	return base.MergeTags(l.mdContent.FrontMatter().Tags)
+}
+
+// AllTags are the lesson's tags, and those of its blocks.
+func (l *LessonTut) AllTags() []string {
+	lists := [][]string{l.Tags()}
+	for _, b := range l.blocks {
+		lists = append(lists, b.Tags())
+	}
+	return base.MergeTags(lists...)
+}
+
+// Raw is the lesson's markdown as written, front matter and all.
+func (l *LessonTut) Raw() string { return l.mdContent.Raw() }
+
 // Path to the lesson.  A lesson has a 1:1 correspondence with a path.
 func (l *LessonTut) Path() base.FilePath { return l.path }
 
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/monopole/mdrip/base"
@@ -16,9 +17,9 @@ type ltTest struct {
 }
 
 var array1 = []*BlockParsed{
-	{bb, []base.Label{}},
-	{bb, []base.Label{base.WildCardLabel}},
-	{bb, []base.Label{base.SleepLabel, base.WildCardLabel}},
+	{bb, []base.Label{}, 0, ""},
+	{bb, []base.Label{base.WildCardLabel}, 0, ""},
+	{bb, []base.Label{base.SleepLabel, base.WildCardLabel}, 0, ""},
 }
 
 var ltTests = []ltTest{
@@ -66,3 +67,22 @@ func TestLessonTut(t *testing.T) {
 		}
 	}
 }
+
+func TestLessonTutTags(t *testing.T) {
+	md := NewMdContent()
+	md.SetFrontMatter(&FrontMatter{Tags: []string{"GCP", "beginner"}})
+	md.Blocks = []*BlockParsed{
+		{bb, []base.Label{"tags=advanced,gcp"}, 0, ""},
+		{bb, []base.Label{"install"}, 0, ""},
+	}
+	l := NewLessonTutFromMdContent(base.FilePath("setup.md"), md)
+	if got := strings.Join(l.Tags(), ","); got != "beginner,gcp" {
+		t.Errorf("got lesson tags %s", got)
+	}
+	if got := strings.Join(l.AllTags(), ","); got != "advanced,beginner,gcp" {
+		t.Errorf("got all tags %s", got)
+	}
+	if got := strings.Join(l.Blocks()[0].Tags(), ","); got != "advanced,gcp" {
+		t.Errorf("got block tags %s", got)
+	}
+}
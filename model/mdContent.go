@@ -26,7 +26,10 @@ type MdContent struct {
 	code     []base.OpaqueCode
 	prose    []base.MdProse
 	headers  []*mdHeader
+	front    *FrontMatter
 	Blocks   []*BlockParsed
+	// raw is the markdown as written, front matter and all.
+	raw string
 }
 
 // NewMdContent makes a new instance of MdContent.
@@ -36,7 +39,29 @@ func NewMdContent() *MdContent {
 		[]base.OpaqueCode{},
 		[]base.MdProse{},
 		[]*mdHeader{},
-		[]*BlockParsed{}}
+		NewFrontMatter(),
+		[]*BlockParsed{},
+		""}
+}
+
+// FrontMatter is the metadata at the top of the markdown.
+func (md *MdContent) FrontMatter() *FrontMatter {
+	return md.front
+}
+
+// SetFrontMatter sets the metadata.
+func (md *MdContent) SetFrontMatter(x *FrontMatter) {
+	md.front = x
+}
+
+// Raw is the markdown as written, if known.
+func (md *MdContent) Raw() string {
+	return md.raw
+}
+
+// SetRaw sets the markdown as written.
+func (md *MdContent) SetRaw(x string) {
+	md.raw = x
 }
 
 // HasTitle is true if a title can be discerned from the markdown.
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"strings"
+)
+
+// Redirects maps the old URL path of a page, e.g. "setup/install",
+// to its new one, so that moving content doesn't break bookmarks.
+type Redirects map[string]string
+
+// maxRedirectHops bounds a chain of redirects, in case of a loop.
+const maxRedirectHops = 10
+
+// cleanRedirectPath trims slashes and a .md suffix, so that
+// "/setup/install.md" and "setup/install/" mean the same page.
+func cleanRedirectPath(p string) string {
+	return strings.TrimSuffix(strings.Trim(strings.TrimSpace(p), "/"), ".md")
+}
+
+// ParseRedirects parses redirects file contents, one
+// "oldPath newPath" per line, optionally separated by an
+// arrow, e.g. "setup/install -> install/linux".  Blank
+// lines, lines starting with # and lines not holding two
+// paths are ignored.
+func ParseRedirects(s string) Redirects {
+	result := Redirects{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Fields(strings.Replace(line, "->", " ", 1))
+		if len(parts) != 2 {
+			continue
+		}
+		from, to := cleanRedirectPath(parts[0]), cleanRedirectPath(parts[1])
+		if len(from) > 0 && from != to {
+			result[from] = to
+		}
+	}
+	return result
+}
+
+// Find returns the path a page moved to, following
+// chains of redirects, and true if it moved at all.
+func (r Redirects) Find(p string) (string, bool) {
+	p = cleanRedirectPath(p)
+	found := false
+	for i := 0; i < maxRedirectHops; i++ {
+		to, ok := r[p]
+		if !ok {
+			break
+		}
+		p, found = to, true
+	}
+	return p, found
+}
+
+// Merge returns new redirects holding those of both, with the
+// other's paths, which are relative to the given directory,
+// e.g. "setup", made relative to this one's.
+func (r Redirects) Merge(dir string, other Redirects) Redirects {
+	result := Redirects{}
+	for f, t := range r {
+		result[f] = t
+	}
+	for f, t := range other {
+		result[dir+"/"+f] = dir + "/" + t
+	}
+	return result
+}
@@ -0,0 +1,36 @@
+package model
+
+import "testing"
+
+func TestRedirects(t *testing.T) {
+	r := ParseRedirects(`
+# Moved in the great reorganization.
+setup/install -> install/linux
+/old.md  setup/install
+loop1 loop2
+loop2 loop1
+just one path
+`)
+	for _, test := range []struct {
+		from, want string
+		found      bool
+	}{
+		{"setup/install", "install/linux", true},
+		{"/setup/install/", "install/linux", true},
+		{"old", "install/linux", true},
+		{"new", "new", false},
+		{"just", "just", false},
+	} {
+		got, found := r.Find(test.from)
+		if got != test.want || found != test.found {
+			t.Errorf("%s: got %s %v, want %s %v", test.from, got, found, test.want, test.found)
+		}
+	}
+	if _, found := r.Find("loop1"); !found {
+		t.Errorf("a loop should still redirect somewhere")
+	}
+	m := Redirects{"a": "b"}.Merge("sub", Redirects{"c": "d"})
+	if m["a"] != "b" || m["sub/c"] != "sub/d" {
+		t.Errorf("got merged %v", m)
+	}
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// Revision is the last change to a lesson's file, per git.
+type Revision struct {
+	// When the change was authored.
+	When time.Time
+	// Author of the change.
+	Author string
+}
+
+// NewRevision is a ctor.
+func NewRevision(when time.Time, author string) *Revision {
+	return &Revision{when, author}
+}
+
+// Date is when the change was authored, as a day, e.g. 2024-03-01.
+func (r *Revision) Date() string {
+	return r.When.Format(VerifiedLayout)
+}
+
+// IsOlderThan is true if the change was authored more
+// than the given number of months before now.
+func (r *Revision) IsOlderThan(months int, now time.Time) bool {
+	return r.When.Before(now.AddDate(0, -months, 0))
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRevision(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	r := NewRevision(time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC), "Tess")
+	if r.Date() != "2024-03-01" {
+		t.Errorf("got date %s", r.Date())
+	}
+	if r.IsOlderThan(4, now) {
+		t.Errorf("a change three months back is older than four months")
+	}
+	if !r.IsOlderThan(3, now) {
+		t.Errorf("a change three and a half months back isn't older than three months")
+	}
+}
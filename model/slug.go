@@ -0,0 +1,99 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Slugify makes a URL path segment from a name, keeping letters
+// (of any script, with their accents), digits, underscores and
+// dots, and turning each run of anything else, e.g. spaces or
+// slashes, into one dash.  Case is kept, so the paths of lessons
+// named without such characters don't change.
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) ||
+			r == '_' || r == '.':
+			b.WriteRune(r)
+			dash = false
+		case !dash && b.Len() > 0:
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// Slug is the lesson's segment of its URL path: the slug from its
+// front matter, else one made from its name.
+func (l *LessonTut) Slug() string {
+	if s := Slugify(l.mdContent.FrontMatter().Slug); len(s) > 0 {
+		return s
+	}
+	if s := Slugify(l.Name()); len(s) > 0 {
+		return s
+	}
+	return l.Name()
+}
+
+// Slug is the course's segment of the URL paths of its lessons.
+func (c *Course) Slug() string {
+	if s := Slugify(c.Name()); len(s) > 0 {
+		return s
+	}
+	return c.Name()
+}
+
+// SlugCollisions describes each pair of lessons or courses in the
+// same course whose slugs differ at most in case, so that one
+// shadows the other in URLs, and on case insensitive file systems.
+func SlugCollisions(t Tutorial) []string {
+	v := &slugChecker{}
+	t.Accept(v)
+	return v.collisions
+}
+
+type slugChecker struct {
+	collisions []string
+}
+
+func (v *slugChecker) check(children []Tutorial) {
+	seen := map[string]Tutorial{}
+	for _, x := range children {
+		s := ""
+		switch y := x.(type) {
+		case *LessonTut:
+			s = y.Slug()
+		case *Course:
+			s = y.Slug()
+		default:
+			continue
+		}
+		k := strings.ToLower(s)
+		if other, ok := seen[k]; ok {
+			v.collisions = append(v.collisions, fmt.Sprintf(
+				"%s and %s both have the slug %q, ignoring case", other.Path(), x.Path(), s))
+			continue
+		}
+		seen[k] = x
+	}
+}
+
+func (v *slugChecker) VisitBlockTut(b *BlockTut) {}
+
+func (v *slugChecker) VisitLessonTut(l *LessonTut) {}
+
+func (v *slugChecker) VisitCourse(c *Course) {
+	v.check(c.Children())
+	for _, x := range c.Children() {
+		x.Accept(v)
+	}
+}
+
+func (v *slugChecker) VisitTopCourse(t *TopCourse) {
+	v.VisitCourse(&t.Course)
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+)
+
+func TestSlugify(t *testing.T) {
+	for _, test := range []struct {
+		name, want string
+	}{
+		{"README", "README"},
+		{"getting started", "getting-started"},
+		{"  a -- b  ", "a-b"},
+		{"v1.2_notes", "v1.2_notes"},
+		{"Café olé!", "Café-olé"},
+		{"日本語 ガイド", "日本語-ガイド"},
+		{"a/b?c", "a-b-c"},
+		{"!!!", ""},
+	} {
+		if got := Slugify(test.name); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSlugCollisions(t *testing.T) {
+	lesson := func(p, fm string) *LessonTut {
+		md := NewMdContent()
+		if len(fm) > 0 {
+			md.SetFrontMatter(&FrontMatter{Slug: fm})
+		}
+		return NewLessonTutFromMdContent(base.FilePath(p), md)
+	}
+	tut := NewTopCourse("top", base.FilePath("top"), []Tutorial{
+		lesson("top/Setup.md", ""),
+		lesson("top/setup.md", ""),
+		lesson("top/intro.md", "welcome"),
+		lesson("top/other.md", "Welcome"),
+		NewCourse(base.FilePath("top/more"), []Tutorial{
+			lesson("top/more/setup.md", ""),
+			lesson("top/more/install.md", "my install"),
+		}),
+	})
+	got := SlugCollisions(tut)
+	if len(got) != 2 ||
+		!strings.HasPrefix(got[0], "top/Setup.md and top/setup.md") ||
+		!strings.HasPrefix(got[1], "top/intro.md and top/other.md") {
+		t.Errorf("got %v", got)
+	}
+	if s := lesson("x/a b.md", "").Slug(); s != "a-b" {
+		t.Errorf("got slug %q", s)
+	}
+	if s := lesson("x/a.md", "my install").Slug(); s != "my-install" {
+		t.Errorf("got slug %q", s)
+	}
+}
@@ -13,7 +13,7 @@ type TopCourse struct {
 
 // NewTopCourse makes a new TopCourse.
 func NewTopCourse(n string, p base.FilePath, c []Tutorial) *TopCourse {
-	return &TopCourse{Course{n, p, c}}
+	return &TopCourse{Course{n, p, c, Glossary{}, Redirects{}}}
 }
 
 // Accept accepts a visitor.
@@ -0,0 +1,76 @@
+// Package preflight runs quick probes of the environment a tutorial's
+// blocks need - a reachable cluster, a valid registry login, free
+// quota - before a long test run, so that a broken environment fails
+// fast, and distinctly, rather than as a failure of the docs.
+//
+// Probes are declared in a YAML file, as a list of Probes.
+package preflight
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+	"github.com/monopole/mdrip/program"
+	"gopkg.in/yaml.v2"
+)
+
+// ExitCode is mdrip's exit status when a probe fails; test
+// failures exit with a different one.
+const ExitCode = 3
+
+// DefaultTimeout is how long a probe that doesn't say may take.
+const DefaultTimeout = 30 * time.Second
+
+// Probe is a quick check that the environment works.
+type Probe struct {
+	// Name identifies the probe in reports, e.g. cluster.
+	Name string `yaml:"name"`
+	// Run is the shell code probing, failing if it exits non-zero.
+	Run string `yaml:"run"`
+	// Timeout is how long the probe may take, e.g. 10s; if
+	// empty, DefaultTimeout.
+	Timeout string `yaml:"timeout"`
+}
+
+// Load reads the probes declared in the YAML file.
+func Load(path string) ([]Probe, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var probes []Probe
+	if err := yaml.UnmarshalStrict(data, &probes); err != nil {
+		return nil, fmt.Errorf("bad probes in %s: %v", path, err)
+	}
+	if len(probes) == 0 {
+		return nil, fmt.Errorf("no probes in %s", path)
+	}
+	for i, p := range probes {
+		if len(p.Name) == 0 || len(p.Run) == 0 {
+			return nil, fmt.Errorf("probe %d in %s needs a name and code to run", i+1, path)
+		}
+		if len(p.Timeout) == 0 {
+			probes[i].Timeout = DefaultTimeout.String()
+		} else if _, err := time.ParseDuration(p.Timeout); err != nil {
+			return nil, fmt.Errorf("probe %s in %s: bad timeout %q", p.Name, path, p.Timeout)
+		}
+	}
+	return probes, nil
+}
+
+// NewProgram returns a program running the probes, in order, as
+// the blocks of a lesson at the given path, so any runner that
+// runs a tutorial's blocks can probe the environment they'd run in.
+func NewProgram(path string, probes []Probe) *program.Program {
+	blocks := make([]*program.BlockPgm, len(probes))
+	for i, p := range probes {
+		blocks[i] = program.NewBlockPgmFromBlockTut(model.NewBlockTut(model.NewBlockParsed(
+			[]base.Label{base.Label(p.Name), base.Label(base.TimeoutAttribute + "=" + p.Timeout)},
+			base.NoProse(), base.OpaqueCode(p.Run+"\n"))))
+	}
+	return program.NewProgram(
+		[]*program.LessonPgm{program.NewLessonPgm(base.FilePath(path), blocks)})
+}
@@ -0,0 +1,77 @@
+package preflight
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monopole/mdrip/subshell"
+)
+
+func writeProbes(t *testing.T, dir, content string) string {
+	n := filepath.Join(dir, "probes.yaml")
+	if err := ioutil.WriteFile(n, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "preflight")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var tests = map[string]struct {
+		content string
+		err     string
+	}{
+		"fine":       {"- name: up\n  run: 'true'\n  timeout: 5s\n- name: also\n  run: 'true'\n", ""},
+		"empty":      {"", "no probes"},
+		"nameless":   {"- run: 'true'\n", "needs a name"},
+		"codeless":   {"- name: up\n", "needs a name and code"},
+		"badTimeout": {"- name: up\n  run: 'true'\n  timeout: soon\n", "bad timeout"},
+		"typo":       {"- name: up\n  rnu: 'true'\n", "bad probes"},
+	}
+	for n, test := range tests {
+		probes, err := Load(writeProbes(t, dir, test.content))
+		if len(test.err) == 0 {
+			if err != nil {
+				t.Errorf("%s: %v", n, err)
+			} else if probes[1].Timeout != DefaultTimeout.String() {
+				t.Errorf("%s: got timeout %q", n, probes[1].Timeout)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, want %q", n, err, test.err)
+		}
+	}
+}
+
+func TestNewProgram(t *testing.T) {
+	r, err := subshell.NewRunner(subshell.NameBash,
+		subshell.RunnerOptions{BlockTimeOut: time.Minute, Parallel: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProgram("probes.yaml", []Probe{
+		{"up", "true", "5s"}, {"quota", "echo none left; exit 7", "5s"}, {"never", "true", "5s"}})
+	b := p.Lessons()[0].Blocks()
+	if b[1].Name() != "quota" {
+		t.Errorf("got name %q", b[1].Name())
+	}
+	if d, _ := b[1].Timeout(); d != 5*time.Second {
+		t.Errorf("got timeout %v", d)
+	}
+	result := r.Run(p)
+	if result.Error() == nil {
+		t.Fatalf("expected the quota probe to fail")
+	}
+	if result.Index() != 1 || !strings.Contains(result.StdOut(), "none left") {
+		t.Errorf("got index %d, output %q", result.Index(), result.StdOut())
+	}
+}
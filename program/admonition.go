@@ -0,0 +1,115 @@
+package program
+
+import (
+	"regexp"
+	"strings"
+)
+
+// admonitionStart matches the start of a GitHub style admonition,
+// a block quote whose first line is e.g. [!NOTE], as rendered.
+var admonitionStart = regexp.MustCompile(
+	`<blockquote>\s*<p>\[!(NOTE|TIP|IMPORTANT|WARNING|CAUTION)\]\s*`)
+
+// admonish styles block quotes that are admonitions, giving them a
+// class and a title, e.g. "Note", in place of the [!NOTE] marker.
+func admonish(h string) string {
+	return admonitionStart.ReplaceAllStringFunc(h, func(m string) string {
+		kind := admonitionStart.FindStringSubmatch(m)[1]
+		title := kind[:1] + strings.ToLower(kind[1:])
+		return `<blockquote class="admonition admonition` + title + `">` +
+			"\n" + `<p class="admonitionTitle">` + title + "</p>\n<p>"
+	})
+}
+
+var emojiCode = regexp.MustCompile(`:([a-z0-9_+-]+):`)
+
+// Text inside these elements keeps its :shortcodes:.
+var emojiSkips = map[string]bool{"code": true, "pre": true}
+
+// emojify replaces :shortcodes: in the text of the given
+// HTML with emoji.  Unknown shortcodes are left alone.
+func emojify(h string) string {
+	return mapText(h, emojiSkips, func(s string) string {
+		return emojiCode.ReplaceAllStringFunc(s, func(m string) string {
+			if e, ok := emoji[m[1:len(m)-1]]; ok {
+				return e
+			}
+			return m
+		})
+	})
+}
+
+// emoji maps the most common GitHub shortcodes to emoji.
+var emoji = map[string]string{
+	"+1":                    "\U0001F44D",
+	"-1":                    "\U0001F44E",
+	"thumbsup":              "\U0001F44D",
+	"thumbsdown":            "\U0001F44E",
+	"smile":                 "\U0001F604",
+	"smiley":                "\U0001F603",
+	"grin":                  "\U0001F601",
+	"laughing":              "\U0001F606",
+	"wink":                  "\U0001F609",
+	"blush":                 "\U0001F60A",
+	"thinking":              "\U0001F914",
+	"confused":              "\U0001F615",
+	"cry":                   "\U0001F622",
+	"sweat_smile":           "\U0001F605",
+	"sunglasses":            "\U0001F60E",
+	"heart":                 "❤️",
+	"tada":                  "\U0001F389",
+	"rocket":                "\U0001F680",
+	"fire":                  "\U0001F525",
+	"sparkles":              "✨",
+	"star":                  "⭐",
+	"zap":                   "⚡",
+	"boom":                  "\U0001F4A5",
+	"bulb":                  "\U0001F4A1",
+	"warning":               "⚠️",
+	"no_entry":              "⛔",
+	"stop_sign":             "\U0001F6D1",
+	"x":                     "❌",
+	"heavy_check_mark":      "✔️",
+	"white_check_mark":      "✅",
+	"ballot_box_with_check": "☑️",
+	"question":              "❓",
+	"exclamation":           "❗",
+	"information_source":    "ℹ️",
+	"memo":                  "\U0001F4DD",
+	"pencil":                "\U0001F4DD",
+	"book":                  "\U0001F4D6",
+	"books":                 "\U0001F4DA",
+	"bookmark":              "\U0001F516",
+	"link":                  "\U0001F517",
+	"lock":                  "\U0001F512",
+	"unlock":                "\U0001F513",
+	"key":                   "\U0001F511",
+	"wrench":                "\U0001F527",
+	"hammer":                "\U0001F528",
+	"gear":                  "⚙️",
+	"package":               "\U0001F4E6",
+	"computer":              "\U0001F4BB",
+	"cloud":                 "☁️",
+	"whale":                 "\U0001F433",
+	"penguin":               "\U0001F427",
+	"bug":                   "\U0001F41B",
+	"construction":          "\U0001F6A7",
+	"hourglass":             "⌛",
+	"stopwatch":             "⏱️",
+	"clock":                 "\U0001F570️",
+	"mag":                   "\U0001F50D",
+	"point_right":           "\U0001F449",
+	"point_left":            "\U0001F448",
+	"point_up":              "☝️",
+	"point_down":            "\U0001F447",
+	"arrow_right":           "➡️",
+	"arrow_left":            "⬅️",
+	"arrow_up":              "⬆️",
+	"arrow_down":            "⬇️",
+	"eyes":                  "\U0001F440",
+	"wave":                  "\U0001F44B",
+	"clap":                  "\U0001F44F",
+	"pray":                  "\U0001F64F",
+	"muscle":                "\U0001F4AA",
+	"100":                   "\U0001F4AF",
+}
@@ -0,0 +1,39 @@
+package program
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAdmonitions(t *testing.T) {
+	got := string(makeBlockWithProse("> [!WARNING]\n> This *deletes* data.\n").HTMLProse())
+	for _, want := range []string{
+		`<blockquote class="admonition admonitionWarning">`,
+		`<p class="admonitionTitle">Warning</p>`,
+		`<p>This <em>deletes</em> data.`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got\n%s\nwant it to hold\n%s", got, want)
+		}
+	}
+	if got := admonish("<blockquote>\n<p>plain</p>\n</blockquote>\n"); strings.Contains(got, "admonition") {
+		t.Errorf("plain block quotes should be left alone, got %s", got)
+	}
+}
+
+func TestEmojify(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"<p>done :tada:</p>", "<p>done \U0001F389</p>"},
+		{"<p>:nonesuch: at 10:30:00</p>", "<p>:nonesuch: at 10:30:00</p>"},
+		{"<p><code>:tada:</code></p>", "<p><code>:tada:</code></p>"},
+		{"<h2>Go :rocket:</h2>", "<h2>Go \U0001F680</h2>"},
+	}
+	for _, test := range tests {
+		if got := emojify(test.input); got != test.want {
+			t.Errorf("emojify(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
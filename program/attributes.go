@@ -0,0 +1,40 @@
+package program
+
+import "fmt"
+
+// CheckAttributes describes each of the program's blocks
+// having an attribute test mode can't make sense of, e.g.
+// @timeout=90, with no unit, so a run can refuse to start
+// rather than stop midway.
+func (p *Program) CheckAttributes() []string {
+	var result []string
+	for i, l := range p.lessons {
+		for j, b := range l.blocks {
+			if len(b.Code()) == 0 {
+				continue
+			}
+			for _, err := range b.attributeErrors() {
+				result = append(result, fmt.Sprintf("%s: %v", p.where(place{i, j}), err))
+			}
+		}
+	}
+	return result
+}
+
+// attributeErrors are those of the block's attributes.
+func (x *BlockPgm) attributeErrors() []error {
+	var result []error
+	if _, err := x.Timeout(); err != nil {
+		result = append(result, err)
+	}
+	if _, err := x.Retries(); err != nil {
+		result = append(result, err)
+	}
+	if _, err := x.Isolated(); err != nil {
+		result = append(result, err)
+	}
+	if _, err := x.Sudo(); err != nil {
+		result = append(result, err)
+	}
+	return result
+}
@@ -0,0 +1,38 @@
+package program
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+func TestCheckAttributes(t *testing.T) {
+	for n, test := range map[string]struct {
+		blocks []*model.BlockTut
+		want   []string
+	}{
+		"fine": {[]*model.BlockTut{
+			needsBlock("pay", "timeout=90s"),
+		}, nil},
+		"timeout": {[]*model.BlockTut{
+			needsBlock("pay"),
+			needsBlock("refund", "timeout=90"),
+		}, []string{"cloud/billing.md#2: block refund: bad @timeout=90"}},
+		"retries and isolation": {[]*model.BlockTut{
+			needsBlock("pay", "retries=-1", "isolation=docker"),
+		}, []string{
+			"cloud/billing.md#1: block pay: bad @retries=-1",
+			"cloud/billing.md#1: block pay: bad @isolation=docker",
+		}},
+		"sudo": {[]*model.BlockTut{
+			needsBlock("pay", "sudo=yes"),
+		}, []string{"cloud/billing.md#1: block pay: bad @sudo=yes"}},
+	} {
+		got := NewProgramFromTutorial(base.WildCardLabel, needsTutorial(test.blocks...)).CheckAttributes()
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s: got %q, want %q", n, got, test.want)
+		}
+	}
+}
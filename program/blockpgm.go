@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"github.com/monopole/mdrip/base"
 	"github.com/monopole/mdrip/model"
-	bf2 "gopkg.in/russross/blackfriday.v2"
 	"html/template"
 	"io"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // BlockPgm is input to execution.
@@ -16,7 +18,33 @@ type BlockPgm struct {
 	name string
 	// Should a sleep be added?
 	shouldAddSleep bool
-	id             int
+	// Should a banner precede the block when sent to tmux?
+	shouldSay bool
+	id        int
+	labels    []base.Label
+	// glossary defines terms to annotate in the block's prose.
+	glossary model.Glossary
+	// footnotes holds the definitions, by name, of the
+	// footnotes defined anywhere in the block's lesson.
+	footnotes map[string]string
+	// dir holds the block's lesson; prose images are relative to it.
+	dir string
+	// headings are the h2 and h3 headings in the block's prose.
+	headings []Heading
+	// anchor is the id of the block's element on the page.
+	anchor string
+	// line is where the block starts in its lesson's file; 0 if unknown.
+	line int
+	// index is the block's place among the code blocks of its
+	// lesson's file, counting from 1, whatever the label and
+	// arch extracted; 0 if unknown.
+	index int
+	// tags are the block's tags, and those of its lesson.
+	tags []string
+	// language is that of the block's fence, e.g. python.
+	language string
+	// expected, if not nil, is the output expected of the block.
+	expected *Expectation
 	base.BlockBase
 }
 
@@ -27,15 +55,16 @@ func NewEmptyBlockPgm() *BlockPgm {
 
 // NewBlockPgm returns a block with the given code.
 func NewBlockPgm(code string) *BlockPgm {
-	return &BlockPgm{"noNameBlock", false, -1,
-		base.NewBlockBase(base.NoProse(), base.OpaqueCode(code))}
+	return &BlockPgm{"noNameBlock", false, false, -1, base.NoLabels(), model.Glossary{}, nil, "", nil, "", 0, 0,
+		[]string{}, "", nil, base.NewBlockBase(base.NoProse(), base.OpaqueCode(code))}
 }
 
 // NewBlockPgmFromBlockTut converts a BlockTut to a BlockPgm.
 func NewBlockPgmFromBlockTut(b *model.BlockTut) *BlockPgm {
 	return &BlockPgm{
 		b.Name(),
-		b.HasLabel(base.SleepLabel), -1,
+		b.HasLabel(base.SleepLabel), b.HasLabel(base.SayLabel), -1, b.Labels(),
+		model.Glossary{}, nil, "", nil, "", b.Line(), 0, b.Tags(), b.Language(), nil,
 		base.NewBlockBase(b.Prose(), b.Code())}
 }
 
@@ -45,21 +74,234 @@ func (x *BlockPgm) ID() int { return x.id }
 // Name returns the block name.
 func (x *BlockPgm) Name() string { return x.name }
 
-// HTMLProse returns HTML that should precede the block.
+// Line is the line of the block's opening code fence
+// in its lesson's file, or 0 if unknown.
+func (x *BlockPgm) Line() int { return x.line }
+
+// Index is the block's place among the code blocks of its
+// lesson's file, counting from 1, as in --mode explain's
+// {filePath}#{block}; unlike ID, it doesn't depend on which
+// blocks were extracted.  It's 0 if unknown.
+func (x *BlockPgm) Index() int { return x.index }
+
+// Anchor is the id of the block's element on the page, for
+// links to it, e.g. block-createCluster for a block with
+// @name=createCluster, else from its lesson's path and its
+// index, e.g. block-setup-install-2; empty if it has no code.
+func (x *BlockPgm) Anchor() string { return x.anchor }
+
+// Labels of the block.
+func (x *BlockPgm) Labels() []base.Label { return x.labels }
+
+// Attribute returns the value of the block's attribute with the given key.
+func (x *BlockPgm) Attribute(key string) (string, bool) {
+	return base.FindAttribute(x.labels, key)
+}
+
+// Target is the name of the tmux target the block asks to be sent to,
+// via the attribute @target={name}, or empty if it doesn't ask.
+func (x *BlockPgm) Target() string {
+	t, _ := x.Attribute(base.TargetAttribute)
+	return t
+}
+
+// Arch lists the architectures the block is for, via the
+// attribute @arch={list}, or is empty if it's for any.
+func (x *BlockPgm) Arch() string {
+	a, _ := x.Attribute(base.ArchAttribute)
+	return a
+}
+
+// Language of the block's code, from its fence, e.g. python;
+// empty if the fence didn't say.
+func (x *BlockPgm) Language() string { return x.language }
+
+// interpreters maps fence languages to the programs that run them.
+// Languages not here, e.g. bash or console, run in the shell.
+var interpreters = map[string]string{
+	"python":     "python3",
+	"python3":    "python3",
+	"py":         "python3",
+	"js":         "node",
+	"javascript": "node",
+	"node":       "node",
+	"ruby":       "ruby",
+	"rb":         "ruby",
+	"perl":       "perl",
+}
+
+// Interpreter is the program test mode pipes the block's code to,
+// e.g. python3, or empty if the code runs in the shell.  The
+// attribute @interpreter={program} chooses one, else the fence
+// language might, e.g. a python block goes to python3.  Naming a
+// shell, e.g. @interpreter=bash, runs the block in the shell.
+func (x *BlockPgm) Interpreter() string {
+	if in, ok := x.Attribute(base.InterpreterAttribute); ok {
+		if isShell(in) {
+			return ""
+		}
+		return in
+	}
+	return interpreters[x.language]
+}
+
+func isShell(name string) bool {
+	switch name {
+	case "", "bash", "sh", "shell":
+		return true
+	}
+	return false
+}
+
+// Expectation is the output expected of the block, from the
+// @expected block following it, or nil if nothing's expected.
+func (x *BlockPgm) Expectation() *Expectation { return x.expected }
+
+// Tags categorizing the block, including those of its lesson.
+func (x *BlockPgm) Tags() []string { return x.tags }
+
+// SuitsArch is true if the block is for the given architecture.
+func (x *BlockPgm) SuitsArch(arch string) bool {
+	return base.SuitsArch(x.labels, arch)
+}
+
+// Timeout is how long test mode should wait for the block, via the
+// attribute @timeout={duration}, e.g. @timeout=90s, or 0 if the
+// block doesn't say.  It's an error if the duration doesn't parse.
+func (x *BlockPgm) Timeout() (time.Duration, error) {
+	t, ok := x.Attribute(base.TimeoutAttribute)
+	if !ok {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(t)
+	if err != nil {
+		return 0, fmt.Errorf("block %s: bad @%s=%s", x.Name(), base.TimeoutAttribute, t)
+	}
+	return d, nil
+}
+
+// Retries is how many more times test mode should run the block if it
+// fails, via the attribute @retries={n}, e.g. @retries=2, or 0 if the
+// block doesn't say.  It's an error if n isn't a whole number.
+func (x *BlockPgm) Retries() (int, error) {
+	r, ok := x.Attribute(base.RetriesAttribute)
+	if !ok {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(r)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("block %s: bad @%s=%s", x.Name(), base.RetriesAttribute, r)
+	}
+	return n, nil
+}
+
+// Isolated is true if test mode should run the block in a subshell,
+// via the attribute @isolation=subshell.  It's an error if the
+// attribute is neither that nor @isolation=none.
+func (x *BlockPgm) Isolated() (bool, error) {
+	switch i, _ := x.Attribute(base.IsolationAttribute); i {
+	case "", IsolationNone:
+		return false, nil
+	case IsolationSubshell:
+		return true, nil
+	default:
+		return false, fmt.Errorf("block %s: bad @%s=%s", x.Name(), base.IsolationAttribute, i)
+	}
+}
+
+// Sudo is true if the block is to run as root, via @sudo=true.
+func (x *BlockPgm) Sudo() (bool, error) {
+	s, ok := x.Attribute(base.SudoAttribute)
+	if !ok {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("block %s: bad @%s=%s", x.Name(), base.SudoAttribute, s)
+	}
+	return b, nil
+}
+
+// IsSetup is true if the block prepares its lesson, via the label @setup.
+func (x *BlockPgm) IsSetup() bool { return x.hasLabel(base.SetupLabel) }
+
+// IsTeardown is true if the block cleans up after
+// its lesson, via the label @teardown.
+func (x *BlockPgm) IsTeardown() bool { return x.hasLabel(base.TeardownLabel) }
+
+func (x *BlockPgm) hasLabel(label base.Label) bool {
+	for _, l := range x.labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// ShouldSay is true if the block's Banner should precede
+// the block when it's sent to tmux.
+func (x *BlockPgm) ShouldSay() bool { return x.shouldSay }
+
+// Banner is a one line shell comment announcing the block, e.g.
+//
+//	# --- Step 3: Create the cluster ---
+//
+// made from the last header in the block's prose, else from the
+// last line of prose, else from the block's name.
+func (x *BlockPgm) Banner() string {
+	text := x.Name()
+	lines := strings.Split(strings.TrimSpace(x.Prose().String()), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.HasPrefix(lines[i], "#") {
+			text = lines[i]
+			break
+		}
+		if i == len(lines)-1 && len(lines[i]) > 0 {
+			text = lines[i]
+		}
+	}
+	text = strings.Trim(strings.NewReplacer("*", "", "`", "").Replace(text), "# \t")
+	return "# --- " + text + " ---\n"
+}
+
+// StartsSection is true if the block's prose holds a header,
+// i.e. the block is the first in a section of a lesson.
+func (x *BlockPgm) StartsSection() bool {
+	for _, l := range strings.Split(x.Prose().String(), "\n") {
+		if strings.HasPrefix(l, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+// HTMLProse returns HTML that should precede the block, rendered
+// as GitHub would, with tables, task lists and footnotes, and with
+// admonitions styled, emoji shortcodes replaced, glossary terms
+// annotated, images made zoomable, math marked for typesetting,
+// and headings given ids for the lesson's table of contents.
 func (x *BlockPgm) HTMLProse() template.HTML {
-	return template.HTML(string(bf2.Run(x.Prose())))
+	md, math := protectMath(withFootnotes(string(x.Prose()), x.footnotes))
+	h := annotate(emojify(admonish(anchorHeadings(
+		renderMarkdown(md, fmt.Sprintf("%d-", x.line)), x.headings))), x.glossary)
+	h = decorateImages(h, x.dir)
+	return template.HTML(restoreMath(h, math))
 }
 
-// Print prints the block.
+// Print prints the block, the n'th of its lesson, as it runs
+// in test mode; see RunScript.
 func (x *BlockPgm) Print(
 	w io.Writer, prefix string, n int, label base.Label, fileName base.FilePath) {
 	fmt.Fprintf(w, "echo \"%s @%s (block #%d in %s) of %s\"\n\n",
 		prefix, x.Name(), n, label, fileName)
-	fmt.Fprint(w, x.Code())
+	fmt.Fprint(w, RunScript(n, x, fileName))
 	// Add a brief sleep at the end.
 	// This hack gives servers placed in the background time to start, assuming
 	// they can do so in the time added!  Yeah, bad.
 	if x.shouldAddSleep {
-		fmt.Fprint(w, "sleep 3s # Added by mdrip\n")
+		fmt.Fprintln(w, addedSleep)
 	}
 }
+
+// addedSleep follows blocks that should sleep after running.
+const addedSleep = "sleep 3s # Added by mdrip"
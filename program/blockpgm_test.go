@@ -2,6 +2,7 @@ package program
 
 import (
 	"testing"
+	"time"
 
 	"github.com/monopole/mdrip/base"
 	"github.com/monopole/mdrip/model"
@@ -32,3 +33,89 @@ func TestBlockPgm(t *testing.T) {
 			"          got \"%s\"", expected, got)
 	}
 }
+
+func TestBanner(t *testing.T) {
+	for _, test := range []struct {
+		labels []base.Label
+		prose  string
+		want   string
+		say    bool
+	}{
+		{[]base.Label{}, "", "# --- " + model.AnonBlockName + " ---\n", false},
+		{[]base.Label{base.SayLabel}, "Now run *this*:", "# --- Now run this: ---\n", true},
+		{[]base.Label{base.Label("make")},
+			"## Step 3: Create the `cluster`\n\nRun it:\n",
+			"# --- Step 3: Create the cluster ---\n", false},
+	} {
+		b := NewBlockPgmFromBlockTut(model.NewBlockTut(model.NewBlockParsed(
+			test.labels, base.MdProse(test.prose), base.OpaqueCode("date\n"))))
+		if got := b.Banner(); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+		if b.ShouldSay() != test.say {
+			t.Errorf("%q: got say %v, want %v", test.prose, b.ShouldSay(), test.say)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	for _, test := range []struct {
+		labels []base.Label
+		want   time.Duration
+		err    bool
+	}{
+		{[]base.Label{}, 0, false},
+		{[]base.Label{base.Label("timeout=90s")}, 90 * time.Second, false},
+		{[]base.Label{base.Label("timeout=1m30s")}, 90 * time.Second, false},
+		{[]base.Label{base.Label("timeout=soon")}, 0, true},
+	} {
+		b := NewBlockPgmFromBlockTut(model.NewBlockTut(model.NewBlockParsed(
+			test.labels, base.NoProse(), base.OpaqueCode("date\n"))))
+		got, err := b.Timeout()
+		if got != test.want || (err != nil) != test.err {
+			t.Errorf("%v: got %v, %v, want %v", test.labels, got, err, test.want)
+		}
+	}
+}
+
+func TestSudo(t *testing.T) {
+	for _, test := range []struct {
+		labels []base.Label
+		want   bool
+		err    bool
+	}{
+		{[]base.Label{}, false, false},
+		{[]base.Label{base.Label("sudo=true")}, true, false},
+		{[]base.Label{base.Label("sudo=false")}, false, false},
+		{[]base.Label{base.Label("sudo=please")}, false, true},
+	} {
+		b := NewBlockPgmFromBlockTut(model.NewBlockTut(model.NewBlockParsed(
+			test.labels, base.NoProse(), base.OpaqueCode("date\n"))))
+		got, err := b.Sudo()
+		if got != test.want || (err != nil) != test.err {
+			t.Errorf("%v: got %v, %v, want %v", test.labels, got, err, test.want)
+		}
+	}
+}
+
+func TestInterpreter(t *testing.T) {
+	for _, test := range []struct {
+		lang   string
+		labels []base.Label
+		want   string
+	}{
+		{"", []base.Label{}, ""},
+		{"bash", []base.Label{}, ""},
+		{"python", []base.Label{}, "python3"},
+		{"js", []base.Label{}, "node"},
+		{"", []base.Label{base.Label("interpreter=ruby")}, "ruby"},
+		{"python", []base.Label{base.Label("interpreter=python2")}, "python2"},
+		{"python", []base.Label{base.Label("interpreter=bash")}, ""},
+	} {
+		p := model.NewBlockParsed(test.labels, base.NoProse(), base.OpaqueCode("x\n"))
+		p.SetLanguage(test.lang)
+		if got := NewBlockPgmFromBlockTut(model.NewBlockTut(p)).Interpreter(); got != test.want {
+			t.Errorf("%q %v: got %q, want %q", test.lang, test.labels, got, test.want)
+		}
+	}
+}
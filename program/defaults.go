@@ -0,0 +1,121 @@
+package program
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monopole/mdrip/base"
+	"gopkg.in/yaml.v2"
+)
+
+// Isolations, the values of the attribute @isolation.
+const (
+	// IsolationNone runs a block in the shell running the others.
+	IsolationNone = "none"
+	// IsolationSubshell runs a block in a subshell.
+	IsolationSubshell = "subshell"
+)
+
+// Settings are how test mode runs blocks with some label, unless
+// a block's own attributes say otherwise.  Empty fields say nothing.
+type Settings struct {
+	// Timeout is how long a block may take, e.g. 10m, as @timeout says.
+	Timeout string `yaml:"timeout"`
+	// Retries is how many more times to run a failing
+	// block, as @retries says.
+	Retries int `yaml:"retries"`
+	// Isolation is none or subshell, as @isolation says.
+	Isolation string `yaml:"isolation"`
+	// Shell is the program to pipe a block to, e.g. python3,
+	// as @interpreter says.
+	Shell string `yaml:"shell"`
+}
+
+// attributes returns the settings as block attributes.
+func (s Settings) attributes() []base.Label {
+	var result []base.Label
+	add := func(key, value string) {
+		if len(value) > 0 {
+			result = append(result, base.Label(key+"="+value))
+		}
+	}
+	add(base.TimeoutAttribute, s.Timeout)
+	if s.Retries > 0 {
+		add(base.RetriesAttribute, strconv.Itoa(s.Retries))
+	}
+	add(base.IsolationAttribute, s.Isolation)
+	add(base.InterpreterAttribute, s.Shell)
+	return result
+}
+
+// LabelDefaults maps labels to the settings of blocks having them.
+type LabelDefaults map[base.Label]Settings
+
+// LoadLabelDefaults reads label defaults from a YAML file mapping
+// labels, with or without their @, to settings, e.g.
+//
+//	slow:
+//	  timeout: 10m
+//	  retries: 2
+func LoadLabelDefaults(path string) (LabelDefaults, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]Settings
+	if err := yaml.UnmarshalStrict(data, &raw); err != nil {
+		return nil, fmt.Errorf("bad label defaults in %s: %v", path, err)
+	}
+	result := LabelDefaults{}
+	for l, s := range raw {
+		if len(s.Timeout) > 0 {
+			if _, err := time.ParseDuration(s.Timeout); err != nil {
+				return nil, fmt.Errorf("label %s in %s: bad timeout %q", l, path, s.Timeout)
+			}
+		}
+		if s.Retries < 0 {
+			return nil, fmt.Errorf("label %s in %s: retries can't be negative", l, path)
+		}
+		switch s.Isolation {
+		case "", IsolationNone, IsolationSubshell:
+		default:
+			return nil, fmt.Errorf("label %s in %s: isolation is %s or %s, not %q",
+				l, path, IsolationNone, IsolationSubshell, s.Isolation)
+		}
+		result[base.Label(strings.TrimPrefix(l, "@"))] = s
+	}
+	return result, nil
+}
+
+// WithDefaults returns a copy of the program in which blocks
+// have the settings of their labels as attributes, unless
+// they have such attributes already.  For blocks with several
+// labels having defaults, the first such label's setting wins.
+func (p *Program) WithDefaults(d LabelDefaults) *Program {
+	result := &Program{p.label, make([]*LessonPgm, len(p.lessons))}
+	for i, l := range p.lessons {
+		nl := *l
+		nl.blocks = make([]*BlockPgm, len(l.blocks))
+		for j, b := range l.blocks {
+			nb := *b
+			nb.labels = append([]base.Label{}, b.labels...)
+			for _, x := range b.labels {
+				s, ok := d[x]
+				if !ok {
+					continue
+				}
+				for _, a := range s.attributes() {
+					if _, ok := base.FindAttribute(nb.labels, a.Key()); !ok {
+						nb.labels = append(nb.labels, a)
+					}
+				}
+			}
+			nl.blocks[j] = &nb
+		}
+		result.lessons[i] = &nl
+	}
+	return result
+}
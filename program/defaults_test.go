@@ -0,0 +1,86 @@
+package program
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+func TestLoadLabelDefaults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "defaults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for n, test := range map[string]struct {
+		content string
+		err     string
+	}{
+		"fine":         {"slow:\n  timeout: 10m\n  retries: 2\n'@py':\n  shell: python3\n  isolation: subshell\n", ""},
+		"badTimeout":   {"slow:\n  timeout: ages\n", "bad timeout"},
+		"badRetries":   {"slow:\n  retries: -1\n", "can't be negative"},
+		"badIsolation": {"slow:\n  isolation: vm\n", "isolation is none or subshell"},
+		"typo":         {"slow:\n  timout: 10m\n", "bad label defaults"},
+	} {
+		p := filepath.Join(dir, n+".yaml")
+		if err := ioutil.WriteFile(p, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		d, err := LoadLabelDefaults(p)
+		if len(test.err) == 0 {
+			if err != nil {
+				t.Errorf("%s: %v", n, err)
+			} else if d["py"].Shell != "python3" || d["slow"].Retries != 2 {
+				t.Errorf("%s: got %v", n, d)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, want %q", n, err, test.err)
+		}
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	block := func(labels ...base.Label) *model.BlockTut {
+		return model.NewBlockTut(model.NewBlockParsed(
+			labels, base.MdProse("prose"), base.OpaqueCode("date\n")))
+	}
+	tut := model.NewLessonTutForTests(base.FilePath("cluster.md"), []*model.BlockTut{
+		block("create", "slow"),
+		block("wait", "slow", "timeout=1h"),
+		block("flaky", "net", "slow"),
+		block("list"),
+	})
+	p := NewProgramFromTutorial(base.WildCardLabel, tut)
+	d := LabelDefaults{
+		"slow": {Timeout: "10m"},
+		"net":  {Timeout: "1m", Retries: 3, Isolation: IsolationSubshell},
+	}
+	blocks := p.WithDefaults(d).Lessons()[0].Blocks()
+	for i, want := range []string{
+		"create slow timeout=10m",
+		"wait slow timeout=1h",
+		"flaky net slow timeout=1m retries=3 isolation=subshell",
+		"list",
+	} {
+		var got []string
+		for _, l := range blocks[i].Labels() {
+			got = append(got, string(l))
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("block %d: got labels %v, want %s", i, got, want)
+		}
+	}
+	if r, _ := blocks[2].Retries(); r != 3 {
+		t.Errorf("got %d retries, want 3", r)
+	}
+	if len(p.Lessons()[0].Blocks()[0].Labels()) != 2 {
+		t.Errorf("the original program shouldn't change")
+	}
+}
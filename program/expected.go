@@ -0,0 +1,129 @@
+package program
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/monopole/mdrip/base"
+)
+
+// Ways of comparing a block's output to what's expected of it.
+const (
+	// ExpectExact wants the output, less trailing spaces and
+	// blank lines, to be the expected text.
+	ExpectExact = ""
+	// ExpectContains wants the output to contain the expected text.
+	ExpectContains = "contains"
+	// ExpectRegex wants the output to match the expected regular expression.
+	ExpectRegex = "regex"
+)
+
+// Expectation is the stdout expected of a block, from the
+// block following it in the markdown, marked @expected.
+type Expectation struct {
+	// kind is how output is compared, e.g. ExpectRegex.
+	kind string
+	text string
+}
+
+// NewExpectation is a ctor.
+func NewExpectation(kind, text string) *Expectation {
+	return &Expectation{kind, text}
+}
+
+// Kind is how output is compared to the expected text.
+func (x *Expectation) Kind() string { return x.kind }
+
+// Text expected, or the regular expression to match.
+func (x *Expectation) Text() string { return x.text }
+
+// normalizeOutput drops trailing spaces from each line,
+// and blank lines at either end.
+func normalizeOutput(s string) string {
+	lines := strings.Split(s, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " \t\r")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// Check returns nil if the output is as expected, else an
+// error saying how it differs.
+func (x *Expectation) Check(output string) error {
+	got, want := normalizeOutput(output), normalizeOutput(x.text)
+	switch x.kind {
+	case ExpectExact:
+		if got == want {
+			return nil
+		}
+		return fmt.Errorf(
+			"output differs from @%s (- expected, + actual):\n%s",
+			base.ExpectedAttribute, diffLines(want, got))
+	case ExpectContains:
+		if strings.Contains(got, want) {
+			return nil
+		}
+		return fmt.Errorf(
+			"output lacks @%s=%s text:\n%s\noutput was:\n%s",
+			base.ExpectedAttribute, x.kind, want, got)
+	case ExpectRegex:
+		re, err := regexp.Compile(want)
+		if err != nil {
+			return fmt.Errorf("bad @%s=%s: %v", base.ExpectedAttribute, x.kind, err)
+		}
+		if re.MatchString(got) {
+			return nil
+		}
+		return fmt.Errorf(
+			"output doesn't match @%s=%s %s\noutput was:\n%s",
+			base.ExpectedAttribute, x.kind, want, got)
+	default:
+		return fmt.Errorf(
+			"unknown @%s=%s; use @%s, @%s=%s or @%s=%s",
+			base.ExpectedAttribute, x.kind, base.ExpectedAttribute,
+			base.ExpectedAttribute, ExpectContains, base.ExpectedAttribute, ExpectRegex)
+	}
+}
+
+// diffLines returns a line by line diff, from the longest common
+// subsequence of lines, marking lines only in a with "- ", lines
+// only in b with "+ ", and lines in both with two spaces.
+func diffLines(a, b string) string {
+	x, y := strings.Split(a, "\n"), strings.Split(b, "\n")
+	// lcs[i][j] is the length of the longest common
+	// subsequence of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			switch {
+			case x[i] == y[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			sb.WriteString("  " + x[i] + "\n")
+			i++
+			j++
+		case j == len(y) || (i < len(x) && lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("- " + x[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+ " + y[j] + "\n")
+			j++
+		}
+	}
+	return sb.String()
+}
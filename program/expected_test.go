@@ -0,0 +1,35 @@
+package program
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpectationCheck(t *testing.T) {
+	for _, test := range []struct {
+		kind, text, output string
+		wantErr            string
+	}{
+		{ExpectExact, "hello\nworld\n", "hello  \nworld\n\n", ""},
+		{ExpectExact, "hello\nworld\n", "hello\nthere\nworld\n", "  hello\n+ there\n  world\n"},
+		{ExpectExact, "a\nb\n", "a\nc\n", "  a\n- b\n+ c\n"},
+		{ExpectContains, "ready", "server is ready\n", ""},
+		{ExpectContains, "ready", "server failed\n", "lacks @expected=contains"},
+		{ExpectRegex, `^v\d+\.\d+`, "v1.22\n", ""},
+		{ExpectRegex, `^v\d+\.\d+`, "version 1\n", "doesn't match @expected=regex"},
+		{ExpectRegex, `(`, "", "bad @expected=regex"},
+		{"fuzzy", "x", "x", "unknown @expected=fuzzy"},
+	} {
+		err := NewExpectation(test.kind, test.text).Check(test.output)
+		if len(test.wantErr) == 0 {
+			if err != nil {
+				t.Errorf("%q vs %q: unexpected error %v", test.text, test.output, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%q vs %q: got error %v, want one holding %q",
+				test.text, test.output, err, test.wantErr)
+		}
+	}
+}
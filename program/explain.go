@@ -0,0 +1,188 @@
+package program
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+// Explanation says whether print and test mode would extract
+// a block, and which filter decided it.
+type Explanation struct {
+	path     base.FilePath
+	index    int
+	block    *model.BlockTut
+	reasons  []string
+	selected bool
+}
+
+// Path of the lesson holding the block.
+func (x *Explanation) Path() base.FilePath { return x.path }
+
+// Index of the block among the code blocks of its lesson, counting from 1.
+func (x *Explanation) Index() int { return x.index }
+
+// Block explained.
+func (x *Explanation) Block() *model.BlockTut { return x.block }
+
+// Reasons are the verdicts of each filter, in the order applied.
+func (x *Explanation) Reasons() []string { return x.reasons }
+
+// Selected is true if the block would be extracted.
+func (x *Explanation) Selected() bool { return x.selected }
+
+func formatLabels(labels []base.Label) string {
+	if len(labels) == 0 {
+		return "no labels"
+	}
+	s := make([]string, len(labels))
+	for i, l := range labels {
+		s[i] = "@" + string(l)
+	}
+	return strings.Join(s, " ")
+}
+
+// explainBlock applies, to one block, the filters LessonPgmExtractor does.
+func explainBlock(p base.FilePath, i int, b *model.BlockTut,
+	label base.Label, arch string) *Explanation {
+	x := &Explanation{p, i, b, []string{}, true}
+	if _, ok := base.FindExpectation(b.Labels()); ok {
+		x.reasons = append(x.reasons, "expected: @"+base.ExpectedAttribute+
+			" marks the output expected of the block before it, not code")
+		x.selected = false
+		return x
+	}
+	x.reasons, x.selected = ExplainLabels(b.Labels(), label, arch)
+	return x
+}
+
+// ExplainLabels says whether print and test mode, given the
+// label and architecture, would extract a code block having
+// the given labels, with the verdict of each filter.
+func ExplainLabels(labels []base.Label, label base.Label, arch string) (
+	reasons []string, selected bool) {
+	selected = true
+	name := strings.TrimPrefix(string(label), "@")
+	switch {
+	case label == base.WildCardLabel:
+		reasons = append(reasons, "label: no --label given, so any block matches")
+	case label.IsExpression() && label.Selects(labels):
+		reasons = append(reasons, fmt.Sprintf(
+			"label: %s matches %s", formatLabels(labels), label))
+	case label.IsExpression():
+		reasons = append(reasons, fmt.Sprintf(
+			"label: %s doesn't match %s", formatLabels(labels), label))
+		selected = false
+	case label.Selects(labels):
+		reasons = append(reasons, "label: has @"+name)
+	default:
+		reasons = append(reasons, fmt.Sprintf(
+			"label: lacks @%s, having %s", name, formatLabels(labels)))
+		selected = false
+	}
+	if hook := findHook(labels); !selected && len(hook) > 0 {
+		reasons = append(reasons, "label: @"+string(hook)+
+			" blocks are kept with any other block of their lesson")
+		selected = true
+	}
+	list, ok := base.FindAttribute(labels, base.ArchAttribute)
+	switch {
+	case !ok:
+		reasons = append(reasons, "arch: no @"+base.ArchAttribute+", so it suits any architecture")
+	case len(arch) == 0:
+		reasons = append(reasons, "arch: --arch is empty, so @"+base.ArchAttribute+" is ignored")
+	case base.SuitsArch(labels, arch):
+		reasons = append(reasons, fmt.Sprintf(
+			"arch: @%s=%s includes %s", base.ArchAttribute, list, arch))
+	default:
+		reasons = append(reasons, fmt.Sprintf(
+			"arch: @%s=%s excludes %s", base.ArchAttribute, list, arch))
+		selected = false
+	}
+	return reasons, selected
+}
+
+// findHook returns the setup or teardown label among the labels, if any.
+func findHook(labels []base.Label) base.Label {
+	for _, l := range labels {
+		if l == base.SetupLabel || l == base.TeardownLabel {
+			return l
+		}
+	}
+	return ""
+}
+
+// lessonCollector gathers every lesson of a tutorial in depth first order.
+type lessonCollector struct {
+	lessons []*model.LessonTut
+}
+
+func (v *lessonCollector) VisitBlockTut(b *model.BlockTut) {}
+
+func (v *lessonCollector) VisitLessonTut(l *model.LessonTut) {
+	v.lessons = append(v.lessons, l)
+}
+
+func (v *lessonCollector) VisitCourse(c *model.Course) {
+	for _, x := range c.Children() {
+		x.Accept(v)
+	}
+}
+
+func (v *lessonCollector) VisitTopCourse(t *model.TopCourse) {
+	v.VisitCourse(&t.Course)
+}
+
+// Explain explains, for the blocks of the tutorial matching which,
+// whether they'd be extracted with the given label and architecture.
+// If which is a number n, it matches the nth code block of each lesson,
+// counting from 1; otherwise it matches blocks with that label.
+func Explain(t model.Tutorial, which string, label base.Label, arch string) (
+	[]*Explanation, error) {
+	v := &lessonCollector{}
+	t.Accept(v)
+	n, err := strconv.Atoi(which)
+	if err != nil {
+		n = 0
+	}
+	result := []*Explanation{}
+	for _, l := range v.lessons {
+		i := 0
+		for _, b := range l.Blocks() {
+			if len(b.Code()) == 0 {
+				continue
+			}
+			i++
+			if (n > 0 && i == n) || (n == 0 && b.HasLabel(base.Label(which))) {
+				result = append(result, explainBlock(l.Path(), i, b, label, arch))
+			}
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no code block matches %q", which)
+	}
+	return result, nil
+}
+
+// PrintExplanations writes the explanations in a human readable form.
+func PrintExplanations(w io.Writer, explanations []*Explanation) {
+	for _, x := range explanations {
+		where := fmt.Sprintf("%s#%d", x.Path(), x.Index())
+		if x.Block().Line() > 0 {
+			where += fmt.Sprintf(" (line %d)", x.Block().Line())
+		}
+		fmt.Fprintf(w, "%s  %s\n", where, formatLabels(x.Block().Labels()))
+		for _, r := range x.Reasons() {
+			fmt.Fprintf(w, "  %s\n", r)
+		}
+		if x.Selected() {
+			fmt.Fprintln(w, "  => selected")
+		} else {
+			fmt.Fprintln(w, "  => not selected")
+		}
+	}
+}
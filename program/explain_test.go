@@ -0,0 +1,53 @@
+package program
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+func TestExplain(t *testing.T) {
+	block := func(labels ...base.Label) *model.BlockTut {
+		return model.NewBlockTut(model.NewBlockParsed(
+			labels, base.MdProse("prose"), base.OpaqueCode("date\n")))
+	}
+	tut := model.NewLessonTutForTests(base.FilePath("download.md"), []*model.BlockTut{
+		block("any", "test"),
+		block("arm", "test", "arch=arm64"),
+		block("intel", "arch=amd64"),
+		block("cleanup", "teardown"),
+	})
+	for _, test := range []struct {
+		which    string
+		label    base.Label
+		selected bool
+		reason   string
+	}{
+		{"1", base.Label("test"), true, "label: has @test"},
+		{"arm", base.Label("test"), false, "arch: @arch=arm64 excludes amd64"},
+		{"3", base.Label("test"), false, "label: lacks @test, having @intel @arch=amd64"},
+		{"intel", base.WildCardLabel, true, "arch: @arch=amd64 includes amd64"},
+		{"1", base.Label("test && !intel"), true, "label: @any @test matches test && !intel"},
+		{"intel", base.Label("any || test"), false, "label: @intel @arch=amd64 doesn't match any || test"},
+		{"cleanup", base.Label("test"), true, "label: @teardown blocks are kept with any other block"},
+	} {
+		got, err := Explain(tut, test.which, test.label, "amd64")
+		if err != nil {
+			t.Fatalf("%s: %v", test.which, err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("%s: got %d explanations, want 1", test.which, len(got))
+		}
+		if got[0].Selected() != test.selected {
+			t.Errorf("%s: got selected %v, want %v", test.which, got[0].Selected(), test.selected)
+		}
+		if !strings.Contains(strings.Join(got[0].Reasons(), "\n"), test.reason) {
+			t.Errorf("%s: got reasons %v, want %q", test.which, got[0].Reasons(), test.reason)
+		}
+	}
+	if _, err := Explain(tut, "nonesuch", base.WildCardLabel, ""); err == nil {
+		t.Errorf("expected an error for an unknown block")
+	}
+}
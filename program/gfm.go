@@ -0,0 +1,86 @@
+package program
+
+import (
+	"regexp"
+	"strings"
+
+	bf2 "gopkg.in/russross/blackfriday.v2"
+)
+
+// markdownExtensions are blackfriday's common extensions - tables,
+// fenced code, autolinks, strikethrough and so on - plus footnotes,
+// so prose renders as GitHub renders it.
+const markdownExtensions = bf2.CommonExtensions | bf2.Footnotes
+
+// renderMarkdown renders a block's prose as HTML, as GitHub would.
+// Footnote anchors get the given prefix, so those of the several
+// blocks on a page don't collide.
+func renderMarkdown(md, prefix string) string {
+	r := bf2.NewHTMLRenderer(bf2.HTMLRendererParameters{
+		Flags:                      bf2.CommonHTMLFlags | bf2.FootnoteReturnLinks,
+		FootnoteAnchorPrefix:       prefix,
+		FootnoteReturnLinkContents: "&#x21a9;",
+	})
+	return taskList(string(bf2.Run(
+		[]byte(md), bf2.WithExtensions(markdownExtensions), bf2.WithRenderer(r))))
+}
+
+// taskItem matches a rendered list item starting with
+// a GitHub task list marker, e.g. "- [ ] write docs".
+var taskItem = regexp.MustCompile(`<li>(<p>)?\[([ xX])\]\s+`)
+
+// taskList renders task list markers as checkboxes, checked
+// if done, that readers can't change, as GitHub does.
+func taskList(h string) string {
+	return taskItem.ReplaceAllStringFunc(h, func(m string) string {
+		p, done := "", taskItem.FindStringSubmatch(m)
+		if len(done[1]) > 0 {
+			p = "<p>"
+		}
+		checked := ""
+		if done[2] != " " {
+			checked = " checked"
+		}
+		return `<li class="taskListItem">` + p +
+			`<input type="checkbox" disabled` + checked + `> `
+	})
+}
+
+// footnoteDef matches a footnote's definition, e.g.
+// "[^note]: some text", with any indented lines continuing it.
+var footnoteDef = regexp.MustCompile(`(?m)^\[\^([^\]\s]+)\]:.*(\n(    |\t).*)*`)
+
+// footnoteRef matches a reference to a footnote, e.g. "[^note]".
+var footnoteRef = regexp.MustCompile(`\[\^([^\]\s]+)\]`)
+
+// footnoteDefs returns the footnotes defined in the
+// given markdown, their definitions by name.
+func footnoteDefs(md string) map[string]string {
+	result := map[string]string{}
+	for _, m := range footnoteDef.FindAllStringSubmatch(md, -1) {
+		result[m[1]] = m[0]
+	}
+	return result
+}
+
+// withFootnotes appends to a block's prose the definitions of the
+// footnotes it refers to but doesn't define.  A lesson's prose is
+// split among its blocks, and footnotes are usually defined at the
+// end of the file, far from the blocks referring to them.
+func withFootnotes(md string, defs map[string]string) string {
+	if len(defs) == 0 {
+		return md
+	}
+	here := footnoteDefs(md)
+	var add []string
+	for _, m := range footnoteRef.FindAllStringSubmatch(md, -1) {
+		if d, ok := defs[m[1]]; ok && len(here[m[1]]) == 0 {
+			add = append(add, d)
+			here[m[1]] = d
+		}
+	}
+	if len(add) == 0 {
+		return md
+	}
+	return strings.TrimRight(md, "\n") + "\n\n" + strings.Join(add, "\n") + "\n"
+}
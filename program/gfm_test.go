@@ -0,0 +1,46 @@
+package program
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGitHubFlavor(t *testing.T) {
+	got := string(makeBlockWithProse(
+		"| flag | default |\n|---|---|\n| --port | 8000 |\n\n" +
+			"- [x] install\n- [ ] configure\n\n" +
+			"Ports may clash[^ports].\n\n[^ports]: Pick another one.\n").HTMLProse())
+	for _, want := range []string{
+		"<th>flag</th>",
+		"<td>8000</td>",
+		`<li class="taskListItem"><input type="checkbox" disabled checked> install</li>`,
+		`<li class="taskListItem"><input type="checkbox" disabled> configure</li>`,
+		`<a rel="footnote" href="#fn:0-ports">1</a>`,
+		`<li id="fn:0-ports">Pick another one.`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got\n%s\nwant it to hold\n%s", got, want)
+		}
+	}
+}
+
+func TestWithFootnotes(t *testing.T) {
+	defs := footnoteDefs("The end.\n\n[^a]: Alpha,\n    continued.\n[^b]: Beta.\n")
+	if len(defs) != 2 || defs["a"] != "[^a]: Alpha,\n    continued." {
+		t.Fatalf("got %q", defs)
+	}
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"No notes.\n", "No notes.\n"},
+		{"See[^b] and[^a], again[^b].\n", "See[^b] and[^a], again[^b].\n\n[^b]: Beta.\n[^a]: Alpha,\n    continued.\n"},
+		{"See[^a].\n\n[^a]: Here.\n", "See[^a].\n\n[^a]: Here.\n"},
+		{"See[^zebra].\n", "See[^zebra].\n"},
+	}
+	for _, test := range tests {
+		if got := withFootnotes(test.input, defs); got != test.want {
+			t.Errorf("withFootnotes(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package program
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/monopole/mdrip/model"
+)
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+var tagName = regexp.MustCompile(`^</?([a-zA-Z0-9]+)`)
+
+// Text inside these elements isn't annotated with glossary terms.
+var glossarySkips = map[string]bool{
+	"a": true, "code": true, "pre": true, "abbr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// termMatcher matches any of the glossary's terms as whole words,
+// ignoring case, preferring longer terms.
+func termMatcher(g model.Glossary) *regexp.Regexp {
+	terms := g.Terms()
+	sort.SliceStable(terms, func(i, j int) bool {
+		return len(terms[i]) > len(terms[j])
+	})
+	for i, t := range terms {
+		terms[i] = regexp.QuoteMeta(html.EscapeString(t))
+	}
+	return regexp.MustCompile(`(?i)\b(` + strings.Join(terms, "|") + `)\b`)
+}
+
+// annotate wraps the first occurrence of each glossary term found in
+// the text of the given HTML in an abbr element whose title is the
+// term's definition, so browsers show the definition as a tooltip.
+func annotate(h string, g model.Glossary) string {
+	if len(g) == 0 {
+		return h
+	}
+	lower := map[string]string{}
+	for t, d := range g {
+		lower[strings.ToLower(html.EscapeString(t))] = d
+	}
+	matcher := termMatcher(g)
+	seen := map[string]bool{}
+	return mapText(h, glossarySkips, func(s string) string {
+		return matcher.ReplaceAllStringFunc(s, func(m string) string {
+			k := strings.ToLower(m)
+			if seen[k] {
+				return m
+			}
+			seen[k] = true
+			return `<abbr class="glossaryTerm" title="` +
+				html.EscapeString(lower[k]) + `">` + m + `</abbr>`
+		})
+	})
+}
+
+// mapText applies f to the text of the given HTML, outside of tags,
+// and outside of the elements named in skip.
+func mapText(h string, skip map[string]bool, f func(string) string) string {
+	skipDepth := 0
+	var b strings.Builder
+	text := func(s string) {
+		if skipDepth > 0 {
+			b.WriteString(s)
+			return
+		}
+		b.WriteString(f(s))
+	}
+	last := 0
+	for _, loc := range htmlTag.FindAllStringIndex(h, -1) {
+		text(h[last:loc[0]])
+		tag := h[loc[0]:loc[1]]
+		if m := tagName.FindStringSubmatch(tag); m != nil &&
+			skip[strings.ToLower(m[1])] {
+			if strings.HasPrefix(tag, "</") {
+				if skipDepth > 0 {
+					skipDepth--
+				}
+			} else {
+				skipDepth++
+			}
+		}
+		b.WriteString(tag)
+		last = loc[1]
+	}
+	text(h[last:])
+	return b.String()
+}
@@ -0,0 +1,37 @@
+package program
+
+import (
+	"testing"
+
+	"github.com/monopole/mdrip/model"
+)
+
+func TestAnnotate(t *testing.T) {
+	g := model.Glossary{
+		"pod":         "a group of containers",
+		"Pod network": "how pods talk",
+	}
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty", "", ""},
+		{"firstOnly",
+			"<p>A Pod, then a pod.</p>",
+			`<p>A <abbr class="glossaryTerm" title="a group of containers">Pod</abbr>, then a pod.</p>`},
+		{"longestWins",
+			"<p>The pod network.</p>",
+			`<p>The <abbr class="glossaryTerm" title="how pods talk">pod network</abbr>.</p>`},
+		{"wholeWords", "<p>pods</p>", "<p>pods</p>"},
+		{"skipCode",
+			"<p><code>pod</code> pod</p>",
+			`<p><code>pod</code> <abbr class="glossaryTerm" title="a group of containers">pod</abbr></p>`},
+		{"skipAttributes", `<p><img alt="pod"></p>`, `<p><img alt="pod"></p>`},
+	}
+	for _, test := range tests {
+		if got := annotate(test.input, g); got != test.want {
+			t.Errorf("%s:\ngot\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package program
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/monopole/mdrip/base"
+)
+
+// Heading is a section heading, h2 or h3, in a lesson's prose,
+// for a table of contents within the lesson.
+type Heading struct {
+	level int
+	text  string
+	id    string
+}
+
+// Level of the heading, 2 or 3.
+func (h Heading) Level() int { return h.level }
+
+// Text of the heading, without markup.
+func (h Heading) Text() string { return h.text }
+
+// ID of the heading's element, unique on the page, for anchors.
+func (h Heading) ID() string { return h.id }
+
+// Headings of the lesson, in order.
+func (l *LessonPgm) Headings() []Heading { return l.headings }
+
+// Anchors maps ids the lesson's headings had, per its front matter,
+// to those they have now, so links to a renamed heading still land
+// on it.  Ids naming no heading now are left out.
+func (l *LessonPgm) Anchors() map[string]string {
+	ids := map[string]bool{}
+	for _, h := range l.headings {
+		ids[h.id] = true
+	}
+	result := map[string]string{}
+	for old, id := range l.anchors {
+		if ids[id] {
+			result[old] = id
+		}
+	}
+	return result
+}
+
+// CheckAnchors describes each mistake in the program's heading ids:
+// an id given to two headings, e.g. by {#id}, and an anchor in front
+// matter naming no heading, so that links to it land nowhere.
+func (p *Program) CheckAnchors() []string {
+	var result []string
+	seen := map[string]base.FilePath{}
+	for _, l := range p.lessons {
+		for _, h := range l.headings {
+			if first, ok := seen[h.id]; ok {
+				result = append(result, fmt.Sprintf(
+					"%s: heading id %s is already that of a heading in %s", l.path, h.id, first))
+				continue
+			}
+			seen[h.id] = l.path
+		}
+	}
+	for _, l := range p.lessons {
+		anchors := l.Anchors()
+		var old []string
+		for x := range l.anchors {
+			if _, ok := anchors[x]; !ok {
+				old = append(old, x)
+			}
+		}
+		sort.Strings(old)
+		for _, x := range old {
+			result = append(result, fmt.Sprintf(
+				"%s: anchor %s leads to %s, but no heading has that id", l.path, x, l.anchors[x]))
+		}
+	}
+	return result
+}
+
+// atxHeading matches an h2 or h3 header line,
+// e.g. "## Install the tools", with any custom id.
+var atxHeading = regexp.MustCompile(
+	`^(#{2,3})[ \t]+(.*?)[ \t#]*?(\{#([^}\s]+)\})?[ \t]*$`)
+
+// mdLink matches a markdown link or image, e.g. "[text](url)".
+var mdLink = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+
+// nonSlug matches runs of characters that don't belong in an id.
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// findHeadings returns the h2 and h3 headings in the given prose,
+// skipping fenced code.  Each gets an id made of the prefix and
+// its text, or its custom id, e.g. "## Go {#golang}".  The seen
+// map holds the ids given so far, so that no two are the same.
+func findHeadings(md, prefix string, seen map[string]bool) []Heading {
+	var result []Heading
+	fenced := false
+	for _, line := range strings.Split(md, "\n") {
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			fenced = !fenced
+			continue
+		}
+		m := atxHeading.FindStringSubmatch(line)
+		if fenced || m == nil {
+			continue
+		}
+		text := strings.NewReplacer("*", "", "`", "").Replace(
+			mdLink.ReplaceAllString(m[2], "$1"))
+		id := m[4]
+		if len(id) == 0 {
+			id = uniqueID(prefix+strings.Trim(
+				nonSlug.ReplaceAllString(strings.ToLower(text), "-"), "-"), seen)
+		}
+		seen[id] = true
+		result = append(result, Heading{len(m[1]), strings.TrimSpace(text), id})
+	}
+	return result
+}
+
+func uniqueID(id string, seen map[string]bool) string {
+	if !seen[id] {
+		return id
+	}
+	for i := 2; ; i++ {
+		if x := fmt.Sprintf("%s-%d", id, i); !seen[x] {
+			return x
+		}
+	}
+}
+
+// blockAnchor returns the id of the block's element on the page,
+// from its @name, if it has one, else from the given path of its
+// lesson and its index, which don't change as other blocks do.
+func blockAnchor(b *BlockPgm, path string) string {
+	if n, ok := b.Attribute(base.NameAttribute); ok && len(n) > 0 {
+		return "block-" + n
+	}
+	return fmt.Sprintf("block-%s-%d", path, b.index)
+}
+
+// renderedHeading matches an h2 or h3 start tag rendered from
+// markdown, with an id if the header gave one.
+var renderedHeading = regexp.MustCompile(`<h([23])( id="[^"]*")?>`)
+
+// anchorHeadings gives the rendered headings, in order,
+// the ids of the given ones, so tables of contents can
+// link to them.
+func anchorHeadings(h string, headings []Heading) string {
+	i := 0
+	return renderedHeading.ReplaceAllStringFunc(h, func(m string) string {
+		if i >= len(headings) {
+			return m
+		}
+		x := headings[i]
+		i++
+		if strings.Contains(m, " id=") {
+			return m
+		}
+		return fmt.Sprintf(`<h%d id="%s">`, x.level, x.id)
+	})
+}
@@ -0,0 +1,100 @@
+package program
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+func TestFindHeadings(t *testing.T) {
+	seen := map[string]bool{}
+	got := findHeadings("# Title\n\n## Install `kubectl` ##\n\n```\n## not a heading\n```\n"+
+		"### See [the docs](http://x.io)\n\n## Go {#golang}\n\n## Install kubectl\n",
+		"setup--", seen)
+	want := []Heading{
+		{2, "Install kubectl", "setup--install-kubectl"},
+		{3, "See the docs", "setup--see-the-docs"},
+		{2, "Go", "golang"},
+		{2, "Install kubectl", "setup--install-kubectl-2"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %+v, want %+v", got[i], want[i])
+		}
+	}
+}
+
+func TestLessonHeadings(t *testing.T) {
+	md := model.NewMdContent()
+	md.Blocks = []*model.BlockParsed{
+		model.NewBlockParsed(base.NoLabels(),
+			base.MdProse("# Setup\n\n## Get the tools\n"), base.OpaqueCode("date\n")),
+		model.NewBlockParsed(base.NoLabels(),
+			base.MdProse("## Check {#check}\n\n### Versions\n"), base.OpaqueCode("ls\n")),
+	}
+	tut := model.NewLessonTutFromMdContent(base.FilePath("setup.md"), md)
+	l := NewProgramFromTutorial(base.WildCardLabel, tut).Lessons()[0]
+	var ids []string
+	for _, h := range l.Headings() {
+		ids = append(ids, h.ID())
+	}
+	if got := strings.Join(ids, " "); got != "setup--get-the-tools check setup--versions" {
+		t.Errorf("got ids %s", got)
+	}
+	got := string(l.Blocks()[1].HTMLProse())
+	for _, want := range []string{`<h2 id="check">Check</h2>`, `<h3 id="setup--versions">Versions</h3>`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got\n%s\nwant it to hold\n%s", got, want)
+		}
+	}
+}
+
+func TestCheckAnchors(t *testing.T) {
+	lesson := func(path, prose string, anchors map[string]string) *model.LessonTut {
+		md := model.NewMdContent()
+		md.SetFrontMatter(&model.FrontMatter{Anchors: anchors})
+		md.Blocks = []*model.BlockParsed{
+			model.NewBlockParsed(base.NoLabels(), base.MdProse(prose), base.OpaqueCode("date\n")),
+		}
+		return model.NewLessonTutFromMdContent(base.FilePath(path), md)
+	}
+	tut := model.NewTopCourse("top", base.FilePath("top"), []model.Tutorial{
+		lesson("setup.md", "## Get the tools {#tools}\n",
+			map[string]string{"setup--get-tools": "tools", "setup--old": "gone"}),
+		lesson("usage.md", "## Tools {#tools}\n", nil),
+	})
+	p := NewProgramFromTutorial(base.WildCardLabel, tut)
+	if got := p.Lessons()[0].Anchors(); len(got) != 1 || got["setup--get-tools"] != "tools" {
+		t.Errorf("got anchors %v", got)
+	}
+	got := p.CheckAnchors()
+	want := []string{
+		"usage.md: heading id tools is already that of a heading in setup.md",
+		"setup.md: anchor setup--old leads to gone, but no heading has that id",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestBlockAnchor(t *testing.T) {
+	md := model.NewMdContent()
+	md.Blocks = []*model.BlockParsed{
+		model.NewBlockParsed([]base.Label{"name=create-cluster"}, base.MdProse("prose"), base.OpaqueCode("date\n")),
+		model.NewBlockParsed([]base.Label{"skip"}, base.MdProse("prose"), base.OpaqueCode("ls\n")),
+		model.NewBlockParsed(base.NoLabels(), base.MdProse("prose"), base.OpaqueCode("pwd\n")),
+	}
+	tut := model.NewLessonTutFromMdContent(base.FilePath("setup.md"), md)
+	var got []string
+	for _, b := range NewProgramFromTutorial(base.WildCardLabel, tut).Lessons()[0].Blocks() {
+		got = append(got, b.Anchor())
+	}
+	if strings.Join(got, " ") != "block-create-cluster block-setup-2 block-setup-3" {
+		t.Errorf("got anchors %v", got)
+	}
+}
@@ -0,0 +1,96 @@
+package program
+
+import (
+	"html"
+	"image"
+	// Register decoders, to learn image sizes.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AssetPath is the server path serving local images; the
+// query parameter AssetParam holds the image's file path.
+const (
+	AssetPath  = "/_/asset"
+	AssetParam = "p"
+)
+
+var imgTag = regexp.MustCompile(`<img\s[^>]*>`)
+var imgSrc = regexp.MustCompile(`\ssrc="([^"]*)"`)
+
+// isRelative is true if the image source is a path relative
+// to the lesson, rather than a URL or an absolute path.
+func isRelative(src string) bool {
+	if strings.HasPrefix(src, "/") || strings.HasPrefix(src, "data:") {
+		return false
+	}
+	u, err := url.Parse(src)
+	return err == nil && len(u.Scheme) == 0 && len(u.Host) == 0
+}
+
+// isWebDir is true if the lesson directory is an http(s) URL.
+func isWebDir(dir string) bool {
+	return strings.HasPrefix(dir, "http://") || strings.HasPrefix(dir, "https://")
+}
+
+// lessonDir is the directory, or for lessons fetched
+// over http the URL, against which image paths resolve.
+func lessonDir(p string) string {
+	if isWebDir(p) {
+		return p[:strings.LastIndex(p, "/")+1]
+	}
+	return filepath.Dir(p)
+}
+
+// imageSize returns the width and height of an image file.
+func imageSize(path string) (int, int, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	defer f.Close()
+	c, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, false
+	}
+	return c.Width, c.Height, true
+}
+
+// decorateImages makes images lazily loaded and zoomable.  Images
+// with paths relative to the lesson's directory are served from
+// AssetPath, and given their width and height as hints, so the
+// page doesn't jump around as they load.
+func decorateImages(h string, dir string) string {
+	return imgTag.ReplaceAllStringFunc(h, func(tag string) string {
+		attrs := ` class="zoomable" loading="lazy"`
+		m := imgSrc.FindStringSubmatch(tag)
+		if m != nil && len(dir) > 0 {
+			src := html.UnescapeString(m[1])
+			if isRelative(src) && isWebDir(dir) {
+				// Lessons fetched over http keep their images there.
+				if base, err := url.Parse(dir); err == nil {
+					if ref, err := url.Parse(src); err == nil {
+						tag = strings.Replace(tag, m[0], ` src="`+
+							html.EscapeString(base.ResolveReference(ref).String())+`"`, 1)
+					}
+				}
+			} else if isRelative(src) {
+				p := filepath.Join(dir, filepath.FromSlash(src))
+				tag = strings.Replace(tag, m[0], ` src="`+html.EscapeString(
+					AssetPath+"?"+AssetParam+"="+url.QueryEscape(p))+`"`, 1)
+				if w, ht, ok := imageSize(p); ok {
+					attrs += ` width="` + strconv.Itoa(w) +
+						`" height="` + strconv.Itoa(ht) + `"`
+				}
+			}
+		}
+		return "<img" + attrs + tag[len("<img"):]
+	})
+}
@@ -0,0 +1,48 @@
+package program
+
+import (
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecorateImages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdrip-images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f, err := os.Create(filepath.Join(dir, "shot.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	png.Encode(f, image.NewGray(image.Rect(0, 0, 40, 30)))
+	f.Close()
+
+	got := decorateImages(`<p><img src="shot.png" alt="a shot" /></p>`, dir)
+	for _, want := range []string{
+		`class="zoomable" loading="lazy" width="40" height="30"`,
+		`src="` + AssetPath + `?` + AssetParam + `=`,
+		`alt="a shot"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got\n%s\nwant it to hold\n%s", got, want)
+		}
+	}
+	remote := `<img src="https://example.com/a.png" alt="" />`
+	got = decorateImages(remote, dir)
+	if !strings.Contains(got, `src="https://example.com/a.png"`) ||
+		!strings.Contains(got, `loading="lazy"`) ||
+		strings.Contains(got, "width=") {
+		t.Errorf("remote images should only be made lazy and zoomable, got %s", got)
+	}
+	got = decorateImages(`<img src="img/a.png" alt="" />`,
+		lessonDir("https://example.com/docs/README.md"))
+	if !strings.Contains(got, `src="https://example.com/docs/img/a.png"`) {
+		t.Errorf("images of web lessons should resolve against the URL, got %s", got)
+	}
+}
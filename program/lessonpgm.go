@@ -3,20 +3,93 @@ package program
 import (
 	"fmt"
 	"io"
+	"path/filepath"
 	"strings"
 
 	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
 )
 
 // LessonPgm has a one to one correspondence to a file.
 type LessonPgm struct {
-	path   base.FilePath
-	blocks []*BlockPgm
+	path     base.FilePath
+	blocks   []*BlockPgm
+	requires []string
+	prereqs  []*Prerequisite
+	// tags are the lesson's tags, and those of all its blocks.
+	tags   []string
+	author string
+	// revision is the last change to the lesson's file; nil if unknown.
+	revision *model.Revision
+	// vars describe the variables the lesson's blocks use.
+	vars map[string]string
+	// headings are the h2 and h3 headings in the lesson's prose.
+	headings []Heading
+	// anchors map old heading ids to new ones, from front matter.
+	anchors map[string]string
 }
 
 // NewLessonPgm is a ctor.
 func NewLessonPgm(p base.FilePath, blocks []*BlockPgm) *LessonPgm {
-	return &LessonPgm{p, blocks}
+	return &LessonPgm{p, blocks, []string{}, []*Prerequisite{}, []string{}, "", nil, nil, nil, nil}
+}
+
+// Author of the lesson, from its front matter; empty if unknown.
+func (l *LessonPgm) Author() string { return l.author }
+
+// Revision is the last change to the lesson's file, per git; nil if unknown.
+func (l *LessonPgm) Revision() *model.Revision { return l.revision }
+
+// Vars maps the names of variables the lesson's blocks use
+// to descriptions of them, from its front matter.
+func (l *LessonPgm) Vars() map[string]string { return l.vars }
+
+// Tags of the lesson, and of all its blocks.
+func (l *LessonPgm) Tags() []string { return l.tags }
+
+// Prerequisite is a lesson to complete before another.
+type Prerequisite struct {
+	name  string
+	index int
+}
+
+// Name of the required lesson, as given in front matter.
+func (p *Prerequisite) Name() string { return p.name }
+
+// Index of the required lesson in the list of lessons,
+// or -1 if no lesson has the name.
+func (p *Prerequisite) Index() int { return p.index }
+
+// Found is true if a lesson has the prerequisite's name.
+func (p *Prerequisite) Found() bool { return p.index >= 0 }
+
+// Prerequisites of the lesson.
+func (l *LessonPgm) Prerequisites() []*Prerequisite { return l.prereqs }
+
+// isNamed is true if the lesson answers to the given name,
+// e.g. "install", "setup/install" or "setup/install.md".
+func (l *LessonPgm) isNamed(n string) bool {
+	n = strings.TrimSuffix(n, ".md")
+	p := strings.TrimSuffix(filepath.ToSlash(string(l.path)), ".md")
+	return l.Name() == n || p == n || strings.HasSuffix(p, "/"+n)
+}
+
+// resolvePrerequisites finds, for each lesson, the
+// indices of the lessons it requires.
+func resolvePrerequisites(lessons []*LessonPgm) {
+	for _, l := range lessons {
+		l.prereqs = []*Prerequisite{}
+		for _, n := range l.requires {
+			p := &Prerequisite{n, -1}
+			for i, other := range lessons {
+				if other != l && other.isNamed(n) {
+					p.index = i
+					break
+				}
+			}
+			l.prereqs = append(l.prereqs, p)
+		}
+	}
 }
 
 // Name of the LessonPgm.
@@ -28,6 +101,18 @@ func (l *LessonPgm) Path() base.FilePath { return l.path }
 // Blocks is all the code blocks extracted from the markdown.
 func (l *LessonPgm) Blocks() []*BlockPgm { return l.blocks }
 
+// SectionEnd returns the index one past the last block of the
+// section holding the block at index i.  A section starts at a
+// block whose prose holds a header, and runs until the next one.
+func (l *LessonPgm) SectionEnd(i int) int {
+	for j := i + 1; j < len(l.blocks); j++ {
+		if l.blocks[j].StartsSection() {
+			return j
+		}
+	}
+	return len(l.blocks)
+}
+
 // Print sends contents to the given Writer.
 //
 // If n <= 0, print everything, else only print the first n blocks.
@@ -0,0 +1,339 @@
+package program
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+func makeBlockWithProse(prose string) *BlockPgm {
+	return NewBlockPgmFromBlockTut(model.NewBlockTut(model.NewBlockParsed(
+		base.NoLabels(), base.MdProse(prose), base.OpaqueCode("date\n"))))
+}
+
+func TestSectionEnd(t *testing.T) {
+	l := NewLessonPgm(base.FilePath("foo.md"), []*BlockPgm{
+		makeBlockWithProse("# Title\n\nfirst"),
+		makeBlockWithProse("second"),
+		makeBlockWithProse("## Next\n"),
+		makeBlockWithProse("fourth"),
+		makeBlockWithProse("fifth"),
+	})
+	for _, test := range []struct{ start, want int }{
+		{0, 2}, {1, 2}, {2, 5}, {4, 5},
+	} {
+		if got := l.SectionEnd(test.start); got != test.want {
+			t.Errorf("SectionEnd(%d) = %d, want %d", test.start, got, test.want)
+		}
+	}
+}
+
+func TestResolvePrerequisites(t *testing.T) {
+	install := NewLessonPgm(base.FilePath("setup/install.md"), nil)
+	configure := NewLessonPgm(base.FilePath("setup/configure.md"), nil)
+	configure.requires = []string{"install"}
+	run := NewLessonPgm(base.FilePath("run.md"), nil)
+	run.requires = []string{"setup/configure.md", "nonesuch"}
+	resolvePrerequisites([]*LessonPgm{install, configure, run})
+	for _, test := range []struct {
+		l    *LessonPgm
+		want []int
+	}{
+		{install, []int{}},
+		{configure, []int{0}},
+		{run, []int{1, -1}},
+	} {
+		got := test.l.Prerequisites()
+		if len(got) != len(test.want) {
+			t.Fatalf("%s: got %d prerequisites, want %d",
+				test.l.Path(), len(got), len(test.want))
+		}
+		for i, p := range got {
+			if p.Index() != test.want[i] {
+				t.Errorf("%s: prerequisite %s has index %d, want %d",
+					test.l.Path(), p.Name(), p.Index(), test.want[i])
+			}
+		}
+	}
+}
+
+func TestProgramForArch(t *testing.T) {
+	block := func(labels ...base.Label) *model.BlockTut {
+		return model.NewBlockTut(model.NewBlockParsed(
+			labels, base.MdProse("prose"), base.OpaqueCode("date\n")))
+	}
+	tut := model.NewLessonTutForTests(base.FilePath("download.md"), []*model.BlockTut{
+		block("any"),
+		block("arm", "arch=arm64"),
+		block("intel", "arch=amd64,386"),
+	})
+	for _, test := range []struct {
+		arch string
+		want []string
+	}{
+		{"", []string{"any", "arm", "intel"}},
+		{"arm64", []string{"any", "arm"}},
+		{"386", []string{"any", "intel"}},
+	} {
+		p := NewProgramFromTutorialForArch(base.WildCardLabel, test.arch, tut)
+		blocks := p.Lessons()[0].Blocks()
+		if len(blocks) != len(test.want) {
+			t.Fatalf("%q: got %d blocks, want %d", test.arch, len(blocks), len(test.want))
+		}
+		for i, b := range blocks {
+			if b.Name() != test.want[i] {
+				t.Errorf("%q: block %d is %s, want %s", test.arch, i, b.Name(), test.want[i])
+			}
+		}
+	}
+}
+
+func TestPrintDryRun(t *testing.T) {
+	b := model.NewBlockParsed(
+		[]base.Label{"install", "arch=arm64"}, base.MdProse("prose"),
+		base.OpaqueCode("\ncurl -sL https://example.com/releases/download/v1.2.3/tool-linux-arm64.tar.gz | tar xz\nls\n"))
+	b.SetLine(12)
+	tut := model.NewLessonTutForTests(base.FilePath("setup.md"), []*model.BlockTut{
+		model.NewBlockTut(b),
+		model.NewBlockTut(model.NewBlockParsed(
+			[]base.Label{"check"}, base.MdProse("prose"), base.OpaqueCode("date"))),
+	})
+	var w strings.Builder
+	NewProgramFromTutorial(base.WildCardLabel, tut).PrintDryRun(&w)
+	want := "STEP  FILE      BLOCK  LINES  LABELS                CODE\n" +
+		"1     setup.md  1      12-16  @install @arch=arm64  curl -sL https://example.com/releases/download/v1.2.3/too...\n" +
+		"\n" +
+		"    curl -sL https://example.com/releases/download/v1.2.3/tool-linux-arm64.tar.gz | tar xz\n" +
+		"    ls\n" +
+		"2     setup.md  2      -      @check                date\n" +
+		"    date\n" +
+		"2 blocks would run; nothing was run.\n"
+	if w.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", w.String(), want)
+	}
+}
+
+func TestPrintHeader(t *testing.T) {
+	tut := model.NewLessonTutForTests(base.FilePath("setup.md"), []*model.BlockTut{
+		model.NewBlockTut(model.NewBlockParsed(
+			[]base.Label{"install"}, base.MdProse("prose"), base.OpaqueCode("date\n")))})
+	tests := []struct {
+		label base.Label
+		opts  ScriptOptions
+		want  string
+	}{
+		{base.WildCardLabel, ScriptOptions{Source: "setup.md"},
+			"# Generated by mdrip " + base.Version() + " from setup.md.\n" +
+				"# Edit the markdown, not this file.\n\n"},
+		{"install", ScriptOptions{Shebang: "#!/usr/bin/env bash", Strict: true, Source: "setup.md"},
+			"#!/usr/bin/env bash\n" +
+				"# Generated by mdrip " + base.Version() + " from setup.md with label @install.\n" +
+				"# Edit the markdown, not this file.\n" +
+				"set -euo pipefail\n\n"},
+	}
+	for _, test := range tests {
+		var w strings.Builder
+		NewProgramFromTutorial(test.label, tut).PrintHeader(&w, test.opts)
+		if w.String() != test.want {
+			t.Errorf("got\n%q\nwant\n%q", w.String(), test.want)
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	md := model.NewMdContent()
+	md.SetFrontMatter(&model.FrontMatter{Tags: []string{"beginner"}})
+	md.Blocks = []*model.BlockParsed{
+		model.NewBlockParsed([]base.Label{"tags=gcp"}, base.MdProse("prose"), base.OpaqueCode("date\n")),
+		model.NewBlockParsed(base.NoLabels(), base.MdProse("prose"), base.OpaqueCode("ls\n")),
+	}
+	tut := model.NewLessonTutFromMdContent(base.FilePath("setup.md"), md)
+	l := NewProgramFromTutorial(base.WildCardLabel, tut).Lessons()[0]
+	if got := strings.Join(l.Tags(), ","); got != "beginner,gcp" {
+		t.Errorf("got lesson tags %s", got)
+	}
+	if got := strings.Join(l.Blocks()[0].Tags(), ","); got != "beginner,gcp" {
+		t.Errorf("got first block tags %s", got)
+	}
+	if got := strings.Join(l.Blocks()[1].Tags(), ","); got != "beginner" {
+		t.Errorf("got second block tags %s", got)
+	}
+}
+
+func TestProgramAtPath(t *testing.T) {
+	block := func(name string) *model.BlockTut {
+		return model.NewBlockTut(model.NewBlockParsed(
+			[]base.Label{base.Label(name)}, base.MdProse("prose"), base.OpaqueCode("date\n")))
+	}
+	tut := model.NewTopCourse("benelux", base.FilePath("benelux"), []model.Tutorial{
+		model.NewCourse(base.FilePath("benelux/belgium"), []model.Tutorial{
+			model.NewLessonTutForTests(base.FilePath("benelux/belgium/antwerp.md"),
+				[]*model.BlockTut{block("diamonds"), block("beer")}),
+			model.NewLessonTutForTests(base.FilePath("benelux/belgium/ghent.md"),
+				[]*model.BlockTut{block("beer")}),
+		}),
+		model.NewLessonTutForTests(base.FilePath("benelux/luxembourg.md"),
+			[]*model.BlockTut{block("banks")}),
+	})
+	for _, test := range []struct {
+		path string
+		want []string
+	}{
+		{"", []string{"diamonds", "beer", "beer", "banks"}},
+		{"belgium", []string{"diamonds", "beer", "beer"}},
+		{"/belgium/antwerp/", []string{"diamonds", "beer"}},
+		{"belgium/antwerp/beer", []string{"beer"}},
+		{"luxembourg", []string{"banks"}},
+	} {
+		p, err := NewProgramFromTutorialAtPath(base.WildCardLabel, "", test.path, tut)
+		if err != nil {
+			t.Fatalf("%q: %v", test.path, err)
+		}
+		var got []string
+		for _, l := range p.Lessons() {
+			for _, b := range l.Blocks() {
+				got = append(got, b.Name())
+			}
+		}
+		if strings.Join(got, " ") != strings.Join(test.want, " ") {
+			t.Errorf("%q: got blocks %v, want %v", test.path, got, test.want)
+		}
+	}
+	for _, path := range []string{"belgium/bruges", "belgium/antwerp/waffles", "netherlands"} {
+		if _, err := NewProgramFromTutorialAtPath(base.WildCardLabel, "", path, tut); err == nil {
+			t.Errorf("%q: expected an error", path)
+		}
+	}
+}
+
+func TestProgramWithHooks(t *testing.T) {
+	block := func(labels ...base.Label) *model.BlockTut {
+		return model.NewBlockTut(model.NewBlockParsed(
+			labels, base.MdProse("prose"), base.OpaqueCode("date\n")))
+	}
+	tut := model.NewTopCourse("cloud", base.FilePath("cloud"), []model.Tutorial{
+		model.NewLessonTutForTests(base.FilePath("cloud/cluster.md"), []*model.BlockTut{
+			block("create", "setup"),
+			block("deploy", "test"),
+			block("browse"),
+			block("destroy", "teardown"),
+		}),
+		model.NewLessonTutForTests(base.FilePath("cloud/billing.md"), []*model.BlockTut{
+			block("login", "setup"),
+			block("invoice"),
+			block("logout", "teardown"),
+		}),
+	})
+	for _, test := range []struct {
+		label base.Label
+		want  string
+	}{
+		{base.WildCardLabel, "create deploy browse destroy login invoice logout"},
+		{base.Label("test"), "create deploy destroy"},
+		{base.Label("setup"), "create destroy login logout"},
+		{base.Label("nonesuch"), ""},
+	} {
+		var got []string
+		for _, l := range NewProgramFromTutorial(test.label, tut).Lessons() {
+			for _, b := range l.Blocks() {
+				got = append(got, b.Name())
+			}
+		}
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("%s: got blocks %v, want %s", test.label, got, test.want)
+		}
+	}
+}
+
+func TestStartAt(t *testing.T) {
+	block := func(labels ...base.Label) *model.BlockTut {
+		return model.NewBlockTut(model.NewBlockParsed(
+			labels, base.MdProse("prose"), base.OpaqueCode("date\n")))
+	}
+	tut := model.NewTopCourse("cloud", base.FilePath("cloud"), []model.Tutorial{
+		model.NewLessonTutForTests(base.FilePath("cloud/cluster.md"), []*model.BlockTut{
+			block("create", "setup"),
+			block("deploy", "test"),
+			block("browse"),
+			block("destroy", "teardown"),
+		}),
+		model.NewLessonTutForTests(base.FilePath("cloud/billing.md"), []*model.BlockTut{
+			block("login"),
+			block("invoice"),
+			block("logout"),
+		}),
+	})
+	for _, test := range []struct {
+		label       base.Label
+		file, block string
+		want        string
+	}{
+		{base.WildCardLabel, "cluster.md", "3", "create browse destroy login invoice logout"},
+		{base.Label("test"), "cluster", "2", "create deploy destroy"},
+		{base.WildCardLabel, "cloud/billing.md", "invoice", "invoice logout"},
+		{base.WildCardLabel, "billing", "1", "login invoice logout"},
+		{base.WildCardLabel, "cluster.md", "9", "error"},
+		{base.WildCardLabel, "nonesuch.md", "1", "error"},
+		{base.Label("test"), "cluster.md", "3", "error"},
+	} {
+		p, err := NewProgramFromTutorial(test.label, tut).StartAt(test.file, test.block)
+		var got []string
+		if err != nil {
+			got = append(got, "error")
+		} else {
+			for _, l := range p.Lessons() {
+				for _, b := range l.Blocks() {
+					got = append(got, b.Name())
+				}
+			}
+		}
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("%s:%s: got blocks %v, want %s", test.file, test.block, got, test.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	lesson := func(path string, requires ...string) *LessonPgm {
+		l := NewLessonPgm(base.FilePath(path), nil)
+		l.requires = requires
+		return l
+	}
+	lessons := []*LessonPgm{
+		lesson("install.md"),
+		lesson("lint.md"),
+		lesson("configure.md", "install"),
+		lesson("docs.md", "nonesuch"),
+		lesson("run.md", "configure"),
+		lesson("test.md", "lint", "run"),
+	}
+	resolvePrerequisites(lessons)
+	var got []string
+	for _, p := range NewProgram(lessons).Split() {
+		var names []string
+		for _, l := range p.Lessons() {
+			names = append(names, l.Name())
+		}
+		got = append(got, strings.Join(names, ","))
+	}
+	want := "install,lint,configure,run,test docs"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	b := makeBlockWithProse("Say when.")
+	p := NewProgram([]*LessonPgm{NewLessonPgm(base.FilePath("a.md"), []*BlockPgm{b})})
+	got := p.Rewrite(func(c base.OpaqueCode) base.OpaqueCode {
+		return base.OpaqueCode(strings.ToUpper(c.String()))
+	}).Lessons()[0].Blocks()[0]
+	if got.Code() != "DATE\n" || string(got.Prose()) != "Say when." {
+		t.Errorf("got %q, %q", got.Code(), got.Prose())
+	}
+	if b.Code() != "date\n" {
+		t.Errorf("original became %q", b.Code())
+	}
+}
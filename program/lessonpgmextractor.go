@@ -1,6 +1,8 @@
 package program
 
 import (
+	"strings"
+
 	"github.com/monopole/mdrip/base"
 	"github.com/monopole/mdrip/model"
 )
@@ -9,21 +11,95 @@ import (
 // from a Tutorial to create a flat list of lessons.  The lessons
 // are edited - only blocks with the given label are carried over
 // into the new extracted lessons.  If a lesson has no blocks with
-// the given label, it is completely dropped.
+// the given label, it is completely dropped.  Setup and teardown
+// blocks are kept, whatever their labels, in any lesson that
+// keeps some other block.
 type LessonPgmExtractor struct {
 	label      base.Label
 	firstTitle string
 	lessons    []*LessonPgm
 	blockAccum []*BlockPgm
+	// glossaries holds, for each course enclosing the lesson
+	// being visited, its glossary merged with those above it.
+	glossaries []model.Glossary
+	// allTerms merges every glossary seen.
+	allTerms model.Glossary
+	// arch, if not empty, drops blocks meant for other architectures.
+	arch string
+	// path, if not empty, drops blocks outside the course, lesson
+	// or block with the given URL path segments, e.g. the
+	// segments of benelux/belgium/antwerp/diamonds.
+	path []string
+	// names holds the segments of the path being visited.
+	names []string
+	// pathFound is true if something had the given path.
+	pathFound bool
+	// lastCode is the lesson's last code block, if it was kept;
+	// a block of its expected output, marked @expected, may follow.
+	lastCode *BlockPgm
+	// chosen counts the lesson's blocks the label chose,
+	// as opposed to its setup and teardown blocks.
+	chosen int
+	// codeBlocks counts the lesson's code blocks visited so far,
+	// extracted or not.
+	codeBlocks int
 }
 
 // NewLessonPgmExtractor is a ctor.
 func NewLessonPgmExtractor(label base.Label) *LessonPgmExtractor {
-	return &LessonPgmExtractor{label, "", []*LessonPgm{}, []*BlockPgm{}}
+	return &LessonPgmExtractor{
+		label, "", []*LessonPgm{}, []*BlockPgm{},
+		[]model.Glossary{}, model.Glossary{}, "", nil, []string{}, false, nil, 0, 0}
+}
+
+// enter pushes a segment onto the path being visited, returning
+// true if whatever has the resulting path may hold wanted blocks.
+func (v *LessonPgmExtractor) enter(segment string) bool {
+	v.names = append(v.names, segment)
+	for i := 0; i < len(v.path) && i < len(v.names); i++ {
+		if v.path[i] != v.names[i] {
+			return false
+		}
+	}
+	if len(v.names) == len(v.path) {
+		v.pathFound = true
+	}
+	return true
+}
+
+func (v *LessonPgmExtractor) leave() {
+	v.names = v.names[:len(v.names)-1]
+}
+
+// isWanted is true if the path being visited is at or below the given one.
+func (v *LessonPgmExtractor) isWanted() bool {
+	return len(v.names) >= len(v.path)
+}
+
+// Glossary merges the glossaries of all courses found.
+func (v *LessonPgmExtractor) Glossary() model.Glossary {
+	return v.allTerms
+}
+
+func (v *LessonPgmExtractor) glossary() model.Glossary {
+	if len(v.glossaries) == 0 {
+		return model.Glossary{}
+	}
+	return v.glossaries[len(v.glossaries)-1]
 }
 
-// Lessons found.
+func (v *LessonPgmExtractor) visitCourse(c *model.Course) {
+	v.glossaries = append(v.glossaries, v.glossary().Merge(c.Glossary()))
+	v.allTerms = v.allTerms.Merge(c.Glossary())
+	for _, x := range c.Children() {
+		x.Accept(v)
+	}
+	v.glossaries = v.glossaries[:len(v.glossaries)-1]
+}
+
+// Lessons found, with their prerequisites resolved.
 func (v *LessonPgmExtractor) Lessons() []*LessonPgm {
+	resolvePrerequisites(v.lessons)
 	return v.lessons
 }
 
@@ -34,9 +110,52 @@ func (v *LessonPgmExtractor) FirstTitle() string {
 
 // VisitBlockTut does just that.
 func (v *LessonPgmExtractor) VisitBlockTut(b *model.BlockTut) {
-	if v.label == base.WildCardLabel || b.HasLabel(v.label) {
-		v.blockAccum = append(v.blockAccum, NewBlockPgmFromBlockTut(b))
+	if len(b.Code()) > 0 {
+		v.codeBlocks++
+	}
+	if kind, ok := base.FindExpectation(b.Labels()); ok {
+		v.visitExpectation(kind, b)
+		return
 	}
+	if len(b.Code()) > 0 {
+		v.lastCode = nil
+	}
+	ok := v.enter(b.Name()) && v.isWanted()
+	v.leave()
+	if !ok {
+		return
+	}
+	if !base.SuitsArch(b.Labels(), v.arch) {
+		return
+	}
+	chosen := v.label.Selects(b.Labels())
+	if chosen || b.HasLabel(base.SetupLabel) || b.HasLabel(base.TeardownLabel) {
+		if chosen {
+			v.chosen++
+		}
+		p := NewBlockPgmFromBlockTut(b)
+		p.index = v.codeBlocks
+		v.blockAccum = append(v.blockAccum, p)
+		if len(b.Code()) > 0 {
+			v.lastCode = p
+		}
+	}
+}
+
+// visitExpectation attaches the expected output held by the block
+// to the code block before it, if that was kept.  The output isn't
+// code, so it's kept as prose, for the web app to show.
+func (v *LessonPgmExtractor) visitExpectation(kind string, b *model.BlockTut) {
+	if v.lastCode == nil {
+		return
+	}
+	v.lastCode.expected = NewExpectation(kind, b.Code().String())
+	v.lastCode = nil
+	p := NewBlockPgmFromBlockTut(b)
+	p.BlockBase = base.NewBlockBase(
+		base.MdProse(b.Prose().String()+"\n```\n"+b.Code().String()+"```\n"),
+		base.NoCode())
+	v.blockAccum = append(v.blockAccum, p)
 }
 
 // VisitLessonTut does just that.
@@ -45,34 +164,66 @@ func (v *LessonPgmExtractor) VisitLessonTut(l *model.LessonTut) {
 		v.firstTitle = l.Title()
 	}
 	v.blockAccum = []*BlockPgm{}
-	for _, x := range l.Children() {
-		x.Accept(v)
+	v.lastCode = nil
+	v.chosen = 0
+	v.codeBlocks = 0
+	inPath := v.enter(l.Slug())
+	// Heading and block ids start with the lesson's path,
+	// e.g. "setup-install", to be unique on the page.
+	path := strings.Join(v.names, "-")
+	if inPath {
+		for _, x := range l.Children() {
+			x.Accept(v)
+		}
 	}
-	if len(v.blockAccum) < 1 {
+	v.leave()
+	if v.chosen < 1 {
 		return
 	}
+	footnotes := map[string]string{}
+	for _, b := range v.blockAccum {
+		for n, d := range footnoteDefs(b.Prose().String()) {
+			footnotes[n] = d
+		}
+	}
 	id := -1
+	var headings []Heading
+	seen := map[string]bool{}
 	for _, b := range v.blockAccum {
+		b.headings = findHeadings(b.Prose().String(), path+"--", seen)
+		headings = append(headings, b.headings...)
+		b.glossary = v.glossary()
+		b.footnotes = footnotes
+		b.dir = lessonDir(string(l.Path()))
+		b.tags = base.MergeTags(l.Tags(), b.tags)
 		if len(b.Code()) > 0 {
 			id++
 			b.id = id
+			b.anchor = blockAnchor(b, path)
 		} else {
 			b.id = -1
 		}
 	}
-	v.lessons = append(v.lessons, NewLessonPgm(l.Path(), v.blockAccum))
+	lp := NewLessonPgm(l.Path(), v.blockAccum)
+	lp.requires = l.Requires()
+	lp.tags = l.AllTags()
+	lp.author = l.Author()
+	lp.revision = l.Revision()
+	lp.vars = l.Vars()
+	lp.headings = headings
+	lp.anchors = l.Anchors()
+	v.lessons = append(v.lessons, lp)
 }
 
 // VisitCourse does just that.
 func (v *LessonPgmExtractor) VisitCourse(c *model.Course) {
-	for _, x := range c.Children() {
-		x.Accept(v)
+	if v.enter(c.Slug()) {
+		v.visitCourse(c)
 	}
+	v.leave()
 }
 
 // VisitTopCourse does just that.
 func (v *LessonPgmExtractor) VisitTopCourse(t *model.TopCourse) {
-	for _, x := range t.Children() {
-		x.Accept(v)
-	}
+	v.visitCourse(&t.Course)
 }
@@ -0,0 +1,70 @@
+package program
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/monopole/mdrip/base"
+)
+
+// SourceMarker starts the comment printed just before the code of a
+// block, naming the markdown file and line that the code's first
+// line came from.  It lets Locate trace a line of a printed script,
+// run long after mdrip is gone, back to the markdown.
+const SourceMarker = "# mdrip-source: "
+
+// sourceComment marks code starting at the given line of a file.
+func sourceComment(p base.FilePath, line int) string {
+	return fmt.Sprintf("%s%s:%d\n", SourceMarker, p, line)
+}
+
+// blockEnd is how LessonPgm.Print ends a block.
+const blockEnd = "#" + "-----"
+
+// Location is a line in a markdown file.
+type Location struct {
+	Path base.FilePath
+	Line int
+}
+
+func (l *Location) String() string {
+	return fmt.Sprintf("%s:%d", l.Path, l.Line)
+}
+
+// Locate reads a script printed by mdrip, and returns the markdown
+// file and line that the given line of the script, counting from 1,
+// came from.  It's an error if the script line isn't from a code
+// block, or the script was printed without source markers.
+func Locate(r io.Reader, line int) (*Location, error) {
+	s := bufio.NewScanner(r)
+	var current *Location
+	markerLine := 0
+	for n := 1; s.Scan(); n++ {
+		text := s.Text()
+		if strings.HasPrefix(text, blockEnd) || text == addedSleep {
+			current = nil
+		}
+		if n == line {
+			if current == nil || strings.HasPrefix(text, SourceMarker) {
+				return nil, fmt.Errorf("line %d isn't from a code block", line)
+			}
+			return &Location{current.Path, current.Line + n - markerLine - 1}, nil
+		}
+		if strings.HasPrefix(text, SourceMarker) {
+			x := strings.TrimPrefix(text, SourceMarker)
+			i := strings.LastIndex(x, ":")
+			k, err := strconv.Atoi(x[i+1:])
+			if i < 0 || err != nil {
+				return nil, fmt.Errorf("bad source marker at line %d: %q", n, text)
+			}
+			current, markerLine = &Location{base.FilePath(x[:i]), k}, n
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("script has fewer than %d lines", line)
+}
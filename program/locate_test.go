@@ -0,0 +1,145 @@
+package program
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+func TestLocate(t *testing.T) {
+	b1 := model.NewBlockParsed(
+		base.NoLabels(), base.MdProse("prose"), base.OpaqueCode("date\nuname -a\n"))
+	b1.SetLine(12)
+	b2 := model.NewBlockParsed(
+		base.NoLabels(), base.MdProse("prose"), base.OpaqueCode("ls\n"))
+	b2.SetLine(30)
+	tut := model.NewLessonTutForTests(base.FilePath("setup.md"),
+		[]*model.BlockTut{model.NewBlockTut(b1), model.NewBlockTut(b2)})
+	var w strings.Builder
+	NewProgramFromTutorial(base.WildCardLabel, tut).PrintNormal(&w)
+	script := w.String()
+	lineOf := func(text string) int {
+		for i, l := range strings.Split(script, "\n") {
+			if l == text {
+				return i + 1
+			}
+		}
+		t.Fatalf("no %q in script:\n%s", text, script)
+		return 0
+	}
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"date", "setup.md:13"},
+		{"uname -a", "setup.md:14"},
+		{"ls", "setup.md:31"},
+	}
+	for _, test := range tests {
+		loc, err := Locate(strings.NewReader(script), lineOf(test.code))
+		if err != nil {
+			t.Errorf("%s: %v", test.code, err)
+			continue
+		}
+		if loc.String() != test.want {
+			t.Errorf("%s: got %s, want %s", test.code, loc, test.want)
+		}
+	}
+	for _, line := range []int{1, lineOf("ls") - 1, lineOf("ls") + 1, 1000} {
+		if _, err := Locate(strings.NewReader(script), line); err == nil {
+			t.Errorf("expected error locating line %d", line)
+		}
+	}
+}
+
+func TestPrintScript(t *testing.T) {
+	b1 := model.NewBlockParsed(
+		[]base.Label{"install"}, base.MdProse("prose"), base.OpaqueCode("date\nuname -a\n"))
+	b1.SetLine(12)
+	b2 := model.NewBlockParsed(
+		[]base.Label{"check"}, base.MdProse("prose"), base.OpaqueCode("ls\n"))
+	b2.SetLine(30)
+	b3 := model.NewBlockParsed(
+		[]base.Label{"check"}, base.MdProse("prose"), base.OpaqueCode("print(1)\nprint(2)"))
+	b3.SetLine(40)
+	b3.SetLanguage("python")
+	tut := model.NewLessonTutForTests(base.FilePath("setup.md"),
+		[]*model.BlockTut{model.NewBlockTut(b1), model.NewBlockTut(b2), model.NewBlockTut(b3)})
+	var w strings.Builder
+	NewProgramFromTutorial(base.WildCardLabel, tut).PrintScript(
+		&w, ScriptOptions{Shebang: "/bin/bash", Strict: true, Source: "setup.md"})
+	script := w.String()
+	delim := "#" + strings.Repeat("-", 70) + "#\n"
+	want := "#!/bin/bash\n" +
+		"# Generated by mdrip " + base.Version() + " from setup.md.\n" +
+		"# Edit the markdown, not this file.\n" +
+		"set -euo pipefail\n\n" +
+		delim +
+		"# Block 1 of 3, @install, from setup.md lines 13-14\n" +
+		"# mdrip-source: setup.md:13\n" +
+		"date\nuname -a\n" +
+		delim +
+		"# Block 2 of 3, @check, from setup.md lines 31-31\n" +
+		"# mdrip-source: setup.md:31\n" +
+		"ls\n" +
+		delim +
+		"# Block 3 of 3, @check, from setup.md lines 41-42\n" +
+		"command -v python3 >/dev/null || " +
+		"{ echo \"mdrip: block check needs python3, not found\" 1>&2; (exit 127); }\n" +
+		"# mdrip-source: setup.md:40\n" +
+		"python3 <<'MDRIP_END_OF_BLOCK'\n" +
+		"print(1)\nprint(2)\n" +
+		"MDRIP_END_OF_BLOCK\n" +
+		delim
+	if script != want {
+		t.Errorf("got\n%s\nwant\n%s", script, want)
+	}
+	// uname -a is on line 10 of the script.
+	loc, err := Locate(strings.NewReader(script), 10)
+	if err != nil || loc.String() != "setup.md:14" {
+		t.Errorf("got %v, %v, want setup.md:14", loc, err)
+	}
+	if _, err := Locate(strings.NewReader(script), 11); err == nil {
+		t.Errorf("the delimiter isn't from a block")
+	}
+	// print(2) is on line 21, the here document's end on line 22,
+	// where the closing fence was.
+	for line, want := range map[int]string{21: "setup.md:42", 22: "setup.md:43"} {
+		loc, err := Locate(strings.NewReader(script), line)
+		if err != nil || loc.String() != want {
+			t.Errorf("line %d: got %v, %v, want %s", line, loc, err, want)
+		}
+	}
+}
+
+func TestPrintNormal(t *testing.T) {
+	b := model.NewBlockParsed(
+		[]base.Label{"check", "retries=1"}, base.MdProse("prose"), base.OpaqueCode("print(1)\n"))
+	b.SetLine(40)
+	b.SetLanguage("python")
+	tut := model.NewLessonTutForTests(base.FilePath("setup.md"),
+		[]*model.BlockTut{model.NewBlockTut(b)})
+	var w strings.Builder
+	NewProgramFromTutorial(base.WildCardLabel, tut).PrintNormal(&w)
+	script := w.String()
+	for _, want := range []string{
+		"mdrip_block_1() {\n",
+		"# mdrip-source: setup.md:40\npython3 <<'MDRIP_END_OF_BLOCK'\nprint(1)\nMDRIP_END_OF_BLOCK\n",
+		"if [ $mdrip_status -eq 0 ] || [ $mdrip_try -ge 1 ]; then break; fi\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script lacks %q:\n%s", want, script)
+		}
+	}
+	lines := strings.Split(script, "\n")
+	for i, l := range lines {
+		if l == "print(1)" {
+			loc, err := Locate(strings.NewReader(script), i+1)
+			if err != nil || loc.String() != "setup.md:41" {
+				t.Errorf("got %v, %v, want setup.md:41", loc, err)
+			}
+		}
+	}
+}
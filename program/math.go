@@ -0,0 +1,96 @@
+package program
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// mathSpan is TeX math found in prose.
+type mathSpan struct {
+	tex     string
+	display bool
+}
+
+func mathPlaceholder(i int) string {
+	return fmt.Sprintf("MDRIPMATH%dX", i)
+}
+
+func isWordByte(b byte) bool {
+	return b < unicode.MaxASCII && (unicode.IsLetter(rune(b)) || unicode.IsDigit(rune(b)))
+}
+
+// protectMath replaces TeX math in markdown, $$...$$ for display and
+// $...$ inline, with placeholders, so markdown rendering doesn't
+// mangle it.  Code is left alone, as is \$.  To avoid mistaking shell
+// variables like $HOME for math, an inline span's opening $ must be
+// followed by, and its closing $ preceded by, a non-space, and the
+// closing $ must not be followed by a letter or digit.
+func protectMath(s string) (string, []mathSpan) {
+	var spans []mathSpan
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r := s[i:]
+		switch {
+		case strings.HasPrefix(r, "```"):
+			end := strings.Index(r[3:], "```")
+			if end < 0 {
+				b.WriteString(r)
+				return b.String(), spans
+			}
+			b.WriteString(r[:end+6])
+			i += end + 6
+			continue
+		case r[0] == '`':
+			end := strings.IndexByte(r[1:], '`')
+			if end < 0 {
+				end = len(r) - 2
+			}
+			b.WriteString(r[:end+2])
+			i += end + 2
+			continue
+		case strings.HasPrefix(r, `\$`):
+			b.WriteString(`\$`)
+			i += 2
+			continue
+		case strings.HasPrefix(r, "$$"):
+			if end := strings.Index(r[2:], "$$"); end > 0 {
+				b.WriteString(mathPlaceholder(len(spans)))
+				spans = append(spans, mathSpan{strings.TrimSpace(r[2 : end+2]), true})
+				i += end + 4
+				continue
+			}
+		case r[0] == '$' && (i == 0 || !isWordByte(s[i-1])):
+			if end := strings.IndexByte(r[1:], '$'); end > 0 {
+				tex := r[1 : end+1]
+				after := end + 2
+				if !unicode.IsSpace(rune(tex[0])) &&
+					!unicode.IsSpace(rune(tex[len(tex)-1])) &&
+					(after >= len(r) || !isWordByte(r[after])) {
+					b.WriteString(mathPlaceholder(len(spans)))
+					spans = append(spans, mathSpan{tex, false})
+					i += after
+					continue
+				}
+			}
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+	return b.String(), spans
+}
+
+// restoreMath replaces placeholders in rendered HTML with elements
+// holding the TeX, for the browser to typeset.
+func restoreMath(h string, spans []mathSpan) string {
+	for i := len(spans) - 1; i >= 0; i-- {
+		class := "math inline"
+		if spans[i].display {
+			class = "math display"
+		}
+		h = strings.Replace(h, mathPlaceholder(i),
+			`<span class="`+class+`">`+html.EscapeString(spans[i].tex)+`</span>`, 1)
+	}
+	return h
+}
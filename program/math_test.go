@@ -0,0 +1,44 @@
+package program
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProtectMath(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []mathSpan
+	}{
+		{"none", "just prose", nil},
+		{"inline", "area $\\pi r^2$ here", []mathSpan{{"\\pi r^2", false}}},
+		{"display", "$$\n x_1 + x_2 \n$$", []mathSpan{{"x_1 + x_2", true}}},
+		{"shellVars", "set $HOME and $PATH, or $HOME/$USER", nil},
+		{"money", "costs $5 or $10", nil},
+		{"escaped", "a \\$x$ b", nil},
+		{"inlineCode", "run `echo $a$` now", nil},
+		{"fencedCode", "```\necho $a$\n```\n", nil},
+	}
+	for _, test := range tests {
+		_, got := protectMath(test.input)
+		if len(got) != len(test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: got %v, want %v", test.name, got[i], test.want[i])
+			}
+		}
+	}
+}
+
+func TestHTMLProseMath(t *testing.T) {
+	b := makeBlockWithProse("Let $x_1 < y_2$ hold.")
+	got := string(b.HTMLProse())
+	want := `<span class="math inline">x_1 &lt; y_2</span>`
+	if !strings.Contains(got, want) {
+		t.Errorf("got\n%s\nwant it to hold\n%s", got, want)
+	}
+}
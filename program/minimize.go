@@ -0,0 +1,86 @@
+package program
+
+import (
+	"fmt"
+)
+
+// Minimize returns a copy of the program ending at the given block,
+// holding as few of the blocks before it as still fail the same way,
+// as fails says, e.g. by running the copy to see if the block fails,
+// along with how many copies it tried.  It's delta debugging: it
+// tries dropping all the earlier blocks, then runs of half as many,
+// and so on down to single blocks, keeping each drop after which
+// the copy still fails.  Blocks keep their order, so the result is
+// a repro of a late failure caused by some much earlier step.
+// fails should be deterministic; a flaky failure keeps too much.
+func (p *Program) Minimize(
+	last *BlockPgm, fails func(*Program) bool) (*Program, int, error) {
+	var before []place
+	found := false
+	for i, l := range p.lessons {
+		for j, b := range l.blocks {
+			if b == last {
+				found = true
+				break
+			}
+			before = append(before, place{i, j})
+		}
+		if found {
+			break
+		}
+	}
+	if !found {
+		return nil, 0, fmt.Errorf("no block %s among the blocks to run", last.Name())
+	}
+	tries := 0
+	for n := len(before); n > 0; n /= 2 {
+		for i := 0; i < len(before); {
+			j := i + n
+			if j > len(before) {
+				j = len(before)
+			}
+			trial := append(append([]place{}, before[:i]...), before[j:]...)
+			tries++
+			if fails(p.keep(trial, last)) {
+				before = trial
+			} else {
+				i = j
+			}
+		}
+	}
+	return p.keep(before, last), tries, nil
+}
+
+// keep returns a copy of the program holding only the blocks at the
+// given places, followed by the last block.  Lessons left with no
+// block are dropped.
+func (p *Program) keep(places []place, last *BlockPgm) *Program {
+	wanted := map[place]bool{}
+	for _, x := range places {
+		wanted[x] = true
+	}
+	result := &Program{p.label, []*LessonPgm{}}
+	for i, l := range p.lessons {
+		nl := *l
+		nl.blocks = []*BlockPgm{}
+		done := false
+		for j, b := range l.blocks {
+			if b == last {
+				nl.blocks = append(nl.blocks, b)
+				done = true
+				break
+			}
+			if wanted[place{i, j}] {
+				nl.blocks = append(nl.blocks, b)
+			}
+		}
+		if len(nl.blocks) > 0 {
+			result.lessons = append(result.lessons, &nl)
+		}
+		if done {
+			break
+		}
+	}
+	resolvePrerequisites(result.lessons)
+	return result
+}
@@ -0,0 +1,48 @@
+package program
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+)
+
+func TestMinimize(t *testing.T) {
+	p := NewProgramFromTutorial(base.WildCardLabel, needsTutorial(
+		needsBlock("name=login"),
+		needsBlock("name=invoice"),
+		needsBlock("name=pay"),
+	))
+	names := func(q *Program) string {
+		var result []string
+		for _, l := range q.Lessons() {
+			for _, b := range l.Blocks() {
+				result = append(result, b.Name())
+			}
+		}
+		return strings.Join(result, " ")
+	}
+	last := p.Lessons()[1].Blocks()[2]
+	// Paying fails only after creating the cluster and logging in.
+	m, tries, err := p.Minimize(last, func(q *Program) bool {
+		got := " " + names(q) + " "
+		return strings.Contains(got, " create ") && strings.Contains(got, " login ")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(m); got != "create login pay" {
+		t.Errorf("got %q", got)
+	}
+	if len(m.Lessons()) != 2 || tries == 0 {
+		t.Errorf("got %d lessons after %d tries", len(m.Lessons()), tries)
+	}
+	m, _, _ = p.Minimize(last, func(*Program) bool { return true })
+	if got := names(m); got != "pay" {
+		t.Errorf("a block failing alone should be alone, got %q", got)
+	}
+	other := NewProgramFromTutorial(base.WildCardLabel, needsTutorial())
+	if _, _, err := other.Minimize(last, func(*Program) bool { return true }); err == nil {
+		t.Errorf("expected an error for a block not in the program")
+	}
+}
@@ -0,0 +1,154 @@
+package program
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/monopole/mdrip/base"
+)
+
+// place is where a block is in a program.
+type place struct {
+	lesson, block int
+}
+
+// where says where the block at the place is, for messages.
+func (p *Program) where(x place) string {
+	l := p.lessons[x.lesson]
+	if line := l.blocks[x.block].Line(); line > 0 {
+		return fmt.Sprintf("%s:%d", l.path, line)
+	}
+	return fmt.Sprintf("%s#%d", l.path, l.blocks[x.block].Index())
+}
+
+// Needs returns the names of the blocks that the block, by its
+// @needs attribute, needs to have run before it.
+func (x *BlockPgm) Needs() []string {
+	list, ok := x.Attribute(base.NeedsAttribute)
+	if !ok {
+		return nil
+	}
+	var result []string
+	for _, n := range strings.Split(list, ",") {
+		if n = strings.TrimSpace(n); len(n) > 0 {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// named maps the @name of each of the program's named code
+// blocks to its place; for names given twice, the first wins.
+func (p *Program) named() map[string]place {
+	result := map[string]place{}
+	for i, l := range p.lessons {
+		for j, b := range l.blocks {
+			n, ok := b.Attribute(base.NameAttribute)
+			if !ok || len(b.Code()) == 0 {
+				continue
+			}
+			if _, dup := result[n]; !dup {
+				result[n] = place{i, j}
+			}
+		}
+	}
+	return result
+}
+
+// CheckNeeds describes each mistake in the program's @name and
+// @needs attributes: a name given to two blocks, and a block
+// needing a block that isn't among the program's, or that runs
+// after it, so that following the tutorial in order fails.
+// Since a block may only need blocks before it, the needs
+// can't form a cycle.
+func (p *Program) CheckNeeds() []string {
+	var result []string
+	named := p.named()
+	for i, l := range p.lessons {
+		for j, b := range l.blocks {
+			if len(b.Code()) == 0 {
+				continue
+			}
+			here := place{i, j}
+			if n, ok := b.Attribute(base.NameAttribute); ok {
+				if first := named[n]; first != here {
+					result = append(result, fmt.Sprintf(
+						"%s: @%s=%s is already the name of the block at %s",
+						p.where(here), base.NameAttribute, n, p.where(first)))
+				}
+			}
+			for _, n := range b.Needs() {
+				there, ok := named[n]
+				switch {
+				case !ok:
+					result = append(result, fmt.Sprintf(
+						"%s: @%s=%s, but no block to run has @%s=%s",
+						p.where(here), base.NeedsAttribute, n, base.NameAttribute, n))
+				case there == here:
+					result = append(result, fmt.Sprintf(
+						"%s: the block needs itself", p.where(here)))
+				case there.lesson > i || (there.lesson == i && there.block > j):
+					result = append(result, fmt.Sprintf(
+						"%s: @%s=%s, but %s runs later, at %s",
+						p.where(here), base.NeedsAttribute, n, n, p.where(there)))
+				}
+			}
+		}
+	}
+	return result
+}
+
+// Only returns a copy of the program holding just the code block
+// with the given @name and the blocks it needs, directly or through
+// other blocks, in their order, along with the setup and teardown
+// blocks of their lessons.  Lessons left with no such block are
+// dropped.
+func (p *Program) Only(name string) (*Program, error) {
+	named := p.named()
+	target, ok := named[name]
+	if !ok {
+		return nil, fmt.Errorf("no block to run has @%s=%s", base.NameAttribute, name)
+	}
+	wanted := map[place]bool{}
+	var visit func(x place) error
+	visit = func(x place) error {
+		if wanted[x] {
+			return nil
+		}
+		wanted[x] = true
+		for _, n := range p.lessons[x.lesson].blocks[x.block].Needs() {
+			y, ok := named[n]
+			if !ok {
+				return fmt.Errorf("%s: @%s=%s, but no block to run has @%s=%s",
+					p.where(x), base.NeedsAttribute, n, base.NameAttribute, n)
+			}
+			if err := visit(y); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := visit(target); err != nil {
+		return nil, err
+	}
+	result := &Program{p.label, []*LessonPgm{}}
+	for i, l := range p.lessons {
+		nl := *l
+		nl.blocks = []*BlockPgm{}
+		kept := false
+		for j, b := range l.blocks {
+			switch {
+			case wanted[place{i, j}]:
+				kept = true
+				nl.blocks = append(nl.blocks, b)
+			case len(findHook(b.labels)) > 0:
+				nl.blocks = append(nl.blocks, b)
+			}
+		}
+		if kept {
+			result.lessons = append(result.lessons, &nl)
+		}
+	}
+	resolvePrerequisites(result.lessons)
+	return result, nil
+}
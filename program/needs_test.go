@@ -0,0 +1,87 @@
+package program
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+func needsTutorial(second ...*model.BlockTut) model.Tutorial {
+	return model.NewTopCourse("cloud", base.FilePath("cloud"), []model.Tutorial{
+		model.NewLessonTutForTests(base.FilePath("cloud/cluster.md"), []*model.BlockTut{
+			needsBlock("setup"),
+			needsBlock("name=create"),
+			needsBlock("name=deploy", "needs=create"),
+			needsBlock("name=browse", "needs=deploy"),
+			needsBlock("teardown"),
+		}),
+		model.NewLessonTutForTests(base.FilePath("cloud/billing.md"), second),
+	})
+}
+
+func needsBlock(labels ...base.Label) *model.BlockTut {
+	return model.NewBlockTut(model.NewBlockParsed(
+		labels, base.MdProse("prose"), base.OpaqueCode("date\n")))
+}
+
+func TestCheckNeeds(t *testing.T) {
+	for n, test := range map[string]struct {
+		blocks []*model.BlockTut
+		want   []string
+	}{
+		"fine": {[]*model.BlockTut{
+			needsBlock("name=invoice", "needs=create, deploy"),
+		}, nil},
+		"later": {[]*model.BlockTut{
+			needsBlock("name=login", "needs=invoice"),
+			needsBlock("name=invoice"),
+		}, []string{"cloud/billing.md#1: @needs=invoice, but invoice runs later, at cloud/billing.md#2"}},
+		"missing": {[]*model.BlockTut{
+			needsBlock("needs=login"),
+		}, []string{"cloud/billing.md#1: @needs=login, but no block to run has @name=login"}},
+		"twice": {[]*model.BlockTut{
+			needsBlock("name=create"),
+		}, []string{"cloud/billing.md#1: @name=create is already the name of the block at cloud/cluster.md#2"}},
+		"self": {[]*model.BlockTut{
+			needsBlock("name=login", "needs=login"),
+		}, []string{"cloud/billing.md#1: the block needs itself"}},
+	} {
+		got := NewProgramFromTutorial(base.WildCardLabel, needsTutorial(test.blocks...)).CheckNeeds()
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s: got %q, want %q", n, got, test.want)
+		}
+	}
+}
+
+func TestOnly(t *testing.T) {
+	p := NewProgramFromTutorial(base.WildCardLabel, needsTutorial(
+		needsBlock("name=login"),
+		needsBlock("name=invoice", "needs=create"),
+	))
+	for _, test := range []struct {
+		name string
+		want string
+	}{
+		{"browse", "setup create deploy browse teardown"},
+		{"invoice", "setup create teardown invoice"},
+		{"login", "login"},
+		{"nonesuch", "error"},
+	} {
+		var got []string
+		o, err := p.Only(test.name)
+		if err != nil {
+			got = append(got, "error")
+		} else {
+			for _, l := range o.Lessons() {
+				for _, b := range l.Blocks() {
+					got = append(got, b.Name())
+				}
+			}
+		}
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("%s: got blocks %v, want %s", test.name, got, test.want)
+		}
+	}
+}
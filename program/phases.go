@@ -0,0 +1,54 @@
+package program
+
+import (
+	"strings"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+// NewProgramFromPhases returns the program running, in turn, the
+// blocks having each of the labels, e.g. setup, then demo, then
+// cleanup.  Each phase holds its blocks in the tutorial's order,
+// as NewProgramFromTutorialForArch would extract them, so a lesson
+// with blocks in several phases appears once in each.  A block
+// runs only in the first phase extracting it, so the setup blocks
+// kept with a lesson's blocks don't run again in later phases.
+func NewProgramFromPhases(phases []base.Label, arch string, t model.Tutorial) *Program {
+	type where struct {
+		path  base.FilePath
+		index int
+	}
+	taken := map[where]bool{}
+	names := make([]string, len(phases))
+	lessons := []*LessonPgm{}
+	for i, label := range phases {
+		names[i] = string(label)
+		for _, l := range NewProgramFromTutorialForArch(label, arch, t).lessons {
+			blocks := []*BlockPgm{}
+			keep, code := true, 0
+			for _, b := range l.blocks {
+				// Blocks without code, e.g. expected output,
+				// go with the code block before them.
+				if len(b.Code()) > 0 {
+					w := where{l.path, b.index}
+					keep = !taken[w]
+					taken[w] = true
+					if keep {
+						code++
+					}
+				}
+				if keep {
+					blocks = append(blocks, b)
+				}
+			}
+			if code > 0 {
+				l.blocks = blocks
+				lessons = append(lessons, l)
+			}
+		}
+	}
+	// A lesson's prerequisites are found among those of every phase.
+	resolvePrerequisites(lessons)
+	return &Program{base.Label(strings.Join(names, ";")), lessons}
+}
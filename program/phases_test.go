@@ -0,0 +1,42 @@
+package program
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+func TestProgramFromPhases(t *testing.T) {
+	block := func(labels ...base.Label) *model.BlockTut {
+		return model.NewBlockTut(model.NewBlockParsed(
+			labels, base.MdProse("prose"), base.OpaqueCode("date\n")))
+	}
+	tut := model.NewTopCourse("top", base.FilePath("top"), []model.Tutorial{
+		model.NewLessonTutForTests(base.FilePath("top/cluster.md"), []*model.BlockTut{
+			block("makeCluster", "setup"),
+			block("dropCluster", "cleanup"),
+		}),
+		model.NewLessonTutForTests(base.FilePath("top/app.md"), []*model.BlockTut{
+			block("install", "setup"),
+			block("call", "demo"),
+			block("scale", "demo"),
+			block("other"),
+		}),
+	})
+	p := NewProgramFromPhases([]base.Label{"setup", "demo", "cleanup"}, "", tut)
+	if p.Label() != "setup;demo;cleanup" {
+		t.Errorf("label is %q", p.Label())
+	}
+	var got []string
+	for _, l := range p.Lessons() {
+		for _, b := range l.Blocks() {
+			got = append(got, l.Name()+":"+string(b.Name()))
+		}
+	}
+	want := "cluster:makeCluster app:install app:call app:scale cluster:dropCluster"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got %s\nwant %s", strings.Join(got, " "), want)
+	}
+}
@@ -1,8 +1,12 @@
 package program
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/monopole/mdrip/base"
 	"github.com/monopole/mdrip/model"
@@ -22,6 +26,122 @@ func (p *Program) Lessons() []*LessonPgm { return p.lessons }
 // Label used to extract the Program.
 func (p *Program) Label() base.Label { return p.label }
 
+// Split divides the program into programs whose lessons don't
+// depend on each other's, so they may run at the same time.
+// Lessons linked by prerequisites, directly or through other
+// lessons, stay in one program.  Lessons keep their order, and
+// the programs are in the order of their first lessons.
+func (p *Program) Split() []*Program {
+	group := make([]int, len(p.lessons))
+	for i := range group {
+		group[i] = i
+	}
+	find := func(i int) int {
+		for group[i] != i {
+			i = group[i]
+		}
+		return i
+	}
+	for i, l := range p.lessons {
+		for _, r := range l.Prerequisites() {
+			if !r.Found() {
+				continue
+			}
+			a, b := find(i), find(r.Index())
+			if a > b {
+				a, b = b, a
+			}
+			group[b] = a
+		}
+	}
+	var result []*Program
+	programs := map[int]*Program{}
+	for i, l := range p.lessons {
+		g := find(i)
+		if programs[g] == nil {
+			programs[g] = &Program{p.label, []*LessonPgm{}}
+			result = append(result, programs[g])
+		}
+		programs[g].lessons = append(programs[g].lessons, l)
+	}
+	return result
+}
+
+// Rewrite returns a copy of the program with the code of
+// each block rewritten by f, e.g. a transform.Pipeline's Apply.
+func (p *Program) Rewrite(f func(base.OpaqueCode) base.OpaqueCode) *Program {
+	result := &Program{p.label, make([]*LessonPgm, len(p.lessons))}
+	for i, l := range p.lessons {
+		nl := *l
+		nl.blocks = make([]*BlockPgm, len(l.blocks))
+		for j, b := range l.blocks {
+			nb := *b
+			nb.BlockBase = base.NewBlockBase(b.Prose(), f(b.Code()))
+			nl.blocks[j] = &nb
+		}
+		result.lessons[i] = &nl
+	}
+	return result
+}
+
+// StartAt returns a copy of the program starting at the given
+// block of the lesson with the given file, e.g. to resume a run
+// where it failed.  Lessons before that one, and the lesson's
+// blocks before that block, are dropped, except for its setup
+// blocks.  The block is a number, counting the file's code
+// blocks from 1 as Explain does, or a label; the first block
+// having it matches.
+func (p *Program) StartAt(file, block string) (*Program, error) {
+	n, err := strconv.Atoi(block)
+	if err != nil {
+		n = 0
+	}
+	for i, l := range p.lessons {
+		if !l.isNamed(file) && string(l.path) != file {
+			continue
+		}
+		for j, b := range l.blocks {
+			if len(b.Code()) == 0 ||
+				(n > 0 && b.index != n) || (n == 0 && !b.hasLabel(base.Label(block))) {
+				continue
+			}
+			nl := *l
+			nl.blocks = []*BlockPgm{}
+			for _, x := range l.blocks[:j] {
+				if x.IsSetup() {
+					nl.blocks = append(nl.blocks, x)
+				}
+			}
+			nl.blocks = append(nl.blocks, l.blocks[j:]...)
+			result := &Program{p.label, []*LessonPgm{&nl}}
+			for _, x := range p.lessons[i+1:] {
+				nx := *x
+				result.lessons = append(result.lessons, &nx)
+			}
+			resolvePrerequisites(result.lessons)
+			return result, nil
+		}
+		return nil, fmt.Errorf(
+			"no block %q among the blocks of %s to run", block, l.path)
+	}
+	return nil, fmt.Errorf("no lesson %q among the lessons to run", file)
+}
+
+// SudoBlocks says where each of the program's blocks to
+// run as root, via @sudo=true, is.  CheckAttributes, not
+// this, reports a bad @sudo, e.g. @sudo=yes.
+func (p *Program) SudoBlocks() []string {
+	var result []string
+	for i, l := range p.lessons {
+		for j, b := range l.blocks {
+			if sudo, _ := b.Sudo(); sudo && len(b.Code()) > 0 {
+				result = append(result, p.where(place{i, j}))
+			}
+		}
+	}
+	return result
+}
+
 // NewProgram returns a program with the given lessons.
 func NewProgram(lessons []*LessonPgm) *Program {
 	return &Program{base.WildCardLabel, lessons}
@@ -29,11 +149,66 @@ func NewProgram(lessons []*LessonPgm) *Program {
 
 // NewProgramFromTutorial builds a program from blocks extracted from a tutorial.
 func NewProgramFromTutorial(l base.Label, t model.Tutorial) *Program {
+	return NewProgramFromTutorialForArch(l, "", t)
+}
+
+// NewProgramFromTutorialForArch is like NewProgramFromTutorial, but drops
+// blocks whose @arch attribute excludes the given architecture.
+func NewProgramFromTutorialForArch(l base.Label, arch string, t model.Tutorial) *Program {
 	v := NewLessonPgmExtractor(l)
+	v.arch = arch
 	t.Accept(v)
 	return &Program{l, v.Lessons()}
 }
 
+// NewProgramFromTutorialAtPath is like NewProgramFromTutorialForArch,
+// but keeps only blocks in the course, lesson or block with the
+// given path, e.g. benelux/belgium/antwerp/diamonds; the path the
+// webserver uses to select it.  It's an error if nothing has that path.
+func NewProgramFromTutorialAtPath(
+	l base.Label, arch, path string, t model.Tutorial) (*Program, error) {
+	v := NewLessonPgmExtractor(l)
+	v.arch = arch
+	path = strings.Trim(path, "/")
+	if len(path) > 0 {
+		v.path = strings.Split(path, "/")
+	}
+	t.Accept(v)
+	if !v.pathFound && len(v.path) > 0 {
+		return nil, fmt.Errorf("no course, lesson or block at path %q", path)
+	}
+	return &Program{l, v.Lessons()}, nil
+}
+
+// ScriptOptions say how to make a printed program
+// into a script that can be run directly.
+type ScriptOptions struct {
+	// Shebang, if not empty, is the interpreter, e.g. "/usr/bin/env bash".
+	Shebang string
+	// Strict stops the script at the first error or unset variable.
+	Strict bool
+	// Source is where the program came from, for the header comment.
+	Source string
+}
+
+// PrintHeader prints, for a script holding the program, an
+// optional shebang line, a comment saying where the script
+// came from, and optionally a strict mode setting.
+func (p Program) PrintHeader(w io.Writer, o ScriptOptions) {
+	if len(o.Shebang) > 0 {
+		fmt.Fprintf(w, "#!%s\n", strings.TrimPrefix(o.Shebang, "#!"))
+	}
+	fmt.Fprintf(w, "# Generated by mdrip %s from %s", base.Version(), o.Source)
+	if p.label != base.WildCardLabel {
+		fmt.Fprintf(w, " with label @%s", p.label)
+	}
+	fmt.Fprintf(w, ".\n# Edit the markdown, not this file.\n")
+	if o.Strict {
+		fmt.Fprintf(w, "set -euo pipefail\n")
+	}
+	fmt.Fprintln(w)
+}
+
 // PrintNormal simply prints the contents of a program.
 func (p Program) PrintNormal(w io.Writer) {
 	for _, s := range p.lessons {
@@ -43,6 +218,125 @@ func (p Program) PrintNormal(w io.Writer) {
 	fmt.Fprintf(w, "echo \"All done.  No errors.\"\n")
 }
 
+// PrintScript prints the program as a standalone script, with
+// the header PrintHeader prints.  Unlike PrintNormal, it echoes
+// nothing; each block is preceded by a comment naming the block,
+// and the file and lines it came from, and a source marker for
+// Locate.  Each block runs as in test mode; see RunScript.
+func (p Program) PrintScript(w io.Writer, o ScriptOptions) {
+	p.PrintHeader(w, o)
+	total := 0
+	for _, l := range p.lessons {
+		total += len(l.Blocks())
+	}
+	delim := "#" + strings.Repeat("-", 70) + "#\n"
+	n := 0
+	for _, l := range p.lessons {
+		for _, b := range l.Blocks() {
+			n++
+			fmt.Fprint(w, delim)
+			fmt.Fprintf(w, "# Block %d of %d, @%s, from %s", n, total, b.Name(), l.Path())
+			if b.Line() > 0 {
+				// The code starts on the line after the opening fence.
+				first := b.Line() + 1
+				lines := strings.Split(strings.TrimSuffix(b.Code().String(), "\n"), "\n")
+				fmt.Fprintf(w, " lines %d-%d", first, first+len(lines)-1)
+			}
+			fmt.Fprintln(w)
+			fmt.Fprint(w, withNewline(RunScript(n, b, l.Path())))
+			if b.shouldAddSleep {
+				fmt.Fprintln(w, addedSleep)
+			}
+		}
+	}
+	if n > 0 {
+		fmt.Fprint(w, delim)
+	}
+}
+
+// maxPlanCode is how much of a block's first line of
+// code, in runes, PrintDryRun shows.
+const maxPlanCode = 60
+
+// PrintDryRun writes a table of the plan test mode would follow:
+// in order, each block it would run, with its file, index in the
+// lesson, the lines it spans, its labels, and its first line of
+// code, and under each row, indented, the block's code in full,
+// exactly as it would run.  Nothing is run.
+func (p Program) PrintDryRun(w io.Writer) {
+	var table bytes.Buffer
+	tw := tabwriter.NewWriter(&table, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tFILE\tBLOCK\tLINES\tLABELS\tCODE")
+	var codes []string
+	for _, l := range p.lessons {
+		for i, b := range l.Blocks() {
+			labels := "-"
+			if len(b.Labels()) > 0 {
+				s := make([]string, len(b.Labels()))
+				for j, x := range b.Labels() {
+					s[j] = "@" + string(x)
+				}
+				labels = strings.Join(s, " ")
+			}
+			index := b.Index()
+			if index == 0 {
+				index = i + 1
+			}
+			codes = append(codes, b.Code().String())
+			fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\t%s\n", len(codes), l.Path(), index,
+				lineRange(b), labels, firstLine(b.Code().String()))
+		}
+	}
+	tw.Flush()
+	// The rows are aligned before the code goes between them.
+	rows := strings.SplitAfter(table.String(), "\n")
+	fmt.Fprint(w, rows[0])
+	for i, code := range codes {
+		fmt.Fprint(w, rows[i+1])
+		for _, line := range strings.Split(strings.TrimSuffix(code, "\n"), "\n") {
+			if len(line) > 0 {
+				line = "    " + line
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+	if len(codes) == 1 {
+		fmt.Fprintln(w, "1 block would run; nothing was run.")
+		return
+	}
+	fmt.Fprintf(w, "%d blocks would run; nothing was run.\n", len(codes))
+}
+
+// lineRange returns the lines of the block's file, from its opening
+// code fence to its closing one, e.g. 12-15, or - if unknown.
+func lineRange(b *BlockPgm) string {
+	if b.Line() == 0 {
+		return "-"
+	}
+	code := b.Code().String()
+	n := strings.Count(code, "\n")
+	if len(code) > 0 && !strings.HasSuffix(code, "\n") {
+		n++
+	}
+	return fmt.Sprintf("%d-%d", b.Line(), b.Line()+n+1)
+}
+
+// firstLine returns the first line of code that isn't blank,
+// shortened to maxPlanCode runes.
+func firstLine(code string) string {
+	for _, line := range strings.Split(code, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if r := []rune(line); len(r) > maxPlanCode {
+			line = string(r[:maxPlanCode-3]) + "..."
+		}
+		return line
+	}
+	return ""
+}
+
 // PrintPreambled emits the first n blocks of a file normally, then
 // emits the n blocks _again_, as well as all the remaining blocks
 // from remaining files, so that they run in a subshell with signal
@@ -0,0 +1,119 @@
+package program
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/monopole/mdrip/base"
+)
+
+// hereDocEnd ends the here document holding a block piped
+// to an interpreter, or to sudo.
+const hereDocEnd = "MDRIP_END_OF_BLOCK"
+
+// sudoCommand is how a block run as root calls sudo: as the
+// shell variable mdrip_sudo says, if set, as test mode sets
+// it, else plainly, prompting for a password if need be.
+const sudoCommand = "${mdrip_sudo:-sudo}"
+
+// needScript returns shell code failing the block, saying
+// so, if the program it needs can't be found.
+func needScript(b *BlockPgm, name string) string {
+	return fmt.Sprintf(
+		"command -v %s >/dev/null || { echo \"mdrip: block %s needs %s, not found\" 1>&2; (exit 127); }\n",
+		name, b.Name(), name)
+}
+
+// withNewline returns the code, ending with a newline.
+func withNewline(code string) string {
+	if strings.HasSuffix(code, "\n") {
+		return code
+	}
+	return code + "\n"
+}
+
+// BlockScript returns the shell code running the block, as test
+// mode runs it, and as print modes print it.  Code for an
+// interpreter is piped to it, so that set -e sees its exit code.  If
+// the interpreter can't be found, the block fails saying so, rather
+// than with whatever the shell makes of the code.  A block run as
+// root is likewise piped to sudo, running the interpreter or bash -e.
+// An isolated block runs in a subshell, with -e on even when keeping
+// going.  If the path isn't empty, the code is preceded by a source
+// marker, for Locate, naming the path and the line the block's code,
+// or its here document, starts on.
+func BlockScript(b *BlockPgm, p base.FilePath) string {
+	code := b.Code().String()
+	in := b.Interpreter()
+	sudo, _ := b.Sudo()
+	if len(in) > 0 || sudo {
+		checks, run := "", in
+		if len(in) > 0 {
+			checks = needScript(b, in)
+		} else {
+			run = "bash -e"
+		}
+		if sudo {
+			checks += needScript(b, "sudo")
+			run = sudoCommand + " -- " + run
+		}
+		// The here document starts where the opening fence was.
+		code = fmt.Sprintf("%s%s%s <<'%s'\n%s%s\n",
+			checks, marker(b, p, 0), run, hereDocEnd, withNewline(code), hereDocEnd)
+	} else {
+		// The code starts on the line after the opening fence.
+		code = marker(b, p, 1) + code
+	}
+	if isolated, _ := b.Isolated(); isolated {
+		code = "(\nset -e\n" + withNewline(code) + ")\n"
+	}
+	return code
+}
+
+// marker is the source marker for code starting the given
+// number of lines after the block's opening fence, or empty
+// if there's no path, or the block's line isn't known.
+func marker(b *BlockPgm, p base.FilePath, after int) string {
+	if len(p) == 0 || b.Line() <= 0 {
+		return ""
+	}
+	return sourceComment(p, b.Line()+after)
+}
+
+// TryScript returns the shell code defining the block, as
+// BlockScript makes it, as the n'th function, which returns at its
+// first failing command, then calling it, up to retries more times
+// while it fails, leaving its exit status in mdrip_status.  It
+// expects -e to be off.
+func TryScript(n int, b *BlockPgm, retries int, p base.FilePath) string {
+	fn := fmt.Sprintf("mdrip_block_%d", n)
+	def := fmt.Sprintf("%s() {\ntrap 'trap - ERR; return' ERR\n%s}\n",
+		fn, withNewline(BlockScript(b, p)))
+	if retries == 0 {
+		return def + fn + "\nmdrip_status=$?\ntrap - ERR\n"
+	}
+	return def + fmt.Sprintf(
+		"mdrip_try=0\n"+
+			"while :; do\n"+
+			"%s\nmdrip_status=$?\ntrap - ERR\n"+
+			"if [ $mdrip_status -eq 0 ] || [ $mdrip_try -ge %d ]; then break; fi\n"+
+			"mdrip_try=$((mdrip_try+1))\n"+
+			"echo \"mdrip: block %s failed with status $mdrip_status; retry $mdrip_try of %d\" 1>&2\n"+
+			"done\n",
+		fn, retries, b.Name(), retries)
+}
+
+// RunScript returns the shell code running the block: BlockScript's,
+// or, if the block has @retries, TryScript's, as the n'th function.
+// The tries run with -e off; if it was on, it's turned back on after
+// them, the shell exiting if every try failed.
+func RunScript(n int, b *BlockPgm, p base.FilePath) string {
+	retries, _ := b.Retries()
+	if retries == 0 {
+		return BlockScript(b, p)
+	}
+	return "case $- in *e*) mdrip_errexit=on ;; *) mdrip_errexit=off ;; esac\nset +e\n" +
+		TryScript(n, b, retries, p) +
+		"if [ $mdrip_errexit = on ]; then\nset -e\n" +
+		"if [ $mdrip_status -ne 0 ]; then exit $mdrip_status; fi\nfi\n"
+}
@@ -0,0 +1,77 @@
+package program
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+func scriptBlock(lang string, line int, code string, labels ...base.Label) *BlockPgm {
+	p := model.NewBlockParsed(append([]base.Label{"b"}, labels...),
+		base.NoProse(), base.OpaqueCode(code))
+	p.SetLanguage(lang)
+	p.SetLine(line)
+	return NewBlockPgmFromBlockTut(model.NewBlockTut(p))
+}
+
+func TestBlockScript(t *testing.T) {
+	const needPython = "command -v python3 >/dev/null || " +
+		"{ echo \"mdrip: block b needs python3, not found\" 1>&2; (exit 127); }\n"
+	const needSudo = "command -v sudo >/dev/null || " +
+		"{ echo \"mdrip: block b needs sudo, not found\" 1>&2; (exit 127); }\n"
+	for n, test := range map[string]struct {
+		b    *BlockPgm
+		p    base.FilePath
+		want string
+	}{
+		"shell": {scriptBlock("", 0, "date\n"), "", "date\n"},
+		"marked": {scriptBlock("", 12, "date\n"), "a.md",
+			"# mdrip-source: a.md:13\ndate\n"},
+		"unknown line": {scriptBlock("", 0, "date\n"), "a.md", "date\n"},
+		"python": {scriptBlock("python", 12, "print(1)"), "a.md",
+			needPython + "# mdrip-source: a.md:12\n" +
+				"python3 <<'MDRIP_END_OF_BLOCK'\nprint(1)\nMDRIP_END_OF_BLOCK\n"},
+		"sudo": {scriptBlock("", 0, "date\n", "sudo=true"), "",
+			needSudo + "${mdrip_sudo:-sudo} -- bash -e <<'MDRIP_END_OF_BLOCK'\n" +
+				"date\nMDRIP_END_OF_BLOCK\n"},
+		"sudo python": {scriptBlock("python", 0, "print(1)\n", "sudo=true"), "",
+			needPython + needSudo + "${mdrip_sudo:-sudo} -- python3 <<'MDRIP_END_OF_BLOCK'\n" +
+				"print(1)\nMDRIP_END_OF_BLOCK\n"},
+		"isolated": {scriptBlock("", 12, "cd /tmp", "isolation=subshell"), "a.md",
+			"(\nset -e\n# mdrip-source: a.md:13\ncd /tmp\n)\n"},
+	} {
+		if got := BlockScript(test.b, test.p); got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", n, got, test.want)
+		}
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	if got := RunScript(1, scriptBlock("", 0, "date\n"), ""); got != "date\n" {
+		t.Errorf("got %q", got)
+	}
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("no bash")
+	}
+	// The block fails until its third try.
+	const code = "n=$(( ${n:-0} + 1 ))\n[ $n -ge 3 ]\necho tried $n\n"
+	for _, test := range []struct {
+		retries base.Label
+		flag    string
+		want    string
+	}{
+		{"retries=2", "-e", "tried 3\nafter\n"},
+		{"retries=2", "+e", "tried 3\nafter\n"},
+		// Giving up, a shell with -e exits, one without goes on.
+		{"retries=1", "-e", ""},
+		{"retries=1", "+e", "after\n"},
+	} {
+		script := RunScript(1, scriptBlock("", 0, code, test.retries), "") + "echo after\n"
+		out, _ := exec.Command("bash", test.flag, "-c", script).Output()
+		if string(out) != test.want {
+			t.Errorf("%s %s: got %q, want %q", test.retries, test.flag, out, test.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package program
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/transform"
+)
+
+// MissingVar is a variable, e.g. {{.REGION}}, that
+// blocks use, but that has no value.
+type MissingVar struct {
+	Name string
+	// Description of the variable, from the vars in the front
+	// matter of a lesson using it; empty if none describes it.
+	Description string
+	// Lessons are those whose blocks use the variable.
+	Lessons []base.FilePath
+}
+
+// String is the variable's name, description and lessons,
+// e.g. "REGION - the GCP region (setup.md)".
+func (v MissingVar) String() string {
+	s := v.Name
+	if len(v.Description) > 0 {
+		s += " - " + v.Description
+	}
+	var lessons []string
+	for _, l := range v.Lessons {
+		lessons = append(lessons, string(l))
+	}
+	return s + " (" + strings.Join(lessons, ", ") + ")"
+}
+
+// MissingVars returns, sorted by name, the variables the program's
+// blocks use that lookup finds no value for.
+func (p *Program) MissingVars(lookup func(string) (string, bool)) []MissingVar {
+	found := map[string]*MissingVar{}
+	for _, l := range p.lessons {
+		for _, b := range l.blocks {
+			for _, n := range transform.VarNames(b.Code()) {
+				if _, ok := lookup(n); ok {
+					continue
+				}
+				v, ok := found[n]
+				if !ok {
+					v = &MissingVar{Name: n}
+					found[n] = v
+				}
+				if len(v.Description) == 0 {
+					v.Description = l.vars[n]
+				}
+				if len(v.Lessons) == 0 || v.Lessons[len(v.Lessons)-1] != l.path {
+					v.Lessons = append(v.Lessons, l.path)
+				}
+			}
+		}
+	}
+	result := make([]MissingVar, 0, len(found))
+	for _, v := range found {
+		result = append(result, *v)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// PromptVars asks, on w, for the value of each of the missing
+// variables, reading the answers, a line each, from r.  A blank
+// answer is asked again.
+func PromptVars(r io.Reader, w io.Writer, missing []MissingVar) (map[string]string, error) {
+	in := bufio.NewScanner(r)
+	result := map[string]string{}
+	for _, v := range missing {
+		for len(result[v.Name]) == 0 {
+			fmt.Fprintf(w, "%s: ", v)
+			if !in.Scan() {
+				if err := in.Err(); err != nil {
+					return nil, err
+				}
+				return nil, fmt.Errorf("no value given for %s", v.Name)
+			}
+			result[v.Name] = strings.TrimSpace(in.Text())
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,42 @@
+package program
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMissingVars(t *testing.T) {
+	setup := NewLessonPgm("setup.md", []*BlockPgm{
+		NewBlockPgm("gcloud config set project {{.PROJECT}}\n"),
+		NewBlockPgm("echo {{.REGION}} {{.PROJECT}}\n")})
+	setup.vars = map[string]string{"REGION": "the GCP region, e.g. us-east1"}
+	run := NewLessonPgm("run.md", []*BlockPgm{NewBlockPgm("echo {{.REGION}} {{.HOME_DIR}}\n")})
+	p := NewProgram([]*LessonPgm{setup, run})
+	missing := p.MissingVars(func(n string) (string, bool) {
+		return "/root", n == "HOME_DIR"
+	})
+	var got []string
+	for _, v := range missing {
+		got = append(got, v.String())
+	}
+	want := "PROJECT (setup.md)|REGION - the GCP region, e.g. us-east1 (setup.md, run.md)"
+	if strings.Join(got, "|") != want {
+		t.Errorf("got %q, want %q", strings.Join(got, "|"), want)
+	}
+
+	var prompts strings.Builder
+	values, err := PromptVars(strings.NewReader("zebra\n\n us-east1 \n"), &prompts, missing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["PROJECT"] != "zebra" || values["REGION"] != "us-east1" {
+		t.Errorf("got %v", values)
+	}
+	if strings.Count(prompts.String(), "REGION - ") != 2 {
+		t.Errorf("a blank answer should be asked again; got %q", prompts.String())
+	}
+	if _, err := PromptVars(strings.NewReader("zebra\n"), &prompts, missing); err == nil ||
+		!strings.Contains(err.Error(), "no value given for REGION") {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package scaffold
+
+// Template file contents.
+//
+// Blocks labeled @test are the ones the CI script runs; blocks
+// without that label are shown to readers, but not tested.
+
+const readmeBasic = `---
+title: Hello mdrip
+author: somebody
+---
+
+# Hello mdrip
+
+This tutorial was made by _mdrip init_.  Serve it with
+
+> ` + "`mdrip --mode demo .`" + `
+
+and test it with
+
+> ` + "`mdrip --mode test --label test .`" + `
+
+Make a place to work:
+
+<!-- @makeWorkDir @test -->
+` + "```" + `
+WORK_DIR=$(mktemp -d)
+echo "working in $WORK_DIR"
+` + "```" + `
+
+Write a file:
+
+<!-- @writeFile @test -->
+` + "```" + `
+cat <<EOF >$WORK_DIR/greeting.txt
+hello
+EOF
+` + "```" + `
+
+Check the file:
+
+<!-- @checkFile @test -->
+` + "```" + `
+grep hello $WORK_DIR/greeting.txt
+` + "```" + `
+
+This block is for readers only, and isn't tested:
+` + "```" + `
+open https://github.com/monopole/mdrip
+` + "```" + `
+
+Clean up:
+
+<!-- @cleanup @test -->
+` + "```" + `
+rm -rf $WORK_DIR
+` + "```" + `
+`
+
+const readmeCourse = `---
+title: Overview
+---
+
+# Overview
+
+This tutorial was made by _mdrip init course_.
+
+The lessons in the _setup_ directory are ordered by
+_setup/README_ORDER.txt_.  Test all of them with
+
+> ` + "`mdrip --mode test --label test .`" + `
+`
+
+const lessonInstall = `---
+title: Install
+---
+
+# Install
+
+<!-- @makeWorkDir @test -->
+` + "```" + `
+WORK_DIR=$(mktemp -d)
+` + "```" + `
+
+<!-- @install @test -->
+` + "```" + `
+echo 'echo hello' >$WORK_DIR/hello.sh
+chmod +x $WORK_DIR/hello.sh
+$WORK_DIR/hello.sh
+` + "```" + `
+`
+
+const lessonCleanup = `---
+title: Cleanup
+---
+
+# Cleanup
+
+<!-- @cleanup @test -->
+` + "```" + `
+rm -rf $WORK_DIR
+` + "```" + `
+`
+
+const ciScript = `#!/bin/bash
+#
+# Run this from CI to confirm the tutorial's
+# code blocks still work, e.g. in .travis.yml:
+#
+#   script:
+#     - ./test_docs.sh
+#
+set -e
+GOBIN=${GOBIN:-$TMPDIR} go install github.com/monopole/mdrip
+${GOBIN:-$TMPDIR}/mdrip --mode test --label test $(dirname $0)
+`
@@ -0,0 +1,198 @@
+package scaffold
+
+// The built-in course, teaching mdrip with mdrip.  It's served
+// when mdrip is given nothing to read, and its @test blocks run
+// in scaffold_test, so the course is also a test of mdrip itself.
+
+const readmeLearn = `---
+title: Learn mdrip
+---
+
+# Learn mdrip
+
+mdrip reads markdown, finds the code blocks in it,
+and serves them as a tutorial, like this one, prints
+them as a script, or runs them as a test.
+
+This course is built into mdrip.  Each lesson's blocks
+can be run from this page; click a block's name to send
+it to your terminal (start _tmux_ first).  The same
+blocks are run when mdrip tests itself, with
+
+> ` + "`mdrip --mode test --builtin --label test`" + `
+
+Start by making a place to work, that later lessons use:
+
+<!-- @makeWorkDir @test -->
+` + "```" + `
+WORK_DIR=$(mktemp -d)
+cd $WORK_DIR
+echo "working in $WORK_DIR"
+` + "```" + `
+`
+
+const lessonLabels = `---
+title: Labels
+---
+
+# Labels
+
+A code block may be preceded by an HTML comment
+holding labels, which readers of the rendered markdown
+don't see, e.g.
+
+    <!-- @writeGreeting @test -->
+
+The first label names the block; it's the name shown
+on the block above and in test reports.  The other
+labels group blocks, so that
+
+> ` + "`mdrip --label test learn.md`" + `
+
+prints only the blocks labeled _@test_.  This block is
+one of them:
+
+<!-- @writeGreeting @test -->
+` + "```" + `
+echo hello > greeting.txt
+` + "```" + `
+
+This one isn't, so tests skip it; it's for readers:
+
+<!-- @browse -->
+` + "```" + `
+open https://github.com/monopole/mdrip
+` + "```" + `
+
+A label may be an expression, e.g. _"test && !slow"_
+picks blocks labeled _@test_ but not _@slow_, like this
+one:
+
+<!-- @countGreeting @test -->
+` + "```" + `
+wc -l < greeting.txt
+` + "```" + `
+`
+
+const lessonDirectives = `---
+title: Directives
+---
+
+# Directives
+
+Some labels, directives, change how a block runs.
+
+A block named with _@name_ can be needed by others,
+with _@needs_; test mode refuses to run blocks out of
+order.
+
+<!-- @makeConfig @test @name=make-config -->
+` + "```" + `
+echo "color=blue" > app.conf
+` + "```" + `
+
+<!-- @readConfig @test @needs=make-config -->
+` + "```" + `
+grep color app.conf
+` + "```" + `
+
+A block marked _@expected_ holds the output expected
+of the block before it, which fails in test mode if its
+output differs.  Demo mode shows it as plain text:
+
+<!-- @showColor @test -->
+` + "```" + `
+cut -d= -f2 app.conf
+` + "```" + `
+
+` + "```" + `text @expected
+blue
+` + "```" + `
+
+With _@retries=2_, a failing block is run twice more
+before it's called failed; with _@timeout=10s_, it may
+take ten seconds; with _@isolation=subshell_, the
+variables it sets and directories it moves to don't
+outlast it:
+
+<!-- @wander @test @isolation=subshell @timeout=10s -->
+` + "```" + `
+cd /
+LEARN_COLOR=red
+` + "```" + `
+
+<!-- @stayed @test -->
+` + "```" + `
+test -f app.conf && test -z "${LEARN_COLOR:-}"
+` + "```" + `
+`
+
+const lessonVariants = `---
+title: Variants
+---
+
+# Variants
+
+A tutorial often differs by machine.  With _@arch_, a
+block is meant only for the architectures listed; print
+and test modes drop blocks meant for others, and demo
+mode shows blocks like these, with no prose between
+them, as tabs.  Only one of them runs here:
+
+<!-- @pickAmd64 @test @arch=amd64 -->
+` + "```" + `
+echo "a download for amd64" > arch.txt
+` + "```" + `
+
+<!-- @pickArm64 @test @arch=arm64 -->
+` + "```" + `
+echo "a download for arm64" > arch.txt
+` + "```" + `
+
+<!-- @showArch @test -->
+` + "```" + `
+cat arch.txt 2>/dev/null || echo "no download for $(uname -m)"
+` + "```" + `
+
+Labels make variants of other kinds, picked with
+_--label_, e.g. _--label linux_ prints just the second
+of these tabs:
+
+<!-- @installMac @mac -->
+` + "```" + `
+brew install tree
+` + "```" + `
+
+<!-- @installLinux @linux -->
+` + "```" + `
+sudo apt-get install tree
+` + "```" + `
+`
+
+const lessonTesting = `---
+title: Testing
+---
+
+# Testing
+
+In test mode, mdrip runs the blocks in a subshell, in
+order, reporting the first to fail, and exits with its
+status; so a tutorial in CI can't quietly rot.
+
+> ` + "`mdrip --mode test --label test {path}`" + `
+
+_--keepGoing_ runs every block and prints a summary;
+_--dryRun_ shows what would run without running it.
+Blocks labeled _@teardown_ run even after a failure,
+so they're the place to clean up:
+
+<!-- @cleanup @test @teardown -->
+` + "```" + `
+cd /
+rm -rf $WORK_DIR
+` + "```" + `
+
+Write a tutorial of your own with
+
+> ` + "`mdrip init course --out {dir}`" + `
+`
@@ -0,0 +1,148 @@
+// Package scaffold writes new tutorial trees from built-in templates,
+// so a team can start from a working, testable example.
+package scaffold
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/pkg/errors"
+)
+
+// DefaultTemplate is used when no template name is given.
+const DefaultTemplate = "basic"
+
+// LearnTemplate is the built-in course, teaching mdrip.
+const LearnTemplate = "learn"
+
+// Template is a named set of files making up a tutorial tree.
+type Template struct {
+	name  string
+	about string
+	// files maps a slash-separated relative path to file contents.
+	files map[string]string
+}
+
+// Name of the template.
+func (t *Template) Name() string { return t.name }
+
+// About is a one line description of the template.
+func (t *Template) About() string { return t.about }
+
+// Paths returns the relative paths of the template's files, sorted.
+func (t *Template) Paths() []string {
+	result := make([]string, 0, len(t.files))
+	for p := range t.files {
+		result = append(result, p)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Write writes the template's files below the given directory,
+// creating directories as needed.  It refuses to overwrite
+// existing files, checking all of them before writing any.
+func (t *Template) Write(dir base.FilePath) error {
+	for _, p := range t.Paths() {
+		target := filepath.Join(string(dir), filepath.FromSlash(p))
+		if _, err := os.Stat(target); err == nil {
+			return errors.New("refusing to overwrite " + target)
+		}
+	}
+	for _, p := range t.Paths() {
+		target := filepath.Join(string(dir), filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return errors.Wrap(err, "unable to make directory for "+target)
+		}
+		perm := os.FileMode(0644)
+		if filepath.Ext(p) == ".sh" {
+			perm = 0755
+		}
+		if err := ioutil.WriteFile(target, []byte(t.files[p]), perm); err != nil {
+			return errors.Wrap(err, "unable to write "+target)
+		}
+	}
+	return nil
+}
+
+// Unpack writes the template's files to a directory below the
+// system's temporary directory, named for their contents so that
+// later calls reuse it, and returns the directory.
+func (t *Template) Unpack() (string, error) {
+	h := sha1.New()
+	for _, p := range t.Paths() {
+		io.WriteString(h, p+"\x00"+t.files[p]+"\x00")
+	}
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("mdrip-%s-%x", t.name, h.Sum(nil)[:6]))
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+	tmp, err := ioutil.TempDir(os.TempDir(), "mdrip-unpack-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	if err := t.Write(base.FilePath(tmp)); err != nil {
+		return "", err
+	}
+	// Rename, so that a partial write is never used.
+	if err := os.Rename(tmp, dir); err != nil {
+		if _, statErr := os.Stat(dir); statErr == nil {
+			// Another instance got there first.
+			return dir, nil
+		}
+		return "", errors.Wrap(err, "unable to unpack template "+t.name)
+	}
+	return dir, nil
+}
+
+var templates = []*Template{
+	{"basic", "a single lesson and a CI script", map[string]string{
+		"README.md":    readmeBasic,
+		"test_docs.sh": ciScript,
+	}},
+	{"course", "an overview plus a nested course of two lessons", map[string]string{
+		"README.md":              readmeCourse,
+		"README_ORDER.txt":       "README\nsetup\n",
+		"setup/README_ORDER.txt": "install\ncleanup\n",
+		"setup/install.md":       lessonInstall,
+		"setup/cleanup.md":       lessonCleanup,
+		"test_docs.sh":           ciScript,
+	}},
+	{LearnTemplate, "the course teaching mdrip, that mdrip serves by default", map[string]string{
+		"README.md":        readmeLearn,
+		"README_ORDER.txt": "README\nlabels\ndirectives\nvariants\ntesting\n",
+		"labels.md":        lessonLabels,
+		"directives.md":    lessonDirectives,
+		"variants.md":      lessonVariants,
+		"testing.md":       lessonTesting,
+	}},
+}
+
+// Names returns the names of all templates.
+func Names() []string {
+	result := make([]string, len(templates))
+	for i, t := range templates {
+		result[i] = t.name
+	}
+	return result
+}
+
+// Lookup returns the template with the given name.
+func Lookup(name string) (*Template, error) {
+	if len(name) == 0 {
+		name = DefaultTemplate
+	}
+	for _, t := range templates {
+		if t.name == name {
+			return t, nil
+		}
+	}
+	return nil, errors.Errorf("unknown template %q; choose from %v", name, Names())
+}
@@ -0,0 +1,87 @@
+package scaffold
+
+import (
+	"io/ioutil"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/loader"
+	"github.com/monopole/mdrip/program"
+	"github.com/monopole/mdrip/subshell"
+)
+
+func TestTemplatesLoad(t *testing.T) {
+	for _, name := range Names() {
+		dir, err := ioutil.TempDir("", "mdrip-scaffold-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		tmpl, err := Lookup(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err = tmpl.Write(base.FilePath(dir)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err = tmpl.Write(base.FilePath(dir)); err == nil {
+			t.Errorf("%s: expected refusal to overwrite", name)
+		}
+		ds, err := base.NewDataSet([]string{dir})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		tut, err := loader.NewLoader(ds).Load()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		p := program.NewProgramFromTutorial(base.Label("test"), tut)
+		if len(p.Lessons()) < 1 {
+			t.Errorf("%s: expected lessons with @test blocks", name)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if x, err := Lookup(""); err != nil || x.Name() != DefaultTemplate {
+		t.Errorf("expected default template, got %v %v", x, err)
+	}
+	if _, err := Lookup("zebra"); err == nil {
+		t.Errorf("expected error for unknown template")
+	}
+}
+
+// TestLearnPasses runs the built-in course's @test blocks, as
+// "mdrip --mode test --builtin --label test" would, so that the
+// course keeps working, and exercises mdrip end to end.
+func TestLearnPasses(t *testing.T) {
+	tmpl, err := Lookup(LearnTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := tmpl.Unpack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := tmpl.Unpack(); err != nil || again != dir {
+		t.Errorf("got %s %v unpacking again, want %s", again, err, dir)
+	}
+	ds, err := base.NewDataSet([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tut, err := loader.NewLoader(ds).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := program.NewProgramFromTutorialForArch(base.Label("test"), runtime.GOARCH, tut)
+	if x := p.CheckNeeds(); len(x) > 0 {
+		t.Fatalf("bad needs: %v", x)
+	}
+	if r := subshell.NewSubshell(10*time.Second, p).Run(); r.Error() != nil {
+		t.Errorf("block %d failed: %v\n%s", r.Index(), r.Error(), r.StdErr())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"github.com/golang/glog"
 	"io"
+	"strings"
 	"time"
 )
 
@@ -21,6 +22,22 @@ const MsgError = "MDRIP_ERROR_Problem_while_processing_command_block"
 // MsgTimeout indicates the block didn't complete fast enough.
 const MsgTimeout = "MDRIP_TIMEOUT_Command_block_did_not_finish_in_allotted_time"
 
+// MsgFailed, followed by an exit status, indicates a command block
+// failed, but that the shell carries on with the next block.
+const MsgFailed = "MDRIP_FAILED_Command_block_exited_with_status"
+
+// MsgStateBegin and MsgStateEnd enclose a snapshot of the shell's
+// state, taken after a command block, that isn't the block's output.
+const (
+	MsgStateBegin = "MDRIP_STATE_BEGIN_Snapshot_of_shell_state"
+	MsgStateEnd   = "MDRIP_STATE_END_Snapshot_of_shell_state"
+)
+
+// MsgTimeLimit, followed by a duration, changes the wait for the command
+// block that follows it.  The wait returns to normal at the next MsgHappy
+// or MsgFailed.
+const MsgTimeLimit = "MDRIP_TIME_LIMIT_For_next_command_block"
+
 // convertStreamToLineChannel returns a string channel to which it writes _lines_.
 // The lines are pulled from an IO stream.
 //
@@ -63,15 +80,31 @@ func convertStreamToLineChannel(label string, stream io.ReadCloser) <-chan strin
 // The text is harvested from an io stream. If the io stream blocks for longer
 // than the given wait time, BuffScanner will send a special line of text to
 // the channel and close it.
+//
+// A MsgTimeLimit line isn't forwarded; it sets the wait until the next
+// MsgHappy or MsgFailed line.
 func BuffScanner(wait time.Duration, label string, stream io.ReadCloser) <-chan string {
 	chIn := convertStreamToLineChannel(label, stream)
 	chOut := make(chan string, 1)
 	go func() {
 		defer close(chOut)
+		limit := wait
 		for {
 			select {
 			case line, ok := <-chIn:
 				if ok {
+					if strings.HasPrefix(line, MsgTimeLimit) {
+						d, err := time.ParseDuration(
+							strings.TrimSpace(line[len(MsgTimeLimit):]))
+						if err == nil {
+							limit = d
+						}
+						continue
+					}
+					if strings.HasPrefix(line, MsgHappy) ||
+						strings.HasPrefix(line, MsgFailed) {
+						limit = wait
+					}
 					chOut <- line
 				} else {
 					if glog.V(2) {
@@ -80,10 +113,10 @@ func BuffScanner(wait time.Duration, label string, stream io.ReadCloser) <-chan
 					chIn = nil
 					return
 				}
-			case <-time.After(wait):
+			case <-time.After(limit):
 				chOut <- MsgTimeout
 				if glog.V(2) {
-					glog.Infof("buffScanner: %s timed out after %v!", label, wait)
+					glog.Infof("buffScanner: %s timed out after %v!", label, limit)
 				}
 				return
 			}
@@ -100,6 +100,20 @@ func TestSimpleReader(t *testing.T) {
 	}
 }
 
+func TestTimeLimit(t *testing.T) {
+	chOut := BuffScanner(1*time.Second, arbitraryLabel,
+		simpleReader{bytes.NewBufferString(
+			MsgTimeLimit + " 90s\nbeans\n" + MsgHappy + "\nrice")})
+	var got []string
+	for line := range chOut {
+		got = append(got, line)
+	}
+	want := []string{"beans", MsgHappy, "rice"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
 // An example main.
 func main() {
 	{
@@ -0,0 +1,333 @@
+package schema
+
+import (
+	"sort"
+	"strings"
+)
+
+// headerProperties are the JSON Schema of the properties common to all documents.
+const headerProperties = `
+    "version": {"const": "mdrip/v1"},
+    "kind": {"const": "%KIND%"},`
+
+const blockDefinition = `
+    "block": {
+      "type": "object",
+      "required": ["name", "labels", "code"],
+      "properties": {
+        "name": {"type": "string"},
+        "line": {"type": "integer", "minimum": 1},
+        "labels": {"type": "array", "items": {"type": "string"}},
+        "tags": {"type": "array", "items": {"type": "string"}},
+        "language": {"type": "string"},
+        "code": {"type": "string"}
+      }
+    }`
+
+var documents = map[string]string{
+	KindTree: `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "mdrip/v1/tree",
+  "title": "A tutorial as loaded: courses, lessons and blocks",
+  "type": "object",
+  "required": ["version", "kind", "root"],
+  "properties": {` + headerProperties + `
+    "root": {"$ref": "#/definitions/node"}
+  },
+  "definitions": {
+    "node": {
+      "type": "object",
+      "required": ["kind", "name"],
+      "properties": {
+        "kind": {"enum": ["course", "lesson", "block"]},
+        "name": {"type": "string"},
+        "path": {"type": "string"},
+        "title": {"type": "string"},
+        "tags": {"type": "array", "items": {"type": "string"}},
+        "modified": {"type": "string", "format": "date-time"},
+        "modifiedBy": {"type": "string"},
+        "children": {"type": "array", "items": {"$ref": "#/definitions/node"}},
+        "block": {"$ref": "#/definitions/block"}
+      }
+    },` + blockDefinition + `
+  }
+}
+`,
+	KindProgram: `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "mdrip/v1/program",
+  "title": "The blocks extracted by print and test mode",
+  "type": "object",
+  "required": ["version", "kind", "lessons"],
+  "properties": {` + headerProperties + `
+    "label": {"type": "string"},
+    "lessons": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["path", "blocks"],
+        "properties": {
+          "path": {"type": "string"},
+          "blocks": {"type": "array", "items": {"$ref": "#/definitions/block"}}
+        }
+      }
+    }
+  },
+  "definitions": {` + blockDefinition + `
+  }
+}
+`,
+	KindResults: `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "mdrip/v1/results",
+  "title": "What became of each block run in test mode",
+  "type": "object",
+  "required": ["version", "kind", "passed", "blocks"],
+  "properties": {` + headerProperties + `
+    "passed": {"type": "boolean"},
+    "error": {"type": "string"},
+    "blocks": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["file", "index", "name", "state", "stdout", "stderr", "seconds"],
+        "properties": {
+          "file": {"type": "string"},
+          "index": {"type": "integer", "minimum": 0},
+          "name": {"type": "string"},
+          "tags": {"type": "array", "items": {"type": "string"}},
+          "interpreter": {"type": "string"},
+          "state": {"enum": ["passed", "failed", "skipped"]},
+          "stdout": {"type": "string"},
+          "stderr": {"type": "string"},
+          "seconds": {"type": "number", "minimum": 0},
+          "stateChanges": {"type": "array", "items": {"type": "string"}}
+        }
+      }
+    }
+  }
+}
+`,
+	KindStatus: `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "mdrip/v1/status",
+  "title": "The state of blocks sent to tmux in demo mode",
+  "type": "object",
+  "required": ["version", "kind", "blocks"],
+  "properties": {` + headerProperties + `
+    "blocks": {
+      "type": "object",
+      "propertyNames": {"pattern": "^[0-9]+/[0-9]+$"},
+      "additionalProperties": {"enum": ["sent", "running", "ok", "failed"]}
+    },
+    "seconds": {
+      "type": "object",
+      "propertyNames": {"pattern": "^[0-9]+/[0-9]+$"},
+      "additionalProperties": {"type": "number", "minimum": 0}
+    },
+    "delivery": {"enum": ["websocket", "tmux", "clipboard"]}
+  }
+}
+`,
+	KindOutput: `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "mdrip/v1/output",
+  "title": "Output of, or a new state of, a block sent to tmux in demo mode",
+  "type": "object",
+  "required": ["version", "kind", "block"],
+  "properties": {` + headerProperties + `
+    "block": {"type": "string", "pattern": "^[0-9]+/[0-9]+$"},
+    "stream": {"enum": ["stdout", "stderr"]},
+    "text": {"type": "string"},
+    "state": {"enum": ["sent", "running", "ok", "failed"]},
+    "exitStatus": {"type": "integer", "minimum": 0},
+    "seconds": {"type": "number", "minimum": 0}
+  }
+}
+`,
+	KindJump: `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "mdrip/v1/jump",
+  "title": "An instructor telling a classroom's pages to show a lesson in demo mode",
+  "type": "object",
+  "required": ["version", "kind", "lesson", "path"],
+  "properties": {` + headerProperties + `
+    "lesson": {"type": "integer", "minimum": 0},
+    "path": {"type": "string"}
+  }
+}
+`,
+	KindSchedule: `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "mdrip/v1/schedule",
+  "title": "The lessons of a classroom in demo mode its instructor hasn't opened yet",
+  "type": "object",
+  "required": ["version", "kind", "locked"],
+  "properties": {` + headerProperties + `
+    "locked": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["lesson", "path"],
+        "properties": {
+          "lesson": {"type": "integer", "minimum": 0},
+          "path": {"type": "string"},
+          "until": {"type": "string", "format": "date-time"}
+        }
+      }
+    }
+  }
+}
+`,
+	KindSearch: `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "mdrip/v1/search",
+  "title": "Lessons matching a search in demo mode, best matches first",
+  "type": "object",
+  "required": ["version", "kind", "query", "hits"],
+  "properties": {` + headerProperties + `
+    "query": {"type": "string"},
+    "hits": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["path", "title", "lesson", "snippet"],
+        "properties": {
+          "path": {"type": "string"},
+          "title": {"type": "string"},
+          "lesson": {"type": "integer", "minimum": 0},
+          "snippet": {"type": "string"}
+        }
+      }
+    }
+  }
+}
+`,
+	KindPreview: `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "mdrip/v1/preview",
+  "title": "An edited lesson, rendered, with what the edit did to its blocks",
+  "type": "object",
+  "required": ["version", "kind", "html", "blocks"],
+  "properties": {` + headerProperties + `
+    "html": {"type": "string"},
+    "blocks": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["name", "change"],
+        "properties": {
+          "name": {"type": "string"},
+          "change": {"enum": ["same", "changed", "added", "removed"]}
+        }
+      }
+    }
+  }
+}
+`,
+	KindProposal: `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "mdrip/v1/proposal",
+  "title": "An edit to a lesson, committed to a branch for review",
+  "type": "object",
+  "required": ["version", "kind", "branch", "commit"],
+  "properties": {` + headerProperties + `
+    "branch": {"type": "string"},
+    "commit": {"type": "string"},
+    "url": {"type": "string"}
+  }
+}
+`,
+	KindLessons: `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "mdrip/v1/lessons",
+  "title": "The lessons served in demo mode",
+  "type": "object",
+  "required": ["version", "kind", "lessons"],
+  "properties": {` + headerProperties + `
+    "lessons": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["path", "title", "lesson", "blocks"],
+        "properties": {
+          "path": {"type": "string"},
+          "title": {"type": "string"},
+          "lesson": {"type": "integer", "minimum": 0},
+          "blocks": {"type": "integer", "minimum": 0}
+        }
+      }
+    }
+  }
+}
+`,
+	KindBlocks: `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "mdrip/v1/blocks",
+  "title": "The blocks of a lesson served in demo mode",
+  "type": "object",
+  "required": ["version", "kind", "path", "blocks"],
+  "properties": {` + headerProperties + `
+    "path": {"type": "string"},
+    "blocks": {
+      "type": "array",
+      "items": {
+        "allOf": [
+          {"$ref": "#/definitions/block"},
+          {
+            "required": ["id"],
+            "properties": {"id": {"type": "string", "pattern": "^[0-9]+/[0-9]+$"}}
+          }
+        ]
+      }
+    }
+  },
+  "definitions": {` + blockDefinition + `
+  }
+}
+`,
+	KindAuditRecord: `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "mdrip/v1/auditRecord",
+  "title": "A line of an audit log: a block run in test or run mode",
+  "type": "object",
+  "required": ["version", "kind", "seq", "time", "user", "host", "source",
+    "file", "index", "name", "codeSha256", "state", "seconds", "prev", "hash"],
+  "properties": {` + headerProperties + `
+    "seq": {"type": "integer", "minimum": 1},
+    "time": {"type": "string", "format": "date-time"},
+    "user": {"type": "string"},
+    "host": {"type": "string"},
+    "source": {"type": "string"},
+    "file": {"type": "string"},
+    "index": {"type": "integer", "minimum": 0},
+    "name": {"type": "string"},
+    "codeSha256": {"type": "string", "pattern": "^[0-9a-f]{64}$"},
+    "state": {"enum": ["passed", "failed", "skipped"]},
+    "exitCode": {"type": "integer"},
+    "seconds": {"type": "number", "minimum": 0},
+    "prev": {"type": "string", "pattern": "^([0-9a-f]{64})?$"},
+    "hash": {"type": "string", "pattern": "^[0-9a-f]{64}$"}
+  }
+}
+`,
+}
+
+// Names of the kinds of document, sorted.
+func Names() []string {
+	result := make([]string, 0, len(documents))
+	for n := range documents {
+		result = append(result, n)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Document returns the JSON Schema of the given kind of document.
+func Document(kind string) (string, bool) {
+	d, ok := documents[kind]
+	if !ok {
+		return "", false
+	}
+	return strings.Replace(d, "%KIND%", kind, 1), true
+}
@@ -0,0 +1,584 @@
+// Package schema defines the JSON documents mdrip writes, so that
+// tools reading them needn't track mdrip's internal types.
+//
+// Every document names its version and kind.  Within a version,
+// fields may be added, but none are removed, renamed or retyped;
+// any such change comes with a new version.  The JSON Schema of
+// each kind is available from Document, and from "mdrip schema".
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+	"github.com/monopole/mdrip/program"
+	"github.com/monopole/mdrip/subshell"
+)
+
+// Version of the documents defined here.
+const Version = "mdrip/v1"
+
+// Kinds of document.
+const (
+	// KindTree is the tutorial as loaded: courses, lessons and blocks.
+	KindTree = "tree"
+	// KindProgram is the blocks extracted by print and test mode.
+	KindProgram = "program"
+	// KindResults is what became of each block run in test mode.
+	KindResults = "results"
+	// KindStatus is the state of blocks sent to tmux in demo mode.
+	KindStatus = "status"
+	// KindOutput is, in demo mode, output of or a change in the
+	// state of a block sent to tmux, pushed over /_/results.
+	KindOutput = "output"
+	// KindSearch is the lessons matching a search in demo mode's /search.
+	KindSearch = "search"
+	// KindPreview is an edited lesson as demo mode's /_/preview
+	// renders it, with how the edit changed its blocks.
+	KindPreview = "preview"
+	// KindProposal is an edit submitted via demo mode's /_/propose.
+	KindProposal = "proposal"
+	// KindLessons is the lessons served by demo mode's /api/v1/lessons.
+	KindLessons = "lessons"
+	// KindBlocks is a lesson's blocks, as demo mode's
+	// /api/v1/lessons/{path}/blocks serves them.
+	KindBlocks = "blocks"
+	// KindAuditRecord is a line of an --auditLog: a block
+	// run in test or run mode, chained to the lines before.
+	KindAuditRecord = "auditRecord"
+	// KindJump, pushed over demo mode's /_/results to the students
+	// of a classroom, tells their pages to show a lesson.
+	KindJump = "jump"
+	// KindSchedule, from demo mode's /_/schedule, lists the
+	// lessons a classroom's instructor hasn't yet opened.
+	KindSchedule = "schedule"
+)
+
+// Header starts every document.
+type Header struct {
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+func header(kind string) Header { return Header{Version, kind} }
+
+// Block is a code block.
+type Block struct {
+	Name string `json:"name"`
+	// Line of the block's opening code fence; absent if unknown.
+	Line   int      `json:"line,omitempty"`
+	Labels []string `json:"labels"`
+	// Tags of the block, with those of its lesson; absent if none.
+	Tags []string `json:"tags,omitempty"`
+	// Language of the code, from its fence, e.g. bash; absent if unknown.
+	Language string `json:"language,omitempty"`
+	Code     string `json:"code"`
+}
+
+// Lesson is a file's extracted blocks.
+type Lesson struct {
+	Path   string  `json:"path"`
+	Blocks []Block `json:"blocks"`
+}
+
+// Program is a document of kind KindProgram.
+type Program struct {
+	Header
+	// Label used to select blocks; absent if none was.
+	Label   string   `json:"label,omitempty"`
+	Lessons []Lesson `json:"lessons"`
+}
+
+// Node is a course, lesson or block in a Tree.
+type Node struct {
+	// Kind is course, lesson or block.
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	Path string `json:"path,omitempty"`
+	// Title of a lesson, from its front matter or first header.
+	Title string `json:"title,omitempty"`
+	// Tags of a lesson, from its front matter; absent if none.
+	Tags []string `json:"tags,omitempty"`
+	// Modified is when a lesson's file last changed, per git,
+	// in RFC 3339 format; absent if unknown.
+	Modified string `json:"modified,omitempty"`
+	// ModifiedBy is who last changed a lesson's file, per git.
+	ModifiedBy string `json:"modifiedBy,omitempty"`
+	Children   []Node `json:"children,omitempty"`
+	// Block is present only in nodes of kind block.
+	Block *Block `json:"block,omitempty"`
+}
+
+// Tree is a document of kind KindTree.
+type Tree struct {
+	Header
+	Root Node `json:"root"`
+}
+
+// BlockResult is what became of one block.
+type BlockResult struct {
+	File  string `json:"file"`
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	// Tags of the block, with those of its lesson; absent if none.
+	Tags []string `json:"tags,omitempty"`
+	// Interpreter that ran the block, e.g. python3; absent if the shell did.
+	Interpreter string `json:"interpreter,omitempty"`
+	// State is passed, failed or skipped.
+	State   string  `json:"state"`
+	StdOut  string  `json:"stdout"`
+	StdErr  string  `json:"stderr"`
+	Seconds float64 `json:"seconds"`
+	// StateChanges say how the block changed the shell's state,
+	// e.g. "+ env KUBECONFIG=/tmp/kc", with --captureState;
+	// absent if it changed nothing, or state wasn't captured.
+	StateChanges []string `json:"stateChanges,omitempty"`
+}
+
+// Results is a document of kind KindResults.
+type Results struct {
+	Header
+	Passed bool `json:"passed"`
+	// Error is why the run failed; absent if it didn't.
+	Error  string        `json:"error,omitempty"`
+	Blocks []BlockResult `json:"blocks"`
+}
+
+// Status is a document of kind KindStatus.
+type Status struct {
+	Header
+	// Blocks maps "{lessonIndex}/{blockIndex}" to the state of
+	// the block: sent, running, ok or failed.
+	Blocks map[string]string `json:"blocks"`
+	// Seconds maps the keys of finished blocks, as in Blocks,
+	// to how long they ran, for those seen to start.
+	Seconds map[string]float64 `json:"seconds,omitempty"`
+	// Delivery is how the server gets blocks to a shell:
+	// DeliveryWebsocket, DeliveryTmux or DeliveryClipboard.
+	Delivery string `json:"delivery,omitempty"`
+}
+
+// Values of Status.Delivery.
+const (
+	// DeliveryWebsocket means blocks go over a websocket
+	// to an "mdrip tmux" session.
+	DeliveryWebsocket = "websocket"
+	// DeliveryTmux means blocks are pasted into a local tmux,
+	// or whichever multiplexer --multiplexer names.
+	DeliveryTmux = "tmux"
+	// DeliveryClipboard means there's no tmux to send blocks
+	// to, so the page copies them to the clipboard instead.
+	DeliveryClipboard = "clipboard"
+)
+
+// Output is a document of kind KindOutput.  It carries either
+// some output of a block (Stream and Text), or its new State.
+type Output struct {
+	Header
+	// Block is "{lessonIndex}/{blockIndex}", as in Status.
+	Block string `json:"block"`
+	// Stream is stdout or stderr.
+	Stream string `json:"stream,omitempty"`
+	Text   string `json:"text,omitempty"`
+	// State is sent, running, ok or failed.
+	State string `json:"state,omitempty"`
+	// ExitStatus is present only once a block's known to have finished.
+	ExitStatus *int `json:"exitStatus,omitempty"`
+	// Seconds is how long a finished block ran, if it was seen to start.
+	Seconds float64 `json:"seconds,omitempty"`
+}
+
+// Jump is a document of kind KindJump.
+type Jump struct {
+	Header
+	// Lesson is the index of the lesson to show, as in Status.
+	Lesson int `json:"lesson"`
+	// Path of the lesson's page, e.g. setup/install.
+	Path string `json:"path"`
+}
+
+// Schedule is a document of kind KindSchedule.
+type Schedule struct {
+	Header
+	Locked []LockedLesson `json:"locked"`
+}
+
+// LockedLesson is a lesson the audience may not open yet.
+type LockedLesson struct {
+	// Lesson is the index of the lesson, as in Status.
+	Lesson int `json:"lesson"`
+	// Path of the lesson's page, e.g. advanced/tuning.
+	Path string `json:"path"`
+	// Until is when the lesson opens, in RFC 3339 format;
+	// empty if it opens only when the instructor says.
+	Until string `json:"until,omitempty"`
+}
+
+// Search is a document of kind KindSearch.
+type Search struct {
+	Header
+	Query string      `json:"query"`
+	Hits  []SearchHit `json:"hits"`
+}
+
+// SearchHit is a lesson matching a search, best matches first.
+type SearchHit struct {
+	// Path of the lesson's page, e.g. setup/install.
+	Path  string `json:"path"`
+	Title string `json:"title"`
+	// Lesson is the lesson's index, as in Status.
+	Lesson int `json:"lesson"`
+	// Snippet is plain text of the lesson around the first hit.
+	Snippet string `json:"snippet"`
+}
+
+// Preview is a document of kind KindPreview.
+type Preview struct {
+	Header
+	// HTML is the edited lesson's prose and code, rendered.
+	HTML   string        `json:"html"`
+	Blocks []BlockChange `json:"blocks"`
+}
+
+// BlockChange is what an edit did to a block.  The blocks
+// of the edited lesson, and those the edit removed, come in
+// the order they'd appear in a diff.
+type BlockChange struct {
+	Name string `json:"name"`
+	// Change is same, changed, added or removed.
+	Change string `json:"change"`
+}
+
+// Proposal is a document of kind KindProposal.
+type Proposal struct {
+	Header
+	// Branch holding the edit, e.g. mdrip/edit-install-1700000000.
+	Branch string `json:"branch"`
+	Commit string `json:"commit"`
+	// URL, present only if the branch was pushed to a host
+	// mdrip knows, opens a pull request of the branch.
+	URL string `json:"url,omitempty"`
+}
+
+// Lessons is a document of kind KindLessons.
+type Lessons struct {
+	Header
+	Lessons []LessonSummary `json:"lessons"`
+}
+
+// LessonSummary is a lesson served in demo mode.
+type LessonSummary struct {
+	// Path of the lesson's page, e.g. setup/install.
+	Path  string `json:"path"`
+	Title string `json:"title"`
+	// Lesson is the lesson's index, as in Status.
+	Lesson int `json:"lesson"`
+	// Blocks is how many blocks of code the lesson has.
+	Blocks int `json:"blocks"`
+}
+
+// Blocks is a document of kind KindBlocks.
+type Blocks struct {
+	Header
+	// Path of the lesson's page, e.g. setup/install.
+	Path   string        `json:"path"`
+	Blocks []BlockWithID `json:"blocks"`
+}
+
+// BlockWithID is a block, with what names it to demo mode.
+type BlockWithID struct {
+	// ID is "{lessonIndex}/{blockIndex}", as in Status,
+	// e.g. for running the block via /api/v1/run/{id}.
+	ID string `json:"id"`
+	Block
+}
+
+func newBlock(
+	name string, line int, labels []base.Label, tags []string,
+	language string, code base.OpaqueCode) Block {
+	x := Block{name, line, []string{}, tags, language, code.String()}
+	for _, l := range labels {
+		x.Labels = append(x.Labels, string(l))
+	}
+	return x
+}
+
+// NewProgram makes a document from a program.
+func NewProgram(p *program.Program) *Program {
+	result := &Program{header(KindProgram), "", []Lesson{}}
+	if p.Label() != base.WildCardLabel {
+		result.Label = string(p.Label())
+	}
+	for _, l := range p.Lessons() {
+		x := Lesson{string(l.Path()), []Block{}}
+		for _, b := range l.Blocks() {
+			x.Blocks = append(x.Blocks, newBlock(
+				b.Name(), b.Line(), b.Labels(), b.Tags(), b.Language(), b.Code()))
+		}
+		result.Lessons = append(result.Lessons, x)
+	}
+	return result
+}
+
+// treeBuilder visits a tutorial, building Nodes.  If tag isn't
+// empty, it leaves out blocks lacking the tag, and lessons and
+// courses left with nothing in them.
+type treeBuilder struct {
+	tag   string
+	nodes []Node
+	// lessonTags are the tags of the lesson being visited.
+	lessonTags []string
+}
+
+func (v *treeBuilder) hasTag(tags []string) bool {
+	if len(v.tag) == 0 {
+		return true
+	}
+	for _, t := range tags {
+		if t == v.tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *treeBuilder) children(t model.Tutorial) []Node {
+	saved := v.nodes
+	v.nodes = []Node{}
+	for _, x := range t.Children() {
+		x.Accept(v)
+		if n := len(v.nodes); n > 0 && len(v.tag) > 0 &&
+			v.nodes[n-1].Kind == "course" && len(v.nodes[n-1].Children) == 0 {
+			v.nodes = v.nodes[:n-1]
+		}
+	}
+	result := v.nodes
+	v.nodes = saved
+	return result
+}
+
+func (v *treeBuilder) VisitBlockTut(b *model.BlockTut) {
+	if len(b.Code()) == 0 {
+		return
+	}
+	tags := base.MergeTags(v.lessonTags, b.Tags())
+	if !v.hasTag(tags) {
+		return
+	}
+	x := newBlock(b.Name(), b.Line(), b.Labels(), tags, b.Language(), b.Code())
+	v.nodes = append(v.nodes, Node{Kind: "block", Name: b.Name(), Block: &x})
+}
+
+func (v *treeBuilder) VisitLessonTut(l *model.LessonTut) {
+	v.lessonTags = l.Tags()
+	children := v.children(l)
+	v.lessonTags = nil
+	if len(children) == 0 && !v.hasTag(l.AllTags()) {
+		return
+	}
+	n := Node{
+		Kind: "lesson", Name: l.Name(), Path: string(l.Path()),
+		Title: l.Title(), Tags: l.Tags(), Children: children}
+	if r := l.Revision(); r != nil {
+		n.Modified = r.When.Format(time.RFC3339)
+		n.ModifiedBy = r.Author
+	}
+	v.nodes = append(v.nodes, n)
+}
+
+func (v *treeBuilder) VisitCourse(c *model.Course) {
+	v.nodes = append(v.nodes, Node{
+		Kind: "course", Name: c.Name(), Path: string(c.Path()), Children: v.children(c)})
+}
+
+func (v *treeBuilder) VisitTopCourse(t *model.TopCourse) {
+	v.VisitCourse(&t.Course)
+}
+
+// NewTree makes a document from a tutorial.
+func NewTree(t model.Tutorial) *Tree {
+	return NewTreeForTag(t, "")
+}
+
+// NewTreeForTag makes a document from the parts of a tutorial with
+// the given tag; all of it if the tag is empty.  A block has the tags
+// of its lesson.
+func NewTreeForTag(t model.Tutorial, tag string) *Tree {
+	v := &treeBuilder{tag: strings.ToLower(tag)}
+	t.Accept(v)
+	if len(v.nodes) == 0 {
+		// The tutorial is a lesson lacking the tag.
+		return &Tree{header(KindTree), Node{Kind: "course"}}
+	}
+	return &Tree{header(KindTree), v.nodes[0]}
+}
+
+// AuditRecord is a document of kind KindAuditRecord.
+type AuditRecord struct {
+	Header
+	// Seq numbers the records of a log, from 1.
+	Seq int `json:"seq"`
+	// Time the record was written, in RFC 3339 format.
+	Time string `json:"time"`
+	// User who ran the block, and Host it ran on.
+	User string `json:"user"`
+	Host string `json:"host"`
+	// Source of the tutorial, as given on the command line.
+	Source string `json:"source"`
+	File   string `json:"file"`
+	Index  int    `json:"index"`
+	Name   string `json:"name"`
+	// CodeSHA256 is the SHA-256, in hex, of the code run, which
+	// the log doesn't hold, lest it hold secrets the code was given.
+	CodeSHA256 string `json:"codeSha256"`
+	// State is passed, failed or skipped.
+	State string `json:"state"`
+	// ExitCode of a failed block; absent if unknown.
+	ExitCode int     `json:"exitCode,omitempty"`
+	Seconds  float64 `json:"seconds"`
+	// Prev is the Hash of the record before; empty for the first.
+	Prev string `json:"prev"`
+	// Hash, in hex, is the SHA-256, or, if the log is signed, the
+	// HMAC-SHA256, of the record as written, with an empty Hash.
+	Hash string `json:"hash"`
+}
+
+// NewAuditRecords makes a document for each block of the result
+// of a run, by the given user on the given host, of the tutorial
+// from the given source.  Their Seq, Time, Prev and Hash are
+// left for the log they're appended to to fill in.
+func NewAuditRecords(user, host, source string, r *subshell.RunResult) []AuditRecord {
+	var result []AuditRecord
+	for _, b := range r.Reports() {
+		state := "passed"
+		switch {
+		case b.Failed():
+			state = "failed"
+		case b.Skipped():
+			state = "skipped"
+		}
+		result = append(result, AuditRecord{
+			Header: header(KindAuditRecord), User: user, Host: host, Source: source,
+			File: string(b.FileName()), Index: b.Index(), Name: b.Block().Name(),
+			CodeSHA256: fmt.Sprintf("%x", sha256.Sum256([]byte(b.Block().Code()))),
+			State:      state, ExitCode: b.ExitCode(), Seconds: b.Elapsed().Seconds()})
+	}
+	return result
+}
+
+// NewResults makes a document from the result of a test mode run.
+func NewResults(r *subshell.RunResult) *Results {
+	result := &Results{header(KindResults), r.Error() == nil, "", []BlockResult{}}
+	if r.Error() != nil {
+		result.Error = r.Error().Error()
+	}
+	for _, b := range r.Reports() {
+		state := "passed"
+		switch {
+		case b.Failed():
+			state = "failed"
+		case b.Skipped():
+			state = "skipped"
+		}
+		result.Blocks = append(result.Blocks, BlockResult{
+			string(b.FileName()), b.Index(), b.Block().Name(), b.Block().Tags(),
+			b.Block().Interpreter(), state,
+			b.StdOut(), b.StdErr(), b.Elapsed().Seconds(), b.StateChanges()})
+	}
+	return result
+}
+
+// NewStatus makes a document from block states keyed by
+// "{lessonIndex}/{blockIndex}", and how blocks are delivered.
+func NewStatus(
+	states map[string]string, seconds map[string]float64, delivery string) *Status {
+	return &Status{header(KindStatus), states, seconds, delivery}
+}
+
+// NewOutput makes a document holding output of the given block.
+func NewOutput(block, stream, text string) *Output {
+	return &Output{Header: header(KindOutput), Block: block, Stream: stream, Text: text}
+}
+
+// NewOutputState makes a document holding the new state of the
+// given block, with its exit status if that's not negative.
+func NewOutputState(block, state string, exitStatus int) *Output {
+	result := &Output{Header: header(KindOutput), Block: block, State: state}
+	if exitStatus >= 0 {
+		result.ExitStatus = &exitStatus
+	}
+	return result
+}
+
+// NewJump makes a document telling pages to show
+// the lesson with the given index and page path.
+func NewJump(lesson int, path string) *Jump {
+	return &Jump{header(KindJump), lesson, path}
+}
+
+// NewSchedule makes a document listing the locked lessons.
+func NewSchedule(locked []LockedLesson) *Schedule {
+	if locked == nil {
+		locked = []LockedLesson{}
+	}
+	return &Schedule{header(KindSchedule), locked}
+}
+
+// NewSearch makes a document holding the lessons matching a query.
+func NewSearch(query string, hits []SearchHit) *Search {
+	if hits == nil {
+		hits = []SearchHit{}
+	}
+	return &Search{header(KindSearch), query, hits}
+}
+
+// NewPreview makes a document holding an edited
+// lesson's HTML and the changes to its blocks.
+func NewPreview(html string, blocks []BlockChange) *Preview {
+	if blocks == nil {
+		blocks = []BlockChange{}
+	}
+	return &Preview{header(KindPreview), html, blocks}
+}
+
+// NewProposal makes a document describing an edit
+// committed to a branch, with a URL if there is one.
+func NewProposal(branch, commit, url string) *Proposal {
+	return &Proposal{header(KindProposal), branch, commit, url}
+}
+
+// NewLessons makes a document listing lessons.
+func NewLessons(lessons []LessonSummary) *Lessons {
+	if lessons == nil {
+		lessons = []LessonSummary{}
+	}
+	return &Lessons{header(KindLessons), lessons}
+}
+
+// NewBlocks makes a document holding the blocks of code of
+// the lesson at the given page path and lesson index.
+func NewBlocks(path string, lessonIndex int, l *program.LessonPgm) *Blocks {
+	result := &Blocks{header(KindBlocks), path, []BlockWithID{}}
+	for i, b := range l.Blocks() {
+		if len(b.Code()) == 0 {
+			continue
+		}
+		result.Blocks = append(result.Blocks, BlockWithID{
+			fmt.Sprintf("%d/%d", lessonIndex, i),
+			newBlock(b.Name(), b.Line(), b.Labels(), b.Tags(), b.Language(), b.Code())})
+	}
+	return result
+}
+
+// Write writes a document as indented JSON.
+func Write(w io.Writer, doc interface{}) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(doc)
+}
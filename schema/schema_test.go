@@ -0,0 +1,157 @@
+package schema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+	"github.com/monopole/mdrip/program"
+	"github.com/monopole/mdrip/subshell"
+)
+
+func tutorial() model.Tutorial {
+	b := model.NewBlockParsed(
+		[]base.Label{"install"}, base.MdProse("prose"), base.OpaqueCode("date\n"))
+	b.SetLine(7)
+	b.SetLanguage("bash")
+	return model.NewCourse(base.FilePath("course"), []model.Tutorial{
+		model.NewLessonTutForTests(
+			base.FilePath("course/setup.md"), []*model.BlockTut{model.NewBlockTut(b)})})
+}
+
+// checkKeys checks that the document's keys are those of its schema.
+func checkKeys(t *testing.T, kind string, doc interface{}) {
+	d, ok := Document(kind)
+	if !ok {
+		t.Fatalf("no schema for %s", kind)
+	}
+	var s struct {
+		Required   []string                   `json:"required"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal([]byte(d), &s); err != nil {
+		t.Fatalf("schema %s isn't JSON: %v", kind, err)
+	}
+	var b strings.Builder
+	if err := Write(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(b.String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	for k := range got {
+		if _, ok := s.Properties[k]; !ok {
+			t.Errorf("%s: key %q not in schema", kind, k)
+		}
+	}
+	for _, k := range s.Required {
+		if _, ok := got[k]; !ok {
+			t.Errorf("%s: required key %q missing", kind, k)
+		}
+	}
+	if got["version"] != Version || got["kind"] != kind {
+		t.Errorf("%s: got header %v %v", kind, got["version"], got["kind"])
+	}
+}
+
+func TestDocuments(t *testing.T) {
+	if strings.Join(Names(), ",") != "auditRecord,blocks,jump,lessons,output,preview,program,proposal,results,schedule,search,status,tree" {
+		t.Errorf("got names %v", Names())
+	}
+	tut := tutorial()
+	p := program.NewProgramFromTutorial(base.WildCardLabel, tut)
+	checkKeys(t, KindTree, NewTree(tut))
+	checkKeys(t, KindProgram, NewProgram(p))
+	checkKeys(t, KindResults, NewResults(subshell.NewRunResult(nil, nil)))
+	checkKeys(t, KindStatus, NewStatus(
+		map[string]string{"0/1": "ok"}, map[string]float64{"0/1": 1.5}, DeliveryTmux))
+	checkKeys(t, KindOutput, NewOutput("0/1", "stdout", "hello\n"))
+	done := NewOutputState("0/1", "failed", 2)
+	done.Seconds = 1.5
+	checkKeys(t, KindOutput, done)
+	checkKeys(t, KindJump, NewJump(2, "belgium/beer"))
+	checkKeys(t, KindSchedule, NewSchedule([]LockedLesson{{3, "advanced/tuning", "2024-05-01T14:00:00Z"}}))
+	checkKeys(t, KindSearch, NewSearch("beer", []SearchHit{{"belgium/beer", "Beer", 6, "Beer..."}}))
+	checkKeys(t, KindPreview, NewPreview("<p>Beer</p>", []BlockChange{{"pour", "changed"}}))
+	checkKeys(t, KindProposal, NewProposal("mdrip/edit-beer-1", "abc123", "https://x.io/pull"))
+	checkKeys(t, KindLessons, NewLessons([]LessonSummary{{"belgium/beer", "Beer", 6, 2}}))
+	checkKeys(t, KindBlocks, NewBlocks("belgium/beer", 0, p.Lessons()[0]))
+	r := subshell.NewRunResult(nil, nil).SetReports([]*subshell.BlockReport{
+		subshell.NewBlockReport("course/setup.md", 0, p.Lessons()[0].Blocks()[0], 0, "", "", time.Second)})
+	records := NewAuditRecords("ann", "box", "course", r)
+	if len(records) != 1 || records[0].State != "passed" || len(records[0].CodeSHA256) != 64 {
+		t.Fatalf("got %+v", records)
+	}
+	checkKeys(t, KindAuditRecord, records[0])
+}
+
+func TestNewTree(t *testing.T) {
+	tree := NewTree(tutorial())
+	if tree.Root.Kind != "course" || len(tree.Root.Children) != 1 {
+		t.Fatalf("unexpected root %+v", tree.Root)
+	}
+	lesson := tree.Root.Children[0]
+	if lesson.Kind != "lesson" || lesson.Path != "course/setup.md" ||
+		lesson.Title != "setup" || len(lesson.Children) != 1 {
+		t.Fatalf("unexpected lesson %+v", lesson)
+	}
+	b := lesson.Children[0].Block
+	if b == nil || b.Name != "install" || b.Line != 7 ||
+		b.Language != "bash" || b.Code != "date\n" {
+		t.Errorf("unexpected block %+v", b)
+	}
+}
+
+func TestNewTreeRevision(t *testing.T) {
+	l := model.NewLessonTutForTests(base.FilePath("setup.md"), []*model.BlockTut{})
+	if x := NewTree(l).Root; len(x.Modified) > 0 || len(x.ModifiedBy) > 0 {
+		t.Errorf("got revision %s %s for a lesson lacking one", x.Modified, x.ModifiedBy)
+	}
+	l.SetRevision(model.NewRevision(time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC), "Tess"))
+	if x := NewTree(l).Root; x.Modified != "2024-03-01T09:30:00Z" || x.ModifiedBy != "Tess" {
+		t.Errorf("got revision %s %s", x.Modified, x.ModifiedBy)
+	}
+}
+
+func TestNewOutputState(t *testing.T) {
+	if x := NewOutputState("0/1", "running", -1); x.ExitStatus != nil {
+		t.Errorf("got exit status %d for a running block", *x.ExitStatus)
+	}
+	if x := NewOutputState("0/1", "ok", 0); x.ExitStatus == nil || *x.ExitStatus != 0 {
+		t.Errorf("expected exit status 0, got %v", x.ExitStatus)
+	}
+}
+
+func TestNewTreeForTag(t *testing.T) {
+	tagged := model.NewBlockParsed(
+		[]base.Label{"tags=GCP"}, base.MdProse("prose"), base.OpaqueCode("gcloud info\n"))
+	plain := model.NewBlockParsed(
+		[]base.Label{"install"}, base.MdProse("prose"), base.OpaqueCode("date\n"))
+	tut := model.NewCourse(base.FilePath("course"), []model.Tutorial{
+		model.NewLessonTutForTests(base.FilePath("course/setup.md"),
+			[]*model.BlockTut{model.NewBlockTut(plain), model.NewBlockTut(tagged)}),
+		model.NewLessonTutForTests(base.FilePath("course/more.md"),
+			[]*model.BlockTut{model.NewBlockTut(plain)})})
+	if n := len(NewTree(tut).Root.Children); n != 2 {
+		t.Fatalf("expected 2 lessons without a tag, got %d", n)
+	}
+	tree := NewTreeForTag(tut, "gcp")
+	if len(tree.Root.Children) != 1 {
+		t.Fatalf("expected 1 lesson tagged gcp, got %+v", tree.Root.Children)
+	}
+	lesson := tree.Root.Children[0]
+	if lesson.Path != "course/setup.md" || len(lesson.Children) != 1 {
+		t.Fatalf("unexpected lesson %+v", lesson)
+	}
+	b := lesson.Children[0].Block
+	if b == nil || b.Code != "gcloud info\n" || strings.Join(b.Tags, ",") != "gcp" {
+		t.Errorf("unexpected block %+v", b)
+	}
+	if x := NewTreeForTag(tut, "aws").Root; x.Path != "course" || len(x.Children) != 0 {
+		t.Errorf("expected an empty course for tag aws, got %+v", x)
+	}
+}
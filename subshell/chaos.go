@@ -0,0 +1,105 @@
+package subshell
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Chaos is trouble to make for a command that blocks call, so that
+// tutorials' retry and wait instructions are tested, not just their
+// passing on a fast network.
+type Chaos struct {
+	// Command is the name of the command, e.g. kubectl.
+	Command string
+	// Delay is how long each call waits before running the command.
+	Delay time.Duration
+	// Failures is how many of the first calls fail, with
+	// exit status 1, rather than run the command.
+	Failures int
+}
+
+var commandName = regexp.MustCompile(`^[A-Za-z0-9_.+-]+$`)
+
+// ParseChaos parses a spec like kubectl=delay:2s,fail:2, meaning
+// delay each call of kubectl by 2s, and fail its first 2 calls.
+func ParseChaos(spec string) (Chaos, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || !commandName.MatchString(parts[0]) || len(parts[1]) == 0 {
+		return Chaos{}, errors.Errorf(
+			"chaos %q should look like command=delay:2s,fail:2", spec)
+	}
+	result := Chaos{Command: parts[0]}
+	for _, x := range strings.Split(parts[1], ",") {
+		kv := strings.SplitN(x, ":", 2)
+		if len(kv) != 2 {
+			return Chaos{}, errors.Errorf("chaos %q: %q should be delay:{duration} or fail:{count}", spec, x)
+		}
+		var err error
+		switch kv[0] {
+		case "delay":
+			result.Delay, err = time.ParseDuration(kv[1])
+			if err == nil && result.Delay < 0 {
+				err = errors.New("negative delay")
+			}
+		case "fail":
+			result.Failures, err = strconv.Atoi(kv[1])
+			if err == nil && result.Failures < 0 {
+				err = errors.New("negative count")
+			}
+		default:
+			err = errors.Errorf("unknown trouble %q", kv[0])
+		}
+		if err != nil {
+			return Chaos{}, errors.Wrapf(err, "chaos %q", spec)
+		}
+	}
+	return result, nil
+}
+
+// shimScript returns a script, to be found on the PATH before the
+// command it's named for, making the chaos's trouble, then, unless
+// failing, running the command found on the PATH less the shim's
+// directory.  Calls are counted in a file beside the shim.
+func shimScript(c Chaos, dir string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#!/bin/bash\n# mdrip --chaos shim for %s.\n", c.Command)
+	if c.Delay > 0 {
+		fmt.Fprintf(&b, "sleep %g\n", c.Delay.Seconds())
+	}
+	if c.Failures > 0 {
+		calls := "'" + filepath.Join(dir, c.Command+".calls") + "'"
+		fmt.Fprintf(&b, "n=$(( $(cat %s 2>/dev/null || echo 0) + 1 ))\necho $n > %s\n", calls, calls)
+		fmt.Fprintf(&b, "if [ $n -le %d ]; then\n"+
+			"  echo \"mdrip: --chaos fails call $n of %s\" 1>&2\n  exit 1\nfi\n",
+			c.Failures, c.Command)
+	}
+	fmt.Fprintf(&b, "PATH=\"${PATH//'%s:'/}\" exec %s \"$@\"\n", dir, c.Command)
+	return b.String()
+}
+
+// writeShims writes a shim for each chaos to a new temporary
+// directory, returning it, for the caller to put first on the
+// PATH, and to remove when done.
+func writeShims(chaos []Chaos) (string, error) {
+	dir, err := ioutil.TempDir("", "mdrip-chaos-")
+	if err != nil {
+		return "", err
+	}
+	for _, c := range chaos {
+		err := ioutil.WriteFile(
+			filepath.Join(dir, c.Command), []byte(shimScript(c, dir)), 0755)
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+	return dir, nil
+}
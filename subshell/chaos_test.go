@@ -0,0 +1,76 @@
+package subshell
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+	"github.com/monopole/mdrip/program"
+)
+
+func TestParseChaos(t *testing.T) {
+	for spec, want := range map[string]Chaos{
+		"kubectl=delay:2s,fail:2": {"kubectl", 2 * time.Second, 2},
+		"curl=fail:1":             {"curl", 0, 1},
+		"gcloud=delay:500ms":      {"gcloud", 500 * time.Millisecond, 0},
+	} {
+		got, err := ParseChaos(spec)
+		if err != nil {
+			t.Errorf("%s: %v", spec, err)
+		} else if got != want {
+			t.Errorf("%s: got %v, want %v", spec, got, want)
+		}
+	}
+	for _, bad := range []string{
+		"kubectl", "=fail:1", "kubectl=", "kubectl=fail", "kubectl=fail:x",
+		"kubectl=delay:-1s", "kubectl=crash:1", "a/b=fail:1",
+	} {
+		if _, err := ParseChaos(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestChaos(t *testing.T) {
+	block := func(code string, labels ...base.Label) *program.BlockPgm {
+		return program.NewBlockPgmFromBlockTut(model.NewBlockTut(model.NewBlockParsed(
+			labels, base.MdProse("prose"), base.OpaqueCode(code))))
+	}
+	chaos := []Chaos{{"date", 100 * time.Millisecond, 2}}
+	for _, test := range []struct {
+		retries string
+		wantErr bool
+	}{
+		{"retries=2", false},
+		{"retries=1", true},
+	} {
+		p := program.NewProgram([]*program.LessonPgm{program.NewLessonPgm(
+			base.FilePath("arbitraryPath"), []*program.BlockPgm{
+				block("date +%Y\n", "wait", base.Label(test.retries)),
+			})})
+		r, err := NewRunner(NameBash, RunnerOptions{BlockTimeOut: timeout, Chaos: chaos})
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		result := r.Run(p)
+		if (result.Error() != nil) != test.wantErr {
+			t.Errorf("%s: got error %v", test.retries, result.Error())
+			continue
+		}
+		if !strings.Contains(result.Reports()[0].StdErr(), "mdrip: --chaos fails call 1 of date") {
+			t.Errorf("%s: got stderr %q", test.retries, result.Reports()[0].StdErr())
+		}
+		if time.Since(start) < 200*time.Millisecond {
+			t.Errorf("%s: took %v, want each call delayed", test.retries, time.Since(start))
+		}
+		if !test.wantErr && !strings.HasPrefix(result.Reports()[0].StdOut(), "20") {
+			t.Errorf("%s: got stdout %q, want the year", test.retries, result.Reports()[0].StdOut())
+		}
+	}
+	if _, err := NewRunner(NameDocker, RunnerOptions{Image: "ubuntu:22.04", Chaos: chaos}); err == nil {
+		t.Errorf("--chaos should make no sense with --runner docker")
+	}
+}
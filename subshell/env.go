@@ -0,0 +1,89 @@
+package subshell
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseEnv splits a KEY=VALUE assignment, e.g. REGION=us-east1.
+func ParseEnv(s string) (string, string, error) {
+	i := strings.Index(s, "=")
+	if i < 0 || !envName.MatchString(s[:i]) {
+		return "", "", errors.Errorf("%q isn't KEY=VALUE", s)
+	}
+	return s[:i], s[i+1:], nil
+}
+
+// ReadEnvFile reads KEY=VALUE assignments, one per line, as
+// "docker run --env-file" and dotenv files hold them.  Blank
+// lines and # comments are skipped, a leading "export " is
+// dropped, and a value wholly in matching quotes loses them.
+func ReadEnvFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var result []string
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		l := strings.TrimSpace(s.Text())
+		if len(l) == 0 || strings.HasPrefix(l, "#") {
+			continue
+		}
+		k, v, err := ParseEnv(strings.TrimPrefix(l, "export "))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s:%d", path, n)
+		}
+		if len(v) > 1 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		}
+		result = append(result, k+"="+v)
+	}
+	return result, s.Err()
+}
+
+// BaseEnv names the variables FilterEnv always lets through,
+// which blocks may fairly expect of any machine.
+var BaseEnv = []string{"HOME", "LANG", "LOGNAME", "PATH", "SHELL", "TMPDIR", "TZ", "USER"}
+
+// FilterEnv returns the KEY=VALUE assignments of env, e.g.
+// os.Environ(), whose keys are in BaseEnv or match one of the
+// patterns, e.g. GOPATH or AWS_*.  The result isn't nil, so
+// that, as an exec.Cmd's Env, it replaces mdrip's environment.
+func FilterEnv(env, patterns []string) []string {
+	patterns = append(append([]string{}, BaseEnv...), patterns...)
+	result := []string{}
+	for _, e := range env {
+		k := e
+		if i := strings.Index(e, "="); i >= 0 {
+			k = e[:i]
+		}
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, k); ok {
+				result = append(result, e)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// exportScript returns shell code exporting the KEY=VALUE
+// assignments, quoting each value so the shell takes it as is.
+func exportScript(env []string) string {
+	var b strings.Builder
+	for _, e := range env {
+		i := strings.Index(e, "=")
+		b.WriteString("export " + e[:i] + "='" +
+			strings.Replace(e[i+1:], "'", `'\''`, -1) + "'\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,94 @@
+package subshell
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/program"
+)
+
+func TestReadEnvFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdrip-env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	n := filepath.Join(dir, "vars.env")
+	ioutil.WriteFile(n, []byte("# for staging\nPROJECT=zebra\n\nexport REGION=\"us east\"\nEMPTY=\nURL=http://x/?a=b\n"), 0644)
+	got, err := ReadEnvFile(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"PROJECT=zebra", "REGION=us east", "EMPTY=", "URL=http://x/?a=b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	ioutil.WriteFile(n, []byte("PROJECT=zebra\nnot an assignment\n"), 0644)
+	if _, err := ReadEnvFile(n); err == nil || !strings.Contains(err.Error(), "vars.env:2") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestSetEnv(t *testing.T) {
+	lesson := program.NewLessonPgm(base.FilePath("arbitraryPath"), []*program.BlockPgm{
+		makeBlock("echo \"$PROJECT/$QUOTED\"\n")})
+	p := program.NewProgram([]*program.LessonPgm{lesson})
+	r := NewSubshell(timeout, p).SetEnv([]string{"PROJECT=zebra", "QUOTED=it's $HOME"}).Run()
+	if r.Error() != nil {
+		t.Fatal(r.Error())
+	}
+	if got := r.Reports()[0].StdOut(); !strings.Contains(got, "zebra/it's $HOME") {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestFilterEnv(t *testing.T) {
+	got := FilterEnv([]string{
+		"PATH=/bin", "CI_TOKEN=secret", "AWS_REGION=us-east-1", "AWS_PROFILE=ci", "GOPATH=/go", "HOME=/root",
+	}, []string{"AWS_*"})
+	want := []string{"PATH=/bin", "AWS_REGION=us-east-1", "AWS_PROFILE=ci", "HOME=/root"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := FilterEnv(nil, nil); got == nil {
+		t.Errorf("got nil, which would let all of mdrip's environment through")
+	}
+}
+
+func TestClearEnv(t *testing.T) {
+	os.Setenv("MDRIP_TEST_SECRET", "agent")
+	defer os.Unsetenv("MDRIP_TEST_SECRET")
+	lesson := program.NewLessonPgm(base.FilePath("arbitraryPath"), []*program.BlockPgm{
+		makeBlock("echo \"[${MDRIP_TEST_SECRET:-}] [$PROJECT]\"\nls / > /dev/null\n")})
+	p := program.NewProgram([]*program.LessonPgm{lesson})
+	for _, test := range []struct {
+		clear bool
+		pass  []string
+		want  string
+	}{
+		{false, nil, "[agent] [zebra]"},
+		{true, nil, "[] [zebra]"},
+		{true, []string{"MDRIP_TEST_*"}, "[agent] [zebra]"},
+	} {
+		r, err := NewRunner(NameBash, RunnerOptions{BlockTimeOut: timeout,
+			Env: []string{"PROJECT=zebra"}, ClearEnv: test.clear, PassEnv: test.pass})
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := r.Run(p)
+		if result.Error() != nil {
+			t.Fatal(result.Error())
+		}
+		if got := result.Reports()[0].StdOut(); !strings.Contains(got, test.want) {
+			t.Errorf("%v %v: got %q, want %q", test.clear, test.pass, got, test.want)
+		}
+	}
+	if _, err := NewRunner(NameSSH, RunnerOptions{Host: "me@vm", ClearEnv: true}); err == nil {
+		t.Errorf("--envClear should make no sense with --runner ssh")
+	}
+}
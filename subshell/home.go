@@ -0,0 +1,131 @@
+package subshell
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// homeVars are the variables pointing tools at their config and
+// credentials, and where, below a hermetic home, they point.
+var homeVars = []struct{ name, path string }{
+	{"XDG_CONFIG_HOME", ".config"},
+	{"XDG_CACHE_HOME", ".cache"},
+	{"XDG_DATA_HOME", ".local/share"},
+	{"XDG_STATE_HOME", ".local/state"},
+	{"KUBECONFIG", ".kube/config"},
+	{"CLOUDSDK_CONFIG", ".config/gcloud"},
+	{"AWS_CONFIG_FILE", ".aws/config"},
+	{"AWS_SHARED_CREDENTIALS_FILE", ".aws/credentials"},
+	{"AZURE_CONFIG_DIR", ".azure"},
+	{"DOCKER_CONFIG", ".docker"},
+}
+
+// homeUnset are variables naming credential files that
+// would be outside a hermetic home, so are unset in it.
+var homeUnset = []string{"GOOGLE_APPLICATION_CREDENTIALS"}
+
+// homeScript returns shell code pointing HOME, and the
+// variables in homeVars, into the given directory.
+func homeScript(home string) string {
+	env := []string{"HOME=" + home}
+	for _, v := range homeVars {
+		env = append(env, v.name+"="+filepath.Join(home, filepath.FromSlash(v.path)))
+	}
+	script := exportScript(env)
+	for _, v := range homeUnset {
+		script += "unset " + v + "\n"
+	}
+	return script
+}
+
+// makeHome makes a hermetic home: a new temporary directory
+// with the XDG directories in it, and a copy of the files
+// below the seed directory, if that's not empty.
+func makeHome(seed string) (string, error) {
+	dir, err := ioutil.TempDir("", "mdrip-home-")
+	if err != nil {
+		return "", err
+	}
+	if len(seed) > 0 {
+		if err := copyTree(seed, dir); err != nil {
+			removeHome(dir)
+			return "", errors.Wrap(err, "unable to seed home from "+seed)
+		}
+	}
+	for _, v := range homeVars {
+		if !strings.HasPrefix(v.name, "XDG_") {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Join(dir, filepath.FromSlash(v.path)), 0700); err != nil {
+			removeHome(dir)
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// removeHome removes a hermetic home, first making its
+// directories writable, as some tools, e.g. go, leave
+// read-only ones behind.
+func removeHome(dir string) {
+	filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() {
+			os.Chmod(p, info.Mode().Perm()|0700)
+		}
+		return nil
+	})
+	if err := os.RemoveAll(dir); err != nil {
+		glog.Errorf("unable to remove home %s: %v", dir, err)
+	}
+}
+
+// copyTree copies the directories, files and symlinks
+// below src to below dst, keeping their permissions.
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(p, target, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
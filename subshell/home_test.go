@@ -0,0 +1,46 @@
+package subshell
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/program"
+)
+
+func TestHermeticHome(t *testing.T) {
+	seed, err := ioutil.TempDir("", "mdrip-seed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(seed)
+	os.MkdirAll(filepath.Join(seed, ".kube"), 0755)
+	ioutil.WriteFile(filepath.Join(seed, ".kube", "config"), []byte("cluster: kind\n"), 0600)
+	lesson := program.NewLessonPgm(base.FilePath("arbitraryPath"), []*program.BlockPgm{
+		makeBlock("echo \"$HOME\"\ncat \"$KUBECONFIG\"\n"),
+		makeBlock("test \"$XDG_CONFIG_HOME\" = \"$HOME/.config\" && test -d \"$XDG_CONFIG_HOME\"\n" +
+			"echo zebra > ~/.bashrc\nmkdir -p ~/go/pkg && chmod 0555 ~/go/pkg\n")})
+	p := program.NewProgram([]*program.LessonPgm{lesson})
+	r := NewSubshell(timeout, p).SetHermeticHome(true, seed).Run()
+	if r.Error() != nil {
+		t.Fatal(r.Error(), r.StdErr())
+	}
+	out := strings.Split(r.Reports()[0].StdOut(), "\n")
+	home := out[0]
+	if real, _ := os.UserHomeDir(); home == real || !strings.Contains(home, "mdrip-home-") {
+		t.Errorf("got HOME %q", home)
+	}
+	if out[1] != "cluster: kind" {
+		t.Errorf("got %q, want the seed's kube config", out[1])
+	}
+	if _, err := os.Stat(home); !os.IsNotExist(err) {
+		t.Errorf("home %s wasn't removed: %v", home, err)
+	}
+
+	if _, err := makeHome(filepath.Join(seed, "nonesuch")); err == nil {
+		t.Errorf("expected an error seeding from a missing directory")
+	}
+}
@@ -0,0 +1,190 @@
+package subshell
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/program"
+)
+
+type reportState int
+
+const (
+	reportPassed reportState = iota
+	reportFailed
+	// reportSkipped means the block never ran, because an earlier one failed.
+	reportSkipped
+)
+
+// BlockReport says what became of one block in a run.
+type BlockReport struct {
+	fileName base.FilePath
+	index    int
+	block    *program.BlockPgm
+	state    reportState
+	stdOut   string
+	stdErr   string
+	elapsed  time.Duration
+	// exitCode is the exit status of a failed block, if known.
+	exitCode int
+	// stateChanges say how the block changed the shell's
+	// state, if that was captured.
+	stateChanges []string
+}
+
+// NewBlockReport is a ctor for BlockReport.
+func NewBlockReport(
+	n base.FilePath, i int, b *program.BlockPgm, s reportState,
+	stdOut, stdErr string, elapsed time.Duration) *BlockReport {
+	return &BlockReport{n, i, b, s, stdOut, stdErr, elapsed, 0, nil}
+}
+
+// FileName is the file holding the block.
+func (x *BlockReport) FileName() base.FilePath { return x.fileName }
+
+// Index of the block in its file.
+func (x *BlockReport) Index() int { return x.index }
+
+// Block reported on.
+func (x *BlockReport) Block() *program.BlockPgm { return x.block }
+
+// Passed is true if the block ran without error.
+func (x *BlockReport) Passed() bool { return x.state == reportPassed }
+
+// Failed is true if the block failed or timed out.
+func (x *BlockReport) Failed() bool { return x.state == reportFailed }
+
+// Skipped is true if the block never ran.
+func (x *BlockReport) Skipped() bool { return x.state == reportSkipped }
+
+// StdOut captured from the block.
+func (x *BlockReport) StdOut() string { return x.stdOut }
+
+// StdErr captured from the block.
+func (x *BlockReport) StdErr() string { return x.stdErr }
+
+// ExitCode is the exit status of a failed block, or 0 if unknown,
+// e.g. because the block timed out, or didn't meet expectations.
+func (x *BlockReport) ExitCode() int { return x.exitCode }
+
+// StateChanges say how the block changed the shell's state, one
+// change per line, e.g. "+ env KUBECONFIG=/tmp/kc" or "+ file
+// ./main.go"; empty if nothing changed, or state wasn't captured.
+func (x *BlockReport) StateChanges() []string { return x.stateChanges }
+
+// Elapsed is roughly how long the block took.
+func (x *BlockReport) Elapsed() time.Duration { return x.elapsed }
+
+// The JUnit XML schema, as understood by Jenkins and GitLab.
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name       string           `xml:"name,attr"`
+	ClassName  string           `xml:"classname,attr"`
+	Time       string           `xml:"time,attr"`
+	Properties *junitProperties `xml:"properties,omitempty"`
+	Failure    *junitFailure    `xml:"failure,omitempty"`
+	Skipped    *struct{}        `xml:"skipped,omitempty"`
+	SystemOut  string           `xml:"system-out,omitempty"`
+	SystemErr  string           `xml:"system-err,omitempty"`
+}
+
+type junitProperties struct {
+	Properties []junitProperty `xml:"property"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// WriteJUnit writes the result's block reports as JUnit XML,
+// with one test suite per file, and one test case per block.
+func WriteJUnit(w io.Writer, r *RunResult) error {
+	var suites junitSuites
+	var elapsed []time.Duration
+	for _, b := range r.Reports() {
+		n := len(suites.Suites)
+		if n == 0 || suites.Suites[n-1].Name != string(b.FileName()) {
+			suites.Suites = append(suites.Suites, junitSuite{Name: string(b.FileName())})
+			elapsed = append(elapsed, 0)
+			n++
+		}
+		s := &suites.Suites[n-1]
+		c := junitCase{
+			Name:      fmt.Sprintf("%d %s", b.Index()+1, b.Block().Name()),
+			ClassName: string(b.FileName()),
+			Time:      seconds(b.Elapsed()),
+			SystemOut: b.StdOut(),
+			SystemErr: b.StdErr(),
+		}
+		var props []junitProperty
+		for _, t := range b.Block().Tags() {
+			props = append(props, junitProperty{"tag", t})
+		}
+		if in := b.Block().Interpreter(); len(in) > 0 {
+			props = append(props, junitProperty{"interpreter", in})
+		}
+		for _, x := range b.StateChanges() {
+			props = append(props, junitProperty{"state", x})
+		}
+		if len(props) > 0 {
+			c.Properties = &junitProperties{props}
+		}
+		s.Tests++
+		elapsed[n-1] += b.Elapsed()
+		switch {
+		case b.Failed():
+			s.Failures++
+			msg := "block failed"
+			if in := b.Block().Interpreter(); len(in) > 0 {
+				msg = in + " block failed"
+			}
+			if r.Error() != nil {
+				msg = r.Error().Error()
+			}
+			c.Failure = &junitFailure{msg, b.Block().Code().String()}
+		case b.Skipped():
+			s.Skipped++
+			c.Skipped = &struct{}{}
+		}
+		s.Cases = append(s.Cases, c)
+	}
+	for i := range suites.Suites {
+		suites.Suites[i].Time = seconds(elapsed[i])
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,207 @@
+package subshell
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monopole/mdrip/program"
+	"github.com/pkg/errors"
+)
+
+// Runner runs a program's blocks somewhere, reporting
+// what became of them.
+type Runner interface {
+	Run(p *program.Program) *RunResult
+}
+
+// RunnerOptions are what a RunnerFactory may use to make a Runner.
+type RunnerOptions struct {
+	// BlockTimeOut is how long to wait for a block
+	// that has no timeout of its own.
+	BlockTimeOut time.Duration
+	// Image is a container image to run blocks in, if the runner uses one.
+	Image string
+	// Host is a user@host to run blocks on, if the runner uses one.
+	Host string
+	// KeepGoing runs every block, even after one fails.
+	KeepGoing bool
+	// CaptureState reports how each block changed the shell's state.
+	CaptureState bool
+	// Parallel, if more than 1, is how many groups of lessons that
+	// don't require each other may run at once, each in its own shell
+	// and temporary working directory.
+	Parallel int
+	// Env holds KEY=VALUE assignments to export in each shell
+	// before running blocks.
+	Env []string
+	// ClearEnv starts blocks with none of mdrip's environment
+	// but the variables in BaseEnv and those matching PassEnv,
+	// so they can't come to rely on, say, a CI agent's.
+	ClearEnv bool
+	// PassEnv holds names, or patterns like AWS_*, of the
+	// variables that ClearEnv lets through.
+	PassEnv []string
+	// RunAs, if not empty, is the user to run blocks as, via sudo.
+	RunAs string
+	// SudoAskpass, if not empty, is the program sudo runs to get a
+	// password; otherwise sudo fails rather than prompt for one.
+	SudoAskpass string
+	// Chaos is trouble to make for commands the blocks call,
+	// e.g. delays and failures, to test tutorials' retries.
+	Chaos []Chaos
+	// HermeticHome runs each shell with HOME, and the config
+	// directories of common tools, in a new temporary directory,
+	// so blocks can't read or change the operator's own.
+	HermeticHome bool
+	// HomeSeed, if not empty, is a directory whose files
+	// are copied into each hermetic home.
+	HomeSeed string
+}
+
+// RunnerFactory makes a Runner, or complains about the options.
+type RunnerFactory func(o RunnerOptions) (Runner, error)
+
+var runners = map[string]RunnerFactory{}
+
+// RegisterRunner makes a runner available, by name, to NewRunner.
+// Registering a name twice replaces the first factory.
+func RegisterRunner(name string, f RunnerFactory) {
+	runners[name] = f
+}
+
+// RunnerNames are the names of the registered runners, sorted.
+func RunnerNames() []string {
+	result := make([]string, 0, len(runners))
+	for n := range runners {
+		result = append(result, n)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// NewRunner makes the runner registered with the given name.
+func NewRunner(name string, o RunnerOptions) (Runner, error) {
+	f, ok := runners[name]
+	if !ok {
+		return nil, errors.Errorf(
+			"unknown runner %q; choose from %s", name, strings.Join(RunnerNames(), ", "))
+	}
+	return f(o)
+}
+
+// shellRunner runs programs with a Subshell in shells it makes.
+type shellRunner struct {
+	blockTimeout time.Duration
+	keepGoing    bool
+	captureState bool
+	parallel     int
+	env          []string
+	sudoAskpass  string
+	chaos        []Chaos
+	hermeticHome bool
+	homeSeed     string
+	newShell     func() Shell
+}
+
+func (r *shellRunner) run(p *program.Program, scratch bool) *RunResult {
+	return NewSubshellInShell(r.blockTimeout, p, r.newShell()).
+		SetKeepGoing(r.keepGoing).SetCaptureState(r.captureState).
+		SetScratch(scratch).SetEnv(r.env).SetSudoAskpass(r.sudoAskpass).SetChaos(r.chaos).
+		SetHermeticHome(r.hermeticHome, r.homeSeed).Run()
+}
+
+// Run runs the program in one shell, or, to run in parallel, splits
+// it into groups of lessons that don't require each other, running
+// up to r.parallel groups at once, and merges their results.  A
+// failing block stops only the rest of its own group.
+func (r *shellRunner) Run(p *program.Program) *RunResult {
+	if r.parallel < 2 {
+		return r.run(p, false)
+	}
+	parts := p.Split()
+	results := make([]*RunResult, len(parts))
+	slots := make(chan bool, r.parallel)
+	var wg sync.WaitGroup
+	for i, part := range parts {
+		wg.Add(1)
+		go func(i int, part *program.Program) {
+			defer wg.Done()
+			slots <- true
+			defer func() { <-slots }()
+			results[i] = r.run(part, true)
+		}(i, part)
+	}
+	wg.Wait()
+	return mergeResults(p, results)
+}
+
+func init() {
+	RegisterRunner(NameBash, func(o RunnerOptions) (Runner, error) {
+		if len(o.Image) > 0 {
+			return nil, errors.Errorf("--image makes no sense with --runner %s", NameBash)
+		}
+		if len(o.Host) > 0 {
+			return nil, errors.Errorf("--host makes no sense with --runner %s", NameBash)
+		}
+		if o.HermeticHome && len(o.RunAs) > 0 {
+			return nil, errors.Errorf("--hermeticHome makes no sense with --runAs, whose user can't write the home")
+		}
+		var env []string
+		if o.ClearEnv {
+			env = FilterEnv(os.Environ(), o.PassEnv)
+		}
+		return &shellRunner{o.BlockTimeOut, o.KeepGoing, o.CaptureState, o.Parallel, o.Env,
+			o.SudoAskpass, o.Chaos, o.HermeticHome, o.HomeSeed,
+			func() Shell { return &bashShell{env, o.RunAs, o.SudoAskpass, nil} }}, nil
+	})
+	RegisterRunner(NameDocker, func(o RunnerOptions) (Runner, error) {
+		if len(o.Image) == 0 {
+			return nil, errors.Errorf("--runner %s needs an --image, e.g. ubuntu:22.04", NameDocker)
+		}
+		if len(o.Host) > 0 {
+			return nil, errors.Errorf("--host makes no sense with --runner %s", NameDocker)
+		}
+		if o.ClearEnv {
+			// The container has its own environment.
+			return nil, errors.Errorf("--envClear makes no sense with --runner %s", NameDocker)
+		}
+		if len(o.RunAs) > 0 {
+			return nil, errors.Errorf("--runAs makes no sense with --runner %s", NameDocker)
+		}
+		if len(o.Chaos) > 0 {
+			// The shims are written on this machine.
+			return nil, errors.Errorf("--chaos makes no sense with --runner %s", NameDocker)
+		}
+		if o.HermeticHome {
+			return nil, errors.Errorf("--hermeticHome makes no sense with --runner %s", NameDocker)
+		}
+		return &shellRunner{o.BlockTimeOut, o.KeepGoing, o.CaptureState, o.Parallel, o.Env,
+			o.SudoAskpass, nil, false, "", func() Shell { return &dockerShell{image: o.Image} }}, nil
+	})
+	RegisterRunner(NameSSH, func(o RunnerOptions) (Runner, error) {
+		if len(o.Host) == 0 {
+			return nil, errors.Errorf("--runner %s needs a --host, e.g. user@host", NameSSH)
+		}
+		if len(o.Image) > 0 {
+			return nil, errors.Errorf("--image makes no sense with --runner %s", NameSSH)
+		}
+		if o.ClearEnv {
+			// The host has its own environment.
+			return nil, errors.Errorf("--envClear makes no sense with --runner %s", NameSSH)
+		}
+		if len(o.RunAs) > 0 {
+			return nil, errors.Errorf("--runAs makes no sense with --runner %s", NameSSH)
+		}
+		if len(o.Chaos) > 0 {
+			return nil, errors.Errorf("--chaos makes no sense with --runner %s", NameSSH)
+		}
+		if o.HermeticHome {
+			return nil, errors.Errorf("--hermeticHome makes no sense with --runner %s", NameSSH)
+		}
+		return &shellRunner{o.BlockTimeOut, o.KeepGoing, o.CaptureState, o.Parallel, o.Env,
+			o.SudoAskpass, nil, false, "", func() Shell { return &sshShell{dest: o.Host} }}, nil
+	})
+}
@@ -0,0 +1,100 @@
+package subshell
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/program"
+)
+
+func TestNewRunner(t *testing.T) {
+	for _, test := range []struct {
+		name, image, host, err string
+	}{
+		{NameBash, "", "", ""},
+		{NameBash, "ubuntu:22.04", "", "makes no sense"},
+		{NameDocker, "ubuntu:22.04", "", ""},
+		{NameDocker, "", "", "needs an --image"},
+		{NameSSH, "", "me@vm", ""},
+		{NameSSH, "", "", "needs a --host"},
+		{"kubernetes", "", "", "unknown runner \"kubernetes\"; choose from bash, docker, ssh"},
+	} {
+		_, err := NewRunner(test.name, RunnerOptions{timeout, test.image, test.host, false, false, 0, nil, false, nil, "", "", nil, false, ""})
+		if len(test.err) == 0 && err != nil {
+			t.Errorf("%s %s: unexpected error %v", test.name, test.image, err)
+		}
+		if len(test.err) > 0 && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%s %s: got error %v, want %q", test.name, test.image, err, test.err)
+		}
+	}
+}
+
+type countingRunner struct {
+	blocks int
+}
+
+func (r *countingRunner) Run(p *program.Program) *RunResult {
+	for _, l := range p.Lessons() {
+		r.blocks += len(l.Blocks())
+	}
+	return NewRunResult(nil, nil)
+}
+
+func TestRegisterRunner(t *testing.T) {
+	c := &countingRunner{}
+	RegisterRunner("counting", func(o RunnerOptions) (Runner, error) { return c, nil })
+	defer delete(runners, "counting")
+	r, err := NewRunner("counting", RunnerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lesson := program.NewLessonPgm(base.FilePath("arbitraryPath"),
+		[]*program.BlockPgm{makeBlock("date\n"), makeBlock("date\n")})
+	r.Run(program.NewProgram([]*program.LessonPgm{lesson}))
+	if c.blocks != 2 {
+		t.Errorf("got %d blocks, want 2", c.blocks)
+	}
+}
+
+func TestParallel(t *testing.T) {
+	r, err := NewRunner(NameBash, RunnerOptions{BlockTimeOut: timeout, Parallel: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lesson := func(path string, code ...string) *program.LessonPgm {
+		var blocks []*program.BlockPgm
+		for _, c := range code {
+			blocks = append(blocks, makeBlock(c))
+		}
+		return program.NewLessonPgm(base.FilePath(path), blocks)
+	}
+	result := r.Run(program.NewProgram([]*program.LessonPgm{
+		lesson("slow.md", "sleep 0.2\npwd\n", "touch mine\n"),
+		lesson("broken.md", "echo oops 1>&2\nfalse\n", "echo never\n"),
+		lesson("quick.md", "pwd\n", "ls\n"),
+	}))
+	checkFail(t, result, 0, "oops")
+	if result.FileName() != "broken.md" {
+		t.Errorf("got failure in %s, want broken.md", result.FileName())
+	}
+	var got []string
+	for _, b := range result.Reports() {
+		got = append(got, string(b.FileName()))
+	}
+	if want := "slow.md slow.md broken.md broken.md quick.md quick.md"; strings.Join(got, " ") != want {
+		t.Errorf("got reports for %v, want %s", got, want)
+	}
+	reports := result.Reports()
+	if !reports[1].Passed() || !reports[3].Skipped() || !reports[5].Passed() {
+		t.Errorf("only the rest of the failing lesson should be skipped")
+	}
+	if !strings.Contains(reports[0].StdOut(), "mdrip-work-") ||
+		reports[0].StdOut() == reports[4].StdOut() {
+		t.Errorf("got directories %q and %q, want a scratch directory each",
+			reports[0].StdOut(), reports[4].StdOut())
+	}
+	if reports[5].StdOut() != "" {
+		t.Errorf("got %q, want an empty scratch directory", reports[5].StdOut())
+	}
+}
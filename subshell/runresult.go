@@ -2,8 +2,12 @@ package subshell
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/monopole/mdrip/base"
 	"github.com/monopole/mdrip/program"
@@ -26,6 +30,12 @@ const (
 type BlockOutput struct {
 	completed status
 	output    string
+	// exitCode is the block's exit status, if it failed
+	// without stopping the shell.
+	exitCode int
+	// snapshots of the shell's state taken while the block's
+	// output was collected; the last follows the block.
+	snapshots []string
 }
 
 // Completed is true if the stream was processed without error.  Does not
@@ -35,6 +45,12 @@ func (x BlockOutput) Completed() bool {
 	return x.completed == yep
 }
 
+// ExitCode is the exit status of a block that failed, with the shell
+// carrying on to the next block, else 0.
+func (x BlockOutput) ExitCode() int {
+	return x.exitCode
+}
+
 // Output returns text accumulated from a stream.
 func (x BlockOutput) Output() string {
 	return x.output
@@ -42,12 +58,18 @@ func (x BlockOutput) Output() string {
 
 // NewIncompleteOutput returns a BlockOutput configured to signal incompletion.
 func NewIncompleteOutput(output string) *BlockOutput {
-	return &BlockOutput{nope, output}
+	return &BlockOutput{nope, output, 0, nil}
 }
 
 // NewCompleteOutput returns a BlockOutput configured to signal completion.
 func NewCompleteOutput(output string) *BlockOutput {
-	return &BlockOutput{yep, output}
+	return &BlockOutput{yep, output, 0, nil}
+}
+
+// NewFailedOutput returns a BlockOutput of a block that failed
+// with the given exit status, the shell carrying on.
+func NewFailedOutput(output string, code int) *BlockOutput {
+	return &BlockOutput{yep, output, code, nil}
 }
 
 // RunResult pairs BlockOutput with meta data about shell execution.
@@ -58,6 +80,7 @@ type RunResult struct {
 	index    int               // Index of command block with error.
 	block    *program.BlockPgm // The command block with the error.
 	anErr    error             // Shell error, if any.
+	reports  []*BlockReport    // The fate of every block.
 }
 
 // NewRunResult is a ctor for RunResult.
@@ -65,7 +88,7 @@ func NewRunResult(out, err *BlockOutput) *RunResult {
 	return &RunResult{
 		out, err, "", -1,
 		program.NewEmptyBlockPgm(),
-		nil}
+		nil, nil}
 }
 
 // HasProgrammerError is one of those "This should never happen" things.
@@ -134,14 +157,68 @@ func (x *RunResult) SetBlock(b *program.BlockPgm) *RunResult {
 	return x
 }
 
+// Block is the failing block, if any.
+func (x *RunResult) Block() *program.BlockPgm {
+	return x.block
+}
+
+// SetReports sets the reports on each block.
+func (x *RunResult) SetReports(r []*BlockReport) *RunResult {
+	x.reports = r
+	return x
+}
+
+// Reports says what became of each block, in the order run.
+func (x *RunResult) Reports() []*BlockReport {
+	return x.reports
+}
+
+// mergeResults combines the results of running parts of a program
+// at once.  Reports are put back in the order of the program's
+// lessons, and the result is that of the part holding the earliest
+// lesson that failed, if any did.
+func mergeResults(p *program.Program, results []*RunResult) *RunResult {
+	order := map[base.FilePath]int{}
+	for i, l := range p.Lessons() {
+		order[l.Path()] = i
+	}
+	var reports []*BlockReport
+	merged := NewRunResult(nil, nil)
+	failedAt := len(p.Lessons())
+	for _, r := range results {
+		reports = append(reports, r.Reports()...)
+		if r.Error() == nil {
+			continue
+		}
+		// A result failing without a file failed to start at all.
+		at, ok := order[r.FileName()]
+		if !ok {
+			at = -1
+		}
+		if at < failedAt {
+			merged, failedAt = r, at
+		}
+	}
+	sort.SliceStable(reports, func(i, j int) bool {
+		return order[reports[i].FileName()] < order[reports[j].FileName()]
+	})
+	return merged.SetReports(reports)
+}
+
 // Print reports the result to stderr.
 func (x *RunResult) Print(selectedLabel base.Label) {
 	delim := strings.Repeat("-", 70) + "\n"
 	fmt.Fprintf(os.Stderr, delim)
 	x.block.Print(os.Stderr, "Error", x.index+1, selectedLabel, x.fileName)
 	fmt.Fprintf(os.Stderr, delim)
+	if in := x.block.Interpreter(); len(in) > 0 {
+		fmt.Fprintf(os.Stderr, "The block above failed in %s, not the shell.\n", in)
+	}
 	printCapturedOutput("stdOut", delim, x.StdOut())
 	printCapturedOutput("stdErr", delim, x.StdErr())
+	if n := x.block.Index(); n > 0 {
+		fmt.Fprintf(os.Stderr, "To resume at this block: --startAt %s:%d\n", x.fileName, n)
+	}
 }
 
 func printCapturedOutput(name, delim, output string) {
@@ -151,3 +228,48 @@ func printCapturedOutput(name, delim, output string) {
 	fmt.Fprintf(os.Stderr, "\n")
 	fmt.Fprintf(os.Stderr, delim)
 }
+
+// PrintSummary writes a table saying what became of each block:
+// its file, index, label (i.e. name), state, exit status and
+// duration, followed by a count of each state.
+func (x *RunResult) PrintSummary(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tBLOCK\tLABEL\tSTATUS\tEXIT\tTIME")
+	passed, failed, skipped := 0, 0, 0
+	for _, r := range x.reports {
+		state, exit := "passed", "0"
+		switch {
+		case r.Failed():
+			failed++
+			state, exit = "FAILED", "-"
+			if r.ExitCode() != 0 {
+				exit = strconv.Itoa(r.ExitCode())
+			}
+		case r.Skipped():
+			skipped++
+			state, exit = "skipped", "-"
+		default:
+			passed++
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%.2fs\n",
+			r.FileName(), r.Index()+1, r.Block().Name(), state, exit,
+			r.Elapsed().Seconds())
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d passed, %d failed, %d skipped\n", passed, failed, skipped)
+}
+
+// PrintStateChanges writes, for each block that changed the
+// shell's state, its file, index and label, then the changes.
+func (x *RunResult) PrintStateChanges(w io.Writer) {
+	for _, r := range x.reports {
+		if len(r.StateChanges()) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s block %d (%s) changed:\n",
+			r.FileName(), r.Index()+1, r.Block().Name())
+		for _, c := range r.StateChanges() {
+			fmt.Fprintf(w, "  %s\n", c)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package subshell
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// Shell starts the process that runs the script made from a program.
+type Shell interface {
+	// Command returns a command, not yet started, running the script.
+	Command(script string) (*exec.Cmd, error)
+	// Stop ends whatever the command started that would outlive
+	// it, e.g. a container, once the command's been killed.
+	Stop()
+	// Cleanup releases whatever Command made, once the command is done.
+	Cleanup()
+}
+
+// Names of the runners using shells.
+const (
+	// NameBash runs the script with the local bash.
+	NameBash = "bash"
+	// NameDocker runs the script with bash in a docker container.
+	NameDocker = "docker"
+	// NameSSH runs the script with bash on another host.
+	NameSSH = "ssh"
+)
+
+// bashShell runs the script in a local bash subprocess, with
+// the given environment, or, if that's nil, mdrip's.  Given a
+// user to run as, it runs bash as that user via sudo, streaming
+// the script to it, since the user may not be able to read it.
+type bashShell struct {
+	env []string
+	// runAs, if not empty, is the user to run bash as.
+	runAs string
+	// askpass, if not empty, is the program sudo runs to
+	// get a password; otherwise sudo never prompts.
+	askpass string
+	script  *os.File
+}
+
+func (s *bashShell) Command(script string) (*exec.Cmd, error) {
+	if len(s.runAs) == 0 {
+		cmd := exec.Command("bash", script)
+		cmd.Env = s.env
+		return cmd, nil
+	}
+	if _, err := exec.LookPath("sudo"); err != nil {
+		return nil, errors.Wrap(err, "--runAs needs sudo on the PATH")
+	}
+	f, err := os.Open(script)
+	if err != nil {
+		return nil, err
+	}
+	s.script = f
+	prompt := "-n"
+	if len(s.askpass) > 0 {
+		prompt = "-A"
+	}
+	cmd := exec.Command("sudo", prompt, "-u", s.runAs, "--", "bash", "-s")
+	cmd.Stdin = f
+	cmd.Env = s.env
+	if len(s.askpass) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, "SUDO_ASKPASS="+s.askpass)
+	}
+	return cmd, nil
+}
+
+// Stop does nothing; bash is the command.
+func (s *bashShell) Stop() {}
+
+func (s *bashShell) Cleanup() {
+	if s.script != nil {
+		s.script.Close()
+		s.script = nil
+	}
+}
+
+// dockerShell runs the script with bash in a throwaway container,
+// in a scratch working directory made on the host and mounted at
+// /work, so blocks can't touch the host's files.
+type dockerShell struct {
+	image   string
+	scratch string
+	// name is the container's, so it can be killed; killing
+	// the docker client leaves the container running.
+	name string
+}
+
+const (
+	dockerScript  = "/mdrip-script.sh"
+	dockerWorkDir = "/work"
+)
+
+func (s *dockerShell) Command(script string) (*exec.Cmd, error) {
+	if _, err := exec.LookPath(NameDocker); err != nil {
+		return nil, errors.Wrap(err, "--runner docker needs docker on the PATH")
+	}
+	dir, err := ioutil.TempDir("", "mdrip-work-")
+	if err != nil {
+		return nil, err
+	}
+	s.scratch = dir
+	s.name = fmt.Sprintf("mdrip-%d-%d", os.Getpid(), time.Now().UnixNano())
+	return exec.Command(NameDocker, "run", "--rm", "--init", "--name", s.name,
+		"-v", script+":"+dockerScript+":ro",
+		"-v", dir+":"+dockerWorkDir,
+		"-w", dockerWorkDir,
+		s.image, "bash", dockerScript), nil
+}
+
+// Stop kills the container, which, run with --rm, is then removed.
+func (s *dockerShell) Stop() {
+	if len(s.name) == 0 {
+		return
+	}
+	if o, err := exec.Command(NameDocker, "kill", s.name).CombinedOutput(); err != nil {
+		glog.Warningf("unable to kill container %s: %v %s", s.name, err, o)
+	}
+}
+
+func (s *dockerShell) Cleanup() {
+	if len(s.scratch) > 0 {
+		os.RemoveAll(s.scratch)
+		s.scratch = ""
+	}
+}
+
+// sshShell streams the script over ssh to bash on another host,
+// where it runs in the login directory of the given user@host.
+// The ssh command exits with the script's status.
+type sshShell struct {
+	dest   string
+	script *os.File
+	// tag, given to the remote bash as its argument, lets Stop
+	// find it; without a tty, killing ssh doesn't end it.
+	tag string
+}
+
+func (s *sshShell) Command(script string) (*exec.Cmd, error) {
+	if _, err := exec.LookPath(NameSSH); err != nil {
+		return nil, errors.Wrap(err, "--runner ssh needs ssh on the PATH")
+	}
+	f, err := os.Open(script)
+	if err != nil {
+		return nil, err
+	}
+	s.script = f
+	s.tag = fmt.Sprintf("mdrip-%d-%d", os.Getpid(), time.Now().UnixNano())
+	// sshd runs the command in a session of its own; exec makes
+	// bash lead it, so the session holds all the script starts.
+	cmd := exec.Command(NameSSH, append(sshOptions, s.dest, "exec bash -s "+s.tag)...)
+	cmd.Stdin = f
+	return cmd, nil
+}
+
+// sshOptions has BatchMode, since there's nobody to answer
+// a password prompt.
+var sshOptions = []string{"-T", "-o", "BatchMode=yes"}
+
+// Stop kills, over another connection, the session of the
+// remote bash: it and every process its blocks started.
+func (s *sshShell) Stop() {
+	if len(s.tag) == 0 {
+		return
+	}
+	kill := fmt.Sprintf(
+		`sid=$(pgrep -o -x -f 'bash -s %s') && pkill -KILL -s "$sid"`, s.tag)
+	o, err := exec.Command(NameSSH, append(sshOptions, s.dest, kill)...).CombinedOutput()
+	if err != nil {
+		glog.Warningf("unable to stop the script on %s: %v %s", s.dest, err, o)
+	}
+}
+
+func (s *sshShell) Cleanup() {
+	if s.script != nil {
+		s.script.Close()
+		s.script = nil
+	}
+}
@@ -0,0 +1,140 @@
+package subshell
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/program"
+)
+
+// fakeDocker pretends to be docker: run records the container,
+// by its --name, in the running directory, then hangs; kill
+// forgets it, as docker run --rm would.
+const fakeDocker = `#!/bin/sh
+dir=$(dirname "$0")/running
+case "$1" in
+run)
+  while [ "$1" != "--name" ]; do shift; done
+  touch "$dir/$2"
+  exec sleep 30
+  ;;
+kill)
+  rm "$dir/$2"
+  ;;
+esac
+`
+
+func TestDockerStopsContainerOnTimeOut(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdrip-docker-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	running := filepath.Join(dir, "running")
+	if err := os.Mkdir(running, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, NameDocker), []byte(fakeDocker), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	sh := &dockerShell{image: "ubuntu:22.04"}
+	lesson := program.NewLessonPgm(base.FilePath("arbitraryPath"),
+		[]*program.BlockPgm{makeBlock("sleep 30\n")})
+	result := NewSubshellInShell(timeout, program.NewProgram(
+		[]*program.LessonPgm{lesson}), sh).Run()
+	if result.Error() == nil {
+		t.Errorf("expected the block to time out")
+	}
+	if !strings.HasPrefix(sh.name, "mdrip-") {
+		t.Errorf("got container name %q", sh.name)
+	}
+	left, err := ioutil.ReadDir(running)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range left {
+		t.Errorf("container %s still running", c.Name())
+	}
+}
+
+// fakeSSH pretends to be ssh to the local host: it runs the
+// remote command in a session of its own, as sshd does, in the
+// background, so killing it leaves the command running, as
+// killing ssh without a tty does.
+const fakeSSH = `#!/bin/sh
+while [ "$1" = "-T" ] || [ "$1" = "-o" ]; do
+  [ "$1" = "-o" ] && shift
+  shift
+done
+shift
+exec 3<&0
+setsid sh -c "$*" <&3 3<&- &
+wait $!
+`
+
+func TestSSHStopsScriptOnTimeOut(t *testing.T) {
+	for _, p := range []string{"setsid", "pgrep", "pkill"} {
+		if _, err := exec.LookPath(p); err != nil {
+			t.Skip("no " + p)
+		}
+	}
+	dir, err := ioutil.TempDir("", "mdrip-ssh-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, NameSSH), []byte(fakeSSH), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	pidFile := filepath.Join(dir, "pid")
+	lesson := program.NewLessonPgm(base.FilePath("arbitraryPath"),
+		[]*program.BlockPgm{makeBlock("sleep 30 &\necho $! > " + pidFile + "\nwait\n")})
+	result := NewSubshellInShell(timeout, program.NewProgram(
+		[]*program.LessonPgm{lesson}), &sshShell{dest: "me@vm"}).Run()
+	if result.Error() == nil {
+		t.Errorf("expected the block to time out")
+	}
+	b, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Give the kill a moment to land.
+	time.Sleep(100 * time.Millisecond)
+	if isRunning(pid) {
+		syscall.Kill(pid, syscall.SIGKILL)
+		t.Errorf("the block's sleep, pid %d, is still running", pid)
+	}
+}
+
+// isRunning is true if the process is neither gone nor a
+// zombie, killed but not yet reaped, e.g. in a container
+// whose init doesn't reap orphans.
+func isRunning(pid int) bool {
+	b, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// The state follows the command's name, in parentheses.
+	s := string(b)
+	i := strings.LastIndex(s, ")")
+	return i < 0 || i+2 >= len(s) || s[i+2] != 'Z'
+}
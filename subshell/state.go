@@ -0,0 +1,106 @@
+package subshell
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/monopole/mdrip/scanner"
+)
+
+// stateScript prints, between markers on stdout, a snapshot of the
+// shell's state: its working directory, its exported variables, and
+// the files in and a little below its working directory.  It can't
+// fail, so it's safe under set -e.
+const stateScript = "echo " + scanner.MsgStateBegin + "\n" +
+	"echo \"dir $(pwd)\"\n" +
+	"for mdrip_n in $(compgen -e); do printf 'env %s=%q\\n' \"$mdrip_n\" \"${!mdrip_n}\"; done\n" +
+	"{ find . -mindepth 1 -maxdepth " + maxStateDepth + " -not -path './.git/*' 2>/dev/null |" +
+	" LC_ALL=C sort | head -n " + maxStateFiles + " | sed 's/^/file /'; } || true\n" +
+	"echo " + scanner.MsgStateEnd + "\n"
+
+// Bounds on the files listed in a snapshot, so that
+// running in, say, a home directory stays quick.
+const (
+	maxStateDepth = "3"
+	maxStateFiles = "2000"
+)
+
+// ignoredVariables change by themselves, or are mdrip's own.
+var ignoredVariables = map[string]bool{
+	"_": true, "PWD": true, "OLDPWD": true, "SHLVL": true,
+}
+
+// snapshot is the parsed output of stateScript.
+type snapshot struct {
+	dir   string
+	env   map[string]string
+	files map[string]bool
+}
+
+func parseSnapshot(s string) *snapshot {
+	result := &snapshot{"", map[string]string{}, map[string]bool{}}
+	for _, line := range strings.Split(s, "\n") {
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "dir":
+			result.dir = parts[1]
+		case "env":
+			nv := strings.SplitN(parts[1], "=", 2)
+			if len(nv) == 2 && !ignoredVariables[nv[0]] {
+				result.env[nv[0]] = nv[1]
+			}
+		case "file":
+			result.files[parts[1]] = true
+		}
+	}
+	return result
+}
+
+// diffState lists how the state in one snapshot changed to that
+// in the next, one change per line, sorted: "cd {dir}" if the
+// working directory changed, "+", "~" or "-" then "env {name}" for
+// an exported variable set, changed or unset, and, if the working
+// directory didn't change, "+" or "-" then "file {path}" for a file
+// added or removed.  An empty before, i.e. no snapshot, is taken
+// as no change.
+func diffState(before, after string) []string {
+	if len(before) == 0 || len(after) == 0 {
+		return nil
+	}
+	b, a := parseSnapshot(before), parseSnapshot(after)
+	var result []string
+	for n, v := range a.env {
+		if old, ok := b.env[n]; !ok {
+			result = append(result, "+ env "+n+"="+v)
+		} else if old != v {
+			result = append(result, "~ env "+n+"="+v)
+		}
+	}
+	for n := range b.env {
+		if _, ok := a.env[n]; !ok {
+			result = append(result, "- env "+n)
+		}
+	}
+	if a.dir == b.dir {
+		for f := range a.files {
+			if !b.files[f] {
+				result = append(result, "+ file "+f)
+			}
+		}
+		for f := range b.files {
+			if !a.files[f] {
+				result = append(result, "- file "+f)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i][2:] < result[j][2:]
+	})
+	if a.dir != b.dir {
+		result = append([]string{"cd " + a.dir}, result...)
+	}
+	return result
+}
@@ -0,0 +1,27 @@
+package subshell
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffState(t *testing.T) {
+	before := "dir /work\nenv HOME=/root\nenv VEG=kale\nenv _=/bin/ls\nfile ./a\nfile ./b\n"
+	for _, test := range []struct {
+		after string
+		want  []string
+	}{
+		{before, nil},
+		{"dir /work\nenv HOME=/root\nenv VEG=tofu\nenv X=$'a\\nb'\nenv _=/bin/cat\nfile ./b\nfile ./c\n",
+			[]string{"~ env VEG=tofu", "+ env X=$'a\\nb'", "- file ./a", "+ file ./c"}},
+		{"dir /tmp\nenv HOME=/root\nfile ./z\n",
+			[]string{"cd /tmp", "- env VEG"}},
+	} {
+		if got := diffState(before, test.after); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+	if got := diffState("", before); got != nil {
+		t.Errorf("got %q, want nothing without a snapshot before", got)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,11 +24,42 @@ import (
 type Subshell struct {
 	blockTimeout time.Duration
 	program      *program.Program
+	shell        Shell
+	// keepGoing runs every block, even after one fails.
+	keepGoing bool
+	// captureState reports how each block changed the shell's state.
+	captureState bool
+	// scratch runs the blocks in a new, temporary directory.
+	scratch bool
+	// env holds KEY=VALUE assignments exported before the blocks run.
+	env []string
+	// sudoAskpass, if not empty, is the program sudo runs to get
+	// a password; otherwise sudo fails rather than prompt for one.
+	sudoAskpass string
+	// chaos is trouble to make for commands the blocks call.
+	chaos []Chaos
+	// hermeticHome runs the blocks with HOME, and tools' config
+	// directories, in a new temporary directory, seeded with
+	// the files of homeSeed, if that's not empty.
+	hermeticHome bool
+	homeSeed     string
 }
 
 // NewSubshell returns a shell loaded with a program and block timeout ready to run.
 func NewSubshell(timeout time.Duration, p *program.Program) *Subshell {
-	return &Subshell{timeout, p}
+	return &Subshell{timeout, p, &bashShell{}, false, false, false, nil, "", nil, false, ""}
+}
+
+// NewSubshellInShell is like NewSubshell, but runs the program in the given shell.
+func NewSubshellInShell(timeout time.Duration, p *program.Program, sh Shell) *Subshell {
+	return &Subshell{timeout, p, sh, false, false, false, nil, "", nil, false, ""}
+}
+
+// SetKeepGoing says whether to run every block, even after one
+// fails, rather than stopping at the first failure.
+func (s *Subshell) SetKeepGoing(k bool) *Subshell {
+	s.keepGoing = k
+	return s
 }
 
 // accumulateOutput returns a channel to which it writes objects that
@@ -45,10 +77,27 @@ func NewSubshell(timeout time.Duration, p *program.Program) *Subshell {
 // flag, and the function exits early, before it's input channel closes.
 func accumulateOutput(prefix string, in <-chan string) <-chan *BlockOutput {
 	out := make(chan *BlockOutput)
-	var accum bytes.Buffer
+	var accum, state bytes.Buffer
+	var snapshots []string
+	inState := false
 	go func() {
 		defer close(out)
 		for line := range in {
+			if strings.HasPrefix(line, scanner.MsgStateBegin) {
+				inState = true
+				state.Reset()
+				continue
+			}
+			if inState && strings.HasPrefix(line, scanner.MsgStateEnd) {
+				inState = false
+				snapshots = append(snapshots, state.String())
+				continue
+			}
+			if inState && !strings.HasPrefix(line, scanner.MsgTimeout) &&
+				!strings.HasPrefix(line, scanner.MsgError) {
+				state.WriteString(line + "\n")
+				continue
+			}
 			if strings.HasPrefix(line, scanner.MsgTimeout) {
 				accum.WriteString("\n" + line + "\n")
 				accum.WriteString("A subprocess might still be running.\n")
@@ -70,7 +119,21 @@ func accumulateOutput(prefix string, in <-chan string) <-chan *BlockOutput {
 				if glog.V(2) {
 					glog.Infof("accum %s: %s", prefix, line)
 				}
-				out <- NewCompleteOutput(accum.String())
+				o := NewCompleteOutput(accum.String())
+				o.snapshots, snapshots = snapshots, nil
+				out <- o
+				accum.Reset()
+			} else if strings.HasPrefix(line, scanner.MsgFailed) {
+				if glog.V(2) {
+					glog.Infof("accum %s: %s", prefix, line)
+				}
+				code, err := strconv.Atoi(strings.TrimSpace(line[len(scanner.MsgFailed):]))
+				if err != nil || code == 0 {
+					code = 1
+				}
+				o := NewFailedOutput(accum.String(), code)
+				o.snapshots, snapshots = snapshots, nil
+				out <- o
 				accum.Reset()
 			} else {
 				// Normal accumulation.
@@ -111,13 +174,32 @@ func accumulateOutput(prefix string, in <-chan string) <-chan *BlockOutput {
 // all of its blocks, and return nil.  If this method doesn't
 // visit all of its blocks, the shell should have exited
 // with an error.
+//
+// Along the way it makes a BlockReport for every block; those
+// after a failing block are reported as not run.  A block whose
+// output isn't what an @expected block says fails too, but as
+// the shell carries on, the blocks after it are still reported.
+// So with blocks that fail in a shell told to keep going.
 func processShellOutput(
 	lessons []*program.LessonPgm,
 	chAccOut, chAccErr <-chan *BlockOutput) *RunResult {
 	var prevOut, prevErr *BlockOutput
+	var failure *RunResult
+	var reports []*BlockReport
+	// prevState is the last snapshot of the shell's state, if any.
+	prevState := ""
+	// stopped is true once the shell has stopped; a block
+	// merely failing to meet an expectation doesn't stop it.
+	stopped := false
+	start := time.Now()
 	for _, lesson := range lessons {
 		numBlocks := len(lesson.Blocks())
 		for i, block := range lesson.Blocks() {
+			if stopped {
+				reports = append(reports, NewBlockReport(
+					lesson.Path(), i, block, reportSkipped, "", "", 0))
+				continue
+			}
 			glog.Infof("Expecting output of %s (%d/%d) from %s\n",
 				block.Name(), i+1, numBlocks, lesson.Path())
 			if glog.V(2) {
@@ -125,19 +207,70 @@ func processShellOutput(
 			}
 			outBlock := <-chAccOut
 			errBlock := <-chAccErr
+			elapsed := time.Since(start)
+			start = time.Now()
 			// These can be nil if there was absolutely no output, either because
 			// there were no commands, or only commands with no output, e.g. /bin/false.
 			if outBlock == nil || !outBlock.Completed() ||
 				errBlock == nil || !errBlock.Completed() {
-				return NewRunResult(
+				stopped = true
+				r := NewRunResult(
 					outBlock, errBlock).SetFileName(lesson.Path()).SetIndex(i).SetBlock(block)
+				if failure == nil {
+					failure = r
+				}
+				reports = append(reports, NewBlockReport(
+					lesson.Path(), i, block, reportFailed,
+					r.StdOut(), r.StdErr(), elapsed))
+				continue
+			}
+			var changes []string
+			if n := len(outBlock.snapshots); n > 0 {
+				if n > 1 {
+					// The first block's output holds the first snapshot too.
+					prevState = outBlock.snapshots[n-2]
+				}
+				changes = diffState(prevState, outBlock.snapshots[n-1])
+				prevState = outBlock.snapshots[n-1]
+			}
+			newReport := func(s reportState) *BlockReport {
+				r := NewBlockReport(
+					lesson.Path(), i, block, s,
+					outBlock.Output(), errBlock.Output(), elapsed)
+				r.stateChanges = changes
+				return r
+			}
+			if code := outBlock.ExitCode(); code != 0 {
+				err := fmt.Errorf("exit status %d", code)
+				if failure == nil {
+					failure = NewRunResult(outBlock, errBlock).SetFileName(
+						lesson.Path()).SetIndex(i).SetBlock(block).SetError(err)
+				}
+				report := newReport(reportFailed)
+				report.exitCode = code
+				reports = append(reports, report)
+				continue
+			}
+			if x := block.Expectation(); x != nil {
+				if err := x.Check(outBlock.Output()); err != nil {
+					if failure == nil {
+						failure = NewRunResult(outBlock, errBlock).SetFileName(
+							lesson.Path()).SetIndex(i).SetBlock(block).SetError(err)
+					}
+					reports = append(reports, newReport(reportFailed))
+					continue
+				}
 			}
+			reports = append(reports, newReport(reportPassed))
 			prevOut = outBlock
 			prevErr = errBlock
 		}
 	}
+	if failure != nil {
+		return failure.SetReports(reports)
+	}
 	glog.Info("All done, no errors triggered.")
-	return NewRunResult(prevOut, prevErr)
+	return NewRunResult(prevOut, prevErr).SetReports(reports)
 }
 
 func writeString(writer io.Writer, output string) {
@@ -154,19 +287,89 @@ func writeString(writer io.Writer, output string) {
 // Start the script with exit on error options like -e.
 // After each block, add two echo commands, one for stderr, the other for stdout.
 // These echos will be used to associate output on either stream with the
-// command that produced it.
+// command that produced it.  A block with its own timeout is preceded by
+// two more, announcing the timeout on each stream.  A block with an
+// interpreter, e.g. python3, becomes a here document piped to it.
 // Doing this instead of writing to the shell's stdinpipe because of
 // https://github.com/monopole/mdrip/commit/a7be6a6fb62ccf8dfe1c2906515ce3e83d0400d7
-func writeFile(lessons []*program.LessonPgm) *os.File {
+//
+// To keep going after a failure, the script doesn't set -e (or -u,
+// which would also end the shell).  Instead each block becomes a
+// function, run in the shell itself so that its variables and
+// directory changes last, returning at the first failing command,
+// as -e would exit; the echos after it then say how it ended.
+//
+// A block to be retried becomes such a function too, with -e
+// off while it runs, called again while it fails.
+//
+// To capture state, the script snapshots it before the first
+// block, and after each, before the echos.
+//
+// Unless keeping going, which runs every block anyway, a lesson
+// with teardown blocks starts by arranging for them to run should
+// the shell exit before reaching them.
+//
+// In a scratch directory, the script first moves to a new
+// temporary directory, removing it when done.  Then it exports
+// the env, before the state snapshot, so the snapshot doesn't
+// report it as changed by the first block.  With chaos, the
+// directory of its shims, if not empty, goes first on the PATH.
+// A hermetic home, if not empty, is exported before the env, so
+// the env may override where it puts a tool's config.
+func (s *Subshell) writeFile(shims, home string) *os.File {
 	f, err := ioutil.TempFile("", "mdrip-file-")
 	util.Check("create temp file", err)
 	util.Check("chmod temp file", os.Chmod(f.Name(), 0744))
-	writeString(f, "set -e\n")
-	writeString(f, "set -u\n")
+	if s.scratch {
+		writeString(f, scratchScript)
+	}
+	if !s.keepGoing {
+		writeString(f, "set -e\n")
+		writeString(f, "set -u\n")
+	}
 	writeString(f, "set -o pipefail\n")
-	for _, lesson := range lessons {
+	if len(home) > 0 {
+		writeString(f, homeScript(home))
+	}
+	writeString(f, exportScript(s.env))
+	if len(shims) > 0 {
+		writeString(f, "export PATH='"+shims+"':\"$PATH\"\n")
+	}
+	if len(s.program.SudoBlocks()) > 0 {
+		writeString(f, sudoScript(s.sudoAskpass))
+	}
+	snapshot := ""
+	if s.captureState {
+		snapshot = stateScript
+		writeString(f, snapshot)
+	}
+	n := 0
+	for i, lesson := range s.program.Lessons() {
+		// guarded is true while a failure would run the lesson's teardown.
+		guarded := false
+		if !s.keepGoing && hasTeardown(lesson) {
+			writeString(f, teardownScript(i, lesson, s.scratch))
+			guarded = true
+		}
 		for _, block := range lesson.Blocks() {
-			writeString(f, block.Code().String())
+			if guarded && block.IsTeardown() {
+				writeString(f, exitTrap(s.scratch))
+				guarded = false
+			}
+			// prepareProgram checked the attributes.
+			t, _ := block.Timeout()
+			retries, _ := block.Retries()
+			if t > 0 {
+				writeString(f, "echo "+scanner.MsgTimeLimit+" "+t.String()+"\n")
+				writeString(f, "echo "+scanner.MsgTimeLimit+" "+t.String()+" 1>&2\n")
+			}
+			n++
+			if s.keepGoing {
+				writeString(f, keepGoingScript(n, block, retries, snapshot))
+				continue
+			}
+			writeString(f, program.RunScript(n, block, ""))
+			writeString(f, snapshot)
 			writeString(f, "echo "+scanner.MsgHappy+" "+block.Name()+"\n")
 			writeString(f, "echo "+scanner.MsgHappy+" "+block.Name()+" 1>&2\n\n")
 		}
@@ -177,6 +380,99 @@ func writeFile(lessons []*program.LessonPgm) *os.File {
 	return f
 }
 
+// keepGoingScript returns the shell code running the block as the
+// n'th function, retrying it as program.TryScript does, then the given
+// snapshot code, then reporting whether it failed on both streams.
+func keepGoingScript(n int, b *program.BlockPgm, retries int, snapshot string) string {
+	return program.TryScript(n, b, retries, "") + fmt.Sprintf(
+		"%s"+
+			"if [ $mdrip_status -eq 0 ]; then\n"+
+			"echo %s %s\necho %s %s 1>&2\n"+
+			"else\n"+
+			"echo %s $mdrip_status\necho %s $mdrip_status 1>&2\n"+
+			"fi\n\n",
+		snapshot,
+		scanner.MsgHappy, b.Name(), scanner.MsgHappy, b.Name(),
+		scanner.MsgFailed, scanner.MsgFailed)
+}
+
+// scratchCleanup removes the scratch directory.
+const scratchCleanup = "cd /; rm -rf \"$mdrip_scratch\"\n"
+
+// scratchScript moves the shell to a directory of its own,
+// removed when the shell exits.
+const scratchScript = "mdrip_scratch=$(mktemp -d \"${TMPDIR:-/tmp}/mdrip-work-XXXXXX\")\n" +
+	"trap '" + scratchCleanup + "' EXIT\n" +
+	"cd \"$mdrip_scratch\"\n"
+
+// exitTrap returns the shell code setting what runs when the
+// shell exits, outside a lesson's teardown: removing the
+// scratch directory, if any, else nothing.
+func exitTrap(scratch bool) string {
+	if scratch {
+		return "trap '" + scratchCleanup + "' EXIT\n"
+	}
+	return "trap - EXIT\n"
+}
+
+func hasTeardown(l *program.LessonPgm) bool {
+	for _, b := range l.Blocks() {
+		if b.IsTeardown() {
+			return true
+		}
+	}
+	return false
+}
+
+// teardownScript returns the shell code defining, as the n'th
+// teardown, a function running the lesson's teardown blocks,
+// then trapping the shell's exit to call it.  So if a block
+// fails, ending the shell, the teardown runs anyway, with -e
+// off so that every command in it gets a try.  The trap is
+// lifted when the teardown blocks are reached normally.
+// The teardown's output is reported as the failing block's.
+func teardownScript(n int, l *program.LessonPgm, scratch bool) string {
+	var b strings.Builder
+	fn := fmt.Sprintf("mdrip_teardown_%d", n)
+	fmt.Fprintf(&b, "%s() {\ntrap - EXIT\nset +eu\n", fn)
+	fmt.Fprintf(&b, "echo \"mdrip: running the teardown of %s\" 1>&2\n", l.Path())
+	var limit time.Duration
+	for _, x := range l.Blocks() {
+		if t, _ := x.Timeout(); x.IsTeardown() && t > limit {
+			limit = t
+		}
+	}
+	if limit > 0 {
+		fmt.Fprintf(&b, "echo %s %s\necho %s %s 1>&2\n",
+			scanner.MsgTimeLimit, limit, scanner.MsgTimeLimit, limit)
+	}
+	for _, x := range l.Blocks() {
+		if !x.IsTeardown() {
+			continue
+		}
+		code := program.BlockScript(x, "")
+		if !strings.HasSuffix(code, "\n") {
+			code += "\n"
+		}
+		b.WriteString(code)
+	}
+	if scratch {
+		b.WriteString(scratchCleanup)
+	}
+	fmt.Fprintf(&b, "}\ntrap %s EXIT\n\n", fn)
+	return b.String()
+}
+
+// sudoScript returns shell code saying how blocks run as root
+// call sudo: never prompting, so failing if a password is needed,
+// unless given a program to ask for one.
+func sudoScript(askpass string) string {
+	if len(askpass) == 0 {
+		return "mdrip_sudo='sudo -n'\n"
+	}
+	return exportScript([]string{"SUDO_ASKPASS=" + askpass}) + "mdrip_sudo='sudo -A'\n"
+}
+
 func makeAccumulator(
 	wait time.Duration, name string, stream io.ReadCloser) <-chan *BlockOutput {
 	return accumulateOutput(name, scanner.BuffScanner(wait, name, stream))
@@ -185,7 +481,8 @@ func makeAccumulator(
 // politeWait waits for shell to end, and return its exit error.
 // It doesn't wait long, because we presume the shell is either already done,
 // or is hung, and we've already burned s.blockTimeout waiting for it.
-func politeWait(shell *exec.Cmd) (err error) {
+// If it kills the shell, it calls stop, to end what the shell started.
+func politeWait(shell *exec.Cmd, stop func()) (err error) {
 	done := make(chan error, 1)
 	err = nil
 	go func() {
@@ -199,6 +496,7 @@ func politeWait(shell *exec.Cmd) (err error) {
 	case <-time.After(2 * time.Second):
 		glog.Infof("Run:  killing the shell after a polite wait")
 		err = shell.Process.Kill()
+		stop()
 		if err == nil {
 			err = errors.New("shell timed out")
 		} // else pass along the error from Kill.
@@ -210,6 +508,49 @@ func politeWait(shell *exec.Cmd) (err error) {
 	return
 }
 
+// SetCaptureState says whether to snapshot the shell's state
+// after each block, reporting how the block changed it.
+func (s *Subshell) SetCaptureState(c bool) *Subshell {
+	s.captureState = c
+	return s
+}
+
+// SetScratch says whether to run the blocks in a new, temporary
+// directory, so that they can't trip over those run elsewhere.
+func (s *Subshell) SetScratch(x bool) *Subshell {
+	s.scratch = x
+	return s
+}
+
+// SetEnv says what KEY=VALUE assignments to export
+// in the shell before running any block.
+func (s *Subshell) SetEnv(env []string) *Subshell {
+	s.env = env
+	return s
+}
+
+// SetSudoAskpass names the program sudo runs to get a password
+// for blocks run as root; without one, sudo fails if it needs one.
+func (s *Subshell) SetSudoAskpass(askpass string) *Subshell {
+	s.sudoAskpass = askpass
+	return s
+}
+
+// SetChaos says what trouble to make for commands the blocks
+// call, via shims found on the PATH before them.
+func (s *Subshell) SetChaos(chaos []Chaos) *Subshell {
+	s.chaos = chaos
+	return s
+}
+
+// SetHermeticHome says whether to run the blocks with a new,
+// temporary home, and the directory to copy into it, if any.
+func (s *Subshell) SetHermeticHome(on bool, seed string) *Subshell {
+	s.hermeticHome = on
+	s.homeSeed = seed
+	return s
+}
+
 // Run runs command blocks in a subprocess, stopping and
 // reporting on any error.
 //
@@ -227,7 +568,25 @@ func politeWait(shell *exec.Cmd) (err error) {
 // succeeded, and only reporting the contents of stdout and stderr
 // when the subprocess exits on error.
 func (s *Subshell) Run() (result *RunResult) {
-	tmpFile := writeFile(s.program.Lessons())
+	shims := ""
+	if len(s.chaos) > 0 {
+		dir, err := writeShims(s.chaos)
+		if err != nil {
+			return NewRunResult(nil, nil).SetError(err)
+		}
+		defer os.RemoveAll(dir)
+		shims = dir
+	}
+	home := ""
+	if s.hermeticHome {
+		dir, err := makeHome(s.homeSeed)
+		if err != nil {
+			return NewRunResult(nil, nil).SetError(err)
+		}
+		defer removeHome(dir)
+		home = dir
+	}
+	tmpFile := s.writeFile(shims, home)
 	defer func() {
 		// Windows has trouble with processes hanging on to temp files.
 		attempts := 6
@@ -254,11 +613,17 @@ func (s *Subshell) Run() (result *RunResult) {
 		log.Fatal(msg)
 	}()
 
-	shell := exec.Command("bash", tmpFile.Name())
+	shell, err := s.shell.Command(tmpFile.Name())
+	if err != nil {
+		return NewRunResult(nil, nil).SetError(err)
+	}
+	defer s.shell.Cleanup()
 
-	stdIn, err := shell.StdinPipe()
-	util.Check("in pipe", err)
-	util.Check("close shell's stdin", stdIn.Close())
+	if shell.Stdin == nil {
+		stdIn, err := shell.StdinPipe()
+		util.Check("in pipe", err)
+		util.Check("close shell's stdin", stdIn.Close())
+	}
 
 	stdOut, err := shell.StdoutPipe()
 	util.Check("out pipe", err)
@@ -290,7 +655,7 @@ func (s *Subshell) Run() (result *RunResult) {
 	// wrong in the plumbing.  If the shell is still running, it
 	// should be killed.
 
-	err = politeWait(shell)
+	err = politeWait(shell, s.shell.Stop)
 	if glog.V(2) {
 		glog.Info("Run:  Shell done.")
 	}
@@ -301,7 +666,10 @@ func (s *Subshell) Run() (result *RunResult) {
 			err = errors.New("problem processing stdout and/or stderr")
 		}
 	}
-	result.SetError(err)
+	if result.Error() == nil {
+		// Else a block's output wasn't as expected.
+		result.SetError(err)
+	}
 	// killProcesssGroup(pgid)?
 	return
 }
@@ -1,11 +1,17 @@
 package subshell
 
 import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
 	"github.com/monopole/mdrip/program"
 	"github.com/monopole/mdrip/scanner"
 )
@@ -150,3 +156,384 @@ func TestTimeOutAtTheEnd(t *testing.T) {
 		2,
 		scanner.MsgTimeout)
 }
+
+func timedBlock(code string, t time.Duration) *program.BlockPgm {
+	return program.NewBlockPgmFromBlockTut(model.NewBlockTut(model.NewBlockParsed(
+		[]base.Label{base.Label(base.TimeoutAttribute + "=" + t.String())},
+		base.NoProse(), base.OpaqueCode(code))))
+}
+
+func TestBlockTimeOut(t *testing.T) {
+	slow := "date\nsleep " + sleep.String() + "\necho kale\n"
+	lesson := program.NewLessonPgm(base.FilePath("arbitraryPath"), []*program.BlockPgm{
+		timedBlock(slow, sleep+timeout),
+		makeBlock(slow),
+	})
+	result := NewSubshell(timeout, program.NewProgram(
+		[]*program.LessonPgm{lesson})).Run()
+	// The first block's own timeout lets it finish, the second
+	// is held to the default.
+	checkFail(t, result, 1, scanner.MsgTimeout)
+}
+
+func TestJUnit(t *testing.T) {
+	result := doIt([]string{
+		"echo kale\n",
+		"echo oops 1>&2\nlochNessMonster\n",
+		"echo tofu\n",
+	})
+	reports := result.Reports()
+	if len(reports) != 3 ||
+		!reports[0].Passed() || !reports[1].Failed() || !reports[2].Skipped() {
+		t.Fatalf("unexpected reports %v", reports)
+	}
+	if !strings.Contains(reports[0].StdOut(), "kale") ||
+		!strings.Contains(reports[1].StdErr(), "oops") {
+		t.Errorf("output not captured per block")
+	}
+	var b strings.Builder
+	if err := WriteJUnit(&b, result); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<testsuite name="arbitraryPath" tests="3" failures="1" skipped="1"`,
+		`<system-out>kale`,
+		`<failure message="exit status 127">echo oops 1&gt;&amp;2`,
+		`<skipped></skipped>`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("got\n%s\nwant it to hold\n%s", b.String(), want)
+		}
+	}
+}
+
+func interpretedBlock(code, interpreter string) *program.BlockPgm {
+	return program.NewBlockPgmFromBlockTut(model.NewBlockTut(model.NewBlockParsed(
+		[]base.Label{base.Label(base.InterpreterAttribute + "=" + interpreter)},
+		base.NoProse(), base.OpaqueCode(code))))
+}
+
+func TestInterpretedBlocks(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("no python3")
+	}
+	lesson := program.NewLessonPgm(base.FilePath("arbitraryPath"), []*program.BlockPgm{
+		makeBlock("echo kale\n"),
+		interpretedBlock("import sys\nprint('beans $HOME')\n", "python3"),
+		interpretedBlock("raise SystemExit('oops')\n", "python3"),
+		makeBlock("echo tofu\n"),
+	})
+	result := NewSubshell(timeout, program.NewProgram(
+		[]*program.LessonPgm{lesson})).Run()
+	checkFail(t, result, 2, "oops")
+	reports := result.Reports()
+	if !reports[1].Passed() || reports[1].StdOut() != "beans $HOME\n" {
+		t.Errorf("got %v %q, want python's own output", reports[1].Passed(), reports[1].StdOut())
+	}
+	var b strings.Builder
+	if err := WriteJUnit(&b, result); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<property name="interpreter" value="python3">`; !strings.Contains(b.String(), want) {
+		t.Errorf("got\n%s\nwant it to hold\n%s", b.String(), want)
+	}
+}
+
+func TestMissingInterpreter(t *testing.T) {
+	lesson := program.NewLessonPgm(base.FilePath("arbitraryPath"), []*program.BlockPgm{
+		interpretedBlock("print 1\n", "noSuchInterpreter"),
+	})
+	result := NewSubshell(timeout, program.NewProgram(
+		[]*program.LessonPgm{lesson})).Run()
+	checkFail(t, result, 0, "needs noSuchInterpreter, not found")
+}
+
+func TestExpectedOutput(t *testing.T) {
+	block := func(code string, labels ...base.Label) *model.BlockTut {
+		return model.NewBlockTut(model.NewBlockParsed(
+			labels, base.NoProse(), base.OpaqueCode(code)))
+	}
+	tut := model.NewLessonTutForTests(base.FilePath("arbitraryPath"), []*model.BlockTut{
+		block("echo kale\n"),
+		block("kale\n", "expected"),
+		block("echo tofu beans\n"),
+		block("tofu\nbeans\n", "expected"),
+		block("echo lemon\n"),
+		block("^lem", "expected=regex"),
+	})
+	result := NewSubshell(timeout, program.NewProgramFromTutorial(
+		base.WildCardLabel, tut)).Run()
+	if result.Error() == nil {
+		t.Fatalf("expected an error, but no error")
+	}
+	if !strings.Contains(result.Error().Error(), "- tofu\n- beans\n+ tofu beans\n") {
+		t.Errorf("got error %v, want a diff", result.Error())
+	}
+	var states []string
+	for _, r := range result.Reports() {
+		switch {
+		case r.Passed():
+			states = append(states, "passed")
+		case r.Failed():
+			states = append(states, "failed")
+		default:
+			states = append(states, "skipped")
+		}
+	}
+	// The @expected blocks become blocks of prose, holding no code.
+	want := "passed passed failed passed passed passed"
+	if got := strings.Join(states, " "); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if result.Index() != 2 {
+		t.Errorf("got index %d, want 2", result.Index())
+	}
+}
+
+func TestKeepGoing(t *testing.T) {
+	lesson := program.NewLessonPgm(base.FilePath("arbitraryPath"), []*program.BlockPgm{
+		makeBlock("export VEG=kale\ncd /\n"),
+		makeBlock("echo oops 1>&2\nfalse\necho never\n"),
+		makeBlock("echo $VEG $(pwd)\n"),
+		makeBlock("(exit 3)\n"),
+		makeBlock("echo tofu\n"),
+	})
+	result := NewSubshell(timeout, program.NewProgram(
+		[]*program.LessonPgm{lesson})).SetKeepGoing(true).Run()
+	checkFail(t, result, 1, "oops")
+	reports := result.Reports()
+	if len(reports) != 5 {
+		t.Fatalf("got %d reports, want 5", len(reports))
+	}
+	for i, want := range []int{0, 1, 0, 3, 0} {
+		if reports[i].Failed() != (want != 0) || reports[i].ExitCode() != want {
+			t.Errorf("block %d: got failed %v, exit %d, want exit %d",
+				i, reports[i].Failed(), reports[i].ExitCode(), want)
+		}
+	}
+	if strings.Contains(reports[1].StdOut(), "never") {
+		t.Errorf("a block should stop at its first failure")
+	}
+	if reports[2].StdOut() != "kale /\n" {
+		t.Errorf("got %q, want variables and directory kept between blocks",
+			reports[2].StdOut())
+	}
+	var b strings.Builder
+	result.PrintSummary(&b)
+	for _, want := range []string{
+		"FILE           BLOCK  LABEL        STATUS  EXIT  TIME",
+		"arbitraryPath  4      noNameBlock  FAILED  3",
+		"3 passed, 2 failed, 0 skipped",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("got\n%s\nwant it to hold\n%s", b.String(), want)
+		}
+	}
+}
+
+func TestCaptureState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdrip-state-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, keepGoing := range []bool{false, true} {
+		lesson := program.NewLessonPgm(base.FilePath("arbitraryPath"), []*program.BlockPgm{
+			makeBlock("cd " + dir + "\n"),
+			makeBlock("export VEG=kale\ntouch beans\necho done\n"),
+			makeBlock("unset VEG\nrm beans\n"),
+		})
+		result := NewSubshell(timeout, program.NewProgram(
+			[]*program.LessonPgm{lesson})).SetKeepGoing(keepGoing).SetCaptureState(true).Run()
+		if result.Error() != nil {
+			t.Fatalf("unexpected error %v", result.Error())
+		}
+		reports := result.Reports()
+		if got := reports[1].StdOut(); got != "done\n" {
+			t.Errorf("keepGoing %v: got stdout %q, want no snapshot in it", keepGoing, got)
+		}
+		for i, want := range [][]string{
+			{"cd " + dir},
+			{"+ env VEG=kale", "+ file ./beans"},
+			{"- env VEG", "- file ./beans"},
+		} {
+			if got := reports[i].StateChanges(); !reflect.DeepEqual(got, want) {
+				t.Errorf("keepGoing %v, block %d: got %q, want %q", keepGoing, i, got, want)
+			}
+		}
+	}
+}
+
+func TestTeardown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdrip-teardown-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	block := func(code string, labels ...base.Label) *program.BlockPgm {
+		return program.NewBlockPgmFromBlockTut(model.NewBlockTut(model.NewBlockParsed(
+			labels, base.MdProse("prose"), base.OpaqueCode(code))))
+	}
+	log := dir + "/log"
+	for _, test := range []struct {
+		middle  string
+		scratch bool
+		wantErr bool
+		wantLog string
+	}{
+		{"echo fine\n", false, false, "up\nran\ndown\n"},
+		{"echo fine\n", true, false, "up\nran\ndown\n"},
+		{"false\necho never >> " + log + "\n", false, true, "up\ndown\n"},
+		{"false\n", true, true, "up\ndown\n"},
+	} {
+		os.Remove(log)
+		lesson := program.NewLessonPgm(base.FilePath("arbitraryPath"), []*program.BlockPgm{
+			block("echo up > "+log+"\n", base.SetupLabel),
+			block(test.middle),
+			block("echo ran >> " + log + "\n"),
+			block("echo down >> "+log+"\n", base.TeardownLabel),
+		})
+		result := NewSubshell(timeout, program.NewProgram(
+			[]*program.LessonPgm{lesson})).SetScratch(test.scratch).Run()
+		if (result.Error() != nil) != test.wantErr {
+			t.Errorf("%q: got error %v", test.middle, result.Error())
+		}
+		if test.wantErr && !strings.Contains(result.StdErr(), "running the teardown of arbitraryPath") {
+			t.Errorf("%q: got stderr %q, want it to announce the teardown", test.middle, result.StdErr())
+		}
+		b, err := ioutil.ReadFile(log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.wantLog {
+			t.Errorf("%q, scratch %v: got log %q, want %q",
+				test.middle, test.scratch, string(b), test.wantLog)
+		}
+	}
+}
+
+func TestRetriesAndIsolation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdrip-retries-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	block := func(code string, labels ...base.Label) *program.BlockPgm {
+		return program.NewBlockPgmFromBlockTut(model.NewBlockTut(model.NewBlockParsed(
+			labels, base.MdProse("prose"), base.OpaqueCode(code))))
+	}
+	// flaky fails until it has been tried three times.
+	flaky := "echo x >> " + dir + "/tries\n[ $(wc -l < " + dir + "/tries) -ge 3 ]\necho made it\n"
+	for _, keepGoing := range []bool{false, true} {
+		for _, test := range []struct {
+			retries string
+			wantErr bool
+		}{
+			{"retries=2", false},
+			{"retries=1", true},
+		} {
+			os.Remove(dir + "/tries")
+			lesson := program.NewLessonPgm(base.FilePath("arbitraryPath"), []*program.BlockPgm{
+				block("export VEG=kale\n"),
+				block("export VEG=tofu\ncd /\n", "isolation=subshell"),
+				block(flaky, "flaky", base.Label(test.retries)),
+				block("echo $VEG $(pwd)\n"),
+			})
+			result := NewSubshell(timeout, program.NewProgram(
+				[]*program.LessonPgm{lesson})).SetKeepGoing(keepGoing).Run()
+			if (result.Error() != nil) != test.wantErr {
+				t.Errorf("keepGoing %v, %s: got error %v", keepGoing, test.retries, result.Error())
+				continue
+			}
+			reports := result.Reports()
+			if !strings.Contains(reports[2].StdErr(), "mdrip: block flaky failed with status 1; retry 1 of") {
+				t.Errorf("keepGoing %v, %s: got stderr %q, want retries announced",
+					keepGoing, test.retries, reports[2].StdErr())
+			}
+			if test.wantErr {
+				continue
+			}
+			if reports[2].StdOut() != "made it\n" {
+				t.Errorf("keepGoing %v: got stdout %q", keepGoing, reports[2].StdOut())
+			}
+			if got := reports[3].StdOut(); strings.HasPrefix(got, "tofu") || strings.HasSuffix(got, " /\n") {
+				t.Errorf("keepGoing %v: got %q, want the isolated block's changes gone", keepGoing, got)
+			}
+		}
+	}
+}
+
+// fakeSudo puts, first on the PATH, a sudo that logs its
+// arguments to the returned file, then runs the command.
+func fakeSudo(t *testing.T, dir string) (log string, restore func()) {
+	log = filepath.Join(dir, "sudo.log")
+	script := "#!/bin/sh\necho \"$*\" >> " + log +
+		"\nwhile [ \"$1\" != -- ]; do shift; done\nshift\nexec \"$@\"\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "sudo"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+":"+path)
+	return log, func() { os.Setenv("PATH", path) }
+}
+
+func TestSudo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdrip-sudo-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	log, restore := fakeSudo(t, dir)
+	defer restore()
+	block := func(code string, labels ...base.Label) *program.BlockPgm {
+		return program.NewBlockPgmFromBlockTut(model.NewBlockTut(model.NewBlockParsed(
+			labels, base.MdProse("prose"), base.OpaqueCode(code))))
+	}
+	lesson := program.NewLessonPgm(base.FilePath("arbitraryPath"), []*program.BlockPgm{
+		block("VEG=kale\n"),
+		block("echo root sees ${VEG:-nothing}\nfalse\necho never\n", "sudo=true"),
+	})
+	for _, test := range []struct {
+		askpass, wantLog string
+	}{
+		{"", "-n -- bash -e\n"},
+		{"/usr/bin/ssh-askpass", "-A -- bash -e\n"},
+	} {
+		os.Remove(log)
+		result := NewSubshell(timeout, program.NewProgram([]*program.LessonPgm{lesson})).
+			SetSudoAskpass(test.askpass).Run()
+		checkFail(t, result, 1, "")
+		if got := result.StdOut(); !strings.Contains(got, "root sees nothing") ||
+			strings.Contains(got, "never") {
+			t.Errorf("got output %q", got)
+		}
+		if got, _ := ioutil.ReadFile(log); string(got) != test.wantLog {
+			t.Errorf("got sudo %q, want %q", got, test.wantLog)
+		}
+	}
+}
+
+func TestRunAs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdrip-sudo-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	log, restore := fakeSudo(t, dir)
+	defer restore()
+	r, err := NewRunner(NameBash, RunnerOptions{BlockTimeOut: timeout, RunAs: "builder"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := r.Run(program.NewProgram([]*program.LessonPgm{program.NewLessonPgm(
+		base.FilePath("arbitraryPath"), []*program.BlockPgm{makeBlock("echo hi\n")})}))
+	if result.Error() != nil {
+		t.Fatal(result.Error())
+	}
+	if got, _ := ioutil.ReadFile(log); string(got) != "-n -u builder -- bash -s\n" {
+		t.Errorf("got sudo %q", got)
+	}
+	if _, err := NewRunner(NameDocker, RunnerOptions{Image: "ubuntu:22.04", RunAs: "builder"}); err == nil {
+		t.Errorf("--runAs should make no sense with --runner docker")
+	}
+}
@@ -0,0 +1,88 @@
+package tmux
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+
+	"github.com/golang/glog"
+)
+
+// Multiplexer is a terminal multiplexer, e.g. tmux, GNU screen or
+// Zellij, that code can be pasted into as if typed.
+type Multiplexer interface {
+	// Name of the multiplexer, e.g. tmux, for messages.
+	Name() string
+	// IsUp is true if the multiplexer appears to be running.
+	IsUp() bool
+	// WithTarget returns a copy of the multiplexer that writes
+	// to the given target, in the multiplexer's own syntax.
+	WithTarget(target string) Multiplexer
+	// Write pastes bytes into the target, for interpretation
+	// as shell commands.
+	Write(bytes []byte) (int, error)
+}
+
+// Tracker is a Multiplexer that can report when code written
+// to it has finished, and copy its output.  Only tmux is one.
+type Tracker interface {
+	WriteTracked(code []byte) (*Completion, error)
+	WriteCaptured(code []byte) (*Completion, error)
+}
+
+// Names of the supported multiplexers.
+const (
+	NameTmux   = "tmux"
+	NameScreen = "screen"
+	NameZellij = "zellij"
+)
+
+// NewMultiplexer returns the multiplexer with the given name.
+func NewMultiplexer(name string) (Multiplexer, error) {
+	switch name {
+	case NameTmux:
+		return NewTmux(Path), nil
+	case NameScreen:
+		return NewScreen(NameScreen), nil
+	case NameZellij:
+		return NewZellij(NameZellij), nil
+	}
+	return nil, fmt.Errorf(
+		"unknown multiplexer %q; use %s, %s or %s", name, NameTmux, NameScreen, NameZellij)
+}
+
+// writeFile writes bytes to a temp file, passes its name to the
+// function, which tells a multiplexer to paste it, then removes it.
+func writeFile(bytes []byte, paste func(name string) error) (int, error) {
+	f, err := ioutil.TempFile("", "mdrip-block-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(bytes); err != nil {
+		f.Close()
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return 0, err
+	}
+	if err := paste(f.Name()); err != nil {
+		return 0, err
+	}
+	return len(bytes), nil
+}
+
+// run runs a multiplexer command, logging its output if it fails.
+func run(cmd *exec.Cmd) error {
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		glog.Info("cmd = ", cmd.Args)
+		glog.Info("out = ", string(out))
+		return fmt.Errorf("%s: %v", cmd.Args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package tmux
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewMultiplexer(t *testing.T) {
+	for _, n := range []string{NameTmux, NameScreen, NameZellij} {
+		m, err := NewMultiplexer(n)
+		if err != nil {
+			t.Fatalf("%s: %v", n, err)
+		}
+		if m.Name() != n || m.WithTarget("demo").Name() != n {
+			t.Errorf("%s: got %s", n, m.Name())
+		}
+	}
+	if _, err := NewMultiplexer("byobu"); err == nil {
+		t.Errorf("expected an error for an unknown multiplexer")
+	}
+	if _, ok := Multiplexer(NewTmux(Path)).(Tracker); !ok {
+		t.Errorf("tmux should track completion")
+	}
+	if _, ok := Multiplexer(NewScreen(NameScreen)).(Tracker); ok {
+		t.Errorf("screen shouldn't claim to track completion")
+	}
+}
+
+func TestScreenCommand(t *testing.T) {
+	for target, want := range map[string]string{
+		"":       "screen -X paste .",
+		"demo":   "screen -S demo -X paste .",
+		"demo:2": "screen -S demo -p 2 -X paste .",
+	} {
+		s := NewScreen(NameScreen).WithTarget(target).(*Screen)
+		if got := strings.Join(s.command("-X", "paste", ".").Args, " "); got != want {
+			t.Errorf("%q: got %q, want %q", target, got, want)
+		}
+	}
+}
+
+func TestNotUp(t *testing.T) {
+	for _, m := range []Multiplexer{NewScreen(badName), NewZellij(badName)} {
+		if m.IsUp() {
+			t.Errorf("%s shouldn't be up without its program", m.Name())
+		}
+	}
+}
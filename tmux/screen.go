@@ -0,0 +1,69 @@
+package tmux
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// Screen holds information about a GNU screen process
+// (https://www.gnu.org/software/screen/).
+type Screen struct {
+	path string
+	// session and window are where to paste; empty means
+	// screen's own choice, i.e. the current ones.
+	session string
+	window  string
+}
+
+// NewScreen is a ctor.
+func NewScreen(programName string) *Screen {
+	return &Screen{programName, "", ""}
+}
+
+// Name of the multiplexer.
+func (s Screen) Name() string {
+	return NameScreen
+}
+
+// WithTarget returns a copy of the Screen that writes to the given
+// target, a session, e.g. demo, or a session and window, e.g. demo:1.
+func (s Screen) WithTarget(target string) Multiplexer {
+	parts := strings.SplitN(target, ":", 2)
+	result := &Screen{s.path, parts[0], ""}
+	if len(parts) == 2 {
+		result.window = parts[1]
+	}
+	return result
+}
+
+// IsUp true if screen appears to have a session.
+func (s Screen) IsUp() bool {
+	if _, err := exec.LookPath(s.path); err != nil {
+		return false
+	}
+	// screen -ls exits non-zero even when listing sessions.
+	out, _ := exec.Command(s.path, "-ls").CombinedOutput()
+	return !strings.Contains(string(out), "No Sockets found")
+}
+
+func (s Screen) command(args ...string) *exec.Cmd {
+	var prefix []string
+	if len(s.session) > 0 {
+		prefix = append(prefix, "-S", s.session)
+	}
+	if len(s.window) > 0 {
+		prefix = append(prefix, "-p", s.window)
+	}
+	return exec.Command(s.path, append(prefix, args...)...)
+}
+
+// Write bytes to a screen window, like Tmux.Write, by reading them
+// into screen's paste buffer, then pasting that.
+func (s Screen) Write(bytes []byte) (int, error) {
+	return writeFile(bytes, func(name string) error {
+		if err := run(s.command("-X", "readbuf", name)); err != nil {
+			return err
+		}
+		return run(s.command("-X", "paste", "."))
+	})
+}
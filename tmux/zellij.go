@@ -0,0 +1,52 @@
+package tmux
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// Zellij holds information about a Zellij process (https://zellij.dev).
+type Zellij struct {
+	path string
+	// session is where to write; empty means the session
+	// mdrip runs in, per $ZELLIJ_SESSION_NAME.
+	session string
+}
+
+// NewZellij is a ctor.
+func NewZellij(programName string) *Zellij {
+	return &Zellij{programName, ""}
+}
+
+// Name of the multiplexer.
+func (z Zellij) Name() string {
+	return NameZellij
+}
+
+// WithTarget returns a copy of the Zellij that writes to the given
+// session's focused pane; Zellij can't address other panes.
+func (z Zellij) WithTarget(target string) Multiplexer {
+	return &Zellij{z.path, target}
+}
+
+// IsUp true if Zellij appears to have a session.
+func (z Zellij) IsUp() bool {
+	if _, err := exec.LookPath(z.path); err != nil {
+		return false
+	}
+	out, err := exec.Command(z.path, "list-sessions").Output()
+	return err == nil && len(strings.TrimSpace(string(out))) > 0
+}
+
+// Write bytes to the focused pane of a Zellij session, as if typed.
+func (z Zellij) Write(bytes []byte) (int, error) {
+	var args []string
+	if len(z.session) > 0 {
+		args = append(args, "--session", z.session)
+	}
+	args = append(args, "action", "write-chars", string(bytes))
+	if err := run(exec.Command(z.path, args...)); err != nil {
+		return 0, err
+	}
+	return len(bytes), nil
+}
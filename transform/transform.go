@@ -0,0 +1,202 @@
+// Package transform rewrites code blocks before they're sent
+// somewhere, e.g. to tmux, so that blocks that read well in
+// documentation aren't noisy when typed into a live terminal.
+package transform
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/pkg/errors"
+)
+
+// Transform rewrites a block of code.
+type Transform func(base.OpaqueCode) base.OpaqueCode
+
+// Pipeline is a sequence of transforms, applied in order.
+type Pipeline []Transform
+
+// Apply runs the code through the pipeline.
+func (p Pipeline) Apply(c base.OpaqueCode) base.OpaqueCode {
+	for _, t := range p {
+		c = t(c)
+	}
+	return c
+}
+
+// Names of the available transforms.
+const (
+	// NameVars replaces {{.NAME}} with the value of the
+	// environment variable NAME, leaving unknown names alone.
+	NameVars = "vars"
+	// NameComments drops lines holding only a shell comment.
+	NameComments = "comments"
+	// NameBlanks collapses runs of blank lines to one blank line,
+	// and drops leading and trailing blank lines.
+	NameBlanks = "blanks"
+)
+
+var transforms = map[string]Transform{
+	NameVars:     ExpandVars(os.LookupEnv),
+	NameComments: StripComments,
+	NameBlanks:   CollapseBlankLines,
+}
+
+// NewPipeline makes a pipeline from a comma separated list of
+// transform names, e.g. "vars,comments".  Empty means no transforms.
+// The vars transform takes a name's value from vars, if it's there,
+// before the environment.
+func NewPipeline(spec string, vars map[string]string) (Pipeline, error) {
+	result := Pipeline{}
+	for _, n := range strings.Split(spec, ",") {
+		n = strings.TrimSpace(n)
+		if len(n) == 0 {
+			continue
+		}
+		t, ok := transforms[n]
+		if n == NameVars && len(vars) > 0 {
+			t = ExpandVars(Lookup(vars))
+		}
+		if !ok {
+			return nil, errors.Errorf(
+				"unknown transform %q; choose from %s, %s or %s",
+				n, NameVars, NameComments, NameBlanks)
+		}
+		result = append(result, t)
+	}
+	return result, nil
+}
+
+// HasVars is true if the comma separated list
+// of transform names holds the vars transform.
+func HasVars(spec string) bool {
+	for _, n := range strings.Split(spec, ",") {
+		if strings.TrimSpace(n) == NameVars {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns a function finding a name's value in vars,
+// if it's there, else in the environment, as the vars
+// transform of a pipeline made with vars does.
+func Lookup(vars map[string]string) func(string) (string, bool) {
+	return func(n string) (string, bool) {
+		if v, ok := vars[n]; ok {
+			return v, true
+		}
+		return os.LookupEnv(n)
+	}
+}
+
+var varRef = regexp.MustCompile(`{{\s*\.([A-Za-z_][A-Za-z0-9_]*)\s*}}`)
+
+// ExpandVars returns a Transform replacing {{.NAME}} with the value
+// found by the lookup function.  References to unknown names are
+// left as they are.
+func ExpandVars(lookup func(string) (string, bool)) Transform {
+	return func(c base.OpaqueCode) base.OpaqueCode {
+		return base.OpaqueCode(varRef.ReplaceAllStringFunc(
+			c.String(), func(m string) string {
+				if v, ok := lookup(varRef.FindStringSubmatch(m)[1]); ok {
+					return v
+				}
+				return m
+			}))
+	}
+}
+
+// VarNames returns the names, in order of first
+// reference, of the variables, e.g. {{.NAME}}, in the code.
+func VarNames(c base.OpaqueCode) []string {
+	var result []string
+	seen := map[string]bool{}
+	for _, m := range varRef.FindAllStringSubmatch(c.String(), -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			result = append(result, m[1])
+		}
+	}
+	return result
+}
+
+var hereDocStart = regexp.MustCompile(`<<-?\s*['"]?([A-Za-z_][A-Za-z0-9_]*)['"]?`)
+
+// hereDocLines reports, for each line, whether it's inside the body
+// of a here document (including the terminator).  Such lines are
+// data, not commands, so transforms must leave them alone.
+func hereDocLines(lines []string) []bool {
+	result := make([]bool, len(lines))
+	terminator := ""
+	for i, l := range lines {
+		if len(terminator) > 0 {
+			result[i] = true
+			if strings.TrimSpace(l) == terminator {
+				terminator = ""
+			}
+			continue
+		}
+		if m := hereDocStart.FindStringSubmatch(l); m != nil {
+			terminator = m[1]
+		}
+	}
+	return result
+}
+
+func mapLines(
+	c base.OpaqueCode, keep func(prev, line string) bool) base.OpaqueCode {
+	text := c.String()
+	trailingNewline := strings.HasSuffix(text, "\n")
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	inHereDoc := hereDocLines(lines)
+	var result []string
+	prev := ""
+	for i, l := range lines {
+		if inHereDoc[i] || keep(prev, l) {
+			result = append(result, l)
+			prev = l
+		}
+	}
+	out := strings.Join(result, "\n")
+	if trailingNewline && len(result) > 0 {
+		out += "\n"
+	}
+	return base.OpaqueCode(out)
+}
+
+// StripComments drops lines that hold only a shell comment.
+// A leading #! line is kept.
+func StripComments(c base.OpaqueCode) base.OpaqueCode {
+	first := true
+	return mapLines(c, func(_, l string) bool {
+		wasFirst := first
+		first = false
+		t := strings.TrimSpace(l)
+		if wasFirst && strings.HasPrefix(t, "#!") {
+			return true
+		}
+		return !strings.HasPrefix(t, "#")
+	})
+}
+
+// CollapseBlankLines collapses runs of blank lines to one, and
+// drops leading and trailing blank lines.
+func CollapseBlankLines(c base.OpaqueCode) base.OpaqueCode {
+	started := false
+	result := mapLines(c, func(prev, l string) bool {
+		blank := len(strings.TrimSpace(l)) == 0
+		if blank && (!started || len(strings.TrimSpace(prev)) == 0) {
+			return false
+		}
+		started = true
+		return true
+	})
+	text := strings.TrimRight(result.String(), " \t\n")
+	if len(text) > 0 && strings.HasSuffix(c.String(), "\n") {
+		text += "\n"
+	}
+	return base.OpaqueCode(text)
+}
@@ -0,0 +1,84 @@
+package transform
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+)
+
+type tTest struct {
+	name  string
+	spec  string
+	input string
+	want  string
+}
+
+var tTests = []tTest{
+	{"nothing", "", "echo hi\n\n\n", "echo hi\n\n\n"},
+	{"comments", NameComments,
+		"#!/bin/bash\n# say hi\necho hi # trailing\n  # indented\n",
+		"#!/bin/bash\necho hi # trailing\n"},
+	{"blanks", NameBlanks,
+		"\n\necho a\n\n\n\necho b\n\n",
+		"echo a\n\necho b\n"},
+	{"hereDoc", "comments,blanks",
+		"# make file\ncat <<EOF >f\n# kept\n\n\nline\nEOF\n\n\necho done\n",
+		"cat <<EOF >f\n# kept\n\n\nline\nEOF\n\necho done\n"},
+	{"quotedHereDoc", NameComments,
+		"cat <<-'END' >f\n# kept\nEND\n# dropped\n",
+		"cat <<-'END' >f\n# kept\nEND\n"},
+	{"vars", NameVars,
+		"echo {{.MDRIP_TEST_VAR}} {{ .MDRIP_NOT_SET }}\n",
+		"echo zebra {{ .MDRIP_NOT_SET }}\n"},
+}
+
+func TestPipeline(t *testing.T) {
+	transforms[NameVars] = ExpandVars(func(n string) (string, bool) {
+		if n == "MDRIP_TEST_VAR" {
+			return "zebra", true
+		}
+		return "", false
+	})
+	for _, test := range tTests {
+		p, err := NewPipeline(test.spec, nil)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		got := p.Apply(base.OpaqueCode(test.input)).String()
+		if got != test.want {
+			t.Errorf("%s:\ngot\n%q\nwant\n%q\n", test.name, got, test.want)
+		}
+	}
+}
+
+func TestBadPipeline(t *testing.T) {
+	if _, err := NewPipeline("comments,zebra", nil); err == nil {
+		t.Errorf("expected error for unknown transform")
+	}
+}
+
+func TestPipelineVars(t *testing.T) {
+	os.Setenv("MDRIP_TEST_REGION", "us-east1")
+	defer os.Unsetenv("MDRIP_TEST_REGION")
+	p, err := NewPipeline(NameVars, map[string]string{"PROJECT": "zebra"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := p.Apply(base.OpaqueCode("gcloud --project {{.PROJECT}} --region {{.MDRIP_TEST_REGION}}\n"))
+	if want := "gcloud --project zebra --region us-east1\n"; got.String() != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestVarNames(t *testing.T) {
+	got := VarNames(base.OpaqueCode("gcloud --project {{.PROJECT}} --region {{ .REGION }}\necho {{.PROJECT}} {{PLAIN}}\n"))
+	if strings.Join(got, ",") != "PROJECT,REGION" {
+		t.Errorf("got %v", got)
+	}
+	if !HasVars("comments, vars") || HasVars("comments,blanks") {
+		t.Errorf("HasVars is wrong")
+	}
+}
@@ -0,0 +1,258 @@
+package webapp
+
+import (
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+const tmplBodyCatalog = `<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<title> {{.Name}} </title>
+<style type="text/css">
+body { font-family: Helvetica, Arial, sans-serif; margin: 2em; }
+.facets { margin-bottom: 1em; }
+.facets input, .facets select { margin-right: 1em; }
+li { margin-top: 0.7em; }
+li.hidden { display: none; }
+.source, .facts { color: gray; }
+.tag { font-size: 0.8em; padding: 0px 4px; border: 1px solid lightgray; }
+.status_verified { color: green; }
+.status_stale { color: darkorange; }
+.status_unverified { color: gray; font-style: italic; }
+</style>
+</head>
+<body>
+<h1> {{.Name}} </h1>
+<div class='facets'>
+  <input id='search' type='search' placeholder='{{msg "search"}}' oninput='filter()'>
+  {{if .Tags}}
+  <select id='tag' onchange='filter()'>
+    <option value=''> {{msg "allTags"}} </option>
+    {{range .Tags}}<option value='{{.}}'> {{.}} </option>{{end}}
+  </select>
+  {{end}}
+  <select id='status' onchange='filter()'>
+    <option value=''> {{msg "anyStatus"}} </option>
+    <option value='` + statusVerified + `'> {{msg "` + statusVerified + `"}} </option>
+    <option value='` + statusStale + `'> {{msg "` + statusStale + `"}} </option>
+    <option value='` + statusUnverified + `'> {{msg "` + statusUnverified + `"}} </option>
+  </select>
+  <select id='length' onchange='filter()'>
+    <option value=''> {{msg "anyLength"}} </option>
+    <option value='` + lengthShort + `'> {{msg "` + lengthShort + `"}} </option>
+    <option value='` + lengthMedium + `'> {{msg "` + lengthMedium + `"}} </option>
+    <option value='` + lengthLong + `'> {{msg "` + lengthLong + `"}} </option>
+  </select>
+</div>
+<ul>
+{{range .Entries}}
+  <li data-text='{{.SearchText}}' data-tags='{{join .Tags}}'
+      data-status='{{.Status}}' data-length='{{.Length}}'>
+    <a href='{{.Href}}'> {{.Title}} </a> <span class='source'> {{.Source}} </span>
+    <div class='facts'>
+      {{.Lessons}} {{msg "lessons"}}, ~{{.Minutes}} {{msg "minutes"}},
+      <span class='status_{{.Status}}'> {{msg .Status}}{{if .VerifiedDate}} ({{.VerifiedDate}}){{end}} </span>
+      {{range .Tags}}<span class='tag'> {{.}} </span> {{end}}
+    </div>
+  </li>
+{{end}}
+</ul>
+<script type="text/javascript">
+function filter() {
+  var text = document.getElementById('search').value.toLowerCase();
+  var tag = document.getElementById('tag');
+  tag = tag ? tag.value : '';
+  var status = document.getElementById('status').value;
+  var length = document.getElementById('length').value;
+  var items = document.querySelectorAll('li');
+  for (var i = 0; i < items.length; i++) {
+    var x = items[i];
+    var show = x.getAttribute('data-text').indexOf(text) > -1
+        && (tag == '' || x.getAttribute('data-tags').split(',').indexOf(tag) > -1)
+        && (status == '' || x.getAttribute('data-status') == status)
+        && (length == '' || x.getAttribute('data-length') == length);
+    x.className = show ? '' : 'hidden';
+  }
+}
+</script>
+</body>
+</html>
+`
+
+// The statuses and lengths of catalog entries, used as facets.
+// Each is also the name of its message.
+const (
+	statusVerified   = "verified"
+	statusStale      = "stale"
+	statusUnverified = "unverified"
+	lengthShort      = "short"
+	lengthMedium     = "medium"
+	lengthLong       = "long"
+)
+
+const (
+	// staleAfter is how long after it was verified a course is stale.
+	staleAfter = 90 * 24 * time.Hour
+	// wordsPerMinute of prose, and minutesPerBlock of code, estimate
+	// how long a lesson lacking a duration in its front matter takes.
+	wordsPerMinute  = 200
+	minutesPerBlock = 1
+)
+
+// CatalogEntry is one course listed on a catalog page.
+type CatalogEntry struct {
+	// Link to the course, e.g. "/k8s/", the URL path it's served under.
+	Link  string
+	Title string
+	// Source is where the course was loaded from.
+	Source  string
+	Tags    []string
+	Lessons int
+	Blocks  int
+	// Duration is roughly how long the course takes.
+	Duration time.Duration
+	// Verified is when the least recently verified lesson was last
+	// known to work; the zero time if any lesson never was.
+	Verified time.Time
+	// Status is verified, stale or unverified, as of when the
+	// entry was made.
+	Status string
+}
+
+// NewCatalogEntry summarizes a tutorial for a catalog, as of now.
+func NewCatalogEntry(
+	link, source string, t model.Tutorial, now time.Time) CatalogEntry {
+	v := &catalogBuilder{}
+	t.Accept(v)
+	e := CatalogEntry{
+		Link: link, Title: v.title, Source: source,
+		Tags: base.MergeTags(v.tags...), Lessons: v.lessons, Blocks: v.blocks,
+		Duration: v.duration, Status: statusUnverified}
+	if len(e.Title) == 0 {
+		e.Title = t.Name()
+	}
+	if v.lessons > 0 && !v.unverified {
+		e.Verified = v.verified
+		e.Status = statusVerified
+		if now.Sub(v.verified) > staleAfter {
+			e.Status = statusStale
+		}
+	}
+	return e
+}
+
+// Href is the Link, trusted, as it comes from mdrip's arguments;
+// html/template would otherwise refuse file:// links.
+func (e CatalogEntry) Href() template.URL { return template.URL(e.Link) }
+
+// Minutes the course takes, rounded up.
+func (e CatalogEntry) Minutes() int {
+	return int((e.Duration + time.Minute - 1) / time.Minute)
+}
+
+// Length is short, medium or long.
+func (e CatalogEntry) Length() string {
+	switch {
+	case e.Duration < 15*time.Minute:
+		return lengthShort
+	case e.Duration <= time.Hour:
+		return lengthMedium
+	default:
+		return lengthLong
+	}
+}
+
+// VerifiedDate is when the course was verified, or empty.
+func (e CatalogEntry) VerifiedDate() string {
+	if e.Verified.IsZero() {
+		return ""
+	}
+	return e.Verified.Format(model.VerifiedLayout)
+}
+
+// SearchText is the lower case text the catalog's search box matches.
+func (e CatalogEntry) SearchText() string {
+	return strings.ToLower(
+		e.Title + " " + e.Source + " " + strings.Join(e.Tags, " "))
+}
+
+// catalogBuilder visits a tutorial, adding up its lessons.
+type catalogBuilder struct {
+	title      string
+	tags       [][]string
+	lessons    int
+	blocks     int
+	duration   time.Duration
+	verified   time.Time
+	unverified bool
+}
+
+func (v *catalogBuilder) VisitBlockTut(b *model.BlockTut) {}
+
+func (v *catalogBuilder) VisitLessonTut(l *model.LessonTut) {
+	if len(v.title) == 0 {
+		v.title = l.Title()
+	}
+	v.lessons++
+	v.tags = append(v.tags, l.AllTags())
+	d, words, blocks := l.Duration(), 0, 0
+	for _, b := range l.Blocks() {
+		words += len(strings.Fields(string(b.Prose())))
+		if len(b.Code()) > 0 {
+			blocks++
+		}
+	}
+	v.blocks += blocks
+	if d == 0 {
+		d = time.Duration(words)*time.Minute/wordsPerMinute +
+			time.Duration(blocks*minutesPerBlock)*time.Minute
+	}
+	v.duration += d
+	switch t := l.Verified(); {
+	case t.IsZero():
+		v.unverified = true
+	case v.verified.IsZero() || t.Before(v.verified):
+		v.verified = t
+	}
+}
+
+func (v *catalogBuilder) VisitCourse(c *model.Course) {
+	for _, x := range c.Children() {
+		x.Accept(v)
+	}
+}
+
+func (v *catalogBuilder) VisitTopCourse(t *model.TopCourse) {
+	v.VisitCourse(&t.Course)
+}
+
+// RenderCatalog writes a page listing courses, with facets to
+// filter them by tag, verification status and length, and a
+// search box.  It works standalone, as a static file.
+func RenderCatalog(w io.Writer, entries []CatalogEntry, msgs *Messages) error {
+	tags := [][]string{}
+	for _, e := range entries {
+		tags = append(tags, e.Tags)
+	}
+	t, err := template.New("catalog").Funcs(template.FuncMap{
+		"msg": msgs.Get,
+		"join": func(tags []string) string {
+			return strings.Join(tags, ",")
+		},
+	}).Parse(tmplBodyCatalog)
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, struct {
+		Name    string
+		Lang    string
+		Tags    []string
+		Entries []CatalogEntry
+	}{msgs.Get("tutorials"), msgs.Lang(), base.MergeTags(tags...), entries})
+}
@@ -0,0 +1,65 @@
+package webapp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+func lessonWithFrontMatter(p string, fm *model.FrontMatter, words int) *model.LessonTut {
+	md := model.NewMdContent()
+	md.SetFrontMatter(fm)
+	md.AddBlockParsed(model.NewBlockParsed([]base.Label{},
+		base.MdProse(strings.Repeat("word ", words)), base.OpaqueCode("echo hi\n")))
+	return model.NewLessonTutFromMdContent(base.FilePath(p), md)
+}
+
+func TestNewCatalogEntry(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tut := model.NewCourse(base.FilePath("k8s"), []model.Tutorial{
+		lessonWithFrontMatter("k8s/a.md", &model.FrontMatter{
+			Title: "Intro", Tags: []string{"GCP"}, Duration: "20m",
+			Verified: "2024-05-01"}, 0),
+		lessonWithFrontMatter("k8s/b.md", &model.FrontMatter{
+			Verified: "2024-01-01"}, 400),
+	})
+	e := NewCatalogEntry("/k8s/", "./k8s", tut, now)
+	if e.Title != "Intro" || e.Lessons != 2 || e.Blocks != 2 {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	// 20m given, plus 400 words and a block estimated at 3m.
+	if e.Minutes() != 23 || e.Length() != lengthMedium {
+		t.Errorf("got %d minutes, %s", e.Minutes(), e.Length())
+	}
+	if e.Status != statusStale || e.VerifiedDate() != "2024-01-01" {
+		t.Errorf("got status %s %s", e.Status, e.VerifiedDate())
+	}
+	if strings.Join(e.Tags, ",") != "gcp" {
+		t.Errorf("got tags %v", e.Tags)
+	}
+	e = NewCatalogEntry("/x/", "./x", model.NewCourse(base.FilePath("x"),
+		[]model.Tutorial{lessonWithFrontMatter("x/a.md", model.NewFrontMatter(), 0)}), now)
+	if e.Status != statusUnverified || e.Title != "a" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+}
+
+func TestRenderCatalog(t *testing.T) {
+	e := CatalogEntry{Link: "file:///tmp/k8s", Title: "Intro",
+		Tags: []string{"gcp"}, Status: statusVerified}
+	var b bytes.Buffer
+	if err := RenderCatalog(&b, []CatalogEntry{e}, DefaultMessages()); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"href='file:///tmp/k8s'", "data-tags='gcp'", "data-status='verified'",
+		"<option value='gcp'>", "id='search'"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("catalog lacks %q", want)
+		}
+	}
+}
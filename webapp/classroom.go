@@ -0,0 +1,196 @@
+package webapp
+
+import (
+	"html/template"
+	"io"
+	"time"
+)
+
+const (
+	// KeyClassCode is the param name for the code students join a classroom with.
+	KeyClassCode = "code"
+	// KeyStudentName is the param name for the name a student joins with.
+	KeyStudentName = "name"
+	// KeyInstructorKey is the param name for the key to a classroom's dashboard.
+	KeyInstructorKey = "key"
+	// KeySection is the param name for the path of a course or lesson
+	// whose opening is scheduled, e.g. advanced.
+	KeySection = "section"
+	// KeyOpensAt is the param name for the time of day, e.g. 14:00,
+	// a section opens.
+	KeyOpensAt = "at"
+)
+
+const tmplBodyJoin = `<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<title> {{.Title}} - {{.Name}} </title>
+<style type="text/css">
+body { font-family: Helvetica, Arial, sans-serif; margin: 2em; }
+label { display: block; margin-top: 0.7em; }
+.problem { color: #c22; }
+</style>
+</head>
+<body>
+<h1> {{.Title}} - {{.Name}} </h1>
+{{if .Problem}}<p class='problem'> {{.Problem}} </p>{{end}}
+<form method='POST' action='{{.Prefix}}/join'>
+  <label> {{.CodeLabel}} <input name='{{.KeyCode}}' value='{{.Code}}' required autofocus> </label>
+  <label> {{.NameLabel}} <input name='{{.KeyName}}' value='{{.Student}}' required> </label>
+  <p> <button type='submit'> {{.Join}} </button> </p>
+</form>
+</body>
+</html>
+`
+
+var tmplJoin = template.Must(template.New("join").Parse(tmplBodyJoin))
+
+// RenderJoin writes a page asking for the class code and the
+// student's name, with the given ones filled in, and the
+// problem with the last try at joining, if any.
+func RenderJoin(
+	w io.Writer, title, prefix, code, student, problem string, msgs *Messages) error {
+	return tmplJoin.Execute(w, struct {
+		Title, Name, Lang, Prefix string
+		CodeLabel, NameLabel      string
+		KeyCode, KeyName          string
+		Code, Student, Problem    string
+		Join                      string
+	}{title, msgs.Get("joinClass"), msgs.Lang(), prefix,
+		msgs.Get("classCode"), msgs.Get("yourName"),
+		KeyClassCode, KeyStudentName, code, student, problem, msgs.Get("join")})
+}
+
+const tmplBodyClassroom = `<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<title> {{.Title}} - {{.Name}} </title>
+<meta http-equiv="refresh" content="5">
+<style type="text/css">
+body { font-family: Helvetica, Arial, sans-serif; margin: 2em; }
+th, td { padding: 0.2em 0.7em; text-align: left; }
+td.ok { color: #2a2; }
+td.failed { color: #c22; }
+form { margin-bottom: 1em; }
+</style>
+</head>
+<body>
+<h1> {{.Title}} - {{.Name}} </h1>
+<form method='POST' action='{{.Prefix}}/classroom/jump?{{.KeyKey}}={{.Key}}'>
+  {{.JumpTo}}
+  <select name='{{.KeyLesson}}'>
+{{range $i, $l := .Lessons}}
+    <option value='{{$i}}'> {{$l}} </option>
+{{end}}
+  </select>
+  <button type='submit'> &#x2192; </button>
+</form>
+<table>
+<tr>
+  <th> {{.StudentLabel}} </th>
+  <th> {{.LessonLabel}} </th>
+  <th> {{.LastBlockLabel}} </th>
+  <th> {{.LastActiveLabel}} </th>
+</tr>
+{{range .Rows}}
+<tr>
+  <td> {{.Name}} </td>
+  <td> {{if ge .Lesson 0}}{{index $.Lessons .Lesson}}{{end}} </td>
+  <td class='{{.LastState}}'> {{.LastBlock}} {{.LastState}} </td>
+  <td> {{.LastActive.Format "15:04:05"}} </td>
+</tr>
+{{end}}
+</table>
+<h2> {{.ScheduleLabel}} </h2>
+<form method='POST' action='{{.Prefix}}/classroom/schedule?{{.KeyKey}}={{.Key}}'>
+  <select name='{{.KeySection}}'>
+{{range .Sections}}
+    <option value='{{.}}'> {{.}} </option>
+{{end}}
+  </select>
+  {{.LockUntil}} <input type='time' name='{{.KeyOpensAt}}'>
+  <button type='submit'> {{.Lock}} </button>
+</form>
+<table>
+<tr>
+  <th> {{.SectionLabel}} </th>
+  <th> {{.OpensAtLabel}} </th>
+  <th></th>
+</tr>
+{{range .Schedule}}
+<tr>
+  <td> {{.Section}} </td>
+  <td> {{if .Open}}{{$.IsOpen}}{{else if .Opens.IsZero}}{{$.UntilReleased}}{{else}}{{.Opens.Format "15:04"}}{{end}} </td>
+  <td>
+{{if not .Open}}
+    <form method='POST' action='{{$.Prefix}}/classroom/release?{{$.KeyKey}}={{$.Key}}'>
+      <input type='hidden' name='{{$.KeySection}}' value='{{.Section}}'>
+      <button type='submit'> {{$.Release}} </button>
+    </form>
+{{end}}
+  </td>
+</tr>
+{{end}}
+</table>
+</body>
+</html>
+`
+
+var tmplClassroom = template.Must(template.New("classroom").Parse(tmplBodyClassroom))
+
+// ClassroomRow is a student, as an instructor's dashboard shows them.
+type ClassroomRow struct {
+	Name string
+	// Lesson is the index of the lesson the student's
+	// page shows; -1 if unknown.
+	Lesson int
+	// LastBlock names the block the student last
+	// ran, e.g. "setup/install #2", and LastState is
+	// what became of it; both empty if they've run none.
+	LastBlock  string
+	LastState  string
+	LastActive time.Time
+}
+
+// ScheduleRow is a section of the tutorial, a course or lesson,
+// that the instructor locked, as their dashboard shows it.
+type ScheduleRow struct {
+	// Section is the path of the course or lesson, e.g. advanced.
+	Section string
+	// Opens is when it opens; zero if when the instructor says.
+	Opens time.Time
+	// Open is true if it has opened.
+	Open bool
+}
+
+// RenderClassroom writes an instructor's dashboard of a classroom's
+// students, with a form sending them all to a lesson, and another
+// scheduling when sections - courses or lessons - open.  Lessons
+// are the paths of the lessons' pages, by lesson index, sections
+// the paths that may be locked, and key is the instructor's
+// key, needed to send the forms.
+func RenderClassroom(
+	w io.Writer, title, prefix, key string, lessons, sections []string,
+	rows []ClassroomRow, schedule []ScheduleRow, msgs *Messages) error {
+	return tmplClassroom.Execute(w, struct {
+		Title, Name, Lang, Prefix       string
+		Key, KeyKey, KeyLesson          string
+		KeySection, KeyOpensAt          string
+		JumpTo                          string
+		StudentLabel, LessonLabel       string
+		LastBlockLabel, LastActiveLabel string
+		ScheduleLabel, SectionLabel     string
+		OpensAtLabel, LockUntil, Lock   string
+		Release, UntilReleased, IsOpen  string
+		Lessons, Sections               []string
+		Rows                            []ClassroomRow
+		Schedule                        []ScheduleRow
+	}{title, msgs.Get("classroom"), msgs.Lang(), prefix,
+		key, KeyInstructorKey, KeyLessonIndex, KeySection, KeyOpensAt,
+		msgs.Get("jumpTo"), msgs.Get("student"), msgs.Get("currentLesson"),
+		msgs.Get("lastBlock"), msgs.Get("lastActive"),
+		msgs.Get("schedule"), msgs.Get("section"), msgs.Get("opensAt"),
+		msgs.Get("lockUntil"), msgs.Get("lock"), msgs.Get("release"),
+		msgs.Get("untilReleased"), msgs.Get("isOpen"),
+		lessons, sections, rows, schedule})
+}
@@ -0,0 +1,59 @@
+package webapp
+
+import (
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/program"
+)
+
+// DebugExtract is the value of the KeyDebug param
+// overlaying each block with its Extraction.
+const DebugExtract = "extract"
+
+// ExtractView holds the filters the extraction overlay applies,
+// as print and test mode would apply those of their flags.
+type ExtractView struct {
+	// Label selecting blocks; base.WildCardLabel selects any.
+	Label base.Label
+	// Arch the blocks are for; empty means any.
+	Arch string
+}
+
+// LabelShown is the view's label as it'd be given to --label,
+// or "" if it's the wildcard.
+func (v *ExtractView) LabelShown() string {
+	if v.Label == base.WildCardLabel {
+		return ""
+	}
+	return string(v.Label)
+}
+
+// Extraction is what the overlay says of a block: what
+// mdrip sees in its labels, and whether it'd run it.
+type Extraction struct {
+	ID int
+	// Labels select the block, e.g. setup.
+	Labels []string
+	// Attributes configure the block, e.g. timeout=90s.
+	Attributes []string
+	// Reasons are the verdicts of each filter, in the order applied.
+	Reasons  []string
+	Selected bool
+}
+
+// extract returns the view's Extraction of the block,
+// or nil if there's no view.
+func (v *ExtractView) extract(b *program.BlockPgm) *Extraction {
+	if v == nil {
+		return nil
+	}
+	x := &Extraction{ID: b.ID()}
+	for _, l := range b.Labels() {
+		if l.IsAttribute() {
+			x.Attributes = append(x.Attributes, string(l))
+		} else {
+			x.Labels = append(x.Labels, string(l))
+		}
+	}
+	x.Reasons, x.Selected = program.ExplainLabels(b.Labels(), v.Label, v.Arch)
+	return x
+}
@@ -0,0 +1,29 @@
+#!/bin/sh
+# Fetches the libraries pages load into assets, to be compiled
+# into mdrip, so that pages, exports and bundles work offline.
+# Run by "go generate ./webapp"; commit what it writes.
+set -eu
+
+katex=0.16.9
+mermaid=8.13.10
+
+cd "$(dirname "$0")"
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+fetch() {
+  mkdir -p "$tmp/$1"
+  curl -fsSL "https://registry.npmjs.org/$1/-/$1-$2.tgz" | tar -xz -C "$tmp/$1"
+}
+
+fetch katex "$katex"
+rm -rf assets/katex
+mkdir -p assets/katex
+cp "$tmp/katex/package/dist/katex.min.js" \
+  "$tmp/katex/package/dist/katex.min.css" assets/katex/
+cp -R "$tmp/katex/package/dist/fonts" assets/katex/
+
+fetch mermaid "$mermaid"
+rm -rf assets/mermaid
+mkdir -p assets/mermaid
+cp "$tmp/mermaid/package/dist/mermaid.min.js" assets/mermaid/
@@ -0,0 +1,44 @@
+package webapp
+
+import (
+	"html/template"
+	"io"
+
+	"github.com/monopole/mdrip/model"
+)
+
+const tmplBodyGlossary = `<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<title> {{.Title}} - {{.Name}} </title>
+<style type="text/css">
+body { font-family: Helvetica, Arial, sans-serif; margin: 2em; }
+dt { font-weight: bold; margin-top: 0.7em; }
+</style>
+</head>
+<body>
+<h1> {{.Title}} - {{.Name}} </h1>
+<dl>
+{{range .Terms}}
+  <dt id='{{.}}'> {{.}} </dt>
+  <dd> {{index $.Glossary .}} </dd>
+{{end}}
+</dl>
+</body>
+</html>
+`
+
+var tmplGlossary = template.Must(template.New("glossary").Parse(tmplBodyGlossary))
+
+// RenderGlossary writes a page listing the glossary's terms
+// and their definitions.
+func RenderGlossary(
+	w io.Writer, title string, g model.Glossary, msgs *Messages) error {
+	return tmplGlossary.Execute(w, struct {
+		Title    string
+		Name     string
+		Lang     string
+		Terms    []string
+		Glossary model.Glossary
+	}{title, msgs.Get("glossary"), msgs.Lang(), g.Terms(), g})
+}
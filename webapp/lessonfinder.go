@@ -1,4 +1,4 @@
-package webserver
+package webapp
 
 import (
 	"fmt"
@@ -8,10 +8,10 @@ import (
 	"github.com/monopole/mdrip/model"
 )
 
-// lessonFinder traverses a tutorial tree to build quick
+// LessonFinder traverses a tutorial tree to build quick
 // data structures (a map and an array) that can answer
 // common questions without the need for more traversals.
-type lessonFinder struct {
+type LessonFinder struct {
 	nextLesson            int
 	courseCounter         int
 	namePathAccumulator   []string
@@ -20,19 +20,20 @@ type lessonFinder struct {
 	coursePathIndex       [][]int
 }
 
-func newLessonFinder() *lessonFinder {
-	return &lessonFinder{
+// NewLessonFinder makes a LessonFinder; have the tutorial accept it.
+func NewLessonFinder() *LessonFinder {
+	return &LessonFinder{
 		0, -1, []string{},
 		[]int{}, make(map[base.FilePath][]int), [][]int{}}
 }
 
-// getLessonPath returns ordered list of course IDs,
+// LessonPath returns ordered list of course IDs,
 // ending with the lesson ID.  The argument should be
 // a path, e.g. benelux/belgium/beer, the result is
 // something like [0, 2, 6], where benelux is course #0,
 // belgium is course #2 inside benelux, and beer is lesson
 // #6 inside belgium.
-func (v *lessonFinder) getLessonPath(path string) []int {
+func (v *LessonFinder) LessonPath(path string) []int {
 	r := v.coursePathMap[base.FilePath(path)]
 	if r == nil {
 		return []int{0}
@@ -40,18 +41,25 @@ func (v *lessonFinder) getLessonPath(path string) []int {
 	return r
 }
 
-// getCoursePaths returns a array of arrays.
+// HasPath is true if the path, e.g. benelux/belgium/beer,
+// names a course, lesson or block.
+func (v *LessonFinder) HasPath(path string) bool {
+	_, ok := v.coursePathMap[base.FilePath(path)]
+	return ok
+}
+
+// CoursePaths returns a array of arrays.
 // The index is a lesson ID, and the entry at that
 // index is an array of course IDs above the lesson.
-// In the example provided for getLessonPath, the
+// In the example provided for LessonPath, the
 // value at index 6 would be [0, 2], i.e. the beer
 // lesson is found under benelux/belgium.
-func (v *lessonFinder) getCoursePaths() [][]int {
+func (v *LessonFinder) CoursePaths() [][]int {
 	return v.coursePathIndex
 }
 
 // For debugging.
-func (v *lessonFinder) print() {
+func (v *LessonFinder) print() {
 	fmt.Println("-------------")
 	for k, v := range v.coursePathMap {
 		fmt.Printf("%20s %v\n", k, v)
@@ -60,14 +68,14 @@ func (v *lessonFinder) print() {
 	fmt.Println()
 }
 
-func (v *lessonFinder) addMapEntry() {
+func (v *LessonFinder) addMapEntry() {
 	newSlice := make([]int, len(v.coursePathAccumulator), len(v.coursePathAccumulator)+1)
 	copy(newSlice, v.coursePathAccumulator)
 	v.coursePathMap[base.FilePath(strings.Join(v.namePathAccumulator, "/"))] =
 		append(newSlice, v.nextLesson)
 }
 
-func (v *lessonFinder) addIndexEntry() {
+func (v *LessonFinder) addIndexEntry() {
 	newSlice := make([]int, len(v.coursePathAccumulator))
 	copy(newSlice, v.coursePathAccumulator)
 	if v.nextLesson != len(v.coursePathIndex) {
@@ -79,15 +87,17 @@ func (v *lessonFinder) addIndexEntry() {
 	v.coursePathIndex = append(v.coursePathIndex, newSlice)
 }
 
-func (v *lessonFinder) VisitBlockTut(x *model.BlockTut) {
+// VisitBlockTut maps the block's path.
+func (v *LessonFinder) VisitBlockTut(x *model.BlockTut) {
 	v.namePathAccumulator = append(v.namePathAccumulator, x.Name())
 	v.addMapEntry()
 	v.namePathAccumulator = v.namePathAccumulator[:len(v.namePathAccumulator)-1]
 }
 
-func (v *lessonFinder) VisitLessonTut(x *model.LessonTut) {
+// VisitLessonTut maps the lesson's path, and its blocks'.
+func (v *LessonFinder) VisitLessonTut(x *model.LessonTut) {
 	v.addIndexEntry()
-	v.namePathAccumulator = append(v.namePathAccumulator, x.Name())
+	v.namePathAccumulator = append(v.namePathAccumulator, x.Slug())
 	v.addMapEntry()
 	for _, c := range x.Children() {
 		c.Accept(v)
@@ -96,9 +106,10 @@ func (v *lessonFinder) VisitLessonTut(x *model.LessonTut) {
 	v.nextLesson++
 }
 
-func (v *lessonFinder) VisitCourse(x *model.Course) {
+// VisitCourse maps the course's path, and its children's.
+func (v *LessonFinder) VisitCourse(x *model.Course) {
 	v.courseCounter++
-	v.namePathAccumulator = append(v.namePathAccumulator, x.Name())
+	v.namePathAccumulator = append(v.namePathAccumulator, x.Slug())
 	v.coursePathAccumulator = append(v.coursePathAccumulator, v.courseCounter)
 	v.addMapEntry()
 	for _, c := range x.Children() {
@@ -108,7 +119,8 @@ func (v *lessonFinder) VisitCourse(x *model.Course) {
 	v.coursePathAccumulator = v.coursePathAccumulator[:len(v.coursePathAccumulator)-1]
 }
 
-func (v *lessonFinder) VisitTopCourse(x *model.TopCourse) {
+// VisitTopCourse maps the paths of the top course's children.
+func (v *LessonFinder) VisitTopCourse(x *model.TopCourse) {
 	v.addMapEntry()
 	for _, c := range x.Children() {
 		c.Accept(v)
@@ -1,4 +1,4 @@
-package webserver
+package webapp
 
 import (
 	"testing"
@@ -96,10 +96,10 @@ func slicesEqual(a, b []int) bool {
 
 func TestGetLessonPath(t *testing.T) {
 	for _, test := range pfTests1 {
-		v := newLessonFinder()
+		v := NewLessonFinder()
 		test.input.Accept(v)
 		for _, w := range test.results {
-			if got := v.getLessonPath(w.path); !slicesEqual(got, w.courseIdx) {
+			if got := v.LessonPath(w.path); !slicesEqual(got, w.courseIdx) {
 				t.Errorf("%s %s:\ngot\n\"%v\"\nwant\n\"%v\"\n",
 					test.name, w.path, got, w.courseIdx)
 			}
@@ -124,9 +124,9 @@ var pfTests2 = []pfTest2{
 
 func TestGetCoursePaths(t *testing.T) {
 	for _, test := range pfTests2 {
-		v := newLessonFinder()
+		v := NewLessonFinder()
 		test.input.Accept(v)
-		result := v.getCoursePaths()
+		result := v.CoursePaths()
 		if len(result) != len(test.results) {
 			t.Errorf("%s length test : got %d, want %d\n",
 				test.name, len(result), len(test.results))
@@ -0,0 +1,61 @@
+package webapp
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// LibraryPath is the path, under the app's prefix, serving the
+// libraries pages load when a lesson needs them: KaTeX, typesetting
+// math, and mermaid, drawing diagrams.  Exports copy them to the
+// same path, so neither needs the network.
+const LibraryPath = "/_/assets/"
+
+// Where pages get the libraries if mdrip was built without them.
+// fetchlibraries.sh fetches the same versions.
+const (
+	// mermaidCDN is the directory holding mermaid.min.js.
+	mermaidCDN = "https://cdn.jsdelivr.net/npm/mermaid@8.13.10/dist"
+	// katexCDN is the directory holding katex.min.js,
+	// katex.min.css and the fonts it refers to.
+	katexCDN = "https://cdn.jsdelivr.net/npm/katex@0.16.9/dist"
+)
+
+//go:generate sh fetchlibraries.sh
+
+//go:embed assets
+var libraries embed.FS
+
+// Libraries holds the files of the libraries pages load, at
+// paths like katex/katex.min.js, for serving at LibraryPath.
+func Libraries() fs.FS {
+	sub, err := fs.Sub(libraries, "assets")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// HasLibraries is true if mdrip was built holding all the
+// libraries pages load, so pages don't fetch them from a CDN.
+func HasLibraries() bool {
+	return hasLibrary("katex") && hasLibrary("mermaid")
+}
+
+func hasLibrary(name string) bool {
+	fi, err := fs.Stat(Libraries(), name)
+	return err == nil && fi.IsDir()
+}
+
+// Library is the URL of the directory holding the named library,
+// katex or mermaid: mdrip's own copy, if it was built with one,
+// else the library's CDN.
+func (wa *WebApp) Library(name string) string {
+	if hasLibrary(name) {
+		return wa.prefix + LibraryPath + name
+	}
+	if name == "katex" {
+		return katexCDN
+	}
+	return mermaidCDN
+}
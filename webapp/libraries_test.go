@@ -0,0 +1,34 @@
+package webapp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/diagram"
+)
+
+func TestLibrary(t *testing.T) {
+	ds, _ := base.NewDataSource("/tmp")
+	wa := NewWebApp(&SessionData{}, "http", "", "/k8s", emptyLesson, ds, []int{}, [][]int{{}},
+		[]string{}, diagram.Servers{}, DefaultMessages(), false, false, nil, false, "")
+	for name, cdn := range map[string]string{"katex": katexCDN, "mermaid": mermaidCDN} {
+		want := cdn
+		if hasLibrary(name) {
+			want = "/k8s" + LibraryPath + name
+		}
+		if got := wa.Library(name); got != want {
+			t.Errorf("%s: got %s, want %s", name, got, want)
+		}
+	}
+	var b bytes.Buffer
+	if err := wa.Render(&b); err != nil {
+		t.Fatal(err)
+	}
+	// The library's URL is escaped in the page's script.
+	want := strings.Replace(wa.Library("katex"), "/", `\/`, -1) + "/katex.min.js"
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("page doesn't load %s", want)
+	}
+}
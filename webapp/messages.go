@@ -0,0 +1,501 @@
+package webapp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Messages holds the text of the web app's chrome - buttons,
+// tooltips, help - in one language, keyed by message name.
+// Lesson content is never translated.
+type Messages struct {
+	lang string
+	text map[string]string
+}
+
+// DefaultLang is the language of messages not found in a catalog.
+const DefaultLang = "en"
+
+// catalogs are the built in message catalogs, by language.
+// Every key in a catalog must be in the DefaultLang catalog.
+var catalogs = map[string]map[string]string{
+	DefaultLang: {
+		"glossary":        "glossary",
+		"sendTo":          "send to",
+		"currentPane":     "current pane",
+		"runLesson":       "run lesson",
+		"runSection":      "run section",
+		"runSectionTitle": "Run this section, one block at a time",
+		"cancel":          "cancel",
+		"prereqTitle":     "Before this lesson, complete:",
+		"prereqMissing":   "(no such lesson)",
+		"prereqWarning":   "You've jumped ahead; the blocks below may depend on unfinished lessons.",
+		"sayTitle":        "Send %s to tmux",
+		"targetTitle":     "Always sent to this target",
+		"archTitle":       "For these architectures; click to show or hide",
+		"snapshotOf":      "Snapshot of markdown from",
+		"keys":            "Keys",
+		"keyHelp":         "help",
+		"keyActivate":     "activate (previous, next) code block",
+		"keyScroll":       "scroll to active code block",
+		"keyCopy":         "copy/execute activated block",
+		"keyLesson":       "(previous, next) lesson",
+		"keyHeader":       "minimize header",
+		"keyNav":          "nav sidebar",
+		"keyMonkey":       "monkey",
+		"tutorials":       "Tutorials",
+		"tags":            "tags",
+		"allTags":         "all",
+		"lessonLang":      "language",
+		"originalLang":    "as written",
+		"tagsTitle":       "Tags categorizing this block and its lesson",
+		"author":          "by",
+		"search":          "search",
+		"noMatches":       "no matches",
+		"edit":            "edit",
+		"propose":         "propose",
+		"editMessage":     "describe the edit",
+		"proposedOn":      "proposed on branch",
+		"openPullRequest": "open a pull request",
+		"close":           "close",
+		"extractView":     "Extraction view: blocks as mdrip would extract them with",
+		"extractSelected": "selected",
+		"extractSkipped":  "not selected",
+		"anyStatus":       "any status",
+		"verified":        "verified",
+		"stale":           "stale",
+		"unverified":      "unverified",
+		"anyLength":       "any length",
+		"short":           "under 15 minutes",
+		"medium":          "15 to 60 minutes",
+		"long":            "over an hour",
+		"lessons":         "lessons",
+		"minutes":         "min",
+		"notFound":        "Page not found",
+		"notFoundText":    "Nothing is at %s.  Perhaps you meant:",
+		"allLessons":      "all lessons",
+		"lastChanged":     "last changed %s by %s",
+		"keyPalette":      "jump to a lesson or block, or do something",
+		"palettePrompt":   "lesson, block or action",
+		"paletteLesson":   "lesson",
+		"paletteBlock":    "block",
+		"paletteAction":   "action",
+		"actionRunBlock":  "send the active block to tmux",
+		"actionRunLesson": "run the active lesson",
+		"actionNav":       "show or hide the nav sidebar",
+		"actionHeader":    "minimize or restore the header",
+		"actionHelp":      "show or hide help",
+		"actionTheme":     "switch between light and dark theme",
+		"actionEdit":      "edit the active lesson",
+		"actionAnyArch":   "show blocks for any architecture",
+		"actionArch":      "show blocks for architecture",
+		"actionTag":       "show tag",
+		"actionLang":      "show lessons in",
+		"noTmux":          "no tmux to send blocks to: running a block copies it, to paste into a terminal",
+		"progress":        "progress",
+		"session":         "session",
+		"lastActive":      "last active",
+		"classroom":       "classroom",
+		"joinClass":       "join the class",
+		"classCode":       "class code",
+		"yourName":        "your name",
+		"join":            "join",
+		"badClassCode":    "that is not the class code",
+		"student":         "student",
+		"currentLesson":   "lesson",
+		"lastBlock":       "last block",
+		"jumpTo":          "send everyone to",
+		"schedule":        "schedule",
+		"section":         "section",
+		"lockUntil":       "lock until",
+		"lock":            "lock",
+		"release":         "open now",
+		"opensAt":         "opens at",
+		"untilReleased":   "when opened",
+		"isOpen":          "open",
+		"lockedLesson":    "not open yet",
+		"summary":         "{run} of {count} blocks run: {ok} ok, {failed} failed",
+		"summaryTime":     "{seconds}s in all",
+		"toc":             "On this page",
+		"copyLinkTitle":   "Copy a link to this block",
+	},
+	"de": {
+		"glossary":        "Glossar",
+		"sendTo":          "senden an",
+		"currentPane":     "aktuelles Fenster",
+		"runLesson":       "Lektion ausführen",
+		"runSection":      "Abschnitt ausführen",
+		"runSectionTitle": "Diesen Abschnitt Block für Block ausführen",
+		"cancel":          "abbrechen",
+		"prereqTitle":     "Vor dieser Lektion abschließen:",
+		"prereqMissing":   "(keine solche Lektion)",
+		"prereqWarning":   "Sie haben vorgegriffen; die Blöcke unten hängen eventuell von unfertigen Lektionen ab.",
+		"sayTitle":        "%s an tmux senden",
+		"targetTitle":     "Wird immer an dieses Ziel gesendet",
+		"archTitle":       "Für diese Architekturen; klicken zum Ein- oder Ausblenden",
+		"snapshotOf":      "Momentaufnahme des Markdowns von",
+		"keys":            "Tasten",
+		"keyHelp":         "Hilfe",
+		"keyActivate":     "(vorherigen, nächsten) Codeblock aktivieren",
+		"keyScroll":       "zum aktiven Codeblock scrollen",
+		"keyCopy":         "aktiven Block kopieren/ausführen",
+		"keyLesson":       "(vorherige, nächste) Lektion",
+		"keyHeader":       "Kopfzeile minimieren",
+		"keyNav":          "Seitenleiste",
+		"keyMonkey":       "Affe",
+		"tutorials":       "Tutorials",
+		"tags":            "Tags",
+		"allTags":         "alle",
+		"lessonLang":      "Sprache",
+		"originalLang":    "wie geschrieben",
+		"tagsTitle":       "Tags dieses Blocks und seiner Lektion",
+		"author":          "von",
+		"search":          "suchen",
+		"noMatches":       "keine Treffer",
+		"edit":            "bearbeiten",
+		"propose":         "vorschlagen",
+		"editMessage":     "Änderung beschreiben",
+		"proposedOn":      "vorgeschlagen im Branch",
+		"openPullRequest": "Pull-Request öffnen",
+		"close":           "schließen",
+		"extractView":     "Extraktionsansicht: Blöcke, wie mdrip sie extrahieren würde, mit",
+		"extractSelected": "ausgewählt",
+		"extractSkipped":  "nicht ausgewählt",
+		"anyStatus":       "jeder Status",
+		"verified":        "geprüft",
+		"stale":           "veraltet",
+		"unverified":      "ungeprüft",
+		"anyLength":       "jede Dauer",
+		"short":           "unter 15 Minuten",
+		"medium":          "15 bis 60 Minuten",
+		"long":            "über eine Stunde",
+		"lessons":         "Lektionen",
+		"minutes":         "Min.",
+		"notFound":        "Seite nicht gefunden",
+		"notFoundText":    "Unter %s ist nichts.  Meinten Sie:",
+		"allLessons":      "alle Lektionen",
+		"lastChanged":     "zuletzt geändert am %s von %s",
+		"keyPalette":      "zu Lektion oder Block springen, Aktion ausführen",
+		"palettePrompt":   "Lektion, Block oder Aktion",
+		"paletteLesson":   "Lektion",
+		"paletteBlock":    "Block",
+		"paletteAction":   "Aktion",
+		"actionRunBlock":  "aktiven Block an tmux senden",
+		"actionRunLesson": "aktive Lektion ausführen",
+		"actionNav":       "Navigationsleiste ein- oder ausblenden",
+		"actionHeader":    "Kopfzeile minimieren oder wiederherstellen",
+		"actionHelp":      "Hilfe ein- oder ausblenden",
+		"actionTheme":     "zwischen hellem und dunklem Design wechseln",
+		"actionEdit":      "aktive Lektion bearbeiten",
+		"actionAnyArch":   "Blöcke für alle Architekturen zeigen",
+		"actionArch":      "Blöcke zeigen für Architektur",
+		"actionTag":       "Schlagwort zeigen",
+		"actionLang":      "Lektionen zeigen auf",
+		"noTmux":          "kein tmux zum Senden: Ausführen kopiert einen Block zum Einfügen in ein Terminal",
+		"progress":        "Fortschritt",
+		"session":         "Sitzung",
+		"lastActive":      "zuletzt aktiv",
+		"classroom":       "Kurs",
+		"joinClass":       "dem Kurs beitreten",
+		"classCode":       "Kurscode",
+		"yourName":        "dein Name",
+		"join":            "beitreten",
+		"badClassCode":    "das ist nicht der Kurscode",
+		"student":         "Teilnehmer",
+		"currentLesson":   "Lektion",
+		"lastBlock":       "letzter Block",
+		"jumpTo":          "alle schicken zu",
+		"schedule":        "Zeitplan",
+		"section":         "Abschnitt",
+		"lockUntil":       "sperren bis",
+		"lock":            "sperren",
+		"release":         "jetzt öffnen",
+		"opensAt":         "öffnet um",
+		"untilReleased":   "bei Freigabe",
+		"isOpen":          "offen",
+		"lockedLesson":    "noch nicht offen",
+		"summary":         "{run} von {count} Blöcken ausgeführt: {ok} ok, {failed} fehlgeschlagen",
+		"summaryTime":     "insgesamt {seconds}s",
+		"toc":             "Auf dieser Seite",
+		"copyLinkTitle":   "Link zu diesem Block kopieren",
+	},
+	"es": {
+		"glossary":        "glosario",
+		"sendTo":          "enviar a",
+		"currentPane":     "panel actual",
+		"runLesson":       "ejecutar lección",
+		"runSection":      "ejecutar sección",
+		"runSectionTitle": "Ejecutar esta sección, un bloque a la vez",
+		"cancel":          "cancelar",
+		"prereqTitle":     "Antes de esta lección, completa:",
+		"prereqMissing":   "(no existe tal lección)",
+		"prereqWarning":   "Te has adelantado; los bloques de abajo pueden depender de lecciones sin terminar.",
+		"sayTitle":        "Enviar %s a tmux",
+		"targetTitle":     "Siempre se envía a este destino",
+		"archTitle":       "Para estas arquitecturas; haz clic para mostrar u ocultar",
+		"snapshotOf":      "Instantánea del markdown de",
+		"keys":            "Teclas",
+		"keyHelp":         "ayuda",
+		"keyActivate":     "activar bloque de código (anterior, siguiente)",
+		"keyScroll":       "desplazarse al bloque activo",
+		"keyCopy":         "copiar/ejecutar el bloque activo",
+		"keyLesson":       "lección (anterior, siguiente)",
+		"keyHeader":       "minimizar encabezado",
+		"keyNav":          "barra de navegación",
+		"keyMonkey":       "mono",
+		"tutorials":       "Tutoriales",
+		"tags":            "etiquetas",
+		"allTags":         "todas",
+		"lessonLang":      "idioma",
+		"originalLang":    "como se escribió",
+		"tagsTitle":       "Etiquetas de este bloque y su lección",
+		"author":          "por",
+		"search":          "buscar",
+		"noMatches":       "sin resultados",
+		"edit":            "editar",
+		"propose":         "proponer",
+		"editMessage":     "describe el cambio",
+		"proposedOn":      "propuesto en la rama",
+		"openPullRequest": "abrir un pull request",
+		"close":           "cerrar",
+		"extractView":     "Vista de extracción: bloques como mdrip los extraería con",
+		"extractSelected": "seleccionado",
+		"extractSkipped":  "no seleccionado",
+		"anyStatus":       "cualquier estado",
+		"verified":        "verificado",
+		"stale":           "desactualizado",
+		"unverified":      "sin verificar",
+		"anyLength":       "cualquier duración",
+		"short":           "menos de 15 minutos",
+		"medium":          "de 15 a 60 minutos",
+		"long":            "más de una hora",
+		"lessons":         "lecciones",
+		"minutes":         "min",
+		"notFound":        "Página no encontrada",
+		"notFoundText":    "No hay nada en %s.  Quizás buscaba:",
+		"allLessons":      "todas las lecciones",
+		"lastChanged":     "modificada por última vez el %s por %s",
+		"keyPalette":      "ir a una lección o bloque, o hacer algo",
+		"palettePrompt":   "lección, bloque o acción",
+		"paletteLesson":   "lección",
+		"paletteBlock":    "bloque",
+		"paletteAction":   "acción",
+		"actionRunBlock":  "enviar el bloque activo a tmux",
+		"actionRunLesson": "ejecutar la lección activa",
+		"actionNav":       "mostrar u ocultar la barra de navegación",
+		"actionHeader":    "minimizar o restaurar la cabecera",
+		"actionHelp":      "mostrar u ocultar la ayuda",
+		"actionTheme":     "cambiar entre tema claro y oscuro",
+		"actionEdit":      "editar la lección activa",
+		"actionAnyArch":   "mostrar bloques de cualquier arquitectura",
+		"actionArch":      "mostrar bloques de la arquitectura",
+		"actionTag":       "mostrar la etiqueta",
+		"actionLang":      "mostrar las lecciones en",
+		"noTmux":          "no hay tmux al que enviar bloques: ejecutar un bloque lo copia, para pegarlo en una terminal",
+		"progress":        "progreso",
+		"session":         "sesión",
+		"lastActive":      "última actividad",
+		"classroom":       "clase",
+		"joinClass":       "unirse a la clase",
+		"classCode":       "código de la clase",
+		"yourName":        "tu nombre",
+		"join":            "unirse",
+		"badClassCode":    "ese no es el código de la clase",
+		"student":         "estudiante",
+		"currentLesson":   "lección",
+		"lastBlock":       "último bloque",
+		"jumpTo":          "llevar a todos a",
+		"schedule":        "horario",
+		"section":         "sección",
+		"lockUntil":       "bloquear hasta",
+		"lock":            "bloquear",
+		"release":         "abrir ahora",
+		"opensAt":         "se abre a las",
+		"untilReleased":   "al liberarse",
+		"isOpen":          "abierta",
+		"lockedLesson":    "aún no está abierta",
+		"summary":         "{run} de {count} bloques ejecutados: {ok} bien, {failed} fallidos",
+		"summaryTime":     "{seconds}s en total",
+		"toc":             "En esta página",
+		"copyLinkTitle":   "Copiar un enlace a este bloque",
+	},
+	"fr": {
+		"glossary":        "glossaire",
+		"sendTo":          "envoyer à",
+		"currentPane":     "volet actuel",
+		"runLesson":       "exécuter la leçon",
+		"runSection":      "exécuter la section",
+		"runSectionTitle": "Exécuter cette section, un bloc à la fois",
+		"cancel":          "annuler",
+		"prereqTitle":     "Avant cette leçon, terminez :",
+		"prereqMissing":   "(leçon introuvable)",
+		"prereqWarning":   "Vous avez pris de l'avance ; les blocs ci-dessous peuvent dépendre de leçons inachevées.",
+		"sayTitle":        "Envoyer %s à tmux",
+		"targetTitle":     "Toujours envoyé à cette cible",
+		"archTitle":       "Pour ces architectures ; cliquez pour afficher ou masquer",
+		"snapshotOf":      "Instantané du markdown de",
+		"keys":            "Touches",
+		"keyHelp":         "aide",
+		"keyActivate":     "activer le bloc de code (précédent, suivant)",
+		"keyScroll":       "défiler jusqu'au bloc actif",
+		"keyCopy":         "copier/exécuter le bloc actif",
+		"keyLesson":       "leçon (précédente, suivante)",
+		"keyHeader":       "réduire l'en-tête",
+		"keyNav":          "barre de navigation",
+		"keyMonkey":       "singe",
+		"tutorials":       "Tutoriels",
+		"tags":            "étiquettes",
+		"allTags":         "toutes",
+		"lessonLang":      "langue",
+		"originalLang":    "telle qu'écrite",
+		"tagsTitle":       "Étiquettes de ce bloc et de sa leçon",
+		"author":          "par",
+		"search":          "rechercher",
+		"noMatches":       "aucun résultat",
+		"edit":            "modifier",
+		"propose":         "proposer",
+		"editMessage":     "décrivez la modification",
+		"proposedOn":      "proposé sur la branche",
+		"openPullRequest": "ouvrir une pull request",
+		"close":           "fermer",
+		"extractView":     "Vue d'extraction : blocs tels que mdrip les extrairait avec",
+		"extractSelected": "sélectionné",
+		"extractSkipped":  "non sélectionné",
+		"anyStatus":       "tout statut",
+		"verified":        "vérifié",
+		"stale":           "périmé",
+		"unverified":      "non vérifié",
+		"anyLength":       "toute durée",
+		"short":           "moins de 15 minutes",
+		"medium":          "de 15 à 60 minutes",
+		"long":            "plus d'une heure",
+		"lessons":         "leçons",
+		"minutes":         "min",
+		"notFound":        "Page introuvable",
+		"notFoundText":    "Rien à %s.  Vouliez-vous dire :",
+		"allLessons":      "toutes les leçons",
+		"lastChanged":     "modifiée le %s par %s",
+		"keyPalette":      "aller à une leçon ou un bloc, ou agir",
+		"palettePrompt":   "leçon, bloc ou action",
+		"paletteLesson":   "leçon",
+		"paletteBlock":    "bloc",
+		"paletteAction":   "action",
+		"actionRunBlock":  "envoyer le bloc actif à tmux",
+		"actionRunLesson": "exécuter la leçon active",
+		"actionNav":       "afficher ou masquer la barre de navigation",
+		"actionHeader":    "réduire ou restaurer l'en-tête",
+		"actionHelp":      "afficher ou masquer l'aide",
+		"actionTheme":     "basculer entre thème clair et sombre",
+		"actionEdit":      "modifier la leçon active",
+		"actionAnyArch":   "afficher les blocs de toute architecture",
+		"actionArch":      "afficher les blocs de l'architecture",
+		"actionTag":       "afficher l'étiquette",
+		"actionLang":      "afficher les leçons en",
+		"noTmux":          "aucun tmux où envoyer les blocs : exécuter un bloc le copie, à coller dans un terminal",
+		"progress":        "progression",
+		"session":         "session",
+		"lastActive":      "dernière activité",
+		"classroom":       "classe",
+		"joinClass":       "rejoindre la classe",
+		"classCode":       "code de la classe",
+		"yourName":        "votre nom",
+		"join":            "rejoindre",
+		"badClassCode":    "ce n'est pas le code de la classe",
+		"student":         "élève",
+		"currentLesson":   "leçon",
+		"lastBlock":       "dernier bloc",
+		"jumpTo":          "envoyer tout le monde à",
+		"schedule":        "programme",
+		"section":         "section",
+		"lockUntil":       "verrouiller jusqu'à",
+		"lock":            "verrouiller",
+		"release":         "ouvrir maintenant",
+		"opensAt":         "ouvre à",
+		"untilReleased":   "à l'ouverture",
+		"isOpen":          "ouverte",
+		"lockedLesson":    "pas encore ouverte",
+		"summary":         "{run} blocs exécutés sur {count} : {ok} réussis, {failed} échoués",
+		"summaryTime":     "{seconds}s au total",
+		"toc":             "Sur cette page",
+		"copyLinkTitle":   "Copier un lien vers ce bloc",
+	},
+}
+
+// Langs are the languages of the built in catalogs, sorted.
+func Langs() []string {
+	result := make([]string, 0, len(catalogs))
+	for l := range catalogs {
+		result = append(result, l)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// DefaultMessages returns the messages in DefaultLang.
+func DefaultMessages() *Messages {
+	m, _ := NewMessages(DefaultLang, "")
+	return m
+}
+
+// NewMessages returns the messages in the given language, e.g. "de"
+// or "de-AT", with overrides read from the named YAML file, if any,
+// holding "name: text" lines.  Messages missing from the language's
+// catalog are taken from DefaultLang's.
+func NewMessages(lang, overrides string) (*Messages, error) {
+	tag := strings.ToLower(lang)
+	c, ok := catalogs[tag]
+	if !ok {
+		tag = strings.SplitN(tag, "-", 2)[0]
+		c, ok = catalogs[tag]
+	}
+	if !ok {
+		return nil, errors.Errorf(
+			"no messages in %q; choose from %s", lang, strings.Join(Langs(), ", "))
+	}
+	result := &Messages{tag, map[string]string{}}
+	for k, v := range catalogs[DefaultLang] {
+		result.text[k] = v
+	}
+	for k, v := range c {
+		result.text[k] = v
+	}
+	if len(overrides) == 0 {
+		return result, nil
+	}
+	data, err := ioutil.ReadFile(overrides)
+	if err != nil {
+		return nil, err
+	}
+	o := map[string]string{}
+	if err := yaml.Unmarshal(data, &o); err != nil {
+		return nil, errors.Wrapf(err, "bad messages in %s", overrides)
+	}
+	for k, v := range o {
+		if _, ok := result.text[k]; !ok {
+			return nil, errors.Errorf("unknown message %q in %s", k, overrides)
+		}
+		result.text[k] = v
+	}
+	return result, nil
+}
+
+// Lang is the language of the messages.
+func (m *Messages) Lang() string { return m.lang }
+
+// Get returns the named message, formatted with the given
+// arguments, or the name itself if there's no such message.
+func (m *Messages) Get(name string, args ...interface{}) string {
+	t, ok := m.text[name]
+	if !ok {
+		return name
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(t, args...)
+	}
+	return t
+}
@@ -0,0 +1,66 @@
+package webapp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCatalogsComplete(t *testing.T) {
+	for lang, c := range catalogs {
+		for k := range catalogs[DefaultLang] {
+			if _, ok := c[k]; !ok {
+				t.Errorf("%s: missing message %q", lang, k)
+			}
+		}
+		for k := range c {
+			if _, ok := catalogs[DefaultLang][k]; !ok {
+				t.Errorf("%s: message %q unknown in %s", lang, k, DefaultLang)
+			}
+		}
+	}
+}
+
+func TestNewMessages(t *testing.T) {
+	m, err := NewMessages("de-AT", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Lang() != "de" || m.Get("cancel") != "abbrechen" {
+		t.Errorf("got %s %q", m.Lang(), m.Get("cancel"))
+	}
+	if got := m.Get("sayTitle", "# hi"); got != "# hi an tmux senden" {
+		t.Errorf("got %q", got)
+	}
+	if _, err := NewMessages("tlh", ""); err == nil {
+		t.Errorf("expected an error for an unknown language")
+	}
+}
+
+func TestMessageOverrides(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdrip-messages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := filepath.Join(dir, "ui.yaml")
+	if err := ioutil.WriteFile(f, []byte("runLesson: start\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMessages("fr", f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Get("runLesson") != "start" || m.Get("cancel") != "annuler" {
+		t.Errorf("got %q, %q", m.Get("runLesson"), m.Get("cancel"))
+	}
+	if err := ioutil.WriteFile(f, []byte("runLeson: start\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewMessages("en", f); err == nil ||
+		!strings.Contains(err.Error(), "unknown message") {
+		t.Errorf("got %v, want an unknown message error", err)
+	}
+}
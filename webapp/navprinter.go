@@ -2,6 +2,7 @@ package webapp
 
 import (
 	"github.com/monopole/mdrip/model"
+	"html"
 	"io"
 	"strings"
 )
@@ -33,8 +34,8 @@ func (v *NavPrinter) navItemStyle() string {
 func (v *NavPrinter) VisitBlockTut(x *model.BlockTut) {
 }
 
-func (v *NavPrinter) addName(t model.Tutorial) {
-	v.name = append(v.name, t.Name())
+func (v *NavPrinter) addName(slug string) {
+	v.name = append(v.name, slug)
 }
 
 func (v *NavPrinter) rmName() {
@@ -48,15 +49,18 @@ func (v *NavPrinter) path() string {
 // VisitLessonTut visits a lesson to print it.
 func (v *NavPrinter) VisitLessonTut(x *model.LessonTut) {
 	v.lessonCounter++
-	v.addName(x)
+	v.addName(x.Slug())
 	v.P("<div class='%s'>", v.navItemStyle())
 	v.Down()
 	v.P("<div id='NL%d' class='navLessonTitleOff'", v.lessonCounter)
 	v.P("    onclick='lessonController.assureActiveLesson(%d)'", v.lessonCounter)
+	if tags := x.AllTags(); len(tags) > 0 {
+		v.P("    data-tags='%s'", html.EscapeString(strings.Join(tags, ",")))
+	}
 	v.P("    data-path='%s'>", v.path())
 	// Could loop over children here - decided not to.
 	v.Down()
-	v.P("%s", x.Name())
+	v.P("%s", html.EscapeString(x.NavName()))
 	v.Up()
 	v.P("</div>")
 	v.Up()
@@ -67,7 +71,7 @@ func (v *NavPrinter) VisitLessonTut(x *model.LessonTut) {
 // VisitCourse visits a course to print it.
 func (v *NavPrinter) VisitCourse(x *model.Course) {
 	v.courseCounter++
-	v.addName(x)
+	v.addName(x.Slug())
 	v.P("<div class='%s'>", v.navItemStyle())
 	v.Down()
 	v.P("<div class='navCourseTitle' onclick='lessonController.ncToggle(%d)'>",
@@ -0,0 +1,129 @@
+package webapp
+
+import (
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+)
+
+const tmplBodyNotFound = `<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<title> {{msg "notFound"}} </title>
+<style type="text/css">
+body { font-family: Helvetica, Arial, sans-serif; margin: 2em; }
+h1 { color: ` + blue700 + `; }
+li { margin-top: 0.5em; }
+.path { color: gray; font-size: 0.8em; }
+</style>
+</head>
+<body>
+<h1> {{msg "notFound"}} </h1>
+<p> {{msg "notFoundText" .Path}} </p>
+<ul>
+{{range .Pages}}
+  <li> <a href='{{$.Prefix}}/{{.Path}}'> {{.Title}} </a> <span class='path'> {{.Path}} </span> </li>
+{{end}}
+</ul>
+<p> <a href='{{.Prefix}}/'> {{msg "allLessons"}} </a> </p>
+</body>
+</html>
+`
+
+// maxNotFoundPages is how many pages a 404 page suggests.
+const maxNotFoundPages = 5
+
+// ClosestPages returns up to n pages, those whose paths are
+// closest to the given one, closest first; e.g. for a bookmark
+// to "setup/instal", the page at "install/setup" comes first.
+// Paths are compared a segment at a time, as reorganizing
+// content often moves pages between directories.
+func ClosestPages(p string, pages []PageMeta, n int) []PageMeta {
+	p = strings.ToLower(strings.Trim(p, "/"))
+	type scored struct {
+		page           PageMeta
+		segments, full int
+	}
+	var all []scored
+	for _, x := range pages {
+		q := strings.ToLower(x.Path)
+		all = append(all, scored{x, segmentDistance(p, q), editDistance(p, q)})
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].segments != all[j].segments {
+			return all[i].segments < all[j].segments
+		}
+		return all[i].full < all[j].full
+	})
+	var result []PageMeta
+	for i := 0; i < len(all) && i < n; i++ {
+		result = append(result, all[i].page)
+	}
+	return result
+}
+
+// segmentDistance sums, over the segments of path p, the edit
+// distance to the closest segment of path q.
+func segmentDistance(p, q string) int {
+	qs := strings.Split(q, "/")
+	total := 0
+	for _, x := range strings.Split(p, "/") {
+		best := len(x)
+		for _, y := range qs {
+			if d := editDistance(x, y); d < best {
+				best = d
+			}
+		}
+		total += best
+	}
+	return total
+}
+
+// editDistance is the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// RenderNotFound writes a page saying nothing is at the given
+// path, under the app's prefix, suggesting the closest pages.
+func RenderNotFound(
+	w io.Writer, prefix, p string, pages []PageMeta, msgs *Messages) error {
+	t, err := template.New("notFound").Funcs(
+		template.FuncMap{"msg": msgs.Get}).Parse(tmplBodyNotFound)
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, struct {
+		Lang   string
+		Prefix string
+		Path   string
+		Pages  []PageMeta
+	}{msgs.Lang(), prefix, p, ClosestPages(p, pages, maxNotFoundPages)})
+}
@@ -0,0 +1,34 @@
+package webapp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestClosestPages(t *testing.T) {
+	pages := []PageMeta{
+		{Path: "intro"}, {Path: "install/setup"}, {Path: "install/linux"}, {Path: "upgrade"}}
+	got := ClosestPages("/setup/instal", pages, 2)
+	if len(got) != 2 || got[0].Path != "install/setup" || got[1].Path != "install/linux" {
+		t.Errorf("got %v", got)
+	}
+	if got := ClosestPages("intr", pages, 1); got[0].Path != "intro" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestRenderNotFound(t *testing.T) {
+	var b bytes.Buffer
+	err := RenderNotFound(&b, "/k8s", "instal",
+		[]PageMeta{{"intro", "Intro", ""}, {"install", "Installing", ""}}, DefaultMessages())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Nothing is at instal.", "<a href='/k8s/install'> Installing </a>"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("page lacks %s:\n%s", want, b.String())
+		}
+	}
+}
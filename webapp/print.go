@@ -0,0 +1,39 @@
+package webapp
+
+// Print makes the page a handout: every lesson, one after
+// another, each starting a new page when printed, without the
+// app's header, navigation and buttons; see Printable.
+func (wa *WebApp) Print() { wa.print = true }
+
+// Printable is true if the page is a handout, to print or
+// make a PDF of, rather than an app.
+func (wa *WebApp) Printable() bool { return wa.print }
+
+// cssPrintView styles a handout, following, and so overriding,
+// cssInHeader, whose rules for paper apply, too, on screen
+// as well as on paper.
+const cssPrintView = `
+header, footer, .headSpacer, .navLeftBox, .navRightBox, .navLeftSpacer,
+.navRightSpacer, .helpBox, .paletteBox, .lessonControl, .codeBlockControl,
+.sequenceButton, .lessonSummary, .extractBanner, .deliveryBanner {
+  display: none !important;
+}
+.proseColumn {
+  width: 100% !important;
+  overflow: visible;
+}
+.oneLesson {
+  display: block !important;
+}
+.oneLesson + .oneLesson {
+  break-before: page;
+}
+.codeblockBody {
+  white-space: pre-wrap;
+  overflow-wrap: anywhere;
+  overflow-x: visible;
+}
+.printTitle {
+  text-align: center;
+}
+`
@@ -0,0 +1,77 @@
+package webapp
+
+import (
+	"html/template"
+	"io"
+	"time"
+)
+
+const tmplBodyProgress = `<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<title> {{.Title}} - {{.Name}} </title>
+<style type="text/css">
+body { font-family: Helvetica, Arial, sans-serif; margin: 2em; }
+th, td { padding: 0.2em 0.7em; text-align: left; }
+td.done { color: #2a2; }
+td.failed { color: #c22; }
+</style>
+</head>
+<body>
+<h1> {{.Title}} - {{.Name}} </h1>
+<table>
+<tr>
+  <th> {{.Session}} </th>
+  <th> {{.LastActive}} </th>
+{{range .Lessons}}
+  <th> {{.}} </th>
+{{end}}
+</tr>
+{{range .Rows}}
+<tr>
+  <td> <code>{{.Session}}</code> </td>
+  <td> {{.LastActive.Format "2006-01-02 15:04:05"}} </td>
+{{range $i, $n := .Done}}
+  <td{{if eq $n (index $.Blocks $i)}} class='done'{{end}}> {{$n}}/{{index $.Blocks $i}} </td>
+{{end}}
+{{if .Failed}}
+  <td class='failed'> {{.Failed}} &#x2717; </td>
+{{end}}
+</tr>
+{{end}}
+</table>
+</body>
+</html>
+`
+
+var tmplProgress = template.Must(template.New("progress").Parse(tmplBodyProgress))
+
+// ProgressRow is how far one session has made it through a tutorial.
+type ProgressRow struct {
+	Session    TypeSessID
+	LastActive time.Time
+	// Done holds, per lesson, the number of blocks that
+	// finished without error, or were sent.
+	Done []int
+	// Failed is the number of blocks whose last run failed.
+	Failed int
+}
+
+// RenderProgress writes a page showing, per session, how many of
+// each lesson's blocks have been done.  Blocks holds, per lesson,
+// the number of blocks it has.
+func RenderProgress(
+	w io.Writer, title string, lessons []string, blocks []int,
+	rows []ProgressRow, msgs *Messages) error {
+	return tmplProgress.Execute(w, struct {
+		Title      string
+		Name       string
+		Session    string
+		LastActive string
+		Lang       string
+		Lessons    []string
+		Blocks     []int
+		Rows       []ProgressRow
+	}{title, msgs.Get("progress"), msgs.Get("session"), msgs.Get("lastActive"),
+		msgs.Lang(), lessons, blocks, rows})
+}
@@ -0,0 +1,195 @@
+package webapp
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/monopole/mdrip/model"
+)
+
+// maxSearchHits is how many lessons a search returns.
+const maxSearchHits = 20
+
+// titleWeight is how many uses in its text a use in a lesson's title is worth.
+const titleWeight = 10
+
+// Bounds on the text around a search's first hit shown in its snippet.
+const (
+	snippetBefore = 40
+	snippetAfter  = 100
+)
+
+// SearchHit is a lesson matching a search.
+type SearchHit struct {
+	PageMeta
+	// Lesson is the lesson's index, as the app numbers lessons.
+	Lesson int
+	// Snippet is plain text of the lesson around its first hit.
+	Snippet string
+	score   int
+}
+
+// SearchIndex indexes the words of a tutorial's lessons: their
+// titles, prose and code.
+type SearchIndex struct {
+	pages []PageMeta
+	// texts are the lessons' plain text, for snippets.
+	texts []string
+	// words maps each word to the count of
+	// its uses in each lesson using it.
+	words map[string]map[int]int
+	// titleWords maps each word to the lessons using it in their title.
+	titleWords map[string]map[int]bool
+}
+
+// NewSearchIndex indexes the lessons of a tutorial.
+func NewSearchIndex(t model.Tutorial) *SearchIndex {
+	v := &searchIndexer{}
+	t.Accept(v)
+	x := &SearchIndex{
+		LessonPages(t), v.texts, map[string]map[int]int{}, map[string]map[int]bool{}}
+	for i, text := range x.texts {
+		for _, w := range searchWords(text) {
+			if x.words[w] == nil {
+				x.words[w] = map[int]int{}
+			}
+			x.words[w][i]++
+		}
+		for _, w := range searchWords(x.pages[i].Title) {
+			if x.titleWords[w] == nil {
+				x.titleWords[w] = map[int]bool{}
+			}
+			x.titleWords[w][i] = true
+		}
+	}
+	return x
+}
+
+// searchIndexer visits a tutorial, collecting each lesson's plain text.
+type searchIndexer struct {
+	texts []string
+}
+
+func (v *searchIndexer) VisitBlockTut(b *model.BlockTut) {}
+
+func (v *searchIndexer) VisitLessonTut(l *model.LessonTut) {
+	var parts []string
+	for _, b := range l.Blocks() {
+		parts = append(parts, plainText(string(b.Prose())), b.Code().String())
+	}
+	v.texts = append(v.texts, strings.Join(strings.Fields(strings.Join(parts, " ")), " "))
+}
+
+func (v *searchIndexer) VisitCourse(c *model.Course) {
+	for _, x := range c.Children() {
+		x.Accept(v)
+	}
+}
+
+func (v *searchIndexer) VisitTopCourse(t *model.TopCourse) {
+	v.VisitCourse(&t.Course)
+}
+
+// plainText drops markdown's link targets, emphasis and header marks.
+func plainText(md string) string {
+	return strings.Replace(
+		mdEmphasis.Replace(mdLink.ReplaceAllString(md, "$1")), "#", "", -1)
+}
+
+// searchWords are the lower case words, i.e. runs of
+// letters and digits, in the text.
+func searchWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Search returns the lessons holding every word of the query,
+// a word matching any word it starts, so that a search can
+// be made as it's typed.  Lessons with more hits, especially
+// in their title, come first.
+func (x *SearchIndex) Search(q string) []SearchHit {
+	terms := searchWords(q)
+	if len(terms) == 0 {
+		return nil
+	}
+	var scores map[int]int
+	for _, term := range terms {
+		found := map[int]int{}
+		for w, uses := range x.words {
+			if !strings.HasPrefix(w, term) {
+				continue
+			}
+			for i, n := range uses {
+				found[i] += n
+			}
+		}
+		for w, lessons := range x.titleWords {
+			if !strings.HasPrefix(w, term) {
+				continue
+			}
+			for i := range lessons {
+				found[i] += titleWeight
+			}
+		}
+		if scores == nil {
+			scores = found
+			continue
+		}
+		for i := range scores {
+			if _, ok := found[i]; ok {
+				scores[i] += found[i]
+			} else {
+				delete(scores, i)
+			}
+		}
+	}
+	var result []SearchHit
+	for i, s := range scores {
+		result = append(result, SearchHit{
+			x.pages[i], i, snippet(x.texts[i], terms[0]), s})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].score != result[j].score {
+			return result[i].score > result[j].score
+		}
+		return result[i].Lesson < result[j].Lesson
+	})
+	if len(result) > maxSearchHits {
+		result = result[:maxSearchHits]
+	}
+	return result
+}
+
+// snippet returns the text around the first use of
+// the term, at word boundaries, or the text's start.
+func snippet(text, term string) string {
+	lower := strings.ToLower(text)
+	i := strings.Index(lower, term)
+	if i < 0 || len(lower) != len(text) {
+		// Lower casing changed the text's length, so i
+		// may not be an index into it; start at the start.
+		i = 0
+	}
+	start, end := i-snippetBefore, i+len(term)+snippetAfter
+	prefix, suffix := "...", "..."
+	if start <= 0 {
+		start, prefix = 0, ""
+	} else if j := strings.Index(text[start:i], " "); j >= 0 {
+		start += j + 1
+	}
+	if end >= len(text) {
+		end, suffix = len(text), ""
+	} else if j := strings.LastIndex(text[i:end], " "); j > len(term) {
+		end = i + j
+	}
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return prefix + text[start:end] + suffix
+}
@@ -0,0 +1,72 @@
+package webapp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+)
+
+func searchLesson(p, prose, code string) *model.LessonTut {
+	md := model.NewMdContent()
+	md.AddBlockParsed(model.NewBlockParsed([]base.Label{},
+		base.MdProse(prose), base.OpaqueCode(code)))
+	return model.NewLessonTutFromMdContent(base.FilePath(p), md)
+}
+
+func TestSearch(t *testing.T) {
+	x := NewSearchIndex(model.NewCourse(base.FilePath("k8s"), []model.Tutorial{
+		searchLesson("k8s/intro.md", "Kubernetes runs [containers](http://x.io).", "echo hi\n"),
+		searchLesson("k8s/install.md", "Install **kubectl**, then run containers.", "kubectl version\n"),
+		searchLesson("k8s/cleanup.md", "Delete the cluster.", "kubectl delete\n"),
+	}))
+	var tests = map[string]struct {
+		q    string
+		want []int
+	}{
+		"none":       {"", nil},
+		"nothing":    {"zebra", nil},
+		"code":       {"kubectl", []int{1, 2}},
+		"prefix":     {"contain", []int{0, 1}},
+		"every":      {"Kubectl containers", []int{1}},
+		"title":      {"install", []int{1}},
+		"linkTarget": {"x.io", nil},
+	}
+	for n, test := range tests {
+		var got []int
+		for _, h := range x.Search(test.q) {
+			got = append(got, h.Lesson)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: got %v, want %v", n, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: got %v, want %v", n, got, test.want)
+				break
+			}
+		}
+	}
+	hits := x.Search("delete")
+	if len(hits) != 1 || hits[0].Path != "k8s/cleanup" ||
+		hits[0].Snippet != "Delete the cluster. kubectl delete" {
+		t.Errorf("got %+v", hits)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	text := strings.Repeat("lead ", 20) + "needle " + strings.Repeat("tail ", 40)
+	s := snippet(text, "needle")
+	if !strings.HasPrefix(s, "...lead") || !strings.HasSuffix(s, "tail...") ||
+		!strings.Contains(s, "needle") || len(s) > snippetBefore+snippetAfter+20 {
+		t.Errorf("got %q", s)
+	}
+	if s := snippet("short text", "absent"); s != "short text" {
+		t.Errorf("got %q", s)
+	}
+	if s := snippet(strings.Repeat("é", 100), "é"); !strings.HasSuffix(s, "é...") {
+		t.Errorf("got %q", s)
+	}
+}
@@ -0,0 +1,95 @@
+package webapp
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/monopole/mdrip/model"
+)
+
+// maxDescriptionLength is about as much of a description
+// as search engines show.
+const maxDescriptionLength = 160
+
+// PageMeta describes a lesson's page to search engines, and to
+// sites that show previews of links to it.
+type PageMeta struct {
+	// Path of the page, relative to the app's prefix, e.g.
+	// "setup/install"; the path the app uses to select the lesson.
+	Path string
+	// Title is the lesson's title, e.g. from its H1.
+	Title string
+	// Description is the lesson's first paragraph, as plain text.
+	Description string
+}
+
+// LessonPages lists the pages of a tutorial's lessons,
+// in the order the app numbers lessons.
+func LessonPages(t model.Tutorial) []PageMeta {
+	v := &pageLister{}
+	t.Accept(v)
+	return v.pages
+}
+
+// pageLister visits a tutorial, describing each lesson's page.
+type pageLister struct {
+	names []string
+	pages []PageMeta
+}
+
+func (v *pageLister) VisitBlockTut(b *model.BlockTut) {}
+
+func (v *pageLister) VisitLessonTut(l *model.LessonTut) {
+	var prose []string
+	for _, b := range l.Blocks() {
+		prose = append(prose, string(b.Prose()))
+	}
+	v.pages = append(v.pages, PageMeta{
+		Path:        strings.Join(append(v.names, l.Slug()), "/"),
+		Title:       l.Title(),
+		Description: describe(strings.Join(prose, "\n\n"))})
+}
+
+func (v *pageLister) VisitCourse(c *model.Course) {
+	v.names = append(v.names, c.Slug())
+	for _, x := range c.Children() {
+		x.Accept(v)
+	}
+	v.names = v.names[:len(v.names)-1]
+}
+
+func (v *pageLister) VisitTopCourse(t *model.TopCourse) {
+	for _, x := range t.Children() {
+		x.Accept(v)
+	}
+}
+
+var (
+	mdLink     = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	mdEmphasis = strings.NewReplacer("**", "", "__", "", "`", "", "*", "")
+)
+
+// describe returns, as plain text, the first paragraph of the given
+// markdown, skipping headers, comments, html and images; shortened,
+// at a word, to maxDescriptionLength.
+func describe(md string) string {
+	for _, p := range strings.Split(md, "\n\n") {
+		p = strings.TrimSpace(p)
+		if len(p) == 0 || strings.HasPrefix(p, "#") ||
+			strings.HasPrefix(p, "<") || strings.HasPrefix(p, "![") ||
+			strings.HasPrefix(p, ">") {
+			continue
+		}
+		text := strings.Join(strings.Fields(
+			mdEmphasis.Replace(mdLink.ReplaceAllString(p, "$1"))), " ")
+		if len(text) <= maxDescriptionLength {
+			return text
+		}
+		cut := strings.LastIndex(text[:maxDescriptionLength-3], " ")
+		if cut < 0 {
+			cut = maxDescriptionLength - 3
+		}
+		return text[:cut] + "..."
+	}
+	return ""
+}
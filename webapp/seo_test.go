@@ -0,0 +1,24 @@
+package webapp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	long := strings.Repeat("word ", 40)
+	for _, test := range []struct {
+		md, want string
+	}{
+		{"", ""},
+		{"# Title\n\nFirst *long*\nparagraph.\n\nSecond.", "First long paragraph."},
+		{"<!-- @x -->\n\n![pic](a.png)\n\nSee [the docs](http://x.io) and `ls`.",
+			"See the docs and ls."},
+		{"> **Note** aside\n\nBody.", "Body."},
+		{long, strings.TrimSpace(strings.Repeat("word ", 31)) + "..."},
+	} {
+		if got := describe(test.md); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.md, got, test.want)
+		}
+	}
+}
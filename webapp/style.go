@@ -0,0 +1,1031 @@
+package webapp
+
+import (
+	"encoding/base64"
+	"html/template"
+	"io/ioutil"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Themes a page may start in.
+const (
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+)
+
+// Branding lets an organization make a served, or
+// exported, tutorial look like its own.
+type Branding struct {
+	// Theme is the one pages start in, ThemeLight or ThemeDark;
+	// a reader's own choice, kept by their browser, wins.
+	Theme string
+	// CSS follows the page's own styles, so its rules win.
+	CSS string
+	// Title, if not empty, replaces the tutorial's first H1.
+	Title string
+	// Logo, if not empty, is the URL of an image for the header.
+	Logo string
+}
+
+// NewBranding makes a Branding from the given theme, path of
+// a CSS file, title, and logo.  The logo is a URL, or the path
+// of an image file, which is inlined, so exported pages hold it.
+func NewBranding(theme, cssPath, title, logo string) (Branding, error) {
+	result := Branding{Theme: theme, Title: title, Logo: logo}
+	if theme != "" && theme != ThemeLight && theme != ThemeDark {
+		return result, errors.Errorf(
+			"unknown theme %q; choose %s or %s", theme, ThemeLight, ThemeDark)
+	}
+	if len(cssPath) > 0 {
+		data, err := ioutil.ReadFile(cssPath)
+		if err != nil {
+			return result, err
+		}
+		result.CSS = string(data)
+	}
+	if len(logo) > 0 && !strings.Contains(logo, "://") {
+		kind := mime.TypeByExtension(filepath.Ext(logo))
+		if !strings.HasPrefix(kind, "image/") {
+			return result, errors.Errorf("logo %s isn't an image", logo)
+		}
+		data, err := ioutil.ReadFile(logo)
+		if err != nil {
+			return result, err
+		}
+		result.Logo = "data:" + kind + ";base64," + base64.StdEncoding.EncodeToString(data)
+	}
+	return result, nil
+}
+
+// Brand applies the branding to the web app's pages.
+func (wa *WebApp) Brand(b Branding) {
+	wa.brand = b
+	if len(b.Title) > 0 {
+		wa.title = b.Title
+	}
+}
+
+// DarkTheme is true if pages start in the dark theme.
+func (wa *WebApp) DarkTheme() bool { return wa.brand.Theme == ThemeDark }
+
+// CustomCSS is the branding's styles, trusted as the
+// server's operator wrote them.
+func (wa *WebApp) CustomCSS() template.CSS { return template.CSS(wa.brand.CSS) }
+
+// Logo is the URL of the branding's logo, or empty.
+func (wa *WebApp) Logo() template.URL { return template.URL(wa.brand.Logo) }
+
+// cssInHeader is the template of the page's styles, which
+// a Branding's CSS may override.
+const cssInHeader = `
+body {
+  padding: 0;
+  margin: 0;
+  background-color: darkgray;
+  /* font-family: "Roboto", sans-serif; */
+  /* font-family: "Veranda", Veranda, sans-serif; */
+  font-family: Verdana, Geneva, sans-serif;
+  position: relative;
+  font-size: 12pt;
+  line-height: 1.4;
+  -webkit-font-smoothing: antialiased;
+  width: 100%;
+}
+
+td.kind {
+  text-align: right;
+  padding-right: 2em;
+}
+
+header, .headSpacer {
+  height: {{.LayHeaderHeight}}px;
+  width: inherit;
+  transition: height {{.TransitionSpeedMs}}ms;
+}
+
+header {
+  position: fixed;
+  top: 0;
+  background: {{.ColorHeader}};
+  /* background: linear-gradient(0deg, {{.ColorBackground}}, {{.ColorHeader}}); */
+  display: flex;
+  justify-content: space-around; /* space-between */
+  flex-direction: row;
+  flex-wrap: nowrap;
+  align-items: center;
+  transition: height {{.TransitionSpeedMs}}ms;
+  box-shadow: 0 2px 2px 2px rgba(0,0,0,.4);
+}
+
+.navLeftBox, .navRightBox {
+  position: fixed;
+  top: calc({{.LayHeaderHeight}}px + 2px);  /* leave room for header drop-shadow */
+  height: calc(100vh - ({{.LayFooterHeight}}px + {{.LayHeaderHeight}}px + 4px));
+  background-color: {{.ColorNavBackground}};
+  color: {{.ColorNavText}};
+  display: inline-block;
+  overflow: hidden;  /* initially hideNav */
+  width: 0px;  /* initially hideNav */
+  min-width: 0px;  /* initially hideNav */
+  transition: width {{.TransitionSpeedMs}}ms, min-width {{.TransitionSpeedMs}}ms;
+}
+.navRightBoxShadow {
+  /* shadow on bottom, top and left */
+  box-shadow: 0 2px 2px 2px rgba(0,0,0,.2), -2px 0px 2px 2px rgba(0,0,0,.2);
+}
+.navLeftBoxShadow {
+  /* shadow on bottom, top and right */
+  box-shadow: 0 2px 2px 2px rgba(0,0,0,.2), 2px 0px 2px 2px rgba(0,0,0,.2);
+}
+.navActual {
+  padding-left: 1em;
+}
+
+a {
+  text-decoration: none;
+}
+a:hover, a:visited, a:link, a:active {
+  text-decoration: none;
+}
+
+.navCourseTitle {
+  padding: 0px;
+}
+
+.navCourseTitle:hover {
+  color: {{.ColorHover}};
+  font-weight: bold;
+}
+
+.navItemTop {
+  /* top rig bot lef */
+  padding: {{.LayNavTopBotPad}}px 0px {{.LayNavTopBotPad}}px 4px;
+}
+
+.navItemBox {
+  /* top rig bot lef */
+  padding: {{.LayNavTopBotPad}}px 0px {{.LayNavTopBotPad}}px {{.LayNavLeftPad}}px;
+}
+
+.navCourseContent {
+  /* top rig bot lef */
+  padding: {{.LayNavTopBotPad}}px 0px 0px 0px;
+}
+
+.navLessonTitleOn {
+  background-color: {{.ColorNavSelected}};
+}
+
+.navLessonTitleOff {
+}
+
+.navLessonTitleOff:hover {
+  color: {{.ColorHover}};
+  font-weight: bold;
+}
+
+.lessonToc {
+  display: none;
+  font-size: smaller;
+}
+.lessonToc ul {
+  list-style: none;
+  padding: 0;
+  margin: 0;
+}
+.tocTitle {
+  font-weight: bold;
+  padding: {{.LayNavTopBotPad}}px 0px;
+}
+.lessonToc a {
+  color: inherit;
+  display: block;
+  padding: 2px 4px;
+  border-left: 2px solid transparent;
+}
+.lessonToc a:hover {
+  color: {{.ColorHover}};
+}
+.lessonToc a.tocOn {
+  border-left-color: {{.ColorHover}};
+  background-color: {{.ColorNavSelected}};
+}
+.tocLevel3 {
+  padding-left: 1em;
+}
+.oneLesson h2[id], .oneLesson h3[id] {
+  scroll-margin-top: calc({{.LayHeaderHeight}}px + 1em);
+}
+
+.scrollingColumn {
+  width: inherit;
+}
+
+.navLeftSpacer, .navRightSpacer {
+   width: {{.LayNavBoxWidth}}px;
+   min-width: {{.LayNavBoxWidth}}px;
+   display: none;  /* initially hideNav */
+}
+
+.proseRow {
+  background-color: {{.ColorBackground}};
+  width: inherit;
+  display: flex;
+  flex-direction: row;
+}
+
+footer {
+  background: {{.ColorHeader}};
+  /* background: linear-gradient(0deg, {{.ColorHeader}}, {{.ColorBackground}}); */
+  height: {{.LayFooterHeight}}px;
+}
+
+.helpButtonBox, .navButtonBox {
+  font-size: larger;
+  display: flex;
+  flex-direction: row;
+  justify-content: center;
+  align-items: center;
+  cursor: pointer;
+  color: {{.ColorControls}};
+  font-weight: bold;
+  border-radius: 50%;
+}
+
+.helpButtonBox {
+  width: 2em;
+}
+
+.helpButtonBox:hover {
+  background-color: {{.ColorHover}};
+  transition: all {{.TransitionSpeedMs}}ms;
+  box-shadow: inset 0 0 0 3px rgba(255,255,255,0.1);
+}
+
+.headerColumn {
+  width: 80%;
+  min-width: {{.LayMinHeaderWidth}}px;
+  height: inherit;
+  display: flex;
+  justify-content: center;
+  flex-direction: column;
+  flex-wrap: nowrap;
+  align-items: center;
+}
+
+
+title {
+  font-size: 2em;
+  font-weight: bold;
+  display: flex;
+  flex-direction: column;
+  justify-content: center;
+  align-items: center;
+}
+
+.activeLessonName {
+  font-weight: bold;
+}
+
+.lessonNavRow {
+  height: inherit;
+  display: flex;
+  width: 100%;
+  justify-content: center;
+  flex-direction: row;
+  flex-wrap: nowrap;
+  align-items: center;
+}
+
+.helpBox {
+  position: fixed;
+  top: {{.LayHeaderHeight}}px;
+  left: {{.LayNavBoxWidth}}px;
+  right: {{.LayNavBoxWidth}}px;
+  height: 0px;  /* initially hideHelp */
+  z-index: 3;
+  background-color: {{.ColorHelpBackground}};
+  color: {{.ColorNavText}};
+  transition: height {{.TransitionSpeedMs}}ms;
+  overflow: auto;
+}
+
+.helpActual {
+  padding: 1em;
+}
+
+.proseColumn {
+  display: flex;
+  justify-content: flex-start;
+  flex-direction: column;
+  overflow-x: hidden;
+  overflow-y: auto;
+  align-items: flex-start;
+  transition: width {{.TransitionSpeedMs}}ms;
+}
+
+.proseActual {
+  /* top right bottom left */
+  padding: 0 1em 0 1em;
+}
+
+.lessonPrevClickerRow, .lessonNextClickerRow {
+  height: 100%;
+  color: {{.ColorControls}};
+  cursor: pointer;
+  display: flex;
+  flex-basis: 45%;
+  flex-direction: row;
+  flex-wrap: nowrap;
+  align-items: center;
+}
+.lessonPrevClickerRow:hover, .lessonNextClickerRow:hover {
+  color: {{.ColorHover}};
+  font-weight: bold;
+}
+.lessonPrevClickerRow {
+  justify-content: flex-end;
+}
+.lessonNextClickerRow {
+}
+
+.lessonPrevPointer, .lessonNextPointer {
+  /* top right bottom left */
+  padding: 0 1em 0 1em;
+  font-weight: bold;
+  font-size: larger;
+}
+
+.lessonPrevTitle, .lessonNextTitle {
+  font-style: oblique;
+  width: 100%;
+  cursor: pointer;
+  display: inline-block;
+}
+.lessonPrevTitle {
+  text-align: right;
+}
+
+.commandBlockBody {
+  margin: 0px;
+  border: 0px;
+  padding: 0px;
+}
+
+.codeBlockCheckOff {
+  display: inline-block;
+  width: 24px;
+  height: 15px;
+  background-repeat: no-repeat;
+  background-size: contain;
+  background-image: url(data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAABgAAAAWCAMAAADto6y6AAAABGdBTUEAALGPC/xhBQAAAAFzUkdCAK7OHOkAAAAgY0hSTQAAeiYAAICEAAD6AAAAgOgAAHUwAADqYAAAOpgAABdwnLpRPAAAAQtQTFRFAAAAAH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//////BQzC2AAAAFd0Uk5TAAADLy4QZVEHKp8FAUnHbeJ3BAh68IYGC4f4nQyM/LkYCYnXf/rvAm/2/oFY7rcTPuHkOCEky3YjlW4Pqbww0MVTfUZA96p061Xs3mz1e4P70R2aHJYf2KM0AgAAAAFiS0dEWO21xI4AAAAJcEhZcwAAEysAABMrAbkohUIAAADTSURBVCjPbdDZUsJAEAXQXAgJIUDCogHBkbhFEIgCsqmo4MImgij9/39iUT4Qkp63OV0zfbsliTkIhWWOEVHUKOdaTNER9HgiaYQY1xUzlWY8kz04tBjP5Y8KRc6PxUmJcftUnMkIFGCdX1yqjDtX5cp1MChQrVHd3Xn8/y1wc0uNpuejZmt7Ae7aJDreBt1e3wVw/0D06HobYPD0/GI7Q0G10V4i4NV8e/8YE/V8KwImUxJEM82fFM78k4gW3MhfS1p9B3ckobgWBpiChJ/fjc//AJIfFr4X0swAAAAAJXRFWHRkYXRlOmNyZWF0ZQAyMDE2LTA3LTMwVDE0OjI3OjUxLTA3OjAwUzMirAAAACV0RVh0ZGF0ZTptb2RpZnkAMjAxNi0wNy0zMFQxNDoyNzo0NC0wNzowMLz8tSkAAAAZdEVYdFNvZnR3YXJlAHd3dy5pbmtzY2FwZS5vcmeb7jwaAAAAFXRFWHRUaXRsZQBibHVlIENoZWNrIG1hcmsiA8jIAAAAAElFTkSuQmCC);
+}
+
+.codePrompt {
+  background-color: {{.ColorCodeHover}};
+  display: none;
+}
+
+.codeBlockButton {
+  height: 100%;
+  cursor: pointer;
+}
+
+.codeBlockButton:hover {
+  color: {{.ColorCodeHover}};
+}
+
+.codeBlockState {
+  padding-left: 5px;
+}
+
+.codeBlockState_running:after {
+  content: '\2026';
+}
+
+.codeBlockState_ok:after {
+  content: '\2714';
+  color: {{.ColorControls}};
+}
+
+.codeBlockOutput {
+  margin: 0px;
+  padding: 0.3em 1em;
+  max-height: 20em;
+  overflow: auto;
+  border-left: 3px solid {{.ColorControls}};
+  white-space: pre-wrap;
+}
+
+.codeBlockOutput_stderr, .codeBlockExit {
+  color: {{.ColorHover}};
+}
+
+.codeBlockState_failed:after {
+  content: '\2718';
+  color: {{.ColorHover}};
+}
+
+.prereqs {
+  margin: 0.5em 0em;
+  padding: 0.2em 1em;
+  border-left: 3px solid {{.ColorHeader}};
+}
+
+.prereqLink {
+  cursor: pointer;
+  text-decoration: underline;
+}
+
+.prereqCheck:before {
+  content: '\2610';
+}
+
+.prereqDone .prereqCheck:before {
+  content: '\2611';
+  color: {{.ColorControls}};
+}
+
+.prereqMissing {
+  font-style: italic;
+}
+
+.prereqWarning {
+  display: none;
+  color: {{.ColorHover}};
+}
+
+.lessonSummary {
+  display: none;
+  margin: 1em 0em;
+  padding: 0.2em 1em;
+  border-left: 3px solid {{.ColorHeader}};
+}
+
+.lessonSummary ul {
+  margin: 0.3em 0em;
+}
+
+.lessonSummary_failed {
+  color: {{.ColorHover}};
+}
+
+.lessonAuthor {
+  font-style: italic;
+  color: {{.ColorHeader}};
+}
+
+.lessonRevision {
+  font-size: 0.8em;
+  color: gray;
+}
+
+.lessonControl {
+  text-align: right;
+  font-family: "Lucida Console", Monaco, monospace;
+}
+
+.math.display {
+  display: block;
+  text-align: center;
+}
+
+.zoomable {
+  cursor: zoom-in;
+  max-width: 100%;
+  height: auto;
+}
+
+.lightbox {
+  display: none;
+  position: fixed;
+  top: 0;
+  left: 0;
+  width: 100%;
+  height: 100%;
+  z-index: 100;
+  align-items: center;
+  justify-content: center;
+  background-color: rgba(0, 0, 0, 0.85);
+  cursor: zoom-out;
+}
+
+.lightboxImage {
+  max-width: 95%;
+  max-height: 95%;
+}
+
+.paletteBox {
+  display: none;
+  position: fixed;
+  top: 0;
+  left: 0;
+  width: 100%;
+  height: 100%;
+  z-index: 95;
+  justify-content: center;
+  align-items: flex-start;
+  background-color: rgba(0, 0, 0, 0.3);
+}
+
+.palette {
+  margin-top: 15vh;
+  width: 40em;
+  max-width: 90%;
+  border: solid 1px #555;
+  border-radius: 4px;
+  box-shadow: 0px 2px 2px 1px rgba(0,0,0,.3);
+  background-color: {{.ColorBackground}};
+}
+
+.paletteInput {
+  width: 100%;
+  box-sizing: border-box;
+  padding: 0.5em;
+  font-size: 1em;
+  border: none;
+  border-bottom: solid 1px #555;
+  outline: none;
+}
+
+.paletteItems {
+  max-height: 50vh;
+  overflow: auto;
+}
+
+.paletteItem {
+  cursor: pointer;
+  padding: 0.3em 0.5em;
+  white-space: nowrap;
+  overflow: hidden;
+  text-overflow: ellipsis;
+}
+
+.paletteChosen {
+  background-color: {{.ColorNavBackground}};
+}
+
+.paletteKind {
+  display: inline-block;
+  width: 4em;
+  font-size: 0.8em;
+  color: {{.ColorHeader}};
+}
+
+.paletteDetail {
+  padding-left: 1em;
+  font-family: "Lucida Console", Monaco, monospace;
+  font-size: 0.8em;
+  color: gray;
+}
+
+html.darkTheme {
+  filter: invert(1) hue-rotate(180deg);
+}
+
+html.darkTheme img {
+  filter: invert(1) hue-rotate(180deg);
+}
+
+html.darkTheme .lightboxImage {
+  filter: none;
+}
+
+.brandLogo {
+  max-height: 40px;
+  margin-bottom: 4px;
+}
+
+.diagram {
+  max-width: 100%;
+}
+
+.glossaryTerm {
+  cursor: help;
+  text-decoration: underline dotted;
+}
+
+.glossaryRow {
+  font-family: "Lucida Console", Monaco, monospace;
+  font-size: 0.8em;
+}
+
+.glossaryRow a {
+  color: {{.ColorHeader}};
+}
+
+.targetRow, .tagRow, .langRow {
+  font-family: "Lucida Console", Monaco, monospace;
+  font-size: 0.8em;
+}
+
+.deliveryBanner {
+  display: none;
+  font-family: "Lucida Console", Monaco, monospace;
+  font-size: 0.8em;
+  padding: 0.2em 0.5em;
+  background-color: #fff3c4;
+  color: #5c4a00;
+}
+
+.searchRow {
+  padding: 0.5em 1em 0em 1em;
+}
+
+.searchRow input {
+  width: 100%;
+  box-sizing: border-box;
+}
+
+.searchHit {
+  cursor: pointer;
+  padding: 0.3em 0em;
+  font-size: 0.8em;
+}
+
+.searchHit:hover .searchHitTitle {
+  text-decoration: underline;
+}
+
+.searchHitSnippet {
+  color: gray;
+}
+
+mark.searchMark {
+  background-color: #FFF59D;
+}
+
+.codeBlockTags {
+  padding: 0px 5px;
+  font-size: 0.8em;
+  color: {{.ColorHeader}};
+}
+
+.codeBlockTarget {
+  padding: 0px 5px;
+  font-style: italic;
+  color: {{.ColorHeader}};
+}
+
+.codeBlockArch {
+  cursor: pointer;
+  padding: 0px 5px;
+  font-style: italic;
+  color: {{.ColorHeader}};
+}
+
+.otherArch {
+  opacity: 0.5;
+}
+
+.otherArch .codeblockBody {
+  display: none;
+}
+
+/* Variants are tabs, working without script, e.g. in an export. */
+.variants {
+  display: flex;
+  flex-wrap: wrap;
+}
+
+.variants > .variantRadio {
+  display: none;
+}
+
+.variantTab {
+  order: 0;
+  cursor: pointer;
+  padding: 2px 10px;
+  margin-left: 20px;
+  border-bottom: 2px solid transparent;
+}
+
+.variantRadio:checked + .variantTab {
+  color: {{.ColorHeader}};
+  border-bottom-color: {{.ColorHeader}};
+}
+
+.variantPanel {
+  order: 1;
+  width: 100%;
+  display: none;
+}
+
+.variantRadio:checked + .variantTab + .variantPanel {
+  display: block;
+}
+
+/* On paper, e.g. a PDF, there are no tabs; show every variant, labeled. */
+@media {{if .Printable}}all{{else}}print{{end}} {
+  .variants {
+    display: block;
+  }
+  .variantTab {
+    display: none;
+  }
+  .variantPanel {
+    display: block;
+  }
+  .variantPanel::before {
+    content: attr(data-variant);
+    font-weight: bold;
+    padding-left: 20px;
+  }
+  .otherArch .codeblockBody {
+    display: block;
+  }
+}
+
+.otherTag {
+  opacity: 0.4;
+}
+
+.lockedLesson {
+  opacity: 0.4;
+  cursor: not-allowed;
+}
+
+.sequenceButton {
+  cursor: pointer;
+  padding: 0px 5px;
+  color: {{.ColorHeader}};
+}
+
+.sequenceButton:hover {
+  color: {{.ColorCodeHover}};
+}
+
+.editBox {
+  display: none;
+  flex-direction: column;
+  position: fixed;
+  top: 0;
+  left: 0;
+  width: 100%;
+  height: 100%;
+  z-index: 90;
+  padding: 1em;
+  box-sizing: border-box;
+  background-color: {{.ColorBackground}};
+}
+
+.editHead {
+  display: flex;
+  align-items: center;
+}
+
+.editMessage {
+  flex-grow: 1;
+  margin: 0em 1em;
+}
+
+.editStatus {
+  min-height: 1.5em;
+  padding: 0.5em 0em;
+}
+
+.editPanes {
+  display: flex;
+  flex-grow: 1;
+  min-height: 0;
+}
+
+.editText {
+  flex: 1;
+  resize: none;
+  font-family: "Lucida Console", Monaco, monospace;
+}
+
+.editPreview {
+  flex: 1;
+  overflow: auto;
+  padding: 0em 1em;
+}
+
+.editBlocks {
+  font-family: "Lucida Console", Monaco, monospace;
+  font-size: 0.8em;
+}
+
+.editBlocks .same {
+  color: gray;
+}
+
+.editBlocks .added {
+  color: green;
+}
+
+.editBlocks .removed {
+  color: red;
+  text-decoration: line-through;
+}
+
+.editBlocks .changed {
+  color: {{.ColorCodeHover}};
+}
+
+.codeBlockSay {
+  height: 100%;
+  cursor: pointer;
+  opacity: 0.5;
+}
+
+.codeBlockSay:hover {
+  color: {{.ColorCodeHover}};
+  opacity: 1;
+}
+
+.codeBlockLink {
+  cursor: pointer;
+  opacity: 0.5;
+  padding-left: 0.5em;
+}
+.codeBlockLink:hover, .codeBlockLink.linkCopied {
+  opacity: 1;
+}
+.codeBox {
+  scroll-margin-top: calc({{.LayHeaderHeight}}px + 1em);
+}
+.codeBox.linkedBlock {
+  outline: 3px solid {{.ColorCodeHover}};
+}
+{{if not .CanRun}}
+.sequenceButton, .codeBlockSay {
+  display: none;
+}
+{{end}}
+
+.codeBlockSpacer {
+  height: 100%;
+  width: 5px;
+}
+
+.codeBox {
+  padding-top: 10px;
+  padding-left: 20px;
+}
+
+.extractBanner {
+  padding: 0.5em;
+  border: dashed 2px {{.ColorCodeHover}};
+}
+
+.extractOverlay {
+  margin-top: 5px;
+  padding: 0.3em 0.5em;
+  font-family: "Lucida Console", Monaco, monospace;
+  font-size: 0.8em;
+  border-left: solid 4px {{.ColorControls}};
+  background-color: {{.ColorHelpBackground}};
+}
+
+.extractSkipped {
+  border-left-color: {{.ColorCodeHover}};
+}
+
+.extractSkipped + .codeblockBody {
+  opacity: 0.4;
+}
+
+.extractLabel, .extractAttribute {
+  margin-left: 0.5em;
+}
+
+.extractAttribute {
+  font-style: italic;
+}
+
+.extractReasons {
+  margin: 0.2em 0em;
+}
+
+.codeBlockControl {
+  font-family: "Lucida Console", Monaco, monospace;
+  font-weight: bold;
+}
+
+.codeblockBody {
+  white-space: pre;
+  font-family: "Lucida Console", Monaco, monospace;
+  color: {{.ColorCodeBlockText}};
+  background-color: {{.ColorCodeBlockBackground}};
+  margin-top: 5px;
+  padding-left: 10px;
+  overflow-x: auto;
+  border: solid 1px #555;
+  border-radius: 4px;
+  /*            x   y blur spread color             x   y blur spread color */
+  box-shadow: 0px 2px  1px    0px rgba(0,0,0,.3), 2px 0px 1px 0px rgba(0,0,0,.3);
+  min-width: {{.LayMinHeaderWidth}};
+  max-width: calc(100% - 40px);
+}
+
+.proseblock {
+}
+
+.proseblock table {
+  border-collapse: collapse;
+  margin: 1em 0em;
+}
+
+.proseblock th, .proseblock td {
+  border: solid 1px ` + grayIsh + `;
+  padding: 0.3em 0.8em;
+}
+
+.proseblock th {
+  text-align: left;
+}
+
+.taskListItem {
+  list-style-type: none;
+}
+
+.taskListItem input {
+  margin: 0em 0.4em 0em -1.4em;
+}
+
+.footnotes {
+  font-size: smaller;
+}
+
+.admonition {
+  margin: 1em 0em;
+  padding: 0.2em 1em;
+  border-left: 4px solid ` + blue700 + `;
+}
+
+.admonitionTitle {
+  font-weight: bold;
+}
+
+.admonitionTip {
+  border-left-color: ` + greenA700 + `;
+}
+
+.admonitionImportant {
+  border-left-color: ` + teal + `;
+}
+
+.admonitionWarning {
+  border-left-color: ` + deepOrange200 + `;
+}
+
+.admonitionCaution {
+  border-left-color: ` + deepOrange700 + `;
+}
+
+.oneLesson {
+  display: none;
+  padding: 0 1em 0 1em;
+  width: '100%';
+  padding-bottom: 1em;
+}
+
+.navBurger {
+  border-radius: 50%;
+
+  justify-content: center;
+  align-items: center;
+  display: flex;
+  flex-direction: column;
+
+  width: 2.8em;
+  height: 2.8em;
+  cursor: pointer;
+}
+
+.navBurger:hover {
+  background-color: {{.ColorHover}};
+  transition: all {{.TransitionSpeedMs}}ms;
+  box-shadow: inset 0 0 0 3px rgba(255,255,255,0.1), 0 1px 2px rgba(0,0,0,0.1);
+}
+
+.burgBar1, .burgBar2, .burgBar3 {
+  width: 28px;
+  height: 4px;
+  /* top rig bot lef */
+  margin: 2px 0 2px 0px;
+  transition: {{.TransitionSpeedMs}}ms;
+  /* offset-x | offset-y | blur-radius | spread-radius | color */
+	box-shadow: 0px 1px 1px 1px rgba(0,0,0,0.4);
+  border: solid 1px #555;
+	background-color: {{.ColorControls}};
+	border-radius:25px;
+}
+
+.burgIsAnX .burgBar1 {
+  transform: translate(0px, 5px) rotate(-45deg);
+}
+.burgIsAnX .burgBar2 {
+  display: none;
+}
+.burgIsAnX .burgBar3 {
+  transform: translate(0px, -5px) rotate(45deg);
+	box-shadow: 0px 0px 0px 0px rgba(0,0,0,0), 2px 0px 1px 0px rgba(0,0,0,0.4);
+}
+`
@@ -0,0 +1,79 @@
+package webapp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/diagram"
+)
+
+func TestNewBranding(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdrip-brand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	css := filepath.Join(dir, "acme.css")
+	ioutil.WriteFile(css, []byte("body { color: teal; }"), 0644)
+	png := filepath.Join(dir, "acme.png")
+	ioutil.WriteFile(png, []byte("png"), 0644)
+
+	if _, err := NewBranding("sepia", "", "", ""); err == nil ||
+		!strings.Contains(err.Error(), `unknown theme "sepia"`) {
+		t.Errorf("got %v", err)
+	}
+	if _, err := NewBranding("", "", "", css); err == nil ||
+		!strings.Contains(err.Error(), "isn't an image") {
+		t.Errorf("got %v", err)
+	}
+	if _, err := NewBranding("", filepath.Join(dir, "missing.css"), "", ""); err == nil {
+		t.Error("want an error reading a missing file")
+	}
+	b, err := NewBranding(ThemeDark, css, "Acme Academy", png)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.CSS != "body { color: teal; }" || b.Logo != "data:image/png;base64,cG5n" {
+		t.Errorf("got %+v", b)
+	}
+	b, err = NewBranding("", "", "", "https://example.com/acme.svg")
+	if err != nil || b.Logo != "https://example.com/acme.svg" {
+		t.Errorf("got %+v, %v", b, err)
+	}
+}
+
+func TestBrand(t *testing.T) {
+	ds, _ := base.NewDataSource("/tmp")
+	wa := NewWebApp(&SessionData{}, "http", "", "", emptyLesson, ds, []int{}, [][]int{{}}, []string{}, diagram.Servers{}, DefaultMessages(), false, false, nil, false, "")
+	var b bytes.Buffer
+	wa.Render(&b)
+	for _, unwanted := range []string{"class='darkTheme'", "brandLogo' src"} {
+		if strings.Contains(b.String(), unwanted) {
+			t.Errorf("unbranded page holds %q", unwanted)
+		}
+	}
+	wa.Brand(Branding{
+		Theme: ThemeDark, CSS: "header { background: teal; }",
+		Title: "Acme Academy", Logo: "data:image/png;base64,cG5n"})
+	b.Reset()
+	wa.Render(&b)
+	got := b.String()
+	for _, want := range []string{
+		"<html lang='en' class='darkTheme'>",
+		"<style type=\"text/css\">header { background: teal; }</style>",
+		"<title id='title'> Acme Academy </title>",
+		"<img class='brandLogo' src='data:image/png;base64,cG5n' alt=''>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("branded page lacks %q", want)
+		}
+	}
+	if strings.Index(got, "teal; }</style>") < strings.Index(got, ".brandLogo {") {
+		t.Error("branding's styles come before the page's own")
+	}
+}
@@ -0,0 +1,120 @@
+package webapp
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/monopole/mdrip/program"
+)
+
+// VariantRun is a run of a lesson's blocks shown together: a
+// lone block, or blocks that are variants of one another, shown
+// as tabs.  Variants are consecutive blocks, with no prose between
+// them, each for other architectures, via @arch, or with other
+// labels, e.g. @mac and @linux, of which a reader wants one.
+type VariantRun struct {
+	// Name is unique to the run on the page, naming its tabs.
+	Name string
+	// First is the run's first block, whose prose precedes it.
+	First *program.BlockPgm
+	// Tabs holds the run's variants; empty if it's a lone block.
+	Tabs []VariantTab
+}
+
+// VariantTab is one of a run's variants.
+type VariantTab struct {
+	// Label says what the variant is for, e.g. arm64 or linux.
+	Label string
+	Block *program.BlockPgm
+}
+
+// variantRuns splits the lesson's blocks into runs, so that
+// pages - served, exported or printed - show every variant,
+// rather than just the one suiting the reader.
+func variantRuns(l *program.LessonPgm) []VariantRun {
+	blocks := l.Blocks()
+	h := fnv.New32a()
+	h.Write([]byte(l.Path()))
+	id := fmt.Sprintf("%x", h.Sum32())
+	var result []VariantRun
+	for i := 0; i < len(blocks); {
+		n := 1
+		for i+n < len(blocks) &&
+			len(strings.TrimSpace(string(blocks[i+n].Prose()))) == 0 {
+			n++
+		}
+		var labels []string
+		for ; n > 1; n-- {
+			if labels = variantLabels(blocks[i : i+n]); labels != nil {
+				break
+			}
+		}
+		run := VariantRun{Name: fmt.Sprintf("variant%s-%d", id, blocks[i].ID()), First: blocks[i]}
+		for j, label := range labels {
+			run.Tabs = append(run.Tabs, VariantTab{label, blocks[i+j]})
+		}
+		result = append(result, run)
+		i += n
+	}
+	return result
+}
+
+// variantLabels returns the labels of the blocks as variants: their
+// architectures, if they all have one, else the labels and tags
+// they don't share.  It returns nil if the blocks aren't variants,
+// i.e. if any label is empty, or two are the same.
+func variantLabels(blocks []*program.BlockPgm) []string {
+	result := make([]string, len(blocks))
+	for i, b := range blocks {
+		result[i] = b.Arch()
+	}
+	if !distinct(result) {
+		count := map[string]int{}
+		for _, b := range blocks {
+			for _, t := range kinds(b) {
+				count[t]++
+			}
+		}
+		for i, b := range blocks {
+			var own []string
+			for _, t := range kinds(b) {
+				if count[t] < len(blocks) {
+					own = append(own, t)
+				}
+			}
+			sort.Strings(own)
+			result[i] = strings.Join(own, ",")
+		}
+	}
+	if !distinct(result) {
+		return nil
+	}
+	return result
+}
+
+// kinds are the block's plain labels, e.g. linux,
+// but not its name, and its tags.
+func kinds(b *program.BlockPgm) []string {
+	var result []string
+	for _, l := range b.Labels() {
+		if !l.IsAttribute() && string(l) != b.Name() {
+			result = append(result, string(l))
+		}
+	}
+	return append(result, b.Tags()...)
+}
+
+// distinct is true if none of the labels is empty
+// and no two are the same.
+func distinct(labels []string) bool {
+	seen := map[string]bool{}
+	for _, x := range labels {
+		if len(x) == 0 || seen[x] {
+			return false
+		}
+		seen[x] = true
+	}
+	return true
+}
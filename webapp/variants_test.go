@@ -0,0 +1,47 @@
+package webapp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+	"github.com/monopole/mdrip/program"
+)
+
+func TestVariantRuns(t *testing.T) {
+	md := model.NewMdContent()
+	for _, b := range []struct {
+		labels []base.Label
+		prose  string
+	}{
+		{[]base.Label{"getAmd", "test", "arch=amd64"}, "Download it."},
+		{[]base.Label{"getArm", "test", "arch=arm64"}, ""},
+		{[]base.Label{"unpack", "test"}, ""},
+		{[]base.Label{"brewIt", "test", "mac"}, "Install tree."},
+		{[]base.Label{"aptIt", "test", "linux"}, ""},
+		{[]base.Label{"first", "test"}, "Then run it."},
+		{[]base.Label{"second", "test"}, ""},
+	} {
+		md.AddBlockParsed(model.NewBlockParsed(
+			b.labels, base.MdProse(b.prose), base.OpaqueCode("echo "+b.labels[0]+"\n")))
+	}
+	v := program.NewLessonPgmExtractor(base.WildCardLabel)
+	model.NewLessonTutFromMdContent(base.FilePath("setup.md"), md).Accept(v)
+	var got []string
+	for _, r := range variantRuns(v.Lessons()[0]) {
+		if len(r.Tabs) == 0 {
+			got = append(got, r.First.Name())
+			continue
+		}
+		var tabs []string
+		for _, x := range r.Tabs {
+			tabs = append(tabs, x.Block.Name()+"="+x.Label)
+		}
+		got = append(got, "["+strings.Join(tabs, " ")+"]")
+	}
+	want := "[getAmd=amd64 getArm=arm64] unpack [brewIt=mac aptIt=linux] first second"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got %q, want %q", strings.Join(got, " "), want)
+	}
+}
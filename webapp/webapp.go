@@ -5,6 +5,7 @@ import (
 	"encoding/gob"
 	"html/template"
 	"io"
+	"strings"
 
 	"bytes"
 
@@ -12,8 +13,10 @@ import (
 
 	"github.com/gorilla/sessions"
 	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/diagram"
 	"github.com/monopole/mdrip/model"
 	"github.com/monopole/mdrip/program"
+	"github.com/monopole/mdrip/schema"
 )
 
 // TypeSessID represents a session ID.
@@ -37,6 +40,12 @@ type SessionData struct {
 	LessonIndex int
 	// The active block.
 	BlockIndex int
+	// Token granting the right to run blocks, if the
+	// server wants one; from a link bearing it.
+	Token string
+	// Lang is the language of the lessons shown, where
+	// they're translated into it; "" for as written.
+	Lang string
 }
 
 // These must all be unique, and preferably short.
@@ -52,6 +61,47 @@ const (
 	KeyLessonIndex = "lix"
 	// KeyBlockIndex is the param name for the block index.
 	KeyBlockIndex = "bix"
+	// KeyBannerOnly is the param name for sending only a block's banner.
+	KeyBannerOnly = "bnr"
+	// KeyScope is the param name for the scope of a block sequence,
+	// either ScopeLesson or ScopeSection.
+	KeyScope = "scp"
+	// KeyTarget is the param name for the name of the tmux target
+	// selected in the UI.
+	KeyTarget = "tgt"
+	// KeyArch is the param name for the browser's architecture.
+	KeyArch = "arch"
+	// KeyTag is the param name for a tag to filter by.
+	KeyTag = "tag"
+	// KeyToken is the param name for a token granting
+	// the right to run blocks.
+	KeyToken = "tok"
+	// KeyLabel is the param name for the label selecting
+	// the blocks of a downloaded script.
+	KeyLabel = "label"
+	// KeySearch is the param name for the words to search for.
+	KeySearch = "q"
+	// KeyEditPath is the param name for the path of an edited lesson.
+	KeyEditPath = "pth"
+	// KeyEditMessage is the param name for the description of an edit.
+	KeyEditMessage = "msg"
+	// KeyPrint is the param name asking for the page as a
+	// handout, to print; see WebApp.Print.
+	KeyPrint = "print"
+	// KeyLang is the param name for the language of the
+	// lessons, where they're translated into it.
+	KeyLang = "lang"
+	// KeyDebug is the param name for a debugging view of the
+	// page, e.g. DebugExtract.
+	KeyDebug = "debug"
+)
+
+// Values for KeyScope.
+const (
+	// ScopeLesson means all the blocks in a lesson.
+	ScopeLesson = "lesson"
+	// ScopeSection means all the blocks in a section of a lesson.
+	ScopeSection = "section"
 )
 
 func makeSessionID() TypeSessID {
@@ -91,13 +141,24 @@ func AssureSessionData(s *sessions.Session) *SessionData {
 		r.BlockIndex = -1
 		s.Values[KeyBlockIndex] = r.BlockIndex
 	}
+	r.Token, _ = s.Values[KeyToken].(string)
 	return r
 }
 
+// NewStaticSessionData returns the session data of an exported
+// page, which has no session to recover it from.  Visitors share
+// its ID, and so, if the page has an endpoint, the output of the
+// blocks they run there.
+func NewStaticSessionData() *SessionData {
+	return &SessionData{SessID: makeSessionID(), IsHeaderOn: true, BlockIndex: -1}
+}
+
 // WebApp presents a tutorial to a web browser.
 type WebApp struct {
 	sessionData *SessionData
+	scheme      string
 	host        string
+	prefix      string
 	tut         model.Tutorial
 	ds          *base.DataSource
 	tmpl        *template.Template
@@ -105,12 +166,39 @@ type WebApp struct {
 	title       string
 	lessonPath  []int
 	coursePaths [][]int
-}
-
-// NewWebApp makes a new web app.
+	targets     []string
+	glossary    model.Glossary
+	msgs        *Messages
+	pages       []PageMeta
+	watch       bool
+	edit        bool
+	extract     *ExtractView
+	// static is true if the page is exported as a file,
+	// rather than served by mdrip; see Static.
+	static   bool
+	endpoint string
+	brand    Branding
+	// print is true if the page is a handout; see Printable.
+	print bool
+}
+
+// NewWebApp makes a new web app, served over the given scheme,
+// e.g. "https", at the given URL path prefix, e.g. "/k8s", or ""
+// if at the root.  The targets are the names of
+// tmux targets the user may choose to send blocks to.  The
+// diagrams are the servers drawing diagrams, if any.
+// The messages are the text of the app's chrome.  If watch is
+// true, the page reloads when the server says the tutorial changed.
+// If edit is true, each lesson offers an editor.  If extract
+// isn't nil, each block is overlaid with its Extraction.  If
+// static is true, the page is to be exported, not served; the
+// endpoint, if not empty, is the URL of the mdrip server it
+// sends blocks to, else it can only copy them.
 func NewWebApp(
-	sessionData *SessionData, host string,
-	tut model.Tutorial, ds *base.DataSource, lp []int, cp [][]int) *WebApp {
+	sessionData *SessionData, scheme, host, prefix string,
+	tut model.Tutorial, ds *base.DataSource, lp []int, cp [][]int,
+	targets []string, diagrams diagram.Servers, msgs *Messages, watch, edit bool,
+	extract *ExtractView, static bool, endpoint string) *WebApp {
 	v := program.NewLessonPgmExtractor(base.WildCardLabel)
 	tut.Accept(v)
 	title := v.FirstTitle()
@@ -118,8 +206,9 @@ func NewWebApp(
 		title = title[maxTitleLength-3:] + "..."
 	}
 	return &WebApp{
-		sessionData, host, tut, ds, makeParsedTemplate(tut),
-		v.Lessons(), title, lp, cp}
+		sessionData, scheme, host, prefix, tut, ds, makeParsedTemplate(tut, diagrams, msgs, extract),
+		v.Lessons(), title, lp, cp, targets, v.Glossary(), msgs, LessonPages(tut), watch, edit, extract,
+		static, endpoint, Branding{}, false}
 }
 
 // SessID is the id of the session returned
@@ -128,6 +217,67 @@ func (wa *WebApp) SessID() TypeSessID { return wa.sessionData.SessID }
 // Host is the webapp's host.
 func (wa *WebApp) Host() string { return wa.host }
 
+// Watch is true if the page should reload when the tutorial changes.
+func (wa *WebApp) Watch() bool { return wa.watch }
+
+// Edit is true if lessons may be edited.
+func (wa *WebApp) Edit() bool { return wa.edit }
+
+// Static is true if the page is exported, e.g. to GitHub
+// Pages, rather than served by mdrip.  Exported pages search
+// themselves, and keep no session.
+func (wa *WebApp) Static() bool { return wa.static }
+
+// API is the URL, or path on the page's host, of the server the
+// page asks to run blocks: its own, or, if it's static, its endpoint.
+func (wa *WebApp) API() string {
+	if wa.static {
+		return wa.endpoint
+	}
+	return wa.prefix
+}
+
+// CanRun is true if the page has a server to run blocks;
+// otherwise clicking a block only copies it.
+func (wa *WebApp) CanRun() bool { return !wa.static || len(wa.endpoint) > 0 }
+
+// Extract is the extraction overlay's view, or nil if it's off.
+func (wa *WebApp) Extract() *ExtractView { return wa.extract }
+
+// PagePaths are the paths of the lessons' pages, e.g.
+// setup/install, in lesson order.
+func (wa *WebApp) PagePaths() []string {
+	result := make([]string, len(wa.pages))
+	for i, p := range wa.pages {
+		result[i] = p.Path
+	}
+	return result
+}
+
+// Prefix is the URL path the webapp is served under, e.g. "/k8s",
+// or "" if it's served at the root.
+func (wa *WebApp) Prefix() string { return wa.prefix }
+
+// SiteURL is the absolute URL of the app, e.g.
+// "https://example.com/k8s/".
+func (wa *WebApp) SiteURL() string {
+	return wa.scheme + "://" + wa.host + wa.prefix + "/"
+}
+
+// Page describes the page of the lesson the user starts at.
+func (wa *WebApp) Page() PageMeta {
+	if i := wa.InitialLesson(); i < len(wa.pages) {
+		return wa.pages[i]
+	}
+	return PageMeta{Title: wa.title}
+}
+
+// CanonicalURL is the one URL search engines should know
+// the page by, whichever path the user took to it.
+func (wa *WebApp) CanonicalURL() string {
+	return wa.SiteURL() + wa.Page().Path
+}
+
 // Lessons is the list of lessons known to the webapp.
 func (wa *WebApp) Lessons() []*program.LessonPgm {
 	return wa.rawLessons
@@ -294,9 +444,66 @@ func (wa *WebApp) KeyIsHeaderOn() string { return KeyIsHeaderOn }
 // KeyIsNavOn delivers the corresponding const to a template.
 func (wa *WebApp) KeyIsNavOn() string { return KeyIsNavOn }
 
+// KeyBannerOnly delivers the corresponding const to a template.
+func (wa *WebApp) KeyBannerOnly() string { return KeyBannerOnly }
+
+// KeyScope delivers the corresponding const to a template.
+func (wa *WebApp) KeyScope() string { return KeyScope }
+
+// KeyTarget delivers the corresponding const to a template.
+func (wa *WebApp) KeyTarget() string { return KeyTarget }
+
+// KeyArch delivers the corresponding const to a template.
+func (wa *WebApp) KeyArch() string { return KeyArch }
+
+// Glossary of terms defined for the tutorial's courses.
+func (wa *WebApp) Glossary() model.Glossary { return wa.glossary }
+
+// Targets are the names of tmux targets offered in the header.
+func (wa *WebApp) Targets() []string { return wa.targets }
+
+// Tags are the distinct tags of all the lessons and blocks, sorted.
+func (wa *WebApp) Tags() []string {
+	lists := [][]string{}
+	for _, l := range wa.rawLessons {
+		lists = append(lists, l.Tags())
+	}
+	return base.MergeTags(lists...)
+}
+
+// Lang is the language of the app's chrome.
+func (wa *WebApp) Lang() string { return wa.msgs.Lang() }
+
+// LessonLangs are the languages lessons are translated into,
+// offered in the header if the page is served by mdrip.
+func (wa *WebApp) LessonLangs() []string { return model.Langs(wa.tut) }
+
+// LessonLang is the language of the lessons shown, where
+// they're translated into it.
+func (wa *WebApp) LessonLang() string { return wa.sessionData.Lang }
+
+// KeyLang delivers the corresponding const to a template.
+func (wa *WebApp) KeyLang() string { return KeyLang }
+
+// KeySearch delivers the corresponding const to a template.
+func (wa *WebApp) KeySearch() string { return KeySearch }
+
+// KeyEditPath delivers the corresponding const to a template.
+func (wa *WebApp) KeyEditPath() string { return KeyEditPath }
+
+// KeyEditMessage delivers the corresponding const to a template.
+func (wa *WebApp) KeyEditMessage() string { return KeyEditMessage }
+
 // KeySessID delivers the corresponding const to a template.
 func (wa *WebApp) KeySessID() string { return KeySessID }
 
+// KeyToken is the param name for a token granting the right to run blocks.
+func (wa *WebApp) KeyToken() string { return KeyToken }
+
+// Token is the session's token granting the right
+// to run blocks, if the server wants one.
+func (wa *WebApp) Token() string { return wa.sessionData.Token }
+
 // LessonCount is just that.
 func (wa *WebApp) LessonCount() int {
 	c := model.NewTutorialLessonCounter()
@@ -304,14 +511,49 @@ func (wa *WebApp) LessonCount() int {
 	return c.Count()
 }
 
+// HasToc is true if some lesson has headings enough
+// for a table of contents, shown in the right margin.
+func (wa *WebApp) HasToc() bool {
+	for _, l := range wa.rawLessons {
+		if len(l.Headings()) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// Anchors maps the old ids of renamed headings, in
+// all lessons, to their ids now.
+func (wa *WebApp) Anchors() map[string]string {
+	result := map[string]string{}
+	for _, l := range wa.rawLessons {
+		for old, id := range l.Anchors() {
+			result[old] = id
+		}
+	}
+	return result
+}
+
 // Render writes a web page to the given writer.
 func (wa *WebApp) Render(w io.Writer) error {
 	return wa.tmpl.ExecuteTemplate(w, tmplNameWebApp, wa)
 }
 
-func makeParsedTemplate(tut model.Tutorial) *template.Template {
+func makeParsedTemplate(
+	tut model.Tutorial, diagrams diagram.Servers, msgs *Messages,
+	extract *ExtractView) *template.Template {
 	return template.Must(
-		template.New("main").Parse(
+		template.New("main").Funcs(template.FuncMap{
+			"diagrams": func(h template.HTML) template.HTML {
+				return template.HTML(diagram.Render(string(h), diagrams))
+			},
+			"msg":        msgs.Get,
+			"extraction": extract.extract,
+			"variants":   variantRuns,
+			"join": func(tags []string) string {
+				return strings.Join(tags, ",")
+			},
+		}).Parse(
 			tmplBodyLesson +
 				tmplBodyBlockPgm +
 				tmplBodyLessonList +
@@ -332,10 +574,29 @@ func makeLeftNavBody(tut model.Tutorial) string {
 func makeAppTemplate(htmlNavActual string) string {
 	return `
 {{define "` + tmplNameWebApp + `"}}
-<html>
+<html lang='{{.Lang}}'{{if .DarkTheme}} class='darkTheme'{{end}}>
 <head>
+{{if not .Static}}
+<link rel="alternate" type="application/atom+xml" href="{{.Prefix}}/_/feed">
+{{end}}
+<link rel="canonical" href="{{.CanonicalURL}}">
+{{with .Page}}
+<meta name="description" content="{{.Description}}">
+<meta property="og:type" content="article">
+<meta property="og:site_name" content="{{$.DocTitle}}">
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:description" content="{{.Description}}">
+<meta property="og:url" content="{{$.CanonicalURL}}">
+<meta name="twitter:card" content="summary">
+<meta name="twitter:title" content="{{.Title}}">
+<meta name="twitter:description" content="{{.Description}}">
+{{end}}
 <style type="text/css">` + cssInHeader + `
+{{if .Printable}}` + cssPrintView + `{{end}}
 </style>
+{{with .CustomCSS}}
+<style type="text/css">{{.}}</style>
+{{end}}
 <script type="text/javascript">` + jsInHeader + `
 </script>
 </head>
@@ -350,19 +611,89 @@ func makeAppTemplate(htmlNavActual string) string {
       </div>
     </div>
     <div class='headerColumn'>
+      {{with .Logo}}<img class='brandLogo' src='{{.}}' alt=''>{{end}}
       <title id='title'> {{.DocTitle}} </title>
       <div class='activeLessonName'> Droplet Formation Rates </div>
       ` + htmlLessonNavRow + `
+      {{if .Glossary}}
+      <div class='glossaryRow'>
+        <a href='{{.Prefix}}/_/glossary' target='_blank'> {{msg "glossary"}} </a>
+      </div>
+      {{end}}
+      {{if .Targets}}
+      <div class='targetRow'> {{msg "sendTo"}}
+        <select id='targetSelect'>
+          <option value=''> {{msg "currentPane"}} </option>
+          {{range .Targets}}<option value='{{.}}'> {{.}} </option>{{end}}
+        </select>
+      </div>
+      {{end}}
+      {{if .CanRun}}
+      <div class='deliveryBanner'> {{msg "noTmux"}} </div>
+      {{end}}
+      {{if .Tags}}
+      <div class='tagRow'> {{msg "tags"}}
+        <select id='tagSelect' onchange='tagController.choose(this.value)'>
+          <option value=''> {{msg "allTags"}} </option>
+          {{range .Tags}}<option value='{{.}}'> {{.}} </option>{{end}}
+        </select>
+      </div>
+      {{end}}
+      {{if and .LessonLangs (not .Static)}}
+      <div class='langRow'> {{msg "lessonLang"}}
+        <select id='langSelect' onchange='langController.choose(this.value)'>
+          <option value=''> {{msg "originalLang"}} </option>
+          {{range .LessonLangs}}<option value='{{.}}'{{if eq . $.LessonLang}} selected{{end}}> {{.}} </option>{{end}}
+        </select>
+      </div>
+      {{end}}
     </div>
     <div class='navButtonBox'> &nbsp; </div>
   </header>
 
   <div class='navLeftBox navLeftBoxShadow' tabindex='-1'>
+    <div class='searchRow'>
+      <input id='searchInput' type='search' placeholder='{{msg "search"}}'
+          data-none='{{msg "noMatches"}}' oninput='searchController.query(this.value)'>
+      <div id='searchResults'></div>
+    </div>
     <nav class='navActual'>
       ` + htmlNavActual + `
     </nav>
   </div>
 
+  <div class='lightbox' onclick='lightboxController.hide()'>
+    <img class='lightboxImage' alt=''>
+  </div>
+
+  <div class='paletteBox' onclick='paletteController.hide()'>
+    <div class='palette' onclick='event.stopPropagation()'>
+      <input class='paletteInput' type='text' placeholder='{{msg "palettePrompt"}}'
+          oninput='paletteController.filter()' onkeydown='paletteController.key(event)'>
+      <div class='paletteItems'></div>
+    </div>
+  </div>
+
+  {{if .Edit}}
+  <div class='editBox'>
+    <div class='editHead'>
+      <code class='editPath'></code>
+      <input class='editMessage' type='text' placeholder='{{msg "editMessage"}}'>
+      <span class='sequenceButton' onclick='editController.propose()'> {{msg "propose"}} </span>
+      <span class='sequenceButton' onclick='editController.hide()'> {{msg "close"}} </span>
+    </div>
+    <div class='editStatus' data-proposed='{{msg "proposedOn"}}'
+        data-pull='{{msg "openPullRequest"}}'></div>
+    <div class='editPanes'>
+      <textarea class='editText' oninput='editController.changed()'></textarea>
+      <div class='editPreview'>
+        <ul class='editBlocks'></ul>
+        <div class='editRendered'></div>
+      </div>
+    </div>
+  </div>
+  {{end}}
+
   <div class='helpBox'>
     <div class='helpActual'>
     ` + htmlHelp + `
@@ -371,7 +702,18 @@ func makeAppTemplate(htmlNavActual string) string {
 
   <div class='navRightBox navRightBoxShadow'>
     <nav class='navActual'>
-      <!-- <p> T O C </p> <p> COMING </p> <p> HERE </p> -->
+      {{range $i, $c := .Lessons}}
+      {{if gt (len $c.Headings) 1}}
+      <div class='lessonToc' id='TL{{$i}}'>
+        <div class='tocTitle'> {{msg "toc"}} </div>
+        <ul>
+        {{range $c.Headings}}
+          <li class='tocLevel{{.Level}}'><a href='#{{.ID}}' data-heading='{{.ID}}'> {{.Text}} </a></li>
+        {{end}}
+        </ul>
+      </div>
+      {{end}}
+      {{end}}
     </nav>
   </div>
 
@@ -380,6 +722,15 @@ func makeAppTemplate(htmlNavActual string) string {
     <div class='proseRow'>
       <div class='navLeftSpacer'> &nbsp; </div>
       <div class='proseColumn'>
+        {{if .Printable}}
+        <h1 class='printTitle'> {{.DocTitle}} </h1>
+        {{end}}
+        {{with .Extract}}
+        <div class='extractBanner'>
+          {{msg "extractView"}}
+          <code>--label {{if .LabelShown}}{{.LabelShown}}{{else}}*{{end}}{{if .Arch}} --arch {{.Arch}}{{end}}</code>
+        </div>
+        {{end}}
 					{{ template "` + tmplNameLessonList + `" .Lessons }}
       </div>
       <div class='navRightSpacer'> &nbsp; </div>
@@ -401,7 +752,7 @@ const (
 	tmplBodyLessonList = `
 {{define "` + tmplNameLessonList + `"}}
 {{range $i, $c := .}}
-  <div class='oneLesson' id='BL{{$i}}' data-id='{{$i}}' >
+  <div class='oneLesson' id='BL{{$i}}' data-id='{{$i}}'{{if $c.Tags}} data-tags='{{join $c.Tags}}'{{end}} >
   {{ template "` + tmplNameLesson + `" $c }}
   </div>
 {{end}}
@@ -410,26 +761,110 @@ const (
 	tmplNameLesson = "oneLesson"
 	tmplBodyLesson = `
 {{define "` + tmplNameLesson + `"}}
-{{range $i, $c := .Blocks}}
+<div class='lessonControl'>
+  <span class='sequenceButton'
+      onclick='codeBlockController.runSequence("` + ScopeLesson + `", 0)'> {{msg "runLesson"}} </span>
+  <span class='sequenceButton'
+      onclick='codeBlockController.cancelSequence()'> {{msg "cancel"}} </span>
+</div>
+{{if .Author}}
+<div class='lessonAuthor'> {{msg "author"}} {{.Author}} </div>
+{{end}}
+{{with .Revision}}
+<div class='lessonRevision' title='{{.When}}'> {{msg "lastChanged" .Date .Author}} </div>
+{{end}}
+{{if .Prerequisites}}
+<div class='prereqs'>
+  <div class='prereqTitle'> {{msg "prereqTitle"}} </div>
+  <ul>
+  {{range .Prerequisites}}
+    {{if .Found}}
+    <li class='prereq' data-lesson='{{.Index}}'>
+      <span class='prereqCheck'></span>
+      <span class='prereqLink' onclick='lessonController.jump({{.Index}})'> {{.Name}} </span>
+    </li>
+    {{else}}
+    <li class='prereqMissing'> {{.Name}} {{msg "prereqMissing"}} </li>
+    {{end}}
+  {{end}}
+  </ul>
+  <div class='prereqWarning'>
+    {{msg "prereqWarning"}}
+  </div>
+</div>
+{{end}}
+{{range $r := variants .}}
   <div class="commandBlockBody">
-  {{ template "` + tmplNameBlockPgm + `" $c }}
+  {{if $r.Tabs}}
+  <div class='proseblock'> {{diagrams $r.First.HTMLProse}} </div>
+  <div class='variants'>
+  {{range $j, $t := $r.Tabs}}
+    <input type='radio' class='variantRadio' name='{{$r.Name}}' id='{{$r.Name}}:{{$j}}'
+        value='{{$t.Label}}'{{if eq $j 0}} checked{{end}}
+        onchange='variantController.choose(this.value)'>
+    <label class='variantTab' for='{{$r.Name}}:{{$j}}'> {{$t.Label}} </label>
+    <div class='variantPanel' data-variant='{{$t.Label}}'>
+    {{ template "` + tmplNameBlockCode + `" $t.Block }}
+    </div>
+  {{end}}
+  </div>
+  {{else}}
+  {{ template "` + tmplNameBlockPgm + `" $r.First }}
+  {{end}}
   </div>
 {{end}}
+<div class='lessonSummary'></div>
 {{end}}
 `
-	tmplNameBlockPgm = "blockPgm"
-	tmplBodyBlockPgm = `
+	tmplNameBlockPgm  = "blockPgm"
+	tmplNameBlockCode = "blockCode"
+	tmplBodyBlockPgm  = `
 {{define "` + tmplNameBlockPgm + `"}}
-<div class='proseblock'> {{.HTMLProse}} </div>
+<div class='proseblock'> {{diagrams .HTMLProse}} </div>
+{{ template "` + tmplNameBlockCode + `" . }}
+{{end}}
+{{define "` + tmplNameBlockCode + `"}}
 {{if .Code}}
-<div class='codeBox' data-id='{{.ID}}'>
+<div class='codeBox'{{with .Anchor}} id='{{.}}'{{end}} data-id='{{.ID}}'{{if .Arch}} data-arch='{{.Arch}}'{{end}}{{if .Tags}} data-tags='{{join .Tags}}'{{end}}>
   <div class='codeBlockControl'>
     <span class='codePrompt'> &nbsp;&gt;&nbsp; </span>
     <span class='codeBlockButton' onclick='codeBlockController.setAndRun({{.ID}})'>
       {{.Name}}
     </span>
+    <span class='codeBlockSay' title='{{msg "sayTitle" .Banner}}'
+        onclick='codeBlockController.say({{.ID}})'> # </span>
+    {{if .Anchor}}
+    <span class='codeBlockLink' title='{{msg "copyLinkTitle"}}'
+        onclick='codeBlockController.copyLink(this)'> &#x1f517; </span>
+    {{end}}
+    {{if .Target}}
+    <span class='codeBlockTarget' title='{{msg "targetTitle"}}'> {{.Target}} </span>
+    {{end}}
+    {{if .Arch}}
+    <span class='codeBlockArch' title='{{msg "archTitle"}}'
+        onclick='archController.toggle(this.parentNode.parentNode)'> {{.Arch}} </span>
+    {{end}}
+    {{if .Tags}}
+    <span class='codeBlockTags' title='{{msg "tagsTitle"}}'> {{join .Tags}} </span>
+    {{end}}
+    {{if .StartsSection}}
+    <span class='sequenceButton' title='{{msg "runSectionTitle"}}'
+        onclick='codeBlockController.runSequence("` + ScopeSection + `", {{.ID}})'> {{msg "runSection"}} </span>
+    {{end}}
     <span class='codeBlockSpacer'> &nbsp; </span>
   </div>
+{{with extraction .}}
+<div class='extractOverlay{{if not .Selected}} extractSkipped{{end}}'>
+  <div class='extractVerdict'>
+    #{{.ID}} {{if .Selected}}{{msg "extractSelected"}}{{else}}{{msg "extractSkipped"}}{{end}}
+    {{range .Labels}}<span class='extractLabel'>@{{.}}</span>{{end}}
+    {{range .Attributes}}<span class='extractAttribute'>@{{.}}</span>{{end}}
+  </div>
+  <ul class='extractReasons'>
+  {{range .Reasons}}<li> {{.}} </li>{{end}}
+  </ul>
+</div>
+{{end}}
 <div class='codeblockBody'>
 {{ .Code }}
 </div>
@@ -461,44 +896,48 @@ const htmlLessonNavRow = `
 
 const htmlHelp = `
 <p>
-Snapshot of markdown from
+{{msg "snapshotOf"}}
 <a target='_blank' href='{{.DataSourceLink}}'><code>{{.DataSourceName}}</code></a>.
 
-<h3>Keys</h3>
+<h3>{{msg "keys"}}</h3>
 <p>
 <table>
   <tr>
-     <td class='kind'> help </td>
+     <td class='kind'> {{msg "keyHelp"}} </td>
      <td> ? &nbsp; / </td>
   </tr>
   <tr>
-    <td class='kind'> activate (previous, next) code block </td>
+    <td class='kind'> {{msg "keyActivate"}} </td>
     <td> w, s &nbsp; j, k </td>
   </tr>
   <tr>
-    <td class='kind'> scroll to active code block </td>
+    <td class='kind'> {{msg "keyScroll"}} </td>
     <td> x </td>
   </tr>
   <tr>
-     <td class='kind'> copy/execute activated block </td>
+     <td class='kind'> {{msg "keyCopy"}} </td>
      <td> &crarr; </td>
   </tr>
   <tr>
-    <td class='kind'> (previous, next) lesson </td>
+    <td class='kind'> {{msg "keyLesson"}} </td>
     <td> a, d &nbsp; h, l &nbsp; &larr;, &rarr; </td>
   </tr>
   <tr>
-    <td class='kind'> minimize header </td>
+    <td class='kind'> {{msg "keyHeader"}} </td>
     <td> - </td>
   </tr>
   <tr>
-    <td class='kind'> nav sidebar </td>
+    <td class='kind'> {{msg "keyNav"}} </td>
     <td> n </td>
   </tr>
   <tr>
-    <td class='kind'> monkey </td>
+    <td class='kind'> {{msg "keyMonkey"}} </td>
     <td> ! </td>
   </tr>
+  <tr>
+    <td class='kind'> {{msg "keyPalette"}} </td>
+    <td> ctrl-k </td>
+  </tr>
 </table>
 </p>
 
@@ -524,6 +963,7 @@ also pastes the block to the active tmux pane for
 immediate execution.
 </p>
 
+{{if not .Static}}
 <h3> Remote server tmux </h3>
 <p> <em>Proof of concept
 for using tmux over a websocket to remote servers.
@@ -543,7 +983,7 @@ href="https://github.com/tmux/tmux/wiki">tmux</a>.
 <li>In some non-tmux shell, run mdrip in <em>tmux</em> mode with a session arg:
 <pre>
   mdrip --mode tmux \
-    ws://{{.Host}}/_/ws?{{.KeySessID}}={{.SessID}}
+    'ws://{{.Host}}{{.Prefix}}/_/ws?{{.KeySessID}}={{.SessID}}{{if .Token}}&{{.KeyToken}}={{.Token}}{{end}}'
 </pre>
 </li>
 </ul>
@@ -555,376 +995,7 @@ to the active <code>tmux</code> pane.<p>
 <p>
 The <code>mdrip</code> service self-exits after a period of inactivity,
 and can be restarted with the same command.</p>
-`
-
-const cssInHeader = `
-body {
-  padding: 0;
-  margin: 0;
-  background-color: darkgray;
-  /* font-family: "Roboto", sans-serif; */
-  /* font-family: "Veranda", Veranda, sans-serif; */
-  font-family: Verdana, Geneva, sans-serif;
-  position: relative;
-  font-size: 12pt;
-  line-height: 1.4;
-  -webkit-font-smoothing: antialiased;
-  width: 100%;
-}
-
-td.kind {
-  text-align: right;
-  padding-right: 2em;
-}
-
-header, .headSpacer {
-  height: {{.LayHeaderHeight}}px;
-  width: inherit;
-  transition: height {{.TransitionSpeedMs}}ms;
-}
-
-header {
-  position: fixed;
-  top: 0;
-  background: {{.ColorHeader}};
-  /* background: linear-gradient(0deg, {{.ColorBackground}}, {{.ColorHeader}}); */
-  display: flex;
-  justify-content: space-around; /* space-between */
-  flex-direction: row;
-  flex-wrap: nowrap;
-  align-items: center;
-  transition: height {{.TransitionSpeedMs}}ms;
-  box-shadow: 0 2px 2px 2px rgba(0,0,0,.4);
-}
-
-.navLeftBox, .navRightBox {
-  position: fixed;
-  top: calc({{.LayHeaderHeight}}px + 2px);  /* leave room for header drop-shadow */
-  height: calc(100vh - ({{.LayFooterHeight}}px + {{.LayHeaderHeight}}px + 4px));
-  background-color: {{.ColorNavBackground}};
-  color: {{.ColorNavText}};
-  display: inline-block;
-  overflow: hidden;  /* initially hideNav */
-  width: 0px;  /* initially hideNav */
-  min-width: 0px;  /* initially hideNav */
-  transition: width {{.TransitionSpeedMs}}ms, min-width {{.TransitionSpeedMs}}ms;
-}
-.navRightBoxShadow {
-  /* shadow on bottom, top and left */
-  box-shadow: 0 2px 2px 2px rgba(0,0,0,.2), -2px 0px 2px 2px rgba(0,0,0,.2);
-}
-.navLeftBoxShadow {
-  /* shadow on bottom, top and right */
-  box-shadow: 0 2px 2px 2px rgba(0,0,0,.2), 2px 0px 2px 2px rgba(0,0,0,.2);
-}
-.navActual {
-  padding-left: 1em;
-}
-
-a {
-  text-decoration: none;
-}
-a:hover, a:visited, a:link, a:active {
-  text-decoration: none;
-}
-
-.navCourseTitle {
-  padding: 0px;
-}
-
-.navCourseTitle:hover {
-  color: {{.ColorHover}};
-  font-weight: bold;
-}
-
-.navItemTop {
-  /* top rig bot lef */
-  padding: {{.LayNavTopBotPad}}px 0px {{.LayNavTopBotPad}}px 4px;
-}
-
-.navItemBox {
-  /* top rig bot lef */
-  padding: {{.LayNavTopBotPad}}px 0px {{.LayNavTopBotPad}}px {{.LayNavLeftPad}}px;
-}
-
-.navCourseContent {
-  /* top rig bot lef */
-  padding: {{.LayNavTopBotPad}}px 0px 0px 0px;
-}
-
-.navLessonTitleOn {
-  background-color: {{.ColorNavSelected}};
-}
-
-.navLessonTitleOff {
-}
-
-.navLessonTitleOff:hover {
-  color: {{.ColorHover}};
-  font-weight: bold;
-}
-
-.scrollingColumn {
-  width: inherit;
-}
-
-.navLeftSpacer, .navRightSpacer {
-   width: {{.LayNavBoxWidth}}px;
-   min-width: {{.LayNavBoxWidth}}px;
-   display: none;  /* initially hideNav */
-}
-
-.proseRow {
-  background-color: {{.ColorBackground}};
-  width: inherit;
-  display: flex;
-  flex-direction: row;
-}
-
-footer {
-  background: {{.ColorHeader}};
-  /* background: linear-gradient(0deg, {{.ColorHeader}}, {{.ColorBackground}}); */
-  height: {{.LayFooterHeight}}px;
-}
-
-.helpButtonBox, .navButtonBox {
-  font-size: larger;
-  display: flex;
-  flex-direction: row;
-  justify-content: center;
-  align-items: center;
-  cursor: pointer;
-  color: {{.ColorControls}};
-  font-weight: bold;
-  border-radius: 50%;
-}
-
-.helpButtonBox {
-  width: 2em;
-}
-
-.helpButtonBox:hover {
-  background-color: {{.ColorHover}};
-  transition: all {{.TransitionSpeedMs}}ms;
-  box-shadow: inset 0 0 0 3px rgba(255,255,255,0.1);
-}
-
-.headerColumn {
-  width: 80%;
-  min-width: {{.LayMinHeaderWidth}}px;
-  height: inherit;
-  display: flex;
-  justify-content: center;
-  flex-direction: column;
-  flex-wrap: nowrap;
-  align-items: center;
-}
-
-
-title {
-  font-size: 2em;
-  font-weight: bold;
-  display: flex;
-  flex-direction: column;
-  justify-content: center;
-  align-items: center;
-}
-
-.activeLessonName {
-  font-weight: bold;
-}
-
-.lessonNavRow {
-  height: inherit;
-  display: flex;
-  width: 100%;
-  justify-content: center;
-  flex-direction: row;
-  flex-wrap: nowrap;
-  align-items: center;
-}
-
-.helpBox {
-  position: fixed;
-  top: {{.LayHeaderHeight}}px;
-  left: {{.LayNavBoxWidth}}px;
-  right: {{.LayNavBoxWidth}}px;
-  height: 0px;  /* initially hideHelp */
-  z-index: 3;
-  background-color: {{.ColorHelpBackground}};
-  color: {{.ColorNavText}};
-  transition: height {{.TransitionSpeedMs}}ms;
-  overflow: auto;
-}
-
-.helpActual {
-  padding: 1em;
-}
-
-.proseColumn {
-  display: flex;
-  justify-content: flex-start;
-  flex-direction: column;
-  overflow-x: hidden;
-  overflow-y: auto;
-  align-items: flex-start;
-  transition: width {{.TransitionSpeedMs}}ms;
-}
-
-.proseActual {
-  /* top right bottom left */
-  padding: 0 1em 0 1em;
-}
-
-.lessonPrevClickerRow, .lessonNextClickerRow {
-  height: 100%;
-  color: {{.ColorControls}};
-  cursor: pointer;
-  display: flex;
-  flex-basis: 45%;
-  flex-direction: row;
-  flex-wrap: nowrap;
-  align-items: center;
-}
-.lessonPrevClickerRow:hover, .lessonNextClickerRow:hover {
-  color: {{.ColorHover}};
-  font-weight: bold;
-}
-.lessonPrevClickerRow {
-  justify-content: flex-end;
-}
-.lessonNextClickerRow {
-}
-
-.lessonPrevPointer, .lessonNextPointer {
-  /* top right bottom left */
-  padding: 0 1em 0 1em;
-  font-weight: bold;
-  font-size: larger;
-}
-
-.lessonPrevTitle, .lessonNextTitle {
-  font-style: oblique;
-  width: 100%;
-  cursor: pointer;
-  display: inline-block;
-}
-.lessonPrevTitle {
-  text-align: right;
-}
-
-.commandBlockBody {
-  margin: 0px;
-  border: 0px;
-  padding: 0px;
-}
-
-.codeBlockCheckOff {
-  display: inline-block;
-  width: 24px;
-  height: 15px;
-  background-repeat: no-repeat;
-  background-size: contain;
-  background-image: url(data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAABgAAAAWCAMAAADto6y6AAAABGdBTUEAALGPC/xhBQAAAAFzUkdCAK7OHOkAAAAgY0hSTQAAeiYAAICEAAD6AAAAgOgAAHUwAADqYAAAOpgAABdwnLpRPAAAAQtQTFRFAAAAAH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//AH//////BQzC2AAAAFd0Uk5TAAADLy4QZVEHKp8FAUnHbeJ3BAh68IYGC4f4nQyM/LkYCYnXf/rvAm/2/oFY7rcTPuHkOCEky3YjlW4Pqbww0MVTfUZA96p061Xs3mz1e4P70R2aHJYf2KM0AgAAAAFiS0dEWO21xI4AAAAJcEhZcwAAEysAABMrAbkohUIAAADTSURBVCjPbdDZUsJAEAXQXAgJIUDCogHBkbhFEIgCsqmo4MImgij9/39iUT4Qkp63OV0zfbsliTkIhWWOEVHUKOdaTNER9HgiaYQY1xUzlWY8kz04tBjP5Y8KRc6PxUmJcftUnMkIFGCdX1yqjDtX5cp1MChQrVHd3Xn8/y1wc0uNpuejZmt7Ae7aJDreBt1e3wVw/0D06HobYPD0/GI7Q0G10V4i4NV8e/8YE/V8KwImUxJEM82fFM78k4gW3MhfS1p9B3ckobgWBpiChJ/fjc//AJIfFr4X0swAAAAAJXRFWHRkYXRlOmNyZWF0ZQAyMDE2LTA3LTMwVDE0OjI3OjUxLTA3OjAwUzMirAAAACV0RVh0ZGF0ZTptb2RpZnkAMjAxNi0wNy0zMFQxNDoyNzo0NC0wNzowMLz8tSkAAAAZdEVYdFNvZnR3YXJlAHd3dy5pbmtzY2FwZS5vcmeb7jwaAAAAFXRFWHRUaXRsZQBibHVlIENoZWNrIG1hcmsiA8jIAAAAAElFTkSuQmCC);
-}
-
-.codePrompt {
-  background-color: {{.ColorCodeHover}};
-  display: none;
-}
-
-.codeBlockButton {
-  height: 100%;
-  cursor: pointer;
-}
-
-.codeBlockButton:hover {
-  color: {{.ColorCodeHover}};
-}
-
-.codeBlockSpacer {
-  height: 100%;
-  width: 5px;
-}
-
-.codeBox {
-  padding-top: 10px;
-  padding-left: 20px;
-}
-
-.codeBlockControl {
-  font-family: "Lucida Console", Monaco, monospace;
-  font-weight: bold;
-}
-
-.codeblockBody {
-  white-space: pre;
-  font-family: "Lucida Console", Monaco, monospace;
-  color: {{.ColorCodeBlockText}};
-  background-color: {{.ColorCodeBlockBackground}};
-  margin-top: 5px;
-  padding-left: 10px;
-  overflow-x: auto;
-  border: solid 1px #555;
-  border-radius: 4px;
-  /*            x   y blur spread color             x   y blur spread color */
-  box-shadow: 0px 2px  1px    0px rgba(0,0,0,.3), 2px 0px 1px 0px rgba(0,0,0,.3);
-  min-width: {{.LayMinHeaderWidth}};
-  max-width: calc(100% - 40px);
-}
-
-.proseblock {
-}
-
-.oneLesson {
-  display: none;
-  padding: 0 1em 0 1em;
-  width: '100%';
-  padding-bottom: 1em;
-}
-
-.navBurger {
-  border-radius: 50%;
-
-  justify-content: center;
-  align-items: center;
-  display: flex;
-  flex-direction: column;
-
-  width: 2.8em;
-  height: 2.8em;
-  cursor: pointer;
-}
-
-.navBurger:hover {
-  background-color: {{.ColorHover}};
-  transition: all {{.TransitionSpeedMs}}ms;
-  box-shadow: inset 0 0 0 3px rgba(255,255,255,0.1), 0 1px 2px rgba(0,0,0,0.1);
-}
-
-.burgBar1, .burgBar2, .burgBar3 {
-  width: 28px;
-  height: 4px;
-  /* top rig bot lef */
-  margin: 2px 0 2px 0px;
-  transition: {{.TransitionSpeedMs}}ms;
-  /* offset-x | offset-y | blur-radius | spread-radius | color */
-	box-shadow: 0px 1px 1px 1px rgba(0,0,0,0.4);
-  border: solid 1px #555;
-	background-color: {{.ColorControls}};
-	border-radius:25px;
-}
-
-.burgIsAnX .burgBar1 {
-  transform: translate(0px, 5px) rotate(-45deg);
-}
-.burgIsAnX .burgBar2 {
-  display: none;
-}
-.burgIsAnX .burgBar3 {
-  transform: translate(0px, -5px) rotate(45deg);
-	box-shadow: 0px 0px 0px 0px rgba(0,0,0,0), 2px 0px 1px 0px rgba(0,0,0,0.4);
-}
+{{end}}
 `
 
 const jsInHeader = `
@@ -1198,7 +1269,7 @@ var navController = new function() {
     styleSpacerLeft = getElByClass('navLeftSpacer').style;
     styleSpacerRight = getElByClass('navRightSpacer').style;
     styleProseColumn = getElByClass('proseColumn').style;
-    if ({{.LessonCount}} < 2) {
+    if ({{.LessonCount}} < 2 && !{{.HasToc}}) {
       elBurger.style.display = 'none';
     }
     mqWide = window.matchMedia(
@@ -1220,6 +1291,102 @@ var navController = new function() {
   }
 }
 
+// Lists, in the right margin, the headings of the active lesson,
+// linking to them, and highlights that of the section in view.
+var tocController = new function() {
+  var elToc = null;
+  var pending = false;
+  var spy = function() {
+    pending = false;
+    if (elToc == null) {
+      return;
+    }
+    var links = elToc.querySelectorAll('a');
+    var top = parseInt(headerController.height()) + 16;
+    var current = links[0];
+    for (var i = 0; i < links.length; i++) {
+      var el = document.getElementById(links[i].getAttribute('data-heading'));
+      if (el != null && el.getBoundingClientRect().top <= top) {
+        current = links[i];
+      }
+    }
+    for (var i = 0; i < links.length; i++) {
+      links[i].classList.toggle('tocOn', links[i] == current);
+    }
+  }
+  this.show = function(index) {
+    if (elToc != null) {
+      elToc.style.display = 'none';
+    }
+    elToc = document.getElementById('TL' + index);
+    if (elToc != null) {
+      elToc.style.display = 'block';
+    }
+    spy();
+  }
+  this.initialize = function() {
+    window.addEventListener('scroll', function() {
+      if (!pending) {
+        pending = true;
+        window.requestAnimationFrame(spy);
+      }
+    });
+  }
+}
+
+// Follows links to headings and blocks, on loading the page and
+// on clicking links in it, showing the lesson holding the heading
+// or block, and scrolling to it; a block is highlighted, too.  Links to renamed headings, per the anchors
+// in lessons' front matter, go to the heading's id now.
+var anchorController = new function() {
+  var anchors = {};
+  var follow = function(always) {
+    var id = decodeURIComponent(window.location.hash.substring(1));
+    if (id.length == 0) {
+      return;
+    }
+    var el = document.getElementById(id);
+    if (el == null && anchors[id] !== undefined) {
+      el = document.getElementById(anchors[id]);
+      if (el != null && history.replaceState) {
+        history.replaceState(null, '', '#' + anchors[id]);
+      }
+      always = true;
+    }
+    if (el == null) {
+      return;
+    }
+    var elLesson = el.closest('.oneLesson');
+    if (elLesson == null) {
+      return;
+    }
+    var index = parseInt(elLesson.getAttribute('data-id'));
+    // If the lesson is showing, the browser has scrolled to it.
+    if (always || index != lessonController.getActiveLesson()) {
+      lessonController.jump(index);
+      // Stop any smooth scroll to the top of the lesson first.
+      window.scrollTo(0, 0);
+      window.requestAnimationFrame(function() {
+        el.scrollIntoView();
+      });
+    }
+    if (el.classList.contains('codeBox')) {
+      codeBlockController.setCurrent(parseInt(getDataId(el)));
+      el.classList.add('linkedBlock');
+      setTimeout(function() {
+        el.classList.remove('linkedBlock');
+      }, 2000);
+    }
+  }
+  this.initialize = function(a) {
+    anchors = a;
+    window.addEventListener('hashchange', function() {
+      follow(false);
+    });
+    follow(true);
+  }
+}
+
 var helpController = new function() {
   var style = null
   var hideIt = function() {
@@ -1303,6 +1470,14 @@ var codeBlockController = new function() {
   }
   // https://stackoverflow.com/questions/400212
   var attemptCopyToBuffer = function(text) {
+    // Prefer the platform's clipboard API, only offered to pages
+    // served over https or from localhost.
+    if (navigator.clipboard && window.isSecureContext) {
+      navigator.clipboard.writeText(text).catch(function(err) {
+        console.log('Oops, unable to copy: ' + err);
+      });
+      return;
+    }
     var tA = document.createElement("textarea");
     hideIt(tA.style);
     tA.value = text;
@@ -1352,6 +1527,14 @@ var codeBlockController = new function() {
   var goodIndex = function(i) {
     return i >= 0 && i < blocks.length
   }
+  // The tmux target chosen in the header, if any, as a query param.
+  var targetParam = function() {
+    var el = document.getElementById('targetSelect');
+    if (el == null) {
+      return '';
+    }
+    return '&{{.KeyTarget}}=' + encodeURIComponent(el.value);
+  }
   this.deActivateCurrent = function() {
     if (!goodIndex(cbIndex)) {
       return;
@@ -1363,6 +1546,11 @@ var codeBlockController = new function() {
       return;
     }
     prompt().style.display = 'inline-block';
+    // Show the block's tab, if it's a variant.
+    var panel = blocks[cbIndex].closest('.variantPanel');
+    if (panel != null) {
+      panel.previousElementSibling.previousElementSibling.checked = true;
+    }
     blocks[cbIndex].scrollIntoView(
       {behavior: 'smooth', block: 'center', inline: 'nearest'});
   }
@@ -1407,6 +1595,67 @@ var codeBlockController = new function() {
     }
     this.runCurrent();
   }
+  // copyLink copies a link to the block holding the given
+  // element, that scrolls to the block and highlights it.
+  this.copyLink = function(el) {
+    attemptCopyToBuffer(window.location.href.split('#')[0]
+        + '#' + el.closest('.codeBox').id);
+    el.classList.add('linkCopied');
+    setTimeout(function() {
+      el.classList.remove('linkCopied');
+    }, 1000);
+  }
+  this.say = function(id) {
+    var codeBox = blocks[id];
+    var fileId = getDataId(codeBox.closest('.oneLesson'));
+    var xhr = new XMLHttpRequest();
+    xhr.open(
+        'POST',
+        '{{.API}}/_/runblock'
+            + '?{{.KeyLessonIndex}}=' + fileId
+            + '&{{.KeyBlockIndex}}=' + id
+            + '&{{.KeyBannerOnly}}=true'
+            + targetParam()
+            + '&{{.KeySessID}}={{.SessID}}',
+        true);
+    xhr.send();
+  }
+  var postSequence = function(path, scope, id) {
+    var fileId = lessonController.getActiveLesson();
+    var xhr = new XMLHttpRequest();
+    xhr.open(
+        'POST',
+        path
+            + '?{{.KeyLessonIndex}}=' + fileId
+            + '&{{.KeyBlockIndex}}=' + id
+            + '&{{.KeyScope}}=' + scope
+            + '&{{.KeyArch}}=' + encodeURIComponent(archController.arch())
+            + targetParam()
+            + '&{{.KeySessID}}={{.SessID}}',
+        true);
+    xhr.send();
+  }
+  this.runSequence = function(scope, id) {
+    postSequence('{{.API}}/_/runseq', scope, id);
+    window.setTimeout(statusController.poll, 500);
+  }
+  // Show the state of a block sent to tmux: sent, running, ok or failed.
+  this.showState = function(id, state) {
+    if (!goodIndex(id)) {
+      return;
+    }
+    var bar = blocks[id].childNodes[1];
+    var el = bar.querySelector('.codeBlockState');
+    if (el == null) {
+      el = document.createElement('span');
+      bar.appendChild(el);
+    }
+    el.className = 'codeBlockState codeBlockState_' + state;
+    el.title = state;
+  }
+  this.cancelSequence = function() {
+    postSequence('{{.API}}/_/cancelseq', '', -1);
+  }
   this.runCurrent = function() {
     if (!goodIndex(cbIndex)) {
       console.log("cannot run block " + cbIndex);
@@ -1421,19 +1670,29 @@ var codeBlockController = new function() {
     // Fragile, but brief!
     var codeBody = codeBox.childNodes[3].firstChild;
     attemptCopyToBuffer(codeBody.textContent)
-    var fileId = getDataId(codeBox.parentNode.parentNode);
+    if (!{{.CanRun}} || statusController.delivery() == '` + schema.DeliveryClipboard + `') {
+      // Nothing to send it to; copying it is all there is.
+      addCheck(codeBox.childNodes[1]);
+      requestRunning = false;
+      return;
+    }
+    var fileId = getDataId(codeBox.closest('.oneLesson'));
     var xhr = new XMLHttpRequest();
     xhr.onreadystatechange = function() {
       if (xhr.readyState == XMLHttpRequest.DONE) {
-        addCheck(codeBox.childNodes[1])
+        if (xhr.status == 200) {
+          addCheck(codeBox.childNodes[1])
+        }
         requestRunning = false;
+        statusController.poll();
       }
     };
     xhr.open(
         'POST',
-        '/_/runblock'
+        '{{.API}}/_/runblock'
             + '?{{.KeyLessonIndex}}=' + fileId
             + '&{{.KeyBlockIndex}}=' + cbIndex
+            + targetParam()
             + '&{{.KeySessID}}={{.SessID}}',
         true);
     xhr.send();
@@ -1537,7 +1796,7 @@ var lessonController = new function() {
   var updateUrl = function(path) {
     elLessonName.innerHTML = '/' + path
     if (history.pushState) {
-      window.history.pushState("not using data yet", "someTitle", "/" + path);
+      window.history.pushState("not using data yet", "someTitle", '{{.Prefix}}/' + path);
     } else {
       document.location.href = path;
     }
@@ -1578,6 +1837,9 @@ var lessonController = new function() {
     if (!goodIndex(index)) {
       return
     }
+    if (scheduleController.isLocked(index)) {
+      return
+    }
     var prevState = bodyController.isVertScrollBarVisible();
     if (goodIndex(activeIndex)) {
       codeBlockController.deActivateCurrent();
@@ -1591,8 +1853,12 @@ var lessonController = new function() {
       return;
     }
     elLesson.style.display = 'block'
+    diagramController.render(elLesson);
+    mathController.render(elLesson);
     updateHeader(index);
+    tocController.show(index);
     codeBlockController.initLesson(elLesson);
+    statusController.refresh();
     smoothScroll()
     if (prevState != bodyController.isVertScrollBarVisible()) {
       navController.handleWidthChange('whatever');
@@ -1618,6 +1884,46 @@ var lessonController = new function() {
   this.getActiveLesson = function() {
     return activeIndex
   }
+  this.jump = function(index) {
+    this.assureActiveLesson(index)
+    saveSession();
+  }
+  // A lesson is complete when all its blocks have been sent to tmux
+  // and none have failed.
+  var isComplete = function(index, states) {
+    var elLesson = getBodyLesson(index);
+    if (elLesson == null) {
+      return false;
+    }
+    var count = elLesson.querySelectorAll('.codeBox').length;
+    for (var j = 0; j < count; j++) {
+      var s = states[index + '/' + j];
+      if (s != 'ok' && s != 'sent') {
+        return false;
+      }
+    }
+    return true;
+  }
+  // Check off the active lesson's completed prerequisites, warning
+  // if the session has been running blocks but skipped some.
+  this.showPrerequisites = function(states) {
+    var elLesson = getBodyLesson(activeIndex);
+    if (elLesson == null) {
+      return;
+    }
+    var missing = false;
+    var items = elLesson.querySelectorAll('.prereq');
+    for (var i = 0; i < items.length; i++) {
+      var done = isComplete(parseInt(items[i].getAttribute('data-lesson')), states);
+      items[i].className = done ? 'prereq prereqDone' : 'prereq';
+      missing = missing || !done;
+    }
+    var el = elLesson.querySelector('.prereqWarning');
+    if (el != null) {
+      var tracked = Object.keys(states).length > 0;
+      el.style.display = (tracked && missing) ? 'block' : 'none';
+    }
+  }
   this.initialize = function(cp) {
     coursePaths = cp;
     activeIndex = -1;
@@ -1634,10 +1940,1064 @@ var lessonController = new function() {
   }
 }
 
+// Loads a script, and a style sheet if given, calling back when
+// the script is ready.
+function loadAssets(scriptUrl, cssUrl, onload) {
+  if (cssUrl.length > 0) {
+    var l = document.createElement('link');
+    l.rel = 'stylesheet';
+    l.href = cssUrl;
+    document.head.appendChild(l);
+  }
+  var s = document.createElement('script');
+  s.src = scriptUrl;
+  s.onload = onload;
+  document.head.appendChild(s);
+}
+
+// Draws a lesson's mermaid diagrams when it's first shown, since
+// mermaid can't lay out hidden elements.  The mermaid library is
+// loaded only if some lesson needs it.
+var diagramController = new function() {
+  var loading = false;
+  var pending = [];
+  var draw = function() {
+    mermaid.initialize({startOnLoad: false});
+    mermaid.init(undefined, pending);
+    pending = [];
+  }
+  this.render = function(elLesson) {
+    var codes = elLesson.querySelectorAll('code.language-` + diagram.Mermaid + `');
+    for (var i = 0; i < codes.length; i++) {
+      var div = document.createElement('div');
+      div.className = 'mermaid';
+      div.textContent = codes[i].textContent;
+      var pre = codes[i].parentNode;
+      pre.parentNode.replaceChild(div, pre);
+      pending.push(div);
+    }
+    if (pending.length == 0) {
+      return;
+    }
+    if (typeof mermaid != 'undefined') {
+      draw();
+      return;
+    }
+    if (loading) {
+      return;
+    }
+    loading = true;
+    loadAssets('{{.Library "mermaid"}}/mermaid.min.js', '', draw);
+  }
+}
+
+// Typesets a lesson's math when it's first shown, loading
+// the KaTeX library only if some lesson needs it.
+var mathController = new function() {
+  var loading = false;
+  var pending = [];
+  var draw = function() {
+    for (var i = 0; i < pending.length; i++) {
+      katex.render(pending[i].textContent, pending[i], {
+        displayMode: pending[i].classList.contains('display'),
+        throwOnError: false
+      });
+    }
+    pending = [];
+  }
+  this.render = function(elLesson) {
+    var els = elLesson.querySelectorAll('.math');
+    for (var i = 0; i < els.length; i++) {
+      if (els[i].getAttribute('data-typeset') == null) {
+        els[i].setAttribute('data-typeset', 'true');
+        pending.push(els[i]);
+      }
+    }
+    if (pending.length == 0) {
+      return;
+    }
+    if (typeof katex != 'undefined') {
+      draw();
+      return;
+    }
+    if (loading) {
+      return;
+    }
+    loading = true;
+    loadAssets('{{.Library "katex"}}/katex.min.js', '{{.Library "katex"}}/katex.min.css', draw);
+  }
+}
+
+// Dims and collapses code blocks marked with an @arch attribute
+// excluding the browser's architecture, learned from the
+// architecture client hint, else guessed from the user agent.
+// Dims the lessons and blocks lacking the tag chosen in the header.
+// A block has its lesson's tags; a lesson has its blocks' tags.
+var tagController = new function() {
+  var tag = '';
+  var hasTag = function(el) {
+    if (tag == '') {
+      return true;
+    }
+    var tags = el.getAttribute('data-tags');
+    return tags != null && tags.split(',').indexOf(tag) > -1;
+  }
+  var dim = function(selector) {
+    var els = document.querySelectorAll(selector);
+    for (var i = 0; i < els.length; i++) {
+      if (hasTag(els[i])) {
+        els[i].classList.remove('otherTag');
+      } else {
+        els[i].classList.add('otherTag');
+      }
+    }
+  }
+  this.choose = function(t) {
+    tag = t;
+    dim('.codeBox');
+    dim('.navLessonTitleOff, .navLessonTitleOn');
+  }
+}
+
+// Reloads the page, at the same place, with its
+// lessons in the chosen language.
+var langController = new function() {
+  this.choose = function(lang) {
+    var params = new URLSearchParams(window.location.search);
+    params.set('{{.KeyLang}}', lang);
+    window.location.href = window.location.pathname
+        + '?' + params.toString() + window.location.hash;
+  }
+}
+
+var archController = new function() {
+  var arch = '';
+  var aliases = {
+    x86_64: 'amd64', x64: 'amd64', aarch64: 'arm64',
+    i386: '386', i686: '386', x86: '386'};
+  var normalize = function(a) {
+    a = a.trim().toLowerCase();
+    return aliases.hasOwnProperty(a) ? aliases[a] : a;
+  }
+  var guess = function() {
+    var ua = (navigator.platform + ' ' + navigator.userAgent).toLowerCase();
+    if (ua.indexOf('aarch64') > -1 || ua.indexOf('arm64') > -1) {
+      return 'arm64';
+    }
+    if (ua.indexOf('x86_64') > -1 || ua.indexOf('amd64') > -1 ||
+        ua.indexOf('win64') > -1 || ua.indexOf('x64') > -1) {
+      return 'amd64';
+    }
+    return '';
+  }
+  var suits = function(list) {
+    if (arch == '') {
+      return true;
+    }
+    var parts = list.split(',');
+    for (var i = 0; i < parts.length; i++) {
+      if (normalize(parts[i]) == arch) {
+        return true;
+      }
+    }
+    return false;
+  }
+  var render = function() {
+    var els = document.querySelectorAll('.codeBox[data-arch]');
+    for (var i = 0; i < els.length; i++) {
+      // A variant's tab, not its dimming, says whom it's for.
+      if (suits(els[i].getAttribute('data-arch')) || els[i].closest('.variants') != null) {
+        els[i].classList.remove('otherArch');
+      } else {
+        els[i].classList.add('otherArch');
+      }
+    }
+    if (arch != '') {
+      variantController.suggest(arch);
+    }
+  }
+  this.arch = function() {
+    return arch;
+  }
+  // arches are the architectures the page's blocks are for.
+  this.arches = function() {
+    var found = [];
+    var els = document.querySelectorAll('.codeBox[data-arch]');
+    for (var i = 0; i < els.length; i++) {
+      els[i].getAttribute('data-arch').split(',').forEach(function(a) {
+        a = normalize(a);
+        if (a != '' && found.indexOf(a) < 0) {
+          found.push(a);
+        }
+      });
+    }
+    return found.sort();
+  }
+  // choose shows the blocks for a, or, if it's empty, all of them.
+  this.choose = function(a) {
+    arch = normalize(a);
+    render();
+  }
+  this.toggle = function(codeBox) {
+    codeBox.classList.toggle('otherArch');
+  }
+  this.initialize = function() {
+    arch = guess();
+    render();
+    var ua = navigator.userAgentData;
+    if (ua == null || ua.getHighEntropyValues == null) {
+      return;
+    }
+    ua.getHighEntropyValues(['architecture', 'bitness']).then(function(v) {
+      if (v.architecture == 'arm') {
+        arch = (v.bitness == '32') ? 'arm' : 'arm64';
+      } else if (v.architecture == 'x86') {
+        arch = (v.bitness == '32') ? '386' : 'amd64';
+      }
+      render();
+    });
+  }
+}
+
+// Keeps the tabs of all the page's variants on the same choice,
+// e.g. arm64 or linux, remembering it in the browser, so a reader
+// picks theirs once.  Without script, the tabs work one by one.
+var variantController = new function() {
+  var key = 'mdripVariant';
+  var chosen = false;
+  var select = function(label) {
+    var radios = document.querySelectorAll('.variantRadio');
+    for (var i = 0; i < radios.length; i++) {
+      if (radios[i].value == label) {
+        radios[i].checked = true;
+      }
+    }
+  }
+  this.choose = function(label) {
+    chosen = true;
+    select(label);
+    try {
+      localStorage.setItem(key, label);
+    } catch (e) {
+    }
+  }
+  // suggest selects the label's tabs, unless the reader chose.
+  this.suggest = function(label) {
+    if (!chosen) {
+      select(label);
+    }
+  }
+  this.initialize = function() {
+    try {
+      var saved = localStorage.getItem(key);
+      if (saved != null) {
+        chosen = true;
+        select(saved);
+      }
+    } catch (e) {
+    }
+  }
+}
+
+// Shows a zoomable image full size over the page.
+var lightboxController = new function() {
+  var el = null;
+  this.show = function(img) {
+    var big = el.firstElementChild;
+    big.src = img.src;
+    big.alt = img.alt;
+    el.style.display = 'flex';
+  }
+  this.hide = function() {
+    el.style.display = 'none';
+  }
+  this.isVisible = function() {
+    return el.style.display == 'flex';
+  }
+  this.initialize = function() {
+    el = getElByClass('lightbox');
+    document.addEventListener('click', function(event) {
+      var t = event.target;
+      if (t.classList && t.classList.contains('zoomable')) {
+        lightboxController.show(t);
+      }
+    });
+  }
+}
+
+var statusController = new function() {
+  var interval = null;
+  var delivery = '';
+  // How the server gets blocks to a shell; until it says,
+  // assume it can.
+  this.delivery = function() {
+    return delivery;
+  }
+  var showDelivery = function(d) {
+    delivery = d;
+    var el = document.querySelector('.deliveryBanner');
+    if (el != null) {
+      el.style.display = (d == '` + schema.DeliveryClipboard + `') ? 'block' : 'none';
+    }
+  }
+  var render = function(states, seconds) {
+    summaryController.show(states, seconds || {});
+    var lesson = lessonController.getActiveLesson();
+    var busy = false;
+    for (var key in states) {
+      if (states[key] == 'running') {
+        busy = true;
+      }
+      var parts = key.split('/');
+      if (parseInt(parts[0]) == lesson) {
+        codeBlockController.showState(parseInt(parts[1]), states[key]);
+      }
+    }
+    lessonController.showPrerequisites(states);
+    if (!busy && interval != null) {
+      window.clearInterval(interval);
+      interval = null;
+    }
+  }
+  this.refresh = function() {
+    if (!{{.CanRun}}) {
+      return;
+    }
+    var xhr = new XMLHttpRequest();
+    xhr.onreadystatechange = function() {
+      if (xhr.readyState == XMLHttpRequest.DONE && xhr.status == 200) {
+        var doc = JSON.parse(xhr.responseText);
+        showDelivery(doc.delivery);
+        render(doc.blocks, doc.seconds);
+      }
+    };
+    xhr.open('GET', '{{.API}}/_/status?{{.KeySessID}}={{.SessID}}', true);
+    xhr.send();
+  }
+  // Poll for status until no blocks are running.
+  this.poll = function() {
+    statusController.refresh();
+    if (interval == null) {
+      interval = window.setInterval(statusController.refresh, 1000);
+    }
+  }
+}
+
+// Sums up, at the foot of each lesson, the session's runs of its
+// blocks - their states, and how long they took - so participants
+// can confirm they did the lesson right.  The status poll and
+// the results websocket keep it current.
+var summaryController = new function() {
+  var states = {};
+  var seconds = {};
+  var fill = function(text, values) {
+    for (var k in values) {
+      text = text.replace('{' + k + '}', values[k]);
+    }
+    return text;
+  }
+  var render = function(lesson) {
+    var elLesson = document.getElementById('BL' + lesson);
+    if (elLesson == null) {
+      return;
+    }
+    var el = elLesson.querySelector('.lessonSummary');
+    var boxes = elLesson.querySelectorAll('.codeBox');
+    var run = 0, ok = 0, failed = 0, total = 0, timed = false;
+    var list = document.createElement('ul');
+    for (var j = 0; j < boxes.length; j++) {
+      var key = lesson + '/' + boxes[j].getAttribute('data-id');
+      var s = states[key];
+      if (s === undefined) {
+        continue;
+      }
+      run++;
+      if (s == 'ok') {
+        ok++;
+      } else if (s == 'failed') {
+        failed++;
+      }
+      var text = boxes[j].querySelector('.codeBlockButton').textContent.trim() + ': ' + s;
+      if (seconds[key] !== undefined) {
+        total += seconds[key];
+        timed = true;
+        text += ' (' + seconds[key].toFixed(1) + 's)';
+      }
+      var li = document.createElement('li');
+      li.className = 'lessonSummary_' + s;
+      li.textContent = text;
+      list.appendChild(li);
+    }
+    if (run == 0) {
+      el.style.display = 'none';
+      return;
+    }
+    var head = fill('{{msg "summary"}}',
+        {run: run, count: boxes.length, ok: ok, failed: failed});
+    if (timed) {
+      head += '; ' + fill('{{msg "summaryTime"}}', {seconds: total.toFixed(1)});
+    }
+    el.textContent = head;
+    el.appendChild(list);
+    el.style.display = 'block';
+  }
+  var lessonOf = function(key) {
+    return parseInt(key.split('/')[0]);
+  }
+  // show replaces the states and times with the server's.
+  this.show = function(s, secs) {
+    var lessons = {};
+    for (var key in states) {
+      lessons[lessonOf(key)] = true;
+    }
+    states = s;
+    seconds = secs;
+    for (var key in states) {
+      lessons[lessonOf(key)] = true;
+    }
+    for (var i in lessons) {
+      render(i);
+    }
+  }
+  // note records a block's new state, and its time, if it finished.
+  this.note = function(key, state, secs) {
+    states[key] = state;
+    if (secs !== undefined) {
+      seconds[key] = secs;
+    } else {
+      delete seconds[key];
+    }
+    render(lessonOf(key));
+  }
+}
+
+// Shows, under each block sent to local tmux, its output and exit
+// status as pushed by the server over a websocket.
+var resultsController = new function() {
+  var codeBox = function(key) {
+    var parts = key.split('/');
+    var el = document.getElementById('BL' + parts[0]);
+    if (el == null) {
+      return null;
+    }
+    return el.querySelector(".codeBox[data-id='" + parts[1] + "']");
+  }
+  var outputEl = function(box) {
+    var el = box.querySelector('.codeBlockOutput');
+    if (el == null) {
+      el = document.createElement('pre');
+      el.className = 'codeBlockOutput';
+      box.appendChild(el);
+    }
+    return el;
+  }
+  var show = function(doc) {
+    var box = codeBox(doc.block);
+    if (box == null) {
+      return;
+    }
+    if (doc.state) {
+      if (doc.state == 'running') {
+        var old = box.querySelector('.codeBlockOutput');
+        if (old != null) {
+          box.removeChild(old);
+        }
+      }
+      var parts = doc.block.split('/');
+      if (parseInt(parts[0]) == lessonController.getActiveLesson()) {
+        codeBlockController.showState(parseInt(parts[1]), doc.state);
+      }
+      summaryController.note(doc.block, doc.state, doc.seconds);
+      if (doc.exitStatus !== undefined && doc.exitStatus != 0) {
+        var status = document.createElement('span');
+        status.className = 'codeBlockExit';
+        status.textContent = 'exit ' + doc.exitStatus + '\n';
+        outputEl(box).appendChild(status);
+      }
+      return;
+    }
+    var span = document.createElement('span');
+    span.className = 'codeBlockOutput_' + doc.stream;
+    span.textContent = doc.text;
+    outputEl(box).appendChild(span);
+  }
+  this.initialize = function() {
+    if (!window.WebSocket || !{{.CanRun}}) {
+      return;
+    }
+    // The API is a path on this page's host, or, for
+    // an exported page, maybe a URL of another.
+    var api = '{{.API}}';
+    if (api.indexOf('://') < 0) {
+      api = location.protocol + '//' + location.host + api;
+    }
+    var socket = new WebSocket(
+        api.replace(/^http/, 'ws') + '/_/results?{{.KeySessID}}={{.SessID}}');
+    socket.onmessage = function(event) {
+      var doc = JSON.parse(event.data);
+      // A classroom's instructor sent everyone to a lesson.
+      if (doc.kind == 'jump') {
+        lessonController.jump(doc.lesson);
+        return;
+      }
+      // ... or changed which lessons are open.
+      if (doc.kind == 'schedule') {
+        scheduleController.show(doc);
+        return;
+      }
+      show(doc);
+    };
+  }
+}
+
+// Greys out the lessons a classroom's instructor hasn't opened
+// yet, asking the server which, and when they open, now and
+// then; the results socket tells it of changes sooner.
+var scheduleController = new function() {
+  var locked = {};
+  var timer = null;
+  this.isLocked = function(index) {
+    return locked.hasOwnProperty(index);
+  }
+  this.show = function(doc) {
+    locked = {};
+    doc.locked.forEach(function(x) {
+      locked[x.lesson] = x;
+    });
+    for (var i = 0; i < lessonPaths.length; i++) {
+      var els = [
+          document.getElementById('NL' + i), document.getElementById('BL' + i)];
+      var x = locked[i];
+      els.forEach(function(el) {
+        if (el == null) {
+          return;
+        }
+        if (x === undefined) {
+          el.classList.remove('lockedLesson');
+          el.removeAttribute('title');
+          return;
+        }
+        el.classList.add('lockedLesson');
+        el.title = (x.until)
+            ? '{{msg "opensAt"}} ' + new Date(x.until).toLocaleTimeString()
+            : '{{msg "lockedLesson"}}';
+      });
+    }
+  }
+  var fetch = function() {
+    var xhr = new XMLHttpRequest();
+    xhr.onreadystatechange = function() {
+      if (xhr.readyState != XMLHttpRequest.DONE) {
+        return;
+      }
+      if (xhr.status != 200) {
+        // No classroom, so nothing is ever locked.
+        clearInterval(timer);
+        return;
+      }
+      scheduleController.show(JSON.parse(xhr.responseText));
+    };
+    xhr.open('GET', '{{.Prefix}}/_/schedule', true);
+    xhr.send();
+  }
+  this.initialize = function() {
+    if ({{.Static}}) {
+      return;
+    }
+    fetch();
+    timer = setInterval(fetch, 30000);
+  }
+}
+
+// The paths of the lessons' pages, by lesson index.
+var lessonPaths = {{.PagePaths}};
+
+// Edits a lesson's markdown in the browser, previewing the
+// edit as it's typed, and proposing it for review.
+var editController = new function() {
+  var el = null;
+  var lesson = -1;
+  var timer = null;
+  var get = function(n) {
+    return el.getElementsByClassName(n)[0];
+  }
+  var editURL = function(action) {
+    return '{{.Prefix}}/_/' + action + '?{{.KeyEditPath}}='
+        + encodeURIComponent(lessonPaths[lesson]);
+  }
+  var post = function(url, onDone) {
+    var xhr = new XMLHttpRequest();
+    xhr.onreadystatechange = function() {
+      if (xhr.readyState == XMLHttpRequest.DONE) {
+        onDone(xhr);
+      }
+    };
+    xhr.open('POST', url, true);
+    xhr.send(get('editText').value);
+  }
+  var showPreview = function(xhr) {
+    if (xhr.status != 200) {
+      return;
+    }
+    var doc = JSON.parse(xhr.responseText);
+    get('editRendered').innerHTML = doc.html;
+    var list = get('editBlocks');
+    list.innerHTML = '';
+    doc.blocks.forEach(function(b) {
+      var li = document.createElement('li');
+      li.className = b.change;
+      li.textContent = b.name + ' (' + b.change + ')';
+      list.appendChild(li);
+    });
+  }
+  var showProposal = function(xhr) {
+    var status = get('editStatus');
+    if (xhr.status != 200) {
+      status.textContent = xhr.responseText;
+      return;
+    }
+    var doc = JSON.parse(xhr.responseText);
+    status.textContent =
+        status.getAttribute('data-proposed') + ' ' + doc.branch + ' ';
+    if (doc.url) {
+      var a = document.createElement('a');
+      a.href = doc.url;
+      a.target = '_blank';
+      a.textContent = status.getAttribute('data-pull');
+      status.appendChild(a);
+    }
+  }
+  this.open = function(i) {
+    lesson = i;
+    var xhr = new XMLHttpRequest();
+    xhr.onreadystatechange = function() {
+      if (xhr.readyState == XMLHttpRequest.DONE && xhr.status == 200) {
+        get('editPath').textContent = lessonPaths[i] + '.md';
+        get('editStatus').textContent = '';
+        get('editText').value = xhr.responseText;
+        el.style.display = 'flex';
+        get('editText').focus();
+        post(editURL('preview'), showPreview);
+      }
+    };
+    xhr.open('GET', '{{.Prefix}}/raw/' + lessonPaths[i] + '.md', true);
+    xhr.send();
+  }
+  this.changed = function() {
+    clearTimeout(timer);
+    timer = setTimeout(function() {
+      post(editURL('preview'), showPreview);
+    }, 300);
+  }
+  this.propose = function() {
+    post(editURL('propose') + '&{{.KeyEditMessage}}='
+        + encodeURIComponent(get('editMessage').value), showProposal);
+  }
+  this.hide = function() {
+    el.style.display = 'none';
+  }
+  this.initialize = function() {
+    if (!{{.Edit}}) {
+      return;
+    }
+    el = getElByClass('editBox');
+    var controls = document.getElementsByClassName('lessonControl');
+    for (var i = 0; i < controls.length; i++) {
+      var b = document.createElement('span');
+      b.className = 'sequenceButton';
+      b.textContent = '{{msg "edit"}}';
+      b.onclick = (function(n) {
+        return function() { editController.open(n); };
+      })(i);
+      controls[i].appendChild(b);
+    }
+  }
+}
+
+// Searches lessons as words are typed in the nav's search box,
+// listing the matches; clicking one jumps to its lesson, with
+// the words highlighted.
+var searchController = new function() {
+  var elInput = null;
+  var elResults = null;
+  var timer = null;
+  var terms = [];
+  var words = function(q) {
+    return q.toLowerCase().split(/[^\p{L}\p{N}]+/u).filter(function(w) {
+      return w.length > 0;
+    });
+  }
+  var unmark = function() {
+    var marks = document.querySelectorAll('mark.searchMark');
+    for (var i = 0; i < marks.length; i++) {
+      var parent = marks[i].parentNode;
+      parent.replaceChild(document.createTextNode(marks[i].textContent), marks[i]);
+      parent.normalize();
+    }
+  }
+  // Wraps the first use of a term in each text node below el in a mark.
+  var mark = function(el) {
+    var walker = document.createTreeWalker(el, NodeFilter.SHOW_TEXT);
+    var nodes = [];
+    while (walker.nextNode()) {
+      nodes.push(walker.currentNode);
+    }
+    nodes.forEach(function(n) {
+      var text = n.textContent.toLowerCase();
+      for (var i = 0; i < terms.length; i++) {
+        var at = text.indexOf(terms[i]);
+        if (at > -1) {
+          var hit = n.splitText(at);
+          hit.splitText(terms[i].length);
+          var m = document.createElement('mark');
+          m.className = 'searchMark';
+          hit.parentNode.replaceChild(m, hit);
+          m.appendChild(hit);
+          return;
+        }
+      }
+    });
+  }
+  var show = function(doc) {
+    elResults.innerHTML = '';
+    if (doc.hits.length == 0) {
+      elResults.textContent = elInput.getAttribute('data-none');
+      return;
+    }
+    doc.hits.forEach(function(h) {
+      var el = document.createElement('div');
+      el.className = 'searchHit';
+      var title = document.createElement('div');
+      title.className = 'searchHitTitle';
+      title.textContent = h.title;
+      var snippet = document.createElement('div');
+      snippet.className = 'searchHitSnippet';
+      snippet.textContent = h.snippet;
+      el.appendChild(title);
+      el.appendChild(snippet);
+      el.onclick = function() {
+        searchController.go(h.lesson);
+      };
+      elResults.appendChild(el);
+    });
+  }
+  // searchPage finds the words in the lessons of the page
+  // itself, for an exported page, which has no server to ask.
+  var searchPage = function() {
+    var hits = [];
+    var els = document.getElementsByClassName('oneLesson');
+    for (var i = 0; i < els.length; i++) {
+      var text = els[i].textContent.replace(/\s+/g, ' ');
+      var lower = text.toLowerCase();
+      if (!terms.every(function(t) { return lower.indexOf(t) > -1; })) {
+        continue;
+      }
+      var lesson = getDataId(els[i]);
+      var nav = document.getElementById('NL' + lesson);
+      var at = Math.max(0, lower.indexOf(terms[0]) - 40);
+      hits.push({
+        lesson: lesson,
+        title: nav == null ? lessonPaths[lesson] : nav.textContent.trim(),
+        snippet: (at > 0 ? '...' : '') + text.substr(at, 120).trim() + '...'});
+    }
+    return {hits: hits};
+  }
+  var search = function(q) {
+    if ({{.Static}}) {
+      show(searchPage());
+      return;
+    }
+    var xhr = new XMLHttpRequest();
+    xhr.onreadystatechange = function() {
+      if (xhr.readyState == XMLHttpRequest.DONE && xhr.status == 200 &&
+          elInput.value == q) {
+        show(JSON.parse(xhr.responseText));
+      }
+    };
+    xhr.open('GET', '{{.Prefix}}/search?{{.KeySearch}}=' + encodeURIComponent(q), true);
+    xhr.send();
+  }
+  this.query = function(q) {
+    clearTimeout(timer);
+    terms = words(q);
+    if (terms.length == 0) {
+      elResults.innerHTML = '';
+      unmark();
+      return;
+    }
+    timer = setTimeout(function() { search(q); }, 150);
+  }
+  this.go = function(lesson) {
+    unmark();
+    lessonController.jump(lesson);
+    var el = document.getElementById('BL' + lesson);
+    if (el == null) {
+      return;
+    }
+    mark(el);
+    var first = el.querySelector('mark.searchMark');
+    if (first != null) {
+      first.scrollIntoView({block: 'center'});
+    }
+  }
+  this.initialize = function() {
+    elInput = document.getElementById('searchInput');
+    elResults = document.getElementById('searchResults');
+  }
+}
+
+// Switches between the light theme and a dark one, made by
+// inverting the page's colors (but not its images'),
+// remembering the choice in the browser.  Until there's a
+// choice, the page starts in the theme the server says.
+var themeController = new function() {
+  var key = 'mdripTheme';
+  var apply = function(dark) {
+    if (dark) {
+      document.documentElement.classList.add('darkTheme');
+    } else {
+      document.documentElement.classList.remove('darkTheme');
+    }
+  }
+  this.toggle = function() {
+    var dark = !document.documentElement.classList.contains('darkTheme');
+    apply(dark);
+    try {
+      localStorage.setItem(key, dark ? 'dark' : 'light');
+    } catch (e) {
+    }
+  }
+  this.initialize = function() {
+    try {
+      var saved = localStorage.getItem(key);
+      if (saved != null) {
+        apply(saved == 'dark');
+      }
+    } catch (e) {
+    }
+  }
+}
+
+// Opened with Ctrl-K from anywhere, lists the lessons, code blocks
+// and actions matching what's typed, so a big tutorial can be got
+// around without the mouse.  Blocks match by name or content.
+var paletteController = new function() {
+  var el = null;
+  var elInput = null;
+  var elItems = null;
+  var items = [];
+  var shown = [];
+  var chosen = 0;
+  var maxShown = 50;
+  var item = function(kind, text, detail, run) {
+    return {kind: kind, text: text, detail: detail, run: run,
+        hay: (text + ' ' + detail).toLowerCase()};
+  }
+  var actions = function() {
+    var a = [
+      item('{{msg "paletteAction"}}', '{{msg "actionRunBlock"}}', '',
+          function() { codeBlockController.runCurrent(); }),
+      item('{{msg "paletteAction"}}', '{{msg "actionRunLesson"}}', '',
+          function() { codeBlockController.runSequence("` + ScopeLesson + `", 0); }),
+      item('{{msg "paletteAction"}}', '{{msg "actionNav"}}', 'n',
+          function() { navController.toggle(); }),
+      item('{{msg "paletteAction"}}', '{{msg "actionHeader"}}', '-',
+          function() { headerController.toggle(); }),
+      item('{{msg "paletteAction"}}', '{{msg "actionHelp"}}', '?',
+          function() { helpController.toggle(); }),
+      item('{{msg "paletteAction"}}', '{{msg "actionTheme"}}', '',
+          function() { themeController.toggle(); }),
+    ];
+    if ({{.Edit}}) {
+      a.push(item('{{msg "paletteAction"}}', '{{msg "actionEdit"}}', '',
+          function() { editController.open(lessonController.getActiveLesson()); }));
+    }
+    var arches = archController.arches();
+    if (arches.length > 0) {
+      a.push(item('{{msg "paletteAction"}}', '{{msg "actionAnyArch"}}', '',
+          function() { archController.choose(''); }));
+    }
+    arches.forEach(function(arch) {
+      a.push(item('{{msg "paletteAction"}}', '{{msg "actionArch"}}', arch,
+          function() { archController.choose(arch); }));
+    });
+    var elTags = document.getElementById('tagSelect');
+    if (elTags != null) {
+      for (var i = 0; i < elTags.options.length; i++) {
+        (function(opt) {
+          a.push(item('{{msg "paletteAction"}}', '{{msg "actionTag"}}',
+              opt.textContent.trim(), function() {
+                elTags.value = opt.value;
+                tagController.choose(opt.value);
+              }));
+        })(elTags.options[i]);
+      }
+    }
+    var elLangs = document.getElementById('langSelect');
+    if (elLangs != null) {
+      for (var i = 0; i < elLangs.options.length; i++) {
+        (function(opt) {
+          a.push(item('{{msg "paletteAction"}}', '{{msg "actionLang"}}',
+              opt.textContent.trim(), function() {
+                langController.choose(opt.value);
+              }));
+        })(elLangs.options[i]);
+      }
+    }
+    return a;
+  }
+  var lessons = function() {
+    var a = [];
+    for (var i = 0; i < lessonPaths.length; i++) {
+      var nav = document.getElementById('NL' + i);
+      var title = (nav == null) ? lessonPaths[i] : nav.textContent.trim();
+      (function(n) {
+        a.push(item('{{msg "paletteLesson"}}', title, lessonPaths[n],
+            function() { lessonController.jump(n); }));
+      })(i);
+    }
+    return a;
+  }
+  var blocks = function() {
+    var a = [];
+    var els = document.getElementsByClassName('oneLesson');
+    for (var i = 0; i < els.length; i++) {
+      var lesson = getDataId(els[i]);
+      var boxes = els[i].querySelectorAll('.codeBox');
+      for (var j = 0; j < boxes.length; j++) {
+        var name = boxes[j].querySelector('.codeBlockButton').textContent.trim();
+        var code = boxes[j].querySelector('.codeblockBody').textContent.trim();
+        var it = item('{{msg "paletteBlock"}}', name, code.split('\n')[0],
+            (function(l, b) {
+              return function() {
+                lessonController.jump(l);
+                codeBlockController.setCurrent(b);
+              };
+            })(lesson, getDataId(boxes[j])));
+        it.hay = (name + ' ' + code).toLowerCase();
+        a.push(it);
+      }
+    }
+    return a;
+  }
+  var render = function() {
+    elItems.innerHTML = '';
+    shown.forEach(function(it, i) {
+      var row = document.createElement('div');
+      row.className = 'paletteItem' + (i == chosen ? ' paletteChosen' : '');
+      var kind = document.createElement('span');
+      kind.className = 'paletteKind';
+      kind.textContent = it.kind;
+      var detail = document.createElement('span');
+      detail.className = 'paletteDetail';
+      detail.textContent = it.detail;
+      row.appendChild(kind);
+      row.appendChild(document.createTextNode(it.text));
+      row.appendChild(detail);
+      row.onclick = function() {
+        paletteController.run(i);
+      };
+      elItems.appendChild(row);
+    });
+    var c = elItems.children[chosen];
+    if (c != null) {
+      c.scrollIntoView({block: 'nearest'});
+    }
+  }
+  // filter shows the items having all the typed words; with
+  // none typed, the actions and lessons but not the blocks.
+  this.filter = function() {
+    var words = elInput.value.toLowerCase().split(/\s+/).filter(function(w) {
+      return w.length > 0;
+    });
+    shown = items.filter(function(it) {
+      if (words.length == 0) {
+        return it.kind != '{{msg "paletteBlock"}}';
+      }
+      return words.every(function(w) {
+        return it.hay.indexOf(w) > -1;
+      });
+    }).slice(0, maxShown);
+    chosen = 0;
+    render();
+  }
+  this.key = function(event) {
+    switch (event.key) {
+      case 'ArrowDown':
+        chosen = Math.min(chosen + 1, shown.length - 1);
+        render();
+        break;
+      case 'ArrowUp':
+        chosen = Math.max(chosen - 1, 0);
+        render();
+        break;
+      case 'Enter':
+        this.run(chosen);
+        break;
+      case 'Escape':
+        this.hide();
+        break;
+      default:
+        return;
+    }
+    event.preventDefault();
+  }
+  this.run = function(i) {
+    if (i < 0 || i >= shown.length) {
+      return;
+    }
+    this.hide();
+    shown[i].run();
+  }
+  this.isVisible = function() {
+    return el.style.display == 'flex';
+  }
+  this.show = function() {
+    items = actions().concat(lessons(), blocks());
+    el.style.display = 'flex';
+    elInput.value = '';
+    this.filter();
+    elInput.focus();
+  }
+  this.hide = function() {
+    el.style.display = 'none';
+    elInput.blur();
+  }
+  this.toggle = function() {
+    if (this.isVisible()) {
+      this.hide();
+    } else {
+      this.show();
+    }
+  }
+  this.initialize = function() {
+    el = getElByClass('paletteBox');
+    elInput = getElByClass('paletteInput');
+    elItems = getElByClass('paletteItems');
+  }
+}
+
+// Reloads the page when the server, watching the
+// tutorial's files, says they changed.
+var reloadController = new function() {
+  this.initialize = function() {
+    if (!{{.Watch}} || !window.WebSocket) {
+      return;
+    }
+    var scheme = location.protocol == 'https:' ? 'wss://' : 'ws://';
+    var socket = new WebSocket(scheme + location.host + '{{.Prefix}}/_/reloads');
+    socket.onmessage = function(event) {
+      if (event.data == 'reload') {
+        location.reload();
+      }
+    };
+  }
+}
+
 var suppressSessionSave = false
 
 function saveSession() {
-  if (suppressSessionSave) {
+  if (suppressSessionSave || {{.Static}}) {
     return
   }
   var xhr = new XMLHttpRequest();
@@ -1648,7 +3008,7 @@ function saveSession() {
   };
   xhr.open(
       'POST',
-      '/_/s'
+      '{{.Prefix}}/_/s'
           + '?{{.KeyIsHeaderOn}}=' + headerController.IsVisible()
           + '&{{.KeyIsNavOn}}=' + navController.IsVisible()
           + '&{{.KeyLessonIndex}}=' + lessonController.getActiveLesson()
@@ -1694,15 +3054,45 @@ function onLoad() {
   bodyController.initialize();
   helpController.initialize();
   navController.initialize();
+  tocController.initialize();
   lessonController.initialize({{.CoursePaths}});
   codeBlockController.initialize();
+  lightboxController.initialize();
+  variantController.initialize();
+  archController.initialize();
+  resultsController.initialize();
+  scheduleController.initialize();
+  reloadController.initialize();
+  searchController.initialize();
+  editController.initialize();
+  themeController.initialize();
+  paletteController.initialize();
+  anchorController.initialize({{.Anchors}});
+  if ({{.Printable}}) {
+    // Every lesson shows, so draw and typeset them all.
+    var lessons = document.getElementsByClassName('oneLesson');
+    for (var i = 0; i < lessons.length; i++) {
+      diagramController.render(lessons[i]);
+      mathController.render(lessons[i]);
+    }
+  }
   monkeyController.initialize(
       new Array(
           headerController, helpController,
           lessonController, navController, codeBlockController));
   monkeyController.reset();
   window.addEventListener('keydown', function (event) {
-    if (event.defaultPrevented) {
+    if ((event.ctrlKey || event.metaKey) && event.key == 'k') {
+      event.preventDefault();
+      paletteController.toggle();
+      return;
+    }
+    if (event.defaultPrevented || event.target.tagName == 'INPUT' ||
+        event.target.tagName == 'TEXTAREA') {
+      return;
+    }
+    if (lightboxController.isVisible()) {
+      lightboxController.hide();
       return;
     }
     switch (event.key) {
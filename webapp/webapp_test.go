@@ -3,6 +3,7 @@ package webapp
 import (
 	"bytes"
 	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/diagram"
 	"strings"
 	"testing"
 )
@@ -24,11 +25,13 @@ var orderedPageParts = []string{
 	"getElByClass(",
 	"navController =",
 	"helpController =",
+	"paletteController =",
 	"/script>",
 	"/head>",
 	"<body",
 	"<header",
 	"<div class='navLeftBox",
+	"<div class='paletteBox'",
 	"<div class='helpBox'",
 	"<div class='scrollingColumn'",
 	"<div class='proseColumn'",
@@ -41,7 +44,7 @@ var waTests = []waTest{
 
 func TestWebAppBasicTemplateRendered(t *testing.T) {
 	ds, _ := base.NewDataSource("/tmp")
-	wa := NewWebApp(&SessionData{}, "", emptyLesson, ds, []int{}, [][]int{{}})
+	wa := NewWebApp(&SessionData{}, "http", "", "", emptyLesson, ds, []int{}, [][]int{{}}, []string{}, diagram.Servers{}, DefaultMessages(), false, false, nil, false, "")
 	for _, test := range waTests {
 
 		var b bytes.Buffer
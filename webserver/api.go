@@ -0,0 +1,109 @@
+package webserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/program"
+	"github.com/monopole/mdrip/schema"
+	"github.com/monopole/mdrip/webapp"
+)
+
+// apiPrefix starts the paths of the JSON API, for frontends and
+// tools driving a tutorial without scraping the web app's HTML.
+const apiPrefix = "/api/v1"
+
+// apiSessID is the session of blocks run via the API
+// by requests naming no session of their own.
+const apiSessID = webapp.TypeSessID("api")
+
+// addAPI routes the JSON API's requests:
+//
+//	GET  /api/v1/lessons                 a schema.Lessons document
+//	GET  /api/v1/lessons/{path}/blocks   a schema.Blocks document
+//	POST /api/v1/run/{lesson}/{block}    runs the block, as /_/runblock
+func (ws *Server) addAPI(r *mux.Router) {
+	r.HandleFunc(apiPrefix+"/lessons", ws.allowCORS(ws.listLessons))
+	r.HandleFunc(apiPrefix+"/lessons/{path:.+}/blocks", ws.allowCORS(ws.listBlocks))
+	r.HandleFunc(apiPrefix+"/run/{lesson:[0-9]+}/{block:[0-9]+}",
+		ws.allowCORS(ws.requireToken(ws.runAPIBlock)))
+}
+
+func writeJSON(w http.ResponseWriter, status int, doc interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := schema.Write(w, doc); err != nil {
+		write500(w, err)
+	}
+}
+
+// listLessons writes the tutorial's lessons, in the
+// order, and with the indices, the web app gives them.
+func (ws *Server) listLessons(w http.ResponseWriter, r *http.Request) {
+	t := ws.currentTutorial()
+	lessons := program.NewProgramFromTutorial(base.WildCardLabel, t).Lessons()
+	var result []schema.LessonSummary
+	for i, page := range webapp.LessonPages(t) {
+		n := 0
+		if i < len(lessons) {
+			for _, b := range lessons[i].Blocks() {
+				if len(b.Code()) > 0 {
+					n++
+				}
+			}
+		}
+		result = append(result, schema.LessonSummary{
+			Path: page.Path, Title: page.Title, Lesson: i, Blocks: n})
+	}
+	writeJSON(w, http.StatusOK, schema.NewLessons(result))
+}
+
+// listBlocks writes the blocks of code of the
+// lesson at the request's path, e.g. setup/install.
+func (ws *Server) listBlocks(w http.ResponseWriter, r *http.Request) {
+	p := mux.Vars(r)["path"]
+	t := ws.currentTutorial()
+	lessons := program.NewProgramFromTutorial(base.WildCardLabel, t).Lessons()
+	for i, page := range webapp.LessonPages(t) {
+		if page.Path == p && i < len(lessons) {
+			writeJSON(w, http.StatusOK, schema.NewBlocks(p, i, lessons[i]))
+			return
+		}
+	}
+	http.Error(w, fmt.Sprintf("no lesson at %q", p), http.StatusNotFound)
+}
+
+// runAPIBlock sends the block with the request's id to the
+// shell of the session named by the sid param, if any, else
+// to that of apiSessID, and writes the block's new state.
+// Its progress is then in /_/status and /_/results.
+func (ws *Server) runAPIBlock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	sessID := apiSessID
+	if x := r.URL.Query().Get(webapp.KeySessID); len(x) > 0 {
+		sessID = webapp.TypeSessID(x)
+	}
+	lessonIndex, _ := strconv.Atoi(mux.Vars(r)["lesson"])
+	blockIndex, _ := strconv.Atoi(mux.Vars(r)["block"])
+	lessons := program.NewProgramFromTutorial(base.WildCardLabel, ws.currentTutorial()).Lessons()
+	if lessonIndex >= len(lessons) ||
+		blockIndex >= len(lessons[lessonIndex].Blocks()) ||
+		len(lessons[lessonIndex].Blocks()[blockIndex].Code()) == 0 {
+		http.Error(w, fmt.Sprintf("no block %s",
+			blockKey(lessonIndex, blockIndex)), http.StatusNotFound)
+		return
+	}
+	block := lessons[lessonIndex].Blocks()[blockIndex]
+	if err := ws.runBlock(sessID, lessonIndex, blockIndex, block, r); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, schema.NewOutputState(
+		blockKey(lessonIndex, blockIndex), string(stateRunning), -1))
+}
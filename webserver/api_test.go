@@ -0,0 +1,70 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/monopole/mdrip/schema"
+)
+
+func TestAPI(t *testing.T) {
+	// With no multiplexer, blocks can't be run.
+	ws, _ := serveFiles(t, "", map[string]string{
+		"intro.md":         "# Intro\n\nNo code here.\n",
+		"install/linux.md": downloadLesson,
+	})
+	do := func(method, p string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ws.router().ServeHTTP(w, httptest.NewRequest(method, p, nil))
+		return w
+	}
+
+	w := do("GET", "/api/v1/lessons")
+	var lessons schema.Lessons
+	if err := json.Unmarshal(w.Body.Bytes(), &lessons); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("lessons: got %d %v %s", w.Code, err, w.Body.String())
+	}
+	linux := -1
+	for _, l := range lessons.Lessons {
+		if l.Path == "install/linux" {
+			linux = l.Lesson
+			if l.Title != "Linux" || l.Blocks != 2 {
+				t.Errorf("lessons: got %+v", l)
+			}
+		}
+	}
+	if lessons.Kind != schema.KindLessons || len(lessons.Lessons) != 2 || linux < 0 {
+		t.Fatalf("lessons: got %+v", lessons)
+	}
+
+	w = do("GET", "/api/v1/lessons/install/linux/blocks")
+	var blocks schema.Blocks
+	if err := json.Unmarshal(w.Body.Bytes(), &blocks); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("blocks: got %d %v %s", w.Code, err, w.Body.String())
+	}
+	if len(blocks.Blocks) != 2 || blocks.Blocks[1].Name != "check" ||
+		blocks.Blocks[1].Code != "echo check\n" {
+		t.Fatalf("blocks: got %+v", blocks)
+	}
+	if w := do("GET", "/api/v1/lessons/install/mac/blocks"); w.Code != http.StatusNotFound {
+		t.Errorf("blocks of nothing: got %d", w.Code)
+	}
+
+	run := "/api/v1/run/" + blocks.Blocks[1].ID
+	if w := do("GET", run); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("run by GET: got %d", w.Code)
+	}
+	if w := do("POST", run); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("run with no multiplexer: got %d", w.Code)
+	}
+	if w := do("POST", "/api/v1/run/9/0"); w.Code != http.StatusNotFound {
+		t.Errorf("run of nothing: got %d", w.Code)
+	}
+
+	ws.RequireTokens("s3cret")
+	if w := do("POST", run); w.Code == http.StatusServiceUnavailable {
+		t.Errorf("run without a token: got %d", w.Code)
+	}
+}
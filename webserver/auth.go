@@ -0,0 +1,87 @@
+package webserver
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Auth lets only those who authenticate into a server.
+type Auth interface {
+	// Wrap returns a handler passing on to h only the
+	// requests of those who've authenticated.
+	Wrap(h http.Handler) http.Handler
+}
+
+// basicAuthRealm names, to browsers, what basic auth protects.
+const basicAuthRealm = "mdrip"
+
+// NewBasicAuth returns an Auth asking for the name and password
+// of one of the users, each given as {name}:{hash}, the hash
+// being a bcrypt hash of the password, as "htpasswd -nB" makes.
+func NewBasicAuth(users []string) (Auth, error) {
+	a := &basicAuth{map[string][]byte{}, sync.Mutex{}, map[[sha256.Size]byte]bool{}}
+	for _, u := range users {
+		i := strings.Index(u, ":")
+		if i < 1 {
+			return nil, fmt.Errorf("basic auth user %q isn't {name}:{hash}", u)
+		}
+		if _, err := bcrypt.Cost([]byte(u[i+1:])); err != nil {
+			return nil, fmt.Errorf("basic auth user %s: %v", u[:i], err)
+		}
+		a.hashes[u[:i]] = []byte(u[i+1:])
+	}
+	if len(a.hashes) == 0 {
+		return nil, fmt.Errorf("no basic auth users")
+	}
+	return a, nil
+}
+
+type basicAuth struct {
+	hashes map[string][]byte
+	mu     sync.Mutex
+	// passed holds digests of the names and passwords that
+	// have passed, since bcrypt is, by design, too slow
+	// to run for each of the many requests a page makes.
+	passed map[[sha256.Size]byte]bool
+}
+
+func (a *basicAuth) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.check(r) {
+			w.Header().Set("WWW-Authenticate",
+				`Basic realm="`+basicAuthRealm+`", charset="UTF-8"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// check is true if the request bears a known
+// user's name, and that user's password.
+func (a *basicAuth) check(r *http.Request) bool {
+	name, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	hash, ok := a.hashes[name]
+	if !ok {
+		return false
+	}
+	digest := sha256.Sum256([]byte(name + ":" + password))
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.passed[digest] {
+		return true
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+		return false
+	}
+	a.passed[digest] = true
+	return true
+}
@@ -0,0 +1,51 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBasicAuth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewBasicAuth([]string{"ann"}); err == nil {
+		t.Errorf("took a user without a hash")
+	}
+	if _, err := NewBasicAuth([]string{"ann:s3cret"}); err == nil {
+		t.Errorf("took a password for a hash")
+	}
+	a, err := NewBasicAuth([]string{"ann:" + string(hash)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := a.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	var tests = map[string]struct {
+		name, password string
+		want           int
+	}{
+		"right":         {"ann", "s3cret", http.StatusOK},
+		"rightAgain":    {"ann", "s3cret", http.StatusOK},
+		"wrongPassword": {"ann", "guess", http.StatusUnauthorized},
+		"unknownUser":   {"bob", "s3cret", http.StatusUnauthorized},
+		"none":          {"", "", http.StatusUnauthorized},
+	}
+	for n, test := range tests {
+		r := httptest.NewRequest("GET", "/_/runblock", nil)
+		if len(test.name) > 0 {
+			r.SetBasicAuth(test.name, test.password)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.want {
+			t.Errorf("%s: got %d, want %d", n, w.Code, test.want)
+		}
+		if w.Code == http.StatusUnauthorized && len(w.Header().Get("WWW-Authenticate")) == 0 {
+			t.Errorf("%s: no challenge", n)
+		}
+	}
+}
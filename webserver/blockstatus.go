@@ -0,0 +1,185 @@
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/monopole/mdrip/webapp"
+)
+
+// blockState is what's known about a block sent to tmux.
+type blockState string
+
+const (
+	// Sent over a websocket; completion can't be observed.
+	stateSent = blockState("sent")
+	// Sent to local tmux, and not yet finished.
+	stateRunning = blockState("running")
+	// Finished with exit status zero.
+	stateOk = blockState("ok")
+	// Finished with non-zero exit status, or timed out.
+	stateFailed = blockState("failed")
+)
+
+func blockKey(lessonIndex, blockIndex int) string {
+	return fmt.Sprintf("%d/%d", lessonIndex, blockIndex)
+}
+
+// statusTracker records, per session, the state of blocks sent to tmux.
+type statusTracker struct {
+	mu     sync.Mutex
+	states map[webapp.TypeSessID]map[string]blockState
+	// updated is when each session last sent a block.
+	updated map[webapp.TypeSessID]time.Time
+	// started holds when each session's running blocks started,
+	// and seconds how long its finished ones ran.
+	started map[webapp.TypeSessID]map[string]time.Time
+	seconds map[webapp.TypeSessID]map[string]float64
+	// path, if not empty, is the file the states are kept
+	// in, so that they outlive the server.
+	path string
+}
+
+func newStatusTracker() *statusTracker {
+	return &statusTracker{
+		states:  make(map[webapp.TypeSessID]map[string]blockState),
+		updated: make(map[webapp.TypeSessID]time.Time),
+		started: make(map[webapp.TypeSessID]map[string]time.Time),
+		seconds: make(map[webapp.TypeSessID]map[string]float64)}
+}
+
+// set records the block's state, returning, if it
+// finished, how many seconds it ran, else 0.
+func (t *statusTracker) set(s webapp.TypeSessID, key string, b blockState) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m, ok := t.states[s]
+	if !ok {
+		m = make(map[string]blockState)
+		t.states[s] = m
+	}
+	if _, ok := t.started[s]; !ok {
+		t.started[s] = make(map[string]time.Time)
+		t.seconds[s] = make(map[string]float64)
+	}
+	m[key] = b
+	now := time.Now()
+	t.updated[s] = now
+	elapsed := 0.0
+	switch b {
+	case stateRunning:
+		t.started[s][key] = now
+		delete(t.seconds[s], key)
+	case stateOk, stateFailed:
+		if start, ok := t.started[s][key]; ok {
+			elapsed = now.Sub(start).Seconds()
+			t.seconds[s][key] = elapsed
+			delete(t.started[s], key)
+		}
+	default:
+		delete(t.seconds[s], key)
+	}
+	if len(t.path) > 0 {
+		if err := t.save(); err != nil {
+			glog.Errorf("unable to keep progress in %s: %v", t.path, err)
+		}
+	}
+	return elapsed
+}
+
+// get returns a copy of the session's block states, keyed by blockKey.
+func (t *statusTracker) get(s webapp.TypeSessID) map[string]blockState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := make(map[string]blockState)
+	for k, v := range t.states[s] {
+		result[k] = v
+	}
+	return result
+}
+
+// elapsed returns a copy of how many seconds
+// the session's finished blocks ran, keyed by blockKey.
+func (t *statusTracker) elapsed(s webapp.TypeSessID) map[string]float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := make(map[string]float64)
+	for k, v := range t.seconds[s] {
+		result[k] = v
+	}
+	return result
+}
+
+// savedSession is how a session's states are kept in a file.
+type savedSession struct {
+	Blocks  map[string]blockState `json:"blocks"`
+	Updated time.Time             `json:"updated"`
+}
+
+// sessions returns a copy of the states of every session.
+func (t *statusTracker) sessions() map[webapp.TypeSessID]savedSession {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := make(map[webapp.TypeSessID]savedSession)
+	for s, m := range t.states {
+		x := savedSession{make(map[string]blockState), t.updated[s]}
+		for k, v := range m {
+			x.Blocks[k] = v
+		}
+		result[s] = x
+	}
+	return result
+}
+
+// keepIn has the tracker keep its states in the file, starting
+// with those the file holds, if it exists.  Blocks that were
+// running when the states were saved are forgotten, since
+// there's no telling how they ended.
+func (t *statusTracker) keepIn(path string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		var saved map[webapp.TypeSessID]savedSession
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return fmt.Errorf("bad progress in %s: %v", path, err)
+		}
+		for s, x := range saved {
+			for k, v := range x.Blocks {
+				if v == stateRunning {
+					delete(x.Blocks, k)
+				}
+			}
+			t.states[s] = x.Blocks
+			t.updated[s] = x.Updated
+		}
+	}
+	t.path = path
+	return nil
+}
+
+// save writes the states to the tracker's file, via a
+// temporary file, so that a crash can't leave half of them.
+func (t *statusTracker) save() error {
+	saved := make(map[webapp.TypeSessID]savedSession)
+	for s, m := range t.states {
+		saved[s] = savedSession{m, t.updated[s]}
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
@@ -0,0 +1,121 @@
+package webserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/monopole/mdrip/schema"
+	"github.com/monopole/mdrip/tmux"
+	"github.com/monopole/mdrip/webapp"
+)
+
+func TestStatusTracker(t *testing.T) {
+	s := newStatusTracker()
+	sess := webapp.TypeSessID("abc")
+	if got := s.get(sess); len(got) != 0 {
+		t.Errorf("expected no states, got %v", got)
+	}
+	if e := s.set(sess, blockKey(0, 1), stateRunning); e != 0 {
+		t.Errorf("a block starting ran %v seconds", e)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if e := s.set(sess, blockKey(0, 1), stateOk); e < 0.01 {
+		t.Errorf("a block ran only %v seconds", e)
+	}
+	s.set(sess, blockKey(2, 0), stateFailed)
+	got := s.get(sess)
+	if len(got) != 2 || got["0/1"] != stateOk || got["2/0"] != stateFailed {
+		t.Errorf("unexpected states %v", got)
+	}
+	// A block seen only to fail has no known time.
+	if e := s.elapsed(sess); len(e) != 1 || e["0/1"] < 0.01 {
+		t.Errorf("unexpected times %v", e)
+	}
+	if other := s.get(webapp.TypeSessID("zebra")); len(other) != 0 {
+		t.Errorf("expected no states in other session, got %v", other)
+	}
+}
+
+func TestShowStatus(t *testing.T) {
+	ws := &Server{statuses: newStatusTracker(), mux: tmux.NewTmux(tmux.Path),
+		connections: map[webapp.TypeSessID]*myConn{"s1": {}}}
+	ws.statuses.set("s1", blockKey(0, 1), stateOk)
+	local := schema.DeliveryClipboard
+	if tmux.NewTmux(tmux.Path).IsUp() {
+		local = schema.DeliveryTmux
+	}
+	for sess, want := range map[string]string{
+		"s1": schema.DeliveryWebsocket,
+		"s2": local,
+	} {
+		w := httptest.NewRecorder()
+		ws.showStatus(w, httptest.NewRequest(
+			"GET", "/_/status?"+webapp.KeySessID+"="+sess, nil))
+		var got schema.Status
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Delivery != want {
+			t.Errorf("%s: got delivery %q, want %q", sess, got.Delivery, want)
+		}
+		if sess == "s1" && got.Blocks["0/1"] != "ok" {
+			t.Errorf("%s: got blocks %v", sess, got.Blocks)
+		}
+	}
+	// Given no multiplexer, blocks are only copied.
+	ws.mux = nil
+	w := httptest.NewRecorder()
+	ws.showStatus(w, httptest.NewRequest(
+		"GET", "/_/status?"+webapp.KeySessID+"=s1", nil))
+	var got schema.Status
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Delivery != schema.DeliveryClipboard {
+		t.Errorf("got delivery %q, want %q", got.Delivery, schema.DeliveryClipboard)
+	}
+	if _, err := ws.send("s1", "", "date\n", ""); err != errNoTmux {
+		t.Errorf("got %v, want %v", err, errNoTmux)
+	}
+}
+
+func TestStatusTrackerKeepIn(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mdrip-progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "progress.json")
+	s := newStatusTracker()
+	if err := s.keepIn(p); err != nil {
+		t.Fatal(err)
+	}
+	s.set("s1", blockKey(0, 0), stateOk)
+	s.set("s1", blockKey(0, 1), stateRunning)
+	s.set("s2", blockKey(1, 0), stateFailed)
+
+	again := newStatusTracker()
+	if err := again.keepIn(p); err != nil {
+		t.Fatal(err)
+	}
+	got := again.get("s1")
+	if len(got) != 1 || got["0/0"] != stateOk {
+		t.Errorf("a running block should be forgotten; got %v", got)
+	}
+	if got := again.get("s2"); got["1/0"] != stateFailed {
+		t.Errorf("got %v", got)
+	}
+	if x := again.sessions()["s2"]; x.Updated.IsZero() {
+		t.Errorf("lost when s2 was last active")
+	}
+
+	ioutil.WriteFile(p, []byte("zebra"), 0644)
+	if err := newStatusTracker().keepIn(p); err == nil {
+		t.Errorf("expected an error for a bad file")
+	}
+}
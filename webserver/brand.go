@@ -0,0 +1,17 @@
+package webserver
+
+import "github.com/monopole/mdrip/webapp"
+
+// Brand gives the tutorial's pages the branding's theme,
+// styles, title and logo.
+func (ws *Server) Brand(b webapp.Branding) {
+	ws.brand = b
+}
+
+// Brand gives each of the hub's tutorials the branding;
+// see Server.Brand.
+func (h *Hub) Brand(b webapp.Branding) {
+	for _, s := range h.servers {
+		s.Brand(b)
+	}
+}
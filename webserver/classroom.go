@@ -0,0 +1,410 @@
+package webserver
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/program"
+	"github.com/monopole/mdrip/schema"
+	"github.com/monopole/mdrip/webapp"
+)
+
+// student is a session that joined a classroom.
+type student struct {
+	name string
+	// lesson is the index of the lesson the
+	// student's page shows; -1 if unknown.
+	lesson int
+	// lastBlock is the blockKey of the block the student
+	// last ran, and lastState what became of it.
+	lastBlock  string
+	lastState  blockState
+	lastActive time.Time
+}
+
+// classroom holds the sessions that joined with its code,
+// for an instructor, holding its key, to follow.
+type classroom struct {
+	code string
+	key  string
+	mu   sync.Mutex
+	// students are the sessions that joined.
+	students map[webapp.TypeSessID]*student
+	// opens holds when locked sections - the paths of courses
+	// or lessons - open; the zero time if when released.
+	opens map[string]time.Time
+}
+
+func newClassroom(code, key string) *classroom {
+	return &classroom{
+		code: code, key: key, students: make(map[webapp.TypeSessID]*student),
+		opens: make(map[string]time.Time)}
+}
+
+// join adds the session to the class, or renames it if it's in it.
+func (c *classroom) join(s webapp.TypeSessID, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if x, ok := c.students[s]; ok {
+		x.name = name
+		return
+	}
+	c.students[s] = &student{name: name, lesson: -1, lastActive: time.Now()}
+}
+
+// noteLesson records the lesson a student's page shows.
+func (c *classroom) noteLesson(s webapp.TypeSessID, lesson int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if x, ok := c.students[s]; ok {
+		x.lesson = lesson
+		x.lastActive = time.Now()
+	}
+}
+
+// noteBlock records what became of the block a student last ran.
+func (c *classroom) noteBlock(s webapp.TypeSessID, key string, b blockState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if x, ok := c.students[s]; ok {
+		x.lastBlock, x.lastState = key, b
+		x.lastActive = time.Now()
+	}
+}
+
+// sessions returns the sessions of the class's students.
+func (c *classroom) sessions() []webapp.TypeSessID {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result []webapp.TypeSessID
+	for s := range c.students {
+		result = append(result, s)
+	}
+	return result
+}
+
+// rows describes the class's students, sorted by name, with
+// lessons given as paths, by lesson index.
+func (c *classroom) rows(lessons []string) []webapp.ClassroomRow {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result []webapp.ClassroomRow
+	for _, x := range c.students {
+		row := webapp.ClassroomRow{
+			Name: x.name, Lesson: x.lesson, LastState: string(x.lastState),
+			LastActive: x.lastActive}
+		if row.Lesson >= len(lessons) {
+			row.Lesson = -1
+		}
+		var l, b int
+		if _, err := fmt.Sscanf(x.lastBlock, "%d/%d", &l, &b); err == nil && l < len(lessons) {
+			row.LastBlock = fmt.Sprintf("%s #%d", lessons[l], b+1)
+		}
+		result = append(result, row)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// lock keeps the section's lessons from the class until the
+// given time, or, if it's zero, until the section is released.
+func (c *classroom) lock(section string, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.opens[section] = at
+}
+
+// release opens the section's lessons to the class.
+func (c *classroom) release(section string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.opens, section)
+}
+
+// inSection is true if the lesson's path is the section's, or below it.
+func inSection(lesson, section string) bool {
+	return lesson == section || strings.HasPrefix(lesson, section+"/")
+}
+
+// locked returns the lessons, given as paths by lesson index,
+// that are locked at the given time.  A lesson in several
+// locked sections opens when the last of them does.
+func (c *classroom) locked(lessons []string, now time.Time) []schema.LockedLesson {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result []schema.LockedLesson
+	for i, l := range lessons {
+		var until time.Time
+		isLocked := false
+		for section, at := range c.opens {
+			if !inSection(l, section) || (!at.IsZero() && !at.After(now)) {
+				continue
+			}
+			if at.IsZero() {
+				until, isLocked = at, true
+				break
+			}
+			if !isLocked || at.After(until) {
+				until, isLocked = at, true
+			}
+		}
+		if !isLocked {
+			continue
+		}
+		x := schema.LockedLesson{Lesson: i, Path: l}
+		if !until.IsZero() {
+			x.Until = until.Format(time.RFC3339)
+		}
+		result = append(result, x)
+	}
+	return result
+}
+
+// schedule describes the locked sections, sorted by path,
+// as the dashboard shows them at the given time.
+func (c *classroom) schedule(now time.Time) []webapp.ScheduleRow {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result []webapp.ScheduleRow
+	for section, at := range c.opens {
+		result = append(result, webapp.ScheduleRow{
+			Section: section, Opens: at, Open: !at.IsZero() && !at.After(now)})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Section < result[j].Section })
+	return result
+}
+
+// sections returns the paths of the courses and lessons
+// holding the given lessons, in the order they start.
+func sections(lessons []string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, l := range lessons {
+		parts := strings.Split(l, "/")
+		for i := range parts {
+			p := strings.Join(parts[:i+1], "/")
+			if !seen[p] {
+				seen[p] = true
+				result = append(result, p)
+			}
+		}
+	}
+	return result
+}
+
+// NewInstructorKey returns a random key to a classroom's dashboard.
+func NewInstructorKey() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
+
+// OpenClassroom lets visitors join a class with the given code, at
+// /join, and its instructor, holding the key, follow them at
+// /classroom, and send them all to a lesson.
+func (ws *Server) OpenClassroom(code, key string) {
+	ws.class = newClassroom(code, key)
+}
+
+// OpenClassroom opens a classroom in each of the hub's
+// tutorials; see Server.OpenClassroom.
+func (h *Hub) OpenClassroom(code, key string) {
+	for _, s := range h.servers {
+		s.OpenClassroom(code, key)
+	}
+}
+
+// lessonPaths are the paths of the tutorial's
+// lessons' pages, by lesson index.
+func (ws *Server) lessonPaths() []string {
+	var result []string
+	for _, page := range webapp.LessonPages(ws.currentTutorial()) {
+		result = append(result, page.Path)
+	}
+	return result
+}
+
+// title is the tutorial's, its first H1 header,
+// unless the server's branding gives another.
+func (ws *Server) title() string {
+	if len(ws.brand.Title) > 0 {
+		return ws.brand.Title
+	}
+	v := program.NewLessonPgmExtractor(base.WildCardLabel)
+	ws.currentTutorial().Accept(v)
+	return v.FirstTitle()
+}
+
+// join shows the form for joining the class, and, given the
+// class's code and a name, adds the visitor's session to the
+// class, and sends them to the tutorial.
+func (ws *Server) join(w http.ResponseWriter, r *http.Request) {
+	if ws.class == nil {
+		http.NotFound(w, r)
+		return
+	}
+	session, err := ws.store.Get(r, cookieName)
+	if err != nil {
+		write500(w, err)
+		return
+	}
+	code := strings.TrimSpace(r.FormValue(webapp.KeyClassCode))
+	name := strings.TrimSpace(r.FormValue(webapp.KeyStudentName))
+	problem := ""
+	if r.Method == http.MethodPost {
+		if subtle.ConstantTimeCompare([]byte(code), []byte(ws.class.code)) == 1 && len(name) > 0 {
+			sessionData := webapp.AssureSessionData(session)
+			if err := session.Save(r, w); err != nil {
+				write500(w, err)
+				return
+			}
+			ws.class.join(sessionData.SessID, name)
+			glog.Infof("%s joined the class in session %v", name, sessionData.SessID)
+			http.Redirect(w, r, ws.prefix+"/", http.StatusSeeOther)
+			return
+		}
+		problem = ws.msgs.Get("badClassCode")
+		w.WriteHeader(http.StatusForbidden)
+	}
+	if err := webapp.RenderJoin(
+		w, ws.title(), ws.prefix, code, name, problem, ws.msgs); err != nil {
+		write500(w, err)
+	}
+}
+
+// requireInstructor wraps a handler of a classroom's dashboard,
+// so that it's only called for requests bearing its key.
+func (ws *Server) requireInstructor(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ws.class == nil {
+			http.NotFound(w, r)
+			return
+		}
+		k := r.URL.Query().Get(webapp.KeyInstructorKey)
+		if subtle.ConstantTimeCompare([]byte(k), []byte(ws.class.key)) != 1 {
+			http.Error(w, "the classroom needs the instructor's key", http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// showClassroom writes the instructor's dashboard.
+func (ws *Server) showClassroom(w http.ResponseWriter, r *http.Request) {
+	lessons := ws.lessonPaths()
+	if err := webapp.RenderClassroom(
+		w, ws.title(), ws.prefix, ws.class.key, lessons, sections(lessons),
+		ws.class.rows(lessons), ws.class.schedule(time.Now()), ws.msgs); err != nil {
+		write500(w, err)
+	}
+}
+
+// jumpClass tells the pages of all the class's students, over
+// their /_/results websockets, to show the requested lesson,
+// then goes back to the dashboard.
+func (ws *Server) jumpClass(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST to send the class to a lesson", http.StatusMethodNotAllowed)
+		return
+	}
+	lessons := ws.lessonPaths()
+	i, err := strconv.Atoi(r.FormValue(webapp.KeyLessonIndex))
+	if err != nil || i < 0 || i >= len(lessons) {
+		http.Error(w, fmt.Sprintf("no lesson %q", r.FormValue(webapp.KeyLessonIndex)),
+			http.StatusBadRequest)
+		return
+	}
+	doc := schema.NewJump(i, lessons[i])
+	for _, s := range ws.class.sessions() {
+		ws.results.publish(s, doc)
+	}
+	http.Redirect(w, r, ws.prefix+"/classroom?"+webapp.KeyInstructorKey+"="+ws.class.key,
+		http.StatusSeeOther)
+}
+
+// showSchedule writes the lessons the class may not open yet,
+// for pages to grey out; there's none without a classroom.
+func (ws *Server) showSchedule(w http.ResponseWriter, r *http.Request) {
+	if ws.class == nil {
+		http.NotFound(w, r)
+		return
+	}
+	doc := schema.NewSchedule(ws.class.locked(ws.lessonPaths(), time.Now()))
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(doc); err != nil {
+		write500(w, err)
+	}
+}
+
+// publishSchedule tells the pages of all the class's students,
+// over their /_/results websockets, what's locked now, then
+// goes back to the dashboard.
+func (ws *Server) publishSchedule(w http.ResponseWriter, r *http.Request) {
+	doc := schema.NewSchedule(ws.class.locked(ws.lessonPaths(), time.Now()))
+	for _, s := range ws.class.sessions() {
+		ws.results.publish(s, doc)
+	}
+	http.Redirect(w, r, ws.prefix+"/classroom?"+webapp.KeyInstructorKey+"="+ws.class.key,
+		http.StatusSeeOther)
+}
+
+// scheduleClass locks the requested section until the requested
+// time of day, today, or, if none, until it's released.
+func (ws *Server) scheduleClass(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST to schedule a section", http.StatusMethodNotAllowed)
+		return
+	}
+	section := r.FormValue(webapp.KeySection)
+	if !ws.isSection(section) {
+		http.Error(w, fmt.Sprintf("no section %q", section), http.StatusBadRequest)
+		return
+	}
+	var at time.Time
+	if v := strings.TrimSpace(r.FormValue(webapp.KeyOpensAt)); len(v) > 0 {
+		t, err := time.ParseInLocation("15:04", v, time.Local)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("bad time %q; want e.g. 14:00", v), http.StatusBadRequest)
+			return
+		}
+		now := time.Now()
+		at = time.Date(now.Year(), now.Month(), now.Day(),
+			t.Hour(), t.Minute(), 0, 0, time.Local)
+		if !at.After(now) {
+			http.Error(w, fmt.Sprintf("%s has passed", v), http.StatusBadRequest)
+			return
+		}
+	}
+	ws.class.lock(section, at)
+	ws.publishSchedule(w, r)
+}
+
+// releaseSection opens the requested section to the class now.
+func (ws *Server) releaseSection(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST to release a section", http.StatusMethodNotAllowed)
+		return
+	}
+	ws.class.release(r.FormValue(webapp.KeySection))
+	ws.publishSchedule(w, r)
+}
+
+// isSection is true if the path is that of a course or lesson.
+func (ws *Server) isSection(path string) bool {
+	for _, s := range sections(ws.lessonPaths()) {
+		if s == path {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package webserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/monopole/mdrip/schema"
+	"github.com/monopole/mdrip/webapp"
+)
+
+func TestClassroom(t *testing.T) {
+	ws, _ := serveFiles(t, "", map[string]string{
+		"install.md": "# Install\n\n```\necho 1\n```\n\n```\necho 2\n```\n",
+		"setup.md":   "```\necho 3\n```\n",
+	})
+	srv := httptest.NewServer(ws.router())
+	defer srv.Close()
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	post := func(p string, form url.Values) (int, string) {
+		resp, err := client.PostForm(srv.URL+p, form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	get := func(p string) (int, string) {
+		resp, err := client.Get(srv.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if code, _ := get("/join"); code != http.StatusNotFound {
+		t.Errorf("got %d for /join without a classroom", code)
+	}
+	ws.OpenClassroom("cats42", "zebra")
+	join := url.Values{webapp.KeyClassCode: {"dogs"}, webapp.KeyStudentName: {"ann"}}
+	if code, body := post("/join", join); code != http.StatusForbidden ||
+		!strings.Contains(body, "that is not the class code") {
+		t.Errorf("got %d %s", code, body)
+	}
+	join.Set(webapp.KeyClassCode, "cats42")
+	if code, _ := post("/join", join); code != http.StatusOK {
+		t.Fatalf("got %d joining", code)
+	}
+	sessions := ws.class.sessions()
+	if len(sessions) != 1 {
+		t.Fatalf("got sessions %v", sessions)
+	}
+	sid := sessions[0]
+	post("/_/s?"+webapp.KeyLessonIndex+"=1", nil)
+	ws.setState(sid, blockKey(0, 1), stateFailed, 2)
+
+	if code, _ := get("/classroom"); code != http.StatusForbidden {
+		t.Errorf("got %d for the dashboard without the key", code)
+	}
+	_, body := get("/classroom?" + webapp.KeyInstructorKey + "=zebra")
+	for _, want := range []string{
+		"<td> ann </td>", "<td> setup </td>", "install #2 failed", "<option value='1'> setup </option>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("got\n%s\nwant it to hold %q", body, want)
+		}
+	}
+
+	c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+
+		"/_/results?"+webapp.KeySessID+"="+string(sid), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for i := 0; ; i++ {
+		ws.results.mu.Lock()
+		_, ok := ws.results.conns[sid]
+		ws.results.mu.Unlock()
+		if ok {
+			break
+		}
+		if i > 100 {
+			t.Fatal("no watcher added")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if code, _ := get("/classroom/jump?" + webapp.KeyInstructorKey + "=zebra"); code != http.StatusMethodNotAllowed {
+		t.Errorf("got %d for GET of a jump", code)
+	}
+	if code, _ := post("/classroom/jump?"+webapp.KeyInstructorKey+"=zebra",
+		url.Values{webapp.KeyLessonIndex: {"0"}}); code != http.StatusOK {
+		t.Errorf("got %d jumping", code)
+	}
+	var jump schema.Jump
+	if err := c.ReadJSON(&jump); err != nil {
+		t.Fatal(err)
+	}
+	if jump.Kind != schema.KindJump || jump.Lesson != 0 || jump.Path != "install" {
+		t.Errorf("got %+v", jump)
+	}
+
+	if code, _ := get("/classroom/schedule?" + webapp.KeyInstructorKey + "=zebra"); code != http.StatusMethodNotAllowed {
+		t.Errorf("got %d for GET of a schedule", code)
+	}
+	if code, _ := post("/classroom/schedule?"+webapp.KeyInstructorKey+"=zebra",
+		url.Values{webapp.KeySection: {"advanced"}}); code != http.StatusBadRequest {
+		t.Errorf("got %d locking a missing section", code)
+	}
+	if code, _ := post("/classroom/schedule?"+webapp.KeyInstructorKey+"=zebra",
+		url.Values{webapp.KeySection: {"setup"}}); code != http.StatusOK {
+		t.Errorf("got %d locking", code)
+	}
+	var sched schema.Schedule
+	if err := c.ReadJSON(&sched); err != nil {
+		t.Fatal(err)
+	}
+	if sched.Kind != schema.KindSchedule || len(sched.Locked) != 1 ||
+		sched.Locked[0].Path != "setup" || sched.Locked[0].Until != "" {
+		t.Errorf("got %+v", sched)
+	}
+	if _, body := get("/_/schedule"); !strings.Contains(body, `"path":"setup"`) {
+		t.Errorf("got %s", body)
+	}
+	if code, _ := post("/classroom/release?"+webapp.KeyInstructorKey+"=zebra",
+		url.Values{webapp.KeySection: {"setup"}}); code != http.StatusOK {
+		t.Errorf("got %d releasing", code)
+	}
+	if err := c.ReadJSON(&sched); err != nil {
+		t.Fatal(err)
+	}
+	if len(sched.Locked) != 0 {
+		t.Errorf("got %+v", sched)
+	}
+}
+
+func TestClassroomLocked(t *testing.T) {
+	lessons := []string{"basics/one", "advanced/one", "advanced/two", "advancedish"}
+	if got, want := strings.Join(sections(lessons), " "),
+		"basics basics/one advanced advanced/one advanced/two advancedish"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	now := time.Date(2020, 1, 2, 13, 0, 0, 0, time.UTC)
+	c := newClassroom("x", "y")
+	c.lock("advanced", now.Add(time.Hour))
+	c.lock("advanced/two", time.Time{})
+	c.lock("basics", now.Add(-time.Hour))
+	got := c.locked(lessons, now)
+	if len(got) != 2 {
+		t.Fatalf("got %+v", got)
+	}
+	if got[0].Lesson != 1 || got[0].Until != "2020-01-02T14:00:00Z" {
+		t.Errorf("got %+v", got[0])
+	}
+	if got[1].Lesson != 2 || got[1].Until != "" {
+		t.Errorf("got %+v", got[1])
+	}
+	if got := c.locked(lessons, now.Add(2*time.Hour)); len(got) != 1 || got[0].Lesson != 2 {
+		t.Errorf("got %+v", got)
+	}
+	rows := c.schedule(now)
+	if len(rows) != 3 || rows[0].Section != "advanced" || rows[0].Open || !rows[2].Open {
+		t.Errorf("got %+v", rows)
+	}
+}
@@ -0,0 +1,89 @@
+package webserver
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AllowOrigins lets pages from the given origins, e.g.
+// https://monopole.github.io, holding a tutorial exported with
+// an endpoint at this server, run its blocks here, and follow
+// their status and output.  Browsers let pages do so only
+// from the server's own origin otherwise.
+func (ws *Server) AllowOrigins(origins []string) {
+	ws.origins = map[string]bool{}
+	for _, o := range origins {
+		ws.origins[o] = true
+	}
+	ws.upgrader.CheckOrigin = func(r *http.Request) bool {
+		return ws.origins[r.Header.Get("Origin")] || sameOrigin(r)
+	}
+}
+
+// AllowOrigins lets pages from the given origins use each
+// of the hub's tutorials; see Server.AllowOrigins.
+func (h *Hub) AllowOrigins(origins []string) {
+	for _, s := range h.servers {
+		s.AllowOrigins(origins)
+	}
+}
+
+// sameOrigin is true if the request comes from a page of the
+// server's own, or from no page; websocket.Upgrader's default check.
+func sameOrigin(r *http.Request) bool {
+	o := r.Header.Get("Origin")
+	if len(o) == 0 {
+		return true
+	}
+	u, err := url.Parse(o)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// fromOwnPage is true if the request comes from a page of the
+// server's own, as its Origin header, or, lacking one, its
+// Referer header says.  Unlike sameOrigin, a request saying
+// neither is refused, so other sites' pages can't forge it.
+func fromOwnPage(r *http.Request) bool {
+	from := r.Header.Get("Origin")
+	if len(from) == 0 {
+		from = r.Header.Get("Referer")
+	}
+	if len(from) == 0 {
+		return false
+	}
+	u, err := url.Parse(from)
+	return err == nil && len(u.Host) > 0 && strings.EqualFold(u.Host, r.Host)
+}
+
+// requireOwnPage refuses requests to the handler that don't come
+// from the server's own pages, e.g. a form on another site
+// POSTing an edit, whether or not tokens are needed.
+func requireOwnPage(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !fromOwnPage(r) {
+			http.Error(w, "only the server's own pages may do this", http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// allowCORS lets pages from the allowed origins call the handler,
+// answering the preflight requests browsers send first.
+func (ws *Server) allowCORS(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o := r.Header.Get("Origin"); ws.origins[o] {
+			w.Header().Set("Access-Control-Allow-Origin", o)
+			w.Header().Add("Vary", "Origin")
+			if r.Method == http.MethodOptions {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+				// Lets public pages call a server on localhost.
+				w.Header().Set("Access-Control-Allow-Private-Network", "true")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		h(w, r)
+	}
+}
@@ -0,0 +1,43 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/loader"
+)
+
+func TestAllowOrigins(t *testing.T) {
+	ds, err := base.NewDataSet([]string{"../data"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := newServer("", loader.NewLoader(ds))
+	ws.AllowOrigins([]string{"https://example.github.io"})
+	for origin, allowed := range map[string]bool{
+		"https://example.github.io": true,
+		"https://example.org":       false,
+		"http://example.com":        true,
+		"":                          true,
+	} {
+		r := httptest.NewRequest("OPTIONS", "http://example.com/_/status", nil)
+		if len(origin) > 0 {
+			r.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		ws.router().ServeHTTP(w, r)
+		// Only other origins need, and get, CORS headers.
+		cors := origin == "https://example.github.io"
+		if got := w.Header().Get("Access-Control-Allow-Origin"); (got == origin && len(got) > 0) != cors {
+			t.Errorf("%q: got Access-Control-Allow-Origin %q", origin, got)
+		}
+		if cors && w.Code != http.StatusNoContent {
+			t.Errorf("%q: got preflight %d", origin, w.Code)
+		}
+		if got := ws.upgrader.CheckOrigin(r); got != allowed {
+			t.Errorf("%q: got websocket allowed %v", origin, got)
+		}
+	}
+}
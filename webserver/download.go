@@ -0,0 +1,168 @@
+package webserver
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+	"github.com/monopole/mdrip/program"
+	"github.com/monopole/mdrip/webapp"
+)
+
+// Suffixes of download paths, e.g. /raw/setup/install.md.
+const (
+	suffixMarkdown = ".md"
+	suffixScript   = ".sh"
+	suffixTarball  = ".tar.gz"
+)
+
+// downloadShebang is the interpreter of downloaded scripts,
+// as of those written in script mode.
+const downloadShebang = "/bin/bash"
+
+// lessonAt returns the lesson at the given path,
+// e.g. benelux/belgium/beer, or nil if there's none.
+func lessonAt(t model.Tutorial, p string) *model.LessonTut {
+	v := &lessonLocator{path: p}
+	t.Accept(v)
+	return v.found
+}
+
+// lessonLocator visits a tutorial, looking for the lesson at a path.
+type lessonLocator struct {
+	path  string
+	names []string
+	found *model.LessonTut
+}
+
+func (v *lessonLocator) VisitBlockTut(b *model.BlockTut) {}
+
+func (v *lessonLocator) VisitLessonTut(l *model.LessonTut) {
+	if strings.Join(append(v.names, l.Slug()), "/") == v.path {
+		v.found = l
+	}
+}
+
+func (v *lessonLocator) VisitCourse(c *model.Course) {
+	v.names = append(v.names, c.Slug())
+	for _, x := range c.Children() {
+		x.Accept(v)
+	}
+	v.names = v.names[:len(v.names)-1]
+}
+
+func (v *lessonLocator) VisitTopCourse(t *model.TopCourse) {
+	for _, x := range t.Children() {
+		x.Accept(v)
+	}
+}
+
+// showRaw writes the markdown, as written, of the
+// lesson at the request's path, less its suffix.
+func (ws *Server) showRaw(w http.ResponseWriter, r *http.Request) {
+	l := lessonAt(ws.tutorialIn(ws.langOf(r)),
+		strings.TrimSuffix(mux.Vars(r)["path"], suffixMarkdown))
+	if l == nil || len(l.Raw()) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	fmt.Fprint(w, l.Raw())
+}
+
+// showProgram writes the blocks of the course or lesson at the
+// request's path as a script, like one written in script mode,
+// or, if the path ends in suffixTarball, a gzipped tarball
+// holding a script for each lesson at or below the path.
+// The label param selects the blocks.
+func (ws *Server) showProgram(w http.ResponseWriter, r *http.Request) {
+	label := base.WildCardLabel
+	if x := r.URL.Query().Get(webapp.KeyLabel); len(x) > 0 {
+		label = base.Label(x)
+	}
+	if err := label.CheckSelector(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p := mux.Vars(r)["path"]
+	if strings.HasSuffix(p, suffixTarball) {
+		ws.writeTarball(w, r, label, strings.TrimSuffix(p, suffixTarball))
+		return
+	}
+	p = strings.TrimSuffix(p, suffixScript)
+	pgm, err := program.NewProgramFromTutorialAtPath(label, "", p, ws.tutorialIn(ws.langOf(r)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/x-shellscript; charset=utf-8")
+	pgm.PrintScript(w, ws.scriptOptions(p))
+}
+
+func (ws *Server) scriptOptions(p string) program.ScriptOptions {
+	return program.ScriptOptions{
+		Shebang: downloadShebang, Strict: true,
+		Source: ws.loader.DataSet().String() + " at " + p}
+}
+
+// writeTarball writes a gzipped tarball holding, for each lesson
+// at or below the given path, a script named for the lesson's
+// path, e.g. belgium/beer.sh.
+func (ws *Server) writeTarball(
+	w http.ResponseWriter, r *http.Request, label base.Label, p string) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	count := 0
+	t := ws.tutorialIn(ws.langOf(r))
+	for _, page := range webapp.LessonPages(t) {
+		if page.Path != p && !strings.HasPrefix(page.Path, p+"/") {
+			continue
+		}
+		pgm, err := program.NewProgramFromTutorialAtPath(label, "", page.Path, t)
+		if err != nil {
+			write500(w, err)
+			return
+		}
+		var script bytes.Buffer
+		pgm.PrintScript(&script, ws.scriptOptions(page.Path))
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    page.Path + suffixScript,
+			Mode:    0755,
+			Size:    int64(script.Len()),
+			ModTime: time.Now(),
+		}); err != nil {
+			write500(w, err)
+			return
+		}
+		if _, err := tw.Write(script.Bytes()); err != nil {
+			write500(w, err)
+			return
+		}
+		count++
+	}
+	if count == 0 {
+		http.Error(w, fmt.Sprintf("no course or lesson at path %q", p), http.StatusNotFound)
+		return
+	}
+	if err := tw.Close(); err != nil {
+		write500(w, err)
+		return
+	}
+	if err := gz.Close(); err != nil {
+		write500(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf("attachment; filename=%q", path.Base(p)+suffixTarball))
+	w.Write(buf.Bytes())
+}
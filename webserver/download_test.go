@@ -0,0 +1,91 @@
+package webserver
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/webapp"
+)
+
+const downloadLesson = `---
+title: Linux
+---
+# Linux
+
+<!-- @install -->
+` + "```" + `
+echo install
+` + "```" + `
+
+<!-- @check -->
+` + "```" + `
+echo check
+` + "```" + `
+`
+
+func TestDownloads(t *testing.T) {
+	ws, _ := serveFiles(t, "/k8s", map[string]string{
+		"intro.md":         "# Intro\n\n```\necho intro\n```\n",
+		"install/linux.md": downloadLesson,
+	})
+	get := func(p string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ws.router().ServeHTTP(w, httptest.NewRequest("GET", p, nil))
+		return w
+	}
+
+	w := get("/raw/install/linux.md")
+	if w.Code != http.StatusOK || w.Body.String() != downloadLesson {
+		t.Errorf("raw: got %d %q", w.Code, w.Body.String())
+	}
+	if w := get("/raw/install/mac.md"); w.Code != http.StatusNotFound {
+		t.Errorf("raw of nothing: got %d", w.Code)
+	}
+
+	w = get("/program/install/linux.sh?" + webapp.KeyLabel + "=check")
+	if w.Code != http.StatusOK {
+		t.Fatalf("script: got %d", w.Code)
+	}
+	s := w.Body.String()
+	if !strings.HasPrefix(s, "#!/bin/bash\n") || !strings.Contains(s, "set -euo pipefail\n") ||
+		!strings.Contains(s, "echo check\n") || strings.Contains(s, "echo install") {
+		t.Errorf("script: got %q", s)
+	}
+	if w := get("/program/install/mac.sh"); w.Code != http.StatusNotFound {
+		t.Errorf("script of nothing: got %d", w.Code)
+	}
+	if w := get("/program/intro.sh?" + webapp.KeyLabel + "=a%20%26%26"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad label: got %d", w.Code)
+	}
+
+	w = get("/program/install.tar.gz")
+	if w.Code != http.StatusOK {
+		t.Fatalf("tarball: got %d %s", w.Code, w.Body.String())
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		h, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, h.Name)
+		b, _ := ioutil.ReadAll(tr)
+		if !strings.Contains(string(b), "echo install\n") {
+			t.Errorf("%s: got %q", h.Name, b)
+		}
+	}
+	if want := []string{"install/linux.sh"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("tarball: got %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,179 @@
+package webserver
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/lexer"
+	"github.com/monopole/mdrip/model"
+	"github.com/monopole/mdrip/program"
+	"github.com/monopole/mdrip/schema"
+	"github.com/monopole/mdrip/webapp"
+)
+
+// maxEditSize bounds the markdown of an edited lesson.
+const maxEditSize = 1 << 20
+
+// What an edit did to a block, as in schema.BlockChange.
+const (
+	blockSame    = "same"
+	blockChanged = "changed"
+	blockAdded   = "added"
+	blockRemoved = "removed"
+)
+
+// TakeEdits offers to edit lessons, submitting
+// edits via the proposer.
+func (ws *Server) TakeEdits(p Proposer) {
+	ws.proposer = p
+}
+
+// TakeEdits offers to edit each of the hub's tutorials'
+// lessons; see Server.TakeEdits.
+func (h *Hub) TakeEdits(p Proposer) {
+	for _, s := range h.servers {
+		s.TakeEdits(p)
+	}
+}
+
+// editable is true if lessons may be edited, i.e. there's
+// a Proposer, and the tutorial is on the local disk.
+func (ws *Server) editable() bool {
+	return ws.proposer != nil && !ws.loader.IsRemote()
+}
+
+// readEdit returns the lesson named by the request's path
+// param, and the edited markdown in the request's body.
+// It answers the request itself, returning nil, if
+// editing's off, or the request is bad.
+func (ws *Server) readEdit(w http.ResponseWriter, r *http.Request) (*model.LessonTut, string) {
+	if !ws.editable() {
+		http.NotFound(w, r)
+		return nil, ""
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "edits must be POSTed", http.StatusMethodNotAllowed)
+		return nil, ""
+	}
+	l := lessonAt(ws.tutorialIn(ws.langOf(r)), r.URL.Query().Get(webapp.KeyEditPath))
+	if l == nil {
+		http.NotFound(w, r)
+		return nil, ""
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxEditSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, ""
+	}
+	return l, string(body)
+}
+
+// preview writes a schema.Preview of the lesson's edited
+// markdown, re-lexed and rendered as the app would.
+func (ws *Server) preview(w http.ResponseWriter, r *http.Request) {
+	l, md := ws.readEdit(w, r)
+	if l == nil {
+		return
+	}
+	edited := model.NewLessonTutFromMdContent(l.Path(), lexer.Parse(md))
+	w.Header().Set("Content-Type", "application/json")
+	if err := schema.Write(w, schema.NewPreview(
+		renderPreview(edited), diffBlocks(l.Blocks(), edited.Blocks()))); err != nil {
+		write500(w, err)
+	}
+}
+
+// propose submits the lesson's edited markdown via the
+// Proposer, writing the resulting schema.Proposal.
+func (ws *Server) propose(w http.ResponseWriter, r *http.Request) {
+	l, md := ws.readEdit(w, r)
+	if l == nil {
+		return
+	}
+	p, err := ws.proposer.Propose(
+		string(l.Path()), md, r.URL.Query().Get(webapp.KeyEditMessage))
+	if err != nil {
+		write500(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := schema.Write(w, p); err != nil {
+		write500(w, err)
+	}
+}
+
+// renderPreview renders the lesson's prose, as blocks
+// are rendered in the app, with its code beneath.
+func renderPreview(l *model.LessonTut) string {
+	var b bytes.Buffer
+	for _, x := range l.Blocks() {
+		b.WriteString(string(program.NewBlockPgmFromBlockTut(x).HTMLProse()))
+		if len(x.Code()) > 0 {
+			fmt.Fprintf(&b, "<pre><code>%s</code></pre>\n",
+				template.HTMLEscapeString(x.Code().String()))
+		}
+	}
+	return b.String()
+}
+
+// diffBlocks lines up the blocks before and after an edit,
+// keeping the most blocks whose code is untouched; of the
+// rest, those between the same untouched blocks pair up
+// as changed, and any left over were added or removed.
+func diffBlocks(before, after []*model.BlockTut) []schema.BlockChange {
+	code := func(b *model.BlockTut) base.OpaqueCode { return b.Code() }
+	// lcs[i][j] is how many blocks before[i:] and after[j:] share.
+	lcs := make([][]int, len(before)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			switch {
+			case code(before[i]) == code(after[j]):
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var result []schema.BlockChange
+	var removed, added []*model.BlockTut
+	flush := func() {
+		for len(removed) > 0 && len(added) > 0 {
+			result = append(result, schema.BlockChange{Name: added[0].Name(), Change: blockChanged})
+			removed, added = removed[1:], added[1:]
+		}
+		for _, b := range removed {
+			result = append(result, schema.BlockChange{Name: b.Name(), Change: blockRemoved})
+		}
+		for _, b := range added {
+			result = append(result, schema.BlockChange{Name: b.Name(), Change: blockAdded})
+		}
+		removed, added = nil, nil
+	}
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case i < len(before) && j < len(after) && code(before[i]) == code(after[j]):
+			flush()
+			result = append(result, schema.BlockChange{Name: after[j].Name(), Change: blockSame})
+			i++
+			j++
+		case j == len(after) || (i < len(before) && lcs[i+1][j] >= lcs[i][j+1]):
+			removed = append(removed, before[i])
+			i++
+		default:
+			added = append(added, after[j])
+			j++
+		}
+	}
+	flush()
+	return result
+}
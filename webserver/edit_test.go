@@ -0,0 +1,168 @@
+package webserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+	"github.com/monopole/mdrip/schema"
+	"github.com/monopole/mdrip/webapp"
+)
+
+func editBlock(name, code string) *model.BlockTut {
+	return model.NewBlockTut(model.NewBlockParsed(
+		[]base.Label{base.Label(name)}, base.MdProse(""), base.OpaqueCode(code)))
+}
+
+func TestDiffBlocks(t *testing.T) {
+	a, b, c, d := editBlock("a", "echo a\n"), editBlock("b", "echo b\n"),
+		editBlock("c", "echo c\n"), editBlock("d", "echo d\n")
+	var tests = map[string]struct {
+		before, after []*model.BlockTut
+		want          string
+	}{
+		"none":    {nil, nil, ""},
+		"same":    {[]*model.BlockTut{a, b}, []*model.BlockTut{a, b}, "a same,b same"},
+		"changed": {[]*model.BlockTut{a, b, c}, []*model.BlockTut{a, editBlock("b", "echo B\n"), c}, "a same,b changed,c same"},
+		"added":   {[]*model.BlockTut{a, c}, []*model.BlockTut{a, b, c}, "a same,b added,c same"},
+		"removed": {[]*model.BlockTut{a, b, c}, []*model.BlockTut{a, c}, "a same,b removed,c same"},
+		"moved":   {[]*model.BlockTut{a, b, c, d}, []*model.BlockTut{b, c, d, a}, "a removed,b same,c same,d same,a added"},
+	}
+	for n, test := range tests {
+		var got []string
+		for _, x := range diffBlocks(test.before, test.after) {
+			got = append(got, x.Name+" "+x.Change)
+		}
+		if strings.Join(got, ",") != test.want {
+			t.Errorf("%s: got %v, want %s", n, got, test.want)
+		}
+	}
+}
+
+func git(t *testing.T, dir string, args ...string) string {
+	out, err := runGit(dir, nil, "", args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestEdit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git")
+	}
+	for k, v := range map[string]string{
+		"GIT_AUTHOR_NAME": "Tess", "GIT_AUTHOR_EMAIL": "tess@example.com",
+		"GIT_COMMITTER_NAME": "Tess", "GIT_COMMITTER_EMAIL": "tess@example.com"} {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Setenv(k, v)
+	}
+	const before = "# Setup\n\n```\necho one\n```\n"
+	dir := writeFiles(t, map[string]string{"setup.md": before})
+	lesson := filepath.Join(dir, "setup.md")
+	git(t, dir, "init", "-q")
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-qm", "Start")
+	ws := loadServer(t, "", dir)
+	ws.TakeEdits(NewGitProposer(""))
+	postFrom := func(origin, action, md string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/_/"+action+"?"+
+			webapp.KeyEditPath+"=setup&"+webapp.KeyEditMessage+"=Say%20two",
+			strings.NewReader(md))
+		if len(origin) > 0 {
+			req.Header.Set("Origin", origin)
+		}
+		ws.router().ServeHTTP(w, req)
+		return w
+	}
+	post := func(action, md string) *httptest.ResponseRecorder {
+		return postFrom("http://example.com", action, md)
+	}
+	w := httptest.NewRecorder()
+	ws.router().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(w.Body.String(), "class='editBox'") ||
+		!strings.Contains(w.Body.String(), `var lessonPaths = ["setup"];`) {
+		t.Errorf("page lacks the editor")
+	}
+	const after = "# Setup\n\nNow *twice*.\n\n```\necho one\n```\n\n```\necho two\n```\n"
+
+	w = post("preview", after)
+	if w.Code != http.StatusOK {
+		t.Fatalf("preview: got %d %s", w.Code, w.Body.String())
+	}
+	var preview schema.Preview
+	if err := json.Unmarshal(w.Body.Bytes(), &preview); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(preview.HTML, "<em>twice</em>") ||
+		!strings.Contains(preview.HTML, "echo two") {
+		t.Errorf("preview: got %s", preview.HTML)
+	}
+	var changes []string
+	for _, b := range preview.Blocks {
+		changes = append(changes, b.Change)
+	}
+	if want := []string{blockSame, blockAdded}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("preview: got %v, want %v", changes, want)
+	}
+
+	// Other sites' pages, or requests saying nowhere, can't edit,
+	// though the server needs no tokens.
+	for _, origin := range []string{"", "http://evil.example", "null"} {
+		for _, action := range []string{"preview", "propose"} {
+			if w := postFrom(origin, action, after); w.Code != http.StatusForbidden {
+				t.Errorf("%s from %q: got %d", action, origin, w.Code)
+			}
+		}
+	}
+	if w := post("propose", before); w.Code != http.StatusInternalServerError {
+		t.Errorf("propose of nothing: got %d", w.Code)
+	}
+	w = post("propose", after)
+	if w.Code != http.StatusOK {
+		t.Fatalf("propose: got %d %s", w.Code, w.Body.String())
+	}
+	var p schema.Proposal
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(p.Branch, "mdrip/edit-setup-") {
+		t.Errorf("got branch %s", p.Branch)
+	}
+	if got := git(t, dir, "show", p.Branch+":setup.md"); got != strings.TrimSpace(after) {
+		t.Errorf("branch has %q", got)
+	}
+	if got := git(t, dir, "log", "-1", "--format=%s", p.Branch); got != "Say two" {
+		t.Errorf("got message %q", got)
+	}
+	// The working tree, and what's staged, are as they were.
+	if b, _ := ioutil.ReadFile(lesson); string(b) != before {
+		t.Errorf("working tree has %q", b)
+	}
+	if got := git(t, dir, "status", "--porcelain"); got != "" {
+		t.Errorf("got status %q", got)
+	}
+}
+
+func TestPullRequestURL(t *testing.T) {
+	for remote, want := range map[string]string{
+		"git@github.com:monopole/mdrip.git":   "https://github.com/monopole/mdrip/compare/b?expand=1",
+		"https://github.com/monopole/mdrip":   "https://github.com/monopole/mdrip/compare/b?expand=1",
+		"https://gitlab.com/monopole/mdrip":   "",
+		"https://github.com/monopole/mdrip/x": "",
+	} {
+		if got := pullRequestURL(remote, "b"); got != want {
+			t.Errorf("%s: got %q, want %q", remote, got, want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/webapp"
+)
+
+func TestExtractView(t *testing.T) {
+	ws, _ := serveFiles(t, "", map[string]string{
+		"setup.md": "# Setup\n\n<!-- @install @timeout=90s -->\n```\necho install\n```\n\n" +
+			"<!-- @cleanup -->\n```\necho cleanup\n```\n",
+	})
+	get := func(q string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ws.router().ServeHTTP(w, httptest.NewRequest("GET", "/setup"+q, nil))
+		return w
+	}
+	if w := get(""); strings.Contains(w.Body.String(), "<div class='extractOverlay") {
+		t.Errorf("overlay shown without asking")
+	}
+	w := get("?" + webapp.KeyDebug + "=" + webapp.DebugExtract + "&" + webapp.KeyLabel + "=install")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d", w.Code)
+	}
+	page := w.Body.String()
+	for _, want := range []string{
+		"<code>--label install</code>",
+		"<span class='extractLabel'>@install</span>",
+		"<span class='extractAttribute'>@timeout=90s</span>",
+		"label: has @install",
+		"label: lacks @install, having @cleanup",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page lacks %q", want)
+		}
+	}
+	if n := strings.Count(page, "<div class='extractOverlay extractSkipped'>"); n != 1 {
+		t.Errorf("got %d skipped blocks, want 1", n)
+	}
+	if w := get("?" + webapp.KeyDebug + "=" + webapp.DebugExtract + "&" +
+		webapp.KeyLabel + "=a%20%26%26"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad label: got %d", w.Code)
+	}
+	if w := get("?" + webapp.KeyDebug + "=everything"); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown view: got %d", w.Code)
+	}
+}
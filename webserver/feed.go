@@ -0,0 +1,225 @@
+package webserver
+
+import (
+	"crypto/sha256"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/model"
+	"github.com/monopole/mdrip/program"
+)
+
+// maxFeedEntries is how many of the most recent changes the feed holds.
+const maxFeedEntries = 50
+
+// feedEntry is one change: a lesson added, updated or removed, or
+// a block whose verdict flipped between ok and failed.
+type feedEntry struct {
+	seq     int
+	title   string
+	summary string
+	// path is the URL path of the lesson, relative to the server's
+	// prefix, e.g. "setup/install"; empty if the lesson is gone.
+	path    string
+	updated time.Time
+}
+
+// lessonDigest identifies the content of a lesson.
+type lessonDigest struct {
+	name   string
+	path   string
+	digest [sha256.Size]byte
+}
+
+// lessonDigester visits a tutorial, digesting its lessons by
+// their URL path, the path the web app uses to select them.
+type lessonDigester struct {
+	names   []string
+	lessons map[base.FilePath]lessonDigest
+}
+
+func (v *lessonDigester) VisitBlockTut(b *model.BlockTut) {}
+
+func (v *lessonDigester) VisitLessonTut(l *model.LessonTut) {
+	h := sha256.New()
+	for _, b := range l.Blocks() {
+		h.Write(b.Prose())
+		h.Write(b.Code().Bytes())
+	}
+	d := lessonDigest{
+		name: l.NavName(),
+		path: strings.Join(append(v.names, l.Slug()), "/")}
+	copy(d.digest[:], h.Sum(nil))
+	v.lessons[l.Path()] = d
+}
+
+func (v *lessonDigester) VisitCourse(c *model.Course) {
+	v.names = append(v.names, c.Slug())
+	for _, x := range c.Children() {
+		x.Accept(v)
+	}
+	v.names = v.names[:len(v.names)-1]
+}
+
+func (v *lessonDigester) VisitTopCourse(t *model.TopCourse) {
+	for _, x := range t.Children() {
+		x.Accept(v)
+	}
+}
+
+// changeFeed records changes to a tutorial's lessons across
+// reloads, and to the verdicts of its blocks run in tmux.
+type changeFeed struct {
+	mu       sync.Mutex
+	seq      int
+	entries  []feedEntry
+	lessons  map[base.FilePath]lessonDigest
+	verdicts map[string]blockState
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{verdicts: make(map[string]blockState)}
+}
+
+// add records an entry; the caller holds the lock.
+func (f *changeFeed) add(e feedEntry) {
+	f.seq++
+	e.seq = f.seq
+	f.entries = append([]feedEntry{e}, f.entries...)
+	if len(f.entries) > maxFeedEntries {
+		f.entries = f.entries[:maxFeedEntries]
+	}
+}
+
+// noteTutorial records lessons added, updated or removed since the
+// tutorial was last noted.  The first tutorial noted is the baseline.
+func (f *changeFeed) noteTutorial(t model.Tutorial, now time.Time) {
+	v := &lessonDigester{lessons: make(map[base.FilePath]lessonDigest)}
+	t.Accept(v)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	old := f.lessons
+	f.lessons = v.lessons
+	if old == nil {
+		return
+	}
+	for p, d := range v.lessons {
+		o, ok := old[p]
+		switch {
+		case !ok:
+			f.add(feedEntry{title: "Added " + d.name,
+				summary: fmt.Sprintf("%s was added.", p), path: d.path, updated: now})
+		case o.digest != d.digest:
+			f.add(feedEntry{title: "Updated " + d.name,
+				summary: fmt.Sprintf("%s changed.", p), path: d.path, updated: now})
+		}
+	}
+	for p, o := range old {
+		if _, ok := v.lessons[p]; !ok {
+			f.add(feedEntry{title: "Removed " + o.name,
+				summary: fmt.Sprintf("%s was removed.", p), updated: now})
+		}
+	}
+}
+
+// noteVerdict records a block's state, adding an entry if it flips
+// between ok and failed.  Other states say nothing of whether the
+// block works.
+func (f *changeFeed) noteVerdict(
+	l *program.LessonPgm, blockIndex int, s blockState, now time.Time) {
+	if s != stateOk && s != stateFailed {
+		return
+	}
+	b := l.Blocks()[blockIndex]
+	key := fmt.Sprintf("%s#%d", l.Path(), blockIndex)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	old, ok := f.verdicts[key]
+	f.verdicts[key] = s
+	if !ok || old == s {
+		return
+	}
+	verb := "Broke"
+	if s == stateOk {
+		verb = "Fixed"
+	}
+	path := ""
+	if d, ok := f.lessons[l.Path()]; ok {
+		path = d.path
+	}
+	f.add(feedEntry{
+		title: fmt.Sprintf("%s: %s in %s", verb, b.Name(), l.Name()),
+		summary: fmt.Sprintf(
+			"Block %d (%s) of %s went from %s to %s.",
+			blockIndex+1, b.Name(), l.Path(), old, s),
+		path: path, updated: now})
+}
+
+// recent returns a copy of the entries, newest first.
+func (f *changeFeed) recent() []feedEntry {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]feedEntry{}, f.entries...)
+}
+
+// The Atom syndication format (RFC 4287).
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary"`
+}
+
+// showFeed writes the server's recent changes as an Atom feed.
+func (ws *Server) showFeed(w http.ResponseWriter, r *http.Request) {
+	root := siteURL(r, ws.prefix)
+	entries := ws.feed.recent()
+	f := atomFeed{
+		Title:   "mdrip: " + ws.loader.DataSet().String(),
+		ID:      root,
+		Updated: ws.started.UTC().Format(time.RFC3339),
+		Links:   []atomLink{{root + "_/feed", "self"}, {root, ""}},
+	}
+	if len(entries) > 0 {
+		f.Updated = entries[0].updated.UTC().Format(time.RFC3339)
+	}
+	for _, e := range entries {
+		f.Entries = append(f.Entries, atomEntry{
+			Title: e.title,
+			// Unique across restarts, as the server's start time is in it.
+			ID: fmt.Sprintf("%s_/feed/%s/%s",
+				root, strconv.FormatInt(ws.started.Unix(), 10), strconv.Itoa(e.seq)),
+			Updated: e.updated.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: root + e.path},
+			Summary: e.summary,
+		})
+	}
+	w.Header().Set("Content-Type", "application/atom+xml")
+	fmt.Fprint(w, xml.Header)
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(f); err != nil {
+		write500(w, err)
+	}
+}
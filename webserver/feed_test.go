@@ -0,0 +1,57 @@
+package webserver
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/monopole/mdrip/tmux"
+)
+
+func TestFeed(t *testing.T) {
+	ws, dir := serveFiles(t, "/k8s", map[string]string{
+		"install.md": "<!-- @fetch -->\n```\necho 1\n```\n",
+		"setup.md":   "```\necho 2\n```\n",
+	})
+	write := func(n, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, n), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("install.md", "<!-- @fetch -->\n```\necho 1 changed\n```\n")
+	os.Remove(filepath.Join(dir, "setup.md"))
+	tut, err := ws.loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.setTutorial(tut)
+	ws.setState("s1", "0/0", stateOk, 0)
+	ws.setState("s1", "0/0", stateRunning, tmux.Unknown)
+	ws.setState("s2", "0/0", stateFailed, 1)
+
+	w := httptest.NewRecorder()
+	ws.router().ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/_/feed", nil))
+	var f atomFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &f); err != nil {
+		t.Fatalf("bad feed: %v\n%s", err, w.Body.String())
+	}
+	var titles []string
+	for _, e := range f.Entries {
+		titles = append(titles, e.Title)
+	}
+	want := []string{"Broke: fetch in install", "Removed setup", "Updated install"}
+	if len(titles) != len(want) {
+		t.Fatalf("got entries %v, want %v", titles, want)
+	}
+	for i := range want {
+		if titles[i] != want[i] {
+			t.Errorf("entry %d: got %q, want %q", i, titles[i], want[i])
+		}
+	}
+	if f.Entries[2].Link.Href != "http://example.com/k8s/install" {
+		t.Errorf("got link %s", f.Entries[2].Link.Href)
+	}
+}
@@ -0,0 +1,54 @@
+package webserver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/loader"
+)
+
+// writeFiles writes the files, keyed by their slash-separated
+// paths, into a new directory, removed when the test ends,
+// and returns the directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for n, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(n))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// loadServer returns a server, under the URL path prefix, of
+// the tutorial in the directory, loaded, and configured as
+// NewServer configures it.
+func loadServer(t *testing.T, prefix, dir string) *Server {
+	t.Helper()
+	ds, err := base.NewDataSet([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := newServer(prefix, loader.NewLoader(ds))
+	if err := ws.load(); err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+// serveFiles writes the files, as writeFiles does, and
+// returns a server, as loadServer does, of the tutorial
+// they make, and the directory holding them.
+func serveFiles(t *testing.T, prefix string, files map[string]string) (*Server, string) {
+	t.Helper()
+	dir := writeFiles(t, files)
+	return loadServer(t, prefix, dir), dir
+}
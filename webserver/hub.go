@@ -0,0 +1,174 @@
+package webserver
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/gorilla/mux"
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/diagram"
+	"github.com/monopole/mdrip/loader"
+	"github.com/monopole/mdrip/program"
+	"github.com/monopole/mdrip/tmux"
+	"github.com/monopole/mdrip/transform"
+	"github.com/monopole/mdrip/webapp"
+)
+
+// Hub serves several independent tutorials from one address, each
+// under its own URL path prefix, with a catalog page at the root
+// linking to them.
+type Hub struct {
+	servers []*Server
+	msgs    *webapp.Messages
+}
+
+// NewHub returns a hub serving a tutorial per data set, each
+// configured as NewServer would configure it.
+func NewHub(sets []*base.DataSet) (*Hub, error) {
+	if len(sets) == 0 {
+		return nil, fmt.Errorf("no tutorials to serve")
+	}
+	h := &Hub{[]*Server{}, webapp.DefaultMessages()}
+	for i, n := range prefixNames(sets) {
+		h.servers = append(h.servers, newServer("/"+n, loader.NewLoader(sets[i])))
+	}
+	return h, nil
+}
+
+// Transform has each of the hub's tutorials apply the
+// transforms; see Server.Transform.
+func (h *Hub) Transform(p transform.Pipeline) {
+	for _, s := range h.servers {
+		s.Transform(p)
+	}
+}
+
+// PasteInto has each of the hub's tutorials paste blocks into
+// the multiplexer; see Server.PasteInto.
+func (h *Hub) PasteInto(m tmux.Multiplexer, t tmux.Targets) {
+	for _, s := range h.servers {
+		s.PasteInto(m, t)
+	}
+}
+
+// DrawDiagrams has each of the hub's tutorials draw diagrams
+// with the servers; see Server.DrawDiagrams.
+func (h *Hub) DrawDiagrams(diagrams diagram.Servers) {
+	for _, s := range h.servers {
+		s.DrawDiagrams(diagrams)
+	}
+}
+
+// Translate shows the catalog, and each of the hub's
+// tutorials, in the messages' language; see Server.Translate.
+func (h *Hub) Translate(msgs *webapp.Messages) {
+	h.msgs = msgs
+	for _, s := range h.servers {
+		s.Translate(msgs)
+	}
+}
+
+var unsafeInPrefix = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// prefixName is a name for a tutorial's URL path prefix,
+// from the last element of its data source's path.
+func prefixName(ds *base.DataSet) string {
+	x := ds.FirstArg()
+	n := x.Display()
+	if !x.IsGitRepo() && !x.IsWebFile() {
+		n = filepath.Base(string(x.AbsPath()))
+	}
+	n = path.Base(strings.TrimSuffix(strings.TrimSuffix(n, "/"), ".git"))
+	n = strings.TrimSuffix(n, ".md")
+	n = strings.Trim(unsafeInPrefix.ReplaceAllString(n, "-"), "-.")
+	if len(n) == 0 || n == "_" {
+		return "tutorial"
+	}
+	return n
+}
+
+// prefixNames are distinct prefix names for the data sets.
+func prefixNames(sets []*base.DataSet) []string {
+	result := make([]string, len(sets))
+	seen := map[string]bool{}
+	for i, ds := range sets {
+		n := prefixName(ds)
+		for k := 2; seen[n]; k++ {
+			n = fmt.Sprintf("%s-%d", prefixName(ds), k)
+		}
+		seen[n] = true
+		result[i] = n
+	}
+	return result
+}
+
+// showCatalog lists the hub's tutorials, with facets to filter them.
+func (h *Hub) showCatalog(w http.ResponseWriter, r *http.Request) {
+	entries := make([]webapp.CatalogEntry, len(h.servers))
+	for i, s := range h.servers {
+		entries[i] = webapp.NewCatalogEntry(s.prefix+"/",
+			s.loader.DataSet().FirstArg().Display(), s.currentTutorial(), time.Now())
+	}
+	if err := webapp.RenderCatalog(w, entries, h.msgs); err != nil {
+		write500(w, err)
+	}
+}
+
+// asset serves an image for whichever tutorial it belongs to.
+// Lessons refer to images at program.AssetPath, without a prefix.
+func (h *Hub) asset(w http.ResponseWriter, r *http.Request) {
+	p := filepath.Clean(r.URL.Query().Get(program.AssetParam))
+	for _, s := range h.servers {
+		if filepath.IsAbs(p) && s.isServableAsset(p) {
+			http.ServeFile(w, r, p)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// showMetrics writes the metrics of all the hub's tutorials.
+func (h *Hub) showMetrics(w http.ResponseWriter, r *http.Request) {
+	writeMetrics(w, h.servers)
+}
+
+// router loads the hub's tutorials, and routes requests to them.
+func (h *Hub) router() (*mux.Router, error) {
+	r := mux.NewRouter()
+	r.HandleFunc("/", h.showCatalog)
+	r.HandleFunc(program.AssetPath, h.asset)
+	r.HandleFunc("/favicon.ico", h.servers[0].favicon)
+	r.HandleFunc("/sitemap.xml", h.showSitemapIndex)
+	r.HandleFunc("/metrics", h.showMetrics)
+	for _, s := range h.servers {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+		r.Handle(s.prefix, http.RedirectHandler(s.prefix+"/", http.StatusMovedPermanently))
+		r.PathPrefix(s.prefix + "/").Handler(http.StripPrefix(s.prefix, s.countRequests(s.router())))
+		glog.Infof("serving %s under %s", s.loader.DataSet(), s.prefix)
+	}
+	return r, nil
+}
+
+// Serve offers an http service, over HTTPS if the TLS says
+// so, to those the Auth, if not nil, lets in.
+func (h *Hub) Serve(hostAndPort string, t TLS, a Auth) error {
+	r, err := h.router()
+	if err != nil {
+		return err
+	}
+	if t.IsOn() {
+		for _, s := range h.servers {
+			s.secureCookies()
+		}
+	}
+	glog.Fatal(listenAndServe(hostAndPort, r, t, a))
+	return nil
+}
@@ -0,0 +1,66 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+)
+
+func TestPrefixNames(t *testing.T) {
+	ds, err := base.NewDataSet([]string{
+		"/tmp/k8s-tutorial", "/tmp/other/k8s-tutorial/", "/tmp/istio setup.md",
+		"gh:monopole/mdrip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(prefixNames(ds.Split()), ",")
+	if got != "k8s-tutorial,k8s-tutorial-2,istio-setup,mdrip" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestHub(t *testing.T) {
+	files := map[string]string{}
+	for _, n := range []string{"k8s", "istio"} {
+		files[n+"/intro.md"] = "# About " + n + "\n\n```\necho " + n + "\n```\n"
+	}
+	dir := writeFiles(t, files)
+	ds, err := base.NewDataSet([]string{
+		filepath.Join(dir, "k8s"), filepath.Join(dir, "istio")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHub(ds.Split())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := h.router()
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(p string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", p, nil))
+		return w
+	}
+	index := get("/").Body.String()
+	for _, want := range []string{"href='/k8s/'", "href='/istio/'", "About k8s"} {
+		if !strings.Contains(index, want) {
+			t.Errorf("index lacks %q:\n%s", want, index)
+		}
+	}
+	if w := get("/istio"); w.Code != http.StatusMovedPermanently {
+		t.Errorf("got %d for /istio", w.Code)
+	}
+	page := get("/istio/").Body.String()
+	if !strings.Contains(page, "echo istio") || strings.Contains(page, "echo k8s") {
+		t.Errorf("/istio/ should show only its own tutorial")
+	}
+	if !strings.Contains(page, `'\/istio/_/runblock'`) {
+		t.Errorf("/istio/ page doesn't post blocks under its prefix")
+	}
+}
@@ -0,0 +1,35 @@
+package webserver
+
+import (
+	"net/http"
+
+	"github.com/gorilla/sessions"
+	"github.com/monopole/mdrip/model"
+	"github.com/monopole/mdrip/webapp"
+)
+
+// sessionLang is the language of the lessons the session
+// chose, with KeyLang, else that of the loaded tutorial.
+func (ws *Server) sessionLang(s *sessions.Session) string {
+	if lang, ok := s.Values[webapp.KeyLang].(string); ok {
+		return lang
+	}
+	return ws.loader.DataSet().Lang()
+}
+
+// langOf is the language of the lessons the request's
+// session shows, so that blocks it asks to run, or to
+// download, are those it showed.
+func (ws *Server) langOf(r *http.Request) string {
+	session, err := ws.store.Get(r, cookieName)
+	if err != nil {
+		return ws.loader.DataSet().Lang()
+	}
+	return ws.sessionLang(session)
+}
+
+// tutorialIn is the tutorial with its lessons in the
+// given language, where they're translated into it.
+func (ws *Server) tutorialIn(lang string) model.Tutorial {
+	return model.InLang(ws.currentTutorial(), lang)
+}
@@ -0,0 +1,165 @@
+package webserver
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// serverMetrics counts what a server has done, for /metrics.
+type serverMetrics struct {
+	mu sync.Mutex
+	// requests maps HTTP status codes to how many responses had them.
+	requests map[int]int64
+	// blocksRun is how many blocks have been sent to a shell.
+	blocksRun int64
+	// blocksFailed is how many blocks have finished failing.
+	blocksFailed int64
+}
+
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{requests: map[int]int64{}}
+}
+
+func (m *serverMetrics) noteRequest(code int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[code]++
+}
+
+// noteState counts a block's change of state.
+func (m *serverMetrics) noteState(s blockState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch s {
+	case stateRunning:
+		m.blocksRun++
+	case stateFailed:
+		m.blocksFailed++
+	}
+}
+
+// statusRecorder remembers the status code written through it.
+// It lets websocket upgrades through.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%T can't be hijacked", r.ResponseWriter)
+	}
+	// A hijacked connection is a websocket's, switching protocols.
+	r.code = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// countRequests wraps a handler, counting its responses by status code.
+func (ws *Server) countRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{w, http.StatusOK}
+		h.ServeHTTP(rec, r)
+		ws.metrics.noteRequest(rec.code)
+	})
+}
+
+// showMetrics writes the server's metrics in the
+// Prometheus text format, for scraping.
+func (ws *Server) showMetrics(w http.ResponseWriter, r *http.Request) {
+	writeMetrics(w, []*Server{ws})
+}
+
+// writeMetrics writes the metrics of the servers in the Prometheus
+// text format.  Those of servers under a prefix, as in a hub,
+// are labelled with it, e.g. tutorial="/k8s".
+func writeMetrics(w http.ResponseWriter, servers []*Server) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metric := func(name, kind, help string, value func(s *Server) []sample) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, s := range servers {
+			for _, x := range value(s) {
+				labels := x.labels
+				if len(s.prefix) > 0 {
+					labels = append([]string{"tutorial", s.prefix}, labels...)
+				}
+				fmt.Fprintf(w, "%s%s %d\n", name, formatLabels(labels), x.value)
+			}
+		}
+	}
+	metric("mdrip_http_requests_total", "counter",
+		"HTTP requests served, by status code.",
+		func(s *Server) []sample {
+			s.metrics.mu.Lock()
+			defer s.metrics.mu.Unlock()
+			var codes []int
+			for c := range s.metrics.requests {
+				codes = append(codes, c)
+			}
+			sort.Ints(codes)
+			var result []sample
+			for _, c := range codes {
+				result = append(result, sample{
+					[]string{"code", strconv.Itoa(c)}, s.metrics.requests[c]})
+			}
+			return result
+		})
+	metric("mdrip_websocket_sessions", "gauge",
+		"Sessions with a websocket to a shell open.",
+		func(s *Server) []sample {
+			s.connMu.Lock()
+			defer s.connMu.Unlock()
+			return []sample{{nil, int64(len(s.connections))}}
+		})
+	metric("mdrip_blocks_run_total", "counter",
+		"Blocks sent to a shell to run.",
+		func(s *Server) []sample {
+			s.metrics.mu.Lock()
+			defer s.metrics.mu.Unlock()
+			return []sample{{nil, s.metrics.blocksRun}}
+		})
+	metric("mdrip_block_failures_total", "counter",
+		"Blocks that finished failing, or timed out.",
+		func(s *Server) []sample {
+			s.metrics.mu.Lock()
+			defer s.metrics.mu.Unlock()
+			return []sample{{nil, s.metrics.blocksFailed}}
+		})
+}
+
+// sample is a value of a metric, with
+// its labels as alternating names and values.
+type sample struct {
+	labels []string
+	value  int64
+}
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	result := "{"
+	for i := 0; i < len(labels); i += 2 {
+		if i > 0 {
+			result += ","
+		}
+		result += labels[i] + "=" + strconv.Quote(labels[i+1])
+	}
+	return result + "}"
+}
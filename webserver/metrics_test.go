@@ -0,0 +1,46 @@
+package webserver
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/tmux"
+	"github.com/monopole/mdrip/webapp"
+)
+
+func TestMetrics(t *testing.T) {
+	ws := newServer("", nil)
+	h := ws.countRequests(ws.router())
+	get := func(p string) string {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", p, nil))
+		return w.Body.String()
+	}
+	get("/_/status?" + webapp.KeySessID + "=s1")
+	get("/_/status")
+	ws.setState("s1", "0/0", stateRunning, tmux.Unknown)
+	ws.setState("s1", "0/0", stateOk, 0)
+	ws.setState("s1", "0/1", stateRunning, tmux.Unknown)
+	ws.setState("s1", "0/1", stateFailed, 2)
+	got := get("/metrics")
+	for _, want := range []string{
+		"# TYPE mdrip_http_requests_total counter\n",
+		`mdrip_http_requests_total{code="200"} 1` + "\n",
+		`mdrip_http_requests_total{code="400"} 1` + "\n",
+		"mdrip_websocket_sessions 0\n",
+		"mdrip_blocks_run_total 2\n",
+		"mdrip_block_failures_total 1\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got\n%s\nwant it to hold %q", got, want)
+		}
+	}
+
+	ws.prefix = "/k8s"
+	w := httptest.NewRecorder()
+	writeMetrics(w, []*Server{ws})
+	if want := `mdrip_http_requests_total{tutorial="/k8s",code="200"} 2`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("got\n%s\nwant it to hold %q", w.Body.String(), want)
+	}
+}
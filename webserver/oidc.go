@@ -0,0 +1,211 @@
+package webserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/websocket"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// oidcCallbackPath is where the issuer sends users back
+	// to, after they log in, with a code proving they did.
+	oidcCallbackPath = "/_/oidc/callback"
+	// oidcCookieName is the cookie holding a logged in user's email.
+	oidcCookieName = "mdrip-oidc"
+	// oidcStateCookieName is the cookie holding what a
+	// login in progress needs to check its callback.
+	oidcStateCookieName = "mdrip-oidc-state"
+	// oidcLoginTTL is how long a login lasts; as long as a session.
+	oidcLoginTTL = 8 * time.Hour
+	// oidcStateTTL is how long a user has to log in.
+	oidcStateTTL = 10 * time.Minute
+)
+
+// NewOIDCAuth returns an Auth sending those who haven't logged
+// in to the OpenID Connect issuer, e.g. https://accounts.google.com,
+// to do so, mdrip being the client with the given id and secret.
+// If allow isn't empty, only users whose verified email is in it,
+// or has a domain in it, e.g. @example.com, get in.  The issuer
+// must accept {scheme}://{host}/_/oidc/callback as a redirect URL.
+func NewOIDCAuth(issuer, clientID, clientSecret string, allow []string) (Auth, error) {
+	p, err := oidc.NewProvider(context.Background(), issuer)
+	if err != nil {
+		return nil, fmt.Errorf("OIDC issuer %s: %v", issuer, err)
+	}
+	return &oidcAuth{
+		p.Verifier(&oidc.Config{ClientID: clientID}),
+		oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Endpoint:     p.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "email"},
+		},
+		allow,
+		newCodec(oidcLoginTTL),
+		newCodec(oidcStateTTL),
+	}, nil
+}
+
+type oidcAuth struct {
+	verifier *oidc.IDTokenVerifier
+	oauth    oauth2.Config
+	allow    []string
+	// logins and states sign and encrypt the cookies;
+	// their keys last as long as the process does.
+	logins *securecookie.SecureCookie
+	states *securecookie.SecureCookie
+}
+
+// oidcState is what a login in progress needs to check its callback.
+type oidcState struct {
+	State, Nonce string
+	// Then is where the user was going.
+	Then string
+}
+
+func newCodec(ttl time.Duration) *securecookie.SecureCookie {
+	return securecookie.New(
+		securecookie.GenerateRandomKey(32),
+		securecookie.GenerateRandomKey(32)).MaxAge(int(ttl.Seconds()))
+}
+
+func (a *oidcAuth) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == oidcCallbackPath {
+			a.callback(w, r)
+			return
+		}
+		if c, err := r.Cookie(oidcCookieName); err == nil {
+			var email string
+			if a.logins.Decode(oidcCookieName, c.Value, &email) == nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+		if r.Method != http.MethodGet || websocket.IsWebSocketUpgrade(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		a.login(w, r)
+	})
+}
+
+// config is the client's config, with the URL of the callback
+// on the host the request came to.
+func (a *oidcAuth) config(r *http.Request) *oauth2.Config {
+	c := a.oauth
+	c.RedirectURL = scheme(r) + "://" + r.Host + oidcCallbackPath
+	return &c
+}
+
+// login sends the user to the issuer to log in.
+func (a *oidcAuth) login(w http.ResponseWriter, r *http.Request) {
+	st := oidcState{randomString(), randomString(), r.URL.RequestURI()}
+	v, err := a.states.Encode(oidcStateCookieName, st)
+	if err != nil {
+		write500(w, err)
+		return
+	}
+	setCookie(w, r, oidcStateCookieName, v, oidcStateTTL)
+	http.Redirect(w, r,
+		a.config(r).AuthCodeURL(st.State, oidc.Nonce(st.Nonce)), http.StatusFound)
+}
+
+// callback checks that the user the issuer sent back logged
+// in, and may get in, then sends them where they were going.
+func (a *oidcAuth) callback(w http.ResponseWriter, r *http.Request) {
+	var st oidcState
+	c, err := r.Cookie(oidcStateCookieName)
+	if err == nil {
+		err = a.states.Decode(oidcStateCookieName, c.Value, &st)
+	}
+	q := r.URL.Query()
+	if err != nil || q.Get("state") != st.State {
+		http.Error(w, "no login in progress", http.StatusBadRequest)
+		return
+	}
+	if e := q.Get("error"); len(e) > 0 {
+		http.Error(w, "login failed: "+e, http.StatusForbidden)
+		return
+	}
+	t, err := a.config(r).Exchange(r.Context(), q.Get("code"))
+	if err != nil {
+		http.Error(w, "login failed: "+err.Error(), http.StatusForbidden)
+		return
+	}
+	raw, _ := t.Extra("id_token").(string)
+	idt, err := a.verifier.Verify(r.Context(), raw)
+	if err == nil && idt.Nonce != st.Nonce {
+		err = fmt.Errorf("nonce mismatch")
+	}
+	if err != nil {
+		http.Error(w, "login failed: "+err.Error(), http.StatusForbidden)
+		return
+	}
+	var claims struct {
+		Email    string `json:"email"`
+		Verified bool   `json:"email_verified"`
+	}
+	if err := idt.Claims(&claims); err != nil {
+		write500(w, err)
+		return
+	}
+	if !a.allows(claims.Email, claims.Verified) {
+		http.Error(w, claims.Email+" may not get in", http.StatusForbidden)
+		return
+	}
+	v, err := a.logins.Encode(oidcCookieName, claims.Email)
+	if err != nil {
+		write500(w, err)
+		return
+	}
+	setCookie(w, r, oidcCookieName, v, oidcLoginTTL)
+	setCookie(w, r, oidcStateCookieName, "", -1)
+	then := st.Then
+	if !strings.HasPrefix(then, "/") || strings.HasPrefix(then, "//") {
+		then = "/"
+	}
+	http.Redirect(w, r, then, http.StatusFound)
+}
+
+// allows is true if the user with the given email may get in.
+func (a *oidcAuth) allows(email string, verified bool) bool {
+	if len(a.allow) == 0 {
+		return true
+	}
+	if !verified {
+		return false
+	}
+	email = strings.ToLower(email)
+	for _, x := range a.allow {
+		x = strings.ToLower(x)
+		if email == x || (strings.HasPrefix(x, "@") && strings.HasSuffix(email, x)) {
+			return true
+		}
+	}
+	return false
+}
+
+// setCookie sets a cookie for the whole host; a negative ttl deletes it.
+// The cookie survives the redirect back from the issuer, being sent
+// on top level navigations from other sites.
+func setCookie(w http.ResponseWriter, r *http.Request, name, value string, ttl time.Duration) {
+	http.SetCookie(w, &http.Cookie{
+		Name: name, Value: value, Path: "/", MaxAge: int(ttl.Seconds()),
+		HttpOnly: true, Secure: r.TLS != nil, SameSite: http.SameSiteLaxMode})
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,145 @@
+package webserver
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// fakeIssuer is an OpenID Connect issuer logging in whoever
+// asks as email; the nonce it's asked for doubles as the code.
+func fakeIssuer(t *testing.T, email *string) *httptest.Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s *httptest.Server
+	m := http.NewServeMux()
+	m.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                s.URL,
+			"authorization_endpoint":                s.URL + "/auth",
+			"token_endpoint":                        s.URL + "/token",
+			"jwks_uri":                              s.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	m.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, Algorithm: "RS256", Use: "sig"}}})
+	})
+	m.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := json.Marshal(map[string]interface{}{
+			"iss": s.URL, "aud": "mdrip", "sub": "1",
+			"iat": time.Now().Unix(), "exp": time.Now().Add(time.Hour).Unix(),
+			"nonce": r.FormValue("code"), "email": *email, "email_verified": true,
+		})
+		jws, err := signer.Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		idt, _ := jws.CompactSerialize()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "a", "token_type": "Bearer", "id_token": idt})
+	})
+	s = httptest.NewServer(m)
+	return s
+}
+
+func TestOIDCAuth(t *testing.T) {
+	email := "ann@example.com"
+	issuer := fakeIssuer(t, &email)
+	defer issuer.Close()
+	a, err := NewOIDCAuth(issuer.URL, "mdrip", "secret", []string{"@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := a.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(r *http.Request, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	// login returns the response to the callback, after starting
+	// a login going to the path, and the login's state cookie.
+	login := func(path string) *httptest.ResponseRecorder {
+		w := serve(httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusFound {
+			t.Fatalf("got %d, want a redirect to log in", w.Code)
+		}
+		to, err := url.Parse(w.Header().Get("Location"))
+		if err != nil || to.Path != "/auth" {
+			t.Fatalf("redirected to %s", w.Header().Get("Location"))
+		}
+		q := to.Query()
+		return serve(httptest.NewRequest("GET", oidcCallbackPath+"?"+url.Values{
+			"state": {q.Get("state")}, "code": {q.Get("nonce")}}.Encode(), nil),
+			w.Result().Cookies()...)
+	}
+
+	if w := serve(httptest.NewRequest("POST", "/_/runblock", nil)); w.Code != http.StatusUnauthorized {
+		t.Errorf("post: got %d", w.Code)
+	}
+	if w := serve(httptest.NewRequest("GET", oidcCallbackPath+"?state=x&code=y", nil)); w.Code != http.StatusBadRequest {
+		t.Errorf("callback without login: got %d", w.Code)
+	}
+	w := login("/k8s?tok=1")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/k8s?tok=1" {
+		t.Fatalf("callback: got %d to %s %s", w.Code, w.Header().Get("Location"), w.Body.String())
+	}
+	var in *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == oidcCookieName {
+			in = c
+		}
+	}
+	if in == nil {
+		t.Fatal("no login cookie")
+	}
+	if w := serve(httptest.NewRequest("POST", "/_/runblock", nil), in); w.Code != http.StatusOK {
+		t.Errorf("logged in: got %d", w.Code)
+	}
+
+	email = "bob@example.org"
+	if w := login("/"); w.Code != http.StatusForbidden {
+		t.Errorf("disallowed: got %d", w.Code)
+	}
+}
+
+func TestOIDCAllows(t *testing.T) {
+	a := &oidcAuth{allow: []string{"ann@example.com", "@Example.ORG"}}
+	var tests = map[string]struct {
+		email    string
+		verified bool
+		want     bool
+	}{
+		"listed":     {"Ann@example.com", true, true},
+		"domain":     {"bob@example.org", true, true},
+		"unverified": {"ann@example.com", false, false},
+		"unlisted":   {"bob@example.com", true, false},
+		"lookalike":  {"bob@notexample.org", true, false},
+	}
+	for n, test := range tests {
+		if got := a.allows(test.email, test.verified); got != test.want {
+			t.Errorf("%s: got %v", n, got)
+		}
+	}
+	if !(&oidcAuth{}).allows("anyone@anywhere", false) {
+		t.Errorf("no allow list should let anyone in")
+	}
+}
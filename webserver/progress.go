@@ -0,0 +1,77 @@
+package webserver
+
+import (
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/program"
+	"github.com/monopole/mdrip/webapp"
+)
+
+// KeepProgress keeps the state of the blocks each session has
+// run in the file at path, starting with those it holds, so
+// that progress outlives the server.
+func (ws *Server) KeepProgress(path string) error {
+	return ws.statuses.keepIn(path)
+}
+
+// KeepProgress keeps each of the hub's tutorials' progress
+// in a file of its own, named for path and the tutorial's
+// prefix, e.g. progress-k8s.json; see Server.KeepProgress.
+func (h *Hub) KeepProgress(path string) error {
+	ext := filepath.Ext(path)
+	for _, s := range h.servers {
+		p := strings.TrimSuffix(path, ext) + "-" + strings.TrimPrefix(s.prefix, "/") + ext
+		if err := s.KeepProgress(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// showProgress writes a page showing how far each
+// session has made it through the tutorial, most
+// recently active first.
+func (ws *Server) showProgress(w http.ResponseWriter, r *http.Request) {
+	t := ws.currentTutorial()
+	lessons := program.NewProgramFromTutorial(base.WildCardLabel, t).Lessons()
+	var paths []string
+	var blocks []int
+	for i, page := range webapp.LessonPages(t) {
+		n := 0
+		if i < len(lessons) {
+			for _, b := range lessons[i].Blocks() {
+				if len(b.Code()) > 0 {
+					n++
+				}
+			}
+		}
+		paths = append(paths, page.Path)
+		blocks = append(blocks, n)
+	}
+	var rows []webapp.ProgressRow
+	for s, x := range ws.statuses.sessions() {
+		row := webapp.ProgressRow{
+			Session: s, LastActive: x.Updated, Done: make([]int, len(paths))}
+		for i := range paths {
+			for j := 0; i < len(lessons) && j < len(lessons[i].Blocks()); j++ {
+				switch x.Blocks[blockKey(i, j)] {
+				case stateOk, stateSent:
+					row.Done[i]++
+				case stateFailed:
+					row.Failed++
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].LastActive.After(rows[j].LastActive)
+	})
+	if err := webapp.RenderProgress(w, ws.title(), paths, blocks, rows, ws.msgs); err != nil {
+		write500(w, err)
+	}
+}
@@ -0,0 +1,46 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/tmux"
+)
+
+func TestShowProgress(t *testing.T) {
+	ws, _ := serveFiles(t, "", map[string]string{
+		"install.md": "# Install\n\n```\necho 1\n```\n\n```\necho 2\n```\n",
+		"setup.md":   "```\necho 3\n```\n",
+	})
+	ws.RequireTokens("zebra")
+	ws.setState("s1", blockKey(0, 0), stateOk, 0)
+	ws.setState("s1", blockKey(0, 1), stateOk, 0)
+	ws.setState("s1", blockKey(1, 0), stateFailed, 1)
+	ws.setState("s2", blockKey(0, 1), stateSent, tmux.Unknown)
+
+	w := httptest.NewRecorder()
+	ws.router().ServeHTTP(w, httptest.NewRequest("GET", "/progress", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("got status %d without a token", w.Code)
+	}
+	ws.RequireTokens("")
+	w = httptest.NewRecorder()
+	ws.router().ServeHTTP(w, httptest.NewRequest("GET", "/progress", nil))
+	got := w.Body.String()
+	for _, want := range []string{
+		"<th> install </th>",
+		"<td class='done'> 2/2 </td>",
+		"<td> 0/1 </td>",
+		"1 &#x2717;",
+		"<td> 1/2 </td>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got\n%s\nwant it to hold %q", got, want)
+		}
+	}
+	if strings.Index(got, "<code>s2</code>") > strings.Index(got, "<code>s1</code>") {
+		t.Errorf("s2, the most recently active, should come first:\n%s", got)
+	}
+}
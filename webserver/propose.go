@@ -0,0 +1,141 @@
+package webserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/monopole/mdrip/model"
+	"github.com/monopole/mdrip/schema"
+)
+
+// Proposer submits an edit of a lesson's markdown for review.
+type Proposer interface {
+	// Propose submits the content as the new content of
+	// the file, described by the message.
+	Propose(file, content, message string) (*schema.Proposal, error)
+}
+
+// NewGitProposer returns a Proposer committing each edit to a
+// new branch of the git repository holding the edited file,
+// leaving the repository's working tree and index alone.  If
+// remote, e.g. origin, isn't empty, it pushes the branch there.
+// Commits are made by git's configured user.
+func NewGitProposer(remote string) Proposer {
+	return &gitProposer{remote}
+}
+
+type gitProposer struct {
+	remote string
+}
+
+// Propose stages the edit in a scratch index, so that it
+// can commit it without disturbing the working tree.
+func (p *gitProposer) Propose(file, content, message string) (*schema.Proposal, error) {
+	abs, err := filepath.Abs(file)
+	if err == nil {
+		abs, err = filepath.EvalSymlinks(abs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(abs)
+	top, err := runGit(dir, nil, "", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(top, abs)
+	if err != nil {
+		return nil, err
+	}
+	rel = filepath.ToSlash(rel)
+	if len(strings.TrimSpace(message)) == 0 {
+		message = "Edit " + rel
+	}
+	index, err := ioutil.TempFile("", "mdrip-index-")
+	if err != nil {
+		return nil, err
+	}
+	index.Close()
+	// git makes the index itself; it rejects an empty file.
+	os.Remove(index.Name())
+	defer os.Remove(index.Name())
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+	if _, err := runGit(dir, env, "", "read-tree", "HEAD"); err != nil {
+		return nil, err
+	}
+	blob, err := runGit(dir, nil, content, "hash-object", "-w", "--stdin")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := runGit(dir, env, "",
+		"update-index", "--add", "--cacheinfo", "100644,"+blob+","+rel); err != nil {
+		return nil, err
+	}
+	tree, err := runGit(dir, env, "", "write-tree")
+	if err != nil {
+		return nil, err
+	}
+	if head, err := runGit(dir, nil, "", "rev-parse", "HEAD^{tree}"); err != nil {
+		return nil, err
+	} else if head == tree {
+		return nil, errors.New("the edit changes nothing")
+	}
+	commit, err := runGit(dir, nil, message+"\n", "commit-tree", tree, "-p", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	branch := fmt.Sprintf("mdrip/edit-%s-%d",
+		model.Slugify(strings.TrimSuffix(filepath.Base(rel), filepath.Ext(rel))),
+		time.Now().Unix())
+	// The empty old value refuses to move a branch that already exists.
+	if _, err := runGit(dir, nil, "", "update-ref", "refs/heads/"+branch, commit, ""); err != nil {
+		return nil, err
+	}
+	if len(p.remote) == 0 {
+		return schema.NewProposal(branch, commit, ""), nil
+	}
+	if _, err := runGit(dir, nil, "", "push", p.remote, "refs/heads/"+branch); err != nil {
+		return nil, err
+	}
+	remoteURL, err := runGit(dir, nil, "", "remote", "get-url", p.remote)
+	if err != nil {
+		return nil, err
+	}
+	return schema.NewProposal(branch, commit, pullRequestURL(remoteURL, branch)), nil
+}
+
+var gitHubRemote = regexp.MustCompile(`github\.com[:/]([^/]+/[^/]+?)(?:\.git)?/?$`)
+
+// pullRequestURL returns the URL of a page opening a pull
+// request of the branch, if the remote is on GitHub, else "".
+func pullRequestURL(remoteURL, branch string) string {
+	m := gitHubRemote.FindStringSubmatch(remoteURL)
+	if m == nil {
+		return ""
+	}
+	return "https://github.com/" + m[1] + "/compare/" + branch + "?expand=1"
+}
+
+// runGit runs git in the directory, with the extra
+// environment and stdin, returning its trimmed stdout.
+func runGit(dir string, env []string, stdin string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var out, stdErr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stdErr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stdErr.String()))
+	}
+	return strings.TrimSpace(out.String()), nil
+}
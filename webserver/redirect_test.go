@@ -0,0 +1,41 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedirectOrNotFound(t *testing.T) {
+	ws, _ := serveFiles(t, "/k8s", map[string]string{
+		"intro.md":              "# Intro\n",
+		"install/linux.md":      "# Linux\n",
+		"REDIRECTS.txt":         "setup -> install/linux\n",
+		"install/REDIRECTS.txt": "ubuntu linux\n",
+	})
+	get := func(p string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ws.router().ServeHTTP(w, httptest.NewRequest("GET", p, nil))
+		return w
+	}
+	for _, p := range []string{"/", "/intro", "/install/linux", "/install/"} {
+		if w := get(p); w.Code != http.StatusOK {
+			t.Errorf("%s: got %d", p, w.Code)
+		}
+	}
+	for p, want := range map[string]string{
+		"/setup":          "/k8s/install/linux",
+		"/install/ubuntu": "/k8s/install/linux",
+	} {
+		w := get(p)
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != want {
+			t.Errorf("%s: got %d to %q, want %s", p, w.Code, w.Header().Get("Location"), want)
+		}
+	}
+	w := get("/instal/linus")
+	if w.Code != http.StatusNotFound ||
+		!strings.Contains(w.Body.String(), "<a href='/k8s/install/linux'> Linux </a>") {
+		t.Errorf("got %d\n%s", w.Code, w.Body.String())
+	}
+}
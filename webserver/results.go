@@ -0,0 +1,152 @@
+package webserver
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/gorilla/websocket"
+	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/program"
+	"github.com/monopole/mdrip/schema"
+	"github.com/monopole/mdrip/tmux"
+	"github.com/monopole/mdrip/webapp"
+)
+
+// How often to push the output of a running block to the browser.
+const outputInterval = 300 * time.Millisecond
+
+// resultWatchers holds, per session, the browser's websocket
+// receiving schema.Output documents about blocks sent to tmux.
+// Unlike the connections to remote tmux, these only carry
+// documents from the server to the browser.
+type resultWatchers struct {
+	mu    sync.Mutex
+	conns map[webapp.TypeSessID]*websocket.Conn
+}
+
+func newResultWatchers() *resultWatchers {
+	return &resultWatchers{conns: make(map[webapp.TypeSessID]*websocket.Conn)}
+}
+
+// add makes c the session's watcher, closing any it replaces.
+func (r *resultWatchers) add(s webapp.TypeSessID, c *websocket.Conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.conns[s]; ok {
+		old.Close()
+	}
+	r.conns[s] = c
+}
+
+// remove drops c, if it's still the session's watcher.
+func (r *resultWatchers) remove(s webapp.TypeSessID, c *websocket.Conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conns[s] == c {
+		delete(r.conns, s)
+	}
+	c.Close()
+}
+
+// publish sends a document to the session's watcher, if it has one.
+func (r *resultWatchers) publish(s webapp.TypeSessID, doc interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.conns[s]
+	if !ok {
+		return
+	}
+	if err := c.WriteJSON(doc); err != nil {
+		glog.Infof("dropping results socket of session %v: %v", s, err)
+		c.Close()
+		delete(r.conns, s)
+	}
+}
+
+// openResults upgrades the request to a websocket on which the
+// session's browser is told of the output and state of its blocks.
+func (ws *Server) openResults(w http.ResponseWriter, r *http.Request) {
+	sessID, ok := getSessID(w, r)
+	if !ok {
+		return
+	}
+	c, err := ws.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		glog.Errorf("unable to upgrade results for session %v: %v", sessID, err)
+		return
+	}
+	ws.results.add(sessID, c)
+	// The browser sends nothing; reading notices when it goes away.
+	go func() {
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				ws.results.remove(sessID, c)
+				return
+			}
+		}
+	}()
+}
+
+// setState records the state of a block, and tells the session's
+// browser, with the block's exit status if that's known.
+func (ws *Server) setState(
+	sessID webapp.TypeSessID, key string, s blockState, exitStatus int) {
+	elapsed := ws.statuses.set(sessID, key, s)
+	ws.metrics.noteState(s)
+	doc := schema.NewOutputState(key, string(s), exitStatus)
+	doc.Seconds = elapsed
+	ws.results.publish(sessID, doc)
+	if ws.class != nil {
+		ws.class.noteBlock(sessID, key, s)
+	}
+	if s == stateOk || s == stateFailed {
+		ws.noteVerdict(key, s)
+	}
+}
+
+// noteVerdict tells the feed the verdict on the block with the
+// given key, if it's still in the tutorial.
+func (ws *Server) noteVerdict(key string, s blockState) {
+	var lessonIndex, blockIndex int
+	t := ws.currentTutorial()
+	if _, err := fmt.Sscanf(key, "%d/%d", &lessonIndex, &blockIndex); err != nil ||
+		t == nil {
+		return
+	}
+	p := program.NewProgramFromTutorial(base.WildCardLabel, t)
+	if lessonIndex < len(p.Lessons()) &&
+		blockIndex < len(p.Lessons()[lessonIndex].Blocks()) {
+		ws.feed.noteVerdict(p.Lessons()[lessonIndex], blockIndex, s, time.Now())
+	}
+}
+
+// follow pushes the output captured from a block to the session's
+// browser as it arrives, until told to stop, then pushes the rest.
+func (ws *Server) follow(
+	sessID webapp.TypeSessID, key string, c *tmux.Completion, stop chan struct{}) {
+	tick := time.NewTicker(outputInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			ws.publishCaptured(sessID, key, c)
+			return
+		case <-tick.C:
+			ws.publishCaptured(sessID, key, c)
+		}
+	}
+}
+
+func (ws *Server) publishCaptured(
+	sessID webapp.TypeSessID, key string, c *tmux.Completion) {
+	stdout, stderr := c.Captured()
+	if len(stdout) > 0 {
+		ws.results.publish(sessID, schema.NewOutput(key, "stdout", string(stdout)))
+	}
+	if len(stderr) > 0 {
+		ws.results.publish(sessID, schema.NewOutput(key, "stderr", string(stderr)))
+	}
+}
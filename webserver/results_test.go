@@ -0,0 +1,62 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/monopole/mdrip/schema"
+	"github.com/monopole/mdrip/tmux"
+	"github.com/monopole/mdrip/webapp"
+)
+
+func TestResults(t *testing.T) {
+	ws := &Server{
+		statuses: newStatusTracker(), results: newResultWatchers(),
+		metrics: newServerMetrics()}
+	srv := httptest.NewServer(http.HandlerFunc(ws.openResults))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") +
+		"/_/results?" + webapp.KeySessID + "=s1"
+	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	// The server adds the watcher after the upgrade; wait for it.
+	for i := 0; ; i++ {
+		ws.results.mu.Lock()
+		_, ok := ws.results.conns["s1"]
+		ws.results.mu.Unlock()
+		if ok {
+			break
+		}
+		if i > 100 {
+			t.Fatal("no watcher added")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	ws.setState("s1", "0/2", stateRunning, tmux.Unknown)
+	ws.setState("s2", "0/2", stateOk, 0)
+	ws.setState("s1", "0/2", stateFailed, 3)
+	var got []schema.Output
+	for i := 0; i < 2; i++ {
+		var x schema.Output
+		if err := c.ReadJSON(&x); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, x)
+	}
+	if got[0].State != "running" || got[0].ExitStatus != nil {
+		t.Errorf("unexpected first document %+v", got[0])
+	}
+	if got[1].State != "failed" || got[1].ExitStatus == nil || *got[1].ExitStatus != 3 {
+		t.Errorf("unexpected second document %+v", got[1])
+	}
+	if ws.statuses.get("s2")["0/2"] != stateOk {
+		t.Errorf("state of unwatched session not recorded")
+	}
+}
@@ -0,0 +1,25 @@
+package webserver
+
+import (
+	"net/http"
+
+	"github.com/monopole/mdrip/schema"
+	"github.com/monopole/mdrip/webapp"
+)
+
+// search writes a schema.Search document listing
+// the lessons holding the words of the query.
+func (ws *Server) search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get(webapp.KeySearch)
+	var hits []schema.SearchHit
+	if index := ws.searchIndex(); index != nil {
+		for _, h := range index.Search(q) {
+			hits = append(hits, schema.SearchHit{
+				Path: h.Path, Title: h.Title, Lesson: h.Lesson, Snippet: h.Snippet})
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := schema.Write(w, schema.NewSearch(q, hits)); err != nil {
+		write500(w, err)
+	}
+}
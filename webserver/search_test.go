@@ -0,0 +1,31 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/monopole/mdrip/schema"
+	"github.com/monopole/mdrip/webapp"
+)
+
+func TestSearch(t *testing.T) {
+	ws, _ := serveFiles(t, "", map[string]string{
+		"intro.md": "# Intro\n\nWelcome.\n",
+		"setup.md": "# Setup\n\n```\nkubectl version\n```\n",
+	})
+	w := httptest.NewRecorder()
+	ws.router().ServeHTTP(w, httptest.NewRequest("GET", "/search?"+webapp.KeySearch+"=kube", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d", w.Code)
+	}
+	var doc schema.Search
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Kind != schema.KindSearch || doc.Query != "kube" ||
+		len(doc.Hits) != 1 || doc.Hits[0].Path != "setup" || doc.Hits[0].Lesson != 1 {
+		t.Errorf("got %+v", doc)
+	}
+}
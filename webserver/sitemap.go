@@ -0,0 +1,71 @@
+package webserver
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+
+	"github.com/monopole/mdrip/webapp"
+)
+
+// The sitemap protocol, https://www.sitemaps.org/protocol.html.
+const sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	NS      string       `xml:"xmlns,attr"`
+	URLs    []sitemapLoc `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	NS       string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// scheme is the scheme the request came in on.
+func scheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// siteURL is the absolute URL of what's served
+// under the given prefix, ending in a slash.
+func siteURL(r *http.Request, prefix string) string {
+	return scheme(r) + "://" + r.Host + prefix + "/"
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprint(w, xml.Header)
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(v); err != nil {
+		write500(w, err)
+	}
+}
+
+// showSitemap lists the canonical URL of every lesson's page.
+func (ws *Server) showSitemap(w http.ResponseWriter, r *http.Request) {
+	root := siteURL(r, ws.prefix)
+	m := sitemapURLSet{NS: sitemapNamespace}
+	for _, p := range webapp.LessonPages(ws.currentTutorial()) {
+		m.URLs = append(m.URLs, sitemapLoc{root + p.Path})
+	}
+	writeXML(w, m)
+}
+
+// showSitemapIndex points at the sitemap of each of the hub's tutorials.
+func (h *Hub) showSitemapIndex(w http.ResponseWriter, r *http.Request) {
+	m := sitemapIndex{NS: sitemapNamespace}
+	for _, s := range h.servers {
+		m.Sitemaps = append(m.Sitemaps, sitemapLoc{siteURL(r, s.prefix) + "sitemap.xml"})
+	}
+	writeXML(w, m)
+}
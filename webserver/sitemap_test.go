@@ -0,0 +1,70 @@
+package webserver
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/monopole/mdrip/base"
+)
+
+func TestSitemap(t *testing.T) {
+	files := map[string]string{"k8s/setup.md": "# Setup\n\n```\necho setup\n```\n"}
+	for _, n := range []string{"k8s", "istio"} {
+		files[n+"/intro.md"] = "# About " + n + "\n\nLearn *" + n + "* here.\n\n```\necho " + n + "\n```\n"
+	}
+	dir := writeFiles(t, files)
+	ds, err := base.NewDataSet([]string{
+		filepath.Join(dir, "k8s"), filepath.Join(dir, "istio")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHub(ds.Split())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := h.router()
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(p string) string {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com"+p, nil))
+		return w.Body.String()
+	}
+
+	var index sitemapIndex
+	if err := xml.Unmarshal([]byte(get("/sitemap.xml")), &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Sitemaps) != 2 ||
+		index.Sitemaps[0].Loc != "http://example.com/k8s/sitemap.xml" {
+		t.Errorf("got sitemap index %v", index.Sitemaps)
+	}
+
+	var m sitemapURLSet
+	if err := xml.Unmarshal([]byte(get("/k8s/sitemap.xml")), &m); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.URLs) != 2 ||
+		m.URLs[0].Loc != "http://example.com/k8s/intro" ||
+		m.URLs[1].Loc != "http://example.com/k8s/setup" {
+		t.Errorf("got sitemap %v", m.URLs)
+	}
+
+	page := get("/k8s/")
+	for _, want := range []string{
+		`<link rel="canonical" href="http://example.com/k8s/intro">`,
+		`<meta property="og:title" content="About k8s">`,
+		`<meta name="description" content="Learn k8s here.">`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page lacks %s", want)
+		}
+	}
+	if want := `<link rel="canonical" href="http://example.com/k8s/setup">`; !strings.Contains(get("/k8s/setup"), want) {
+		t.Errorf("setup page lacks %s", want)
+	}
+}
@@ -0,0 +1,71 @@
+package webserver
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gorilla/sessions"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// TLS says how to serve HTTPS; its zero value serves plain HTTP.
+type TLS struct {
+	// CertFile and KeyFile hold, in PEM, the server's
+	// certificate (chain) and its private key.
+	CertFile, KeyFile string
+	// ACMEHost, if not empty, is the host name to get a certificate
+	// for from Let's Encrypt, which must reach the server on port
+	// 443 to verify it's the host's.
+	ACMEHost string
+}
+
+// acmeCacheDir, under the user's cache directory, holds
+// the certificates got from Let's Encrypt, and its account key.
+const acmeCacheDir = "mdrip/autocert"
+
+// IsOn is true if the server is to serve HTTPS.
+func (t TLS) IsOn() bool {
+	return len(t.CertFile) > 0 || len(t.ACMEHost) > 0
+}
+
+// secureCookies has browsers send the server's
+// session cookie only over HTTPS.
+func (ws *Server) secureCookies() {
+	if s, ok := ws.store.(*sessions.CookieStore); ok {
+		s.Options.Secure = true
+	}
+}
+
+// listenAndServe serves the handler at the address, over
+// HTTPS if the TLS says so, to those the Auth, if any, lets in.
+func listenAndServe(hostAndPort string, h http.Handler, t TLS, a Auth) error {
+	if a != nil {
+		h = a.Wrap(h)
+	}
+	if !t.IsOn() {
+		fmt.Println("Serving at " + hostAndPort)
+		return http.ListenAndServe(hostAndPort, h)
+	}
+	s := &http.Server{Addr: hostAndPort, Handler: h,
+		TLSConfig: &tls.Config{MinVersion: tls.VersionTLS12}}
+	if len(t.ACMEHost) > 0 {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return err
+		}
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(t.ACMEHost),
+			Cache:      autocert.DirCache(filepath.Join(dir, acmeCacheDir)),
+		}
+		s.TLSConfig = m.TLSConfig()
+		s.TLSConfig.MinVersion = tls.VersionTLS12
+	}
+	fmt.Println("Serving HTTPS at " + hostAndPort)
+	// With a certificate from Let's Encrypt, the files are empty,
+	// and the TLSConfig supplies it.
+	return s.ListenAndServeTLS(t.CertFile, t.KeyFile)
+}
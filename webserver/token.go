@@ -0,0 +1,106 @@
+package webserver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/monopole/mdrip/webapp"
+)
+
+// headerToken is the header a script may send a token in.
+const headerToken = "X-Mdrip-Token"
+
+// MakeToken returns a token, signed with the secret, granting
+// the right to run blocks on a server with the same secret until
+// the given time.  The token is the expiry time in unix seconds,
+// a dot, then the signature of that.
+func MakeToken(secret string, expires time.Time) string {
+	t := strconv.FormatInt(expires.Unix(), 10)
+	return t + "." + sign(secret, t)
+}
+
+func sign(secret, text string) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(text))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+// CheckToken returns nil if the token was signed with the
+// secret and hasn't expired by the given time.
+func CheckToken(secret, token string, now time.Time) error {
+	if len(token) == 0 {
+		return errors.New("no token")
+	}
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return errors.New("malformed token")
+	}
+	if !hmac.Equal([]byte(parts[1]), []byte(sign(secret, parts[0]))) {
+		return errors.New("token has a bad signature")
+	}
+	t, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	if !now.Before(time.Unix(t, 0)) {
+		return errors.New("token expired at " + time.Unix(t, 0).Format(time.RFC3339))
+	}
+	return nil
+}
+
+// requestToken is the token sent with a request: a query
+// param, else a header, else one kept in the session cookie.
+func (ws *Server) requestToken(r *http.Request) string {
+	if t := r.URL.Query().Get(webapp.KeyToken); len(t) > 0 {
+		return t
+	}
+	if t := r.Header.Get(headerToken); len(t) > 0 {
+		return t
+	}
+	session, err := ws.store.Get(r, cookieName)
+	if err != nil {
+		return ""
+	}
+	t, _ := session.Values[webapp.KeyToken].(string)
+	return t
+}
+
+// RequireTokens has requests that run or send blocks bear
+// a token signed with the secret; see MakeToken.  If the
+// secret's empty, anyone may run them.
+func (ws *Server) RequireTokens(secret string) {
+	ws.tokenSecret = secret
+}
+
+// RequireTokens has each of the hub's tutorials require
+// tokens; see Server.RequireTokens.
+func (h *Hub) RequireTokens(secret string) {
+	for _, s := range h.servers {
+		s.RequireTokens(secret)
+	}
+}
+
+// requireToken wraps a handler that runs or sends blocks, so
+// that, if the server has a token secret, it's only called for
+// requests bearing a valid, unexpired token.
+func (ws *Server) requireToken(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(ws.tokenSecret) > 0 {
+			if err := CheckToken(
+				ws.tokenSecret, ws.requestToken(r), time.Now()); err != nil {
+				glog.Infof("refused %s: %v", r.URL.Path, err)
+				http.Error(w, "running blocks needs a valid token: "+err.Error(),
+					http.StatusForbidden)
+				return
+			}
+		}
+		h(w, r)
+	}
+}
@@ -0,0 +1,67 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/monopole/mdrip/webapp"
+)
+
+func TestCheckToken(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	good := MakeToken("s3cret", now.Add(2*time.Hour))
+	var tests = map[string]struct {
+		secret, token string
+		now           time.Time
+		ok            bool
+	}{
+		"valid":        {"s3cret", good, now, true},
+		"expired":      {"s3cret", good, now.Add(3 * time.Hour), false},
+		"otherSecret":  {"other", good, now, false},
+		"empty":        {"s3cret", "", now, false},
+		"malformed":    {"s3cret", "1700007200", now, false},
+		"extendedTime": {"s3cret", "1800000000" + good[10:], now, false},
+	}
+	for n, test := range tests {
+		err := CheckToken(test.secret, test.token, test.now)
+		if (err == nil) != test.ok {
+			t.Errorf("%s: got error %v, want ok %v", n, err, test.ok)
+		}
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	ws, _ := serveFiles(t, "", map[string]string{"intro.md": "# Intro\n"})
+	ws.RequireTokens("s3cret")
+	token := MakeToken("s3cret", time.Now().Add(time.Hour))
+	get := func(p string, c []*http.Cookie) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", p, nil)
+		for _, x := range c {
+			r.AddCookie(x)
+		}
+		ws.router().ServeHTTP(w, r)
+		return w
+	}
+	cancel := "/_/cancelseq?" + webapp.KeySessID + "=s1"
+	if w := get(cancel, nil); w.Code != http.StatusForbidden {
+		t.Errorf("no token: got %d", w.Code)
+	}
+	if w := get(cancel+"&"+webapp.KeyToken+"="+
+		MakeToken("other", time.Now().Add(time.Hour)), nil); w.Code != http.StatusForbidden {
+		t.Errorf("bad token: got %d", w.Code)
+	}
+	if w := get(cancel+"&"+webapp.KeyToken+"="+token, nil); w.Code != http.StatusOK {
+		t.Errorf("token param: got %d", w.Code)
+	}
+	// A link bearing a token leaves it in the session cookie.
+	w := get("/?"+webapp.KeyToken+"="+token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("page: got %d", w.Code)
+	}
+	if w := get(cancel, w.Result().Cookies()); w.Code != http.StatusOK {
+		t.Errorf("token cookie: got %d", w.Code)
+	}
+}
@@ -0,0 +1,180 @@
+package webserver
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/golang/glog"
+	"github.com/gorilla/websocket"
+)
+
+// How long to wait, after a file changes, for more changes
+// before reloading, so that, say, an editor's save of many
+// files, or a git checkout, reloads just once.
+const watchSettleTime = 200 * time.Millisecond
+
+// msgReload, sent over /_/reloads, tells a browser to reload the page.
+const msgReload = "reload"
+
+// reloadWatchers holds the websockets of browsers
+// to tell when the tutorial's been reloaded.
+type reloadWatchers struct {
+	mu    sync.Mutex
+	conns map[*websocket.Conn]bool
+}
+
+func newReloadWatchers() *reloadWatchers {
+	return &reloadWatchers{conns: make(map[*websocket.Conn]bool)}
+}
+
+func (r *reloadWatchers) add(c *websocket.Conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.conns[c] = true
+}
+
+func (r *reloadWatchers) remove(c *websocket.Conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.conns, c)
+	c.Close()
+}
+
+// broadcast tells every browser to reload.
+func (r *reloadWatchers) broadcast() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for c := range r.conns {
+		if err := c.WriteMessage(websocket.TextMessage, []byte(msgReload)); err != nil {
+			c.Close()
+			delete(r.conns, c)
+		}
+	}
+}
+
+// openReloads upgrades the request to a websocket on which
+// the browser is told to reload when the tutorial changes.
+func (ws *Server) openReloads(w http.ResponseWriter, r *http.Request) {
+	c, err := ws.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		glog.Errorf("unable to upgrade reloads: %v", err)
+		return
+	}
+	ws.reloads.add(c)
+	// The browser sends nothing; reading notices when it goes away.
+	go func() {
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				ws.reloads.remove(c)
+				return
+			}
+		}
+	}()
+}
+
+// Watch reloads the tutorial, and the browsers showing it,
+// when its files change.
+func (ws *Server) Watch() {
+	ws.watch = true
+}
+
+// Watch has each of the hub's tutorials reload when its
+// files change; see Server.Watch.
+func (h *Hub) Watch() {
+	for _, s := range h.servers {
+		s.Watch()
+	}
+}
+
+// startWatching watches the directories of the server's local tutorial,
+// and those below them, reloading the tutorial, and telling
+// browsers to reload, when markdown in them changes.
+func (ws *Server) startWatching() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	for _, p := range ws.loader.DataSet().AsPaths() {
+		// A file's directory is watched, but not those below it.
+		add := watchTree
+		if s, err := os.Stat(string(p)); err == nil && !s.IsDir() {
+			add = func(w *fsnotify.Watcher, f string) error {
+				return w.Add(filepath.Dir(f))
+			}
+		}
+		if err := add(w, string(p)); err != nil {
+			w.Close()
+			return err
+		}
+	}
+	go ws.reloadOnChange(w)
+	return nil
+}
+
+// watchTree adds the directory, and those below
+// it, less hidden ones like .git, to the watcher.
+func watchTree(w *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if p != dir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		return w.Add(p)
+	})
+}
+
+// isWatched is true if a change to the file may change the tutorial.
+func isWatched(p string) bool {
+	return strings.HasSuffix(p, ".md") ||
+		filepath.Base(p) == "GLOSSARY.txt" || filepath.Base(p) == "REDIRECTS.txt"
+}
+
+func (ws *Server) reloadOnChange(w *fsnotify.Watcher) {
+	defer w.Close()
+	var settle <-chan time.Time
+	for {
+		select {
+		case e, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if e.Op&fsnotify.Create != 0 {
+				if s, err := os.Stat(e.Name); err == nil && s.IsDir() {
+					// A new directory may hold new lessons.
+					if err := watchTree(w, e.Name); err != nil {
+						glog.Errorf("unable to watch %s: %v", e.Name, err)
+					}
+					settle = time.After(watchSettleTime)
+					continue
+				}
+			}
+			if isWatched(e.Name) {
+				settle = time.After(watchSettleTime)
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			glog.Errorf("watching: %v", err)
+		case <-settle:
+			settle = nil
+			t, err := ws.loader.Load()
+			if err != nil {
+				glog.Errorf("Trouble reloading changed data: %v", err)
+				continue
+			}
+			ws.setTutorial(t)
+			glog.Info("Reloaded changed data.")
+			ws.reloads.broadcast()
+		case <-ws.connReaperQuitCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package webserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWatch(t *testing.T) {
+	ws, dir := serveFiles(t, "", map[string]string{"intro.md": "# Intro\n"})
+	ws.Watch()
+	if err := ws.startWatching(); err != nil {
+		t.Fatal(err)
+	}
+	defer close(ws.connReaperQuitCh)
+	srv := httptest.NewServer(ws.router())
+	defer srv.Close()
+	c, _, err := websocket.DefaultDialer.Dial(
+		"ws"+strings.TrimPrefix(srv.URL, "http")+"/_/reloads", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	read := func(what string) {
+		c.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, msg, err := c.ReadMessage()
+		if err != nil {
+			t.Fatalf("no reload after %s: %v", what, err)
+		}
+		if string(msg) != msgReload {
+			t.Errorf("after %s, got %q, want %q", what, msg, msgReload)
+		}
+	}
+	os.Mkdir(filepath.Join(dir, "install"), 0755)
+	read("new directory")
+	// The new directory is watched too.
+	ioutil.WriteFile(filepath.Join(dir, "install", "linux.md"), []byte("# Linux\n"), 0644)
+	read("new lesson")
+	ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not markdown\n"), 0644)
+	c.SetReadDeadline(time.Now().Add(4 * watchSettleTime))
+	if _, msg, err := c.ReadMessage(); err == nil {
+		t.Errorf("after non-markdown change, got %q", msg)
+	}
+}
+
+func TestReloadWhileServing(t *testing.T) {
+	ws, _ := serveFiles(t, "", map[string]string{
+		"intro.md": "# Intro\n\n```\necho hi\n```\n",
+	})
+	defer close(ws.connReaperQuitCh)
+	r := ws.router()
+	// Handlers read the tutorial while a reload, as the
+	// watcher's, replaces it; go test -race checks they don't race.
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			tut, err := ws.loader.Load()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ws.setTutorial(tut)
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		for _, p := range []string{"/_/tree", "/search?q=intro", "/sitemap.xml", "/api/v1/lessons"} {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", p, nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("%s: got %d", p, w.Code)
+			}
+		}
+	}
+	<-done
+}
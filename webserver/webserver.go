@@ -1,11 +1,15 @@
 package webserver
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
@@ -13,10 +17,13 @@ import (
 	"github.com/gorilla/sessions"
 	"github.com/gorilla/websocket"
 	"github.com/monopole/mdrip/base"
+	"github.com/monopole/mdrip/diagram"
 	"github.com/monopole/mdrip/loader"
 	"github.com/monopole/mdrip/model"
 	"github.com/monopole/mdrip/program"
+	"github.com/monopole/mdrip/schema"
 	"github.com/monopole/mdrip/tmux"
+	"github.com/monopole/mdrip/transform"
 	"github.com/monopole/mdrip/util"
 	"github.com/monopole/mdrip/webapp"
 )
@@ -40,13 +47,51 @@ func (c *myConn) Write(bytes []byte) (n int, err error) {
 
 // Server represents a webserver.
 type Server struct {
-	loader           *loader.Loader
-	didFirstRender   bool
-	tutorial         model.Tutorial
-	store            sessions.Store
-	upgrader         websocket.Upgrader
+	// prefix is the URL path the server's tutorial is served
+	// under, e.g. "/k8s", or "" if it's served at the root.
+	prefix         string
+	loader         *loader.Loader
+	didFirstRender bool
+	// tutMu guards tutorial and index, which reloads, e.g.
+	// by the watcher, replace while handlers read them.
+	tutMu    sync.RWMutex
+	tutorial model.Tutorial
+	store    sessions.Store
+	upgrader websocket.Upgrader
+	// connMu guards connections, which handlers, block
+	// sequences and the reaper all use, and serializes
+	// writes to a websocket, which allows only one writer.
+	connMu           sync.Mutex
 	connections      map[webapp.TypeSessID]*myConn
 	connReaperQuitCh chan bool
+	pipeline         transform.Pipeline
+	sequenceMu       sync.Mutex
+	sequences        map[webapp.TypeSessID]chan struct{}
+	statuses         *statusTracker
+	results          *resultWatchers
+	feed             *changeFeed
+	started          time.Time
+	targets          tmux.Targets
+	mux              tmux.Multiplexer
+	diagrams         diagram.Servers
+	msgs             *webapp.Messages
+	// tokenSecret, if not empty, signs the tokens
+	// needed to run blocks; see MakeToken.
+	tokenSecret string
+	// watch means reload the tutorial when its files change.
+	watch   bool
+	reloads *reloadWatchers
+	index   *webapp.SearchIndex
+	// proposer, if not nil, submits edits of lessons.
+	proposer Proposer
+	// origins are those, besides the server's own, whose
+	// pages may run blocks; see AllowOrigins.
+	origins map[string]bool
+	metrics *serverMetrics
+	// class, if not nil, is the classroom visitors may join.
+	class *classroom
+	// brand makes the pages an organization's own; see Brand.
+	brand webapp.Branding
 }
 
 const (
@@ -57,25 +102,81 @@ const (
 var keyAuth = []byte("static-visible-secret")
 var keyEncrypt = []byte(nil)
 
-// NewServer returns a new web server configured with the given loader.
+// NewServer returns a new web server configured with the given
+// loader.  It copies blocks to the clipboard only, until given a
+// multiplexer to paste them into; see PasteInto.
 func NewServer(l *loader.Loader) (*Server, error) {
+	return newServer("", l), nil
+}
+
+// newServer returns a server for a tutorial served under the given
+// URL path prefix.  Each prefix gets its own session cookie.
+func newServer(prefix string, l *loader.Loader) *Server {
 	s := sessions.NewCookieStore(keyAuth, keyEncrypt)
 	s.Options = &sessions.Options{
-		Path:     "/",
+		Path:     prefix + "/",
 		MaxAge:   8 * 60 * 60, // 8 hours (Max-Age has units seconds)
 		HttpOnly: true,
 	}
 	result := &Server{
+		prefix,
 		l,
 		false,
+		sync.RWMutex{},
 		nil,
 		s,
 		websocket.Upgrader{},
+		sync.Mutex{},
 		make(map[webapp.TypeSessID]*myConn),
 		make(chan bool),
+		transform.Pipeline{},
+		sync.Mutex{},
+		make(map[webapp.TypeSessID]chan struct{}),
+		newStatusTracker(),
+		newResultWatchers(),
+		newChangeFeed(),
+		time.Now(),
+		tmux.Targets{},
+		nil,
+		diagram.Servers{},
+		webapp.DefaultMessages(),
+		"",
+		false,
+		newReloadWatchers(),
+		nil,
+		nil,
+		nil,
+		newServerMetrics(),
+		nil,
+		webapp.Branding{},
 	}
 	go result.reapConnections()
-	return result, nil
+	return result
+}
+
+// Transform applies the transforms to blocks before
+// sending them to tmux.
+func (ws *Server) Transform(p transform.Pipeline) {
+	ws.pipeline = p
+}
+
+// PasteInto pastes blocks, when there's no websocket to send
+// them to, into the multiplexer, e.g. tmux, at the named targets.
+func (ws *Server) PasteInto(m tmux.Multiplexer, t tmux.Targets) {
+	ws.mux = m
+	ws.targets = t
+}
+
+// DrawDiagrams draws diagrams with the servers, whose
+// URLs may be empty.
+func (ws *Server) DrawDiagrams(diagrams diagram.Servers) {
+	ws.diagrams = diagrams
+}
+
+// Translate shows the web app's chrome - buttons, tooltips,
+// help - in the messages' language.
+func (ws *Server) Translate(msgs *webapp.Messages) {
+	ws.msgs = msgs
 }
 
 func getSessIdParam(r *http.Request) (webapp.TypeSessID, error) {
@@ -97,6 +198,8 @@ func (ws *Server) openWebSocket(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	ws.connMu.Lock()
+	defer ws.connMu.Unlock()
 	existingConn := ws.connections[sessID]
 	var c *websocket.Conn
 	if existingConn != nil {
@@ -175,17 +278,32 @@ func (ws *Server) reload(w http.ResponseWriter, r *http.Request) {
 		glog.Errorf("Unable to save session: %v", err)
 	}
 
-	ws.tutorial = t
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	ws.setTutorial(t)
+	http.Redirect(w, r, ws.prefix+"/", http.StatusSeeOther)
 }
 
 func (ws *Server) showControlPage(w http.ResponseWriter, r *http.Request) {
+	extract, err := extractView(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	session, err := ws.store.Get(r, cookieName)
 	if err != nil {
 		write500(w, err)
 		return
 	}
 	sessionData := webapp.AssureSessionData(session)
+	// Keep a token from a link, so the page's requests to run blocks bear it.
+	if t := r.URL.Query().Get(webapp.KeyToken); len(t) > 0 {
+		session.Values[webapp.KeyToken] = t
+		sessionData.Token = t
+	}
+	// Keep the language of lessons chosen in the header.
+	if lang, ok := r.URL.Query()[webapp.KeyLang]; ok && model.IsLang(lang[0]) {
+		session.Values[webapp.KeyLang] = lang[0]
+	}
+	sessionData.Lang = ws.sessionLang(session)
 	err = session.Save(r, w)
 	if err != nil {
 		write500(w, err)
@@ -195,17 +313,23 @@ func (ws *Server) showControlPage(w http.ResponseWriter, r *http.Request) {
 	if ws.didFirstRender {
 		// Consider reloading data on all renders beyond the first.
 		glog.Infof("Already did first render.")
-		if !ws.loader.SmellsLikeGithub() {
+		if !ws.loader.IsRemote() {
 			t, err := ws.loader.Load()
 			if err == nil {
-				ws.tutorial = t
+				ws.setTutorial(t)
 				glog.Info("Reloaded data.")
 			} else {
 				glog.Errorf("Trouble reloading local data: %v", err)
 			}
 		}
 	}
-	app := ws.makeWebApp(sessionData, r.Host, r.URL.Path)
+	if ws.redirectOrNotFound(w, r) {
+		return
+	}
+	app := ws.makeWebApp(sessionData, scheme(r), r.Host, r.URL.Path, extract)
+	if p := r.URL.Query().Get(webapp.KeyPrint); p == "1" || p == "true" {
+		app.Print()
+	}
 	ws.didFirstRender = true
 	if err := app.Render(w); err != nil {
 		write500(w, err)
@@ -213,19 +337,89 @@ func (ws *Server) showControlPage(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (ws *Server) makeWebApp(sessionData *webapp.SessionData, host, path string) *webapp.WebApp {
-	v := newLessonFinder()
-	ws.tutorial.Accept(v)
+// redirectOrNotFound, if the request's path names nothing in the
+// tutorial, redirects to where the page moved, per the tutorial's
+// REDIRECTS.txt, or else writes a 404 page suggesting lessons.
+// It returns true if it wrote a response.
+func (ws *Server) redirectOrNotFound(w http.ResponseWriter, r *http.Request) bool {
+	p := strings.Trim(r.URL.Path, "/")
+	t := ws.currentTutorial()
+	v := webapp.NewLessonFinder()
+	t.Accept(v)
+	if p == "" || v.HasPath(p) {
+		return false
+	}
+	if to, ok := redirectsOf(t).Find(p); ok {
+		http.Redirect(w, r, ws.prefix+"/"+to, http.StatusMovedPermanently)
+		return true
+	}
+	w.WriteHeader(http.StatusNotFound)
+	if err := webapp.RenderNotFound(
+		w, ws.prefix, p, webapp.LessonPages(t), ws.msgs); err != nil {
+		glog.Errorf("Trouble rendering 404 for %s: %v", p, err)
+	}
+	return true
+}
+
+// redirectsOf returns the redirects of the tutorial, if it's a course.
+func redirectsOf(t model.Tutorial) model.Redirects {
+	switch c := t.(type) {
+	case *model.TopCourse:
+		return c.Redirects()
+	case *model.Course:
+		return c.Redirects()
+	}
+	return model.Redirects{}
+}
+
+// extractView returns the extraction overlay's view the request
+// asks for, with its debug, label and arch params, or nil if none.
+func extractView(r *http.Request) (*webapp.ExtractView, error) {
+	q := r.URL.Query()
+	switch q.Get(webapp.KeyDebug) {
+	case "":
+		return nil, nil
+	case webapp.DebugExtract:
+	default:
+		return nil, fmt.Errorf("unknown debug view %q", q.Get(webapp.KeyDebug))
+	}
+	label := base.WildCardLabel
+	if x := q.Get(webapp.KeyLabel); len(x) > 0 {
+		label = base.Label(x)
+	}
+	if err := label.CheckSelector(); err != nil {
+		return nil, err
+	}
+	return &webapp.ExtractView{Label: label, Arch: q.Get(webapp.KeyArch)}, nil
+}
+
+func (ws *Server) makeWebApp(
+	sessionData *webapp.SessionData, scheme, host, path string,
+	extract *webapp.ExtractView) *webapp.WebApp {
+	t := ws.tutorialIn(sessionData.Lang)
+	v := webapp.NewLessonFinder()
+	t.Accept(v)
 	var lessonPath []int
 	if len(path) > 0 && path[0] == '/' {
-		lessonPath = v.getLessonPath(path[1:])
+		lessonPath = v.LessonPath(path[1:])
 	} else {
-		lessonPath = v.getLessonPath(path)
+		lessonPath = v.LessonPath(path)
+	}
+	wa := webapp.NewWebApp(
+		sessionData, scheme, host, ws.prefix,
+		t, ws.loader.DataSet().FirstArg(),
+		lessonPath, v.CoursePaths(), ws.targets.Names(), ws.diagrams, ws.msgs,
+		ws.watch, ws.editable(), extract, false, "")
+	wa.Brand(ws.brand)
+	return wa
+}
+
+func (ws *Server) showGlossary(w http.ResponseWriter, r *http.Request) {
+	v := program.NewLessonPgmExtractor(base.WildCardLabel)
+	ws.currentTutorial().Accept(v)
+	if err := webapp.RenderGlossary(w, v.FirstTitle(), v.Glossary(), ws.msgs); err != nil {
+		write500(w, err)
 	}
-	return webapp.NewWebApp(
-		sessionData, host,
-		ws.tutorial, ws.loader.DataSet().FirstArg(),
-		lessonPath, v.getCoursePaths())
 }
 
 func (ws *Server) showDebugPage(w http.ResponseWriter, r *http.Request) {
@@ -235,8 +429,9 @@ func (ws *Server) showDebugPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	session.Save(r, w)
-	ws.tutorial.Accept(model.NewTutorialTxtPrinter(w))
-	p := program.NewProgramFromTutorial(base.WildCardLabel, ws.tutorial)
+	t := ws.currentTutorial()
+	t.Accept(model.NewTutorialTxtPrinter(w))
+	p := program.NewProgramFromTutorial(base.WildCardLabel, t)
 	fmt.Fprintf(w, "\n\nfile count %d\n\n", len(p.Lessons()))
 	for i, lesson := range p.Lessons() {
 		fmt.Fprintf(w, "file %d: %s\n", i, lesson.Path())
@@ -247,17 +442,18 @@ func (ws *Server) showDebugPage(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (ws *Server) attemptTmuxWrite(b *program.BlockPgm) error {
-	t := tmux.NewTmux(tmux.Path)
-	if !t.IsUp() {
-		return errors.New("no local tmux to write to")
+// showTree writes the tutorial as a schema.Tree document, or just
+// the parts of it with the tag given in the query.
+func (ws *Server) showTree(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	t := schema.NewTreeForTag(ws.currentTutorial(), r.URL.Query().Get(webapp.KeyTag))
+	if err := schema.Write(w, t); err != nil {
+		write500(w, err)
 	}
-	_, err := t.Write(b.Code().Bytes())
-	return err
 }
 
 func inRange(w http.ResponseWriter, name string, arg, n int) bool {
-	if arg >= 0 || arg < n {
+	if arg >= 0 && arg < n {
 		return true
 	}
 	http.Error(w,
@@ -266,55 +462,338 @@ func inRange(w http.ResponseWriter, name string, arg, n int) bool {
 	return false
 }
 
+// lookupBlock finds the block addressed by the request's lesson and
+// block index params, writing an error response if there's no such block.
+func (ws *Server) lookupBlock(
+	w http.ResponseWriter, r *http.Request) (*program.LessonPgm, int, int, bool) {
+	lessonIndex := getIntParam(webapp.KeyLessonIndex, r, -1)
+	blockIndex := getIntParam(webapp.KeyBlockIndex, r, -1)
+	p := program.NewProgramFromTutorial(base.WildCardLabel, ws.tutorialIn(ws.langOf(r)))
+	if !inRange(w, webapp.KeyLessonIndex, lessonIndex, len(p.Lessons())) {
+		return nil, 0, 0, false
+	}
+	lesson := p.Lessons()[lessonIndex]
+	if !inRange(w, webapp.KeyBlockIndex, blockIndex, len(lesson.Blocks())) {
+		return nil, 0, 0, false
+	}
+	return lesson, lessonIndex, blockIndex, true
+}
+
+func getSessID(w http.ResponseWriter, r *http.Request) (webapp.TypeSessID, bool) {
+	arg := r.URL.Query().Get(webapp.KeySessID)
+	if len(arg) == 0 {
+		http.Error(w, "No session id for block runner", http.StatusBadRequest)
+		return "", false
+	}
+	return webapp.TypeSessID(arg), true
+}
+
+// send sends code to the session's websocket if it has one, else
+// directly to the local multiplexer.  The returned function waits for
+// the code to finish, returning its state and exit status.  The wait
+// is real only for a local tmux; with a websocket, or another
+// multiplexer, completion can't be observed, so the function just
+// pauses.
+//
+// With local tmux, if key (a blockKey) isn't empty, the code's
+// output is pushed to the session's browser while it runs.
+//
+// The target is the name of a target pane; if it's not one
+// of the server's targets, the code goes to the default pane.
+// Remote tmux (over a websocket) has only one target.
+// errNoTmux is send's error when there's neither a socket nor
+// a local multiplexer, or blocks are only to be copied; the page
+// then copies blocks to the clipboard.
+var errNoTmux = errors.New("no local multiplexer to write to")
+
+// delivery returns how blocks sent in the session reach a shell,
+// as one of the schema's Delivery values.
+func (ws *Server) delivery(sessID webapp.TypeSessID) string {
+	if ws.mux == nil {
+		return schema.DeliveryClipboard
+	}
+	ws.connMu.Lock()
+	_, ok := ws.connections[sessID]
+	ws.connMu.Unlock()
+	if ok {
+		return schema.DeliveryWebsocket
+	}
+	if ws.mux.IsUp() {
+		return schema.DeliveryTmux
+	}
+	return schema.DeliveryClipboard
+}
+
+// writeToSocket writes the code to the session's websocket, if it
+// has one, returning true if that worked; a socket that fails is
+// dropped.
+func (ws *Server) writeToSocket(sessID webapp.TypeSessID, code base.OpaqueCode) bool {
+	ws.connMu.Lock()
+	defer ws.connMu.Unlock()
+	c := ws.connections[sessID]
+	if c == nil {
+		glog.Infof("no socket for session %v", sessID)
+		return false
+	}
+	if _, err := c.Write(code.Bytes()); err != nil {
+		glog.Infof("socket write failed: %v", err)
+		delete(ws.connections, sessID)
+		return false
+	}
+	return true
+}
+
+// pause is the wait of code whose completion can't be observed.
+func pause() (blockState, int) {
+	time.Sleep(sequenceRemotePause)
+	return stateSent, tmux.Unknown
+}
+
+func (ws *Server) send(
+	sessID webapp.TypeSessID, key string, code base.OpaqueCode,
+	target string) (func() (blockState, int), error) {
+	if ws.mux == nil {
+		return nil, errNoTmux
+	}
+	if ws.writeToSocket(sessID, code) {
+		return pause, nil
+	}
+	glog.Infof("no socket, attempting direct %s paste", ws.mux.Name())
+	m := ws.mux
+	if pane, ok := ws.targets[target]; ok {
+		m = m.WithTarget(pane)
+	} else {
+		if len(target) > 0 {
+			glog.Infof("unknown target %q, using the default pane", target)
+		}
+		if pane := ws.targets.Default(); len(pane) > 0 {
+			m = m.WithTarget(pane)
+		}
+	}
+	if !m.IsUp() {
+		return nil, errNoTmux
+	}
+	t, ok := m.(tmux.Tracker)
+	if !ok {
+		if _, err := m.Write(code.Bytes()); err != nil {
+			glog.Infof("%s write failed: %v", m.Name(), err)
+			return nil, err
+		}
+		return pause, nil
+	}
+	var completion *tmux.Completion
+	var err error
+	if len(key) > 0 {
+		completion, err = t.WriteCaptured(code.Bytes())
+	} else {
+		completion, err = t.WriteTracked(code.Bytes())
+	}
+	if err != nil {
+		glog.Infof("tmux write failed: %v", err)
+		return nil, err
+	}
+	return func() (blockState, int) {
+		defer completion.Close()
+		stop, followed := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(followed)
+			ws.follow(sessID, key, completion, stop)
+		}()
+		status, err := completion.Wait(sequenceBlockTimeout)
+		close(stop)
+		<-followed
+		if err != nil {
+			glog.Infof("no exit status from tmux: %v", err)
+			return stateFailed, tmux.Unknown
+		}
+		if status != 0 {
+			return stateFailed, status
+		}
+		return stateOk, status
+	}, nil
+}
+
+// chooseTarget picks the tmux target for a block:
+// the block's own, else the one selected in the UI.
+func chooseTarget(block *program.BlockPgm, r *http.Request) string {
+	if t := block.Target(); len(t) > 0 {
+		return t
+	}
+	return r.URL.Query().Get(webapp.KeyTarget)
+}
+
+// Prepare a block for sending to tmux.
+func (ws *Server) prepare(block *program.BlockPgm) base.OpaqueCode {
+	// Transform first, so the banner (a comment) survives.
+	code := ws.pipeline.Apply(block.Code())
+	if block.ShouldSay() {
+		code = base.OpaqueCode(block.Banner()) + code
+	}
+	return code
+}
+
 func (ws *Server) makeBlockRunner() func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		arg := r.URL.Query().Get(webapp.KeySessID)
-		if len(arg) == 0 {
-			http.Error(w, "No session id for block runner", http.StatusBadRequest)
+		sessID, ok := getSessID(w, r)
+		if !ok {
+			return
+		}
+		lesson, lessonIndex, blockIndex, ok := ws.lookupBlock(w, r)
+		if !ok {
 			return
 		}
-		sessID := webapp.TypeSessID(arg)
-		lessonIndex := getIntParam(webapp.KeyLessonIndex, r, -1)
-		blockIndex := getIntParam(webapp.KeyBlockIndex, r, -1)
 		glog.Infof("%s = %s, %s = %d, %s = %d",
 			webapp.KeySessID, sessID,
 			webapp.KeyLessonIndex, lessonIndex,
 			webapp.KeyBlockIndex, blockIndex)
-
-		p := program.NewProgramFromTutorial(base.WildCardLabel, ws.tutorial)
-		if !inRange(w, webapp.KeyLessonIndex, lessonIndex, len(p.Lessons())) {
+		block := lesson.Blocks()[blockIndex]
+		if getBoolParam(webapp.KeyBannerOnly, r, false) {
+			// Errors are logged; nothing more to try.
+			ws.send(sessID, "", base.OpaqueCode(block.Banner()), chooseTarget(block, r))
+			fmt.Fprintln(w, "Ok")
 			return
 		}
-		lesson := p.Lessons()[lessonIndex]
-		if !inRange(w, webapp.KeyBlockIndex, blockIndex, len(lesson.Blocks())) {
+		if err := ws.runBlock(sessID, lessonIndex, blockIndex, block, r); err != nil {
+			// The page has copied the block already; it learns
+			// from the status to say it must be pasted by hand.
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
 			return
 		}
-		block := lesson.Blocks()[blockIndex]
+		fmt.Fprintln(w, "Ok")
+	}
+}
 
-		var err error
+// runBlock sends the block to the session's shell, marking it
+// running, and marks it with its final state once it finishes.
+func (ws *Server) runBlock(
+	sessID webapp.TypeSessID, lessonIndex, blockIndex int,
+	block *program.BlockPgm, r *http.Request) error {
+	key := blockKey(lessonIndex, blockIndex)
+	wait, err := ws.send(sessID, key, ws.prepare(block), chooseTarget(block, r))
+	if err != nil {
+		glog.Infof("block %s not sent: %v", key, err)
+		return err
+	}
+	ws.setState(sessID, key, stateRunning, tmux.Unknown)
+	go func() {
+		state, status := wait()
+		ws.setState(sessID, key, state, status)
+	}()
+	return nil
+}
 
-		c := ws.connections[sessID]
-		if c == nil {
-			glog.Infof("no socket for session %v", sessID)
-		} else {
-			_, err := c.Write(block.Code().Bytes())
-			if err != nil {
-				glog.Infof("socket write failed: %v", err)
-				delete(ws.connections, sessID)
+// showStatus writes, as a schema.Status document, the state of all
+// the blocks the session has sent to tmux, how long the finished
+// ones ran, and how they're sent.
+func (ws *Server) showStatus(w http.ResponseWriter, r *http.Request) {
+	sessID, ok := getSessID(w, r)
+	if !ok {
+		return
+	}
+	states := map[string]string{}
+	for k, v := range ws.statuses.get(sessID) {
+		states[k] = string(v)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(
+		schema.NewStatus(states, ws.statuses.elapsed(sessID), ws.delivery(sessID))); err != nil {
+		write500(w, err)
+	}
+}
+
+const (
+	// How long to wait for a block sent to local tmux to finish.
+	sequenceBlockTimeout = 10 * time.Minute
+	// How long to pause between blocks in a sequence sent to a remote tmux.
+	sequenceRemotePause = 3 * time.Second
+)
+
+// runSequence sends all the blocks of a lesson (or of the section
+// holding the given block) one at a time, sending each only after
+// the previous one has finished, and stopping if one fails.
+// Blocks for architectures other than the browser's are skipped.
+// A new sequence in the same session cancels the old one.
+func (ws *Server) runSequence(w http.ResponseWriter, r *http.Request) {
+	sessID, ok := getSessID(w, r)
+	if !ok {
+		return
+	}
+	lesson, lessonIndex, blockIndex, ok := ws.lookupBlock(w, r)
+	if !ok {
+		return
+	}
+	start, end := 0, len(lesson.Blocks())
+	if r.URL.Query().Get(webapp.KeyScope) == webapp.ScopeSection {
+		start, end = blockIndex, lesson.SectionEnd(blockIndex)
+	}
+	arch := r.URL.Query().Get(webapp.KeyArch)
+	cancel := ws.startSequence(sessID)
+	go func() {
+		defer ws.endSequence(sessID, cancel)
+		for i := start; i < end; i++ {
+			b := lesson.Blocks()[i]
+			if len(b.Code()) == 0 || !b.SuitsArch(arch) {
+				continue
 			}
-		}
-		if c == nil || err != nil {
-			glog.Info("no socket, attempting direct tmux paste")
-			err = ws.attemptTmuxWrite(block)
+			select {
+			case <-cancel:
+				glog.Infof("sequence cancelled in session %v", sessID)
+				return
+			default:
+			}
+			glog.Infof("sequence %v: block %d of %s", sessID, i, lesson.Name())
+			key := blockKey(lessonIndex, i)
+			wait, err := ws.send(sessID, key, ws.prepare(b), chooseTarget(b, r))
 			if err != nil {
-				glog.Infof("tmux write failed: %v", err)
-				// nothing more to try
+				glog.Infof("sequence %v stopped: %v", sessID, err)
+				return
+			}
+			ws.setState(sessID, key, stateRunning, tmux.Unknown)
+			state, status := wait()
+			ws.setState(sessID, key, state, status)
+			if state == stateFailed {
+				glog.Infof("sequence %v stopped at failing block %d", sessID, i)
+				return
 			}
 		}
-		fmt.Fprintln(w, "Ok")
+	}()
+	fmt.Fprintln(w, "Ok")
+}
+
+func (ws *Server) startSequence(sessID webapp.TypeSessID) chan struct{} {
+	ws.sequenceMu.Lock()
+	defer ws.sequenceMu.Unlock()
+	if old, ok := ws.sequences[sessID]; ok {
+		close(old)
+	}
+	c := make(chan struct{})
+	ws.sequences[sessID] = c
+	return c
+}
+
+func (ws *Server) endSequence(sessID webapp.TypeSessID, c chan struct{}) {
+	ws.sequenceMu.Lock()
+	defer ws.sequenceMu.Unlock()
+	if ws.sequences[sessID] == c {
+		delete(ws.sequences, sessID)
 	}
 }
 
+// cancelSequence stops a running sequence after its current block.
+func (ws *Server) cancelSequence(w http.ResponseWriter, r *http.Request) {
+	sessID, ok := getSessID(w, r)
+	if !ok {
+		return
+	}
+	ws.sequenceMu.Lock()
+	if c, ok := ws.sequences[sessID]; ok {
+		close(c)
+		delete(ws.sequences, sessID)
+	}
+	ws.sequenceMu.Unlock()
+	fmt.Fprintln(w, "Ok")
+}
+
 func (ws *Server) saveSession(w http.ResponseWriter, r *http.Request) {
 	session, err := ws.store.Get(r, cookieName)
 	if err != nil {
@@ -325,6 +804,11 @@ func (ws *Server) saveSession(w http.ResponseWriter, r *http.Request) {
 	session.Values[webapp.KeyIsHeaderOn] = getBoolParam(webapp.KeyIsHeaderOn, r, false)
 	session.Values[webapp.KeyLessonIndex] = getIntParam(webapp.KeyLessonIndex, r, 0)
 	session.Values[webapp.KeyBlockIndex] = getIntParam(webapp.KeyBlockIndex, r, 0)
+	if ws.class != nil {
+		if s, ok := session.Values[webapp.KeySessID].(webapp.TypeSessID); ok {
+			ws.class.noteLesson(s, getIntParam(webapp.KeyLessonIndex, r, -1))
+		}
+	}
 	err = session.Save(r, w)
 	if err != nil {
 		glog.Errorf("Unable to save session: %v", err)
@@ -362,6 +846,50 @@ func getBoolParam(n string, r *http.Request, d bool) bool {
 	return v
 }
 
+// Image file extensions served as lesson assets.
+var assetExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true,
+	".svg": true, ".webp": true,
+}
+
+// resolve returns the path with symlinks evaluated, if possible.
+func resolve(p string) string {
+	if r, err := filepath.EvalSymlinks(p); err == nil {
+		return r
+	}
+	return p
+}
+
+// isServableAsset is true if the path is an image in or below
+// one of the paths the tutorial was loaded from.
+func (ws *Server) isServableAsset(p string) bool {
+	if !assetExtensions[strings.ToLower(filepath.Ext(p))] {
+		return false
+	}
+	p = resolve(p)
+	for _, root := range ws.loader.DataSet().AsPaths() {
+		dir := resolve(string(root))
+		if fi, err := os.Stat(dir); err == nil && !fi.IsDir() {
+			dir = filepath.Dir(dir)
+		}
+		if rel, err := filepath.Rel(dir, p); err == nil &&
+			rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// asset serves an image referenced by a lesson.
+func (ws *Server) asset(w http.ResponseWriter, r *http.Request) {
+	p := filepath.Clean(r.URL.Query().Get(program.AssetParam))
+	if !filepath.IsAbs(p) || !ws.isServableAsset(p) {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, p)
+}
+
 func (ws *Server) quit(w http.ResponseWriter, r *http.Request) {
 	close(ws.connReaperQuitCh)
 	fmt.Fprint(w, "\nbye bye\n")
@@ -378,6 +906,8 @@ const (
 
 // Look for and close idle websockets.
 func (ws *Server) closeStaleConnections() {
+	ws.connMu.Lock()
+	defer ws.connMu.Unlock()
 	for s, c := range ws.connections {
 		if time.Since(c.lastUse) > maxConnectionIdleTime {
 			glog.Infof(
@@ -398,36 +928,113 @@ func (ws *Server) reapConnections() {
 		case <-time.After(connectionScanWaitPeriod):
 		case <-ws.connReaperQuitCh:
 			glog.Info("Received quit, reaping all connections.")
+			ws.connMu.Lock()
 			for s, c := range ws.connections {
 				c.conn.Close()
 				delete(ws.connections, s)
 			}
+			ws.connMu.Unlock()
 			return
 		}
 	}
 }
 
-// Serve offers an http service.
-func (ws *Server) Serve(hostAndPort string) error {
+// router routes requests for the server's tutorial, with
+// paths relative to the server's prefix.
+func (ws *Server) router() *mux.Router {
 	r := mux.NewRouter()
 	r.HandleFunc("/_/r", ws.reload)
 	r.HandleFunc("/_/r/", ws.reload)
 	r.HandleFunc("/_/r/{gitclone:.*}", ws.reload)
-	r.HandleFunc("/_/runblock", ws.makeBlockRunner())
+	r.HandleFunc("/_/runblock", ws.allowCORS(ws.requireToken(ws.makeBlockRunner())))
+	r.HandleFunc("/_/runseq", ws.allowCORS(ws.requireToken(ws.runSequence)))
+	r.HandleFunc("/_/cancelseq", ws.allowCORS(ws.requireToken(ws.cancelSequence)))
+	r.HandleFunc("/_/status", ws.allowCORS(ws.showStatus))
 	r.HandleFunc("/_/s", ws.saveSession)
 	r.HandleFunc("/_/debug", ws.showDebugPage)
-	r.HandleFunc("/_/ws", ws.openWebSocket)
+	r.HandleFunc("/_/tree", ws.showTree)
+	r.HandleFunc("/_/feed", ws.showFeed)
+	r.HandleFunc("/sitemap.xml", ws.showSitemap)
+	r.HandleFunc("/_/glossary", ws.showGlossary)
+	r.HandleFunc("/progress", ws.requireToken(ws.showProgress))
+	r.HandleFunc("/join", ws.join)
+	r.HandleFunc("/classroom", ws.requireInstructor(ws.showClassroom))
+	r.HandleFunc("/classroom/jump", ws.requireInstructor(ws.jumpClass))
+	r.HandleFunc("/classroom/schedule", ws.requireInstructor(ws.scheduleClass))
+	r.HandleFunc("/classroom/release", ws.requireInstructor(ws.releaseSection))
+	r.HandleFunc("/_/schedule", ws.showSchedule)
+	r.HandleFunc("/metrics", ws.showMetrics)
+	r.HandleFunc("/_/ws", ws.requireToken(ws.openWebSocket))
+	r.HandleFunc("/_/results", ws.openResults)
+	r.HandleFunc("/_/reloads", ws.openReloads)
+	r.HandleFunc("/_/preview", requireOwnPage(ws.requireToken(ws.preview)))
+	r.HandleFunc("/_/propose", requireOwnPage(ws.requireToken(ws.propose)))
 	r.HandleFunc("/_/image", ws.image)
-	r.HandleFunc("/_/q", ws.quit)
+	r.HandleFunc(program.AssetPath, ws.asset)
+	r.PathPrefix(webapp.LibraryPath).Handler(http.StripPrefix(
+		webapp.LibraryPath, http.FileServer(http.FS(webapp.Libraries()))))
+	r.HandleFunc("/_/q", ws.requireToken(ws.quit))
 	r.HandleFunc("/favicon.ico", ws.favicon)
+	r.HandleFunc(`/raw/{path:.+\.md}`, ws.showRaw)
+	r.HandleFunc("/search", ws.search).Queries(webapp.KeySearch, "{q}")
+	r.HandleFunc(`/program/{path:.+\.(?:sh|tar\.gz)}`, ws.showProgram)
+	ws.addAPI(r)
 	r.PathPrefix("/").HandlerFunc(ws.showControlPage)
-	var err error
+	return r
+}
+
+// load loads the tutorial for the first time.
+func (ws *Server) load() error {
 	fmt.Printf("Loading from %s\n", ws.loader.DataSet())
-	ws.tutorial, err = ws.loader.Load()
-	if err != nil {
+	t, err := ws.loader.Load()
+	ws.setTutorial(t)
+	if err == nil && ws.watch && !ws.loader.IsRemote() {
+		err = ws.startWatching()
+	}
+	return err
+}
+
+// setTutorial serves the given tutorial, noting in the
+// feed how its lessons differ from the last one's, and
+// indexing its lessons for search.
+func (ws *Server) setTutorial(t model.Tutorial) {
+	var index *webapp.SearchIndex
+	if t != nil {
+		ws.feed.noteTutorial(t, time.Now())
+		index = webapp.NewSearchIndex(t)
+	}
+	ws.tutMu.Lock()
+	defer ws.tutMu.Unlock()
+	ws.tutorial = t
+	if index != nil {
+		ws.index = index
+	}
+}
+
+// currentTutorial is the tutorial served, which a reload may
+// replace at any time; a handler reads it once, and uses that.
+func (ws *Server) currentTutorial() model.Tutorial {
+	ws.tutMu.RLock()
+	defer ws.tutMu.RUnlock()
+	return ws.tutorial
+}
+
+// searchIndex indexes the lessons of the tutorial served.
+func (ws *Server) searchIndex() *webapp.SearchIndex {
+	ws.tutMu.RLock()
+	defer ws.tutMu.RUnlock()
+	return ws.index
+}
+
+// Serve offers an http service, over HTTPS if the TLS says
+// so, to those the Auth, if not nil, lets in.
+func (ws *Server) Serve(hostAndPort string, t TLS, a Auth) error {
+	if err := ws.load(); err != nil {
 		return err
 	}
-	fmt.Println("Serving at " + hostAndPort)
-	glog.Fatal(http.ListenAndServe(hostAndPort, r))
+	if t.IsOn() {
+		ws.secureCookies()
+	}
+	glog.Fatal(listenAndServe(hostAndPort, ws.countRequests(ws.router()), t, a))
 	return nil
 }
@@ -1,9 +1,14 @@
 package webserver
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
 	"github.com/monopole/mdrip/base"
 	"github.com/monopole/mdrip/loader"
-	"testing"
+	"github.com/monopole/mdrip/webapp"
 )
 
 func TestNewWebServer(t *testing.T) {
@@ -19,3 +24,68 @@ func TestNewWebServer(t *testing.T) {
 		return
 	}
 }
+
+func TestIsServableAsset(t *testing.T) {
+	dir := writeFiles(t, nil)
+	ds, err := base.NewDataSet([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := NewServer(loader.NewLoader(ds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{filepath.Join(dir, "img", "shot.png"), true},
+		{filepath.Join(dir, "lesson.md"), false},
+		{filepath.Join(dir, "..", "elsewhere.png"), false},
+		{"/etc/passwd", false},
+	}
+	for _, test := range tests {
+		if got := ws.isServableAsset(filepath.Clean(test.path)); got != test.want {
+			t.Errorf("isServableAsset(%s) = %v, want %v", test.path, got, test.want)
+		}
+	}
+}
+
+func TestInRange(t *testing.T) {
+	for _, test := range []struct {
+		arg  int
+		want bool
+	}{
+		{-1, false}, {0, true}, {2, true}, {3, false},
+	} {
+		w := httptest.NewRecorder()
+		if got := inRange(w, webapp.KeyLessonIndex, test.arg, 3); got != test.want {
+			t.Errorf("inRange(%d) = %v, want %v", test.arg, got, test.want)
+		}
+		if !test.want && w.Code != http.StatusBadRequest {
+			t.Errorf("inRange(%d) answered %d", test.arg, w.Code)
+		}
+	}
+}
+
+func TestLibraries(t *testing.T) {
+	ds, err := base.NewDataSet([]string{"hey"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := NewServer(loader.NewLoader(ds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := ws.router()
+	for p, want := range map[string]int{
+		webapp.LibraryPath + "README.md":   http.StatusOK,
+		webapp.LibraryPath + "nonesuch.js": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", p, nil))
+		if w.Code != want {
+			t.Errorf("%s: got %d, want %d", p, w.Code, want)
+		}
+	}
+}